@@ -11,13 +11,21 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/cache"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/analytics"
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/devmode"
+	"github.com/codeready-toolchain/registration-service/pkg/health"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
 	"github.com/codeready-toolchain/registration-service/pkg/server"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	signupservice "github.com/codeready-toolchain/registration-service/pkg/signup/service"
+	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
+	"github.com/codeready-toolchain/registration-service/pkg/webhooks"
 	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	errs "github.com/pkg/errors"
@@ -53,30 +61,50 @@ func main() {
 		})),
 	)
 
+	devMode := configuration.DevModeEnabled()
 	_, found := os.LookupEnv(commonconfig.WatchNamespaceEnvVar)
 	if !found {
-		panic(fmt.Errorf("%s not set", commonconfig.WatchNamespaceEnvVar))
-	}
-
-	// Get a config to talk to the apiserver
-	cfg, err := config.GetConfig()
-	if err != nil {
-		os.Exit(1)
+		if !devMode {
+			panic(fmt.Errorf("%s not set", commonconfig.WatchNamespaceEnvVar))
+		}
+		if err := os.Setenv(commonconfig.WatchNamespaceEnvVar, devmode.Namespace); err != nil {
+			panic(err.Error())
+		}
 	}
 
 	ctx := controllerruntime.SetupSignalHandler()
 
-	// create cached runtime client
-	cl, err := newCachedClient(ctx, cfg)
-	if err != nil {
-		panic(err.Error())
+	var cl client.Client
+	var informers cache.Informers
+	getMembersFunc := cluster.GetMemberClusters
+	if devMode {
+		// run against in-memory host and member clusters, see the devmode package
+		members, err := devmode.StartMemberClusters(ctx)
+		if err != nil {
+			panic(errs.Wrap(err, "failed to start the dev mode member clusters"))
+		}
+		if cl, err = devmode.NewHostClient(members); err != nil {
+			panic(errs.Wrap(err, "failed to create the dev mode host client"))
+		}
+		getMembersFunc = devmode.GetMemberClustersFunc(members)
+	} else {
+		// Get a config to talk to the apiserver
+		cfg, err := config.GetConfig()
+		if err != nil {
+			os.Exit(1)
+		}
+
+		// create cached runtime client
+		if cl, informers, err = newCachedClient(ctx, cfg); err != nil {
+			panic(err.Error())
+		}
 	}
 
 	configuration.SetClient(cl)
 	crtConfig := configuration.GetRegistrationServiceConfig()
 	crtConfig.Print()
 
-	if crtConfig.Verification().CaptchaEnabled() {
+	if crtConfig.Verification().CaptchaEnabled() && crtConfig.Verification().CaptchaProvider() == configuration.CaptchaProviderRecaptcha {
 		if err := createCaptchaFileFromSecret(crtConfig); err != nil {
 			panic(fmt.Sprintf("failed to create captcha file: %s", err.Error()))
 		}
@@ -88,18 +116,49 @@ func main() {
 	}
 	nsClient := namespaced.NewClient(cl, configuration.Namespace())
 
-	app := server.NewInClusterApplication(nsClient)
+	app := server.NewInClusterApplication(nsClient, signupservice.WithGetMembersFunc(getMembersFunc))
 	// Initialize toolchain cluster cache service
 	// let's cache the member clusters before we start the services,
 	// this will speed up the first request
-	cacheLog := controllerlog.Log.WithName("registration-service")
-	cluster.NewToolchainClusterService(cl, cacheLog, configuration.Namespace(), 5*time.Second)
-	cluster.GetMemberClusters()
+	var proxyOpts []proxy.ProxyOption
+	if !devMode {
+		cacheLog := controllerlog.Log.WithName("registration-service")
+		clusterService := cluster.NewToolchainClusterService(cl, cacheLog, configuration.Namespace(), 5*time.Second)
+		cluster.GetMemberClusters()
+
+		// refresh the cached member clusters when the tokens of their service accounts are rotated
+		refresher := proxy.NewClusterConfigRefresher(nsClient, &clusterService)
+		if err := refresher.WatchSecrets(ctx, informers); err != nil {
+			panic(errs.Wrap(err, "failed to watch the secrets of the member clusters"))
+		}
+		proxyOpts = append(proxyOpts, proxy.WithClusterConfigRefresher(refresher))
+	}
 
-	_, err = auth.InitializeDefaultTokenParser()
+	// keep the caches of the replicas of the proxy consistent
+	var claimer webhooks.Claimer
+	if sharedCacheURL := crtConfig.Proxy().SharedCacheURL(); sharedCacheURL != "" {
+		sharedCache, err := proxy.NewSharedCache(sharedCacheURL)
+		if err != nil {
+			panic(err.Error())
+		}
+		proxyOpts = append(proxyOpts, proxy.WithSharedCache(sharedCache))
+		// the events of the UserSignups are seen by all the replicas, but delivered to the webhooks and the analytics by the one
+		// which claimed them first
+		claimer = sharedCache
+	}
+
+	tokenParser, err := auth.InitializeDefaultTokenParser()
 	if err != nil {
 		panic(errs.Wrap(err, "failed to init default token parser"))
 	}
+	go tokenParser.RefreshKeys(ctx)
+	if devMode {
+		token, err := devmode.Token()
+		if err != nil {
+			panic(errs.Wrap(err, "failed to generate the dev mode token"))
+		}
+		log.Infof(nil, "Dev mode enabled, use the following token to send requests as the '%s' user: %s", devmode.Username, token)
+	}
 
 	// ---------------------------------------------
 	// API Proxy
@@ -108,22 +167,68 @@ func main() {
 	// API Proxy metrics server
 	proxyRegistry := prometheus.NewRegistry()
 	proxyMetrics := metrics.NewProxyMetrics(proxyRegistry)
-	proxyMetricsSrv := proxy.StartMetricsServer(proxyRegistry, proxy.ProxyMetricsPort)
 	// Proxy API server
-	p, err := proxy.NewProxy(nsClient, app, proxyMetrics, cluster.GetMemberClusters)
+	p, err := proxy.NewProxy(nsClient, app, proxyMetrics, getMembersFunc, proxyOpts...)
 	if err != nil {
 		panic(errs.Wrap(err, "failed to create proxy"))
 	}
-	proxySrv := p.StartProxy(proxy.DefaultPort)
+	proxyMetricsSrv := proxy.StartMetricsServer(proxyRegistry, proxy.ProxyMetricsPort, p)
+	if err := p.SyncSharedCache(ctx); err != nil {
+		// not fatal: the subscription is retried in the background, and the caches of this replica are not shared
+		// with the other replicas in the meantime
+		log.Error(nil, err, "failed to sync the shared cache of the proxy, retrying in the background")
+	}
+	// prime the routing caches before the proxy starts to serve requests, so that the service
+	// is not reported as ready (see the readiness endpoint) while they are still being populated
+	if err := p.WarmUpCaches(ctx); err != nil {
+		// not fatal: the caches are populated lazily by the first requests anyway
+		log.Error(nil, err, "failed to warm up the proxy caches")
+	}
+	proxyAddresses := proxy.ListenAddresses()
+	proxyHealthCheckPort, err := proxy.HealthCheckPort(proxyAddresses)
+	if err != nil {
+		panic(err.Error())
+	}
+	proxySrv, err := p.StartProxyListeners(proxyAddresses...)
+	if err != nil {
+		panic(err.Error())
+	}
 
 	// ---------------------------------------------
 	// Registration Service
 	// ---------------------------------------------
 	regsvcRegistry := prometheus.NewRegistry()
 	configuration.RegisterVersionMetrics(regsvcRegistry)
-	regsvcMetricsSrv, _ := server.StartMetricsServer(regsvcRegistry, server.RegSvcMetricsPort)
-	regsvcSrv := server.New(app)
-	err = regsvcSrv.SetupRoutes(proxy.DefaultPort, regsvcRegistry, nsClient)
+	signup.RegisterSlowStartMetrics(regsvcRegistry)
+	verificationservice.RegisterBlocklistMetrics(regsvcRegistry)
+	health.RegisterHealthMetrics(regsvcRegistry)
+	webhooks.RegisterWebhooksMetrics(regsvcRegistry)
+	regsvcMetricsSrv, regsvcMetricsRouter := server.StartMetricsServer(regsvcRegistry, server.RegSvcMetricsPort)
+
+	// history of the health transitions of the components, exposed on the metrics port only
+	healthHistory := health.NewHistory(crtConfig.HealthHistory().Size())
+	regsvcMetricsRouter.GET("/health/history", healthHistory.GetHandler)
+	// notify the webhooks and the analytics of the transitions of the UserSignups (not in dev mode, which has no informers)
+	if informers != nil {
+		if err := webhooks.WatchUserSignups(ctx, informers, webhooks.NewDispatcher(ctx, claimer)); err != nil {
+			panic(errs.Wrap(err, "failed to watch the UserSignups for the webhooks"))
+		}
+		// send the analytics events of the lifecycle of the UserSignups
+		if err := analytics.WatchUserSignups(ctx, informers, analytics.NewEmitter(&http.Client{Timeout: 10 * time.Second}), claimer); err != nil {
+			panic(errs.Wrap(err, "failed to watch the UserSignups for the analytics"))
+		}
+	}
+	ssoKeysURL := crtConfig.Auth().AuthClientPublicKeysURL()
+	if crtConfig.Environment() == "e2e-tests" {
+		// the public keys are not fetched from the SSO in the e2e-tests environment
+		ssoKeysURL = ""
+	}
+	go health.Monitor(ctx, healthHistory, crtConfig.HealthHistory().Interval(),
+		health.ProxyCheck(controller.NewHealthChecker(proxyHealthCheckPort)),
+		health.MemberClustersCheck(getMembersFunc),
+		health.SSOCheck(ssoKeysURL))
+	regsvcSrv := server.New(app, server.WithGetMembersFunc(getMembersFunc), server.WithBanListener(p.BanUser))
+	err = regsvcSrv.SetupRoutes(proxyHealthCheckPort, regsvcRegistry, nsClient)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -162,7 +267,7 @@ func gracefulShutdown(ctx context.Context, timeout time.Duration, hs ...*http.Se
 	}
 }
 
-func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, error) {
+func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, cache.Informers, error) {
 	scheme := runtime.NewScheme()
 	var AddToSchemes runtime.SchemeBuilder
 	addToSchemes := append(AddToSchemes,
@@ -170,7 +275,7 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 		toolchainv1alpha1.AddToScheme)
 	err := addToSchemes.AddToScheme(scheme)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	hostCluster, err := runtimecluster.New(cfg, func(options *runtimecluster.Options) {
@@ -179,7 +284,7 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 		options.Cache.DefaultNamespaces = map[string]cache.Config{configuration.Namespace(): {}}
 	})
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	go func() {
 		if err := hostCluster.Start(ctx); err != nil {
@@ -188,7 +293,7 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 	}()
 
 	if !hostCluster.GetCache().WaitForCacheSync(ctx) {
-		return nil, fmt.Errorf("unable to sync the cache of the client")
+		return nil, nil, fmt.Errorf("unable to sync the cache of the client")
 	}
 
 	// populate the cache backed by shared informers that are initialized lazily on the first call
@@ -204,19 +309,20 @@ func newCachedClient(ctx context.Context, cfg *rest.Config) (client.Client, erro
 		"ToolchainConfig":  &toolchainv1alpha1.ToolchainConfigList{},
 		"BannedUser":       &toolchainv1alpha1.BannedUserList{},
 		"ToolchainCluster": &toolchainv1alpha1.ToolchainClusterList{},
-		"Secret":           &corev1.SecretList{}}
+		"Secret":           &corev1.SecretList{},
+		"ConfigMap":        &corev1.ConfigMapList{}}
 
 	for resourceName := range objectsToList {
 		log.Infof(nil, "Syncing informer cache with %s resources", resourceName)
 		if err := hostCluster.GetClient().List(ctx, objectsToList[resourceName], client.InNamespace(configuration.Namespace())); err != nil {
 			log.Errorf(nil, err, "Informer cache sync failed for %s", resourceName)
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	log.Info(nil, "Informer caches synced")
 
-	return hostCluster.GetClient(), nil
+	return hostCluster.GetClient(), hostCluster.GetCache(), nil
 }
 
 func createCaptchaFileFromSecret(cfg configuration.RegistrationServiceConfig) error {
@@ -0,0 +1,111 @@
+// The proxy-replay command resolves the routes of the proxied requests captured by the proxy again, against the current
+// state of the host and member clusters, to debug the requests which were reported to be forwarded to the wrong cluster.
+//
+// The requests of a given user are captured at runtime with the '/proxyadmin/capture' endpoint of the admin port of the proxy, eg:
+//
+//	oc port-forward deployment/registration-service 8082 &
+//	curl -X PUT -H "Authorization: Bearer $TOKEN" -d '{"username":"smith","until":"2024-01-01T12:00:00Z"}' http://localhost:8082/proxyadmin/capture
+//
+// or by setting the REGISTRATION_SERVICE_PROXY_CAPTURE_USERNAME and REGISTRATION_SERVICE_PROXY_CAPTURE_UNTIL environment
+// variables of the registration-service deployment. The captured requests are then read from the logs of the proxy, eg:
+//
+//	oc logs deployment/registration-service | WATCH_NAMESPACE=toolchain-host-operator proxy-replay -changed-only
+//
+// The routes are printed as JSON, one request per line.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy"
+	"github.com/codeready-toolchain/registration-service/pkg/server"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	errs "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	controllerlog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+func main() {
+	logsFile := flag.String("file", "", "the file containing the logs of the proxy (defaults to the standard input)")
+	changedOnly := flag.Bool("changed-only", false, "only print the requests whose route changed")
+	flag.Parse()
+
+	log.Init("proxy-replay")
+	if err := run(*logsFile, *changedOnly, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(logsFile string, changedOnly bool, out io.Writer) error {
+	if _, found := os.LookupEnv(commonconfig.WatchNamespaceEnvVar); !found {
+		return fmt.Errorf("%s not set", commonconfig.WatchNamespaceEnvVar)
+	}
+
+	logs := io.Reader(os.Stdin)
+	if logsFile != "" {
+		f, err := os.Open(logsFile)
+		if err != nil {
+			return errs.Wrap(err, "unable to open the logs of the proxy")
+		}
+		defer f.Close()
+		logs = f
+	}
+	captured, err := proxy.ReadCapturedRequests(logs)
+	if err != nil {
+		return errs.Wrap(err, "unable to read the captured requests")
+	}
+
+	cl, err := newClient()
+	if err != nil {
+		return err
+	}
+	configuration.SetClient(cl)
+	nsClient := namespaced.NewClient(cl, configuration.Namespace())
+	cluster.NewToolchainClusterService(cl, controllerlog.Log.WithName("proxy-replay"), configuration.Namespace(), 5*time.Second)
+	replayer := proxy.NewReplayer(nsClient, server.NewInClusterApplication(nsClient), cluster.GetMemberClusters)
+
+	encoder := json.NewEncoder(out)
+	changed := 0
+	for _, c := range captured {
+		result := replayer.Replay(c)
+		if result.Changed {
+			changed++
+		} else if changedOnly {
+			continue
+		}
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d captured requests replayed, %d of them changed\n", len(captured), changed)
+	return nil
+}
+
+func newClient() (client.Client, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to get the config of the host cluster")
+	}
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := toolchainv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(cfg, client.Options{Scheme: scheme})
+}
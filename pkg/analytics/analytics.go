@@ -0,0 +1,97 @@
+// Package analytics sends server-side analytics events to the Segment destinations
+// defined in the Analytics configuration.
+package analytics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	errs "github.com/pkg/errors"
+)
+
+const segmentTrackURL = "https://api.segment.io/v1/track"
+
+// Event is an analytics event tracked for a given user
+type Event struct {
+	// Name is the name of the event, used to filter the destinations which the event is sent to
+	Name string
+	// UserID is the (anonymized) identifier of the user
+	UserID string
+	// MessageID is the optional identifier of the event, so that Segment ignores the events sent several times
+	MessageID string
+	// Properties are the additional properties of the event
+	Properties map[string]interface{}
+}
+
+type trackRequest struct {
+	MessageID  string                 `json:"messageId,omitempty"`
+	UserID     string                 `json:"userId"`
+	Event      string                 `json:"event"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Timestamp  string                 `json:"timestamp"`
+}
+
+// Emitter sends the analytics events to all the destinations which accept them
+type Emitter struct {
+	HTTPClient *http.Client
+}
+
+// NewEmitter returns a new Emitter using the given HTTP client
+func NewEmitter(httpClient *http.Client) *Emitter {
+	return &Emitter{
+		HTTPClient: httpClient,
+	}
+}
+
+// Emit sends the given event to all the configured destinations which accept it.
+// The event is sent to all the destinations even if some of them fail, and the first error is returned.
+func (e *Emitter) Emit(event Event) error {
+	cfg := configuration.GetRegistrationServiceConfig()
+	var firstErr error
+	for _, d := range cfg.Analytics().Destinations() {
+		if !d.Accepts(event.Name) {
+			continue
+		}
+		if err := e.send(d, event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (e *Emitter) send(destination configuration.AnalyticsDestination, event Event) error {
+	body, err := json.Marshal(trackRequest{
+		MessageID:  event.MessageID,
+		UserID:     event.UserID,
+		Event:      event.Name,
+		Properties: event.Properties,
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return errs.Wrapf(err, "failed to marshal the '%s' analytics event", event.Name)
+	}
+	req, err := http.NewRequest(http.MethodPost, segmentTrackURL, bytes.NewReader(body))
+	if err != nil {
+		return errs.Wrapf(err, "failed to create the request for the '%s' analytics destination", destination.Name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// the write key is the username of the basic authentication, with an empty password
+	req.SetBasicAuth(destination.SegmentWriteKey, "")
+
+	resp, err := e.HTTPClient.Do(req)
+	if err != nil {
+		return errs.Wrapf(err, "failed to send the '%s' event to the '%s' analytics destination", event.Name, destination.Name)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("the '%s' analytics destination returned status %d for the '%s' event: %s", destination.Name, resp.StatusCode, event.Name, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package analytics_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/analytics"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/h2non/gock.v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+type TestEmitterSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunEmitterSuite(t *testing.T) {
+	suite.Run(t, &TestEmitterSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestEmitterSuite) TestEmit() {
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Analytics().SegmentWriteKey("sandbox-key").
+		Analytics().DevSpacesSegmentWriteKey("devspaces-key"))
+	s.T().Setenv("REGISTRATION_SERVICE_ANALYTICS_DESTINATIONS", `[{"name":"console","segmentWriteKey":"console-key","events":["signup"]}]`)
+
+	httpClient := &http.Client{Transport: &http.Transport{}}
+	gock.InterceptClient(httpClient)
+	defer gock.Off()
+	emitter := analytics.NewEmitter(httpClient)
+
+	// records the write keys of the received requests
+	var writeKeys []string
+	gock.Observe(func(request *http.Request, _ gock.Mock) {
+		username, _, ok := request.BasicAuth()
+		require.True(s.T(), ok)
+		writeKeys = append(writeKeys, username)
+	})
+	defer gock.Observe(nil)
+
+	s.Run("event sent to all the accepting destinations", func() {
+		// given
+		writeKeys = nil
+		gock.New("https://api.segment.io").
+			Post("/v1/track").
+			Times(3).
+			Reply(http.StatusOK)
+
+		// when
+		err := emitter.Emit(analytics.Event{Name: "signup", UserID: "abc123"})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.ElementsMatch(s.T(), []string{"sandbox-key", "devspaces-key", "console-key"}, writeKeys)
+		assert.True(s.T(), gock.IsDone())
+	})
+
+	s.Run("filtered destination is skipped", func() {
+		// given
+		writeKeys = nil
+		gock.New("https://api.segment.io").
+			Post("/v1/track").
+			Times(2).
+			Reply(http.StatusOK)
+
+		// when
+		err := emitter.Emit(analytics.Event{Name: "verification", UserID: "abc123"})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.ElementsMatch(s.T(), []string{"sandbox-key", "devspaces-key"}, writeKeys)
+		assert.True(s.T(), gock.IsDone())
+	})
+
+	s.Run("request body", func() {
+		// given
+		var body map[string]interface{}
+		gock.New("https://api.segment.io").
+			Post("/v1/track").
+			AddMatcher(func(req *http.Request, _ *gock.Request) (bool, error) {
+				data, err := io.ReadAll(req.Body)
+				if err != nil {
+					return false, err
+				}
+				return true, json.Unmarshal(data, &body)
+			}).
+			Times(2).
+			Reply(http.StatusOK)
+
+		// when
+		err := emitter.Emit(analytics.Event{Name: "verification", UserID: "abc123", MessageID: "1234-5-verification", Properties: map[string]interface{}{"country": "CZ"}})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "abc123", body["userId"])
+		assert.Equal(s.T(), "1234-5-verification", body["messageId"])
+		assert.Equal(s.T(), "verification", body["event"])
+		assert.Equal(s.T(), map[string]interface{}{"country": "CZ"}, body["properties"])
+		assert.NotEmpty(s.T(), body["timestamp"])
+	})
+
+	s.Run("failing destination", func() {
+		// given
+		gock.New("https://api.segment.io").
+			Post("/v1/track").
+			Times(2).
+			Reply(http.StatusBadRequest).
+			BodyString("invalid write key")
+
+		// when
+		err := emitter.Emit(analytics.Event{Name: "verification", UserID: "abc123"})
+
+		// then
+		require.EqualError(s.T(), err, "the 'sandbox' analytics destination returned status 400 for the 'verification' event: invalid write key")
+	})
+}
+
+// roundTripperFunc sends the requests with the function
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// claimer claims the keys which were not claimed yet, as the shared cache of the proxy
+type claimer struct {
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+func (c *claimer) Claim(_ context.Context, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.claimed[key] {
+		return false
+	}
+	c.claimed[key] = true
+	return true
+}
+
+func (s *TestEmitterSuite) TestWatchUserSignups() {
+	// given
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Analytics().SegmentWriteKey("sandbox-key"))
+	s.T().Setenv("REGISTRATION_SERVICE_ANALYTICS_SIGNUP_EVENTS_ENABLED", "true")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// records the message IDs of the sent events
+	var mu sync.Mutex
+	var messageIDs []string
+	httpClient := &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := map[string]interface{}{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		messageIDs = append(messageIDs, body["messageId"].(string))
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})}
+	received := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, messageIDs...)
+	}
+
+	// the event of the second UserSignup was already claimed by another replica
+	claimer := &claimer{claimed: map[string]bool{"analytics:5678-6-signup.created": true}}
+	informers := &informertest.FakeInformers{}
+	require.NoError(s.T(), analytics.WatchUserSignups(ctx, informers, analytics.NewEmitter(httpClient), claimer))
+	informer, err := informers.FakeInformerFor(ctx, &toolchainv1alpha1.UserSignup{})
+	require.NoError(s.T(), err)
+	newUserSignup := func(name, uid, resourceVersion string) *toolchainv1alpha1.UserSignup {
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithName(name))
+		userSignup.UID = types.UID(uid)
+		userSignup.ResourceVersion = resourceVersion
+		return userSignup
+	}
+
+	// when
+	informer.Add(newUserSignup("smith", "1234", "5"))
+	informer.Add(newUserSignup("jones", "5678", "6"))
+	informer.Add(newUserSignup("brown", "9012", "7"))
+
+	// then
+	require.Eventually(s.T(), func() bool {
+		return len(received()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(s.T(), []string{"1234-5-signup.created", "9012-7-signup.created"}, received())
+}
@@ -0,0 +1,142 @@
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/webhooks"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// The names of the analytics events of the lifecycle of the UserSignups
+const (
+	// EventSignupCreated is sent when a UserSignup is created
+	EventSignupCreated = "Signup Created"
+	// EventVerificationStarted is sent when the first verification code or link is sent to a user who has to verify their account
+	EventVerificationStarted = "Verification Started"
+	// EventVerificationCompleted is sent when the user completed the verification required by their UserSignup
+	EventVerificationCompleted = "Verification Completed"
+	// EventSignupApproved is sent when a UserSignup is approved, automatically or manually
+	EventSignupApproved = "Signup Approved"
+	// EventSignupProvisioned is sent when the account of an approved UserSignup is provisioned
+	EventSignupProvisioned = "Signup Provisioned"
+)
+
+// queueSize is the number of events waiting to be sent, beyond which the new events are dropped
+const queueSize = 1000
+
+// lifecycleEvents are the analytics events by type of the events of the transitions of the UserSignups sent to the webhooks
+var lifecycleEvents = map[string]string{
+	webhooks.EventCreated:               EventSignupCreated,
+	webhooks.EventVerificationCompleted: EventVerificationCompleted,
+	webhooks.EventApproved:              EventSignupApproved,
+	webhooks.EventProvisioned:           EventSignupProvisioned,
+}
+
+// AnonymizedUserID returns the identifier of the user of the given UserSignup in the analytics events, ie. the SHA-256 of the name
+// of the UserSignup, so that all the events of a user are correlated without sending their username
+func AnonymizedUserID(userSignup *toolchainv1alpha1.UserSignup) string {
+	sum := sha256.Sum256([]byte(userSignup.Name))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignupEvents returns the analytics events of the transitions from the given old version of a UserSignup to the given new one.
+// The old version is nil when the UserSignup was created. The message IDs of the events are the IDs of the events of the webhooks,
+// which are the same for all the replicas of the registration service, so that Segment ignores the duplicates, eg. when the
+// replicas can't claim the events (see WatchUserSignups).
+func SignupEvents(oldSignup, newSignup *toolchainv1alpha1.UserSignup, now time.Time) []Event {
+	events := []Event{}
+	if oldSignup != nil && oldSignup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] == "" &&
+		newSignup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] != "" {
+		events = append(events, newSignupEvent(EventVerificationStarted,
+			fmt.Sprintf("%s-%s-verification_started", newSignup.UID, newSignup.ResourceVersion), newSignup))
+	}
+	for _, transition := range webhooks.Transitions(oldSignup, newSignup, now) {
+		if name, found := lifecycleEvents[transition.Type]; found {
+			events = append(events, newSignupEvent(name, transition.ID, newSignup))
+		}
+	}
+	return events
+}
+
+// newSignupEvent returns a new analytics event with the given name and message ID for the given UserSignup
+func newSignupEvent(name, messageID string, userSignup *toolchainv1alpha1.UserSignup) Event {
+	event := Event{
+		Name:      name,
+		UserID:    AnonymizedUserID(userSignup),
+		MessageID: messageID,
+	}
+	if socialEvent := userSignup.Labels[toolchainv1alpha1.UserSignupSocialEventLabelKey]; socialEvent != "" {
+		event.Properties = map[string]interface{}{"socialEvent": socialEvent}
+	}
+	return event
+}
+
+// WatchUserSignups sends the analytics events of the lifecycle of the UserSignups seen by the given informers with the given
+// emitter, if enabled (see the SignupEventsEnabled setting). The events are sent in the background, without blocking the informers.
+// The UserSignups of the initial list of the informer are not reported as created. Since the UserSignups are seen by all the
+// replicas of the registration service, each event is only sent by the replica which claimed it first with the given claimer,
+// unless nil.
+func WatchUserSignups(ctx context.Context, informers cache.Informers, emitter *Emitter, claimer webhooks.Claimer) error {
+	cfg := configuration.GetRegistrationServiceConfig().Analytics()
+	if !cfg.SignupEventsEnabled() || len(cfg.Destinations()) == 0 {
+		return nil
+	}
+	informer, err := informers.GetInformer(ctx, &toolchainv1alpha1.UserSignup{})
+	if err != nil {
+		return err
+	}
+	queue := make(chan Event, queueSize)
+	go emitAll(ctx, emitter, queue, claimer)
+	enqueue := func(events []Event) {
+		for _, event := range events {
+			select {
+			case queue <- event:
+			default:
+				log.Infof(nil, "dropping the '%s' analytics event '%s' since the queue is full", event.Name, event.MessageID)
+			}
+		}
+	}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if userSignup, ok := obj.(*toolchainv1alpha1.UserSignup); ok && !isInInitialList {
+				enqueue(SignupEvents(nil, userSignup, time.Now()))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSignup, ok := oldObj.(*toolchainv1alpha1.UserSignup)
+			newSignup, ok2 := newObj.(*toolchainv1alpha1.UserSignup)
+			if !ok || !ok2 || oldSignup.ResourceVersion == newSignup.ResourceVersion {
+				// periodic resync
+				return
+			}
+			enqueue(SignupEvents(oldSignup, newSignup, time.Now()))
+		},
+	})
+	return err
+}
+
+// emitAll sends the events of the given queue with the given emitter until the given context is done, except the events claimed
+// by another replica with the given claimer (if not nil)
+func emitAll(ctx context.Context, emitter *Emitter, queue <-chan Event, claimer webhooks.Claimer) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			if claimer != nil && !claimer.Claim(ctx, "analytics:"+event.MessageID) {
+				continue
+			}
+			if err := emitter.Emit(event); err != nil {
+				log.Error(nil, err, fmt.Sprintf("unable to send the '%s' analytics event '%s'", event.Name, event.MessageID))
+			}
+		}
+	}
+}
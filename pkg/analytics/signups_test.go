@@ -0,0 +1,96 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/analytics"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignupEvents(t *testing.T) {
+	now := time.Now()
+	namesOf := func(events []analytics.Event) []string {
+		names := []string{}
+		for _, event := range events {
+			names = append(names, event.Name)
+		}
+		return names
+	}
+
+	t.Run("created", func(t *testing.T) {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithName("smith"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupSocialEventLabelKey, "summit"))
+		userSignup.UID = "1234"
+		userSignup.ResourceVersion = "5"
+
+		// when
+		events := analytics.SignupEvents(nil, userSignup, now)
+
+		// then
+		require.Len(t, events, 1)
+		assert.Equal(t, analytics.Event{
+			Name:       analytics.EventSignupCreated,
+			UserID:     "6627835f988e2c5e50533d491163072d3f4f41f5c8b04630150debb3722ca2dd", // SHA-256 of 'smith'
+			MessageID:  "1234-5-signup.created",
+			Properties: map[string]interface{}{"socialEvent": "summit"},
+		}, events[0])
+	})
+
+	for name, tc := range map[string]struct {
+		old      *toolchainv1alpha1.UserSignup
+		new      *toolchainv1alpha1.UserSignup
+		expected []string
+	}{
+		"verification started": {
+			old: testusersignup.NewUserSignup(testusersignup.VerificationRequired()),
+			new: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "1")),
+			expected: []string{analytics.EventVerificationStarted},
+		},
+		"verification code sent again": {
+			old: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "1")),
+			new: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithAnnotation(toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, "2")),
+			expected: []string{},
+		},
+		"verification completed and approved": {
+			old: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueNotReady)),
+			new: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			expected: []string{analytics.EventVerificationCompleted, analytics.EventSignupApproved},
+		},
+		"provisioned": {
+			old: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			new: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			expected: []string{analytics.EventSignupProvisioned},
+		},
+		"deactivated": {
+			old: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			new: testusersignup.NewUserSignup(testusersignup.SignupComplete(toolchainv1alpha1.UserSignupUserDeactivatedReason),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueDeactivated)),
+			expected: []string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			events := analytics.SignupEvents(tc.old, tc.new, now)
+
+			// then
+			assert.Equal(t, tc.expected, namesOf(events))
+			for _, event := range events {
+				assert.Equal(t, analytics.AnonymizedUserID(tc.new), event.UserID)
+				assert.NotEmpty(t, event.MessageID)
+			}
+		})
+	}
+}
@@ -9,12 +9,18 @@ import (
 type SignupService interface {
 	Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, error)
 	GetSignup(ctx *gin.Context, username string, checkUserSignupCompleted bool) (*signup.Signup, error)
+	Deactivate(ctx *gin.Context, username string) error
+	UpdateProfile(ctx *gin.Context, username string, profile signup.Profile) error
+	AcceptTermsOfService(ctx *gin.Context, username, version string) error
+	Anonymize(ctx *gin.Context, username string) error
 }
 
 type VerificationService interface {
 	InitVerification(ctx *gin.Context, username, e164PhoneNumber, countryCode string) error
 	VerifyPhoneCode(ctx *gin.Context, username, code string) error
 	VerifyActivationCode(ctx *gin.Context, username, code string) error
+	InitEmailVerification(ctx *gin.Context, username string) error
+	VerifyEmailLink(ctx *gin.Context, token string) error
 }
 
 type Services interface {
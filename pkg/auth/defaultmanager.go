@@ -28,7 +28,12 @@ func InitializeDefaultTokenParser() (*TokenParser, error) {
 			returnErr = err
 			return
 		}
-		defaultTokenParser, returnErr = NewTokenParser(keyManager)
+		realmKeyManagers, err := NewRealmKeyManagers()
+		if err != nil {
+			returnErr = err
+			return
+		}
+		defaultTokenParser, returnErr = NewTokenParser(keyManager, WithRealmKeyManagers(realmKeyManagers))
 	})
 	if returnErr != nil {
 		return nil, returnErr
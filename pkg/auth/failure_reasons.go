@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// the reasons of the failures of the validation of the tokens, eg. to label the metrics of the rejected tokens
+const (
+	FailureReasonMissing      = "missing"
+	FailureReasonMalformed    = "malformed"
+	FailureReasonExpired      = "expired"
+	FailureReasonBadSignature = "bad_signature"
+	FailureReasonMissingClaim = "missing_claim"
+	FailureReasonRevoked      = "revoked"
+	FailureReasonInactive     = "inactive"
+	FailureReasonOther        = "other"
+)
+
+var (
+	// ErrMissingClaims is returned when a token doesn't contain the expected claims
+	ErrMissingClaims = errors.New("token does not comply to expected claims")
+	// ErrTokenRevoked is returned when a token was revoked, or was issued before the not-before policy of its realm
+	ErrTokenRevoked = errors.New("token is revoked")
+	// ErrTokenNotActive is returned when SSO reports that an introspected token is not active
+	ErrTokenNotActive = errors.New("token is not active")
+)
+
+// FailureReason returns the reason of the given failure of the validation of a token, as returned by the TokenParser
+func FailureReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return FailureReasonMalformed
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return FailureReasonExpired
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return FailureReasonBadSignature
+	case errors.Is(err, ErrMissingClaims):
+		return FailureReasonMissingClaim
+	case errors.Is(err, ErrTokenRevoked):
+		return FailureReasonRevoked
+	case errors.Is(err, ErrTokenNotActive):
+		return FailureReasonInactive
+	default:
+		return FailureReasonOther
+	}
+}
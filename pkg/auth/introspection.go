@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+// introspectionTimeout is the timeout of the requests to the introspection endpoint of SSO
+const introspectionTimeout = 10 * time.Second
+
+// introspection is the result of the introspection of a token, cached until it expires
+type introspection struct {
+	// claims are the claims of the token, or nil if the token is not active
+	claims    *TokenClaims
+	expiresAt time.Time
+}
+
+// Introspector validates the opaque access tokens with the introspection endpoint of SSO (see RFC 7662 and the
+// IntrospectionClientID setting), and caches the results by hash of the token (see the IntrospectionCacheTTL setting)
+type Introspector struct {
+	client *http.Client
+	mu     sync.Mutex
+	cache  map[string]introspection
+}
+
+// NewIntrospector returns a new Introspector, without any cached result
+func NewIntrospector() *Introspector {
+	return &Introspector{
+		client: NewSSOClient(introspectionTimeout),
+		cache:  map[string]introspection{},
+	}
+}
+
+// introspect returns the claims of the given opaque token, from the cache or else from the introspection endpoint of the default
+// realm of SSO. An error is returned if the token is not active.
+func (i *Introspector) introspect(token string, now time.Time) (*TokenClaims, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	hash := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(hash[:])
+	i.mu.Lock()
+	cached, found := i.cache[key]
+	i.mu.Unlock()
+	if !found || !now.Before(cached.expiresAt) {
+		claims, err := i.call(cfg, token)
+		if err != nil {
+			return nil, err
+		}
+		cached = introspection{claims: claims, expiresAt: now.Add(cfg.IntrospectionCacheTTL())}
+		if claims != nil && claims.ExpiresAt != nil && claims.ExpiresAt.Before(cached.expiresAt) {
+			cached.expiresAt = claims.ExpiresAt.Time
+		}
+		i.mu.Lock()
+		for key, entry := range i.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(i.cache, key)
+			}
+		}
+		i.cache[key] = cached
+		i.mu.Unlock()
+	}
+	if cached.claims == nil || (cached.claims.ExpiresAt != nil && !now.Before(cached.claims.ExpiresAt.Time)) {
+		return nil, ErrTokenNotActive
+	}
+	// a copy, since the claims are mapped by the caller
+	claims := *cached.claims
+	return &claims, nil
+}
+
+// call calls the introspection endpoint of the default realm of SSO with the given token, and returns its claims, or nil if the
+// token is not active
+func (i *Introspector) call(cfg configuration.AuthConfig, token string) (*TokenClaims, error) {
+	endpoint := cfg.Realms()[0].Issuer + "/protocol/openid-connect/token/introspect"
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(url.QueryEscape(cfg.IntrospectionClientID()), url.QueryEscape(cfg.IntrospectionClientSecret()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to introspect the token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to introspect the token: SSO responded with status %d", resp.StatusCode)
+	}
+	body := json.RawMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("unable to decode the introspection of the token: %w", err)
+	}
+	active := struct {
+		Active bool `json:"active"`
+	}{}
+	if err := json.Unmarshal(body, &active); err != nil {
+		return nil, fmt.Errorf("unable to decode the introspection of the token: %w", err)
+	}
+	if !active.Active {
+		return nil, nil
+	}
+	claims := &TokenClaims{}
+	if err := json.Unmarshal(body, claims); err != nil {
+		return nil, fmt.Errorf("unable to decode the introspection of the token: %w", err)
+	}
+	return claims, nil
+}
@@ -2,12 +2,24 @@ package auth
 
 import (
 	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
@@ -23,10 +35,10 @@ type KeyManagerConfiguration interface {
 	GetEnvironment() string
 }
 
-// PublicKey represents an RSA public key with a Key ID
+// PublicKey represents an RSA, EC or Ed25519 public key with a Key ID
 type PublicKey struct {
 	KeyID string
-	Key   *rsa.PublicKey
+	Key   crypto.PublicKey
 }
 
 // JSONKeys the remote keys encoded in a json document
@@ -34,17 +46,63 @@ type JSONKeys struct {
 	Keys []interface{} `json:"keys"`
 }
 
+// keysFetchTimeout is the maximum duration of a fetch of the public keys
+const keysFetchTimeout = 10 * time.Second
+
 // KeyManager manages the public keys for token validation.
+// The keys are fetched again periodically and when a token is signed with an unknown key,
+// so that the keys rotated by the SSO are taken into account without a restart.
 type KeyManager struct {
-	keyMap map[string]*rsa.PublicKey
+	// keysEndpointURL is the URL the keys are fetched from, or empty if the keys are not fetched (eg. in the e2e-tests environment)
+	keysEndpointURL string
+	mu              sync.RWMutex
+	keyMap          map[string]crypto.PublicKey
+	// staticKeys are the keys loaded from the file of static keys, if any, which are kept when the other keys are refreshed
+	staticKeys map[string]crypto.PublicKey
+	// refreshMu serializes the refreshes of the keys
+	refreshMu   sync.Mutex
+	lastRefresh time.Time
 }
 
-// NewKeyManager creates a new KeyManager and retrieves the public keys from the given URL.
+// NewKeyManager creates a new KeyManager, loads the static public keys of the PublicKeysFile, if any, and retrieves the public
+// keys from the AuthClientPublicKeysURL.
 func NewKeyManager() (*KeyManager, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	return newKeyManager(cfg.AuthClientPublicKeysURL(), cfg.PublicKeysFile())
+}
+
+// NewRealmKeyManagers creates the KeyManagers of the additional realms of SSO (see the Realms setting), by issuer,
+// and loads or retrieves their public keys.
+func NewRealmKeyManagers() (map[string]*KeyManager, error) {
+	keyManagers := map[string]*KeyManager{}
+	for _, realm := range configuration.GetRegistrationServiceConfig().Auth().Realms()[1:] {
+		km, err := newKeyManager(realm.PublicKeysURL, realm.PublicKeysFile)
+		if err != nil {
+			return nil, err
+		}
+		keyManagers[realm.Issuer] = km
+	}
+	return keyManagers, nil
+}
+
+// newKeyManager creates a new KeyManager, loads the static public keys of the given file, if any, and retrieves the public keys
+// from the given URL. The keys are not required to be retrieved when static keys are loaded: they are fetched again later on.
+func newKeyManager(keysEndpointURL, keysFile string) (*KeyManager, error) {
 	cfg := configuration.GetRegistrationServiceConfig()
-	keysEndpointURL := cfg.Auth().AuthClientPublicKeysURL()
 	km := &KeyManager{
-		keyMap: make(map[string]*rsa.PublicKey),
+		keyMap:     make(map[string]crypto.PublicKey),
+		staticKeys: make(map[string]crypto.PublicKey),
+	}
+	if keysFile != "" {
+		keys, err := loadStaticKeys(keysFile)
+		if err != nil {
+			return nil, err
+		}
+		log.Infof(nil, "%s static public keys loaded from %s", strconv.Itoa(len(keys)), keysFile)
+		for _, key := range keys {
+			km.staticKeys[key.KeyID] = key.Key
+			km.keyMap[key.KeyID] = key.Key
+		}
 	}
 	// fetch raw keys
 	if keysEndpointURL != "" {
@@ -58,9 +116,14 @@ func NewKeyManager() (*KeyManager, error) {
 			}
 		} else {
 			log.Infof(nil, "fetching public keys from url: %s", keysEndpointURL)
+			km.keysEndpointURL = keysEndpointURL
+			km.lastRefresh = time.Now()
 			keys, err := km.fetchKeys(keysEndpointURL)
 			if err != nil {
-				return nil, err
+				if len(km.staticKeys) == 0 {
+					return nil, err
+				}
+				log.Error(nil, err, "unable to fetch the public keys, using the static keys until they are fetched")
 			}
 			// add them to the kid map
 			for _, key := range keys {
@@ -74,12 +137,87 @@ func NewKeyManager() (*KeyManager, error) {
 }
 
 // Key retrieves the public key for a given kid.
-func (km *KeyManager) Key(kid string) (*rsa.PublicKey, error) {
+// The keys are fetched again if the kid is unknown, unless they were fetched
+// less than the PublicKeysMinRefreshInterval ago.
+func (km *KeyManager) Key(kid string) (crypto.PublicKey, error) {
+	if key, ok := km.key(kid); ok {
+		return key, nil
+	}
+	minInterval := configuration.GetRegistrationServiceConfig().Auth().PublicKeysMinRefreshInterval()
+	if err := km.refresh(minInterval); err != nil {
+		log.Error(nil, err, "unable to refresh the public keys after a token signed with an unknown key")
+	}
+	if key, ok := km.key(kid); ok {
+		return key, nil
+	}
+	return nil, errors.New("unknown kid")
+}
+
+func (km *KeyManager) key(kid string) (crypto.PublicKey, bool) {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
 	key, ok := km.keyMap[kid]
-	if !ok {
-		return nil, errors.New("unknown kid")
+	return key, ok
+}
+
+// KeysLoaded returns true if at least one public key was loaded
+func (km *KeyManager) KeysLoaded() bool {
+	km.mu.RLock()
+	defer km.mu.RUnlock()
+	return len(km.keyMap) > 0
+}
+
+// RefreshKeys fetches the keys again at the PublicKeysRefreshInterval, until the given context is done.
+// The keys are not refreshed if they are not fetched from a URL or if the interval is 0.
+func (km *KeyManager) RefreshKeys(ctx context.Context) {
+	interval := configuration.GetRegistrationServiceConfig().Auth().PublicKeysRefreshInterval()
+	if km.keysEndpointURL == "" || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := km.refresh(0); err != nil {
+				log.Error(nil, err, "unable to refresh the public keys")
+			}
+		}
 	}
-	return key, nil
+}
+
+// refresh replaces the keys with the keys fetched from the keys endpoint, unless they were fetched less than the given
+// interval ago. The current keys are kept if the keys can't be fetched.
+func (km *KeyManager) refresh(minInterval time.Duration) error {
+	if km.keysEndpointURL == "" {
+		return nil
+	}
+	km.refreshMu.Lock()
+	defer km.refreshMu.Unlock()
+	if time.Since(km.lastRefresh) < minInterval {
+		return nil
+	}
+	// the failed fetches count as well, so that an unavailable SSO isn't called for every request
+	km.lastRefresh = time.Now()
+	keys, err := km.fetchKeys(km.keysEndpointURL)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return errors.New("no public key obtained from remote service")
+	}
+	// the static keys are kept along with the fetched ones
+	keyMap := make(map[string]crypto.PublicKey, len(km.staticKeys)+len(keys))
+	maps.Copy(keyMap, km.staticKeys)
+	for _, key := range keys {
+		keyMap[key.KeyID] = key.Key
+	}
+	km.mu.Lock()
+	defer km.mu.Unlock()
+	km.keyMap = keyMap
+	return nil
 }
 
 // unmarshalKeys unmarshals keys from given JSON.
@@ -104,18 +242,69 @@ func (km *KeyManager) unmarshalKeys(jsonData []byte) ([]*PublicKey, error) {
 	return keys, nil
 }
 
-// unmarshalKey unmarshals a single key from a given JSON.
+// unmarshalKey unmarshals a single key from a given JSON. Only the RSA, EC and Ed25519 public keys are supported.
 func (km *KeyManager) unmarshalKey(jsonData []byte) (*PublicKey, error) {
 	key := &jose.JSONWebKey{}
 	err := key.UnmarshalJSON(jsonData)
 	if err != nil {
 		return nil, err
 	}
-	rsaKey, ok := key.Key.(*rsa.PublicKey)
-	if !ok {
-		return nil, errors.New("Key is not an *rsa.PublicKey")
+	switch publicKey := key.Key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return &PublicKey{key.KeyID, publicKey}, nil
+	default:
+		return nil, fmt.Errorf("key '%s' is not an RSA, EC or Ed25519 public key", key.KeyID)
 	}
-	return &PublicKey{key.KeyID, rsaKey}, nil
+}
+
+// loadStaticKeys loads the static public keys of the given file: either a JWKS document, or PEM-encoded public keys or certificates
+// whose key IDs are given by their 'kid' header, or else are their JWK thumbprint (see RFC 7638).
+func loadStaticKeys(path string) ([]*PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the static public keys: %w", err)
+	}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		return (&KeyManager{}).unmarshalKeys(trimmed)
+	}
+	var keys []*PublicKey
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		var key crypto.PublicKey
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			err = fmt.Errorf("unsupported PEM block '%s'", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid static public key: %w", err)
+		}
+		switch key.(type) {
+		case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		default:
+			return nil, errors.New("invalid static public key: not an RSA, EC or Ed25519 public key")
+		}
+		kid := block.Headers["kid"]
+		if kid == "" {
+			thumbprint, err := (&jose.JSONWebKey{Key: key}).Thumbprint(crypto.SHA256)
+			if err != nil {
+				return nil, err
+			}
+			kid = base64.RawURLEncoding.EncodeToString(thumbprint)
+		}
+		keys = append(keys, &PublicKey{KeyID: kid, Key: key})
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", path)
+	}
+	return keys, nil
 }
 
 // unmarshalls the keys from a byte array.
@@ -133,15 +322,7 @@ func (km *KeyManager) fetchKeysFromBytes(keysBytes []byte) ([]*PublicKey, error)
 // fetchKeys fetches the keys from the given URL, unmarshalling them.
 func (km *KeyManager) fetchKeys(keysEndpointURL string) ([]*PublicKey, error) {
 	// use httpClient to perform request
-	transport := http.DefaultTransport
-	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
-		transport = &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true, // nolint:gosec
-			},
-		}
-	}
-	httpClient := &http.Client{Transport: transport}
+	httpClient := NewSSOClient(keysFetchTimeout)
 	req, err := http.NewRequest("GET", keysEndpointURL, nil)
 	if err != nil {
 		return nil, err
@@ -1,11 +1,25 @@
 package auth_test
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/auth"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
@@ -21,6 +35,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/go-jose/go-jose.v2"
 )
 
 type TestKeyManagerSuite struct {
@@ -298,3 +313,296 @@ func (s *TestKeyManagerSuite) TestE2EKeyFetching() {
 		checkE2EKeysNotFound()
 	})
 }
+
+func (s *TestKeyManagerSuite) TestKeyRotation() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// given
+	tokengenerator := authsupport.NewTokenManager()
+	kid0 := uuid.NewString()
+	_, err := tokengenerator.AddPrivateKey(kid0)
+	require.NoError(s.T(), err)
+	var fetches atomic.Int32
+	var unavailable atomic.Bool
+	keyServer := tokengenerator.NewKeyServer()
+	defer keyServer.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		if unavailable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		keyServer.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.DefaultEnvironment).
+		Auth().AuthClientPublicKeysURL(ts.URL))
+	defer s.DefaultConfig()
+
+	s.Run("unknown kid triggers a refresh", func() {
+		// given
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_MIN_REFRESH_INTERVAL", "0")
+		kid1 := uuid.NewString()
+		_, err = tokengenerator.AddPrivateKey(kid1)
+		require.NoError(s.T(), err)
+		defer tokengenerator.RemovePrivateKey(kid1)
+
+		// when
+		_, err = keyManager.Key(kid1)
+
+		// then
+		require.NoError(s.T(), err)
+
+		s.Run("rotated key removed", func() {
+			// given
+			tokengenerator.RemovePrivateKey(kid0)
+			defer func() {
+				_, err := tokengenerator.AddPrivateKey(kid0)
+				require.NoError(s.T(), err)
+			}()
+
+			// when
+			_, err := keyManager.Key(uuid.NewString())
+
+			// then
+			require.EqualError(s.T(), err, "unknown kid")
+			_, err = keyManager.Key(kid0)
+			require.EqualError(s.T(), err, "unknown kid")
+			_, err = keyManager.Key(kid1)
+			require.NoError(s.T(), err)
+		})
+	})
+
+	s.Run("refreshes rate limited", func() {
+		// given
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		before := fetches.Load()
+
+		// when
+		for i := 0; i < 10; i++ {
+			_, err := keyManager.Key(uuid.NewString())
+			require.EqualError(s.T(), err, "unknown kid")
+		}
+
+		// then
+		assert.Equal(s.T(), before, fetches.Load())
+	})
+
+	s.Run("keys kept when the refresh fails", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_MIN_REFRESH_INTERVAL", "0")
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		unavailable.Store(true)
+		defer unavailable.Store(false)
+
+		// when
+		_, err = keyManager.Key(uuid.NewString())
+
+		// then
+		require.EqualError(s.T(), err, "unknown kid")
+		_, err = keyManager.Key(kid0)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), keyManager.KeysLoaded())
+	})
+}
+
+func (s *TestKeyManagerSuite) TestRefreshKeys() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// given
+	tokengenerator0 := authsupport.NewTokenManager()
+	kid0 := uuid.NewString()
+	_, err := tokengenerator0.AddPrivateKey(kid0)
+	require.NoError(s.T(), err)
+	keyServer0 := tokengenerator0.NewKeyServer()
+	defer keyServer0.Close()
+	tokengenerator1 := authsupport.NewTokenManager()
+	kid1 := uuid.NewString()
+	_, err = tokengenerator1.AddPrivateKey(kid1)
+	require.NoError(s.T(), err)
+	keyServer1 := tokengenerator1.NewKeyServer()
+	defer keyServer1.Close()
+	// the keys served before and after the rotation
+	var current atomic.Pointer[httptest.Server]
+	current.Store(keyServer0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current.Load().Config.Handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.DefaultEnvironment).
+		Auth().AuthClientPublicKeysURL(ts.URL))
+	defer s.DefaultConfig()
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_REFRESH_INTERVAL", "10ms")
+	keyManager, err := auth.NewKeyManager()
+	require.NoError(s.T(), err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		keyManager.RefreshKeys(ctx)
+		close(done)
+	}()
+
+	// when
+	current.Store(keyServer1)
+
+	// then
+	assert.Eventually(s.T(), func() bool {
+		_, err := keyManager.Key(kid0)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = keyManager.Key(kid1)
+	require.NoError(s.T(), err)
+	cancel()
+	<-done
+}
+
+func (s *TestKeyManagerSuite) TestStaticKeys() {
+	restore := commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	defer restore()
+
+	// given
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(s.T(), err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	encode := func(key crypto.PublicKey, headers map[string]string) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		require.NoError(s.T(), err)
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Headers: headers, Bytes: der})
+	}
+	ecThumbprint, err := (&jose.JSONWebKey{Key: &ecKey.PublicKey}).Thumbprint(crypto.SHA256)
+	require.NoError(s.T(), err)
+	ecKid := base64.RawURLEncoding.EncodeToString(ecThumbprint)
+	writeKeys := func(content []byte) string {
+		path := filepath.Join(s.T().TempDir(), "keys")
+		require.NoError(s.T(), os.WriteFile(path, content, 0600))
+		return path
+	}
+	// SSO is not reachable in the disconnected environments
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.DefaultEnvironment).
+		Auth().AuthClientPublicKeysURL(unreachable.URL))
+	defer s.DefaultConfig()
+
+	s.Run("PEM keys", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", writeKeys(append(encode(&rsaKey.PublicKey, map[string]string{"kid": "static-rsa"}), encode(&ecKey.PublicKey, nil)...)))
+
+		// when
+		keyManager, err := auth.NewKeyManager()
+
+		// then
+		require.NoError(s.T(), err)
+		key, err := keyManager.Key("static-rsa")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), &rsaKey.PublicKey, key)
+		key, err = keyManager.Key(ecKid)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), &ecKey.PublicKey, key)
+
+		s.Run("token validated", func() {
+			// given
+			tokenParser, err := auth.NewTokenParser(keyManager)
+			require.NoError(s.T(), err)
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+				"sub":                uuid.NewString(),
+				"preferred_username": "johnny",
+				"email":              "johnny@example.com",
+				"iat":                time.Now().Unix(),
+				"exp":                time.Now().Add(time.Hour).Unix(),
+			})
+			token.Header["kid"] = "static-rsa"
+			signed, err := token.SignedString(rsaKey)
+			require.NoError(s.T(), err)
+
+			// when
+			claims, err := tokenParser.FromString(signed)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "johnny", claims.PreferredUsername)
+		})
+	})
+
+	s.Run("JWKS document", func() {
+		// given
+		jwks, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &ecKey.PublicKey, KeyID: "static-ec", Algorithm: "ES256", Use: "sig"}}})
+		require.NoError(s.T(), err)
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", writeKeys(jwks))
+
+		// when
+		keyManager, err := auth.NewKeyManager()
+
+		// then
+		require.NoError(s.T(), err)
+		_, err = keyManager.Key("static-ec")
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("invalid file", func() {
+		for name, content := range map[string][]byte{
+			"no key":       []byte("not a key"),
+			"private key":  pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+			"invalid JWKS": []byte(`{"keys":`),
+		} {
+			s.Run(name, func() {
+				// given
+				s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", writeKeys(content))
+
+				// when
+				_, err := auth.NewKeyManager()
+
+				// then
+				require.Error(s.T(), err)
+			})
+		}
+
+		s.Run("missing", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", filepath.Join(s.T().TempDir(), "missing"))
+
+			// when
+			_, err := auth.NewKeyManager()
+
+			// then
+			require.ErrorContains(s.T(), err, "unable to read the static public keys")
+		})
+	})
+
+	s.Run("static keys kept along with the fetched ones", func() {
+		// given
+		tokengenerator := authsupport.NewTokenManager()
+		kid := uuid.NewString()
+		_, err := tokengenerator.AddPrivateKey(kid)
+		require.NoError(s.T(), err)
+		keyServer := tokengenerator.NewKeyServer()
+		defer keyServer.Close()
+		s.OverrideApplicationDefault(testconfig.RegistrationService().
+			Environment(configuration.DefaultEnvironment).
+			Auth().AuthClientPublicKeysURL(keyServer.URL))
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", writeKeys(encode(&rsaKey.PublicKey, map[string]string{"kid": "static-rsa"})))
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_MIN_REFRESH_INTERVAL", "0")
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = keyManager.Key(uuid.NewString()) // triggers a refresh
+
+		// then
+		require.EqualError(s.T(), err, "unknown kid")
+		_, err = keyManager.Key(kid)
+		require.NoError(s.T(), err)
+		_, err = keyManager.Key("static-rsa")
+		require.NoError(s.T(), err)
+	})
+}
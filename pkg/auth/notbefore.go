@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// pushNotBeforeAction is the action of the PushNotBeforeActions
+const pushNotBeforeAction = "PUSH_NOT_BEFORE"
+
+// PushNotBeforeAction is the admin action Keycloak pushes to the admin URL of the clients of a realm (eg. with the 'Push' button of
+// the 'Sessions' > 'Revocation' menu of the realm) when its not-before policy changes, signed with a key of the realm
+type PushNotBeforeAction struct {
+	// Action is 'PUSH_NOT_BEFORE'
+	Action string `json:"action"`
+	// Resource is the ID of the client the action is pushed to
+	Resource string `json:"resource"`
+	// Expiration is the time the action expires at, in seconds since the epoch
+	Expiration int64 `json:"expiration"`
+	// NotBeforeTime is the not-before time of the realm, in seconds since the epoch
+	NotBeforeTime int64 `json:"notBefore"`
+	jwt.RegisteredClaims
+}
+
+// NotBeforePolicies holds the not-before policies of the realms of SSO, by issuer: the tokens issued before the not-before time
+// of the realm of their issuer are rejected by the TokenParser, eg. once all the sessions of the realm were logged out in SSO
+type NotBeforePolicies struct {
+	mu       sync.RWMutex
+	policies map[string]time.Time
+}
+
+// NewNotBeforePolicies returns a new NotBeforePolicies, without any policy
+func NewNotBeforePolicies() *NotBeforePolicies {
+	return &NotBeforePolicies{
+		policies: map[string]time.Time{},
+	}
+}
+
+// Set sets the not-before time of the realm with the given issuer, unless the current one is later, so that a policy replayed
+// or received out of order doesn't let the revoked tokens in again. Returns true if the not-before time was set.
+func (n *NotBeforePolicies) Set(issuer string, notBefore time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if current, found := n.policies[issuer]; found && !notBefore.After(current) {
+		return false
+	}
+	n.policies[issuer] = notBefore
+	return true
+}
+
+// Get returns the not-before time of the realm with the given issuer, if any
+func (n *NotBeforePolicies) Get(issuer string) (time.Time, bool) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	notBefore, found := n.policies[issuer]
+	return notBefore, found
+}
+
+// IsBefore returns true if the token with the given claims was issued before the not-before time of the realm of its issuer, or
+// before the given cutoff time, if not zero (see the TokensNotBefore setting). The tokens without an issue time are then rejected.
+func (n *NotBeforePolicies) IsBefore(claims *TokenClaims, cutoff time.Time) bool {
+	notBefore, _ := n.Get(claims.Issuer)
+	if cutoff.After(notBefore) {
+		notBefore = cutoff
+	}
+	if notBefore.IsZero() {
+		return false
+	}
+	return claims.IssuedAt == nil || claims.IssuedAt.Before(notBefore)
+}
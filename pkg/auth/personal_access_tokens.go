@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// PersonalAccessTokenPrefix is the prefix of the personal access tokens, which tells them apart from the SSO tokens
+	// (and lets the secret scanners spot them)
+	PersonalAccessTokenPrefix = "sandbox_pat_"
+	// PersonalAccessTokenIssuer is the `iss` claim of the personal access tokens
+	PersonalAccessTokenIssuer = "registration-service"
+
+	// maxPersonalAccessTokenNameLength is the maximum length of the name of a personal access token
+	maxPersonalAccessTokenNameLength = 100
+	// exchangedTokenName is the name of the tokens issued in exchange for the SSO tokens (see ExchangeToken)
+	exchangedTokenName = "token-exchange"
+)
+
+// The scopes of the personal access tokens
+const (
+	// ReadScope allows the read-only requests, ie. the 'get', 'list' and 'watch' requests
+	ReadScope = "read"
+	// WriteScope allows all the requests
+	WriteScope = "write"
+	// WorkspaceScopePrefix is the prefix of the scopes limiting the requests to a workspace, eg. 'workspace:smith-dev'.
+	// The requests are allowed in all the workspaces of the user if there is no such scope.
+	WorkspaceScopePrefix = "workspace:"
+)
+
+const (
+	personalAccessTokenNameClaim   = "token_name"
+	personalAccessTokenScopesClaim = "scopes"
+)
+
+// PersonalAccessToken is a named, scoped and expiring token issued for a user by the registration service, which the proxy accepts
+// in place of the SSO tokens of the user, eg. for the CI systems which can't perform the SSO flows
+type PersonalAccessToken struct {
+	// ID is the `jti` claim of the token, which the token can be revoked with (see Revocations)
+	ID string `json:"id"`
+	// Name is the name of the token given by the user, eg. 'tekton-ci'
+	Name string `json:"name"`
+	// Scopes are the scopes of the token, eg. ['read', 'workspace:smith-dev']
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is the time the token expires
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Token is the token itself, only returned once, when the token is issued
+	Token string `json:"token,omitempty"`
+}
+
+// personalAccessTokenClaims are the claims of the personal access tokens: the claims of the SSO token of the user along with
+// the name and the scopes of the token
+type personalAccessTokenClaims struct {
+	TokenClaims
+	TokenName string   `json:"token_name"`
+	Scopes    []string `json:"scopes"`
+}
+
+// ValidateScopes returns an error unless the given scopes of a personal access token contain the read or the write scope,
+// along with workspace scopes only
+func ValidateScopes(scopes []string) error {
+	if !slices.Contains(scopes, ReadScope) && !slices.Contains(scopes, WriteScope) {
+		return fmt.Errorf("either the '%s' or the '%s' scope is required", ReadScope, WriteScope)
+	}
+	for _, scope := range scopes {
+		if workspace, found := strings.CutPrefix(scope, WorkspaceScopePrefix); found && workspace == "" {
+			return fmt.Errorf("the name of the workspace is missing in the '%s' scope", scope)
+		} else if !found && scope != ReadScope && scope != WriteScope {
+			return fmt.Errorf("unknown scope '%s'", scope)
+		}
+	}
+	return nil
+}
+
+// IssuePersonalAccessToken issues a personal access token with the given name and scopes for the user of the given claims of
+// an SSO token, until the given time
+func IssuePersonalAccessToken(user *TokenClaims, name string, scopes []string, expiresAt time.Time) (PersonalAccessToken, error) {
+	cfg := configuration.GetRegistrationServiceConfig().PersonalAccessTokens()
+	if !cfg.Enabled() {
+		return PersonalAccessToken{}, errors.New("the personal access tokens are not enabled")
+	}
+	if name == "" || len(name) > maxPersonalAccessTokenNameLength {
+		return PersonalAccessToken{}, fmt.Errorf("the name of the token is required and can't be longer than %d characters", maxPersonalAccessTokenNameLength)
+	}
+	if err := ValidateScopes(scopes); err != nil {
+		return PersonalAccessToken{}, err
+	}
+	if now := time.Now(); !expiresAt.After(now) || expiresAt.Sub(now) > cfg.MaxLifetime() {
+		return PersonalAccessToken{}, fmt.Errorf("the token must expire within %s", cfg.MaxLifetime())
+	}
+	return signPersonalAccessToken(user, name, scopes, expiresAt)
+}
+
+// ExchangeToken issues a short-lived token limited to the given workspace with the given read or write scope, in exchange for
+// the SSO token of the user of the given claims (see RFC 8693). The token is a personal access token with the workspace scope,
+// so that the proxy accepts it and enforces its scopes the same way, and it expires after the ExchangeLifetime.
+func ExchangeToken(user *TokenClaims, workspace, scope string) (PersonalAccessToken, error) {
+	cfg := configuration.GetRegistrationServiceConfig().PersonalAccessTokens()
+	if !cfg.Enabled() {
+		return PersonalAccessToken{}, errors.New("the personal access tokens are not enabled")
+	}
+	if workspace == "" {
+		return PersonalAccessToken{}, errors.New("the workspace is required")
+	}
+	if scope != ReadScope && scope != WriteScope {
+		return PersonalAccessToken{}, fmt.Errorf("either the '%s' or the '%s' scope is required", ReadScope, WriteScope)
+	}
+	return signPersonalAccessToken(user, exchangedTokenName, []string{scope, WorkspaceScopePrefix + workspace}, time.Now().Add(cfg.ExchangeLifetime()))
+}
+
+// signPersonalAccessToken signs a personal access token with the given name and scopes for the user of the given claims, until the given time
+func signPersonalAccessToken(user *TokenClaims, name string, scopes []string, expiresAt time.Time) (PersonalAccessToken, error) {
+	now := time.Now()
+	claims := personalAccessTokenClaims{
+		TokenClaims: TokenClaims{
+			PreferredUsername: user.PreferredUsername,
+			GivenName:         user.GivenName,
+			FamilyName:        user.FamilyName,
+			Email:             user.Email,
+			EmailVerified:     user.EmailVerified,
+			Company:           user.Company,
+			OriginalSub:       user.OriginalSub,
+			UserID:            user.UserID,
+			AccountID:         user.AccountID,
+			AccountNumber:     user.AccountNumber,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        uuid.NewString(),
+				Issuer:    PersonalAccessTokenIssuer,
+				Subject:   user.Subject,
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		},
+		TokenName: name,
+		Scopes:    scopes,
+	}
+	signingKey := configuration.GetRegistrationServiceConfig().PersonalAccessTokens().SigningKey()
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(signingKey))
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+	return PersonalAccessToken{
+		ID:        claims.ID,
+		Name:      name,
+		Scopes:    scopes,
+		ExpiresAt: claims.ExpiresAt.Time,
+		Token:     PersonalAccessTokenPrefix + signed,
+	}, nil
+}
+
+// IsPersonalAccessToken returns true if the given token is a personal access token
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PersonalAccessTokenPrefix)
+}
+
+// IsPersonalAccessTokenClaims returns true if the given claims are the ones of a personal access token
+func IsPersonalAccessTokenClaims(claims *TokenClaims) bool {
+	return claims.Issuer == PersonalAccessTokenIssuer
+}
+
+// PersonalAccessTokenName returns the name of the personal access token of the given claims
+func PersonalAccessTokenName(claims *TokenClaims) string {
+	if names := claims.StringValues(personalAccessTokenNameClaim); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// PersonalAccessTokenScopes returns the scopes of the personal access token of the given claims
+func PersonalAccessTokenScopes(claims *TokenClaims) []string {
+	return claims.StringValues(personalAccessTokenScopesClaim)
+}
+
+// FromPersonalAccessToken parses a personal access token, validates its signature and returns its claims
+func (tp *TokenParser) FromPersonalAccessToken(token string) (*TokenClaims, error) {
+	signingKey := configuration.GetRegistrationServiceConfig().PersonalAccessTokens().SigningKey()
+	if signingKey == "" {
+		return nil, errors.New("the personal access tokens are not enabled")
+	}
+	jwtEncoded, found := strings.CutPrefix(token, PersonalAccessTokenPrefix)
+	if !found {
+		return nil, errors.New("not a personal access token")
+	}
+	parsed, err := jwt.ParseWithClaims(
+		jwtEncoded,
+		&TokenClaims{},
+		func(_ *jwt.Token) (interface{}, error) {
+			return []byte(signingKey), nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(PersonalAccessTokenIssuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := tp.validClaims(parsed)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateScopes(PersonalAccessTokenScopes(claims)); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMissingClaims, err)
+	}
+	return claims, nil
+}
@@ -0,0 +1,193 @@
+package auth_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestPersonalAccessTokensSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunPersonalAccessTokensSuite(t *testing.T) {
+	suite.Run(t, &TestPersonalAccessTokensSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestPersonalAccessTokensSuite) TestPersonalAccessTokens() {
+	// given
+	tokenParser, err := auth.NewTokenParser(&auth.KeyManager{})
+	require.NoError(s.T(), err)
+	user := &auth.TokenClaims{
+		PreferredUsername: "smith",
+		Email:             "smith@redhat.com",
+		UserID:            "12345",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      "0a6b5b3e",
+			Subject: "f8c6ad6e",
+		},
+	}
+	expiresAt := time.Now().Add(time.Hour)
+
+	s.Run("not enabled", func() {
+		// when
+		_, err := auth.IssuePersonalAccessToken(user, "tekton-ci", []string{auth.ReadScope}, expiresAt)
+
+		// then
+		require.EqualError(s.T(), err, "the personal access tokens are not enabled")
+	})
+
+	s.SetRegistrationServiceSecret(map[string]string{configuration.PersonalAccessTokensSigningKeyKey: "s3cr3t"})
+
+	s.Run("issued and parsed", func() {
+		// when
+		token, err := auth.IssuePersonalAccessToken(user, "tekton-ci", []string{auth.ReadScope, "workspace:smith-dev"}, expiresAt)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), auth.IsPersonalAccessToken(token.Token))
+		assert.Equal(s.T(), "tekton-ci", token.Name)
+		assert.NotEqual(s.T(), user.ID, token.ID)
+
+		claims, err := tokenParser.FromPersonalAccessToken(token.Token)
+		require.NoError(s.T(), err)
+		assert.True(s.T(), auth.IsPersonalAccessTokenClaims(claims))
+		assert.Equal(s.T(), token.ID, claims.ID)
+		assert.Equal(s.T(), user.Subject, claims.Subject)
+		assert.Equal(s.T(), user.PreferredUsername, claims.PreferredUsername)
+		assert.Equal(s.T(), user.Email, claims.Email)
+		assert.Equal(s.T(), user.UserID, claims.UserID)
+		assert.Equal(s.T(), "tekton-ci", auth.PersonalAccessTokenName(claims))
+		assert.Equal(s.T(), []string{auth.ReadScope, "workspace:smith-dev"}, auth.PersonalAccessTokenScopes(claims))
+		assert.False(s.T(), auth.IsPersonalAccessTokenClaims(user))
+
+		s.Run("revoked", func() {
+			// given
+			require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{TokenID: token.ID}))
+			defer tokenParser.Revocations().Lift("tokens/" + token.ID)
+
+			// when
+			_, err := tokenParser.FromPersonalAccessToken(token.Token)
+
+			// then
+			require.EqualError(s.T(), err, "token is revoked")
+		})
+
+		s.Run("signing key changed", func() {
+			// given
+			s.SetRegistrationServiceSecret(map[string]string{configuration.PersonalAccessTokensSigningKeyKey: "0th3r"})
+
+			// when
+			_, err := tokenParser.FromPersonalAccessToken(token.Token)
+
+			// then
+			require.ErrorIs(s.T(), err, jwt.ErrTokenSignatureInvalid)
+		})
+
+		s.Run("not a personal access token", func() {
+			// when
+			_, err := tokenParser.FromPersonalAccessToken(strings.TrimPrefix(token.Token, auth.PersonalAccessTokenPrefix))
+
+			// then
+			require.EqualError(s.T(), err, "not a personal access token")
+		})
+	})
+
+	s.Run("exchanged", func() {
+		// when
+		token, err := auth.ExchangeToken(user, "smith-dev", auth.ReadScope)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "token-exchange", token.Name)
+		assert.Equal(s.T(), []string{auth.ReadScope, "workspace:smith-dev"}, token.Scopes)
+		assert.WithinDuration(s.T(), time.Now().Add(15*time.Minute), token.ExpiresAt, time.Minute)
+		claims, err := tokenParser.FromPersonalAccessToken(token.Token)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), user.Subject, claims.Subject)
+		assert.Equal(s.T(), token.Scopes, auth.PersonalAccessTokenScopes(claims))
+
+		s.Run("without workspace", func() {
+			// when
+			_, err := auth.ExchangeToken(user, "", auth.ReadScope)
+
+			// then
+			require.EqualError(s.T(), err, "the workspace is required")
+		})
+
+		s.Run("with unknown scope", func() {
+			// when
+			_, err := auth.ExchangeToken(user, "smith-dev", "workspace:smith-dev")
+
+			// then
+			require.EqualError(s.T(), err, "either the 'read' or the 'write' scope is required")
+		})
+	})
+
+	s.Run("not issued", func() {
+		for name, tc := range map[string]struct {
+			tokenName string
+			scopes    []string
+			expiresAt time.Time
+			err       string
+		}{
+			"no name": {
+				scopes:    []string{auth.WriteScope},
+				expiresAt: expiresAt,
+				err:       "the name of the token is required and can't be longer than 100 characters",
+			},
+			"name too long": {
+				tokenName: strings.Repeat("a", 101),
+				scopes:    []string{auth.WriteScope},
+				expiresAt: expiresAt,
+				err:       "the name of the token is required and can't be longer than 100 characters",
+			},
+			"no read or write scope": {
+				tokenName: "tekton-ci",
+				scopes:    []string{"workspace:smith-dev"},
+				expiresAt: expiresAt,
+				err:       "either the 'read' or the 'write' scope is required",
+			},
+			"unknown scope": {
+				tokenName: "tekton-ci",
+				scopes:    []string{auth.ReadScope, "admin"},
+				expiresAt: expiresAt,
+				err:       "unknown scope 'admin'",
+			},
+			"workspace missing": {
+				tokenName: "tekton-ci",
+				scopes:    []string{auth.ReadScope, "workspace:"},
+				expiresAt: expiresAt,
+				err:       "the name of the workspace is missing in the 'workspace:' scope",
+			},
+			"already expired": {
+				tokenName: "tekton-ci",
+				scopes:    []string{auth.ReadScope},
+				expiresAt: time.Now().Add(-time.Minute),
+				err:       "the token must expire within 2160h0m0s",
+			},
+			"lifetime too long": {
+				tokenName: "tekton-ci",
+				scopes:    []string{auth.ReadScope},
+				expiresAt: time.Now().Add(91 * 24 * time.Hour),
+				err:       "the token must expire within 2160h0m0s",
+			},
+		} {
+			s.Run(name, func() {
+				// when
+				_, err := auth.IssuePersonalAccessToken(user, tc.tokenName, tc.scopes, tc.expiresAt)
+
+				// then
+				require.EqualError(s.T(), err, tc.err)
+			})
+		}
+	})
+}
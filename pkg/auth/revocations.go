@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Revocation revokes the tokens with a given ID (the `jti` claim), or all the tokens of a given subject (the `sub` claim),
+// eg. to cut off a compromised token before it expires
+type Revocation struct {
+	// TokenID is the `jti` claim of the revoked token
+	TokenID string `json:"tokenID,omitempty"`
+	// Subject is the `sub` claim of the user whose tokens are all revoked
+	Subject string `json:"subject,omitempty"`
+	// ExpiresAt is the time the revocation is lifted, eg. once the revoked token expired. The revocation is never lifted if not set.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Key returns the key of the revocation, ie. 'tokens/<jti>' or 'subjects/<sub>'
+func (r Revocation) Key() string {
+	if r.TokenID != "" {
+		return "tokens/" + r.TokenID
+	}
+	return "subjects/" + r.Subject
+}
+
+// Validate returns an error unless the revocation revokes either a token or a subject
+func (r Revocation) Validate() error {
+	switch {
+	case r.TokenID == "" && r.Subject == "":
+		return errors.New("either the ID of the token or the subject is required")
+	case r.TokenID != "" && r.Subject != "":
+		return errors.New("the ID of the token and the subject can't be both revoked at once")
+	}
+	return nil
+}
+
+func (r Revocation) expired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// Revocations holds the revoked tokens and subjects, which are rejected by the TokenParser even if their tokens are valid
+type Revocations struct {
+	mu          sync.RWMutex
+	revocations map[string]Revocation
+}
+
+// NewRevocations returns a new Revocations, without any revoked token or subject
+func NewRevocations() *Revocations {
+	return &Revocations{
+		revocations: map[string]Revocation{},
+	}
+}
+
+// Revoke adds the given revocation, replacing the revocation of the same token or subject, if any.
+// The expired revocations are removed along the way.
+func (r *Revocations) Revoke(revocation Revocation) error {
+	if err := revocation.Validate(); err != nil {
+		return err
+	}
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, existing := range r.revocations {
+		if existing.expired(now) {
+			delete(r.revocations, key)
+		}
+	}
+	r.revocations[revocation.Key()] = revocation
+	return nil
+}
+
+// Lift removes the revocation with the given key (see Revocation.Key). Returns false if there was no such revocation.
+func (r *Revocations) Lift(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, found := r.revocations[key]
+	delete(r.revocations, key)
+	return found
+}
+
+// List returns the revocations which are not expired, sorted by key
+func (r *Revocations) List() []Revocation {
+	now := time.Now()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	revocations := make([]Revocation, 0, len(r.revocations))
+	for _, revocation := range r.revocations {
+		if !revocation.expired(now) {
+			revocations = append(revocations, revocation)
+		}
+	}
+	slices.SortFunc(revocations, func(a, b Revocation) int {
+		return strings.Compare(a.Key(), b.Key())
+	})
+	return revocations
+}
+
+// IsRevoked returns true if the token with the given claims, or its subject, is revoked at the given time
+func (r *Revocations) IsRevoked(claims *TokenClaims, now time.Time) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.revocations) == 0 {
+		return false
+	}
+	keys := make([]string, 0, 2)
+	if claims.ID != "" {
+		keys = append(keys, Revocation{TokenID: claims.ID}.Key())
+	}
+	if claims.Subject != "" {
+		keys = append(keys, Revocation{Subject: claims.Subject}.Key())
+	}
+	for _, key := range keys {
+		if revocation, found := r.revocations[key]; found && !revocation.expired(now) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+// ssoTransportSettings are the settings the transport of the requests to SSO was created with
+type ssoTransportSettings struct {
+	proxyURL     string
+	caBundleFile string
+	insecure     bool
+}
+
+// ssoTransports keeps the transport of the requests to SSO, so that the connections are reused until the settings change
+var ssoTransports = struct {
+	sync.Mutex
+	settings  ssoTransportSettings
+	transport http.RoundTripper
+}{}
+
+// ssoTransport returns the transport of the requests to SSO, for the current SSOProxyURL and SSOCABundleFile settings.
+// The certificate of SSO is not verified outside the production environment, and the default transport is used in the production
+// environment when there is neither a proxy nor a CA bundle.
+func ssoTransport() (http.RoundTripper, error) {
+	cfg := configuration.GetRegistrationServiceConfig()
+	settings := ssoTransportSettings{
+		proxyURL:     cfg.Auth().SSOProxyURL(),
+		caBundleFile: cfg.Auth().SSOCABundleFile(),
+		insecure:     !cfg.IsProdEnvironment(),
+	}
+	ssoTransports.Lock()
+	defer ssoTransports.Unlock()
+	if ssoTransports.transport != nil && ssoTransports.settings == settings {
+		return ssoTransports.transport, nil
+	}
+	if settings == (ssoTransportSettings{}) {
+		return http.DefaultTransport, nil
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	if settings.proxyURL != "" {
+		proxyURL, err := url.Parse(settings.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL of the proxy of SSO: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	transport.TLSClientConfig = &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: settings.insecure, // nolint:gosec
+	}
+	if settings.caBundleFile != "" {
+		bundle, err := os.ReadFile(settings.caBundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the CA bundle of SSO: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("no CA certificate found in %s", settings.caBundleFile)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	if previous, ok := ssoTransports.transport.(*http.Transport); ok {
+		previous.CloseIdleConnections()
+	}
+	ssoTransports.settings = settings
+	ssoTransports.transport = transport
+	return transport, nil
+}
+
+// ssoRoundTripper sends the requests with the transport of the current settings (see ssoTransport)
+type ssoRoundTripper struct{}
+
+func (ssoRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport, err := ssoTransport()
+	if err != nil {
+		return nil, err
+	}
+	return transport.RoundTrip(req)
+}
+
+// NewSSOClient returns a new client of SSO with the given timeout, which sends the requests through the SSOProxyURL, if any,
+// and trusts the CA certificates of the SSOCABundleFile, if any
+func NewSSOClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: ssoRoundTripper{}, Timeout: timeout}
+}
@@ -0,0 +1,116 @@
+package auth_test
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestSSOClientSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunSSOClientSuite(t *testing.T) {
+	suite.Run(t, &TestSSOClientSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestSSOClientSuite) TestSSOClient() {
+	// given
+	sso := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer sso.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(string(testconfig.Prod)))
+	defer s.DefaultConfig()
+	client := auth.NewSSOClient(5 * time.Second)
+
+	s.Run("unknown CA rejected in production", func() {
+		// when
+		_, err := client.Get(sso.URL)
+
+		// then
+		require.Error(s.T(), err)
+	})
+
+	s.Run("CA of the bundle trusted", func() {
+		// given
+		bundle := filepath.Join(s.T().TempDir(), "ca.pem")
+		require.NoError(s.T(), os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: sso.Certificate().Raw}), 0600))
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_SSO_CA_BUNDLE_FILE", bundle)
+
+		// when
+		resp, err := client.Get(sso.URL)
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+	})
+
+	s.Run("unreadable CA bundle", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_SSO_CA_BUNDLE_FILE", filepath.Join(s.T().TempDir(), "missing.pem"))
+
+		// when
+		_, err := client.Get(sso.URL)
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to read the CA bundle of SSO")
+	})
+
+	s.Run("no certificate in the CA bundle", func() {
+		// given
+		bundle := filepath.Join(s.T().TempDir(), "ca.pem")
+		require.NoError(s.T(), os.WriteFile(bundle, []byte("not a certificate"), 0600))
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_SSO_CA_BUNDLE_FILE", bundle)
+
+		// when
+		_, err := client.Get(sso.URL)
+
+		// then
+		require.ErrorContains(s.T(), err, "no CA certificate found in "+bundle)
+	})
+
+	s.Run("requests sent through the proxy", func() {
+		// given
+		var proxied string
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			proxied = r.URL.String()
+			w.WriteHeader(http.StatusTeapot)
+		}))
+		defer proxy.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_SSO_PROXY_URL", proxy.URL)
+
+		// when
+		resp, err := client.Get("http://sso.example.com/auth/realms/sandbox-dev/protocol/openid-connect/certs")
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusTeapot, resp.StatusCode)
+		assert.Equal(s.T(), "http://sso.example.com/auth/realms/sandbox-dev/protocol/openid-connect/certs", proxied)
+	})
+
+	s.Run("invalid proxy URL", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_SSO_PROXY_URL", "http://proxy.example.com:port")
+
+		// when
+		_, err := client.Get(sso.URL)
+
+		// then
+		require.ErrorContains(s.T(), err, "invalid URL of the proxy of SSO")
+	})
+}
@@ -1,10 +1,14 @@
 package auth
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -24,66 +28,256 @@ type TokenClaims struct {
 	AccountID         string `json:"account_id"`
 	AccountNumber     string `json:"account_number,omitempty"`
 	jwt.RegisteredClaims
+	// raw contains all the claims of the token, including the ones without a dedicated field
+	raw map[string]interface{}
+}
+
+// UnmarshalJSON unmarshals the claims, keeping all of them in addition to the ones with a dedicated field
+func (c *TokenClaims) UnmarshalJSON(data []byte) error {
+	type claims TokenClaims // without the UnmarshalJSON method, to avoid an infinite recursion
+	if err := json.Unmarshal(data, (*claims)(c)); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.raw)
+}
+
+// StringValues returns the value of the given claim as a list of strings, if the claim is a string or a list of strings.
+// Any other value is ignored.
+func (c *TokenClaims) StringValues(name string) []string {
+	switch value := c.raw[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// mapClaims sets the username, the email, the subject and the names of the user from the claims configured with the given
+// settings, when they are not the default ones, so that the tokens of the identity providers using other claims are supported
+func (c *TokenClaims) mapClaims(cfg configuration.AuthConfig) {
+	for _, mapping := range []struct {
+		claim, defaultClaim string
+		field               *string
+	}{
+		{cfg.UsernameClaim(), "preferred_username", &c.PreferredUsername},
+		{cfg.EmailClaim(), "email", &c.Email},
+		{cfg.SubjectClaim(), "sub", &c.Subject},
+		{cfg.GivenNameClaim(), "given_name", &c.GivenName},
+		{cfg.FamilyNameClaim(), "family_name", &c.FamilyName},
+	} {
+		if mapping.claim == mapping.defaultClaim {
+			continue
+		}
+		*mapping.field = ""
+		if values := c.StringValues(mapping.claim); len(values) > 0 {
+			*mapping.field = values[0]
+		}
+	}
 }
 
 // TokenParser represents a parser for JWT tokens.
 type TokenParser struct {
 	keyManager *KeyManager
+	// realmKeyManagers are the KeyManagers of the additional realms of SSO, by issuer
+	realmKeyManagers map[string]*KeyManager
+	revocations      *Revocations
+	notBefore        *NotBeforePolicies
+	introspector     *Introspector
+}
+
+// TokenParserOption the options of the TokenParser
+type TokenParserOption func(*TokenParser)
+
+// WithRealmKeyManagers sets the KeyManagers of the additional realms of SSO, by issuer (see NewRealmKeyManagers),
+// which validate the tokens of these issuers instead of the KeyManager of the default realm
+func WithRealmKeyManagers(keyManagers map[string]*KeyManager) TokenParserOption {
+	return func(tp *TokenParser) {
+		tp.realmKeyManagers = keyManagers
+	}
 }
 
 // NewTokenParser creates a new TokenParser.
-func NewTokenParser(keyManager *KeyManager) (*TokenParser, error) {
+func NewTokenParser(keyManager *KeyManager, opts ...TokenParserOption) (*TokenParser, error) {
 	if keyManager == nil {
 		return nil, errors.New("no keyManager given when creating TokenParser")
 	}
-	return &TokenParser{
-		keyManager: keyManager,
-	}, nil
+	tp := &TokenParser{
+		keyManager:       keyManager,
+		realmKeyManagers: map[string]*KeyManager{},
+		revocations:      NewRevocations(),
+		notBefore:        NewNotBeforePolicies(),
+		introspector:     NewIntrospector(),
+	}
+	for _, opt := range opts {
+		opt(tp)
+	}
+	return tp, nil
+}
+
+// Revocations returns the revoked tokens and subjects, whose tokens are rejected
+func (tp *TokenParser) Revocations() *Revocations {
+	return tp.revocations
+}
+
+// NotBeforePolicies returns the not-before policies of the realms of SSO, which reject the tokens issued before them
+func (tp *TokenParser) NotBeforePolicies() *NotBeforePolicies {
+	return tp.notBefore
+}
+
+// IsRevoked returns true if the token with the given claims, or its subject, is revoked, or if the token was issued before the
+// not-before policy of its realm or the TokensNotBefore setting, eg. for the claims of a token which were validated before the
+// revocation
+func (tp *TokenParser) IsRevoked(claims *TokenClaims) bool {
+	return tp.revocations.IsRevoked(claims, time.Now()) ||
+		tp.notBefore.IsBefore(claims, configuration.GetRegistrationServiceConfig().Auth().TokensNotBefore())
+}
+
+// KeysLoaded returns true if the public keys used to validate the tokens are loaded, for all the realms
+func (tp *TokenParser) KeysLoaded() bool {
+	for _, km := range tp.realmKeyManagers {
+		if !km.KeysLoaded() {
+			return false
+		}
+	}
+	return tp.keyManager.KeysLoaded()
+}
+
+// RefreshKeys refreshes the public keys used to validate the tokens periodically, for all the realms, until the given context is done
+func (tp *TokenParser) RefreshKeys(ctx context.Context) {
+	for _, km := range tp.realmKeyManagers {
+		go km.RefreshKeys(ctx)
+	}
+	tp.keyManager.RefreshKeys(ctx)
+}
+
+// keyManagerOf returns the KeyManager of the realm which issued the given token: the one of the additional realm with the issuer
+// of the token, if any, or the one of the default realm. The tokens are then rejected if they are not signed with a key of the
+// realm of their issuer.
+func (tp *TokenParser) keyManagerOf(token *jwt.Token) *KeyManager {
+	if claims, ok := token.Claims.(*TokenClaims); ok {
+		if km, found := tp.realmKeyManagers[claims.Issuer]; found {
+			return km
+		}
+	}
+	return tp.keyManager
+}
+
+// keyFunc returns the function returning the public key the given token is signed with, from the KeyManager returned by the
+// given function for the token
+func keyFunc(keyManagerOf func(*jwt.Token) *KeyManager) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		// validate the alg is what we expect, and allowed by the configuration
+		if !slices.Contains(configuration.GetRegistrationServiceConfig().Auth().AllowedAlgorithms(), token.Method.Alg()) {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid := token.Header["kid"]
+		if kid == nil {
+			return nil, errors.New("no key id given in the token")
+		}
+		kidStr, ok := kid.(string)
+		if !ok {
+			return nil, errors.New("given key id has unknown type")
+		}
+		// get the public key for kid from the keyManager of the realm of the token
+		publicKey, err := keyManagerOf(token).Key(kidStr)
+		if err != nil {
+			return nil, err
+		}
+		return publicKey, nil
+	}
 }
 
 // FromString parses a JWT, validates the signature and returns the claims struct.
+// The tokens which are not JWTs are introspected with SSO instead, if enabled (see the IntrospectionClientID setting).
 func (tp *TokenParser) FromString(jwtEncoded string) (*TokenClaims, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
 	token, err := jwt.ParseWithClaims(
 		jwtEncoded,
 		&TokenClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			// validate the alg is what we expect
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-
-			kid := token.Header["kid"]
-			if kid == nil {
-				return nil, errors.New("no key id given in the token")
-			}
-			kidStr, ok := kid.(string)
-			if !ok {
-				return nil, errors.New("given key id has unknown type")
-			}
-			// get the public key for kid from keyManager
-			publicKey, err := tp.keyManager.Key(kidStr)
-			if err != nil {
-				return nil, err
-			}
-			return publicKey, nil
-		},
+		keyFunc(tp.keyManagerOf),
 		jwt.WithLeeway(leeway),
 	)
+	if errors.Is(err, jwt.ErrTokenMalformed) && cfg.IntrospectionClientID() != "" {
+		claims, err := tp.introspector.introspect(jwtEncoded, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		claims.mapClaims(cfg)
+		return tp.checkClaims(claims)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if claims, ok := token.Claims.(*TokenClaims); ok {
+		claims.mapClaims(cfg)
+	}
+	return tp.validClaims(token)
+}
+
+// validClaims returns the claims of the given parsed token, if they contain the expected claims and if the token is not revoked
+func (tp *TokenParser) validClaims(token *jwt.Token) (*TokenClaims, error) {
 	if claims, ok := token.Claims.(*TokenClaims); ok && token.Valid {
-		// we need username and email, so check if those are contained in the claims
-		if claims.PreferredUsername == "" {
-			return nil, errors.New("token does not comply to expected claims: username missing")
+		return tp.checkClaims(claims)
+	}
+	return nil, ErrMissingClaims
+}
+
+// checkClaims returns the given claims, if they contain the expected claims and if their token is not revoked
+func (tp *TokenParser) checkClaims(claims *TokenClaims) (*TokenClaims, error) {
+	// we need username and email, so check if those are contained in the claims
+	if claims.PreferredUsername == "" {
+		return nil, fmt.Errorf("%w: username missing", ErrMissingClaims)
+	}
+	if claims.Email == "" {
+		return nil, fmt.Errorf("%w: email missing", ErrMissingClaims)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: subject missing", ErrMissingClaims)
+	}
+	if tp.IsRevoked(claims) {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+// FromPushNotBeforeAction parses a PushNotBeforeAction, validates its signature with the keys of the realms of SSO, and returns
+// the issuer of the realm whose key it is signed with, along with the not-before time of the realm
+func (tp *TokenParser) FromPushNotBeforeAction(encoded string) (string, time.Time, error) {
+	keyManagers := map[string]*KeyManager{
+		configuration.GetRegistrationServiceConfig().Auth().Realms()[0].Issuer: tp.keyManager,
+	}
+	for issuer, km := range tp.realmKeyManagers {
+		keyManagers[issuer] = km
+	}
+	var err error
+	for issuer, km := range keyManagers {
+		action := &PushNotBeforeAction{}
+		if _, err = jwt.ParseWithClaims(encoded, action, keyFunc(func(*jwt.Token) *KeyManager {
+			return km
+		})); err != nil {
+			continue
 		}
-		if claims.Email == "" {
-			return nil, errors.New("token does not comply to expected claims: email missing")
+		if action.Action != pushNotBeforeAction {
+			return "", time.Time{}, fmt.Errorf("unexpected action: %s", action.Action)
 		}
-		if claims.Subject == "" {
-			return nil, errors.New("token does not comply to expected claims: subject missing")
+		if time.Now().After(time.Unix(action.Expiration, 0).Add(leeway)) {
+			return "", time.Time{}, errors.New("the action is expired")
 		}
-		return claims, nil
+		return issuer, time.Unix(action.NotBeforeTime, 0), nil
 	}
-	return nil, errors.New("token does not comply to expected claims")
+	return "", time.Time{}, err
 }
@@ -1,6 +1,13 @@
 package auth_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -19,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"gopkg.in/go-jose/go-jose.v2"
 )
 
 type TestTokenParserSuite struct {
@@ -123,6 +131,12 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		require.EqualError(s.T(), err, "token does not comply to expected claims: email missing")
 	})
 
+	s.Run("parse malformed token", func() {
+		_, err := tokenParser.FromString("not-a-jwt")
+		require.Error(s.T(), err)
+		assert.Equal(s.T(), auth.FailureReasonMalformed, auth.FailureReason(err))
+	})
+
 	s.Run("unexpected signing method", func() {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 			"foo": "bar",
@@ -157,6 +171,7 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		_, err = tokenParser.FromString(jwtX)
 		require.Error(s.T(), err)
 		require.EqualError(s.T(), err, "token is unverifiable: error while executing keyfunc: unknown kid")
+		assert.Equal(s.T(), auth.FailureReasonBadSignature, auth.FailureReason(err))
 	})
 
 	s.Run("no KID header in token", func() {
@@ -232,6 +247,7 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		_, err = tokenParser.FromString(jwt0string)
 		require.Error(s.T(), err)
 		require.EqualError(s.T(), err, "token does not comply to expected claims: subject missing")
+		assert.Equal(s.T(), auth.FailureReasonMissingClaim, auth.FailureReason(err))
 	})
 
 	s.Run("signature is good but token expired", func() {
@@ -253,6 +269,7 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		_, err = tokenParser.FromString(jwt0string)
 		require.Error(s.T(), err)
 		require.EqualError(s.T(), err, "token has invalid claims: token is expired")
+		assert.Equal(s.T(), auth.FailureReasonExpired, auth.FailureReason(err))
 	})
 
 	s.Run("signature is good but token not valid yet", func() {
@@ -317,6 +334,7 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		_, err = tokenParser.FromString(jwt0string)
 		require.Error(s.T(), err)
 		require.EqualError(s.T(), err, "token signature is invalid: crypto/rsa: verification error")
+		assert.Equal(s.T(), auth.FailureReasonBadSignature, auth.FailureReason(err))
 	})
 
 	s.Run("parse valid token with original_sub claim", func() {
@@ -395,4 +413,400 @@ func (s *TestTokenParserSuite) TestTokenParser() {
 		require.Equal(s.T(), email0, claims.Email)
 		require.Equal(s.T(), "123456789", claims.AccountNumber)
 	})
+
+	s.Run("string values of the claims", func() {
+		// create a test token with a list of audiences
+		identity0 := &authsupport.Identity{
+			ID:       uuid.New(),
+			Username: uuid.NewString(),
+		}
+		email0 := identity0.Username + "@email.tld"
+
+		jwt0, err := tokengenerator.GenerateSignedToken(*identity0, kid0, authsupport.WithEmailClaim(email0), authsupport.WithAudClaim([]string{"console", "cli"}))
+		require.NoError(s.T(), err)
+
+		claims, err := tokenParser.FromString(jwt0)
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), []string{email0}, claims.StringValues("email"))
+		require.Equal(s.T(), []string{"console", "cli"}, claims.StringValues("aud"))
+		require.Empty(s.T(), claims.StringValues("iat"))
+		require.Empty(s.T(), claims.StringValues("unknown"))
+	})
+
+	s.Run("revoked tokens", func() {
+		// given
+		identity0 := &authsupport.Identity{
+			ID:       uuid.New(),
+			Username: uuid.NewString(),
+		}
+		jwt0, err := tokengenerator.GenerateSignedToken(*identity0, kid0, authsupport.WithEmailClaim(identity0.Username+"@email.tld"))
+		require.NoError(s.T(), err)
+		jwt1, err := tokengenerator.GenerateSignedToken(*identity0, kid0, authsupport.WithEmailClaim(identity0.Username+"@email.tld"))
+		require.NoError(s.T(), err)
+		claims0, err := tokenParser.FromString(jwt0)
+		require.NoError(s.T(), err)
+
+		s.Run("token revoked", func() {
+			// given
+			require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{TokenID: claims0.ID}))
+			defer tokenParser.Revocations().Lift("tokens/" + claims0.ID)
+
+			// when
+			_, err := tokenParser.FromString(jwt0)
+
+			// then
+			require.EqualError(s.T(), err, "token is revoked")
+			assert.Equal(s.T(), auth.FailureReasonRevoked, auth.FailureReason(err))
+			assert.True(s.T(), tokenParser.IsRevoked(claims0))
+			_, err = tokenParser.FromString(jwt1)
+			require.NoError(s.T(), err)
+		})
+
+		s.Run("subject revoked", func() {
+			// given
+			require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{Subject: identity0.ID.String()}))
+			defer tokenParser.Revocations().Lift("subjects/" + identity0.ID.String())
+
+			// then
+			for _, token := range []string{jwt0, jwt1} {
+				_, err := tokenParser.FromString(token)
+				require.EqualError(s.T(), err, "token is revoked")
+			}
+			assert.Equal(s.T(), []auth.Revocation{{Subject: identity0.ID.String()}}, tokenParser.Revocations().List())
+		})
+
+		s.Run("revocation expired", func() {
+			// given
+			expiresAt := time.Now().Add(-time.Second)
+			require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{TokenID: claims0.ID, ExpiresAt: &expiresAt}))
+			defer tokenParser.Revocations().Lift("tokens/" + claims0.ID)
+
+			// when
+			_, err := tokenParser.FromString(jwt0)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Empty(s.T(), tokenParser.Revocations().List())
+		})
+
+		s.Run("revocation lifted", func() {
+			// given
+			require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{TokenID: claims0.ID}))
+
+			// when
+			lifted := tokenParser.Revocations().Lift("tokens/" + claims0.ID)
+
+			// then
+			assert.True(s.T(), lifted)
+			_, err := tokenParser.FromString(jwt0)
+			require.NoError(s.T(), err)
+			assert.False(s.T(), tokenParser.Revocations().Lift("tokens/"+claims0.ID))
+		})
+
+		s.Run("invalid revocations", func() {
+			for _, revocation := range []auth.Revocation{{}, {TokenID: claims0.ID, Subject: identity0.ID.String()}} {
+				require.Error(s.T(), tokenParser.Revocations().Revoke(revocation))
+			}
+		})
+	})
+
+	s.Run("mapped claims", func() {
+		// given
+		identity0 := &authsupport.Identity{
+			ID:       uuid.New(),
+			Username: uuid.NewString(),
+		}
+		jwt0, err := tokengenerator.GenerateSignedToken(*identity0, kid0,
+			authsupport.WithEmailClaim(identity0.Username+"@email.tld"),
+			authsupport.WithUserIDClaim("12345"),
+			authsupport.WithCompanyClaim("Red Hat"),
+			authsupport.WithGivenNameClaim("John"))
+		require.NoError(s.T(), err)
+
+		s.Run("claims of the user mapped", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_USERNAME_CLAIM", "email")
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_SUBJECT_CLAIM", "user_id")
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_FAMILY_NAME_CLAIM", "company")
+
+			// when
+			claims, err := tokenParser.FromString(jwt0)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), identity0.Username+"@email.tld", claims.PreferredUsername)
+			assert.Equal(s.T(), identity0.Username+"@email.tld", claims.Email)
+			assert.Equal(s.T(), "12345", claims.Subject)
+			assert.Equal(s.T(), "John", claims.GivenName)
+			assert.Equal(s.T(), "Red Hat", claims.FamilyName)
+		})
+
+		s.Run("mapped claim missing", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_USERNAME_CLAIM", "upn")
+
+			// when
+			_, err := tokenParser.FromString(jwt0)
+
+			// then
+			require.EqualError(s.T(), err, "token does not comply to expected claims: username missing")
+		})
+	})
+
+	s.Run("additional realms", func() {
+		// given
+		partnersgenerator := authsupport.NewTokenManager()
+		kid2 := uuid.NewString()
+		_, err := partnersgenerator.AddPrivateKey(kid2)
+		require.NoError(s.T(), err)
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_REALMS", `[{"name":"partners","ssoBaseURL":"https://sso.partners.example.com","publicKeysURL":"`+partnersgenerator.NewKeyServer().URL+`"}]`)
+		realmKeyManagers, err := auth.NewRealmKeyManagers()
+		require.NoError(s.T(), err)
+		require.Len(s.T(), realmKeyManagers, 1)
+		realmTokenParser, err := auth.NewTokenParser(keyManager, auth.WithRealmKeyManagers(realmKeyManagers))
+		require.NoError(s.T(), err)
+		assert.True(s.T(), realmTokenParser.KeysLoaded())
+		withIssuer := func(issuer string) authsupport.ExtraClaim {
+			return func(token *jwt.Token) {
+				token.Claims.(*authsupport.MyClaims).Issuer = issuer
+			}
+		}
+		identity := authsupport.Identity{
+			ID:       uuid.New(),
+			Username: uuid.NewString(),
+		}
+
+		s.Run("token of the additional realm", func() {
+			// given
+			jwt0, err := partnersgenerator.GenerateSignedToken(identity, kid2, authsupport.WithEmailClaim(identity.Username+"@email.tld"),
+				withIssuer("https://sso.partners.example.com/auth/realms/partners"))
+			require.NoError(s.T(), err)
+
+			// when
+			claims, err := realmTokenParser.FromString(jwt0)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), identity.Username, claims.PreferredUsername)
+		})
+
+		s.Run("token of the default realm", func() {
+			// given
+			jwt0, err := tokengenerator.GenerateSignedToken(identity, kid0, authsupport.WithEmailClaim(identity.Username+"@email.tld"))
+			require.NoError(s.T(), err)
+
+			// when
+			claims, err := realmTokenParser.FromString(jwt0)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), identity.Username, claims.PreferredUsername)
+		})
+
+		s.Run("token signed with the key of another realm", func() {
+			for name, tc := range map[string]struct {
+				generator *authsupport.TokenManager
+				kid       string
+				issuer    string
+			}{
+				"default key with the issuer of the additional realm": {
+					generator: tokengenerator,
+					kid:       kid0,
+					issuer:    "https://sso.partners.example.com/auth/realms/partners",
+				},
+				"key of the additional realm with another issuer": {
+					generator: partnersgenerator,
+					kid:       kid2,
+					issuer:    "https://sso.devsandbox.dev/auth/realms/sandbox-dev",
+				},
+			} {
+				s.Run(name, func() {
+					// given
+					jwt0, err := tc.generator.GenerateSignedToken(identity, tc.kid, authsupport.WithEmailClaim(identity.Username+"@email.tld"), withIssuer(tc.issuer))
+					require.NoError(s.T(), err)
+
+					// when
+					_, err = realmTokenParser.FromString(jwt0)
+
+					// then
+					require.ErrorContains(s.T(), err, "unknown kid")
+				})
+			}
+		})
+	})
+
+	s.Run("EC and Ed25519 keys", func() {
+		// given
+		ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(s.T(), err)
+		edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(s.T(), err)
+		ecKid, edKid := uuid.NewString(), uuid.NewString()
+		keys, err := json.Marshal(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &ecKey.PublicKey, KeyID: ecKid, Algorithm: "ES256", Use: "sig"},
+			{Key: edPublicKey, KeyID: edKid, Algorithm: "EdDSA", Use: "sig"},
+		}})
+		require.NoError(s.T(), err)
+		keyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write(keys)
+		}))
+		defer keyServer.Close()
+		s.OverrideApplicationDefault(testconfig.RegistrationService().
+			Environment(configuration.UnitTestsEnvironment).
+			Auth().AuthClientPublicKeysURL(keyServer.URL))
+		defer s.OverrideApplicationDefault(testconfig.RegistrationService().
+			Environment(configuration.UnitTestsEnvironment).
+			Auth().AuthClientPublicKeysURL(keysEndpointURL))
+		keyManager, err := auth.NewKeyManager()
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+		sign := func(method jwt.SigningMethod, kid string, key interface{}) string {
+			token := jwt.NewWithClaims(method, jwt.MapClaims{
+				"sub":                uuid.NewString(),
+				"preferred_username": "smith",
+				"email":              "smith@email.tld",
+				"iat":                time.Now().Unix(),
+				"exp":                time.Now().Add(time.Hour).Unix(),
+			})
+			token.Header["kid"] = kid
+			signed, err := token.SignedString(key)
+			require.NoError(s.T(), err)
+			return signed
+		}
+
+		for name, token := range map[string]string{
+			"ES256": sign(jwt.SigningMethodES256, ecKid, ecKey),
+			"EdDSA": sign(jwt.SigningMethodEdDSA, edKid, edKey),
+		} {
+			s.Run(name, func() {
+				// when
+				claims, err := tokenParser.FromString(token)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "smith", claims.PreferredUsername)
+			})
+		}
+
+		s.Run("signing method not matching the key", func() {
+			// when
+			_, err := tokenParser.FromString(sign(jwt.SigningMethodEdDSA, ecKid, edKey))
+
+			// then
+			require.ErrorContains(s.T(), err, "key is of invalid type")
+		})
+
+		s.Run("algorithm not allowed", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_ALLOWED_ALGORITHMS", "ES256")
+
+			// when
+			_, esErr := tokenParser.FromString(sign(jwt.SigningMethodES256, ecKid, ecKey))
+			_, edErr := tokenParser.FromString(sign(jwt.SigningMethodEdDSA, edKid, edKey))
+
+			// then
+			require.NoError(s.T(), esErr)
+			require.EqualError(s.T(), edErr, "token is unverifiable: error while executing keyfunc: unexpected signing method: EdDSA")
+		})
+	})
+
+	s.Run("not-before policies", func() {
+		// given
+		tokenParser, err := auth.NewTokenParser(keyManager)
+		require.NoError(s.T(), err)
+		issuer := cfg.Auth().Realms()[0].Issuer
+		issuedAt := time.Now().Add(-time.Hour).Truncate(time.Second)
+		tokenIssuedAt := func(iat time.Time) string {
+			token := tokengenerator.GenerateToken(authsupport.Identity{ID: uuid.New(), Username: "smith"}, kid0,
+				authsupport.WithEmailClaim("smith@email.tld"), authsupport.WithIATClaim(iat))
+			token.Claims.(*authsupport.MyClaims).Issuer = issuer
+			signed, err := tokengenerator.SignToken(token, kid0)
+			require.NoError(s.T(), err)
+			return signed
+		}
+		pushAction := func(signer *authsupport.TokenManager, kid, action string, expiration time.Time) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+				"id":         uuid.NewString(),
+				"action":     action,
+				"resource":   "sandbox-proxy",
+				"expiration": expiration.Unix(),
+				"notBefore":  issuedAt.Unix(),
+			})
+			token.Header["kid"] = kid
+			signed, err := signer.SignToken(token, kid)
+			require.NoError(s.T(), err)
+			return signed
+		}
+		otherGenerator := authsupport.NewTokenManager()
+		otherKid := uuid.NewString()
+		_, err = otherGenerator.AddPrivateKey(otherKid)
+		require.NoError(s.T(), err)
+
+		s.Run("pushed by SSO", func() {
+			// when
+			pushedIssuer, notBefore, err := tokenParser.FromPushNotBeforeAction(pushAction(tokengenerator, kid1, "PUSH_NOT_BEFORE", time.Now().Add(10*time.Second)))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), issuer, pushedIssuer)
+			assert.True(s.T(), issuedAt.Equal(notBefore))
+
+			s.Run("tokens issued before rejected", func() {
+				// given
+				assert.True(s.T(), tokenParser.NotBeforePolicies().Set(pushedIssuer, notBefore))
+
+				// when
+				_, beforeErr := tokenParser.FromString(tokenIssuedAt(issuedAt.Add(-time.Second)))
+				_, atErr := tokenParser.FromString(tokenIssuedAt(issuedAt))
+
+				// then
+				require.EqualError(s.T(), beforeErr, "token is revoked")
+				require.NoError(s.T(), atErr)
+			})
+
+			s.Run("not lowered", func() {
+				// when
+				set := tokenParser.NotBeforePolicies().Set(pushedIssuer, notBefore.Add(-time.Minute))
+
+				// then
+				assert.False(s.T(), set)
+				current, found := tokenParser.NotBeforePolicies().Get(pushedIssuer)
+				require.True(s.T(), found)
+				assert.True(s.T(), notBefore.Equal(current))
+			})
+		})
+
+		s.Run("invalid actions", func() {
+			for name, action := range map[string]string{
+				"other action": pushAction(tokengenerator, kid0, "PUSH_REVOCATION", time.Now().Add(10*time.Second)),
+				"expired":      pushAction(tokengenerator, kid0, "PUSH_NOT_BEFORE", time.Now().Add(-time.Minute)),
+				"not signed":   strings.Join(strings.Split(pushAction(tokengenerator, kid0, "PUSH_NOT_BEFORE", time.Now().Add(10*time.Second)), ".")[:2], ".") + ".",
+				"unknown key":  pushAction(otherGenerator, otherKid, "PUSH_NOT_BEFORE", time.Now().Add(10*time.Second)),
+				"access token": tokenIssuedAt(time.Now()),
+				"not a JWS":    "k_push_not_before",
+			} {
+				s.Run(name, func() {
+					// when
+					_, _, err := tokenParser.FromPushNotBeforeAction(action)
+
+					// then
+					require.Error(s.T(), err)
+				})
+			}
+		})
+
+		s.Run("configured cutoff", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_TOKENS_NOT_BEFORE", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+
+			// when
+			_, beforeErr := tokenParser.FromString(tokenIssuedAt(time.Now().Add(-2 * time.Minute)))
+			_, afterErr := tokenParser.FromString(tokenIssuedAt(time.Now()))
+
+			// then
+			require.EqualError(s.T(), beforeErr, "token is revoked")
+			require.NoError(s.T(), afterErr)
+		})
+	})
 }
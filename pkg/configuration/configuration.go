@@ -3,8 +3,12 @@
 package configuration
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -31,6 +35,7 @@ const (
 	prodEnvironment      = "prod"
 	DefaultEnvironment   = prodEnvironment
 	UnitTestsEnvironment = "unit-tests"
+	E2ETestsEnvironment  = "e2e-tests"
 )
 
 // captcha specific configuration
@@ -38,9 +43,223 @@ const (
 	CaptchaFileName = "captcha.json"
 	CaptchaFilePath = "/tmp/" + CaptchaFileName
 
+	// CaptchaProviderRecaptcha is the captcha provider of the reCAPTCHA Enterprise assessments
+	CaptchaProviderRecaptcha = "recaptcha"
+	// CaptchaProviderTurnstile is the captcha provider of the Cloudflare Turnstile challenges
+	CaptchaProviderTurnstile = "turnstile"
+	// CaptchaProviderHCaptcha is the captcha provider of the hCaptcha challenges
+	CaptchaProviderHCaptcha = "hcaptcha"
+
+	captchaProviderEnvVar          = "CAPTCHA_PROVIDER"
+	captchaRequiredForSignupEnvVar = "CAPTCHA_REQUIRED_FOR_SIGNUP"
+
 	defaultScoreThreshold float32 = 0.9
 )
 
+// Settings that are not (yet) part of the ToolchainConfig CRD are read from the environment of the
+// registration-service deployment. The names of all such environment variables start with this prefix.
+// The secret settings are never read from the environment, see the keys below.
+const envVarPrefix = "REGISTRATION_SERVICE_"
+
+// Keys of the secret settings that are not (yet) part of the ToolchainConfig CRD. They are read from the secret referenced by the
+// ToolchainConfig CR (see `verification.secret.ref`), which also holds the Twilio and AWS credentials, so that they are reloaded
+// with the rest of the configuration and never exposed in the environment of the deployment.
+const (
+	AuthIntrospectionClientSecretKey  = "auth.introspection.client-secret"   // nolint:gosec
+	PersonalAccessTokensSigningKeyKey = "personal-access-tokens.signing-key" // nolint:gosec
+	ProxyLoginClientSecretKey         = "proxy.login.client-secret"          // nolint:gosec
+	WebhooksSecretKey                 = "webhooks.secret"                    // nolint:gosec
+	EmailVerificationSMTPPasswordKey  = "email-verification.smtp-password"   // nolint:gosec
+	EmailVerificationSigningKeyKey    = "email-verification.signing-key"     // nolint:gosec
+	CaptchaTurnstileSecretKeyKey      = "captcha.turnstile.secret-key"       // nolint:gosec
+	CaptchaHCaptchaSecretKeyKey       = "captcha.hcaptcha.secret-key"        // nolint:gosec
+)
+
+// auth specific configuration
+const (
+	authPublicKeysRefreshIntervalEnvVar    = "AUTH_PUBLIC_KEYS_REFRESH_INTERVAL"
+	authPublicKeysMinRefreshIntervalEnvVar = "AUTH_PUBLIC_KEYS_MIN_REFRESH_INTERVAL"
+	authUsernameClaimEnvVar                = "AUTH_USERNAME_CLAIM"
+	authEmailClaimEnvVar                   = "AUTH_EMAIL_CLAIM"
+	authSubjectClaimEnvVar                 = "AUTH_SUBJECT_CLAIM"
+	authGivenNameClaimEnvVar               = "AUTH_GIVEN_NAME_CLAIM"
+	authFamilyNameClaimEnvVar              = "AUTH_FAMILY_NAME_CLAIM"
+	authRealmsEnvVar                       = "AUTH_REALMS"
+	authClientsEnvVar                      = "AUTH_CLIENTS"
+	authDeviceClientIDEnvVar               = "AUTH_DEVICE_CLIENT_ID"
+	authAllowedAlgorithmsEnvVar            = "AUTH_ALLOWED_ALGORITHMS"
+	authPublicKeysFileEnvVar               = "AUTH_PUBLIC_KEYS_FILE"
+	authTokensNotBeforeEnvVar              = "AUTH_TOKENS_NOT_BEFORE"
+	authIntrospectionClientIDEnvVar        = "AUTH_INTROSPECTION_CLIENT_ID"
+	authIntrospectionCacheTTLEnvVar        = "AUTH_INTROSPECTION_CACHE_TTL"
+	authRequireVerifiedEmailEnvVar         = "AUTH_REQUIRE_VERIFIED_EMAIL"
+	authSSOProxyURLEnvVar                  = "AUTH_SSO_PROXY_URL"
+	authSSOCABundleFileEnvVar              = "AUTH_SSO_CA_BUNDLE_FILE"
+)
+
+// personal access tokens specific configuration
+const (
+	personalAccessTokensDefaultLifetimeEnvVar  = "PERSONAL_ACCESS_TOKENS_DEFAULT_LIFETIME"
+	personalAccessTokensMaxLifetimeEnvVar      = "PERSONAL_ACCESS_TOKENS_MAX_LIFETIME"
+	personalAccessTokensExchangeLifetimeEnvVar = "PERSONAL_ACCESS_TOKENS_EXCHANGE_LIFETIME"
+)
+
+// signup polling specific configuration
+const (
+	signupPollingEnabledEnvVar     = "SIGNUP_POLLING_PROTECTION_ENABLED"
+	signupPollingMinIntervalEnvVar = "SIGNUP_POLLING_MIN_INTERVAL"
+	signupPollingBurstEnvVar       = "SIGNUP_POLLING_BURST"
+	signupPollingRetryAfterEnvVar  = "SIGNUP_POLLING_RETRY_AFTER"
+)
+
+// proxy specific configuration
+const (
+	proxyPluginEndpointCacheTTLEnvVar  = "PROXY_PLUGIN_ENDPOINT_CACHE_TTL"
+	proxyTrustedProxiesEnvVar          = "PROXY_TRUSTED_PROXIES"
+	proxyCaptureUsernameEnvVar         = "PROXY_CAPTURE_USERNAME"
+	proxyCaptureUntilEnvVar            = "PROXY_CAPTURE_UNTIL"
+	proxyAccessLogEnabledEnvVar        = "PROXY_ACCESS_LOG_ENABLED"
+	proxyAccessLogSamplingEnvVar       = "PROXY_ACCESS_LOG_SAMPLING"
+	proxyAccessLogRedactedEnvVar       = "PROXY_ACCESS_LOG_REDACTED_FIELDS"
+	proxyAccessLogAuditMutationsEnvVar = "PROXY_ACCESS_LOG_AUDIT_MUTATIONS"
+	proxyMaxUpgradedConnsEnvVar        = "PROXY_MAX_UPGRADED_CONNECTIONS_PER_USER"
+	proxyMaxInFlightRequestsEnvVar     = "PROXY_MAX_IN_FLIGHT_REQUESTS_PER_MEMBER"
+	proxyInFlightQueueSizeEnvVar       = "PROXY_IN_FLIGHT_QUEUE_SIZE"
+	proxyInFlightQueueTimeoutEnvVar    = "PROXY_IN_FLIGHT_QUEUE_TIMEOUT"
+	proxyLoadSheddingMaxHeapEnvVar     = "PROXY_LOAD_SHEDDING_MAX_HEAP_BYTES"
+	proxyLoadSheddingGoroutinesEnvVar  = "PROXY_LOAD_SHEDDING_MAX_GOROUTINES"
+	proxyLoadSheddingMaxInFlightEnvVar = "PROXY_LOAD_SHEDDING_MAX_IN_FLIGHT_REQUESTS"
+	proxyLogScrubbingEnabledEnvVar     = "PROXY_LOG_SCRUBBING_ENABLED"
+	proxyCanaryFlagsEnvVar             = "PROXY_CANARY_FLAGS"
+	proxyListenAddressesEnvVar         = "PROXY_LISTEN_ADDRESSES"
+	proxyDedicatedAdminPortEnvVar      = "PROXY_DEDICATED_ADMIN_PORT"
+	proxyPprofEnabledEnvVar            = "PROXY_PPROF_ENABLED"
+	proxyImpersonateGroupClaimsEnvVar  = "PROXY_IMPERSONATE_GROUP_CLAIMS"
+	proxyImpersonateRolePrefixEnvVar   = "PROXY_IMPERSONATE_ROLE_GROUP_PREFIX"
+	proxyE2EUpstreamAllowlistEnvVar    = "PROXY_E2E_UPSTREAM_ALLOWLIST"
+	proxyResponseHeaderTimeoutEnvVar   = "PROXY_RESPONSE_HEADER_TIMEOUT"
+	proxyIdleTimeoutEnvVar             = "PROXY_IDLE_TIMEOUT"
+	proxyStreamingHeaderTimeoutEnvVar  = "PROXY_STREAMING_RESPONSE_HEADER_TIMEOUT"
+	proxyStreamingIdleTimeoutEnvVar    = "PROXY_STREAMING_IDLE_TIMEOUT"
+	proxyEventStreamIdleTimeoutEnvVar  = "PROXY_EVENT_STREAM_IDLE_TIMEOUT"
+	proxyWatchMaxLifetimeEnvVar        = "PROXY_WATCH_MAX_LIFETIME"
+	proxyConnectionBufferSizeEnvVar    = "PROXY_CONNECTION_BUFFER_SIZE"
+	proxyCopyBufferSizeEnvVar          = "PROXY_COPY_BUFFER_SIZE"
+	proxyProtocolEnabledEnvVar         = "PROXY_PROXY_PROTOCOL_ENABLED"
+	proxyDNSServersEnvVar              = "PROXY_DNS_SERVERS"
+	proxyHostOverridesEnvVar           = "PROXY_HOST_OVERRIDES"
+	proxyTokenCacheSizeEnvVar          = "PROXY_TOKEN_CACHE_SIZE"
+	proxyImpersonatorTokenTTLEnvVar    = "PROXY_IMPERSONATOR_TOKEN_TTL"
+	proxyDenyRulesEnvVar               = "PROXY_DENY_RULES"
+	proxyDeniedPathsEnvVar             = "PROXY_DENIED_PATHS"
+	proxyRoleAllowedVerbsEnvVar        = "PROXY_ROLE_ALLOWED_VERBS"
+	proxyPreflightAccessReviewEnvVar   = "PROXY_PREFLIGHT_ACCESS_REVIEW_ENABLED"
+	proxyAuthzWebhookURLEnvVar         = "PROXY_AUTHORIZATION_WEBHOOK_URL"
+	proxyAuthzWebhookCacheTTLEnvVar    = "PROXY_AUTHORIZATION_WEBHOOK_CACHE_TTL"
+	proxyAuthzWebhookFailOpenEnvVar    = "PROXY_AUTHORIZATION_WEBHOOK_FAIL_OPEN"
+	proxyWorkspaceQuotaEnvVar          = "PROXY_WORKSPACE_QUOTA"
+	proxyWorkspaceQuotaBurstEnvVar     = "PROXY_WORKSPACE_QUOTA_BURST"
+	proxyWorkspaceQuotaOverridesEnvVar = "PROXY_WORKSPACE_QUOTA_OVERRIDES"
+	proxyShadowWorkspacesEnvVar        = "PROXY_SHADOW_WORKSPACES"
+	proxyRoutingAdminsEnvVar           = "PROXY_ROUTING_ADMINS"
+	proxyRoutingRulesEnvVar            = "PROXY_ROUTING_RULES"
+	proxyAdminsEnvVar                  = "PROXY_ADMINS"
+	proxyImpersonationAdminClaimEnvVar = "PROXY_IMPERSONATION_ADMIN_CLAIM"
+	proxyTLSCertFileEnvVar             = "PROXY_TLS_CERT_FILE"
+	proxyTLSKeyFileEnvVar              = "PROXY_TLS_KEY_FILE"
+	proxyClientCAFileEnvVar            = "PROXY_CLIENT_CA_FILE"
+	proxyClientCertRulesEnvVar         = "PROXY_CLIENT_CERT_RULES"
+	proxyGroupClaimEnvVar              = "PROXY_GROUP_CLAIM"
+	proxyGroupRolesEnvVar              = "PROXY_GROUP_ROLES"
+	proxySSOCacheTTLEnvVar             = "PROXY_SSO_CACHE_TTL"
+	proxySSOCacheMaxStaleEnvVar        = "PROXY_SSO_CACHE_MAX_STALE"
+	proxySSOCachedPathsEnvVar          = "PROXY_SSO_CACHED_PATHS"
+	proxyLoginClientIDEnvVar           = "PROXY_LOGIN_CLIENT_ID"
+	proxySessionLifetimeEnvVar         = "PROXY_SESSION_LIFETIME"
+	proxySharedCacheURLEnvVar          = "PROXY_SHARED_CACHE_URL"
+	proxyWorkspaceDomainsEnvVar        = "PROXY_WORKSPACE_DOMAINS"
+	proxyStrictNamespacesEnvVar        = "PROXY_STRICT_NAMESPACE_VALIDATION_ENABLED"
+)
+
+// support specific configuration
+const (
+	supportTeamEnvVar                = "SUPPORT_TEAM"
+	supportEmailEnvVar               = "SUPPORT_EMAIL"
+	supportURLEnvVar                 = "SUPPORT_URL"
+	supportPriorityEmailEnvVar       = "SUPPORT_PRIORITY_EMAIL"
+	supportPriorityURLEnvVar         = "SUPPORT_PRIORITY_URL"
+	supportCriticalStatusCodesEnvVar = "SUPPORT_CRITICAL_STATUS_CODES"
+	supportAdminsEnvVar              = "SUPPORT_ADMINS"
+)
+
+// health history specific configuration
+const (
+	healthHistorySizeEnvVar     = "HEALTH_HISTORY_SIZE"
+	healthHistoryIntervalEnvVar = "HEALTH_HISTORY_INTERVAL"
+)
+
+// webhooks specific configuration
+const (
+	webhooksURLsEnvVar          = "WEBHOOKS_URLS"
+	webhooksMaxAttemptsEnvVar   = "WEBHOOKS_MAX_ATTEMPTS"
+	webhooksRetryIntervalEnvVar = "WEBHOOKS_RETRY_INTERVAL"
+	webhooksTimeoutEnvVar       = "WEBHOOKS_TIMEOUT"
+)
+
+// email verification specific configuration
+const (
+	emailVerificationEnabledEnvVar         = "EMAIL_VERIFICATION_ENABLED"
+	emailVerificationSMTPAddressEnvVar     = "EMAIL_VERIFICATION_SMTP_ADDRESS"
+	emailVerificationSMTPUsernameEnvVar    = "EMAIL_VERIFICATION_SMTP_USERNAME"
+	emailVerificationFromEnvVar            = "EMAIL_VERIFICATION_FROM"
+	emailVerificationSubjectEnvVar         = "EMAIL_VERIFICATION_SUBJECT"
+	emailVerificationMessageTemplateEnvVar = "EMAIL_VERIFICATION_MESSAGE_TEMPLATE"
+	emailVerificationLinkExpiresInEnvVar   = "EMAIL_VERIFICATION_LINK_EXPIRES_IN"
+	emailVerificationRedirectURLEnvVar     = "EMAIL_VERIFICATION_REDIRECT_URL"
+)
+
+// terms of service specific configuration
+const (
+	termsOfServiceVersionEnvVar  = "TERMS_OF_SERVICE_VERSION"
+	termsOfServiceURLEnvVar      = "TERMS_OF_SERVICE_URL"
+	termsOfServiceRequiredEnvVar = "TERMS_OF_SERVICE_REQUIRED"
+)
+
+// anonymization specific configuration
+const (
+	anonymizationRetainEmailHashEnvVar = "ANONYMIZATION_RETAIN_EMAIL_HASH"
+	anonymizationRetainPhoneHashEnvVar = "ANONYMIZATION_RETAIN_PHONE_HASH"
+)
+
+// security headers specific configuration
+const (
+	securityHeadersEnabledEnvVar    = "SECURITY_HEADERS_ENABLED"
+	securityHeadersHSTSMaxAgeEnvVar = "SECURITY_HEADERS_HSTS_MAX_AGE"
+	securityHeadersCSPEnvVar        = "SECURITY_HEADERS_CONTENT_SECURITY_POLICY"
+)
+
+// DefaultContentSecurityPolicy is the Content-Security-Policy of the responses when the ContentSecurityPolicy setting is not set:
+// the responses of the APIs are not meant to be rendered by the browsers, nor embedded in other pages
+const DefaultContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// memberSlowStartWindowEnvVar is the duration over which the share of the new signups a newly added member cluster
+// can receive is ramped up
+const memberSlowStartWindowEnvVar = "MEMBER_SLOW_START_WINDOW"
+
+// devModeEnvVar enables the developer mode, in which the service runs against in-memory host and member clusters
+const devModeEnvVar = "DEV_MODE"
+
+// analytics specific configuration
+const (
+	analyticsDestinationsEnvVar        = "ANALYTICS_DESTINATIONS"
+	analyticsSignupEventsEnabledEnvVar = "ANALYTICS_SIGNUP_EVENTS_ENABLED"
+
+	// SandboxAnalyticsDestination is the name of the destination backed by the Analytics().SegmentWriteKey() setting
+	SandboxAnalyticsDestination = "sandbox"
+	// DevSpacesAnalyticsDestination is the name of the destination backed by the Analytics().DevSpacesSegmentWriteKey() setting
+	DevSpacesAnalyticsDestination = "devspaces"
+)
+
 var configurationClient client.Client
 
 func IsTestingMode() bool {
@@ -52,6 +271,12 @@ func Namespace() string {
 	return os.Getenv(commonconfig.WatchNamespaceEnvVar)
 }
 
+// DevModeEnabled returns true if the service should run against in-memory host and member clusters
+// instead of connecting to the actual clusters (see the devmode package)
+func DevModeEnabled() bool {
+	return getEnvBool(devModeEnvVar, false)
+}
+
 // GetRegistrationServiceConfig returns a RegistrationServiceConfig reflecting the current state of the ToolchainConfig CR and the associated secrets
 func GetRegistrationServiceConfig() RegistrationServiceConfig {
 	if configurationClient == nil {
@@ -92,6 +317,11 @@ func NewRegistrationServiceConfig(config runtime.Object, secrets map[string]map[
 	return RegistrationServiceConfig{cfg: &toolchaincfg.Spec, secrets: secrets}
 }
 
+// registrationServiceSecret returns the content of the secret referenced by the ToolchainConfig CR
+func (r RegistrationServiceConfig) registrationServiceSecret() map[string]string {
+	return r.secrets[commonconfig.GetString(r.cfg.Host.RegistrationService.Verification.Secret.Ref, "")]
+}
+
 func (r RegistrationServiceConfig) Print() {
 	if r.cfg == nil {
 		logger.Info("ToolchainConfig not found, using default Registration Service configuration")
@@ -112,12 +342,17 @@ func (r RegistrationServiceConfig) IsProdEnvironment() bool {
 	return r.Environment() == prodEnvironment
 }
 
+// IsTestEnvironment returns true in the unit-tests and e2e-tests environments
+func (r RegistrationServiceConfig) IsTestEnvironment() bool {
+	return r.Environment() == UnitTestsEnvironment || r.Environment() == E2ETestsEnvironment
+}
+
 func (r RegistrationServiceConfig) Analytics() AnalyticsConfig {
 	return AnalyticsConfig{r.cfg.Host.RegistrationService.Analytics}
 }
 
 func (r RegistrationServiceConfig) Auth() AuthConfig {
-	return AuthConfig{r.cfg.Host.RegistrationService.Auth}
+	return AuthConfig{c: r.cfg.Host.RegistrationService.Auth, secret: r.registrationServiceSecret()}
 }
 
 func (r RegistrationServiceConfig) LogLevel() string {
@@ -153,6 +388,53 @@ func (r RegistrationServiceConfig) DisabledIntegrations() []string {
 	return disabledIntegrations
 }
 
+func (r RegistrationServiceConfig) Proxy() ProxyConfig {
+	return ProxyConfig{secret: r.registrationServiceSecret()}
+}
+
+func (r RegistrationServiceConfig) PersonalAccessTokens() PersonalAccessTokensConfig {
+	return PersonalAccessTokensConfig{secret: r.registrationServiceSecret()}
+}
+
+func (r RegistrationServiceConfig) SignupPolling() SignupPollingConfig {
+	return SignupPollingConfig{}
+}
+
+func (r RegistrationServiceConfig) Support() SupportConfig {
+	return SupportConfig{}
+}
+
+func (r RegistrationServiceConfig) HealthHistory() HealthHistoryConfig {
+	return HealthHistoryConfig{}
+}
+
+func (r RegistrationServiceConfig) Webhooks() WebhooksConfig {
+	return WebhooksConfig{secret: r.registrationServiceSecret()}
+}
+
+func (r RegistrationServiceConfig) EmailVerification() EmailVerificationConfig {
+	return EmailVerificationConfig{registrationServiceURL: r.RegistrationServiceURL(), secret: r.registrationServiceSecret()}
+}
+
+func (r RegistrationServiceConfig) TermsOfService() TermsOfServiceConfig {
+	return TermsOfServiceConfig{}
+}
+
+func (r RegistrationServiceConfig) Anonymization() AnonymizationConfig {
+	return AnonymizationConfig{}
+}
+
+func (r RegistrationServiceConfig) SecurityHeaders() SecurityHeadersConfig {
+	return SecurityHeadersConfig{prod: r.IsProdEnvironment()}
+}
+
+// MemberSlowStartWindow returns the duration, since the creation of its SpaceProvisionerConfig, over which the share of the
+// new signups a member cluster can receive is linearly ramped up, rather than instantly exposing the new cluster to the full load.
+// The slow-start is disabled when the duration is zero (the default).
+func (r RegistrationServiceConfig) MemberSlowStartWindow() time.Duration {
+	return getEnvDuration(memberSlowStartWindowEnvVar, 0)
+}
+
 type AnalyticsConfig struct {
 	c toolchainv1alpha1.RegistrationServiceAnalyticsConfig
 }
@@ -165,8 +447,73 @@ func (r AnalyticsConfig) DevSpacesSegmentWriteKey() string {
 	return commonconfig.GetString(r.c.DevSpaces.SegmentWriteKey, "")
 }
 
+// SignupEventsEnabled returns true if the registration service sends the analytics events of the lifecycle of the UserSignups
+// (see the analytics package) to the destinations. Disabled by default, since the web UIs send their own events.
+func (r AnalyticsConfig) SignupEventsEnabled() bool {
+	return getEnvBool(analyticsSignupEventsEnabledEnvVar, false)
+}
+
+// AnalyticsDestination is a named Segment source which the analytics events can be sent to
+type AnalyticsDestination struct {
+	Name            string `json:"name"`
+	SegmentWriteKey string `json:"segmentWriteKey"`
+	// Events is the list of the events sent to the destination. All events are sent if the list is empty.
+	Events []string `json:"events,omitempty"`
+}
+
+// Accepts returns true if the given event should be sent to the destination
+func (d AnalyticsDestination) Accepts(event string) bool {
+	return len(d.Events) == 0 || slices.Contains(d.Events, event)
+}
+
+// Destinations returns all the analytics destinations that have a Segment write key:
+// the "sandbox" and "devspaces" ones backed by the SegmentWriteKey and DevSpacesSegmentWriteKey settings,
+// followed by the additional destinations defined as a JSON list in the REGISTRATION_SERVICE_ANALYTICS_DESTINATIONS
+// environment variable. An additional destination with the name of a built-in one overrides it.
+func (r AnalyticsConfig) Destinations() []AnalyticsDestination {
+	destinations := []AnalyticsDestination{
+		{Name: SandboxAnalyticsDestination, SegmentWriteKey: r.SegmentWriteKey()},
+		{Name: DevSpacesAnalyticsDestination, SegmentWriteKey: r.DevSpacesSegmentWriteKey()},
+	}
+	if raw := getEnvString(analyticsDestinationsEnvVar, ""); raw != "" {
+		var additional []AnalyticsDestination
+		if err := json.Unmarshal([]byte(raw), &additional); err != nil {
+			logger.Error(err, "unable to parse the additional analytics destinations, ignoring them")
+		}
+		for _, a := range additional {
+			i := slices.IndexFunc(destinations, func(d AnalyticsDestination) bool {
+				return d.Name == a.Name
+			})
+			if i >= 0 {
+				destinations[i] = a
+			} else {
+				destinations = append(destinations, a)
+			}
+		}
+	}
+
+	withKey := make([]AnalyticsDestination, 0, len(destinations))
+	for _, d := range destinations {
+		if d.Name != "" && d.SegmentWriteKey != "" {
+			withKey = append(withKey, d)
+		}
+	}
+	return withKey
+}
+
+// Destination returns the analytics destination with the given name, if any
+func (r AnalyticsConfig) Destination(name string) (AnalyticsDestination, bool) {
+	for _, d := range r.Destinations() {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return AnalyticsDestination{}, false
+}
+
 type AuthConfig struct {
-	c toolchainv1alpha1.RegistrationServiceAuthConfig
+	c      toolchainv1alpha1.RegistrationServiceAuthConfig
+	secret map[string]string
 }
 
 func (r AuthConfig) AuthClientLibraryURL() string {
@@ -193,6 +540,1292 @@ func (r AuthConfig) SSORealm() string {
 	return commonconfig.GetString(r.c.SSORealm, "sandbox-dev")
 }
 
+// PublicKeysRefreshInterval returns the interval at which the public keys are fetched again from the AuthClientPublicKeysURL,
+// so that the keys rotated by the SSO are known before they are used to sign the tokens. The keys are not refreshed if 0.
+func (r AuthConfig) PublicKeysRefreshInterval() time.Duration {
+	return getEnvDuration(authPublicKeysRefreshIntervalEnvVar, time.Hour)
+}
+
+// PublicKeysMinRefreshInterval returns the minimum interval between two fetches of the public keys triggered by tokens signed
+// with an unknown key, so that the tokens with a bogus key ID can't flood the SSO
+func (r AuthConfig) PublicKeysMinRefreshInterval() time.Duration {
+	return getEnvDuration(authPublicKeysMinRefreshIntervalEnvVar, 30*time.Second)
+}
+
+// UsernameClaim returns the name of the token claim the username of the users is read from, eg. 'upn' for the identity providers
+// which don't set the 'preferred_username' claim (the default). If the claim is a list, then its first value is the username.
+func (r AuthConfig) UsernameClaim() string {
+	return getEnvString(authUsernameClaimEnvVar, "preferred_username")
+}
+
+// EmailClaim returns the name of the token claim the email address of the users is read from, 'email' by default
+func (r AuthConfig) EmailClaim() string {
+	return getEnvString(authEmailClaimEnvVar, "email")
+}
+
+// SubjectClaim returns the name of the token claim the subject (ie. the unique and stable ID) of the users is read from,
+// 'sub' by default, eg. 'oid' for the identity providers whose subjects differ between the clients
+func (r AuthConfig) SubjectClaim() string {
+	return getEnvString(authSubjectClaimEnvVar, "sub")
+}
+
+// GivenNameClaim returns the name of the token claim the given name of the users is read from, 'given_name' by default
+func (r AuthConfig) GivenNameClaim() string {
+	return getEnvString(authGivenNameClaimEnvVar, "given_name")
+}
+
+// FamilyNameClaim returns the name of the token claim the family name of the users is read from, 'family_name' by default
+func (r AuthConfig) FamilyNameClaim() string {
+	return getEnvString(authFamilyNameClaimEnvVar, "family_name")
+}
+
+// PublicKeysFile returns the path of the file of the static public keys the SSO tokens can be signed with, in addition to the
+// keys fetched from the AuthClientPublicKeysURL, eg. mounted from a secret in the disconnected environments where the keys URL of SSO
+// is unreachable. The file contains either a JWKS document or PEM-encoded public keys or certificates, whose key IDs are given by
+// their 'kid' header or else are their JWK thumbprint (see RFC 7638). No static key is loaded if empty (the default).
+func (r AuthConfig) PublicKeysFile() string {
+	return getEnvString(authPublicKeysFileEnvVar, "")
+}
+
+// supportedAlgorithms are the algorithms of the signatures of the SSO tokens which can be validated
+var supportedAlgorithms = []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// AllowedAlgorithms returns the algorithms the signatures of the SSO tokens must be made with, configured as a comma-separated list,
+// so that the tokens signed with any other algorithm are rejected, eg. 'ES256' to only accept the tokens of an IdP signing with ES256.
+// Only the RS256, RS384, RS512, ES256, ES384, ES512 and EdDSA algorithms are supported. Invalid entries are ignored, and all the
+// supported algorithms are allowed by default, or if no entry is valid.
+func (r AuthConfig) AllowedAlgorithms() []string {
+	algorithms := []string{}
+	for _, entry := range getEnvList(authAllowedAlgorithmsEnvVar) {
+		if !slices.Contains(supportedAlgorithms, entry) {
+			logger.Error(nil, "ignoring invalid allowed algorithm", "name", envVarPrefix+authAllowedAlgorithmsEnvVar, "value", entry)
+			continue
+		}
+		algorithms = append(algorithms, entry)
+	}
+	if len(algorithms) == 0 {
+		return slices.Clone(supportedAlgorithms)
+	}
+	return algorithms
+}
+
+// DeviceClientID returns the ID of the public client of SSO the CLI users log in with through the device authorization flow
+// (see RFC 8628), eg. from headless machines. The device authorization endpoints are disabled if empty (the default).
+func (r AuthConfig) DeviceClientID() string {
+	return getEnvString(authDeviceClientIDEnvVar, "")
+}
+
+// TokensNotBefore returns the time (in RFC3339 format in the environment) such that the tokens issued before it are rejected, in
+// addition to the not-before policies pushed by the realms of SSO, eg. to invalidate all the tokens issued before a force-logout.
+// No token is rejected because of its issue time when the time is not set (the default) or invalid.
+func (r AuthConfig) TokensNotBefore() time.Time {
+	value := getEnvString(authTokensNotBeforeEnvVar, "")
+	if value == "" {
+		return time.Time{}
+	}
+	notBefore, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Error(err, "unable to parse environment variable, ignoring the not-before time of the tokens", "name", envVarPrefix+authTokensNotBeforeEnvVar)
+		return time.Time{}
+	}
+	return notBefore
+}
+
+// IntrospectionClientID returns the ID of the confidential client of SSO the opaque access tokens are introspected with (see RFC 7662),
+// ie. the tokens which are not JWTs, so that the clients which only receive opaque tokens can be served. The tokens are introspected
+// with the introspection endpoint of the default realm. The opaque tokens are rejected if empty (the default).
+func (r AuthConfig) IntrospectionClientID() string {
+	return getEnvString(authIntrospectionClientIDEnvVar, "")
+}
+
+// IntrospectionClientSecret returns the secret of the client the opaque access tokens are introspected with (see IntrospectionClientID),
+// from the AuthIntrospectionClientSecretKey key of the registration service secret
+func (r AuthConfig) IntrospectionClientSecret() string {
+	return r.secret[AuthIntrospectionClientSecretKey]
+}
+
+// IntrospectionCacheTTL returns how long the result of the introspection of an opaque access token is cached, so that SSO isn't
+// called for every request made with the token. The active tokens are not cached beyond their expiration time. Defaults to 1 minute.
+func (r AuthConfig) IntrospectionCacheTTL() time.Duration {
+	return getEnvDuration(authIntrospectionCacheTTLEnvVar, time.Minute)
+}
+
+// RequireVerifiedEmail returns true if the users must have verified their email with their identity provider, ie. if the
+// `email_verified` claim of their token must be true, to sign up and to access the proxy, so that the throwaway accounts can't be used.
+// Disabled by default.
+func (r AuthConfig) RequireVerifiedEmail() bool {
+	return getEnvBool(authRequireVerifiedEmailEnvVar, false)
+}
+
+// SSOProxyURL returns the URL of the outbound proxy the requests to SSO are sent through, such as the requests fetching the public
+// keys, eg. 'http://egress-proxy.example.com:3128' in the deployments which can only reach SSO through an egress proxy. The proxy of
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables is used if empty (the default).
+func (r AuthConfig) SSOProxyURL() string {
+	return getEnvString(authSSOProxyURLEnvVar, "")
+}
+
+// SSOCABundleFile returns the path of the file of the PEM-encoded CA certificates the certificate of SSO (or of the SSOProxyURL)
+// can be signed with, in addition to the system ones, eg. for a private CA. Only the system CA certificates are trusted if empty (the
+// default).
+func (r AuthConfig) SSOCABundleFile() string {
+	return getEnvString(authSSOCABundleFileEnvVar, "")
+}
+
+// SSORealm is a realm of SSO the users can log in with, along with the settings of its clients
+type SSORealm struct {
+	// Name is the name of the realm
+	Name string `json:"name"`
+	// SSOBaseURL is the base URL of the SSO server of the realm, eg. 'https://sso.example.com'
+	SSOBaseURL string `json:"ssoBaseURL"`
+	// Issuer is the issuer of the tokens of the realm, '<ssoBaseURL>/auth/realms/<name>' by default
+	Issuer string `json:"issuer,omitempty"`
+	// PublicKeysURL is the URL of the keys the tokens of the realm are signed with,
+	// '<ssoBaseURL>/auth/realms/<name>/protocol/openid-connect/certs' by default
+	PublicKeysURL string `json:"publicKeysURL,omitempty"`
+	// PublicKeysFile is the path of the file of the static public keys the tokens of the realm can be signed with, if any
+	// (see the PublicKeysFile setting)
+	PublicKeysFile string `json:"publicKeysFile,omitempty"`
+	// ClientLibraryURL is the URL of the client library the UI logs in with, '<ssoBaseURL>/auth/js/keycloak.js' by default
+	ClientLibraryURL string `json:"clientLibraryURL,omitempty"`
+	// ClientConfigRaw is the raw config of the client the UI logs in with
+	ClientConfigRaw string `json:"clientConfig,omitempty"`
+	// Hosts are the hosts of the registration service and of the proxy whose users log in with the realm
+	Hosts []string `json:"hosts,omitempty"`
+}
+
+// Realms returns the realms of SSO the users can log in with: the default one, backed by the SSORealm, SSOBaseURL,
+// AuthClientPublicKeysURL, PublicKeysFile, AuthClientLibraryURL and AuthClientConfigRaw settings, followed by the additional realms
+// defined as a JSON list in the REGISTRATION_SERVICE_AUTH_REALMS environment variable, so that more than one identity domain can be
+// served by the same deployment. The additional realms without a name or an SSO base URL, or with the name of another realm, are ignored.
+func (r AuthConfig) Realms() []SSORealm {
+	realms := []SSORealm{{
+		Name:             r.SSORealm(),
+		SSOBaseURL:       r.SSOBaseURL(),
+		Issuer:           fmt.Sprintf("%s/auth/realms/%s", r.SSOBaseURL(), r.SSORealm()),
+		PublicKeysURL:    r.AuthClientPublicKeysURL(),
+		PublicKeysFile:   r.PublicKeysFile(),
+		ClientLibraryURL: r.AuthClientLibraryURL(),
+		ClientConfigRaw:  r.AuthClientConfigRaw(),
+	}}
+	raw := getEnvString(authRealmsEnvVar, "")
+	if raw == "" {
+		return realms
+	}
+	var additional []SSORealm
+	if err := json.Unmarshal([]byte(raw), &additional); err != nil {
+		logger.Error(err, "unable to parse the additional realms, ignoring them", "name", envVarPrefix+authRealmsEnvVar)
+		return realms
+	}
+	for _, realm := range additional {
+		realm.SSOBaseURL = strings.TrimSuffix(realm.SSOBaseURL, "/")
+		if realm.Name == "" || realm.SSOBaseURL == "" || slices.ContainsFunc(realms, func(other SSORealm) bool {
+			return other.Name == realm.Name
+		}) {
+			logger.Error(nil, "ignoring invalid realm", "name", envVarPrefix+authRealmsEnvVar, "realm", realm.Name)
+			continue
+		}
+		if realm.Issuer == "" {
+			realm.Issuer = fmt.Sprintf("%s/auth/realms/%s", realm.SSOBaseURL, realm.Name)
+		}
+		if realm.PublicKeysURL == "" {
+			realm.PublicKeysURL = realm.Issuer + "/protocol/openid-connect/certs"
+		}
+		if realm.ClientLibraryURL == "" {
+			realm.ClientLibraryURL = realm.SSOBaseURL + "/auth/js/keycloak.js"
+		}
+		realms = append(realms, realm)
+	}
+	return realms
+}
+
+// RealmByName returns the realm of the given name, or the default realm if there is none (see Realms)
+func (r AuthConfig) RealmByName(name string) SSORealm {
+	realms := r.Realms()
+	for _, realm := range realms {
+		if realm.Name == name {
+			return realm
+		}
+	}
+	return realms[0]
+}
+
+// RealmByHost returns the realm the users of the given host (with or without a port) log in with, or the default realm if there
+// is none (see Realms)
+func (r AuthConfig) RealmByHost(host string) SSORealm {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	realms := r.Realms()
+	for _, realm := range realms {
+		if slices.ContainsFunc(realm.Hosts, func(h string) bool {
+			return strings.EqualFold(h, host)
+		}) {
+			return realm
+		}
+	}
+	return realms[0]
+}
+
+// AuthClient is a client of SSO the UIs log in with, whose configuration is returned by the auth config endpoint to the UIs
+// requesting it by its ID or from one of its origins
+type AuthClient struct {
+	// ID is the ID of the client in its realm, eg. 'sandbox-public'
+	ID string `json:"id"`
+	// Realm is the name of the realm of the client (see Realms), the default realm if empty
+	Realm string `json:"realm,omitempty"`
+	// Origins are the origins of the UIs using the client, eg. 'https://console.example.com'
+	Origins []string `json:"origins,omitempty"`
+	// RedirectURIs are the URIs the UIs using the client can redirect the users to once logged in
+	RedirectURIs []string `json:"redirectURIs,omitempty"`
+	// ConfigRaw is the raw config of the client. The config of a public client of its realm is used if empty.
+	ConfigRaw string `json:"config,omitempty"`
+}
+
+// Clients returns the clients of SSO whose configuration can be returned by the auth config endpoint instead of the one of the
+// realm of the host of the request (see Realms), defined as a JSON list in the REGISTRATION_SERVICE_AUTH_CLIENTS environment
+// variable. The clients without an ID, with the ID of another client or with an unknown realm are ignored.
+func (r AuthConfig) Clients() []AuthClient {
+	raw := getEnvString(authClientsEnvVar, "")
+	if raw == "" {
+		return nil
+	}
+	var clients []AuthClient
+	if err := json.Unmarshal([]byte(raw), &clients); err != nil {
+		logger.Error(err, "unable to parse the auth clients, ignoring them", "name", envVarPrefix+authClientsEnvVar)
+		return nil
+	}
+	realms := r.Realms()
+	valid := make([]AuthClient, 0, len(clients))
+	for _, client := range clients {
+		if client.ID == "" || slices.ContainsFunc(valid, func(other AuthClient) bool {
+			return other.ID == client.ID
+		}) || (client.Realm != "" && !slices.ContainsFunc(realms, func(realm SSORealm) bool {
+			return realm.Name == client.Realm
+		})) {
+			logger.Error(nil, "ignoring invalid auth client", "name", envVarPrefix+authClientsEnvVar, "client", client.ID)
+			continue
+		}
+		valid = append(valid, client)
+	}
+	return valid
+}
+
+// ClientByID returns the client of the given ID, if any (see Clients)
+func (r AuthConfig) ClientByID(id string) (AuthClient, bool) {
+	for _, client := range r.Clients() {
+		if client.ID == id {
+			return client, true
+		}
+	}
+	return AuthClient{}, false
+}
+
+// ClientByOrigin returns the first client used by the UIs of the given origin, if any (see Clients)
+func (r AuthConfig) ClientByOrigin(origin string) (AuthClient, bool) {
+	origin = strings.TrimSuffix(origin, "/")
+	for _, client := range r.Clients() {
+		if slices.ContainsFunc(client.Origins, func(o string) bool {
+			return strings.EqualFold(strings.TrimSuffix(o, "/"), origin)
+		}) {
+			return client, true
+		}
+	}
+	return AuthClient{}, false
+}
+
+// PersonalAccessTokensConfig contains the settings of the personal access tokens issued by the registration service,
+// which the proxy accepts in place of the SSO tokens, eg. for the CI systems which can't perform the SSO flows
+type PersonalAccessTokensConfig struct {
+	secret map[string]string
+}
+
+// Enabled returns true if the personal access tokens can be issued and used, ie. if the SigningKey is set
+func (r PersonalAccessTokensConfig) Enabled() bool {
+	return r.SigningKey() != ""
+}
+
+// SigningKey returns the secret key the personal access tokens are signed with. Changing the key invalidates all
+// the personal access tokens issued so far. The personal access tokens are disabled if empty (the default). The key is read from
+// the PersonalAccessTokensSigningKeyKey key of the registration service secret.
+func (r PersonalAccessTokensConfig) SigningKey() string {
+	return r.secret[PersonalAccessTokensSigningKeyKey]
+}
+
+// DefaultLifetime returns how long the personal access tokens are valid when their expiration time is not requested
+func (r PersonalAccessTokensConfig) DefaultLifetime() time.Duration {
+	return getEnvDuration(personalAccessTokensDefaultLifetimeEnvVar, 30*24*time.Hour)
+}
+
+// MaxLifetime returns how long the personal access tokens can be valid at most
+func (r PersonalAccessTokensConfig) MaxLifetime() time.Duration {
+	return getEnvDuration(personalAccessTokensMaxLifetimeEnvVar, 90*24*time.Hour)
+}
+
+// ExchangeLifetime returns how long the workspace-scoped tokens issued in exchange for the SSO tokens are valid
+// (see RFC 8693). They are signed with the SigningKey, like the personal access tokens.
+func (r PersonalAccessTokensConfig) ExchangeLifetime() time.Duration {
+	return getEnvDuration(personalAccessTokensExchangeLifetimeEnvVar, 15*time.Minute)
+}
+
+// SignupPollingConfig contains the settings of the protection against aggressive polling of the signup status
+type SignupPollingConfig struct {
+}
+
+// Enabled returns true if the requests polling the signup status too aggressively should be rejected
+func (r SignupPollingConfig) Enabled() bool {
+	return getEnvBool(signupPollingEnabledEnvVar, true)
+}
+
+// MinInterval returns the minimum expected interval between two consecutive requests of the same user
+func (r SignupPollingConfig) MinInterval() time.Duration {
+	return getEnvDuration(signupPollingMinIntervalEnvVar, time.Second)
+}
+
+// Burst returns the number of consecutive requests arriving within the MinInterval which are still tolerated
+func (r SignupPollingConfig) Burst() int {
+	return getEnvInt(signupPollingBurstEnvVar, 3)
+}
+
+// RetryAfter returns the delay suggested to the clients whose requests were rejected.
+// It roughly matches the time needed by the host operator to move a UserSignup to its next provisioning state.
+func (r SignupPollingConfig) RetryAfter() time.Duration {
+	return getEnvDuration(signupPollingRetryAfterEnvVar, 3*time.Second)
+}
+
+// SupportConfig contains the support contact details presented to the users when an error occurs
+type SupportConfig struct {
+}
+
+// SupportChannel is a channel through which the users can contact the support team
+type SupportChannel struct {
+	Team  string
+	Email string
+	URL   string
+}
+
+// Contact returns how to contact the support team through the channel, eg. "the Developer Sandbox team at devsandbox@redhat.com"
+func (c SupportChannel) Contact() string {
+	switch {
+	case c.Email != "":
+		return fmt.Sprintf("%s at %s", c.Team, c.Email)
+	case c.URL != "":
+		return fmt.Sprintf("%s at %s", c.Team, c.URL)
+	default:
+		return c.Team
+	}
+}
+
+// Channel returns the regular support channel
+func (r SupportConfig) Channel() SupportChannel {
+	return SupportChannel{
+		Team:  getEnvString(supportTeamEnvVar, "the Developer Sandbox team"),
+		Email: getEnvString(supportEmailEnvVar, "devsandbox@redhat.com"),
+		URL:   getEnvString(supportURLEnvVar, ""),
+	}
+}
+
+// PriorityChannel returns the support channel for the errors of critical severity (see CriticalStatusCodes),
+// or false if no priority channel is configured, in which case the regular channel is used for all the errors
+func (r SupportConfig) PriorityChannel() (SupportChannel, bool) {
+	email := getEnvString(supportPriorityEmailEnvVar, "")
+	url := getEnvString(supportPriorityURLEnvVar, "")
+	if email == "" && url == "" {
+		return SupportChannel{}, false
+	}
+	return SupportChannel{
+		Team:  r.Channel().Team,
+		Email: email,
+		URL:   url,
+	}, true
+}
+
+// CriticalStatusCodes returns the status codes of the errors of critical severity, ie. the errors which affect
+// all the users (such as an unavailable service) rather than a single request. Defaults to 502, 503 and 504.
+func (r SupportConfig) CriticalStatusCodes() []int {
+	entries := getEnvList(supportCriticalStatusCodesEnvVar)
+	if len(entries) == 0 {
+		return []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	}
+	codes := []int{}
+	for _, entry := range entries {
+		code, err := strconv.Atoi(entry)
+		if err != nil {
+			logger.Error(err, "ignoring invalid status code", "name", envVarPrefix+supportCriticalStatusCodesEnvVar, "value", entry)
+			continue
+		}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// Admins returns the names of the users allowed to use the admin endpoints of the registration service for the support tooling,
+// eg. to list the UserSignups. Configured as a comma-separated list of usernames. No user is allowed by default.
+func (r SupportConfig) Admins() []string {
+	return getEnvList(supportAdminsEnvVar)
+}
+
+// HealthHistoryConfig contains the settings of the history of the health transitions of the components
+// the service depends on (the proxy, the member clusters and the SSO)
+type HealthHistoryConfig struct {
+}
+
+// Size returns the maximum number of health transitions kept in the history, the oldest ones being dropped first
+func (r HealthHistoryConfig) Size() int {
+	return getEnvInt(healthHistorySizeEnvVar, 100)
+}
+
+// Interval returns the interval at which the health of the components is checked.
+// The health of the components is not monitored when the interval is zero or negative.
+func (r HealthHistoryConfig) Interval() time.Duration {
+	return getEnvDuration(healthHistoryIntervalEnvVar, 30*time.Second)
+}
+
+// WebhooksConfig contains the settings of the webhooks notified of the transitions of the UserSignups
+type WebhooksConfig struct {
+	secret map[string]string
+}
+
+// URLs returns the URLs of the webhooks, configured as a comma-separated list. The webhooks are disabled when there is no URL (the default).
+func (r WebhooksConfig) URLs() []string {
+	return getEnvList(webhooksURLsEnvVar)
+}
+
+// Secret returns the secret the events are signed with (HMAC-SHA256), so that the webhooks can verify that the events were sent
+// by the registration service. It is read from the WebhooksSecretKey key of the registration service secret.
+func (r WebhooksConfig) Secret() string {
+	return r.secret[WebhooksSecretKey]
+}
+
+// MaxAttempts returns the maximum number of attempts to deliver an event to a webhook, before it is dropped
+func (r WebhooksConfig) MaxAttempts() int {
+	return getEnvInt(webhooksMaxAttemptsEnvVar, 5)
+}
+
+// RetryInterval returns the interval before the first retry of the delivery of an event, which is doubled after each attempt
+func (r WebhooksConfig) RetryInterval() time.Duration {
+	return getEnvDuration(webhooksRetryIntervalEnvVar, time.Second)
+}
+
+// Timeout returns the timeout of the requests to the webhooks
+func (r WebhooksConfig) Timeout() time.Duration {
+	return getEnvDuration(webhooksTimeoutEnvVar, 10*time.Second)
+}
+
+// EmailVerificationConfig contains the settings of the verification of the users with a one-time link sent by email, as an
+// alternative to the phone verification for the regions where the SMS are not reliably delivered
+type EmailVerificationConfig struct {
+	registrationServiceURL string
+	secret                 map[string]string
+}
+
+// Enabled returns true if the users can request a verification link by email. Disabled by default.
+func (r EmailVerificationConfig) Enabled() bool {
+	return getEnvBool(emailVerificationEnabledEnvVar, false)
+}
+
+// SMTPAddress returns the address of the SMTP server the emails are sent with, in the 'host:port' format
+func (r EmailVerificationConfig) SMTPAddress() string {
+	return getEnvString(emailVerificationSMTPAddressEnvVar, "")
+}
+
+// SMTPUsername returns the username to authenticate with on the SMTP server. No authentication is made when it's not set.
+func (r EmailVerificationConfig) SMTPUsername() string {
+	return getEnvString(emailVerificationSMTPUsernameEnvVar, "")
+}
+
+// SMTPPassword returns the password to authenticate with on the SMTP server, from the EmailVerificationSMTPPasswordKey key of
+// the registration service secret
+func (r EmailVerificationConfig) SMTPPassword() string {
+	return r.secret[EmailVerificationSMTPPasswordKey]
+}
+
+// From returns the address the emails are sent from
+func (r EmailVerificationConfig) From() string {
+	return getEnvString(emailVerificationFromEnvVar, "")
+}
+
+// Subject returns the subject of the emails with the verification link
+func (r EmailVerificationConfig) Subject() string {
+	return getEnvString(emailVerificationSubjectEnvVar, "Verify your Developer Sandbox account")
+}
+
+// MessageTemplate returns the template of the body of the emails, in which the verification link is substituted for the '%s' verb
+func (r EmailVerificationConfig) MessageTemplate() string {
+	return getEnvString(emailVerificationMessageTemplateEnvVar, "Open the following link to verify your Developer Sandbox account: %s")
+}
+
+// SigningKey returns the key the verification links are signed with (HMAC-SHA256), from the EmailVerificationSigningKeyKey key of
+// the registration service secret
+func (r EmailVerificationConfig) SigningKey() string {
+	return r.secret[EmailVerificationSigningKeyKey]
+}
+
+// LinkExpiresIn returns the duration the verification links are valid for
+func (r EmailVerificationConfig) LinkExpiresIn() time.Duration {
+	return getEnvDuration(emailVerificationLinkExpiresInEnvVar, 30*time.Minute)
+}
+
+// RedirectURL returns the URL the users are redirected to once verified. Defaults to the URL of the registration service.
+func (r EmailVerificationConfig) RedirectURL() string {
+	return getEnvString(emailVerificationRedirectURLEnvVar, r.registrationServiceURL)
+}
+
+// TermsOfServiceConfig contains the settings of the terms of service the users accept, whose acceptance is recorded on their UserSignup
+type TermsOfServiceConfig struct {
+}
+
+// Version returns the version of the current terms of service, eg. '2024-01'. The acceptance of the terms of service is not
+// tracked when it's not set (the default).
+func (r TermsOfServiceConfig) Version() string {
+	return getEnvString(termsOfServiceVersionEnvVar, "")
+}
+
+// URL returns the URL of the current terms of service
+func (r TermsOfServiceConfig) URL() string {
+	return getEnvString(termsOfServiceURLEnvVar, "")
+}
+
+// Required returns true if the users must accept the current version of the terms of service to sign up. Disabled by default.
+func (r TermsOfServiceConfig) Required() bool {
+	return getEnvBool(termsOfServiceRequiredEnvVar, false)
+}
+
+// AnonymizationConfig contains the policy of the anonymization of the personal data of the deactivated UserSignups (right to erasure)
+type AnonymizationConfig struct {
+}
+
+// RetainEmailHash returns true if the hash of the email address of the user is retained on the anonymized UserSignups, so that
+// the banned users are still recognized when they sign up again. Enabled by default.
+func (r AnonymizationConfig) RetainEmailHash() bool {
+	return getEnvBool(anonymizationRetainEmailHashEnvVar, true)
+}
+
+// RetainPhoneHash returns true if the hash of the phone number of the user is retained on the anonymized UserSignups, so that
+// the phone number cannot be used to verify another account. Disabled by default.
+func (r AnonymizationConfig) RetainPhoneHash() bool {
+	return getEnvBool(anonymizationRetainPhoneHashEnvVar, false)
+}
+
+// SecurityHeadersConfig contains the settings of the security headers of the responses of the registration service and of the proxy,
+// some of which default to distinct values in the production environment
+type SecurityHeadersConfig struct {
+	prod bool
+}
+
+// Enabled returns true if the security headers are set on the responses. Enabled by default.
+func (r SecurityHeadersConfig) Enabled() bool {
+	return getEnvBool(securityHeadersEnabledEnvVar, true)
+}
+
+// HSTSMaxAge returns the max-age of the Strict-Transport-Security header, which isn't set when the max-age is zero or negative.
+// Defaults to one year in the production environment, and to zero in the other environments, whose certificates may be self-signed.
+func (r SecurityHeadersConfig) HSTSMaxAge() time.Duration {
+	if r.prod {
+		return getEnvDuration(securityHeadersHSTSMaxAgeEnvVar, 365*24*time.Hour)
+	}
+	return getEnvDuration(securityHeadersHSTSMaxAgeEnvVar, 0)
+}
+
+// ContentSecurityPolicy returns the value of the Content-Security-Policy header, which defaults to DefaultContentSecurityPolicy
+// in the production environment, and isn't set by default in the other environments
+func (r SecurityHeadersConfig) ContentSecurityPolicy() string {
+	if r.prod {
+		return getEnvString(securityHeadersCSPEnvVar, DefaultContentSecurityPolicy)
+	}
+	return getEnvString(securityHeadersCSPEnvVar, "")
+}
+
+// ProxyConfig contains the settings of the proxy
+type ProxyConfig struct {
+	secret map[string]string
+}
+
+// PluginEndpointCacheTTL returns how long the URL of a proxy plugin backend, resolved from the OpenShift Route
+// in the member cluster, is cached. The cache is disabled when the TTL is zero.
+func (r ProxyConfig) PluginEndpointCacheTTL() time.Duration {
+	return getEnvDuration(proxyPluginEndpointCacheTTLEnvVar, 30*time.Second)
+}
+
+// TokenCacheSize returns the maximum number of validated tokens whose claims are cached until the tokens expire, so that
+// the signature of the tokens is not verified on every request. The cache is disabled when the size is zero.
+func (r ProxyConfig) TokenCacheSize() int {
+	return getEnvInt(proxyTokenCacheSizeEnvVar, 10000)
+}
+
+// ImpersonatorTokenTTL returns the lifetime of the short-lived tokens minted with the TokenRequest API for the service accounts
+// of the member clusters, and used to impersonate the users instead of the tokens of the ToolchainClusters. The lifetime is at
+// least 10 minutes (the minimum of the TokenRequest API). The tokens of the ToolchainClusters are used when zero (the default).
+func (r ProxyConfig) ImpersonatorTokenTTL() time.Duration {
+	return getEnvDuration(proxyImpersonatorTokenTTLEnvVar, 0)
+}
+
+func (r ProxyConfig) AccessLog() AccessLogConfig {
+	return AccessLogConfig{}
+}
+
+func (r ProxyConfig) LoadShedding() LoadSheddingConfig {
+	return LoadSheddingConfig{}
+}
+
+// LogScrubbingEnabled returns true if the bearer tokens (including the ones of the Sec-WebSocket-Protocol header) and the email
+// addresses are redacted from the log lines of the proxy, from its access log and from its error responses. Disabled by default.
+func (r ProxyConfig) LogScrubbingEnabled() bool {
+	return getEnvBool(proxyLogScrubbingEnabledEnvVar, false)
+}
+
+// MaxUpgradedConnectionsPerUser returns the maximum number of active upgraded (websocket, SPDY) connections, eg. used by
+// the watch streams, a user can open through the proxy. There is no limit when the value is zero (the default) or negative.
+func (r ProxyConfig) MaxUpgradedConnectionsPerUser() int {
+	return getEnvInt(proxyMaxUpgradedConnsEnvVar, 0)
+}
+
+// MaxInFlightRequestsPerMember returns the maximum number of requests the proxy forwards concurrently to each member cluster,
+// so that a struggling API server of a member cluster isn't buried under the retries of the clients. The watch streams and the
+// upgraded connections are not limited. There is no limit when the value is zero (the default) or negative.
+func (r ProxyConfig) MaxInFlightRequestsPerMember() int {
+	return getEnvInt(proxyMaxInFlightRequestsEnvVar, 0)
+}
+
+// InFlightQueueSize returns the maximum number of requests waiting for one of the MaxInFlightRequestsPerMember requests to a member
+// cluster to complete. The requests exceeding the queue are rejected right away. There is no queue by default.
+func (r ProxyConfig) InFlightQueueSize() int {
+	return getEnvInt(proxyInFlightQueueSizeEnvVar, 0)
+}
+
+// InFlightQueueTimeout returns how long a queued request waits for one of the MaxInFlightRequestsPerMember requests to a member
+// cluster to complete before it is rejected (see InFlightQueueSize). 5 seconds by default.
+func (r ProxyConfig) InFlightQueueTimeout() time.Duration {
+	return getEnvDuration(proxyInFlightQueueTimeoutEnvVar, 5*time.Second)
+}
+
+// ResponseHeaderTimeout returns how long the proxy waits for the headers of the response of a member cluster (or of a proxy plugin
+// backend) to an ordinary request, ie. not to a streaming request (see StreamingResponseHeaderTimeout). Zero (the default) means no timeout.
+func (r ProxyConfig) ResponseHeaderTimeout() time.Duration {
+	return getEnvDuration(proxyResponseHeaderTimeoutEnvVar, 0)
+}
+
+// IdleTimeout returns how long the proxy waits for more data of the response body of a member cluster (or of a proxy plugin backend)
+// to an ordinary request before aborting the response. Zero (the default) means no timeout.
+func (r ProxyConfig) IdleTimeout() time.Duration {
+	return getEnvDuration(proxyIdleTimeoutEnvVar, 0)
+}
+
+// StreamingResponseHeaderTimeout is the ResponseHeaderTimeout of the streaming requests, ie. the watches, the followed logs
+// and the upgraded connections (exec, attach, port-forward), so that tightening the timeouts of the ordinary requests doesn't
+// break the streams. Zero (the default) means no timeout.
+func (r ProxyConfig) StreamingResponseHeaderTimeout() time.Duration {
+	return getEnvDuration(proxyStreamingHeaderTimeoutEnvVar, 0)
+}
+
+// StreamingIdleTimeout is the IdleTimeout of the streaming requests (see StreamingResponseHeaderTimeout). It doesn't apply
+// to the upgraded connections. Zero (the default) means no timeout.
+func (r ProxyConfig) StreamingIdleTimeout() time.Duration {
+	return getEnvDuration(proxyStreamingIdleTimeoutEnvVar, 0)
+}
+
+// EventStreamIdleTimeout is the IdleTimeout of the event streams (Server-Sent Events), eg. of the proxy plugin backends, which may only
+// send an event, or a heartbeat, every few minutes. Defaults to the StreamingIdleTimeout.
+func (r ProxyConfig) EventStreamIdleTimeout() time.Duration {
+	return getEnvDuration(proxyEventStreamIdleTimeoutEnvVar, r.StreamingIdleTimeout())
+}
+
+// WatchMaxLifetime returns the maximum lifetime of the watches forwarded to the member clusters (and to the proxy plugin backends),
+// after which the watches are ended and have to be re-established by the clients, so that the watches are spread again across
+// the replicas of the proxy and the member clusters. Zero (the default) means no maximum lifetime.
+func (r ProxyConfig) WatchMaxLifetime() time.Duration {
+	return getEnvDuration(proxyWatchMaxLifetimeEnvVar, 0)
+}
+
+// ConnectionBufferSize returns the size (in bytes) of the read and write buffers of the connections to the member clusters
+// (and to the proxy plugin backends), including the upgraded connections (exec, attach, port-forward), so that the memory used by
+// each connection can be traded for the throughput of the large transfers. Zero (the default) or negative means 4KiB.
+func (r ProxyConfig) ConnectionBufferSize() int {
+	return getEnvInt(proxyConnectionBufferSizeEnvVar, 0)
+}
+
+// CopyBufferSize returns the size (in bytes) of the pooled buffers used to copy the data between the clients and the member
+// clusters (or the proxy plugin backends), ie. the response bodies (eg. the followed logs) and both directions of the upgraded
+// connections (eg. the file copies). Zero (the default) or negative means 32KiB, and the size is capped at 1MiB.
+func (r ProxyConfig) CopyBufferSize() int {
+	return getEnvInt(proxyCopyBufferSizeEnvVar, 0)
+}
+
+// DedicatedAdminPort returns true if the health endpoint of the proxy is only served on the admin port, along with the metrics,
+// and not on the listen addresses of the proxied traffic, so that the probes and the scrapes can be firewalled separately
+// from the user traffic. The health endpoint is served on both by default.
+func (r ProxyConfig) DedicatedAdminPort() bool {
+	return getEnvBool(proxyDedicatedAdminPortEnvVar, false)
+}
+
+// PprofEnabled returns true if the pprof endpoints, used to profile the memory, the CPU or the goroutines of the proxy,
+// are served on the admin port under /debug/pprof/. Disabled by default.
+func (r ProxyConfig) PprofEnabled() bool {
+	return getEnvBool(proxyPprofEnabledEnvVar, false)
+}
+
+// ImpersonateGroupClaims returns the names of the token claims whose values (a string or a list of strings) are impersonated
+// as groups, along with the user, when forwarding the requests to the member clusters, configured as a comma-separated list,
+// eg. 'groups,roles'. No group is impersonated from the token claims by default.
+func (r ProxyConfig) ImpersonateGroupClaims() []string {
+	return getEnvList(proxyImpersonateGroupClaimsEnvVar)
+}
+
+// ImpersonateRoleGroupPrefix returns the prefix of the group impersonated, along with the user, from the role of the user
+// in the targeted workspace when forwarding the requests to the member clusters, eg. 'sandbox-role:' to impersonate
+// the 'sandbox-role:admin' group for the 'admin' role. No group is impersonated from the role when the prefix is empty (the default).
+func (r ProxyConfig) ImpersonateRoleGroupPrefix() string {
+	return getEnvString(proxyImpersonateRolePrefixEnvVar, "")
+}
+
+// E2EUpstreamAllowlist returns the URLs of the stub servers the e2e tests can forward the proxied requests to, instead of
+// the member clusters (see the X-Sandbox-E2E-Upstream header), configured as a comma-separated list, eg. 'http://127.0.0.1:9090'.
+// Only the scheme and the host of the URLs are compared. The upstream can't be overridden outside of the test environments.
+func (r ProxyConfig) E2EUpstreamAllowlist() []string {
+	return getEnvList(proxyE2EUpstreamAllowlistEnvVar)
+}
+
+// WorkspaceDomains returns the domains whose subdomains are the workspaces targeted by the requests to the proxy, as an alternative
+// to the '/workspaces/<name>' prefix of the path, eg. the requests to 'myworkspace.proxy.example.com' target the 'myworkspace' workspace
+// with the 'proxy.example.com' domain. Configured as a comma-separated list. No workspace is resolved from the host by default.
+func (r ProxyConfig) WorkspaceDomains() []string {
+	domains := []string{}
+	for _, domain := range getEnvList(proxyWorkspaceDomainsEnvVar) {
+		if domain = strings.Trim(domain, "."); domain != "" {
+			domains = append(domains, strings.ToLower(domain))
+		}
+	}
+	return domains
+}
+
+// ProxyProtocolEnabled returns true if the proxy reads the PROXY protocol (v1 or v2) header sent by a TCP load balancer
+// at the beginning of the connections, so that the address of the original client is used, eg. in the access log and the metrics.
+// The header is only read from the TrustedProxies, which must thus be set, or else the proxy fails to start. Disabled by default.
+func (r ProxyConfig) ProxyProtocolEnabled() bool {
+	return getEnvBool(proxyProtocolEnabledEnvVar, false)
+}
+
+// DNSServers returns the addresses of the DNS servers used to resolve the hosts of the API endpoints of the member clusters
+// (and of the proxy plugin backends), instead of the ones of the system, eg. in split-horizon DNS environments where the members
+// must be reached via their internal addresses. Configured as a comma-separated list of 'host:port' addresses, the port 53
+// being used when not set. The DNS servers of the system are used by default.
+func (r ProxyConfig) DNSServers() []string {
+	servers := []string{}
+	for _, server := range getEnvList(proxyDNSServersEnvVar) {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		servers = append(servers, server)
+	}
+	return servers
+}
+
+// HostOverrides returns the addresses dialed, instead of the resolved ones, to reach the hosts of the API endpoints of the member
+// clusters (and of the proxy plugin backends). The TLS certificates are still verified against the original hosts. Configured as a
+// comma-separated list of 'host=address' entries, eg. 'api.member-1.example.com=10.0.1.10'. Invalid entries are ignored.
+// No host is overridden by default.
+func (r ProxyConfig) HostOverrides() map[string]string {
+	overrides := map[string]string{}
+	for _, entry := range getEnvList(proxyHostOverridesEnvVar) {
+		host, address, found := strings.Cut(entry, "=")
+		host, address = strings.TrimSpace(host), strings.TrimSpace(address)
+		if !found || host == "" || address == "" {
+			logger.Error(nil, "ignoring invalid host override", "name", envVarPrefix+proxyHostOverridesEnvVar, "value", entry)
+			continue
+		}
+		overrides[strings.ToLower(host)] = address
+	}
+	return overrides
+}
+
+// CaptureUsername returns the name of the user whose proxied requests are captured (see CaptureUntil), if any.
+// The capture can also be set at runtime with the admin endpoint of the proxy (see Admins).
+func (r ProxyConfig) CaptureUsername() string {
+	return getEnvString(proxyCaptureUsernameEnvVar, "")
+}
+
+// CaptureUntil returns the time (in RFC3339 format in the environment) until which the proxied requests
+// of the CaptureUsername user are captured. The capture is disabled when the time is not set or invalid.
+func (r ProxyConfig) CaptureUntil() time.Time {
+	value := getEnvString(proxyCaptureUntilEnvVar, "")
+	if value == "" {
+		return time.Time{}
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Error(err, "unable to parse environment variable, disabling the capture", "name", envVarPrefix+proxyCaptureUntilEnvVar)
+		return time.Time{}
+	}
+	return until
+}
+
+// TrustedProxies returns the IP ranges of the upstream proxies (eg. the OpenShift router or a load balancer)
+// whose X-Forwarded-* and Forwarded headers are trusted. The value is a comma-separated list of CIDRs or IPs.
+// When no proxy is configured (the default), the headers of all the peers are forwarded as they are, which is what is expected
+// behind the OpenShift router, but they are not trusted by the proxy itself, eg. for the scheme of the session cookies.
+func (r ProxyConfig) TrustedProxies() []*net.IPNet {
+	trusted := []*net.IPNet{}
+	for _, entry := range getEnvList(proxyTrustedProxiesEnvVar) {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			logger.Error(err, "ignoring invalid trusted proxy", "name", envVarPrefix+proxyTrustedProxiesEnvVar, "value", entry)
+			continue
+		}
+		trusted = append(trusted, ipNet)
+	}
+	return trusted
+}
+
+// ListenAddresses returns the addresses the proxy listens on, configured as a comma-separated list of 'host:port' or ':port'
+// TCP addresses and 'unix:/path/to/socket' Unix domain sockets, eg. ':8081,unix:/var/run/proxy.sock'.
+// The proxy listens on the default port of all the interfaces when no address is set.
+func (r ProxyConfig) ListenAddresses() []string {
+	return getEnvList(proxyListenAddressesEnvVar)
+}
+
+// CanaryFlags returns the percentage (0-100) of the users for whom each of the canary behaviors of the proxy is enabled,
+// configured as a comma-separated list of 'flag=percentage' entries, eg. 'shared-transport=10'.
+// Invalid entries are ignored, and the percentages are capped to 100.
+func (r ProxyConfig) CanaryFlags() map[string]int {
+	flags := map[string]int{}
+	for _, entry := range getEnvList(proxyCanaryFlagsEnvVar) {
+		flag, percentage, found := strings.Cut(entry, "=")
+		p, err := strconv.Atoi(strings.TrimSpace(percentage))
+		if !found || strings.TrimSpace(flag) == "" || err != nil || p < 0 {
+			logger.Error(err, "ignoring invalid canary flag", "name", envVarPrefix+proxyCanaryFlagsEnvVar, "value", entry)
+			continue
+		}
+		flags[strings.TrimSpace(flag)] = min(p, 100)
+	}
+	return flags
+}
+
+// StrictNamespaceValidationEnabled returns true if the proxy rejects the requests to a namespace which doesn't belong to the targeted
+// workspace, instead of forwarding them and relying on the RBAC of the member cluster. Disabled by default.
+func (r ProxyConfig) StrictNamespaceValidationEnabled() bool {
+	return getEnvBool(proxyStrictNamespacesEnvVar, false)
+}
+
+// PreflightAccessReviewEnabled returns true if the proxy checks with a SelfSubjectAccessReview, as the impersonated user, that
+// the user is allowed to perform a mutating request (create, update, patch, delete) before forwarding it to the member cluster,
+// so that a denied request is rejected with an explicit description of the missing permission. Disabled by default.
+func (r ProxyConfig) PreflightAccessReviewEnabled() bool {
+	return getEnvBool(proxyPreflightAccessReviewEnvVar, false)
+}
+
+// AuthorizationWebhookURL returns the URL of the external policy service the proxy asks, with the user, the workspace, the verb and
+// the path of each request, whether the request can be forwarded, so that the organizations can enforce their own policies.
+// No webhook is called by default.
+func (r ProxyConfig) AuthorizationWebhookURL() string {
+	return getEnvString(proxyAuthzWebhookURLEnvVar, "")
+}
+
+// AuthorizationWebhookCacheTTL returns how long the proxy caches the decisions of the authorization webhook (see AuthorizationWebhookURL)
+// for the same user, workspace, verb and path. Defaults to 1 minute. Zero means the decisions are not cached.
+func (r ProxyConfig) AuthorizationWebhookCacheTTL() time.Duration {
+	return getEnvDuration(proxyAuthzWebhookCacheTTLEnvVar, time.Minute)
+}
+
+// AuthorizationWebhookFailOpen returns true if the requests are forwarded when the authorization webhook (see AuthorizationWebhookURL)
+// can't be called or fails. The requests are rejected by default.
+func (r ProxyConfig) AuthorizationWebhookFailOpen() bool {
+	return getEnvBool(proxyAuthzWebhookFailOpenEnvVar, false)
+}
+
+// WorkspaceQuota returns the maximum number of requests per second the proxy forwards to each workspace, whichever the users
+// sending them, so that a shared or community workspace can't be used to overload a member cluster (see WorkspaceQuotaOverrides).
+// There is no quota when the value is zero (the default) or negative.
+func (r ProxyConfig) WorkspaceQuota() int {
+	return getEnvInt(proxyWorkspaceQuotaEnvVar, 0)
+}
+
+// WorkspaceQuotaBurst returns the number of requests to a workspace the proxy tolerates above its quota during a short period
+// of time. Defaults to the quota of the workspace when the value is zero (the default) or negative.
+func (r ProxyConfig) WorkspaceQuotaBurst() int {
+	return getEnvInt(proxyWorkspaceQuotaBurstEnvVar, 0)
+}
+
+// WorkspaceQuotaOverrides returns the quotas of the workspaces which differ from the WorkspaceQuota, configured as a comma-separated
+// list of 'workspace=quota' entries, eg. 'community-demo=50'. A zero quota disables the quota of the workspace. Invalid entries are ignored.
+func (r ProxyConfig) WorkspaceQuotaOverrides() map[string]int {
+	overrides := map[string]int{}
+	for _, entry := range getEnvList(proxyWorkspaceQuotaOverridesEnvVar) {
+		workspace, quota, found := strings.Cut(entry, "=")
+		q, err := strconv.Atoi(strings.TrimSpace(quota))
+		if !found || strings.TrimSpace(workspace) == "" || err != nil || q < 0 {
+			logger.Error(err, "ignoring invalid workspace quota", "name", envVarPrefix+proxyWorkspaceQuotaOverridesEnvVar, "value", entry)
+			continue
+		}
+		overrides[strings.TrimSpace(workspace)] = q
+	}
+	return overrides
+}
+
+// ShadowWorkspaces returns the secondary member cluster of each workspace whose read-only requests are also sent, by the proxy,
+// to the secondary member cluster, whose responses are discarded, so that the migration of the workspaces to another member cluster
+// can be validated before the cutover. Configured as a comma-separated list of 'workspace=member-cluster' entries,
+// eg. 'smith-dev=member-2'. Invalid entries are ignored. No request is shadowed by default.
+func (r ProxyConfig) ShadowWorkspaces() map[string]string {
+	workspaces := map[string]string{}
+	for _, entry := range getEnvList(proxyShadowWorkspacesEnvVar) {
+		workspace, cluster, found := strings.Cut(entry, "=")
+		workspace, cluster = strings.TrimSpace(workspace), strings.TrimSpace(cluster)
+		if !found || workspace == "" || cluster == "" {
+			logger.Error(nil, "ignoring invalid shadow workspace", "name", envVarPrefix+proxyShadowWorkspacesEnvVar, "value", entry)
+			continue
+		}
+		workspaces[workspace] = cluster
+	}
+	return workspaces
+}
+
+// RoutingAdmins returns the names of the users allowed to route their requests to a given member cluster with the X-Route-To
+// header of the proxy, eg. to check a workspace which is being relocated to another member cluster. Configured as a comma-separated
+// list of usernames. No user is allowed by default.
+func (r ProxyConfig) RoutingAdmins() []string {
+	return getEnvList(proxyRoutingAdminsEnvVar)
+}
+
+// Admins returns the names of the users allowed to use the admin endpoints of the proxy, ie. to register the endpoints of the
+// member clusters, to revoke the tokens and to capture the requests at runtime, and to send requests as another user with the
+// ImpersonateUserHeader (see also ImpersonationAdminClaim). Configured as a comma-separated list of usernames. No user is allowed
+// by default.
+func (r ProxyConfig) Admins() []string {
+	return getEnvList(proxyAdminsEnvVar)
+}
+
+// ImpersonationAdminClaim returns the name and the value of the token claim of the users allowed to send requests as another
+// user through the proxy, in addition to the Admins, eg. 'roles=sandbox-support' for the users whose 'roles' claim
+// contains 'sandbox-support'. Configured as '<claim>=<value>'. No claim is used by default, nor if the setting is invalid.
+func (r ProxyConfig) ImpersonationAdminClaim() (string, string) {
+	claim, value, found := strings.Cut(getEnvString(proxyImpersonationAdminClaimEnvVar, ""), "=")
+	claim, value = strings.TrimSpace(claim), strings.TrimSpace(value)
+	if !found || claim == "" || value == "" {
+		return "", ""
+	}
+	return claim, value
+}
+
+// TLSCertFile returns the path of the PEM-encoded certificate the proxy serves TLS with, along with the TLSKeyFile, eg. when the
+// client certificates are used (see ClientCAFile). The proxy serves plain HTTP when empty (the default), eg. behind a route
+// terminating TLS.
+func (r ProxyConfig) TLSCertFile() string {
+	return getEnvString(proxyTLSCertFileEnvVar, "")
+}
+
+// TLSKeyFile returns the path of the PEM-encoded private key of the TLSCertFile
+func (r ProxyConfig) TLSKeyFile() string {
+	return getEnvString(proxyTLSKeyFileEnvVar, "")
+}
+
+// ClientCAFile returns the path of the PEM-encoded certificates of the authorities the client certificates are verified with,
+// so that the machine clients can authenticate with a client certificate instead of a token (see ClientCertRules). It only
+// applies when the proxy serves TLS (see TLSCertFile). The client certificates are not requested when empty (the default).
+func (r ProxyConfig) ClientCAFile() string {
+	return getEnvString(proxyClientCAFileEnvVar, "")
+}
+
+// ClientCertRule maps the client certificates with a given common name or subject alternative name to a user
+type ClientCertRule struct {
+	// Field is the field of the certificate the rule matches: 'cn' (the common name of the subject), or 'dns', 'email' or 'uri'
+	// (the subject alternative names of the given type)
+	Field string
+	// Value is the value of the field, or '*' for any value
+	Value string
+	// Username is the name of the user the certificate is mapped to, or '*' for the matched value itself
+	Username string
+}
+
+// clientCertFields are the fields of the client certificates the ClientCertRules can match
+var clientCertFields = []string{"cn", "dns", "email", "uri"}
+
+// ClientCertRules returns the rules mapping the client certificates to the users, configured as a comma-separated list of
+// '<field>:<value>=<username>' entries, eg. 'cn:tekton-ci=smith,uri:spiffe://example.com/ci=john,cn:*=*'. The first matching
+// rule applies, and the certificates which match no rule are rejected. Invalid entries are ignored. No rule is set by default.
+func (r ProxyConfig) ClientCertRules() []ClientCertRule {
+	rules := []ClientCertRule{}
+	for _, entry := range getEnvList(proxyClientCertRulesEnvVar) {
+		field, mapping, _ := strings.Cut(entry, ":")
+		// the usernames can't contain any '=', unlike the URIs
+		i := strings.LastIndex(mapping, "=")
+		if i < 0 {
+			logger.Error(nil, "ignoring invalid client certificate rule", "name", envVarPrefix+proxyClientCertRulesEnvVar, "value", entry)
+			continue
+		}
+		rule := ClientCertRule{
+			Field:    strings.ToLower(strings.TrimSpace(field)),
+			Value:    strings.TrimSpace(mapping[:i]),
+			Username: strings.TrimSpace(mapping[i+1:]),
+		}
+		if !slices.Contains(clientCertFields, rule.Field) || rule.Value == "" || rule.Username == "" {
+			logger.Error(nil, "ignoring invalid client certificate rule", "name", envVarPrefix+proxyClientCertRulesEnvVar, "value", entry)
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// GroupClaim returns the name of the token claim with the groups of the user (a string or a list of strings) the GroupRoles
+// are evaluated with. Defaults to 'groups'.
+func (r ProxyConfig) GroupClaim() string {
+	return getEnvString(proxyGroupClaimEnvVar, "groups")
+}
+
+// GroupRole grants a role in a workspace to the members of a group of the identity provider
+type GroupRole struct {
+	// Group is the name of the group, as found in the GroupClaim of the tokens
+	Group string
+	// Workspace is the name of the workspace the members of the group have access to
+	Workspace string
+	// Role is the role of the members of the group in the workspace, eg. 'viewer' or 'contributor'
+	Role string
+}
+
+// GroupRoles returns the roles granted in the shared workspaces to the members of the groups of the identity provider, so that
+// the enterprise groups don't need a SpaceBinding for each of their members, configured as a comma-separated list of
+// '<group>=<workspace>:<role>' entries, eg. 'payments-team=payments:contributor,auditors=payments:viewer'. The SpaceBinding
+// of the user, if any, takes precedence, then the first matching entry applies. Since no RoleBinding is created in the member
+// clusters for the members of the groups, their role must be granted there with the groups of the ImpersonateRoleGroupPrefix
+// setting. Invalid entries are ignored. No role is granted to the groups by default.
+func (r ProxyConfig) GroupRoles() []GroupRole {
+	roles := []GroupRole{}
+	for _, entry := range getEnvList(proxyGroupRolesEnvVar) {
+		// the group names can contain any ':', unlike the workspace names
+		i := strings.LastIndex(entry, "=")
+		workspace, role, found := strings.Cut(entry[i+1:], ":")
+		groupRole := GroupRole{
+			Group:     strings.TrimSpace(entry[:max(i, 0)]),
+			Workspace: strings.TrimSpace(workspace),
+			Role:      strings.TrimSpace(role),
+		}
+		if i < 0 || !found || groupRole.Group == "" || groupRole.Workspace == "" || groupRole.Role == "" {
+			logger.Error(nil, "ignoring invalid group role", "name", envVarPrefix+proxyGroupRolesEnvVar, "value", entry)
+			continue
+		}
+		roles = append(roles, groupRole)
+	}
+	return roles
+}
+
+// SSOCacheTTL returns how long the responses of SSO to the requests of the SSOCachedPaths are cached by the proxy, so that
+// the web login doesn't depend on SSO for each of these requests. Defaults to 5 minutes. Zero disables the cache.
+func (r ProxyConfig) SSOCacheTTL() time.Duration {
+	return getEnvDuration(proxySSOCacheTTLEnvVar, 5*time.Minute)
+}
+
+// SSOCacheMaxStale returns how long the cached responses of SSO can still be served once expired (see SSOCacheTTL), when SSO
+// is unavailable or responds with a server error, so that the web login keeps working during the outages of SSO. Defaults to 1 hour.
+func (r ProxyConfig) SSOCacheMaxStale() time.Duration {
+	return getEnvDuration(proxySSOCacheMaxStaleEnvVar, time.Hour)
+}
+
+// SSOCachedPaths returns the paths of the requests forwarded to SSO whose responses are cached (see SSOCacheTTL), configured
+// as a comma-separated list of absolute paths. Only the responses which don't depend on the user must be cached. Invalid entries
+// are ignored. Defaults to the well-known OAuth configuration and the keycloak.js adapter.
+func (r ProxyConfig) SSOCachedPaths() []string {
+	entries := getEnvList(proxySSOCachedPathsEnvVar)
+	if len(entries) == 0 {
+		entries = []string{"/.well-known/oauth-authorization-server", "/auth/js/keycloak.js"}
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry, "/") {
+			logger.Error(nil, "ignoring invalid SSO cached path", "name", envVarPrefix+proxySSOCachedPathsEnvVar, "value", entry)
+			continue
+		}
+		paths = append(paths, entry)
+	}
+	return paths
+}
+
+// LoginClientID returns the ID of the client of SSO the browsers log in with through the '/login' endpoint of the proxy, which
+// performs the OIDC code flow and keeps the token of the user server-side, in a session identified by an HttpOnly cookie. The
+// login endpoints are disabled if empty (the default).
+func (r ProxyConfig) LoginClientID() string {
+	return getEnvString(proxyLoginClientIDEnvVar, "")
+}
+
+// LoginClientSecret returns the secret of the LoginClientID client, if it is a confidential client. It is read from the
+// ProxyLoginClientSecretKey key of the registration service secret.
+func (r ProxyConfig) LoginClientSecret() string {
+	return r.secret[ProxyLoginClientSecretKey]
+}
+
+// SessionLifetime returns how long the sessions of the browsers logged in through the '/login' endpoint last at most. The sessions
+// also end when the token of the user expires. Defaults to 8 hours.
+func (r ProxyConfig) SessionLifetime() time.Duration {
+	return getEnvDuration(proxySessionLifetimeEnvVar, 8*time.Hour)
+}
+
+// SharedCacheURL returns the URL of the Redis server used to keep the caches of the replicas of the proxy consistent,
+// eg. 'redis://:password@redis:6379/0' (or 'rediss://' for TLS). The caches are not shared when empty (the default).
+func (r ProxyConfig) SharedCacheURL() string {
+	return getEnvString(proxySharedCacheURLEnvVar, "")
+}
+
+// RoutingRule routes a percentage of the users of a workspace to another member cluster than the one of the Space
+type RoutingRule struct {
+	// Cluster is the name of the member cluster the requests are routed to
+	Cluster string
+	// Percentage is the percentage (between 0 and 100) of the users whose requests are routed to the member cluster
+	Percentage int
+}
+
+// RoutingRules returns the rules routing the requests of a percentage of the users of each workspace to another member cluster,
+// so that the traffic can be gradually shifted to the new member cluster during the relocation of the Space. Configured as a
+// comma-separated list of 'workspace=member-cluster:percentage' entries, eg. 'smith-dev=member-3:25'. Invalid entries are ignored.
+// The requests are routed to the member cluster of the Space by default.
+func (r ProxyConfig) RoutingRules() map[string]RoutingRule {
+	rules := map[string]RoutingRule{}
+	for _, entry := range getEnvList(proxyRoutingRulesEnvVar) {
+		workspace, rule, found := strings.Cut(entry, "=")
+		cluster, percentage, found2 := strings.Cut(rule, ":")
+		workspace, cluster = strings.TrimSpace(workspace), strings.TrimSpace(cluster)
+		p, err := strconv.Atoi(strings.TrimSpace(percentage))
+		if !found || !found2 || workspace == "" || cluster == "" || err != nil || p < 0 || p > 100 {
+			logger.Error(err, "ignoring invalid routing rule", "name", envVarPrefix+proxyRoutingRulesEnvVar, "value", entry)
+			continue
+		}
+		rules[workspace] = RoutingRule{Cluster: cluster, Percentage: p}
+	}
+	return rules
+}
+
+// DenyRule is a combination of a verb and a resource the users can't request through the proxy, regardless of their permissions
+// in the member clusters. The verbs and the resources are the ones of the RBAC rules, eg. 'create' and 'pods/exec'.
+type DenyRule struct {
+	// Role is the role of the user in the targeted workspace the rule applies to, or '*' for all the users
+	Role string
+	// Verb is the verb of the request, or '*' for all the verbs
+	Verb string
+	// Resource is the resource of the request, with its subresource if any (eg. 'pods/exec'), or '*' for all the resources.
+	// 'pods/*' matches all the subresources of the pods, but not the pods themselves.
+	Resource string
+}
+
+// DenyRules returns the requests to the member clusters which are denied by the proxy, configured as a comma-separated list
+// of '[role:]verb:resource' entries, eg. 'get:secrets,viewer:create:pods/exec'. The rules without a role apply to all the users.
+// Invalid entries are ignored. No request is denied by default.
+func (r ProxyConfig) DenyRules() []DenyRule {
+	rules := []DenyRule{}
+	for _, entry := range getEnvList(proxyDenyRulesEnvVar) {
+		parts := strings.Split(entry, ":")
+		if len(parts) == 2 {
+			parts = append([]string{"*"}, parts...)
+		}
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		if len(parts) != 3 || slices.Contains(parts, "") {
+			logger.Error(nil, "ignoring invalid deny rule", "name", envVarPrefix+proxyDenyRulesEnvVar, "value", entry)
+			continue
+		}
+		rules = append(rules, DenyRule{Role: parts[0], Verb: strings.ToLower(parts[1]), Resource: strings.ToLower(parts[2])})
+	}
+	return rules
+}
+
+// DeniedPaths returns the paths of the API of the member clusters which are never forwarded by the proxy, eg. '/metrics' or
+// '/version', configured as a comma-separated list of absolute paths. '/debug/*' matches all the paths under '/debug', but not
+// '/debug' itself. Invalid entries are ignored. No path is denied by default.
+func (r ProxyConfig) DeniedPaths() []string {
+	paths := []string{}
+	for _, entry := range getEnvList(proxyDeniedPathsEnvVar) {
+		if !strings.HasPrefix(entry, "/") {
+			logger.Error(nil, "ignoring invalid denied path", "name", envVarPrefix+proxyDeniedPathsEnvVar, "value", entry)
+			continue
+		}
+		paths = append(paths, entry)
+	}
+	return paths
+}
+
+// RoleAllowedVerbs returns the verbs (as the verbs of the RBAC rules, eg. 'get', 'list' and 'watch') each role of the users in the
+// targeted workspaces is limited to by the proxy, regardless of their permissions in the member clusters. Configured as a
+// comma-separated list of 'role=verb|verb' entries, eg. 'viewer=get|list|watch'. The users with another role are not limited.
+// Invalid entries are ignored. No role is limited by default.
+func (r ProxyConfig) RoleAllowedVerbs() map[string][]string {
+	roles := map[string][]string{}
+	for _, entry := range getEnvList(proxyRoleAllowedVerbsEnvVar) {
+		role, value, found := strings.Cut(entry, "=")
+		verbs := []string{}
+		for _, verb := range strings.Split(value, "|") {
+			if verb = strings.ToLower(strings.TrimSpace(verb)); verb != "" {
+				verbs = append(verbs, verb)
+			}
+		}
+		if !found || strings.TrimSpace(role) == "" || len(verbs) == 0 {
+			logger.Error(nil, "ignoring invalid allowed verbs of a role", "name", envVarPrefix+proxyRoleAllowedVerbsEnvVar, "value", entry)
+			continue
+		}
+		roles[strings.TrimSpace(role)] = verbs
+	}
+	return roles
+}
+
+// AccessLogConfig contains the settings of the access log of the proxy
+type AccessLogConfig struct {
+}
+
+// Enabled returns true if the proxy should write an access log entry (as a JSON line) for the proxied requests
+func (r AccessLogConfig) Enabled() bool {
+	return getEnvBool(proxyAccessLogEnabledEnvVar, false)
+}
+
+// Sampling returns N if only one in N requests should be written to the access log.
+// The requests failing with a server error are always written.
+func (r AccessLogConfig) Sampling() int {
+	sampling := getEnvInt(proxyAccessLogSamplingEnvVar, 1)
+	if sampling < 1 {
+		return 1
+	}
+	return sampling
+}
+
+// RedactedFields returns the names of the fields of the access log entries whose values must be redacted, eg. "user" or "path"
+func (r AccessLogConfig) RedactedFields() []string {
+	return getEnvList(proxyAccessLogRedactedEnvVar)
+}
+
+// AuditMutations returns true if the mutating requests (POST, PUT, PATCH and DELETE) to the member clusters are always written
+// to the access log, ie. regardless of the sampling, along with the resource, the kind, the namespace and the name of their object
+func (r AccessLogConfig) AuditMutations() bool {
+	return getEnvBool(proxyAccessLogAuditMutationsEnvVar, false)
+}
+
+// LoadSheddingConfig contains the thresholds above which the proxy is overloaded and sheds the lowest-priority requests first.
+// There is no threshold by default, ie. no request is ever shed.
+type LoadSheddingConfig struct {
+}
+
+// MaxHeapBytes returns the size (in bytes) of the heap in use above which the proxy is overloaded, or zero if there is no threshold
+func (r LoadSheddingConfig) MaxHeapBytes() int {
+	return getEnvInt(proxyLoadSheddingMaxHeapEnvVar, 0)
+}
+
+// MaxGoroutines returns the number of goroutines above which the proxy is overloaded, or zero if there is no threshold
+func (r LoadSheddingConfig) MaxGoroutines() int {
+	return getEnvInt(proxyLoadSheddingGoroutinesEnvVar, 0)
+}
+
+// MaxInFlightRequests returns the number of requests (except the watch streams and the upgraded connections) handled concurrently
+// by the proxy above which the proxy is overloaded, or zero if there is no threshold
+func (r LoadSheddingConfig) MaxInFlightRequests() int {
+	return getEnvInt(proxyLoadSheddingMaxInFlightEnvVar, 0)
+}
+
+// Enabled returns true if at least one of the thresholds is set
+func (r LoadSheddingConfig) Enabled() bool {
+	return r.MaxHeapBytes() > 0 || r.MaxGoroutines() > 0 || r.MaxInFlightRequests() > 0
+}
+
 type VerificationConfig struct {
 	c       toolchainv1alpha1.RegistrationServiceVerificationConfig
 	secrets map[string]map[string]string
@@ -219,6 +1852,9 @@ func (r VerificationConfig) MessageTemplate() string {
 	return commonconfig.GetString(r.c.MessageTemplate, "Your Developer Sandbox verification code is %s")
 }
 
+// ExcludedEmailDomains returns the patterns of the email domains of the users who are not required to verify their phone number:
+// domain names, wildcard patterns such as '*.edu', or regular expressions enclosed in slashes (see util.DomainPatterns),
+// separated with commas (and thus, the regular expressions can't contain any comma)
 func (r VerificationConfig) ExcludedEmailDomains() []string {
 	excluded := commonconfig.GetString(r.c.ExcludedEmailDomains, "")
 	v := strings.FieldsFunc(excluded, func(c rune) bool {
@@ -280,6 +1916,13 @@ func (r VerificationConfig) CaptchaEnabled() bool {
 	return commonconfig.GetBool(r.c.Captcha.Enabled, false)
 }
 
+// CaptchaRequiredForSignup returns true if the signup requests must have a valid captcha token when the captcha is enabled, in which
+// case the requests without a token, or with an invalid one, are rejected before the UserSignup is created or reactivated, instead
+// of only requiring phone verification
+func (r VerificationConfig) CaptchaRequiredForSignup() bool {
+	return getEnvBool(captchaRequiredForSignupEnvVar, false)
+}
+
 func (r VerificationConfig) CaptchaScoreThreshold() float32 {
 	threshold := commonconfig.GetString(r.c.Captcha.ScoreThreshold, "")
 	thresholdFloat, err := strconv.ParseFloat(threshold, 32)
@@ -319,8 +1962,84 @@ func (r VerificationConfig) CaptchaProjectID() string {
 	return commonconfig.GetString(r.c.Captcha.ProjectID, "")
 }
 
+// CaptchaProvider returns the provider of the captcha assessments, either 'recaptcha' (reCAPTCHA Enterprise, the default),
+// 'turnstile' (Cloudflare Turnstile) or 'hcaptcha' (hCaptcha)
+func (r VerificationConfig) CaptchaProvider() string {
+	return getEnvString(captchaProviderEnvVar, CaptchaProviderRecaptcha)
+}
+
+// CaptchaTurnstileSecretKey returns the secret key the Cloudflare Turnstile tokens are validated with, from the
+// CaptchaTurnstileSecretKeyKey key of the registration service secret
+func (r VerificationConfig) CaptchaTurnstileSecretKey() string {
+	return r.registrationServiceSecret(CaptchaTurnstileSecretKeyKey)
+}
+
+// CaptchaHCaptchaSecretKey returns the secret key the hCaptcha tokens are validated with. The tokens are also checked against the
+// CaptchaSiteKey setting, if set. The key is read from the CaptchaHCaptchaSecretKeyKey key of the registration service secret.
+func (r VerificationConfig) CaptchaHCaptchaSecretKey() string {
+	return r.registrationServiceSecret(CaptchaHCaptchaSecretKeyKey)
+}
+
 func (r VerificationConfig) CaptchaServiceAccountFileContents() string {
 	key := commonconfig.GetString(r.c.Secret.RecaptchaServiceAccountFile, "")
 	content := r.registrationServiceSecret(key)
 	return string(content)
 }
+
+func getEnvString(name, defaultValue string) string {
+	if value, found := os.LookupEnv(envVarPrefix + name); found && value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList returns the entries of the comma-separated list of the environment variable, without their surrounding spaces.
+// The empty entries are ignored.
+func getEnvList(name string) []string {
+	list := []string{}
+	for _, entry := range strings.Split(getEnvString(name, ""), ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func getEnvBool(name string, defaultValue bool) bool {
+	value := getEnvString(name, "")
+	if value == "" {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		logger.Error(err, "unable to parse environment variable, using default value", "name", envVarPrefix+name, "default", defaultValue)
+		return defaultValue
+	}
+	return b
+}
+
+func getEnvInt(name string, defaultValue int) int {
+	value := getEnvString(name, "")
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Error(err, "unable to parse environment variable, using default value", "name", envVarPrefix+name, "default", defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+func getEnvDuration(name string, defaultValue time.Duration) time.Duration {
+	value := getEnvString(name, "")
+	if value == "" {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Error(err, "unable to parse environment variable, using default value", "name", envVarPrefix+name, "default", defaultValue.String())
+		return defaultValue
+	}
+	return d
+}
@@ -2,6 +2,7 @@ package configuration_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
@@ -46,6 +47,8 @@ func TestRegistrationService(t *testing.T) {
 		assert.Empty(t, regServiceCfg.RegistrationServiceURL())
 		assert.Empty(t, regServiceCfg.Analytics().SegmentWriteKey())
 		assert.Empty(t, regServiceCfg.Analytics().DevSpacesSegmentWriteKey())
+		assert.Empty(t, regServiceCfg.Analytics().Destinations())
+		assert.False(t, regServiceCfg.Analytics().SignupEventsEnabled())
 		assert.Equal(t, "https://sso.devsandbox.dev/auth/js/keycloak.js", regServiceCfg.Auth().AuthClientLibraryURL())
 		assert.Equal(t, "application/json; charset=utf-8", regServiceCfg.Auth().AuthClientConfigContentType())
 		assert.JSONEq(t, `{"realm": "sandbox-dev","auth-server-url": "https://sso.devsandbox.dev/auth","ssl-required": "none","resource": "sandbox-public","clientId": "sandbox-public","public-client": true, "confidential-port": 0}`,
@@ -69,8 +72,135 @@ func TestRegistrationService(t *testing.T) {
 		assert.InDelta(t, float32(0), regServiceCfg.Verification().CaptchaRequiredScore(), 0.01)
 		assert.True(t, regServiceCfg.Verification().CaptchaAllowLowScoreReactivation())
 		assert.Empty(t, regServiceCfg.Verification().CaptchaServiceAccountFileContents())
+		assert.Equal(t, configuration.CaptchaProviderRecaptcha, regServiceCfg.Verification().CaptchaProvider())
+		assert.Empty(t, regServiceCfg.Verification().CaptchaTurnstileSecretKey())
+		assert.Empty(t, regServiceCfg.Verification().CaptchaHCaptchaSecretKey())
+		assert.False(t, regServiceCfg.Verification().CaptchaRequiredForSignup())
 		assert.False(t, regServiceCfg.PublicViewerEnabled())
 		assert.Empty(t, regServiceCfg.AccountVerifierURL())
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().PublicKeysRefreshInterval())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Auth().PublicKeysMinRefreshInterval())
+		assert.Equal(t, "preferred_username", regServiceCfg.Auth().UsernameClaim())
+		assert.Equal(t, "email", regServiceCfg.Auth().EmailClaim())
+		assert.Equal(t, "sub", regServiceCfg.Auth().SubjectClaim())
+		assert.Equal(t, "given_name", regServiceCfg.Auth().GivenNameClaim())
+		assert.Equal(t, "family_name", regServiceCfg.Auth().FamilyNameClaim())
+		assert.Empty(t, regServiceCfg.Auth().DeviceClientID())
+		assert.Equal(t, []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}, regServiceCfg.Auth().AllowedAlgorithms())
+		assert.Empty(t, regServiceCfg.Auth().PublicKeysFile())
+		assert.Empty(t, regServiceCfg.Auth().Realms()[0].PublicKeysFile)
+		assert.True(t, regServiceCfg.Auth().TokensNotBefore().IsZero())
+		assert.Empty(t, regServiceCfg.Auth().IntrospectionClientID())
+		assert.Empty(t, regServiceCfg.Auth().IntrospectionClientSecret())
+		assert.Equal(t, time.Minute, regServiceCfg.Auth().IntrospectionCacheTTL())
+		assert.False(t, regServiceCfg.Auth().RequireVerifiedEmail())
+		assert.Empty(t, regServiceCfg.Auth().SSOProxyURL())
+		assert.Empty(t, regServiceCfg.Auth().SSOCABundleFile())
+		assert.False(t, regServiceCfg.PersonalAccessTokens().Enabled())
+		assert.Empty(t, regServiceCfg.PersonalAccessTokens().SigningKey())
+		assert.Equal(t, 30*24*time.Hour, regServiceCfg.PersonalAccessTokens().DefaultLifetime())
+		assert.Equal(t, 90*24*time.Hour, regServiceCfg.PersonalAccessTokens().MaxLifetime())
+		assert.Equal(t, 15*time.Minute, regServiceCfg.PersonalAccessTokens().ExchangeLifetime())
+		assert.True(t, regServiceCfg.SignupPolling().Enabled())
+		assert.Equal(t, time.Second, regServiceCfg.SignupPolling().MinInterval())
+		assert.Equal(t, 3, regServiceCfg.SignupPolling().Burst())
+		assert.Equal(t, 3*time.Second, regServiceCfg.SignupPolling().RetryAfter())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Proxy().PluginEndpointCacheTTL())
+		assert.Empty(t, regServiceCfg.Proxy().TrustedProxies())
+		assert.Empty(t, regServiceCfg.Proxy().CaptureUsername())
+		assert.True(t, regServiceCfg.Proxy().CaptureUntil().IsZero())
+		assert.False(t, regServiceCfg.Proxy().AccessLog().Enabled())
+		assert.Equal(t, 1, regServiceCfg.Proxy().AccessLog().Sampling())
+		assert.Empty(t, regServiceCfg.Proxy().AccessLog().RedactedFields())
+		assert.False(t, regServiceCfg.Proxy().AccessLog().AuditMutations())
+		assert.False(t, regServiceCfg.Proxy().LoadShedding().Enabled())
+		assert.False(t, regServiceCfg.Proxy().LogScrubbingEnabled())
+		assert.Zero(t, regServiceCfg.Proxy().LoadShedding().MaxHeapBytes())
+		assert.Zero(t, regServiceCfg.Proxy().LoadShedding().MaxGoroutines())
+		assert.Zero(t, regServiceCfg.Proxy().LoadShedding().MaxInFlightRequests())
+		assert.Zero(t, regServiceCfg.MemberSlowStartWindow())
+		assert.Equal(t, 100, regServiceCfg.HealthHistory().Size())
+		assert.Equal(t, 30*time.Second, regServiceCfg.HealthHistory().Interval())
+		assert.True(t, regServiceCfg.SecurityHeaders().Enabled())
+		assert.Equal(t, 365*24*time.Hour, regServiceCfg.SecurityHeaders().HSTSMaxAge())
+		assert.Equal(t, "default-src 'none'; frame-ancestors 'none'", regServiceCfg.SecurityHeaders().ContentSecurityPolicy())
+		assert.Zero(t, regServiceCfg.Proxy().MaxUpgradedConnectionsPerUser())
+		assert.Zero(t, regServiceCfg.Proxy().MaxInFlightRequestsPerMember())
+		assert.Zero(t, regServiceCfg.Proxy().InFlightQueueSize())
+		assert.Equal(t, 5*time.Second, regServiceCfg.Proxy().InFlightQueueTimeout())
+		assert.Empty(t, regServiceCfg.Proxy().CanaryFlags())
+		assert.Empty(t, regServiceCfg.Proxy().ListenAddresses())
+		assert.False(t, regServiceCfg.Proxy().DedicatedAdminPort())
+		assert.False(t, regServiceCfg.Proxy().PprofEnabled())
+		assert.Empty(t, regServiceCfg.Proxy().ImpersonateGroupClaims())
+		assert.Empty(t, regServiceCfg.Proxy().ImpersonateRoleGroupPrefix())
+		assert.Empty(t, regServiceCfg.Proxy().E2EUpstreamAllowlist())
+		assert.Zero(t, regServiceCfg.Proxy().ResponseHeaderTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().IdleTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().StreamingResponseHeaderTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().StreamingIdleTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().EventStreamIdleTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().WatchMaxLifetime())
+		assert.Zero(t, regServiceCfg.Proxy().ConnectionBufferSize())
+		assert.Zero(t, regServiceCfg.Proxy().CopyBufferSize())
+		assert.False(t, regServiceCfg.Proxy().ProxyProtocolEnabled())
+		assert.Empty(t, regServiceCfg.Proxy().DNSServers())
+		assert.Empty(t, regServiceCfg.Proxy().HostOverrides())
+		assert.Equal(t, 10000, regServiceCfg.Proxy().TokenCacheSize())
+		assert.Zero(t, regServiceCfg.Proxy().ImpersonatorTokenTTL())
+		assert.Empty(t, regServiceCfg.Proxy().DenyRules())
+		assert.Empty(t, regServiceCfg.Proxy().DeniedPaths())
+		assert.Empty(t, regServiceCfg.Proxy().RoleAllowedVerbs())
+		assert.False(t, regServiceCfg.Proxy().PreflightAccessReviewEnabled())
+		assert.Empty(t, regServiceCfg.Proxy().AuthorizationWebhookURL())
+		assert.Equal(t, time.Minute, regServiceCfg.Proxy().AuthorizationWebhookCacheTTL())
+		assert.False(t, regServiceCfg.Proxy().AuthorizationWebhookFailOpen())
+		assert.False(t, regServiceCfg.Proxy().StrictNamespaceValidationEnabled())
+		assert.Zero(t, regServiceCfg.Proxy().WorkspaceQuota())
+		assert.Zero(t, regServiceCfg.Proxy().WorkspaceQuotaBurst())
+		assert.Empty(t, regServiceCfg.Proxy().WorkspaceQuotaOverrides())
+		assert.Empty(t, regServiceCfg.Proxy().ShadowWorkspaces())
+		assert.Empty(t, regServiceCfg.Proxy().RoutingAdmins())
+		assert.Empty(t, regServiceCfg.Proxy().Admins())
+		assert.Empty(t, regServiceCfg.Proxy().TLSCertFile())
+		assert.Empty(t, regServiceCfg.Proxy().TLSKeyFile())
+		assert.Empty(t, regServiceCfg.Proxy().ClientCAFile())
+		assert.Empty(t, regServiceCfg.Proxy().ClientCertRules())
+		assert.Equal(t, "groups", regServiceCfg.Proxy().GroupClaim())
+		assert.Empty(t, regServiceCfg.Proxy().GroupRoles())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.Proxy().SSOCacheTTL())
+		assert.Equal(t, time.Hour, regServiceCfg.Proxy().SSOCacheMaxStale())
+		assert.Equal(t, []string{"/.well-known/oauth-authorization-server", "/auth/js/keycloak.js"}, regServiceCfg.Proxy().SSOCachedPaths())
+		assert.Empty(t, regServiceCfg.Proxy().LoginClientID())
+		assert.Empty(t, regServiceCfg.Proxy().LoginClientSecret())
+		assert.Equal(t, 8*time.Hour, regServiceCfg.Proxy().SessionLifetime())
+		claim, value := regServiceCfg.Proxy().ImpersonationAdminClaim()
+		assert.Empty(t, claim)
+		assert.Empty(t, value)
+		assert.Empty(t, regServiceCfg.Proxy().SharedCacheURL())
+		assert.Empty(t, regServiceCfg.Proxy().RoutingRules())
+		assert.Empty(t, regServiceCfg.Proxy().WorkspaceDomains())
+		assert.Equal(t, "the Developer Sandbox team at devsandbox@redhat.com", regServiceCfg.Support().Channel().Contact())
+		_, found := regServiceCfg.Support().PriorityChannel()
+		assert.False(t, found)
+		assert.Equal(t, []int{502, 503, 504}, regServiceCfg.Support().CriticalStatusCodes())
+		assert.Empty(t, regServiceCfg.Support().Admins())
+		assert.Empty(t, regServiceCfg.Webhooks().URLs())
+		assert.Empty(t, regServiceCfg.Webhooks().Secret())
+		assert.Equal(t, 5, regServiceCfg.Webhooks().MaxAttempts())
+		assert.Equal(t, time.Second, regServiceCfg.Webhooks().RetryInterval())
+		assert.Equal(t, 10*time.Second, regServiceCfg.Webhooks().Timeout())
+		assert.False(t, regServiceCfg.EmailVerification().Enabled())
+		assert.Empty(t, regServiceCfg.EmailVerification().SMTPAddress())
+		assert.Equal(t, "Verify your Developer Sandbox account", regServiceCfg.EmailVerification().Subject())
+		assert.Equal(t, "Open the following link to verify your Developer Sandbox account: %s", regServiceCfg.EmailVerification().MessageTemplate())
+		assert.Equal(t, 30*time.Minute, regServiceCfg.EmailVerification().LinkExpiresIn())
+		assert.Empty(t, regServiceCfg.EmailVerification().RedirectURL())
+		assert.Empty(t, regServiceCfg.TermsOfService().Version())
+		assert.Empty(t, regServiceCfg.TermsOfService().URL())
+		assert.False(t, regServiceCfg.TermsOfService().Required())
+		assert.True(t, regServiceCfg.Anonymization().RetainEmailHash())
+		assert.False(t, regServiceCfg.Anonymization().RetainPhoneHash())
 	})
 	t.Run("non-default", func(t *testing.T) {
 		// given
@@ -118,6 +248,14 @@ func TestRegistrationService(t *testing.T) {
 		verificationSecretValues["aws.accesskeyid"] = "foo"
 		verificationSecretValues["aws.secretaccesskey"] = "bar"
 		verificationSecretValues["captcha.json"] = "example-content"
+		verificationSecretValues[configuration.AuthIntrospectionClientSecretKey] = "s3cr3t"
+		verificationSecretValues[configuration.PersonalAccessTokensSigningKeyKey] = "s3cr3t"
+		verificationSecretValues[configuration.ProxyLoginClientSecretKey] = "s3cr3t"
+		verificationSecretValues[configuration.WebhooksSecretKey] = "s3cr3t"
+		verificationSecretValues[configuration.EmailVerificationSMTPPasswordKey] = "p4ssw0rd"
+		verificationSecretValues[configuration.EmailVerificationSigningKeyKey] = "s1gn1ng"
+		verificationSecretValues[configuration.CaptchaTurnstileSecretKeyKey] = "0x4AAA"
+		verificationSecretValues[configuration.CaptchaHCaptchaSecretKeyKey] = "0x0000"
 		secrets := make(map[string]map[string]string)
 		secrets["verification-secrets"] = verificationSecretValues
 
@@ -126,9 +264,13 @@ func TestRegistrationService(t *testing.T) {
 
 		// then
 		assert.Equal(t, "e2e-tests", regServiceCfg.Environment())
+		// no HSTS nor CSP by default outside of the production environment
+		assert.Zero(t, regServiceCfg.SecurityHeaders().HSTSMaxAge())
+		assert.Empty(t, regServiceCfg.SecurityHeaders().ContentSecurityPolicy())
 		assert.Equal(t, "debug", regServiceCfg.LogLevel())
 		assert.Equal(t, "www.crtregservice.com", regServiceCfg.RegistrationServiceURL())
 		assert.Equal(t, "keyabc", regServiceCfg.Analytics().SegmentWriteKey())
+		assert.Equal(t, []configuration.AnalyticsDestination{{Name: "sandbox", SegmentWriteKey: "keyabc"}}, regServiceCfg.Analytics().Destinations())
 		assert.Equal(t, "https://sso.openshift.com/auth/js/keycloak.js", regServiceCfg.Auth().AuthClientLibraryURL())
 		assert.Equal(t, "application/xml", regServiceCfg.Auth().AuthClientConfigContentType())
 		assert.JSONEq(t, `{"realm": "toolchain-private"}`, regServiceCfg.Auth().AuthClientConfigRaw()) //using as per linter suggestion encoded-compare: use assert.JSONEq (testifylint)
@@ -157,11 +299,588 @@ func TestRegistrationService(t *testing.T) {
 		assert.InDelta(t, float32(0.5), regServiceCfg.Verification().CaptchaRequiredScore(), 0.01)
 		assert.False(t, regServiceCfg.Verification().CaptchaAllowLowScoreReactivation())
 		assert.Equal(t, "example-content", regServiceCfg.Verification().CaptchaServiceAccountFileContents())
+		assert.Equal(t, "0x4AAA", regServiceCfg.Verification().CaptchaTurnstileSecretKey())
+		assert.Equal(t, "0x0000", regServiceCfg.Verification().CaptchaHCaptchaSecretKey())
+		assert.Equal(t, "s3cr3t", regServiceCfg.Auth().IntrospectionClientSecret())
+		assert.True(t, regServiceCfg.PersonalAccessTokens().Enabled())
+		assert.Equal(t, "s3cr3t", regServiceCfg.PersonalAccessTokens().SigningKey())
+		assert.Equal(t, "s3cr3t", regServiceCfg.Proxy().LoginClientSecret())
+		assert.Equal(t, "s3cr3t", regServiceCfg.Webhooks().Secret())
+		assert.Equal(t, "p4ssw0rd", regServiceCfg.EmailVerification().SMTPPassword())
+		assert.Equal(t, "s1gn1ng", regServiceCfg.EmailVerification().SigningKey())
 		assert.False(t, regServiceCfg.PublicViewerEnabled())
 		assert.Equal(t, "https://verifier.example.com", regServiceCfg.AccountVerifierURL())
 	})
 }
 
+func TestEnvironmentConfiguration(t *testing.T) {
+	t.Run("non-default", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_REFRESH_INTERVAL", "0")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_MIN_REFRESH_INTERVAL", "5s")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_USERNAME_CLAIM", "upn")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_EMAIL_CLAIM", "mail")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_SUBJECT_CLAIM", "oid")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_GIVEN_NAME_CLAIM", "first_name")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_FAMILY_NAME_CLAIM", "last_name")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_DEVICE_CLIENT_ID", "sandbox-cli")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_ALLOWED_ALGORITHMS", "ES256, EdDSA")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_FILE", "/etc/sandbox/keys.pem")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_TOKENS_NOT_BEFORE", "2024-02-01T08:00:00Z")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_INTROSPECTION_CLIENT_ID", "sandbox-proxy")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_INTROSPECTION_CACHE_TTL", "30s")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_REQUIRE_VERIFIED_EMAIL", "true")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_SSO_PROXY_URL", "http://proxy.example.com:3128")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_SSO_CA_BUNDLE_FILE", "/etc/pki/sso/ca.pem")
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_DEFAULT_LIFETIME", "24h")
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_MAX_LIFETIME", "168h")
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_EXCHANGE_LIFETIME", "5m")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_PROTECTION_ENABLED", "false")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_MIN_INTERVAL", "500ms")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "5")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_RETRY_AFTER", "10s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_PLUGIN_ENDPOINT_CACHE_TTL", "0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_TRUSTED_PROXIES", "10.128.0.0/14, 192.168.1.10,fd00::1")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CAPTURE_USERNAME", "johnsmith")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CAPTURE_UNTIL", "2024-01-01T12:00:00Z")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_SAMPLING", "10")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_REDACTED_FIELDS", "user, path")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_AUDIT_MUTATIONS", "true")
+		t.Setenv("REGISTRATION_SERVICE_MEMBER_SLOW_START_WINDOW", "72h")
+		t.Setenv("REGISTRATION_SERVICE_HEALTH_HISTORY_SIZE", "500")
+		t.Setenv("REGISTRATION_SERVICE_HEALTH_HISTORY_INTERVAL", "0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_MAX_UPGRADED_CONNECTIONS_PER_USER", "20")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_MAX_IN_FLIGHT_REQUESTS_PER_MEMBER", "400")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IN_FLIGHT_QUEUE_SIZE", "100")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IN_FLIGHT_QUEUE_TIMEOUT", "2s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_HEAP_BYTES", "1073741824")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_GOROUTINES", "50000")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_IN_FLIGHT_REQUESTS", "2000")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOG_SCRUBBING_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CANARY_FLAGS", "shared-transport=10, new-cors = 150")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LISTEN_ADDRESSES", "127.0.0.1:8081, unix:/var/run/proxy.sock,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DEDICATED_ADMIN_PORT", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_PPROF_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_GROUP_CLAIMS", "groups, roles,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_ROLE_GROUP_PREFIX", "sandbox-role:")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_E2E_UPSTREAM_ALLOWLIST", "http://127.0.0.1:9090, https://stub.example.com")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_RESPONSE_HEADER_TIMEOUT", "10s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IDLE_TIMEOUT", "30s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_STREAMING_RESPONSE_HEADER_TIMEOUT", "1m")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_STREAMING_IDLE_TIMEOUT", "1h")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WATCH_MAX_LIFETIME", "30m")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CONNECTION_BUFFER_SIZE", "65536")
+		t.Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_ENABLED", "false")
+		t.Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_HSTS_MAX_AGE", "1h")
+		t.Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_CONTENT_SECURITY_POLICY", "default-src 'self'")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_COPY_BUFFER_SIZE", "131072")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_PROXY_PROTOCOL_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DNS_SERVERS", "10.0.0.10, 10.0.0.11:5353,2001:db8::53")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_HOST_OVERRIDES", "API.member-1.example.com=10.0.1.10, api.member-2.example.com = internal.member-2")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_TOKEN_CACHE_SIZE", "0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATOR_TOKEN_TTL", "1h")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DENY_RULES", "get:secrets, viewer : CREATE : pods/exec,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DENIED_PATHS", "/metrics, /debug/*,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ROLE_ALLOWED_VERBS", "viewer=get|list|watch, contributor = GET | List|watch|create|")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_PREFLIGHT_ACCESS_REVIEW_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_URL", "https://policy.example.com/authorize")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_CACHE_TTL", "10s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_FAIL_OPEN", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_STRICT_NAMESPACE_VALIDATION_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA", "20")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA_BURST", "40")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA_OVERRIDES", "community-demo=50, internal = 0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SHADOW_WORKSPACES", "smith-dev=member-2, alice-dev = member-3")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_ADMINS", "admin1, admin2,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin3, ,admin4,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATION_ADMIN_CLAIM", "roles = sandbox-support")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", "/etc/proxy/tls.crt")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE", "/etc/proxy/tls.key")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CA_FILE", "/etc/proxy/ca.crt")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:tekton-ci=smith, URI:spiffe://example.com/ci?env=prod=john,cn:*=*")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_GROUP_CLAIM", "roles")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_GROUP_ROLES", "payments-team=payments:contributor, org:auditors = payments : viewer")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHE_TTL", "0s")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHE_MAX_STALE", "24h")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHED_PATHS", "/auth/js/keycloak.min.js, /auth/resources/logo.png")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOGIN_CLIENT_ID", "sandbox-proxy")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SESSION_LIFETIME", "1h")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SHARED_CACHE_URL", "redis://redis:6379/0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_DOMAINS", "proxy.example.com, .API.Sandbox.com.,")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3:25, alice-dev = member-2 : 100")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_TEAM", "the ACME support")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_EMAIL", "")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_URL", "https://support.acme.com")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_PRIORITY_EMAIL", "oncall@acme.com")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_CRITICAL_STATUS_CODES", "500, 503")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "alice, bob,")
+		t.Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", "https://hooks.example.com/sandbox, https://crm.example.com/events")
+		t.Setenv("REGISTRATION_SERVICE_WEBHOOKS_MAX_ATTEMPTS", "3")
+		t.Setenv("REGISTRATION_SERVICE_WEBHOOKS_RETRY_INTERVAL", "5s")
+		t.Setenv("REGISTRATION_SERVICE_WEBHOOKS_TIMEOUT", "3s")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_SMTP_ADDRESS", "smtp.acme.com:587")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_SMTP_USERNAME", "sandbox")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_FROM", "noreply@acme.com")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_LINK_EXPIRES_IN", "1h")
+		t.Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_REDIRECT_URL", "https://sandbox.acme.com/verified")
+		t.Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_VERSION", "2024-01")
+		t.Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_URL", "https://sandbox.acme.com/terms")
+		t.Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_REQUIRED", "true")
+		t.Setenv("REGISTRATION_SERVICE_ANONYMIZATION_RETAIN_EMAIL_HASH", "false")
+		t.Setenv("REGISTRATION_SERVICE_ANONYMIZATION_RETAIN_PHONE_HASH", "true")
+		t.Setenv("REGISTRATION_SERVICE_ANALYTICS_SIGNUP_EVENTS_ENABLED", "true")
+		t.Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "turnstile")
+		t.Setenv("REGISTRATION_SERVICE_CAPTCHA_REQUIRED_FOR_SIGNUP", "true")
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+
+		// when
+		regServiceCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{})
+
+		// then
+		assert.Zero(t, regServiceCfg.Auth().PublicKeysRefreshInterval())
+		assert.Equal(t, 5*time.Second, regServiceCfg.Auth().PublicKeysMinRefreshInterval())
+		assert.Equal(t, "upn", regServiceCfg.Auth().UsernameClaim())
+		assert.Equal(t, "mail", regServiceCfg.Auth().EmailClaim())
+		assert.Equal(t, "oid", regServiceCfg.Auth().SubjectClaim())
+		assert.Equal(t, "first_name", regServiceCfg.Auth().GivenNameClaim())
+		assert.Equal(t, "last_name", regServiceCfg.Auth().FamilyNameClaim())
+		assert.Equal(t, "sandbox-cli", regServiceCfg.Auth().DeviceClientID())
+		assert.Equal(t, []string{"ES256", "EdDSA"}, regServiceCfg.Auth().AllowedAlgorithms())
+		assert.Equal(t, "/etc/sandbox/keys.pem", regServiceCfg.Auth().PublicKeysFile())
+		assert.Equal(t, "/etc/sandbox/keys.pem", regServiceCfg.Auth().Realms()[0].PublicKeysFile)
+		assert.Equal(t, time.Date(2024, 2, 1, 8, 0, 0, 0, time.UTC), regServiceCfg.Auth().TokensNotBefore())
+		assert.Equal(t, "sandbox-proxy", regServiceCfg.Auth().IntrospectionClientID())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Auth().IntrospectionCacheTTL())
+		assert.True(t, regServiceCfg.Auth().RequireVerifiedEmail())
+		assert.Equal(t, "http://proxy.example.com:3128", regServiceCfg.Auth().SSOProxyURL())
+		assert.Equal(t, "/etc/pki/sso/ca.pem", regServiceCfg.Auth().SSOCABundleFile())
+		assert.Equal(t, 24*time.Hour, regServiceCfg.PersonalAccessTokens().DefaultLifetime())
+		assert.Equal(t, 7*24*time.Hour, regServiceCfg.PersonalAccessTokens().MaxLifetime())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.PersonalAccessTokens().ExchangeLifetime())
+		assert.False(t, regServiceCfg.SignupPolling().Enabled())
+		assert.Equal(t, 500*time.Millisecond, regServiceCfg.SignupPolling().MinInterval())
+		assert.Equal(t, 5, regServiceCfg.SignupPolling().Burst())
+		assert.Equal(t, 10*time.Second, regServiceCfg.SignupPolling().RetryAfter())
+		assert.Zero(t, regServiceCfg.Proxy().PluginEndpointCacheTTL())
+		trusted := regServiceCfg.Proxy().TrustedProxies()
+		require.Len(t, trusted, 3)
+		assert.Equal(t, "10.128.0.0/14", trusted[0].String())
+		assert.Equal(t, "192.168.1.10/32", trusted[1].String())
+		assert.Equal(t, "fd00::1/128", trusted[2].String())
+		assert.Equal(t, "johnsmith", regServiceCfg.Proxy().CaptureUsername())
+		assert.Equal(t, time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), regServiceCfg.Proxy().CaptureUntil())
+		assert.True(t, regServiceCfg.Proxy().AccessLog().Enabled())
+		assert.Equal(t, 10, regServiceCfg.Proxy().AccessLog().Sampling())
+		assert.Equal(t, []string{"user", "path"}, regServiceCfg.Proxy().AccessLog().RedactedFields())
+		assert.True(t, regServiceCfg.Proxy().AccessLog().AuditMutations())
+		assert.True(t, regServiceCfg.Proxy().LoadShedding().Enabled())
+		assert.Equal(t, 1073741824, regServiceCfg.Proxy().LoadShedding().MaxHeapBytes())
+		assert.Equal(t, 50000, regServiceCfg.Proxy().LoadShedding().MaxGoroutines())
+		assert.Equal(t, 2000, regServiceCfg.Proxy().LoadShedding().MaxInFlightRequests())
+		assert.True(t, regServiceCfg.Proxy().LogScrubbingEnabled())
+		assert.Equal(t, 72*time.Hour, regServiceCfg.MemberSlowStartWindow())
+		assert.Equal(t, 500, regServiceCfg.HealthHistory().Size())
+		assert.Zero(t, regServiceCfg.HealthHistory().Interval())
+		assert.Equal(t, 20, regServiceCfg.Proxy().MaxUpgradedConnectionsPerUser())
+		assert.Equal(t, 400, regServiceCfg.Proxy().MaxInFlightRequestsPerMember())
+		assert.Equal(t, 100, regServiceCfg.Proxy().InFlightQueueSize())
+		assert.Equal(t, 2*time.Second, regServiceCfg.Proxy().InFlightQueueTimeout())
+		assert.Equal(t, map[string]int{"shared-transport": 10, "new-cors": 100}, regServiceCfg.Proxy().CanaryFlags())
+		assert.Equal(t, []string{"127.0.0.1:8081", "unix:/var/run/proxy.sock"}, regServiceCfg.Proxy().ListenAddresses())
+		assert.True(t, regServiceCfg.Proxy().DedicatedAdminPort())
+		assert.True(t, regServiceCfg.Proxy().PprofEnabled())
+		assert.Equal(t, []string{"groups", "roles"}, regServiceCfg.Proxy().ImpersonateGroupClaims())
+		assert.Equal(t, "sandbox-role:", regServiceCfg.Proxy().ImpersonateRoleGroupPrefix())
+		assert.Equal(t, []string{"http://127.0.0.1:9090", "https://stub.example.com"}, regServiceCfg.Proxy().E2EUpstreamAllowlist())
+		assert.Equal(t, 10*time.Second, regServiceCfg.Proxy().ResponseHeaderTimeout())
+		assert.Equal(t, 30*time.Second, regServiceCfg.Proxy().IdleTimeout())
+		assert.Equal(t, time.Minute, regServiceCfg.Proxy().StreamingResponseHeaderTimeout())
+		assert.Equal(t, time.Hour, regServiceCfg.Proxy().StreamingIdleTimeout())
+		assert.Equal(t, 30*time.Minute, regServiceCfg.Proxy().WatchMaxLifetime())
+		assert.Equal(t, 65536, regServiceCfg.Proxy().ConnectionBufferSize())
+		assert.Equal(t, 131072, regServiceCfg.Proxy().CopyBufferSize())
+		assert.True(t, regServiceCfg.Proxy().ProxyProtocolEnabled())
+		assert.Equal(t, []string{"10.0.0.10:53", "10.0.0.11:5353", "[2001:db8::53]:53"}, regServiceCfg.Proxy().DNSServers())
+		assert.Equal(t, map[string]string{
+			"api.member-1.example.com": "10.0.1.10",
+			"api.member-2.example.com": "internal.member-2",
+		}, regServiceCfg.Proxy().HostOverrides())
+		assert.False(t, regServiceCfg.SecurityHeaders().Enabled())
+		assert.Equal(t, time.Hour, regServiceCfg.SecurityHeaders().HSTSMaxAge())
+		assert.Equal(t, "default-src 'self'", regServiceCfg.SecurityHeaders().ContentSecurityPolicy())
+		// the event streams have the idle timeout of the streaming requests, unless set
+		assert.Equal(t, time.Hour, regServiceCfg.Proxy().EventStreamIdleTimeout())
+		t.Setenv("REGISTRATION_SERVICE_PROXY_EVENT_STREAM_IDLE_TIMEOUT", "10m")
+		assert.Equal(t, 10*time.Minute, regServiceCfg.Proxy().EventStreamIdleTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().TokenCacheSize())
+		assert.Equal(t, time.Hour, regServiceCfg.Proxy().ImpersonatorTokenTTL())
+		assert.Equal(t, []configuration.DenyRule{
+			{Role: "*", Verb: "get", Resource: "secrets"},
+			{Role: "viewer", Verb: "create", Resource: "pods/exec"},
+		}, regServiceCfg.Proxy().DenyRules())
+		assert.Equal(t, []string{"/metrics", "/debug/*"}, regServiceCfg.Proxy().DeniedPaths())
+		assert.Equal(t, map[string][]string{
+			"viewer":      {"get", "list", "watch"},
+			"contributor": {"get", "list", "watch", "create"},
+		}, regServiceCfg.Proxy().RoleAllowedVerbs())
+		assert.True(t, regServiceCfg.Proxy().PreflightAccessReviewEnabled())
+		assert.Equal(t, "https://policy.example.com/authorize", regServiceCfg.Proxy().AuthorizationWebhookURL())
+		assert.Equal(t, 10*time.Second, regServiceCfg.Proxy().AuthorizationWebhookCacheTTL())
+		assert.True(t, regServiceCfg.Proxy().AuthorizationWebhookFailOpen())
+		assert.True(t, regServiceCfg.Proxy().StrictNamespaceValidationEnabled())
+		assert.Equal(t, 20, regServiceCfg.Proxy().WorkspaceQuota())
+		assert.Equal(t, 40, regServiceCfg.Proxy().WorkspaceQuotaBurst())
+		assert.Equal(t, map[string]int{"community-demo": 50, "internal": 0}, regServiceCfg.Proxy().WorkspaceQuotaOverrides())
+		assert.Equal(t, map[string]string{"smith-dev": "member-2", "alice-dev": "member-3"}, regServiceCfg.Proxy().ShadowWorkspaces())
+		assert.Equal(t, []string{"admin1", "admin2"}, regServiceCfg.Proxy().RoutingAdmins())
+		assert.Equal(t, []string{"admin3", "admin4"}, regServiceCfg.Proxy().Admins())
+		assert.Equal(t, "/etc/proxy/tls.crt", regServiceCfg.Proxy().TLSCertFile())
+		assert.Equal(t, "/etc/proxy/tls.key", regServiceCfg.Proxy().TLSKeyFile())
+		assert.Equal(t, "/etc/proxy/ca.crt", regServiceCfg.Proxy().ClientCAFile())
+		assert.Equal(t, []configuration.ClientCertRule{
+			{Field: "cn", Value: "tekton-ci", Username: "smith"},
+			{Field: "uri", Value: "spiffe://example.com/ci?env=prod", Username: "john"},
+			{Field: "cn", Value: "*", Username: "*"},
+		}, regServiceCfg.Proxy().ClientCertRules())
+		assert.Equal(t, "roles", regServiceCfg.Proxy().GroupClaim())
+		assert.Equal(t, []configuration.GroupRole{
+			{Group: "payments-team", Workspace: "payments", Role: "contributor"},
+			{Group: "org:auditors", Workspace: "payments", Role: "viewer"},
+		}, regServiceCfg.Proxy().GroupRoles())
+		assert.Zero(t, regServiceCfg.Proxy().SSOCacheTTL())
+		assert.Equal(t, 24*time.Hour, regServiceCfg.Proxy().SSOCacheMaxStale())
+		assert.Equal(t, []string{"/auth/js/keycloak.min.js", "/auth/resources/logo.png"}, regServiceCfg.Proxy().SSOCachedPaths())
+		assert.Equal(t, "sandbox-proxy", regServiceCfg.Proxy().LoginClientID())
+		assert.Equal(t, time.Hour, regServiceCfg.Proxy().SessionLifetime())
+		claim, value := regServiceCfg.Proxy().ImpersonationAdminClaim()
+		assert.Equal(t, "roles", claim)
+		assert.Equal(t, "sandbox-support", value)
+		assert.Equal(t, "redis://redis:6379/0", regServiceCfg.Proxy().SharedCacheURL())
+		assert.Equal(t, []string{"proxy.example.com", "api.sandbox.com"}, regServiceCfg.Proxy().WorkspaceDomains())
+		assert.Equal(t, map[string]configuration.RoutingRule{
+			"smith-dev": {Cluster: "member-3", Percentage: 25},
+			"alice-dev": {Cluster: "member-2", Percentage: 100},
+		}, regServiceCfg.Proxy().RoutingRules())
+		// an empty value means the default value
+		assert.Equal(t, "the ACME support at devsandbox@redhat.com", regServiceCfg.Support().Channel().Contact())
+		priority, found := regServiceCfg.Support().PriorityChannel()
+		require.True(t, found)
+		assert.Equal(t, configuration.SupportChannel{Team: "the ACME support", Email: "oncall@acme.com"}, priority)
+		assert.Equal(t, []int{500, 503}, regServiceCfg.Support().CriticalStatusCodes())
+		assert.Equal(t, []string{"alice", "bob"}, regServiceCfg.Support().Admins())
+		assert.Equal(t, []string{"https://hooks.example.com/sandbox", "https://crm.example.com/events"}, regServiceCfg.Webhooks().URLs())
+		assert.Equal(t, 3, regServiceCfg.Webhooks().MaxAttempts())
+		assert.Equal(t, 5*time.Second, regServiceCfg.Webhooks().RetryInterval())
+		assert.Equal(t, 3*time.Second, regServiceCfg.Webhooks().Timeout())
+		assert.True(t, regServiceCfg.EmailVerification().Enabled())
+		assert.Equal(t, "smtp.acme.com:587", regServiceCfg.EmailVerification().SMTPAddress())
+		assert.Equal(t, "sandbox", regServiceCfg.EmailVerification().SMTPUsername())
+		assert.Equal(t, "noreply@acme.com", regServiceCfg.EmailVerification().From())
+		assert.Equal(t, time.Hour, regServiceCfg.EmailVerification().LinkExpiresIn())
+		assert.Equal(t, "https://sandbox.acme.com/verified", regServiceCfg.EmailVerification().RedirectURL())
+		assert.Equal(t, "2024-01", regServiceCfg.TermsOfService().Version())
+		assert.Equal(t, "https://sandbox.acme.com/terms", regServiceCfg.TermsOfService().URL())
+		assert.True(t, regServiceCfg.TermsOfService().Required())
+		assert.False(t, regServiceCfg.Anonymization().RetainEmailHash())
+		assert.True(t, regServiceCfg.Anonymization().RetainPhoneHash())
+		assert.True(t, regServiceCfg.Analytics().SignupEventsEnabled())
+		assert.Equal(t, configuration.CaptchaProviderTurnstile, regServiceCfg.Verification().CaptchaProvider())
+		assert.True(t, regServiceCfg.Verification().CaptchaRequiredForSignup())
+	})
+
+	t.Run("secrets are not read from the environment", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_SIGNING_KEY", "s3cr3t")
+		t.Setenv("REGISTRATION_SERVICE_WEBHOOKS_SECRET", "s3cr3t")
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+
+		// when
+		regServiceCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{})
+
+		// then
+		assert.False(t, regServiceCfg.PersonalAccessTokens().Enabled())
+		assert.Empty(t, regServiceCfg.Webhooks().Secret())
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_AUTH_PUBLIC_KEYS_REFRESH_INTERVAL", "hourly")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_ALLOWED_ALGORITHMS", "HS256,none,,es256,ES384")
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_MAX_LIFETIME", "a quarter")
+		t.Setenv("REGISTRATION_SERVICE_PERSONAL_ACCESS_TOKENS_EXCHANGE_LIFETIME", "short")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_PROTECTION_ENABLED", "maybe")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_MIN_INTERVAL", "soon")
+		t.Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "a few")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_TRUSTED_PROXIES", "10.128.0.0/33,router,10.0.0.1")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CAPTURE_UNTIL", "tomorrow")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_TOKENS_NOT_BEFORE", "yesterday")
+		t.Setenv("REGISTRATION_SERVICE_AUTH_INTROSPECTION_CACHE_TTL", "a minute")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_SAMPLING", "0")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CANARY_FLAGS", "shared-transport,new-cors=some,=10,other=-1,valid=5")
+		t.Setenv("REGISTRATION_SERVICE_SUPPORT_CRITICAL_STATUS_CODES", "503,unavailable")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DENY_RULES", "secrets,get:,a:b:c:d,delete:*")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_DENIED_PATHS", "metrics,/version")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ROLE_ALLOWED_VERBS", "viewer,=get,admin=|,valid=get")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA_OVERRIDES", "demo,other=many,=10,negative=-1,valid=5")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SHADOW_WORKSPACES", "smith-dev,=member-2,alice-dev=,bob-dev=member-2")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_EVENT_STREAM_IDLE_TIMEOUT", "forever")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_WATCH_MAX_LIFETIME", "1 hour")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3,=member-3:10,alice-dev=:10,bob-dev=member-3:101,john-dev=member-3:-1,jane-dev=member-3:all,valid=member-3:5")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_HOST_OVERRIDES", "api.member-1.example.com,=10.0.1.10,api.member-2.example.com=,valid=10.0.1.12")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATION_ADMIN_CLAIM", "roles")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:tekton-ci,ou:ci=smith,dns:=smith,email:ci@example.com=,dns:ci.example.com=john")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHE_TTL", "5 minutes")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHED_PATHS", "auth/js/keycloak.js,,/auth/js/keycloak.min.js")
+		t.Setenv("REGISTRATION_SERVICE_PROXY_GROUP_ROLES", "payments-team,=payments:viewer,auditors=payments,auditors=:viewer,auditors=payments:,valid=payments:viewer")
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t)
+
+		// when
+		regServiceCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{})
+
+		// then default values are used
+		assert.Equal(t, time.Hour, regServiceCfg.Auth().PublicKeysRefreshInterval())
+		assert.Equal(t, 90*24*time.Hour, regServiceCfg.PersonalAccessTokens().MaxLifetime())
+		assert.Equal(t, 15*time.Minute, regServiceCfg.PersonalAccessTokens().ExchangeLifetime())
+		assert.True(t, regServiceCfg.SignupPolling().Enabled())
+		assert.Equal(t, time.Second, regServiceCfg.SignupPolling().MinInterval())
+		assert.Equal(t, 3, regServiceCfg.SignupPolling().Burst())
+		// and invalid entries are ignored
+		assert.Equal(t, []string{"ES384"}, regServiceCfg.Auth().AllowedAlgorithms())
+		trusted := regServiceCfg.Proxy().TrustedProxies()
+		require.Len(t, trusted, 1)
+		assert.Equal(t, "10.0.0.1/32", trusted[0].String())
+		assert.True(t, regServiceCfg.Proxy().CaptureUntil().IsZero())
+		assert.True(t, regServiceCfg.Auth().TokensNotBefore().IsZero())
+		assert.Equal(t, time.Minute, regServiceCfg.Auth().IntrospectionCacheTTL())
+		assert.Equal(t, 1, regServiceCfg.Proxy().AccessLog().Sampling())
+		assert.Equal(t, map[string]int{"valid": 5}, regServiceCfg.Proxy().CanaryFlags())
+		assert.Equal(t, []int{503}, regServiceCfg.Support().CriticalStatusCodes())
+		assert.Equal(t, []configuration.DenyRule{{Role: "*", Verb: "delete", Resource: "*"}}, regServiceCfg.Proxy().DenyRules())
+		assert.Equal(t, []string{"/version"}, regServiceCfg.Proxy().DeniedPaths())
+		assert.Equal(t, map[string][]string{"valid": {"get"}}, regServiceCfg.Proxy().RoleAllowedVerbs())
+		assert.Equal(t, map[string]int{"valid": 5}, regServiceCfg.Proxy().WorkspaceQuotaOverrides())
+		assert.Equal(t, map[string]string{"bob-dev": "member-2"}, regServiceCfg.Proxy().ShadowWorkspaces())
+		assert.Zero(t, regServiceCfg.Proxy().EventStreamIdleTimeout())
+		assert.Zero(t, regServiceCfg.Proxy().WatchMaxLifetime())
+		assert.Equal(t, map[string]configuration.RoutingRule{"valid": {Cluster: "member-3", Percentage: 5}}, regServiceCfg.Proxy().RoutingRules())
+		assert.Equal(t, map[string]string{"valid": "10.0.1.12"}, regServiceCfg.Proxy().HostOverrides())
+		claim, value := regServiceCfg.Proxy().ImpersonationAdminClaim()
+		assert.Empty(t, claim)
+		assert.Empty(t, value)
+		assert.Equal(t, []configuration.ClientCertRule{{Field: "dns", Value: "ci.example.com", Username: "john"}}, regServiceCfg.Proxy().ClientCertRules())
+		assert.Equal(t, []configuration.GroupRole{{Group: "valid", Workspace: "payments", Role: "viewer"}}, regServiceCfg.Proxy().GroupRoles())
+		assert.Equal(t, 5*time.Minute, regServiceCfg.Proxy().SSOCacheTTL())
+		assert.Equal(t, []string{"/auth/js/keycloak.min.js"}, regServiceCfg.Proxy().SSOCachedPaths())
+	})
+}
+
+func TestAnalyticsDestinations(t *testing.T) {
+	t.Run("additional destinations", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_ANALYTICS_DESTINATIONS", `[
+			{"name":"devspaces","segmentWriteKey":"devspaces-override","events":["signup"]},
+			{"name":"console","segmentWriteKey":"console-key"},
+			{"name":"no-key"}
+		]`)
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Analytics().SegmentWriteKey("sandbox-key").
+			Analytics().DevSpacesSegmentWriteKey("devspaces-key"))
+
+		// when
+		analyticsCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{}).Analytics()
+
+		// then
+		assert.Equal(t, []configuration.AnalyticsDestination{
+			{Name: "sandbox", SegmentWriteKey: "sandbox-key"},
+			{Name: "devspaces", SegmentWriteKey: "devspaces-override", Events: []string{"signup"}},
+			{Name: "console", SegmentWriteKey: "console-key"},
+		}, analyticsCfg.Destinations())
+		console, found := analyticsCfg.Destination("console")
+		require.True(t, found)
+		assert.Equal(t, "console-key", console.SegmentWriteKey)
+		_, found = analyticsCfg.Destination("no-key")
+		assert.False(t, found)
+	})
+
+	t.Run("invalid additional destinations are ignored", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_ANALYTICS_DESTINATIONS", `{"name":"console"`)
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Analytics().SegmentWriteKey("sandbox-key"))
+
+		// when
+		analyticsCfg := configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{}).Analytics()
+
+		// then
+		assert.Equal(t, []configuration.AnalyticsDestination{{Name: "sandbox", SegmentWriteKey: "sandbox-key"}}, analyticsCfg.Destinations())
+	})
+
+	t.Run("event filters", func(t *testing.T) {
+		all := configuration.AnalyticsDestination{Name: "all"}
+		filtered := configuration.AnalyticsDestination{Name: "filtered", Events: []string{"signup"}}
+
+		assert.True(t, all.Accepts("signup"))
+		assert.True(t, filtered.Accepts("signup"))
+		assert.False(t, filtered.Accepts("verification"))
+	})
+}
+
+func TestAuthRealms(t *testing.T) {
+	defaultRealm := configuration.SSORealm{
+		Name:             "sandbox-dev",
+		SSOBaseURL:       "https://sso.test.org",
+		Issuer:           "https://sso.test.org/auth/realms/sandbox-dev",
+		PublicKeysURL:    "https://sso.test.org/certs",
+		ClientLibraryURL: "https://sso.test.org/auth/js/keycloak.js",
+		ClientConfigRaw:  `{"realm": "sandbox-dev"}`,
+	}
+	newAuthConfig := func(t *testing.T) configuration.AuthConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().
+			Auth().SSOBaseURL("https://sso.test.org").
+			Auth().AuthClientPublicKeysURL("https://sso.test.org/certs").
+			Auth().AuthClientLibraryURL("https://sso.test.org/auth/js/keycloak.js").
+			Auth().AuthClientConfigRaw(`{"realm": "sandbox-dev"}`))
+		return configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{}).Auth()
+	}
+
+	t.Run("default realm only", func(t *testing.T) {
+		// when
+		authCfg := newAuthConfig(t)
+
+		// then
+		assert.Equal(t, []configuration.SSORealm{defaultRealm}, authCfg.Realms())
+		assert.Equal(t, defaultRealm, authCfg.RealmByName("partners"))
+		assert.Equal(t, defaultRealm, authCfg.RealmByHost("api.partners.example.com"))
+	})
+
+	t.Run("additional realms", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_AUTH_REALMS", `[
+			{"name":"partners","ssoBaseURL":"https://sso.partners.example.com/","clientConfig":"{\"realm\": \"partners\"}","hosts":["api.partners.example.com","registration.partners.example.com"]},
+			{"name":"acme","ssoBaseURL":"https://login.acme.com","issuer":"https://login.acme.com/realms/acme","publicKeysURL":"https://login.acme.com/keys","clientLibraryURL":"https://login.acme.com/keycloak.js"}
+		]`)
+
+		// when
+		authCfg := newAuthConfig(t)
+
+		// then
+		partners := configuration.SSORealm{
+			Name:             "partners",
+			SSOBaseURL:       "https://sso.partners.example.com",
+			Issuer:           "https://sso.partners.example.com/auth/realms/partners",
+			PublicKeysURL:    "https://sso.partners.example.com/auth/realms/partners/protocol/openid-connect/certs",
+			ClientLibraryURL: "https://sso.partners.example.com/auth/js/keycloak.js",
+			ClientConfigRaw:  `{"realm": "partners"}`,
+			Hosts:            []string{"api.partners.example.com", "registration.partners.example.com"},
+		}
+		acme := configuration.SSORealm{
+			Name:             "acme",
+			SSOBaseURL:       "https://login.acme.com",
+			Issuer:           "https://login.acme.com/realms/acme",
+			PublicKeysURL:    "https://login.acme.com/keys",
+			ClientLibraryURL: "https://login.acme.com/keycloak.js",
+		}
+		assert.Equal(t, []configuration.SSORealm{defaultRealm, partners, acme}, authCfg.Realms())
+		assert.Equal(t, partners, authCfg.RealmByName("partners"))
+		assert.Equal(t, acme, authCfg.RealmByName("acme"))
+		assert.Equal(t, defaultRealm, authCfg.RealmByName("unknown"))
+		assert.Equal(t, partners, authCfg.RealmByHost("API.partners.example.com:443"))
+		assert.Equal(t, partners, authCfg.RealmByHost("registration.partners.example.com"))
+		assert.Equal(t, defaultRealm, authCfg.RealmByHost("api.sandbox.example.com"))
+	})
+
+	t.Run("invalid additional realms are ignored", func(t *testing.T) {
+		for name, realms := range map[string]string{
+			"not a list":      `{"name":"partners"`,
+			"no name":         `[{"ssoBaseURL":"https://sso.partners.example.com"}]`,
+			"no SSO base URL": `[{"name":"partners"}]`,
+			"name of default": `[{"name":"sandbox-dev","ssoBaseURL":"https://sso.partners.example.com"}]`,
+		} {
+			t.Run(name, func(t *testing.T) {
+				// given
+				t.Setenv("REGISTRATION_SERVICE_AUTH_REALMS", realms)
+
+				// when
+				authCfg := newAuthConfig(t)
+
+				// then
+				assert.Equal(t, []configuration.SSORealm{defaultRealm}, authCfg.Realms())
+			})
+		}
+	})
+}
+
+func TestAuthClients(t *testing.T) {
+	newAuthConfig := func(t *testing.T) configuration.AuthConfig {
+		cfg := commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService())
+		return configuration.NewRegistrationServiceConfig(cfg, map[string]map[string]string{}).Auth()
+	}
+
+	t.Run("no client", func(t *testing.T) {
+		// when
+		authCfg := newAuthConfig(t)
+
+		// then
+		assert.Empty(t, authCfg.Clients())
+		_, found := authCfg.ClientByID("sandbox-public")
+		assert.False(t, found)
+		_, found = authCfg.ClientByOrigin("https://console.example.com")
+		assert.False(t, found)
+	})
+
+	t.Run("clients", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_AUTH_REALMS", `[{"name":"partners","ssoBaseURL":"https://sso.partners.example.com"}]`)
+		t.Setenv("REGISTRATION_SERVICE_AUTH_CLIENTS", `[
+			{"id":"console","origins":["https://console.example.com/"],"redirectURIs":["https://console.example.com/*"]},
+			{"id":"partners-console","realm":"partners","origins":["https://console.partners.example.com"],"config":"{\"realm\": \"partners\"}"},
+			{"id":"console","realm":"partners"},
+			{"id":"unknown-realm","realm":"unknown"},
+			{"origins":["https://other.example.com"]}
+		]`)
+
+		// when
+		authCfg := newAuthConfig(t)
+
+		// then
+		console := configuration.AuthClient{
+			ID:           "console",
+			Origins:      []string{"https://console.example.com/"},
+			RedirectURIs: []string{"https://console.example.com/*"},
+		}
+		partnersConsole := configuration.AuthClient{
+			ID:        "partners-console",
+			Realm:     "partners",
+			Origins:   []string{"https://console.partners.example.com"},
+			ConfigRaw: `{"realm": "partners"}`,
+		}
+		assert.Equal(t, []configuration.AuthClient{console, partnersConsole}, authCfg.Clients())
+		client, found := authCfg.ClientByID("partners-console")
+		assert.True(t, found)
+		assert.Equal(t, partnersConsole, client)
+		_, found = authCfg.ClientByID("unknown-realm")
+		assert.False(t, found)
+		client, found = authCfg.ClientByOrigin("https://Console.example.com")
+		assert.True(t, found)
+		assert.Equal(t, console, client)
+		_, found = authCfg.ClientByOrigin("https://other.example.com")
+		assert.False(t, found)
+	})
+
+	t.Run("invalid clients", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_AUTH_CLIENTS", `{"id":"console"}`)
+
+		// when
+		authCfg := newAuthConfig(t)
+
+		// then
+		assert.Empty(t, authCfg.Clients())
+	})
+}
+
 func TestPublicViewerConfiguration(t *testing.T) {
 	tt := map[string]struct {
 		name               string
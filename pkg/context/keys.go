@@ -11,6 +11,8 @@ const (
 	UsernameKey = "username"
 	// EmailKey is the context key for the email claim
 	EmailKey = "email"
+	// EmailVerifiedKey is the context key for the email_verified claim
+	EmailVerifiedKey = "emailVerified"
 	// GivenNameKey is the context key for the given name claim
 	GivenNameKey = "givenName"
 	// FamilyNameKey is the context key for the family name claim
@@ -25,12 +27,38 @@ const (
 	JWTClaimsKey = "jwtClaims"
 	// WorkspaceKey is the context key for the workspace name in echo.Context
 	WorkspaceKey = "workspace"
+	// HomeWorkspaceKey is the context key for the name of the home workspace of the user, when the request targets the home workspace
+	HomeWorkspaceKey = "homeWorkspace"
 	// RequestReceivedTime is the context key for the starting time of a request made
 	RequestReceivedTime = "requestReceivedTime"
 	// PublicViewerEnabled is a boolean value indicating whether PublicViewer support is enabled
 	PublicViewerEnabled = "publicViewerEnabled"
+	// AnonymousKey is a boolean value indicating whether the request was sent without a token, and is thus proxied as the PublicViewer
+	AnonymousKey = "anonymous"
 	// ImpersonateUser is the context key for the impersonated user in proxied call
 	ImpersonateUser = "impersonateUser"
+	// ClientCertificateKey is the context key for the subject of the client certificate the user of the proxied call authenticated with,
+	// instead of a token (see the ClientCertRules setting of the proxy)
+	ClientCertificateKey = "clientCertificate"
+	// ImpersonatedByKey is the context key for the name of the admin who sent the proxied call as another user
+	// (see the Admins setting of the proxy)
+	ImpersonatedByKey = "impersonatedBy"
+	// WorkspaceNamespacesKey is the context key for the names of the namespaces of the workspace targeted by the proxied call
+	WorkspaceNamespacesKey = "workspaceNamespaces"
+	// WorkspaceRoleKey is the context key for the role of the impersonated user in the workspace targeted by the proxied call
+	WorkspaceRoleKey = "workspaceRole"
+	// TargetClusterKey is the context key for the name of the member cluster the proxied call is forwarded to
+	TargetClusterKey = "targetCluster"
 	// SocialEvent is the context key for the activation code provided in UI
 	SocialEvent = "socialEvent"
+	// IdempotencyKey is the context key for the value of the Idempotency-Key header of the signup requests
+	IdempotencyKey = "idempotencyKey"
+	// TermsOfServiceVersionKey is the context key for the version of the terms of service accepted by the user with the signup request
+	TermsOfServiceVersionKey = "termsOfServiceVersion"
+	// CaptchaAssessmentKey is the context key for the captcha assessment of the token of the signup request, when completed before
+	// the UserSignup is created (see the CaptchaRequiredForSignup setting)
+	CaptchaAssessmentKey = "captchaAssessment"
+	// IdempotentReplayKey is a boolean value indicating whether the signup request was a repeat of a request with the same
+	// Idempotency-Key header, whose original result was returned
+	IdempotentReplayKey = "idempotentReplay"
 )
@@ -10,3 +10,10 @@ func IsPublicViewerEnabled(ctx echo.Context) bool {
 	publicViewerEnabled, _ := ctx.Get(PublicViewerEnabled).(bool)
 	return publicViewerEnabled
 }
+
+// IsAnonymous retrieves from the context the boolean value associated to the AnonymousKey key.
+// If the key is not set it returns false, otherwise it returns the boolean value stored in the context.
+func IsAnonymous(ctx echo.Context) bool {
+	anonymous, _ := ctx.Get(AnonymousKey).(bool)
+	return anonymous
+}
@@ -1,9 +1,11 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -30,3 +32,15 @@ func (a *Analytics) GetDevSpacesSegmentWriteKey(ctx *gin.Context) {
 	segmentWriteKey := cfg.Analytics().DevSpacesSegmentWriteKey()
 	ctx.String(http.StatusOK, segmentWriteKey)
 }
+
+// GetSegmentWriteKey returns the segment-write-key of the analytics destination given as the path param
+func (a *Analytics) GetSegmentWriteKey(ctx *gin.Context) {
+	cfg := configuration.GetRegistrationServiceConfig()
+	name := ctx.Param("destination")
+	destination, found := cfg.Analytics().Destination(name)
+	if !found {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, fmt.Errorf("analytics destination '%s' not found", name), "unknown analytics destination")
+		return
+	}
+	ctx.String(http.StatusOK, destination.SegmentWriteKey)
+}
@@ -102,4 +102,48 @@ func (s *TestAnalyticsSuite) TestAnalyticsHandler() {
 			assert.Equal(s.T(), cfg.Analytics().SegmentWriteKey(), dataEnvelope, "wrong 'segment write key' in segment response")
 		})
 	})
+
+	s.Run("segment write key of a named destination", func() {
+		s.OverrideApplicationDefault(testconfig.RegistrationService().
+			Analytics().SegmentWriteKey("testing sandbox segment write key"))
+		s.T().Setenv("REGISTRATION_SERVICE_ANALYTICS_DESTINATIONS", `[{"name":"console","segmentWriteKey":"testing console segment write key"}]`)
+
+		for name, expectedKey := range map[string]string{
+			"sandbox": "testing sandbox segment write key",
+			"console": "testing console segment write key",
+		} {
+			s.Run(name, func() {
+				// given
+				rr := httptest.NewRecorder()
+				ctx, _ := gin.CreateTestContext(rr)
+				req, err := http.NewRequest(http.MethodGet, "/api/v1/analytics/"+name+"/segment-write-key", nil)
+				require.NoError(s.T(), err)
+				ctx.Request = req
+				ctx.Params = gin.Params{{Key: "destination", Value: name}}
+
+				// when
+				analyticsCtrl.GetSegmentWriteKey(ctx)
+
+				// then
+				require.Equal(s.T(), http.StatusOK, rr.Code)
+				assert.Equal(s.T(), expectedKey, rr.Body.String())
+			})
+		}
+
+		s.Run("unknown destination", func() {
+			// given
+			rr := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rr)
+			req, err := http.NewRequest(http.MethodGet, "/api/v1/analytics/unknown/segment-write-key", nil)
+			require.NoError(s.T(), err)
+			ctx.Request = req
+			ctx.Params = gin.Params{{Key: "destination", Value: "unknown"}}
+
+			// when
+			analyticsCtrl.GetSegmentWriteKey(ctx)
+
+			// then
+			assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+		})
+	})
 }
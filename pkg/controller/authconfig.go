@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,6 +18,19 @@ type configResponse struct {
 	// The signup page URL. After user logs into SSO but has not signed up yet then this URL can be used to redirect the user to
 	// sign up. If not set then the base URL of the registration service is supposed to be used by the clients.
 	SignupURL string `json:"signup-url"`
+	// The URIs the client can redirect the user to once logged in, if restricted by the configuration of the client
+	RedirectURIs []string `json:"redirect-uris,omitempty"`
+}
+
+// publicClientConfig is the config of a public client of a realm, used by the clients whose config is not set
+type publicClientConfig struct {
+	Realm            string `json:"realm"`
+	AuthServerURL    string `json:"auth-server-url"`
+	SSLRequired      string `json:"ssl-required"`
+	Resource         string `json:"resource"`
+	ClientID         string `json:"clientId"`
+	PublicClient     bool   `json:"public-client"`
+	ConfidentialPort int    `json:"confidential-port"`
 }
 
 // AuthConfig implements the auth config endpoint, which is invoked to
@@ -27,13 +43,54 @@ func NewAuthConfig() *AuthConfig {
 	return &AuthConfig{}
 }
 
-// GetHandler returns raw auth config content for UI.
+// GetHandler returns raw auth config content for UI: the config of the client given by the 'client_id' query parameter or else
+// of the client used from the origin of the request, if any, or else the config of the realm of SSO the users of the host of the
+// request log in with.
 func (ac *AuthConfig) GetHandler(ctx *gin.Context) {
 	cfg := configuration.GetRegistrationServiceConfig()
-	configRespData := configResponse{
-		AuthClientLibraryURL: cfg.Auth().AuthClientLibraryURL(),
-		AuthClientConfigRaw:  cfg.Auth().AuthClientConfigRaw(),
-		SignupURL:            cfg.RegistrationServiceURL(),
+	// the response depends on the origin of the request
+	ctx.Header("Vary", "Origin")
+	var client configuration.AuthClient
+	found := false
+	if clientID := ctx.Query("client_id"); clientID != "" {
+		if client, found = cfg.Auth().ClientByID(clientID); !found {
+			crterrors.AbortWithError(ctx, http.StatusNotFound, fmt.Errorf("auth client '%s' not found", clientID), "unknown auth client")
+			return
+		}
+	} else if origin := ctx.GetHeader("Origin"); origin != "" {
+		client, found = cfg.Auth().ClientByOrigin(origin)
+	}
+	if !found {
+		realm := cfg.Auth().RealmByHost(ctx.Request.Host)
+		ctx.JSON(http.StatusOK, configResponse{
+			AuthClientLibraryURL: realm.ClientLibraryURL,
+			AuthClientConfigRaw:  realm.ClientConfigRaw,
+			SignupURL:            cfg.RegistrationServiceURL(),
+		})
+		return
 	}
-	ctx.JSON(http.StatusOK, configRespData)
+
+	realm := cfg.Auth().RealmByName(client.Realm)
+	configRaw := client.ConfigRaw
+	if configRaw == "" {
+		raw, err := json.Marshal(publicClientConfig{
+			Realm:         realm.Name,
+			AuthServerURL: realm.SSOBaseURL + "/auth",
+			SSLRequired:   "none",
+			Resource:      client.ID,
+			ClientID:      client.ID,
+			PublicClient:  true,
+		})
+		if err != nil {
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unable to marshal the auth client config")
+			return
+		}
+		configRaw = string(raw)
+	}
+	ctx.JSON(http.StatusOK, configResponse{
+		AuthClientLibraryURL: realm.ClientLibraryURL,
+		AuthClientConfigRaw:  configRaw,
+		SignupURL:            cfg.RegistrationServiceURL(),
+		RedirectURIs:         client.RedirectURIs,
+	})
 }
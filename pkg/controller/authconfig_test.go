@@ -126,3 +126,104 @@ func (s *TestAuthConfigSuite) TestAuthClientConfigHandler() {
 		})
 	})
 }
+
+func (s *TestAuthConfigSuite) TestAuthClientConfigHandlerWithRealms() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_REALMS", `[{"name":"partners","ssoBaseURL":"https://sso.partners.example.com","clientConfig":"{\"realm\": \"partners\"}","hosts":["registration.partners.example.com"]}]`)
+	cfg := configuration.GetRegistrationServiceConfig()
+	handler := gin.HandlerFunc(NewAuthConfig().GetHandler)
+	get := func(host string) configResponse {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/authconfig", nil)
+		ctx.Request.Host = host
+		handler(ctx)
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		var resp configResponse
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	s.Run("host of the realm", func() {
+		// when
+		resp := get("registration.partners.example.com:443")
+
+		// then
+		assert.Equal(s.T(), "https://sso.partners.example.com/auth/js/keycloak.js", resp.AuthClientLibraryURL)
+		assert.Equal(s.T(), `{"realm": "partners"}`, resp.AuthClientConfigRaw)
+	})
+
+	s.Run("other host", func() {
+		// when
+		resp := get("registration.example.com")
+
+		// then
+		assert.Equal(s.T(), cfg.Auth().AuthClientLibraryURL(), resp.AuthClientLibraryURL)
+		assert.Equal(s.T(), cfg.Auth().AuthClientConfigRaw(), resp.AuthClientConfigRaw)
+	})
+}
+
+func (s *TestAuthConfigSuite) TestAuthClientConfigHandlerWithClients() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_REALMS", `[{"name":"partners","ssoBaseURL":"https://sso.partners.example.com"}]`)
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_CLIENTS", `[
+		{"id":"console","origins":["https://console.example.com"],"config":"{\"clientId\": \"console\"}"},
+		{"id":"partners-console","realm":"partners","origins":["https://console.partners.example.com"],"redirectURIs":["https://console.partners.example.com/*"]}
+	]`)
+	cfg := configuration.GetRegistrationServiceConfig()
+	handler := gin.HandlerFunc(NewAuthConfig().GetHandler)
+	get := func(query, origin string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/api/v1/authconfig"+query, nil)
+		if origin != "" {
+			ctx.Request.Header.Set("Origin", origin)
+		}
+		handler(ctx)
+		return rr
+	}
+	response := func(rr *httptest.ResponseRecorder) configResponse {
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		assert.Equal(s.T(), "Origin", rr.Header().Get("Vary"))
+		var resp configResponse
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &resp))
+		return resp
+	}
+
+	s.Run("by client ID", func() {
+		// when
+		resp := response(get("?client_id=partners-console", "https://console.example.com"))
+
+		// then
+		assert.Equal(s.T(), "https://sso.partners.example.com/auth/js/keycloak.js", resp.AuthClientLibraryURL)
+		assert.JSONEq(s.T(), `{"realm": "partners","auth-server-url": "https://sso.partners.example.com/auth","ssl-required": "none","resource": "partners-console","clientId": "partners-console","public-client": true, "confidential-port": 0}`, resp.AuthClientConfigRaw)
+		assert.Equal(s.T(), []string{"https://console.partners.example.com/*"}, resp.RedirectURIs)
+	})
+
+	s.Run("by origin", func() {
+		// when
+		resp := response(get("", "https://console.example.com"))
+
+		// then
+		assert.Equal(s.T(), cfg.Auth().AuthClientLibraryURL(), resp.AuthClientLibraryURL)
+		assert.Equal(s.T(), `{"clientId": "console"}`, resp.AuthClientConfigRaw)
+		assert.Empty(s.T(), resp.RedirectURIs)
+	})
+
+	s.Run("other origin", func() {
+		// when
+		resp := response(get("", "https://other.example.com"))
+
+		// then
+		assert.Equal(s.T(), cfg.Auth().AuthClientConfigRaw(), resp.AuthClientConfigRaw)
+	})
+
+	s.Run("unknown client ID", func() {
+		// when
+		rr := get("?client_id=unknown", "")
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+		assert.Contains(s.T(), rr.Body.String(), "auth client 'unknown' not found")
+	})
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crtcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// BanReasonAnnotationKey is the annotation of the BannedUsers created with the admin ban endpoint, with the reason of the ban
+const BanReasonAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "ban-reason"
+
+// BanRequest is the body of the requests banning a user
+type BanRequest struct {
+	// Email is the email address of the user to ban
+	Email string `json:"email"`
+	// Reason is the reason of the ban
+	Reason string `json:"reason"`
+}
+
+// BanResponse is the response of the requests banning a user
+type BanResponse struct {
+	// Name is the name of the BannedUser resource
+	Name string `json:"name"`
+	// Email is the email address of the banned user
+	Email string `json:"email"`
+	// Reason is the reason of the ban
+	Reason string `json:"reason"`
+}
+
+// BanListener is notified of the users banned with the admin ban endpoint, by email, eg. to invalidate the caches of the proxy
+type BanListener func(ctx context.Context, email string)
+
+// Bans implements the admin endpoint banning the users for the support tooling, restricted to the users of the Admins
+// support setting
+type Bans struct {
+	namespaced.Client
+	listener BanListener
+}
+
+// NewBans returns a new Bans instance, notifying the given listener (if any) of the banned users.
+func NewBans(nsClient namespaced.Client, listener BanListener) *Bans {
+	return &Bans{
+		Client:   nsClient,
+		listener: listener,
+	}
+}
+
+// PostHandler creates a BannedUser for the email of the request, with the reason of the ban, unless the user is already
+// banned. The listener is notified in both cases, and the response is 201 or 200 (with the existing ban) respectively.
+func (b *Bans) PostHandler(ctx *gin.Context) {
+	if !requireAdmin(ctx) {
+		return
+	}
+	var req BanRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		log.Error(ctx, err, "invalid ban request")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if req.Email == "" || req.Reason == "" {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the email and the reason are required"), "invalid ban request")
+		return
+	}
+	emailHash := hash.EncodeString(req.Email)
+	bannedUser := &toolchainv1alpha1.BannedUser{
+		ObjectMeta: metav1.ObjectMeta{
+			// same name as the BannedUsers created by the ksctl ban command
+			Name:      fmt.Sprintf("banneduser-%s", emailHash),
+			Namespace: b.Namespace,
+			Labels: map[string]string{
+				toolchainv1alpha1.BannedUserEmailHashLabelKey: emailHash,
+			},
+			Annotations: map[string]string{
+				BanReasonAnnotationKey: req.Reason,
+			},
+		},
+		Spec: toolchainv1alpha1.BannedUserSpec{
+			Email:  req.Email,
+			Reason: req.Reason,
+		},
+	}
+	admin := ctx.GetString(crtcontext.UsernameKey)
+	if len(validation.IsValidLabelValue(admin)) == 0 {
+		bannedUser.Labels[toolchainv1alpha1.BannedByLabelKey] = admin
+	}
+	status := http.StatusCreated
+	if err := b.Create(ctx.Request.Context(), bannedUser); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			log.Error(ctx, err, "error creating the BannedUser")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error creating the BannedUser")
+			return
+		}
+		// the reason of the existing ban is returned
+		if err := b.Get(ctx.Request.Context(), b.NamespacedName(bannedUser.Name), bannedUser); err != nil {
+			log.Error(ctx, err, "error getting the BannedUser")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting the BannedUser")
+			return
+		}
+		status = http.StatusOK
+	} else {
+		log.Infof(ctx, "audit: user with email hash '%s' banned by '%s': %s", emailHash, admin, req.Reason)
+	}
+	if b.listener != nil {
+		b.listener(ctx.Request.Context(), req.Email)
+	}
+	ctx.JSON(status, BanResponse{
+		Name:   bannedUser.Name,
+		Email:  bannedUser.Spec.Email,
+		Reason: bannedUser.Spec.Reason,
+	})
+}
@@ -0,0 +1,115 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crtcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestBansSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunBansSuite(t *testing.T) {
+	suite.Run(t, &TestBansSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestBansSuite) TestPostHandler() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "support")
+	fakeClient := commontest.NewFakeClient(s.T())
+	var banned []string
+	ctrl := controller.NewBans(namespaced.NewClient(fakeClient, commontest.HostOperatorNs), func(_ context.Context, email string) {
+		banned = append(banned, email)
+	})
+	ban := func(user, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/admin/bans", bytes.NewBufferString(body))
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.Set(crtcontext.UsernameKey, user)
+		ctrl.PostHandler(ctx)
+		return rr
+	}
+	emailHash := hash.EncodeString("smith@example.com")
+
+	s.Run("banned", func() {
+		// when
+		rr := ban("support", `{"email":"smith@example.com","reason":"crypto mining"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusCreated, rr.Code, rr.Body.String())
+		bannedUser := &toolchainv1alpha1.BannedUser{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: commontest.HostOperatorNs, Name: "banneduser-" + emailHash}, bannedUser))
+		assert.Equal(s.T(), "smith@example.com", bannedUser.Spec.Email)
+		assert.Equal(s.T(), "crypto mining", bannedUser.Spec.Reason)
+		assert.Equal(s.T(), "crypto mining", bannedUser.Annotations[controller.BanReasonAnnotationKey])
+		assert.Equal(s.T(), emailHash, bannedUser.Labels[toolchainv1alpha1.BannedUserEmailHashLabelKey])
+		assert.Equal(s.T(), "support", bannedUser.Labels[toolchainv1alpha1.BannedByLabelKey])
+		assert.Equal(s.T(), []string{"smith@example.com"}, banned)
+
+		s.Run("already banned", func() {
+			// when
+			rr := ban("support", `{"email":"smith@example.com","reason":"another reason"}`)
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+			response := controller.BanResponse{}
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(s.T(), controller.BanResponse{
+				Name:   "banneduser-" + emailHash,
+				Email:  "smith@example.com",
+				Reason: "crypto mining",
+			}, response)
+			assert.Equal(s.T(), []string{"smith@example.com", "smith@example.com"}, banned)
+		})
+	})
+
+	s.Run("not an admin", func() {
+		// when
+		rr := ban("smith", `{"email":"alice@example.com","reason":"crypto mining"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "user 'smith' is not an admin", "forbidden")
+	})
+
+	s.Run("missing reason", func() {
+		// when
+		rr := ban("support", `{"email":"alice@example.com"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "the email and the reason are required", "invalid ban request")
+	})
+
+	s.Run("create error", func() {
+		// given
+		fakeClient.MockCreate = func(_ context.Context, _ client.Object, _ ...client.CreateOption) error {
+			return fmt.Errorf("mock error")
+		}
+		defer func() { fakeClient.MockCreate = nil }()
+
+		// when
+		rr := ban("support", `{"email":"alice@example.com","reason":"crypto mining"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusInternalServerError, "mock error", "error creating the BannedUser")
+	})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+// deviceCodeGrantType is the grant type of the device access token requests defined by RFC 8628
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceAuthorizationTimeout is the timeout of the requests to SSO
+const deviceAuthorizationTimeout = 10 * time.Second
+
+// DeviceAuthorizationResponse is the response of a successful device authorization request (see RFC 8628, section 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval,omitempty"`
+}
+
+// DeviceTokenResponse is the response of a successful device access token request (see RFC 6749, section 5.1)
+type DeviceTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// DeviceAuthorization implements the endpoints of the device authorization flow (see RFC 8628) against the SSO realm of the host
+// of the requests, so that the CLI users can log in from the machines without a browser: the CLI starts the flow, asks the user to
+// open the verification URI on any device and to enter the user code, then polls for the SSO token, which is usable with the proxy.
+type DeviceAuthorization struct {
+	client *http.Client
+}
+
+// NewDeviceAuthorization returns a new DeviceAuthorization instance.
+func NewDeviceAuthorization() *DeviceAuthorization {
+	return &DeviceAuthorization{
+		client: auth.NewSSOClient(deviceAuthorizationTimeout),
+	}
+}
+
+// StartHandler starts a device authorization flow for the optional `scope` parameter ('openid' by default), and returns the device
+// code along with the user code and the verification URI to give to the user.
+func (d *DeviceAuthorization) StartHandler(ctx *gin.Context) {
+	clientID := configuration.GetRegistrationServiceConfig().Auth().DeviceClientID()
+	if clientID == "" {
+		d.abort(ctx, http.StatusNotFound, invalidRequestError, "the device authorization is not enabled")
+		return
+	}
+	resp := DeviceAuthorizationResponse{}
+	if !d.call(ctx, "/protocol/openid-connect/auth/device", url.Values{
+		"client_id": {clientID},
+		"scope":     {ctx.DefaultPostForm("scope", "openid")},
+	}, &resp) {
+		return
+	}
+	log.Infof(ctx, "started the device authorization flow with the '%s' user code", resp.UserCode)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// TokenHandler returns the SSO token of the user once the device authorization flow of the `device_code` parameter is completed.
+// Until then, the 'authorization_pending' or 'slow_down' errors of SSO are returned, and the CLI is supposed to poll again.
+func (d *DeviceAuthorization) TokenHandler(ctx *gin.Context) {
+	clientID := configuration.GetRegistrationServiceConfig().Auth().DeviceClientID()
+	if clientID == "" {
+		d.abort(ctx, http.StatusNotFound, invalidRequestError, "the device authorization is not enabled")
+		return
+	}
+	deviceCode := ctx.PostForm("device_code")
+	if deviceCode == "" {
+		d.abort(ctx, http.StatusBadRequest, invalidRequestError, "the device code is missing")
+		return
+	}
+	resp := DeviceTokenResponse{}
+	if !d.call(ctx, "/protocol/openid-connect/token", url.Values{
+		"grant_type":  {deviceCodeGrantType},
+		"client_id":   {clientID},
+		"device_code": {deviceCode},
+	}, &resp) {
+		return
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, resp)
+}
+
+// call posts the given form to the given endpoint of the SSO realm of the host of the request, and decodes the successful response
+// into the given value. Otherwise, the request is aborted with the error of SSO, if any, and false is returned.
+func (d *DeviceAuthorization) call(ctx *gin.Context, endpoint string, form url.Values, value any) bool {
+	realm := configuration.GetRegistrationServiceConfig().Auth().RealmByHost(ctx.Request.Host)
+	req, err := http.NewRequestWithContext(ctx.Request.Context(), http.MethodPost, realm.Issuer+endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Error(ctx, err, "unable to create the request to SSO")
+		d.abort(ctx, http.StatusInternalServerError, "server_error", "unable to create the request to SSO")
+		return false
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		log.Error(ctx, err, "unable to call SSO")
+		d.abort(ctx, http.StatusBadGateway, "server_error", "unable to call SSO")
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+			log.Error(ctx, err, "unable to decode the response of SSO")
+			d.abort(ctx, http.StatusBadGateway, "server_error", "unable to decode the response of SSO")
+			return false
+		}
+		return true
+	}
+	// the errors of the flow, such as 'authorization_pending', are forwarded to the CLI as is
+	ssoErr := TokenExchangeError{}
+	if resp.StatusCode < http.StatusInternalServerError && json.NewDecoder(resp.Body).Decode(&ssoErr) == nil && ssoErr.Error != "" {
+		d.abort(ctx, resp.StatusCode, ssoErr.Error, ssoErr.ErrorDescription)
+		return false
+	}
+	log.Error(ctx, fmt.Errorf("SSO responded with status %d", resp.StatusCode), "unexpected response of SSO")
+	d.abort(ctx, http.StatusBadGateway, "server_error", "unexpected response of SSO")
+	return false
+}
+
+// abort aborts the request with the given status code and the given error in the format of RFC 6749, which the OAuth clients expect
+func (d *DeviceAuthorization) abort(ctx *gin.Context, code int, err, description string) {
+	ctx.AbortWithStatusJSON(code, TokenExchangeError{
+		Error:            err,
+		ErrorDescription: description,
+	})
+}
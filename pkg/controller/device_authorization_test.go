@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestDeviceAuthorizationSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunDeviceAuthorizationSuite(t *testing.T) {
+	suite.Run(t, &TestDeviceAuthorizationSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestDeviceAuthorizationSuite) TestDeviceAuthorization() {
+	// given
+	var tokenStatus int
+	var tokenBody string
+	sso := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), http.MethodPost, r.Method)
+		assert.NoError(s.T(), r.ParseForm())
+		assert.Equal(s.T(), "sandbox-cli", r.PostForm.Get("client_id"))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/auth/realms/sandbox-dev/protocol/openid-connect/auth/device":
+			assert.Equal(s.T(), "openid offline_access", r.PostForm.Get("scope"))
+			_, _ = w.Write([]byte(`{"device_code":"dc","user_code":"ABCD-EFGH","verification_uri":"https://sso/device","verification_uri_complete":"https://sso/device?user_code=ABCD-EFGH","expires_in":600,"interval":5}`))
+		case "/auth/realms/sandbox-dev/protocol/openid-connect/token":
+			assert.Equal(s.T(), deviceCodeGrantType, r.PostForm.Get("grant_type"))
+			assert.Equal(s.T(), "dc", r.PostForm.Get("device_code"))
+			w.WriteHeader(tokenStatus)
+			_, _ = w.Write([]byte(tokenBody))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer sso.Close()
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Auth().SSOBaseURL(sso.URL))
+	defer s.DefaultConfig()
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_DEVICE_CLIENT_ID", "sandbox-cli")
+	ctrl := NewDeviceAuthorization()
+	post := func(handler gin.HandlerFunc, params url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/device", strings.NewReader(params.Encode()))
+		ctx.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler(ctx)
+		return rr
+	}
+	assertError := func(rr *httptest.ResponseRecorder, code int, expected string) {
+		assert.Equal(s.T(), code, rr.Code)
+		oauthErr := TokenExchangeError{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &oauthErr))
+		assert.Equal(s.T(), expected, oauthErr.Error)
+	}
+
+	s.Run("start", func() {
+		// when
+		rr := post(ctrl.StartHandler, url.Values{"scope": {"openid offline_access"}})
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code)
+		assert.Equal(s.T(), "no-store", rr.Header().Get("Cache-Control"))
+		resp := DeviceAuthorizationResponse{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.Equal(s.T(), DeviceAuthorizationResponse{
+			DeviceCode:              "dc",
+			UserCode:                "ABCD-EFGH",
+			VerificationURI:         "https://sso/device",
+			VerificationURIComplete: "https://sso/device?user_code=ABCD-EFGH",
+			ExpiresIn:               600,
+			Interval:                5,
+		}, resp)
+	})
+
+	s.Run("token", func() {
+		s.Run("completed", func() {
+			// given
+			tokenStatus, tokenBody = http.StatusOK, `{"access_token":"at","token_type":"Bearer","expires_in":300,"refresh_token":"rt"}`
+
+			// when
+			rr := post(ctrl.TokenHandler, url.Values{"device_code": {"dc"}})
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rr.Code)
+			assert.Equal(s.T(), "no-store", rr.Header().Get("Cache-Control"))
+			resp := DeviceTokenResponse{}
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &resp))
+			assert.Equal(s.T(), DeviceTokenResponse{AccessToken: "at", TokenType: "Bearer", ExpiresIn: 300, RefreshToken: "rt"}, resp)
+		})
+
+		s.Run("pending", func() {
+			// given
+			tokenStatus, tokenBody = http.StatusBadRequest, `{"error":"authorization_pending","error_description":"The authorization request is still pending"}`
+
+			// when
+			rr := post(ctrl.TokenHandler, url.Values{"device_code": {"dc"}})
+
+			// then
+			assertError(rr, http.StatusBadRequest, "authorization_pending")
+		})
+
+		s.Run("SSO error", func() {
+			// given
+			tokenStatus, tokenBody = http.StatusServiceUnavailable, `unavailable`
+
+			// when
+			rr := post(ctrl.TokenHandler, url.Values{"device_code": {"dc"}})
+
+			// then
+			assertError(rr, http.StatusBadGateway, "server_error")
+		})
+
+		s.Run("missing device code", func() {
+			// when
+			rr := post(ctrl.TokenHandler, url.Values{})
+
+			// then
+			assertError(rr, http.StatusBadRequest, invalidRequestError)
+		})
+	})
+
+	s.Run("disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_AUTH_DEVICE_CLIENT_ID", "")
+
+		for name, handler := range map[string]gin.HandlerFunc{"start": ctrl.StartHandler, "token": ctrl.TokenHandler} {
+			s.Run(name, func() {
+				// when
+				rr := post(handler, url.Values{"device_code": {"dc"}})
+
+				// then
+				assertError(rr, http.StatusNotFound, invalidRequestError)
+			})
+		}
+	})
+}
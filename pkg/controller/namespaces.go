@@ -2,8 +2,10 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	customCtx "github.com/codeready-toolchain/registration-service/pkg/context"
 	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
@@ -38,12 +40,13 @@ func (ctrl *namespacesCtrl) ResetNamespaces(ctx *gin.Context) {
 	if err != nil {
 		log.Errorf(ctx, err, `unable to reset the namespaces for user "%s"`, ctx.GetString(customCtx.UsernameKey))
 
+		contact := configuration.GetRegistrationServiceConfig().Support().Channel().Contact()
 		if errors.Is(err, namespaces.ErrUserSignUpNotFoundOrDeactivated) {
-			crterrors.AbortWithError(ctx, http.StatusNotFound, ErrNamespaceReset, "The user is either not found or deactivated. Please contact the Developer Sandbox team at devsandbox@redhat.com for assistance")
+			crterrors.AbortWithError(ctx, http.StatusNotFound, ErrNamespaceReset, fmt.Sprintf("The user is either not found or deactivated. Please contact %s for assistance", contact))
 		} else if errors.As(err, &namespaces.ErrUserHasNoProvisionedNamespaces{}) {
-			crterrors.AbortWithError(ctx, http.StatusBadRequest, ErrNamespaceReset, "No namespaces provisioned, unable to perform reset. Please try again in a while and if the issue persists, please contact the Developer Sandbox team at devsandbox@redhat.com for assistance")
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, ErrNamespaceReset, fmt.Sprintf("No namespaces provisioned, unable to perform reset. Please try again in a while and if the issue persists, please contact %s for assistance", contact))
 		} else {
-			crterrors.AbortWithError(ctx, http.StatusInternalServerError, ErrNamespaceReset, "Unable to reset your namespaces. Please try again in a while and if the issue persists, please contact the Developer Sandbox team at devsandbox@redhat.com for assistance")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, ErrNamespaceReset, fmt.Sprintf("Unable to reset your namespaces. Please try again in a while and if the issue persists, please contact %s for assistance", contact))
 		}
 		return
 	}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+// PersonalAccessTokenRequest is the body of the requests to issue a personal access token
+type PersonalAccessTokenRequest struct {
+	// Name is the name of the token, eg. 'tekton-ci'
+	Name string `json:"name"`
+	// Scopes are the scopes of the token, eg. ['read', 'workspace:smith-dev'] (see auth.ReadScope, auth.WriteScope and auth.WorkspaceScopePrefix)
+	Scopes []string `json:"scopes"`
+	// ExpiresAt is the time the token expires. The token expires after the DefaultLifetime of the personal access tokens if not set.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// PersonalAccessTokens implements the endpoint issuing the personal access tokens, which the proxy accepts in place of the SSO tokens
+type PersonalAccessTokens struct {
+}
+
+// NewPersonalAccessTokens returns a new PersonalAccessTokens instance.
+func NewPersonalAccessTokens() *PersonalAccessTokens {
+	return &PersonalAccessTokens{}
+}
+
+// PostHandler issues a personal access token for the user of the SSO token of the request, from the PersonalAccessTokenRequest of the body
+func (t *PersonalAccessTokens) PostHandler(ctx *gin.Context) {
+	cfg := configuration.GetRegistrationServiceConfig().PersonalAccessTokens()
+	if !cfg.Enabled() {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, errors.New("personal access tokens not enabled"), "the personal access tokens are not enabled")
+		return
+	}
+	claims, ok := ctx.Get(context.JWTClaimsKey)
+	user, isClaims := claims.(*auth.TokenClaims)
+	if !ok || !isClaims {
+		crterrors.AbortWithError(ctx, http.StatusUnauthorized, errors.New("no claims found"), "the token of the user is missing")
+		return
+	}
+	var req PersonalAccessTokenRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		log.Error(ctx, err, "invalid personal access token request")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	expiresAt := time.Now().Add(cfg.DefaultLifetime())
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	token, err := auth.IssuePersonalAccessToken(user, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		log.Error(ctx, err, "unable to issue the personal access token")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "invalid personal access token request")
+		return
+	}
+	log.Infof(ctx, "issued the '%s' personal access token '%s' with the '%s' scopes, until %s", token.ID, token.Name, strings.Join(token.Scopes, ","), token.ExpiresAt.Format(time.RFC3339))
+	ctx.JSON(http.StatusCreated, token)
+}
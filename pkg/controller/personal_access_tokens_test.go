@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestPersonalAccessTokensSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunPersonalAccessTokensSuite(t *testing.T) {
+	suite.Run(t, &TestPersonalAccessTokensSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestPersonalAccessTokensSuite) TestPersonalAccessTokensPostHandler() {
+	// given
+	ctrl := NewPersonalAccessTokens()
+	handler := gin.HandlerFunc(ctrl.PostHandler)
+	user := &auth.TokenClaims{
+		PreferredUsername: "smith",
+		Email:             "smith@redhat.com",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: "f8c6ad6e",
+		},
+	}
+	newContext := func(claims *auth.TokenClaims, body string) (*gin.Context, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/tokens", strings.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		if claims != nil {
+			ctx.Set(context.JWTClaimsKey, claims)
+		}
+		return ctx, rr
+	}
+
+	s.Run("not enabled", func() {
+		// given
+		ctx, rr := newContext(user, `{"name":"tekton-ci","scopes":["read"]}`)
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+
+	s.SetRegistrationServiceSecret(map[string]string{configuration.PersonalAccessTokensSigningKeyKey: "s3cr3t"})
+
+	s.Run("issued", func() {
+		// given
+		ctx, rr := newContext(user, `{"name":"tekton-ci","scopes":["read","workspace:smith-dev"]}`)
+
+		// when
+		handler(ctx)
+
+		// then
+		require.Equal(s.T(), http.StatusCreated, rr.Code)
+		token := auth.PersonalAccessToken{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &token))
+		assert.NotEmpty(s.T(), token.ID)
+		assert.Equal(s.T(), "tekton-ci", token.Name)
+		assert.Equal(s.T(), []string{"read", "workspace:smith-dev"}, token.Scopes)
+		assert.True(s.T(), auth.IsPersonalAccessToken(token.Token))
+		tokenParser, err := auth.NewTokenParser(&auth.KeyManager{})
+		require.NoError(s.T(), err)
+		claims, err := tokenParser.FromPersonalAccessToken(token.Token)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "smith", claims.PreferredUsername)
+	})
+
+	s.Run("no claims", func() {
+		// given
+		ctx, rr := newContext(nil, `{"name":"tekton-ci","scopes":["read"]}`)
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusUnauthorized, rr.Code)
+	})
+
+	s.Run("invalid body", func() {
+		// given
+		ctx, rr := newContext(user, `{"name":`)
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+
+	s.Run("invalid scopes", func() {
+		// given
+		ctx, rr := newContext(user, `{"name":"tekton-ci","scopes":["admin"]}`)
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+}
@@ -2,25 +2,55 @@ package controller
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
 
 	"github.com/gin-gonic/gin"
 	"github.com/nyaruka/phonenumbers"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
+const (
+	// IdempotencyKeyHeader is the header of the signup requests identifying the request, so that the retries of the clients return
+	// the result of the original request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set in the responses to the repeats of a signup request with the same IdempotencyKeyHeader
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLength is the maximum length of the IdempotencyKeyHeader
+	maxIdempotencyKeyLength = 255
+)
+
 // Signup implements the signup endpoint, which is invoked for new user registrations.
 type Signup struct {
 	app application.Application
 }
 
+// SignupRequest is the optional body of the signup requests
+type SignupRequest struct {
+	// ActivationCode is the activation code of a SocialEvent, so that the attendees of the event are approved (or only need to
+	// complete the verification required by the event) without having to verify the activation code in a separate request
+	ActivationCode string `json:"activationCode"`
+	// TermsOfServiceVersion is the version of the terms of service accepted by the user, which must be the current one
+	TermsOfServiceVersion string `json:"termsOfServiceVersion"`
+}
+
+// ProfileUpdate is the optional body of the requests updating the profile of the user: the given fields override the claims of
+// the token of the user
+type ProfileUpdate struct {
+	GivenName  *string `json:"givenName"`
+	FamilyName *string `json:"familyName"`
+	Company    *string `json:"company"`
+}
+
 type Phone struct {
 	CountryCode string `form:"country_code" json:"country_code" binding:"required"`
 	PhoneNumber string `form:"phone_number" json:"phone_number" binding:"required"`
@@ -33,28 +63,155 @@ func NewSignup(app application.Application) *Signup {
 	}
 }
 
-// PostHandler creates a Signup resource
+// PostHandler creates a Signup resource. The repeats of a request with the same Idempotency-Key header return the result of the
+// original request, with the Idempotent-Replayed header, instead of a conflict. The optional body of the request (see SignupRequest)
+// may carry the activation code of a SocialEvent and the version of the terms of service accepted by the user.
+// When a valid captcha token is required to sign up (see the CaptchaRequiredForSignup setting), the requests without a token, or
+// with an invalid one, are rejected with a Forbidden error before the UserSignup is created or reactivated.
 func (s *Signup) PostHandler(ctx *gin.Context) {
+	if key := ctx.GetHeader(IdempotencyKeyHeader); key != "" {
+		if len(key) > maxIdempotencyKeyLength {
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, fmt.Errorf("the idempotency key is longer than %d characters", maxIdempotencyKeyLength), "invalid Idempotency-Key header")
+			return
+		}
+		ctx.Set(context.IdempotencyKey, key)
+	}
+	if ctx.Request.ContentLength != 0 {
+		request := SignupRequest{}
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+			return
+		}
+		if request.ActivationCode != "" {
+			ctx.Set(context.SocialEvent, request.ActivationCode)
+		}
+		if request.TermsOfServiceVersion != "" {
+			ctx.Set(context.TermsOfServiceVersionKey, request.TermsOfServiceVersion)
+		}
+	}
 	userSignup, err := s.app.SignupService().Signup(ctx)
 	e := &apierrors.StatusError{}
 	if errors.As(err, &e) {
 		crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error creating UserSignup resource")
 		return
 	}
+	crtErr := &crterrors.Error{}
+	if errors.As(err, &crtErr) {
+		crterrors.AbortWithError(ctx, int(crtErr.Code), err, "error creating UserSignup resource")
+		return
+	}
 	if err != nil {
 		log.Error(ctx, err, "error creating UserSignup resource")
 		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error creating UserSignup resource")
 		return
 	}
-	if _, exists := userSignup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; !exists {
+	switch _, exists := userSignup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; {
+	case ctx.GetBool(context.IdempotentReplayKey):
+		ctx.Header(IdempotentReplayedHeader, "true")
+	case !exists:
 		log.Infof(ctx, "UserSignup created: %s", userSignup.Name)
-	} else {
+	default:
 		log.Infof(ctx, "UserSignup reactivated: %s", userSignup.Name)
 	}
 	ctx.Status(http.StatusAccepted)
 	ctx.Writer.WriteHeaderNow()
 }
 
+// DeleteHandler deactivates the Signup resource of the user, so that they can close their account. As a confirmation, the
+// `confirm` query parameter must be set with the username of the user.
+func (s *Signup) DeleteHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	if ctx.Query("confirm") != username {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the 'confirm' query parameter must be set with the username of the account"),
+			"deactivation not confirmed")
+		return
+	}
+	err := s.app.SignupService().Deactivate(ctx, username)
+	e := &apierrors.StatusError{}
+	if errors.As(err, &e) {
+		crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error deactivating UserSignup resource")
+		return
+	}
+	if err != nil {
+		log.Error(ctx, err, "error deactivating UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error deactivating UserSignup resource")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// AnonymizeHandler erases the personal data of the deactivated Signup resource of the user (see signup.Anonymize), so that they can
+// exercise their right to erasure once they closed their account. As a confirmation, the `confirm` query parameter must be set with
+// the username of the user.
+func (s *Signup) AnonymizeHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	if ctx.Query("confirm") != username {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the 'confirm' query parameter must be set with the username of the account"),
+			"anonymization not confirmed")
+		return
+	}
+	err := s.app.SignupService().Anonymize(ctx, username)
+	e := &apierrors.StatusError{}
+	if errors.As(err, &e) {
+		crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error anonymizing UserSignup resource")
+		return
+	}
+	crtErr := &crterrors.Error{}
+	if errors.As(err, &crtErr) {
+		crterrors.AbortWithError(ctx, int(crtErr.Code), err, "error anonymizing UserSignup resource")
+		return
+	}
+	if err != nil {
+		log.Error(ctx, err, "error anonymizing UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error anonymizing UserSignup resource")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// PatchHandler updates the profile (given name, family name and company) of the Signup resource of the user with the claims of their
+// token, so that it is kept in sync with the profile of the user in the Identity Provider. The claims can be overridden by the
+// fields of the optional body of the request (see ProfileUpdate).
+func (s *Signup) PatchHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	profile := signup.Profile{
+		GivenName:  ctx.GetString(context.GivenNameKey),
+		FamilyName: ctx.GetString(context.FamilyNameKey),
+		Company:    ctx.GetString(context.CompanyKey),
+	}
+	if ctx.Request.ContentLength != 0 {
+		update := ProfileUpdate{}
+		if err := ctx.ShouldBindJSON(&update); err != nil {
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+			return
+		}
+		if update.GivenName != nil {
+			profile.GivenName = *update.GivenName
+		}
+		if update.FamilyName != nil {
+			profile.FamilyName = *update.FamilyName
+		}
+		if update.Company != nil {
+			profile.Company = *update.Company
+		}
+	}
+	err := s.app.SignupService().UpdateProfile(ctx, username, profile)
+	e := &apierrors.StatusError{}
+	if errors.As(err, &e) {
+		crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error updating UserSignup resource")
+		return
+	}
+	if err != nil {
+		log.Error(ctx, err, "error updating UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error updating UserSignup resource")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
 // InitVerificationHandler starts the phone verification process for a user.  It extracts the user's identifying
 // information from their Access Token (presented in the Authorization HTTP header) to determine the user, and then
 // invokes the Verification service with an E.164 formatted phone number value derived from the country code and phone number
@@ -104,6 +261,57 @@ func (s *Signup) InitVerificationHandler(ctx *gin.Context) {
 	ctx.Writer.WriteHeaderNow()
 }
 
+// InitEmailVerificationHandler sends a one-time verification link to the email address of the user, as an alternative to the
+// phone verification
+func (s *Signup) InitEmailVerificationHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	err := s.app.VerificationService().InitEmailVerification(ctx, username)
+	if err != nil {
+		log.Errorf(ctx, err, "Verification link for %s could not be sent", username)
+		e := &crterrors.Error{}
+		switch {
+		case errors.As(err, &e):
+			crterrors.AbortWithError(ctx, int(e.Code), err, e.Message)
+		default:
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error while initiating verification")
+		}
+		return
+	}
+
+	log.Infof(ctx, "email verification has been sent for username %s", username)
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
+
+// VerifyEmailLinkHandler validates the verification link sent by email, whose token is in the `token` query parameter, and
+// redirects the user to the configured page once verified. This endpoint is not secured since the link is opened from the email,
+// and the token of the link identifies the user.
+func (s *Signup) VerifyEmailLinkHandler(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if token == "" {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the 'token' query parameter is required"), "invalid verification link")
+		return
+	}
+	err := s.app.VerificationService().VerifyEmailLink(ctx, token)
+	if err != nil {
+		log.Error(ctx, err, "error validating verification link")
+		e := &crterrors.Error{}
+		switch {
+		case errors.As(err, &e):
+			crterrors.AbortWithError(ctx, int(e.Code), err, "error while verifying verification link")
+		default:
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "unexpected error while verifying verification link")
+		}
+		return
+	}
+	if redirectURL := configuration.GetRegistrationServiceConfig().EmailVerification().RedirectURL(); redirectURL != "" {
+		ctx.Redirect(http.StatusSeeOther, redirectURL)
+		return
+	}
+	ctx.Status(http.StatusOK)
+	ctx.Writer.WriteHeaderNow()
+}
+
 // GetHandler returns the Signup resource
 func (s *Signup) GetHandler(ctx *gin.Context) {
 
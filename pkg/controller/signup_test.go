@@ -22,6 +22,7 @@ import (
 	"github.com/codeready-toolchain/registration-service/test"
 	"github.com/codeready-toolchain/registration-service/test/fake"
 	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
 	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
@@ -89,6 +90,103 @@ func (s *TestSignupSuite) TestSignupPostHandler() {
 		assert.Equal(s.T(), expectedUserID+"@test.com", userSignup.Spec.IdentityClaims.Email)
 	})
 
+	s.Run("signup repeated with the same idempotency key", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		handler := gin.HandlerFunc(controller.NewSignup(application).PostHandler)
+		post := func(key string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rr)
+			req, err := http.NewRequest(http.MethodPost, "/api/v1/signup", nil)
+			require.NoError(s.T(), err)
+			req.Header.Set(controller.IdempotencyKeyHeader, key)
+			ctx.Request = req
+			ctx.Set(context.SubKey, "1234")
+			ctx.Set(context.UsernameKey, "bill@kubesaw")
+			ctx.Set(context.EmailKey, "bill@test.com")
+			handler(ctx)
+			return rr
+		}
+		rr := post("a1b2c3")
+		require.Equal(s.T(), http.StatusAccepted, rr.Code)
+		assert.Empty(s.T(), rr.Header().Get(controller.IdempotentReplayedHeader))
+
+		// when
+		rr = post("a1b2c3")
+
+		// then
+		require.Equal(s.T(), http.StatusAccepted, rr.Code)
+		assert.Equal(s.T(), "true", rr.Header().Get(controller.IdempotentReplayedHeader))
+
+		s.Run("another key", func() {
+			// when
+			rr := post("d4e5f6")
+
+			// then
+			assert.Equal(s.T(), http.StatusConflict, rr.Code)
+		})
+
+		s.Run("key too long", func() {
+			// when
+			rr := post(strings.Repeat("a", 256))
+
+			// then
+			test.AssertError(s.T(), rr, http.StatusBadRequest, "the idempotency key is longer than 255 characters", "invalid Idempotency-Key header")
+		})
+	})
+
+	s.Run("signup with an activation code", func() {
+		// given
+		event := testsocialevent.NewSocialEvent(commontest.HostOperatorNs, "event")
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), event)
+		handler := gin.HandlerFunc(controller.NewSignup(application).PostHandler)
+		post := func(username, body string) *httptest.ResponseRecorder {
+			rr := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rr)
+			req, err := http.NewRequest(http.MethodPost, "/api/v1/signup", strings.NewReader(body))
+			require.NoError(s.T(), err)
+			ctx.Request = req
+			ctx.Set(context.SubKey, username)
+			ctx.Set(context.UsernameKey, username)
+			ctx.Set(context.EmailKey, username+"@test.com")
+			handler(ctx)
+			return rr
+		}
+
+		s.Run("approved", func() {
+			// when
+			rr := post("jane", `{"activationCode":"event"}`)
+
+			// then
+			require.Equal(s.T(), http.StatusAccepted, rr.Code)
+			userSignup := &crtapi.UserSignup{}
+			require.NoError(s.T(), fakeClient.Get(gocontext.TODO(),
+				commontest.NamespacedName(commontest.HostOperatorNs, usersignup.EncodeUserIdentifier("jane")), userSignup))
+			assert.False(s.T(), states.VerificationRequired(userSignup))
+			assert.True(s.T(), states.ApprovedManually(userSignup))
+			assert.Equal(s.T(), event.Name, userSignup.Labels[crtapi.SocialEventUserSignupLabelKey])
+		})
+
+		s.Run("invalid activation code", func() {
+			// when
+			rr := post("john", `{"activationCode":"unknown"}`)
+
+			// then
+			test.AssertError(s.T(), rr, http.StatusForbidden, "invalid code: the provided code is invalid", "error creating UserSignup resource")
+			err := fakeClient.Get(gocontext.TODO(),
+				commontest.NamespacedName(commontest.HostOperatorNs, usersignup.EncodeUserIdentifier("john")), &crtapi.UserSignup{})
+			assert.True(s.T(), apierrors.IsNotFound(err))
+		})
+
+		s.Run("invalid body", func() {
+			// when
+			rr := post("john", `{"activationCode":`)
+
+			// then
+			assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+		})
+	})
+
 	s.Run("signup error", func() {
 		// given
 		fakeClient, application := testutil.PrepareInClusterApp(s.T())
@@ -130,6 +228,231 @@ func (s *TestSignupSuite) TestSignupPostHandler() {
 	})
 }
 
+func (s *TestSignupSuite) TestSignupDeleteHandler() {
+	// given
+	userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("bill@kubesaw"))
+	newContext := func(confirm string) (*gin.Context, *httptest.ResponseRecorder) {
+		req, err := http.NewRequest(http.MethodDelete, "/api/v1/signup?confirm="+confirm, nil)
+		require.NoError(s.T(), err)
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+		ctx.Set(context.UsernameKey, "bill@kubesaw")
+		return ctx, rr
+	}
+
+	s.Run("signup deactivated", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).DeleteHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code)
+		deactivated := &crtapi.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(ctx, client.ObjectKeyFromObject(userSignup), deactivated))
+		assert.True(s.T(), states.Deactivated(deactivated))
+	})
+
+	s.Run("deactivation not confirmed", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).DeleteHandler)
+		ctx, rr := newContext("someone-else")
+
+		// when
+		handler(ctx)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "the 'confirm' query parameter must be set with the username of the account", "deactivation not confirmed")
+		unchanged := &crtapi.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(ctx, client.ObjectKeyFromObject(userSignup), unchanged))
+		assert.False(s.T(), states.Deactivated(unchanged))
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		handler := gin.HandlerFunc(controller.NewSignup(application).DeleteHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+
+	s.Run("update error", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		fakeClient.MockUpdate = func(_ gocontext.Context, _ client.Object, _ ...client.UpdateOption) error {
+			return errors.New("blah")
+		}
+		handler := gin.HandlerFunc(controller.NewSignup(application).DeleteHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusInternalServerError, "blah", "error deactivating UserSignup resource")
+	})
+}
+
+func (s *TestSignupSuite) TestSignupAnonymizeHandler() {
+	// given
+	userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("bill@kubesaw"), testusersignup.Deactivated())
+	newContext := func(confirm string) (*gin.Context, *httptest.ResponseRecorder) {
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/signup/anonymize?confirm="+confirm, nil)
+		require.NoError(s.T(), err)
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+		ctx.Set(context.UsernameKey, "bill@kubesaw")
+		return ctx, rr
+	}
+
+	s.Run("signup anonymized", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).AnonymizeHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code)
+		anonymized := &crtapi.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(ctx, client.ObjectKeyFromObject(userSignup), anonymized))
+		assert.Empty(s.T(), anonymized.Spec.IdentityClaims.Email)
+		assert.Empty(s.T(), anonymized.Spec.IdentityClaims.PreferredUsername)
+		assert.NotEmpty(s.T(), anonymized.Annotations[signup.AnonymizedAtAnnotationKey])
+	})
+
+	s.Run("anonymization not confirmed", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).AnonymizeHandler)
+		ctx, rr := newContext("someone-else")
+
+		// when
+		handler(ctx)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "the 'confirm' query parameter must be set with the username of the account", "anonymization not confirmed")
+	})
+
+	s.Run("signup not deactivated", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), testusersignup.NewUserSignup(testusersignup.WithEncodedName("bill@kubesaw")))
+		handler := gin.HandlerFunc(controller.NewSignup(application).AnonymizeHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusConflict, rr.Code)
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		handler := gin.HandlerFunc(controller.NewSignup(application).AnonymizeHandler)
+		ctx, rr := newContext("bill@kubesaw")
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+}
+
+func (s *TestSignupSuite) TestSignupPatchHandler() {
+	// given
+	userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("bill@kubesaw"))
+	newContext := func(body string) (*gin.Context, *httptest.ResponseRecorder) {
+		req, err := http.NewRequest(http.MethodPatch, "/api/v1/signup", strings.NewReader(body))
+		require.NoError(s.T(), err)
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = req
+		ctx.Set(context.UsernameKey, "bill@kubesaw")
+		ctx.Set(context.GivenNameKey, "Bill")
+		ctx.Set(context.FamilyNameKey, "Smith")
+		ctx.Set(context.CompanyKey, "Red Hat")
+		return ctx, rr
+	}
+
+	s.Run("profile updated from the token", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).PatchHandler)
+		ctx, rr := newContext("")
+
+		// when
+		handler(ctx)
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code)
+		updated := &crtapi.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(ctx, client.ObjectKeyFromObject(userSignup), updated))
+		assert.Equal(s.T(), "Bill", updated.Spec.IdentityClaims.GivenName)
+		assert.Equal(s.T(), "Smith", updated.Spec.IdentityClaims.FamilyName)
+		assert.Equal(s.T(), "Red Hat", updated.Spec.IdentityClaims.Company)
+	})
+
+	s.Run("profile updated from the body", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).PatchHandler)
+		ctx, rr := newContext(`{"givenName":"William","company":""}`)
+
+		// when
+		handler(ctx)
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code)
+		updated := &crtapi.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(ctx, client.ObjectKeyFromObject(userSignup), updated))
+		assert.Equal(s.T(), "William", updated.Spec.IdentityClaims.GivenName)
+		assert.Equal(s.T(), "Smith", updated.Spec.IdentityClaims.FamilyName)
+		assert.Empty(s.T(), updated.Spec.IdentityClaims.Company)
+	})
+
+	s.Run("invalid body", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup.DeepCopy())
+		handler := gin.HandlerFunc(controller.NewSignup(application).PatchHandler)
+		ctx, rr := newContext(`{"givenName":`)
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+
+	s.Run("signup not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		handler := gin.HandlerFunc(controller.NewSignup(application).PatchHandler)
+		ctx, rr := newContext("")
+
+		// when
+		handler(ctx)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+}
+
 func (s *TestSignupSuite) TestSignupGetHandler() {
 	// given
 	// Create a request to pass to our handler. We don't have any query parameters for now, so we'll
@@ -145,6 +468,9 @@ func (s *TestSignupSuite) TestSignupGetHandler() {
 		testusersignup.WithHomeSpace("ted"),
 	)
 
+	approved, _ := condition.FindConditionByType(userSignup.Status.Conditions, crtapi.UserSignupApproved)
+	approvedAt := approved.LastTransitionTime.UTC().Format(time.RFC3339)
+
 	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
 
 	// Create Signup controller instance.
@@ -171,6 +497,11 @@ func (s *TestSignupSuite) TestSignupGetHandler() {
 			AccountID:     "5647382910",
 			AccountNumber: "4242",
 			Email:         "foo@redhat.com",
+			Phases: []signup.Phase{
+				{Name: signup.PhaseApproval, Status: signup.PhaseStatusDone, Reason: crtapi.UserSignupApprovedAutomaticallyReason, LastTransitionTime: approvedAt},
+				{Name: signup.PhaseSpaceProvisioning, Status: signup.PhaseStatusInProgress, Reason: "Provisioning"},
+				{Name: signup.PhaseNamespaces, Status: signup.PhaseStatusPending},
+			},
 		}
 
 		// when
@@ -799,3 +1130,55 @@ func initActivationCodeVerification(t *testing.T, handler gin.HandlerFunc, usern
 	handler(ctx)
 	return rr
 }
+
+func (s *TestSignupSuite) TestEmailVerificationHandlers() {
+	// given
+	userSignup := testusersignup.NewUserSignup(testusersignup.VerificationRequiredAgo(time.Second))
+	_, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+	ctrl := controller.NewSignup(application)
+
+	s.Run("init disabled", func() {
+		// given
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodPut, "/api/v1/signup/verification/email", nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.Set(context.UsernameKey, userSignup.Spec.IdentityClaims.PreferredUsername)
+
+		// when
+		ctrl.InitEmailVerificationHandler(ctx)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "forbidden request: email verification is not enabled", "forbidden request")
+	})
+
+	verify := func(query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/signup/verification/email-link"+query, nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctrl.VerifyEmailLinkHandler(ctx)
+		return rr
+	}
+
+	s.Run("missing token", func() {
+		// when
+		rr := verify("")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "the 'token' query parameter is required", "invalid verification link")
+	})
+
+	s.Run("invalid token", func() {
+		// given
+		s.SetRegistrationServiceSecret(map[string]string{configuration.EmailVerificationSigningKeyKey: "s1gn1ng"})
+
+		// when
+		rr := verify("?token=abc.def")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "invalid link: the verification link is invalid", "error while verifying verification link")
+	})
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// TermsOfServiceResponse is the current version of the terms of service
+type TermsOfServiceResponse struct {
+	// Version is the version of the current terms of service
+	Version string `json:"version"`
+	// URL is the URL of the current terms of service
+	URL string `json:"url,omitempty"`
+	// Required is true if the users must accept the current terms of service to sign up
+	Required bool `json:"required"`
+}
+
+// TermsOfServiceAcceptance is the body of the requests recording the acceptance of the terms of service by the user
+type TermsOfServiceAcceptance struct {
+	Version string `json:"version" binding:"required"`
+}
+
+// TermsOfService implements the terms of service endpoints, which return the current version of the terms of service and record
+// its acceptance by the users on their UserSignup, so that it is not only tracked by the web UIs
+type TermsOfService struct {
+	app application.Application
+}
+
+// NewTermsOfService returns a new TermsOfService instance.
+func NewTermsOfService(app application.Application) *TermsOfService {
+	return &TermsOfService{
+		app: app,
+	}
+}
+
+// GetHandler returns the current version of the terms of service, or a 404 if the acceptance of the terms of service is not
+// tracked (see the TermsOfService settings)
+func (t *TermsOfService) GetHandler(ctx *gin.Context) {
+	cfg := configuration.GetRegistrationServiceConfig().TermsOfService()
+	if cfg.Version() == "" {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, errors.New("the terms of service are not configured"), "terms of service not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, TermsOfServiceResponse{
+		Version:  cfg.Version(),
+		URL:      cfg.URL(),
+		Required: cfg.Required(),
+	})
+}
+
+// AcceptHandler records the acceptance of the version of the terms of service of the body of the request (see
+// TermsOfServiceAcceptance) on the Signup resource of the user
+func (t *TermsOfService) AcceptHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	acceptance := TermsOfServiceAcceptance{}
+	if err := ctx.ShouldBindJSON(&acceptance); err != nil {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	err := t.app.SignupService().AcceptTermsOfService(ctx, username, acceptance.Version)
+	e := &apierrors.StatusError{}
+	if errors.As(err, &e) {
+		crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error updating UserSignup resource")
+		return
+	}
+	crtErr := &crterrors.Error{}
+	if errors.As(err, &crtErr) {
+		crterrors.AbortWithError(ctx, int(crtErr.Code), err, "error updating UserSignup resource")
+		return
+	}
+	if err != nil {
+		log.Error(ctx, err, "error updating UserSignup resource")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error updating UserSignup resource")
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+	ctx.Writer.WriteHeaderNow()
+}
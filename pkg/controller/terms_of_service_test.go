@@ -0,0 +1,127 @@
+package controller_test
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/signup/service"
+	"github.com/codeready-toolchain/registration-service/test"
+	testutil "github.com/codeready-toolchain/registration-service/test/util"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestTermsOfServiceSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunTermsOfServiceSuite(t *testing.T) {
+	suite.Run(t, &TestTermsOfServiceSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestTermsOfServiceSuite) TestGetHandler() {
+	get := func() *httptest.ResponseRecorder {
+		_, application := testutil.PrepareInClusterApp(s.T())
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/terms-of-service", nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		controller.NewTermsOfService(application).GetHandler(ctx)
+		return rr
+	}
+
+	s.Run("not configured", func() {
+		// when
+		rr := get()
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusNotFound, "the terms of service are not configured", "terms of service not found")
+	})
+
+	s.Run("current version", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_VERSION", "2024-01")
+		s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_URL", "https://sandbox.acme.com/terms")
+		s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_REQUIRED", "true")
+
+		// when
+		rr := get()
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		response := controller.TermsOfServiceResponse{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(s.T(), controller.TermsOfServiceResponse{
+			Version:  "2024-01",
+			URL:      "https://sandbox.acme.com/terms",
+			Required: true,
+		}, response)
+	})
+}
+
+func (s *TestTermsOfServiceSuite) TestAcceptHandler() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_VERSION", "2024-01")
+	userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"))
+	fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+	ctrl := controller.NewTermsOfService(application)
+	accept := func(username, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/signup/terms-of-service", strings.NewReader(body))
+		require.NoError(s.T(), err)
+		req.Header.Set("Content-Type", "application/json")
+		ctx.Request = req
+		ctx.Set(context.UsernameKey, username)
+		ctrl.AcceptHandler(ctx)
+		return rr
+	}
+
+	s.Run("accepted", func() {
+		// when
+		rr := accept("jsmith", `{"version":"2024-01"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusNoContent, rr.Code, rr.Body.String())
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		assert.Equal(s.T(), "2024-01", updated.Annotations[service.TermsOfServiceVersionAnnotationKey])
+	})
+
+	s.Run("another version", func() {
+		// when
+		rr := accept("jsmith", `{"version":"2023-06"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "invalid terms of service version: the version '2023-06' is not the current version of the terms of service",
+			"error updating UserSignup resource")
+	})
+
+	s.Run("no version", func() {
+		// when
+		rr := accept("jsmith", `{}`)
+
+		// then
+		require.Equal(s.T(), http.StatusBadRequest, rr.Code, rr.Body.String())
+	})
+
+	s.Run("no signup", func() {
+		// when
+		rr := accept("unknown", `{"version":"2024-01"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusNotFound, rr.Code, rr.Body.String())
+	})
+}
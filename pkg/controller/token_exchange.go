@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+// The parameters and the values of the token exchange defined by RFC 8693
+const (
+	tokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType        = "urn:ietf:params:oauth:token-type:access_token"
+	jwtTokenType           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// The error codes of the token exchange defined by RFC 6749 and RFC 8693
+const (
+	invalidRequestError       = "invalid_request"
+	invalidGrantError         = "invalid_grant"
+	invalidScopeError         = "invalid_scope"
+	invalidTargetError        = "invalid_target"
+	unsupportedGrantTypeError = "unsupported_grant_type"
+)
+
+// TokenExchangeResponse is the response of a successful token exchange (see RFC 8693, section 2.2.1)
+type TokenExchangeResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope"`
+}
+
+// TokenExchangeError is the response of a failed token exchange (see RFC 6749, section 5.2)
+type TokenExchangeError struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+// TokenExchange implements the endpoint exchanging the SSO tokens of the users for short-lived tokens limited to a single
+// workspace (see RFC 8693), so that the users can hand limited credentials to their automation instead of their SSO tokens.
+// The workspace is the `audience` of the request, and the optional `scope` is either 'read' or 'write' (the default).
+type TokenExchange struct {
+	tokenParser *auth.TokenParser
+}
+
+// NewTokenExchange returns a new TokenExchange instance.
+func NewTokenExchange(tokenParser *auth.TokenParser) *TokenExchange {
+	return &TokenExchange{
+		tokenParser: tokenParser,
+	}
+}
+
+// PostHandler exchanges the SSO token of the `subject_token` parameter for a token limited to the workspace of the `audience`
+// parameter. The proxy still checks that the user has access to the workspace when the token is used.
+func (t *TokenExchange) PostHandler(ctx *gin.Context) {
+	if !configuration.GetRegistrationServiceConfig().PersonalAccessTokens().Enabled() {
+		t.abort(ctx, http.StatusNotFound, invalidRequestError, "the token exchange is not enabled")
+		return
+	}
+	if grantType := ctx.PostForm("grant_type"); grantType != tokenExchangeGrantType {
+		t.abort(ctx, http.StatusBadRequest, unsupportedGrantTypeError, "the grant type must be '"+tokenExchangeGrantType+"'")
+		return
+	}
+	if tokenType := ctx.PostForm("subject_token_type"); tokenType != accessTokenType && tokenType != jwtTokenType {
+		t.abort(ctx, http.StatusBadRequest, invalidRequestError, "the subject token type must be '"+accessTokenType+"' or '"+jwtTokenType+"'")
+		return
+	}
+	if tokenType := ctx.PostForm("requested_token_type"); tokenType != "" && tokenType != accessTokenType {
+		t.abort(ctx, http.StatusBadRequest, invalidRequestError, "the requested token type must be '"+accessTokenType+"'")
+		return
+	}
+	subjectToken := ctx.PostForm("subject_token")
+	if subjectToken == "" {
+		t.abort(ctx, http.StatusBadRequest, invalidRequestError, "the subject token is missing")
+		return
+	}
+	workspace := ctx.PostForm("audience")
+	if workspace == "" {
+		t.abort(ctx, http.StatusBadRequest, invalidTargetError, "the workspace must be given as the audience")
+		return
+	}
+	scope := ctx.DefaultPostForm("scope", auth.WriteScope)
+	if scope != auth.ReadScope && scope != auth.WriteScope {
+		t.abort(ctx, http.StatusBadRequest, invalidScopeError, "the scope must be either '"+auth.ReadScope+"' or '"+auth.WriteScope+"'")
+		return
+	}
+	user, err := t.tokenParser.FromString(subjectToken)
+	if err != nil {
+		log.Error(ctx, err, "invalid subject token")
+		t.abort(ctx, http.StatusBadRequest, invalidGrantError, "the subject token is invalid")
+		return
+	}
+	token, err := auth.ExchangeToken(user, workspace, scope)
+	if err != nil {
+		log.Error(ctx, err, "unable to exchange the token")
+		t.abort(ctx, http.StatusBadRequest, invalidRequestError, err.Error())
+		return
+	}
+	log.Infof(ctx, "exchanged the token of '%s' for the '%s' token with the '%s' scope in the '%s' workspace", user.PreferredUsername, token.ID, scope, workspace)
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, TokenExchangeResponse{
+		AccessToken:     token.Token,
+		IssuedTokenType: accessTokenType,
+		TokenType:       "Bearer",
+		ExpiresIn:       int64(time.Until(token.ExpiresAt).Seconds()),
+		Scope:           scope,
+	})
+}
+
+// abort aborts the request with the given status code and the given error in the format of RFC 6749, which the OAuth clients expect
+func (t *TokenExchange) abort(ctx *gin.Context, code int, err, description string) {
+	ctx.AbortWithStatusJSON(code, TokenExchangeError{
+		Error:            err,
+		ErrorDescription: description,
+	})
+}
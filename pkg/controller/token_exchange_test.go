@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/test"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestTokenExchangeSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunTokenExchangeSuite(t *testing.T) {
+	suite.Run(t, &TestTokenExchangeSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestTokenExchangeSuite) TestTokenExchangePostHandler() {
+	// given
+	tokengenerator := authsupport.NewTokenManager()
+	kid := uuid.NewString()
+	_, err := tokengenerator.AddPrivateKey(kid)
+	require.NoError(s.T(), err)
+	s.OverrideApplicationDefault(testconfig.RegistrationService().
+		Environment(configuration.UnitTestsEnvironment).
+		Auth().AuthClientPublicKeysURL(tokengenerator.NewKeyServer().URL))
+	defer s.DefaultConfig()
+	keyManager, err := auth.NewKeyManager()
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParser(keyManager)
+	require.NoError(s.T(), err)
+	identity := authsupport.Identity{
+		ID:       uuid.New(),
+		Username: "smith",
+	}
+	ssoToken, err := tokengenerator.GenerateSignedToken(identity, kid, authsupport.WithEmailClaim("smith@redhat.com"))
+	require.NoError(s.T(), err)
+	handler := gin.HandlerFunc(NewTokenExchange(tokenParser).PostHandler)
+	exchange := func(params url.Values) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/tokens/exchange", strings.NewReader(params.Encode()))
+		ctx.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		handler(ctx)
+		return rr
+	}
+	validParams := func() url.Values {
+		return url.Values{
+			"grant_type":         {"urn:ietf:params:oauth:grant-type:token-exchange"},
+			"subject_token":      {ssoToken},
+			"subject_token_type": {"urn:ietf:params:oauth:token-type:access_token"},
+			"audience":           {"smith-dev"},
+		}
+	}
+	assertError := func(rr *httptest.ResponseRecorder, code int, expected string) {
+		assert.Equal(s.T(), code, rr.Code)
+		tokenErr := TokenExchangeError{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &tokenErr))
+		assert.Equal(s.T(), expected, tokenErr.Error)
+	}
+
+	s.Run("not enabled", func() {
+		// when
+		rr := exchange(validParams())
+
+		// then
+		assertError(rr, http.StatusNotFound, "invalid_request")
+	})
+
+	s.SetRegistrationServiceSecret(map[string]string{configuration.PersonalAccessTokensSigningKeyKey: "s3cr3t"})
+
+	s.Run("exchanged", func() {
+		for name, tc := range map[string]struct {
+			scope          string
+			expectedScopes []string
+		}{
+			"default scope": {
+				expectedScopes: []string{"write", "workspace:smith-dev"},
+			},
+			"read scope": {
+				scope:          "read",
+				expectedScopes: []string{"read", "workspace:smith-dev"},
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				params := validParams()
+				if tc.scope != "" {
+					params.Set("scope", tc.scope)
+				}
+
+				// when
+				rr := exchange(params)
+
+				// then
+				require.Equal(s.T(), http.StatusOK, rr.Code)
+				assert.Equal(s.T(), "no-store", rr.Header().Get("Cache-Control"))
+				resp := TokenExchangeResponse{}
+				require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &resp))
+				assert.Equal(s.T(), "urn:ietf:params:oauth:token-type:access_token", resp.IssuedTokenType)
+				assert.Equal(s.T(), "Bearer", resp.TokenType)
+				assert.InDelta(s.T(), 900, resp.ExpiresIn, 5)
+				assert.Equal(s.T(), tc.expectedScopes[0], resp.Scope)
+				claims, err := tokenParser.FromPersonalAccessToken(resp.AccessToken)
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "smith", claims.PreferredUsername)
+				assert.Equal(s.T(), identity.ID.String(), claims.Subject)
+				assert.Equal(s.T(), tc.expectedScopes, auth.PersonalAccessTokenScopes(claims))
+			})
+		}
+	})
+
+	s.Run("not exchanged", func() {
+		for name, tc := range map[string]struct {
+			param    string
+			value    string
+			code     int
+			expected string
+		}{
+			"unsupported grant type": {
+				param:    "grant_type",
+				value:    "client_credentials",
+				code:     http.StatusBadRequest,
+				expected: "unsupported_grant_type",
+			},
+			"unsupported subject token type": {
+				param:    "subject_token_type",
+				value:    "urn:ietf:params:oauth:token-type:refresh_token",
+				code:     http.StatusBadRequest,
+				expected: "invalid_request",
+			},
+			"unsupported requested token type": {
+				param:    "requested_token_type",
+				value:    "urn:ietf:params:oauth:token-type:id_token",
+				code:     http.StatusBadRequest,
+				expected: "invalid_request",
+			},
+			"no subject token": {
+				param:    "subject_token",
+				code:     http.StatusBadRequest,
+				expected: "invalid_request",
+			},
+			"invalid subject token": {
+				param:    "subject_token",
+				value:    "not-a-token",
+				code:     http.StatusBadRequest,
+				expected: "invalid_grant",
+			},
+			"no workspace": {
+				param:    "audience",
+				code:     http.StatusBadRequest,
+				expected: "invalid_target",
+			},
+			"unknown scope": {
+				param:    "scope",
+				value:    "admin",
+				code:     http.StatusBadRequest,
+				expected: "invalid_scope",
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				params := validParams()
+				params.Set(tc.param, tc.value)
+
+				// when
+				rr := exchange(params)
+
+				// then
+				assertError(rr, tc.code, tc.expected)
+			})
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/application/service"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// UsageResponse is the usage of the resources of the user, ie. of the namespaces of their home workspace
+type UsageResponse struct {
+	// Tier is the tier of the home workspace of the user
+	Tier string `json:"tier"`
+	// ClusterName is the name of the member cluster of the home workspace of the user
+	ClusterName string `json:"clusterName"`
+	// Namespaces are the namespaces of the home workspace of the user
+	Namespaces []string `json:"namespaces"`
+	// Resources are the quotas of the resources of the namespaces, and their consumption, sorted by name
+	Resources []ResourceUsage `json:"resources"`
+}
+
+// ResourceUsage is the quota and the consumption of a resource, summed up over all the ResourceQuotas of the namespaces of the user
+type ResourceUsage struct {
+	// Name is the name of the resource in the ResourceQuotas, eg. 'limits.memory'
+	Name string `json:"name"`
+	// Hard is the quota of the resource
+	Hard string `json:"hard"`
+	// Used is the consumption of the resource
+	Used string `json:"used"`
+	// Percentage is the consumption of the resource relative to its quota, rounded down
+	Percentage int `json:"percentage"`
+}
+
+// Usage implements the usage endpoint, which returns the quotas of the resources of the user and their consumption, as collected
+// from the ResourceQuotas of the namespaces of the user in the member cluster, so that the dashboard doesn't have to query them
+type Usage struct {
+	namespaced.Client
+	signupService  service.SignupService
+	getMembersFunc cluster.GetMemberClustersFunc
+}
+
+// NewUsage returns a new Usage instance.
+func NewUsage(nsClient namespaced.Client, signupService service.SignupService, getMembersFunc cluster.GetMemberClustersFunc) *Usage {
+	return &Usage{
+		Client:         nsClient,
+		signupService:  signupService,
+		getMembersFunc: getMembersFunc,
+	}
+}
+
+// GetHandler returns the usage of the resources of the user
+func (u *Usage) GetHandler(ctx *gin.Context) {
+	username := ctx.GetString(context.UsernameKey)
+	userSignup, err := u.signupService.GetSignup(ctx, username, true)
+	if err != nil {
+		log.Error(ctx, err, "error getting the UserSignup")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting the usage")
+		return
+	}
+	if userSignup == nil || userSignup.CompliantUsername == "" {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, fmt.Errorf("user '%s' not found or not provisioned yet", username), "usage not found")
+		return
+	}
+
+	space := &toolchainv1alpha1.Space{}
+	if err := u.Get(ctx, u.NamespacedName(userSignup.CompliantUsername), space); err != nil {
+		log.Error(ctx, err, "error getting the Space")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting the usage")
+		return
+	}
+	response := UsageResponse{
+		Tier:        space.Spec.TierName,
+		ClusterName: space.Status.TargetCluster,
+		Namespaces:  []string{},
+		Resources:   []ResourceUsage{},
+	}
+	for _, ns := range space.Status.ProvisionedNamespaces {
+		response.Namespaces = append(response.Namespaces, ns.Name)
+	}
+
+	members := u.getMembersFunc(func(member *cluster.CachedToolchainCluster) bool {
+		return member.Name == space.Status.TargetCluster
+	})
+	if len(response.Namespaces) == 0 || len(members) == 0 || members[0].Client == nil {
+		// the namespaces are not provisioned yet
+		ctx.JSON(http.StatusOK, response)
+		return
+	}
+
+	hard, used := corev1.ResourceList{}, corev1.ResourceList{}
+	for _, ns := range response.Namespaces {
+		quotas := &corev1.ResourceQuotaList{}
+		if err := members[0].Client.List(ctx, quotas, client.InNamespace(ns)); err != nil {
+			log.Error(ctx, err, fmt.Sprintf("error listing the ResourceQuotas of the namespace '%s'", ns))
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, errors.New("unable to collect the usage from the member cluster"), "error getting the usage")
+			return
+		}
+		for _, quota := range quotas.Items {
+			addResources(hard, quota.Status.Hard)
+			addResources(used, quota.Status.Used)
+		}
+	}
+	for name, quantity := range hard {
+		consumption := used[name]
+		response.Resources = append(response.Resources, ResourceUsage{
+			Name:       string(name),
+			Hard:       quantity.String(),
+			Used:       consumption.String(),
+			Percentage: percentage(consumption, quantity),
+		})
+	}
+	sort.Slice(response.Resources, func(i, j int) bool {
+		return response.Resources[i].Name < response.Resources[j].Name
+	})
+	ctx.JSON(http.StatusOK, response)
+}
+
+// addResources adds the quantities of the given resources to the given total
+func addResources(total, resources corev1.ResourceList) {
+	for name, quantity := range resources {
+		sum := total[name]
+		sum.Add(quantity)
+		total[name] = sum
+	}
+}
+
+// percentage returns the given consumption relative to the given quota, rounded down, or zero if there is no quota
+func percentage(used, hard resource.Quantity) int {
+	if hard.IsZero() {
+		return 0
+	}
+	return int(math.Floor(used.AsApproximateFloat64() * 100 / hard.AsApproximateFloat64()))
+}
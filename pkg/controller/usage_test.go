@@ -0,0 +1,126 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestUsageSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunUsageSuite(t *testing.T) {
+	suite.Run(t, &TestUsageSuite{test.UnitTestSuite{}})
+}
+
+func newResourceQuota(name, namespace string, hard, used corev1.ResourceList) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
+
+func (s *TestUsageSuite) TestGetHandler() {
+	// given
+	hostClient := commontest.NewFakeClient(s.T(), fake.NewSpace("dancelover", "member-1", "dancelover"))
+	memberClient := commontest.NewFakeClient(s.T(),
+		newResourceQuota("compute", "dancelover-dev",
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4Gi"), corev1.ResourcePods: resource.MustParse("10")},
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("2Gi"), corev1.ResourcePods: resource.MustParse("4")}),
+		newResourceQuota("compute", "dancelover-stage",
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4Gi")},
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4608Mi")}),
+		newResourceQuota("compute", "movielover-dev",
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4Gi")},
+			corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4Gi")}),
+	)
+	getMembersFunc := func(conditions ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		members := []*commoncluster.CachedToolchainCluster{}
+		for _, member := range []*commoncluster.CachedToolchainCluster{
+			{Client: commontest.NewFakeClient(s.T()), Config: &commoncluster.Config{Name: "member-2"}},
+			{Client: memberClient, Config: &commoncluster.Config{Name: "member-1"}},
+		} {
+			if conditions[0](member) {
+				members = append(members, member)
+			}
+		}
+		return members
+	}
+	signupService := fake.NewSignupService(
+		&signup.Signup{Name: "dancelover", CompliantUsername: "dancelover", Status: signup.Status{Ready: true}},
+		&signup.Signup{Name: "movielover", Status: signup.Status{Ready: false}},
+	)
+	ctrl := controller.NewUsage(namespaced.NewClient(hostClient, commontest.HostOperatorNs), signupService, getMembersFunc)
+	get := func(username string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/usage", nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.Set(rcontext.UsernameKey, username)
+		ctrl.GetHandler(ctx)
+		return rr
+	}
+
+	s.Run("usage", func() {
+		// when
+		rr := get("dancelover")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		response := controller.UsageResponse{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &response))
+		assert.Equal(s.T(), controller.UsageResponse{
+			Tier:        "base1ns",
+			ClusterName: "member-1",
+			Namespaces:  []string{"dancelover-dev", "dancelover-stage"},
+			Resources: []controller.ResourceUsage{
+				{Name: "limits.memory", Hard: "8Gi", Used: "6656Mi", Percentage: 81},
+				{Name: "pods", Hard: "10", Used: "4", Percentage: 40},
+			},
+		}, response)
+	})
+
+	s.Run("not provisioned yet", func() {
+		// when
+		rr := get("movielover")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusNotFound, "user 'movielover' not found or not provisioned yet", "usage not found")
+	})
+
+	s.Run("member cluster error", func() {
+		// given
+		memberClient.MockList = func(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+			return fmt.Errorf("mock error")
+		}
+		defer func() { memberClient.MockList = nil }()
+
+		// when
+		rr := get("dancelover")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusInternalServerError, "unable to collect the usage from the member cluster", "error getting the usage")
+	})
+}
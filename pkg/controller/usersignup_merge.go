@@ -0,0 +1,62 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/gin-gonic/gin"
+)
+
+// MergeRequest is the body of the requests merging two UserSignups
+type MergeRequest struct {
+	// Survivor is the name of the UserSignup which is kept
+	Survivor string `json:"survivor"`
+	// Duplicate is the name of the UserSignup which is merged into the survivor, and deactivated
+	Duplicate string `json:"duplicate"`
+	// DryRun returns the changes without applying them
+	DryRun bool `json:"dryRun"`
+}
+
+// UserSignupMerge implements the admin endpoint merging two UserSignups belonging to the same person, restricted to the users of
+// the Admins support setting
+type UserSignupMerge struct {
+	namespaced.Client
+}
+
+// NewUserSignupMerge returns a new UserSignupMerge instance.
+func NewUserSignupMerge(nsClient namespaced.Client) *UserSignupMerge {
+	return &UserSignupMerge{
+		Client: nsClient,
+	}
+}
+
+// PostHandler merges the duplicate UserSignup of the request into the surviving one (see signup.MergeUserSignups),
+// and returns the changes
+func (m *UserSignupMerge) PostHandler(ctx *gin.Context) {
+	if !requireAdmin(ctx) {
+		return
+	}
+	var req MergeRequest
+	if err := ctx.BindJSON(&req); err != nil {
+		log.Error(ctx, err, "invalid merge request")
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+		return
+	}
+	result, err := signup.MergeUserSignups(ctx, m.Client, req.Survivor, req.Duplicate, req.DryRun)
+	if err != nil {
+		log.Error(ctx, err, "error merging the UserSignups")
+		e := &crterrors.Error{}
+		switch {
+		case errors.As(err, &e):
+			crterrors.AbortWithError(ctx, e.Code, err, e.Message)
+		default:
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error merging the UserSignups")
+		}
+		return
+	}
+	ctx.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,94 @@
+package controller_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crtcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type TestUserSignupMergeSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunUserSignupMergeSuite(t *testing.T) {
+	suite.Run(t, &TestUserSignupMergeSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestUserSignupMergeSuite) TestPostHandler() {
+	// given
+	newUserSignup := func(name string) *toolchainv1alpha1.UserSignup {
+		return &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: commontest.HostOperatorNs,
+			},
+		}
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "support")
+	fakeClient := commontest.NewFakeClient(s.T(), newUserSignup("john"), newUserSignup("john-doe"))
+	handler := gin.HandlerFunc(controller.NewUserSignupMerge(namespaced.NewClient(fakeClient, commontest.HostOperatorNs)).PostHandler)
+	post := func(user, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/admin/signups/merge", strings.NewReader(body))
+		ctx.Set(crtcontext.UsernameKey, user)
+		handler(ctx)
+		return rr
+	}
+
+	s.Run("dry run", func() {
+		// when
+		rr := post("support", `{"survivor": "john", "duplicate": "john-doe", "dryRun": true}`)
+
+		// then
+		assert.Equal(s.T(), http.StatusOK, rr.Code)
+		result := &signup.MergeResult{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), result))
+		assert.True(s.T(), result.DryRun)
+		assert.Len(s.T(), result.Changes, 3)
+	})
+
+	s.Run("not an admin", func() {
+		// when
+		rr := post("alice", `{"survivor": "john", "duplicate": "john-doe"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "user 'alice' is not an admin", "forbidden")
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), namespaced.NewClient(fakeClient, commontest.HostOperatorNs).NamespacedName("john-doe"), userSignup))
+		assert.Empty(s.T(), userSignup.Annotations)
+	})
+
+	s.Run("invalid body", func() {
+		// when
+		rr := post("support", `{"survivor":`)
+
+		// then
+		assert.Equal(s.T(), http.StatusBadRequest, rr.Code)
+	})
+
+	s.Run("UserSignup not found", func() {
+		// when
+		rr := post("support", `{"survivor": "john", "duplicate": "unknown"}`)
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+		assert.Contains(s.T(), rr.Body.String(), "UserSignup 'unknown' not found")
+	})
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// DecidedByAnnotationKey is the annotation of the UserSignups approved or declined with the admin endpoints, with the
+	// username of the admin
+	DecidedByAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "decided-by"
+	// DecisionReasonAnnotationKey is the annotation of the UserSignups approved or declined with the admin endpoints, with
+	// the reason of the decision
+	DecisionReasonAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "decision-reason"
+)
+
+const (
+	// defaultUserSignupsLimit is the number of UserSignups returned per page when the `limit` query parameter is not set
+	defaultUserSignupsLimit = 50
+	// maxUserSignupsLimit is the maximum number of UserSignups returned per page
+	maxUserSignupsLimit = 500
+)
+
+// userSignupStates are the values of the `state` query parameter of the admin endpoint listing the UserSignups, by value of
+// the state label of the UserSignups
+var userSignupStates = map[string]string{
+	"pending-approval":      toolchainv1alpha1.UserSignupStateLabelValuePending,
+	"verification-required": toolchainv1alpha1.UserSignupStateLabelValueNotReady,
+	"approved":              toolchainv1alpha1.UserSignupStateLabelValueApproved,
+	"deactivated":           toolchainv1alpha1.UserSignupStateLabelValueDeactivated,
+	"banned":                toolchainv1alpha1.UserSignupStateLabelValueBanned,
+}
+
+// UserSignupSummary is the summary of a UserSignup returned by the admin endpoint listing the UserSignups
+type UserSignupSummary struct {
+	// Name is the name of the UserSignup resource
+	Name string `json:"name"`
+	// Username is the preferred username of the user in SSO
+	Username string `json:"username"`
+	// Email is the email address of the user
+	Email string `json:"email"`
+	// CompliantUsername is the username of the user in the clusters, once provisioned. Empty if the UserSignup is anonymized.
+	CompliantUsername string `json:"compliantUsername,omitempty"`
+	// State is the value of the state label of the UserSignup
+	State string `json:"state"`
+	// VerificationRequired is true if the user has to verify their phone number before being approved
+	VerificationRequired bool `json:"verificationRequired"`
+	// Created is the creation time of the UserSignup, in RFC3339 format
+	Created string `json:"created"`
+	// Anonymized is true if the personal data of the user was erased (see signup.Anonymize)
+	Anonymized bool `json:"anonymized,omitempty"`
+}
+
+// UserSignupList is a page of UserSignups returned by the admin endpoint listing the UserSignups
+type UserSignupList struct {
+	// Items are the UserSignups of the page, sorted by name
+	Items []UserSignupSummary `json:"items"`
+	// Continue is the value of the `continue` query parameter returning the next page, or empty if this is the last page
+	Continue string `json:"continue,omitempty"`
+}
+
+// requireAdmin returns true if the user of the request is one of the Admins of the support settings, and aborts the request
+// with a 403 error otherwise
+func requireAdmin(ctx *gin.Context) bool {
+	admin := ctx.GetString(context.UsernameKey)
+	if !slices.Contains(configuration.GetRegistrationServiceConfig().Support().Admins(), admin) {
+		log.Infof(ctx, "user '%s' is not allowed to use the admin endpoints", admin)
+		crterrors.AbortWithError(ctx, http.StatusForbidden, fmt.Errorf("user '%s' is not an admin", admin), "forbidden")
+		return false
+	}
+	return true
+}
+
+// DecisionRequest is the body of the requests approving or declining a UserSignup
+type DecisionRequest struct {
+	// Reason is the reason of the decision, which is required to decline a UserSignup
+	Reason string `json:"reason"`
+}
+
+// UserSignupsAdmin implements the admin endpoints listing, approving, declining and anonymizing the UserSignups for the support tooling,
+// restricted to the users of the Admins support setting
+type UserSignupsAdmin struct {
+	namespaced.Client
+}
+
+// NewUserSignupsAdmin returns a new UserSignupsAdmin instance.
+func NewUserSignupsAdmin(nsClient namespaced.Client) *UserSignupsAdmin {
+	return &UserSignupsAdmin{
+		Client: nsClient,
+	}
+}
+
+// ListHandler returns a page of the UserSignups, sorted by name and filtered with the optional query parameters:
+// `state` (pending-approval, verification-required, approved, deactivated or banned), `email` and `username`.
+// The pages have at most `limit` items, and the next page is returned with the `continue` value of the response.
+func (a *UserSignupsAdmin) ListHandler(ctx *gin.Context) {
+	if !requireAdmin(ctx) {
+		return
+	}
+	limit := defaultUserSignupsLimit
+	if value := ctx.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, fmt.Errorf("invalid limit: '%s'", value), "invalid query parameter")
+			return
+		}
+		limit = min(limit, maxUserSignupsLimit)
+	}
+	labels := client.MatchingLabels{}
+	if value := ctx.Query("state"); value != "" {
+		state, found := userSignupStates[value]
+		if !found {
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, fmt.Errorf("invalid state: '%s'", value), "invalid query parameter")
+			return
+		}
+		labels[toolchainv1alpha1.UserSignupStateLabelKey] = state
+	}
+	if email := ctx.Query("email"); email != "" {
+		labels[toolchainv1alpha1.UserSignupUserEmailHashLabelKey] = hash.EncodeString(email)
+	}
+	username := ctx.Query("username")
+
+	userSignups := &toolchainv1alpha1.UserSignupList{}
+	if err := a.List(ctx.Request.Context(), userSignups, client.InNamespace(a.Namespace), labels); err != nil {
+		log.Error(ctx, err, "error listing the UserSignups")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error listing the UserSignups")
+		return
+	}
+	// the pages are made here rather than with the `limit` and `continue` options of the list, which are not supported by the
+	// cache of the client
+	sort.Slice(userSignups.Items, func(i, j int) bool {
+		return userSignups.Items[i].Name < userSignups.Items[j].Name
+	})
+	after := ctx.Query("continue")
+	result := UserSignupList{Items: []UserSignupSummary{}}
+	for _, userSignup := range userSignups.Items {
+		if userSignup.Name <= after || (username != "" && userSignup.Spec.IdentityClaims.PreferredUsername != username) {
+			continue
+		}
+		if len(result.Items) == limit {
+			result.Continue = result.Items[limit-1].Name
+			break
+		}
+		result.Items = append(result.Items, summaryOf(&userSignup))
+	}
+	ctx.JSON(http.StatusOK, result)
+}
+
+// ApproveHandler approves the pending UserSignup of the `name` path parameter, with the optional reason of the body
+func (a *UserSignupsAdmin) ApproveHandler(ctx *gin.Context) {
+	a.decide(ctx, true)
+}
+
+// DeclineHandler declines the pending UserSignup of the `name` path parameter, with the reason of the body, which is required.
+// The UserSignup is deactivated, as with the host operator.
+func (a *UserSignupsAdmin) DeclineHandler(ctx *gin.Context) {
+	a.decide(ctx, false)
+}
+
+// decide approves or declines the pending UserSignup of the `name` path parameter, and records the reason and the admin who
+// decided in its annotations. A 409 error is returned if the UserSignup is not pending approval.
+func (a *UserSignupsAdmin) decide(ctx *gin.Context, approve bool) {
+	if !requireAdmin(ctx) {
+		return
+	}
+	var req DecisionRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.BindJSON(&req); err != nil {
+			log.Error(ctx, err, "invalid decision request")
+			crterrors.AbortWithError(ctx, http.StatusBadRequest, err, "error reading request body")
+			return
+		}
+	}
+	if !approve && req.Reason == "" {
+		crterrors.AbortWithError(ctx, http.StatusBadRequest, errors.New("the reason is required"), "invalid decision request")
+		return
+	}
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := a.Get(ctx.Request.Context(), a.NamespacedName(ctx.Param("name")), userSignup); err != nil {
+		if apierrors.IsNotFound(err) {
+			crterrors.AbortWithError(ctx, http.StatusNotFound, err, "UserSignup not found")
+			return
+		}
+		log.Error(ctx, err, "error getting the UserSignup")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting the UserSignup")
+		return
+	}
+	if state := userSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey]; state != toolchainv1alpha1.UserSignupStateLabelValuePending {
+		crterrors.AbortWithError(ctx, http.StatusConflict, fmt.Errorf("the UserSignup is in the '%s' state", state), "the UserSignup is not pending approval")
+		return
+	}
+	decision := "declined"
+	if approve {
+		decision = "approved"
+		states.SetApprovedManually(userSignup, true)
+	} else {
+		states.SetDeactivated(userSignup, true)
+	}
+	admin := ctx.GetString(context.UsernameKey)
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[DecidedByAnnotationKey] = admin
+	userSignup.Annotations[DecisionReasonAnnotationKey] = req.Reason
+	if err := a.Update(ctx.Request.Context(), userSignup); err != nil {
+		log.Error(ctx, err, "error updating the UserSignup")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error updating the UserSignup")
+		return
+	}
+	log.Info(ctx, fmt.Sprintf("audit: the UserSignup '%s' was %s by '%s': %s", userSignup.Name, decision, admin, req.Reason))
+	ctx.JSON(http.StatusOK, summaryOf(userSignup))
+}
+
+// AnonymizeHandler erases the personal data of the deactivated UserSignup of the `name` path parameter (see signup.Anonymize), to
+// fulfill the erasure requests of the users. A 409 error is returned if the UserSignup is not deactivated.
+func (a *UserSignupsAdmin) AnonymizeHandler(ctx *gin.Context) {
+	if !requireAdmin(ctx) {
+		return
+	}
+	userSignup, err := signup.Anonymize(ctx, a.Client, ctx.Param("name"))
+	if err != nil {
+		e := &apierrors.StatusError{}
+		crtErr := &crterrors.Error{}
+		switch {
+		case apierrors.IsNotFound(err):
+			crterrors.AbortWithError(ctx, http.StatusNotFound, err, "UserSignup not found")
+		case errors.As(err, &crtErr):
+			crterrors.AbortWithError(ctx, int(crtErr.Code), err, "error anonymizing the UserSignup")
+		case errors.As(err, &e):
+			crterrors.AbortWithError(ctx, int(e.Status().Code), err, "error anonymizing the UserSignup")
+		default:
+			log.Error(ctx, err, "error anonymizing the UserSignup")
+			crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error anonymizing the UserSignup")
+		}
+		return
+	}
+	log.Info(ctx, fmt.Sprintf("audit: the personal data of the UserSignup '%s' was erased by '%s'", userSignup.Name, ctx.GetString(context.UsernameKey)))
+	ctx.JSON(http.StatusOK, summaryOf(userSignup))
+}
+
+// summaryOf returns the summary of the given UserSignup, without the compliant username of an anonymized UserSignup
+func summaryOf(userSignup *toolchainv1alpha1.UserSignup) UserSignupSummary {
+	summary := UserSignupSummary{
+		Name:                 userSignup.Name,
+		Username:             userSignup.Spec.IdentityClaims.PreferredUsername,
+		Email:                userSignup.Spec.IdentityClaims.Email,
+		CompliantUsername:    userSignup.Status.CompliantUsername,
+		State:                userSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey],
+		VerificationRequired: states.VerificationRequired(userSignup),
+		Created:              userSignup.CreationTimestamp.UTC().Format(time.RFC3339),
+		Anonymized:           signup.IsAnonymized(userSignup),
+	}
+	if summary.Anonymized {
+		summary.CompliantUsername = ""
+	}
+	return summary
+}
@@ -0,0 +1,360 @@
+package controller_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crtcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type TestUserSignupsAdminSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunUserSignupsAdminSuite(t *testing.T) {
+	suite.Run(t, &TestUserSignupsAdminSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestUserSignupsAdminSuite) TestListHandler() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "support")
+	fakeClient := commontest.NewFakeClient(s.T(),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("alice"),
+			testusersignup.WithEmail("alice@redhat.com"),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("bob"),
+			testusersignup.WithEmail("bob@redhat.com"),
+			testusersignup.VerificationRequired(),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueNotReady)),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("carol"),
+			testusersignup.WithEmail("carol@redhat.com"),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueBanned)),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("dave"),
+			testusersignup.WithEmail("dave@redhat.com"),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+	)
+	ctrl := controller.NewUserSignupsAdmin(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	list := func(user, query string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/admin/signups?"+query, nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.Set(crtcontext.UsernameKey, user)
+		ctrl.ListHandler(ctx)
+		return rr
+	}
+	namesOf := func(rr *httptest.ResponseRecorder) ([]string, string) {
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		result := controller.UserSignupList{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &result))
+		names := []string{}
+		for _, item := range result.Items {
+			names = append(names, item.Name)
+		}
+		return names, result.Continue
+	}
+
+	s.Run("all", func() {
+		// when
+		names, next := namesOf(list("support", ""))
+
+		// then
+		assert.Equal(s.T(), []string{"alice", "bob", "carol", "dave"}, names)
+		assert.Empty(s.T(), next)
+	})
+
+	s.Run("pages", func() {
+		// when
+		names, next := namesOf(list("support", "limit=3"))
+
+		// then
+		assert.Equal(s.T(), []string{"alice", "bob", "carol"}, names)
+		assert.Equal(s.T(), "carol", next)
+
+		s.Run("next page", func() {
+			// when
+			names, next := namesOf(list("support", "limit=3&continue="+next))
+
+			// then
+			assert.Equal(s.T(), []string{"dave"}, names)
+			assert.Empty(s.T(), next)
+		})
+	})
+
+	s.Run("by state", func() {
+		for state, expected := range map[string][]string{
+			"pending-approval":      {"alice", "dave"},
+			"verification-required": {"bob"},
+			"banned":                {"carol"},
+			"deactivated":           {},
+		} {
+			// when
+			names, _ := namesOf(list("support", "state="+state))
+
+			// then
+			assert.Equal(s.T(), expected, names, state)
+		}
+	})
+
+	s.Run("by email", func() {
+		// when
+		rr := list("support", "email=bob@redhat.com")
+
+		// then
+		names, _ := namesOf(rr)
+		assert.Equal(s.T(), []string{"bob"}, names)
+		result := controller.UserSignupList{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &result))
+		assert.Equal(s.T(), "bob@redhat.com", result.Items[0].Email)
+		assert.Equal(s.T(), toolchainv1alpha1.UserSignupStateLabelValueNotReady, result.Items[0].State)
+		assert.True(s.T(), result.Items[0].VerificationRequired)
+	})
+
+	s.Run("by username", func() {
+		// when
+		names, _ := namesOf(list("support", "username=carol&state=banned"))
+
+		// then
+		assert.Equal(s.T(), []string{"carol"}, names)
+	})
+
+	s.Run("not an admin", func() {
+		// when
+		rr := list("alice", "")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "user 'alice' is not an admin", "forbidden")
+	})
+
+	s.Run("invalid state", func() {
+		// when
+		rr := list("support", "state=unknown")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "invalid state: 'unknown'", "invalid query parameter")
+	})
+
+	s.Run("invalid limit", func() {
+		// when
+		rr := list("support", "limit=0")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "invalid limit: '0'", "invalid query parameter")
+	})
+
+	s.Run("list error", func() {
+		// given
+		fakeClient.MockList = func(_ context.Context, _ client.ObjectList, _ ...client.ListOption) error {
+			return fmt.Errorf("mock error")
+		}
+		defer func() { fakeClient.MockList = nil }()
+
+		// when
+		rr := list("support", "")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusInternalServerError, "mock error", "error listing the UserSignups")
+	})
+}
+
+func (s *TestUserSignupsAdminSuite) TestDecisionHandlers() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "support")
+	fakeClient := commontest.NewFakeClient(s.T(),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("alice"),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("bob"),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+		testusersignup.NewUserSignup(
+			testusersignup.WithName("carol"),
+			testusersignup.ApprovedManually(),
+			testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+	)
+	ctrl := controller.NewUserSignupsAdmin(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	decide := func(handler gin.HandlerFunc, user, name, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/admin/signups/"+name, bytes.NewBufferString(body))
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.AddParam("name", name)
+		ctx.Set(crtcontext.UsernameKey, user)
+		handler(ctx)
+		return rr
+	}
+	userSignupOf := func(name string) *toolchainv1alpha1.UserSignup {
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: commontest.HostOperatorNs, Name: name}, userSignup))
+		return userSignup
+	}
+
+	s.Run("approve", func() {
+		// when
+		rr := decide(ctrl.ApproveHandler, "support", "alice", "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		userSignup := userSignupOf("alice")
+		assert.True(s.T(), states.ApprovedManually(userSignup))
+		assert.Equal(s.T(), "support", userSignup.Annotations[controller.DecidedByAnnotationKey])
+		assert.Empty(s.T(), userSignup.Annotations[controller.DecisionReasonAnnotationKey])
+	})
+
+	s.Run("decline", func() {
+		// when
+		rr := decide(ctrl.DeclineHandler, "support", "bob", `{"reason":"fake company"}`)
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		userSignup := userSignupOf("bob")
+		assert.True(s.T(), states.Deactivated(userSignup))
+		assert.False(s.T(), states.ApprovedManually(userSignup))
+		assert.Equal(s.T(), "support", userSignup.Annotations[controller.DecidedByAnnotationKey])
+		assert.Equal(s.T(), "fake company", userSignup.Annotations[controller.DecisionReasonAnnotationKey])
+	})
+
+	s.Run("decline without reason", func() {
+		// when
+		rr := decide(ctrl.DeclineHandler, "support", "bob", "")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusBadRequest, "the reason is required", "invalid decision request")
+	})
+
+	s.Run("not pending", func() {
+		// when
+		rr := decide(ctrl.DeclineHandler, "support", "carol", `{"reason":"fake company"}`)
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusConflict, "the UserSignup is in the 'approved' state", "the UserSignup is not pending approval")
+		assert.False(s.T(), states.Deactivated(userSignupOf("carol")))
+	})
+
+	s.Run("not found", func() {
+		// when
+		rr := decide(ctrl.ApproveHandler, "support", "dave", "")
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+
+	s.Run("not an admin", func() {
+		// when
+		rr := decide(ctrl.ApproveHandler, "alice", "alice", "")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "user 'alice' is not an admin", "forbidden")
+	})
+}
+
+func (s *TestUserSignupsAdminSuite) TestAnonymizeHandler() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_ADMINS", "support")
+	fakeClient := commontest.NewFakeClient(s.T(),
+		testusersignup.NewUserSignup(testusersignup.WithName("alice"), testusersignup.Deactivated(), testusersignup.WithCompliantUsername("alice")),
+		testusersignup.NewUserSignup(testusersignup.WithName("bob"), testusersignup.ApprovedManually()),
+	)
+	ctrl := controller.NewUserSignupsAdmin(namespaced.NewClient(fakeClient, commontest.HostOperatorNs))
+	anonymize := func(user, name string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodPost, "/api/v1/admin/signups/"+name+"/anonymize", nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.AddParam("name", name)
+		ctx.Set(crtcontext.UsernameKey, user)
+		ctrl.AnonymizeHandler(ctx)
+		return rr
+	}
+
+	s.Run("anonymized", func() {
+		// when
+		rr := anonymize("support", "alice")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		summary := controller.UserSignupSummary{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &summary))
+		assert.Equal(s.T(), "alice", summary.Name)
+		assert.Empty(s.T(), summary.Username)
+		assert.Empty(s.T(), summary.Email)
+		assert.Empty(s.T(), summary.CompliantUsername)
+		assert.True(s.T(), summary.Anonymized)
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKey{Namespace: commontest.HostOperatorNs, Name: "alice"}, userSignup))
+		assert.Empty(s.T(), userSignup.Spec.IdentityClaims.Email)
+		assert.Equal(s.T(), "alice", userSignup.Status.CompliantUsername) // managed by the host operator
+
+		s.Run("listed without the compliant username", func() {
+			// given
+			rr := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(rr)
+			req, err := http.NewRequest(http.MethodGet, "/api/v1/admin/signups", nil)
+			require.NoError(s.T(), err)
+			ctx.Request = req
+			ctx.Set(crtcontext.UsernameKey, "support")
+
+			// when
+			ctrl.ListHandler(ctx)
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+			list := controller.UserSignupList{}
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &list))
+			require.Len(s.T(), list.Items, 2)
+			assert.Equal(s.T(), "alice", list.Items[0].Name)
+			assert.Empty(s.T(), list.Items[0].CompliantUsername)
+			assert.True(s.T(), list.Items[0].Anonymized)
+			assert.False(s.T(), list.Items[1].Anonymized)
+		})
+	})
+
+	s.Run("not deactivated", func() {
+		// when
+		rr := anonymize("support", "bob")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusConflict, "the UserSignup is not deactivated: the UserSignup 'bob' must be deactivated before its personal data is erased",
+			"error anonymizing the UserSignup")
+	})
+
+	s.Run("not found", func() {
+		// when
+		rr := anonymize("support", "dave")
+
+		// then
+		assert.Equal(s.T(), http.StatusNotFound, rr.Code)
+	})
+
+	s.Run("not an admin", func() {
+		// when
+		rr := anonymize("alice", "alice")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusForbidden, "user 'alice' is not an admin", "forbidden")
+	})
+}
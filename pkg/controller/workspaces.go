@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/labstack/echo/v4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Workspaces implements the workspaces endpoints, which return the same workspaces as the workspaces API of the proxy, so that the
+// web UIs can enumerate the workspaces of the user without going through the Kubernetes-flavored proxy API
+type Workspaces struct {
+	spaceLister    *handlers.SpaceLister
+	getMembersFunc cluster.GetMemberClustersFunc
+}
+
+// NewWorkspaces returns a new Workspaces instance.
+func NewWorkspaces(spaceLister *handlers.SpaceLister, getMembersFunc cluster.GetMemberClustersFunc) *Workspaces {
+	return &Workspaces{
+		spaceLister:    spaceLister,
+		getMembersFunc: getMembersFunc,
+	}
+}
+
+// ListHandler returns the workspaces of the user, including the ones shared with the user, but not the ones only visible to the
+// public viewer
+func (w *Workspaces) ListHandler(ctx *gin.Context) {
+	workspaces, err := handlers.ListUserWorkspaces(echoContext(ctx, false), w.spaceLister)
+	if err != nil {
+		log.Error(ctx, err, "error listing the workspaces")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error listing the workspaces")
+		return
+	}
+	ctx.JSON(http.StatusOK, &toolchainv1alpha1.WorkspaceList{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "WorkspaceList",
+			APIVersion: "toolchain.dev.openshift.com/v1alpha1",
+		},
+		Items: workspaces,
+	})
+}
+
+// GetHandler returns the workspace of the `name` path parameter, with its bindings, if the user has access to it
+func (w *Workspaces) GetHandler(ctx *gin.Context) {
+	name := ctx.Param("name")
+	workspace, err := handlers.GetUserWorkspaceWithBindings(echoContext(ctx, configuration.GetRegistrationServiceConfig().PublicViewerEnabled()),
+		w.spaceLister, name, w.getMembersFunc)
+	if err != nil {
+		log.Error(ctx, err, "error getting the workspace")
+		crterrors.AbortWithError(ctx, http.StatusInternalServerError, err, "error getting the workspace")
+		return
+	}
+	if workspace == nil {
+		crterrors.AbortWithError(ctx, http.StatusNotFound, fmt.Errorf("workspace '%s' not found", name), "workspace not found")
+		return
+	}
+	ctx.JSON(http.StatusOK, workspace)
+}
+
+// echoContext returns the context of the request expected by the space lister, which is shared with the proxy, with the user of
+// the given request
+func echoContext(ctx *gin.Context, publicViewerEnabled bool) echo.Context {
+	ectx := echo.New().NewContext(ctx.Request, ctx.Writer)
+	for _, key := range []string{context.UsernameKey, context.JWTClaimsKey} {
+		if value, found := ctx.Get(key); found {
+			ectx.Set(key, value)
+		}
+	}
+	ectx.Set(context.PublicViewerEnabled, publicViewerEnabled)
+	return ectx
+}
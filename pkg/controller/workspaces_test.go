@@ -0,0 +1,125 @@
+package controller_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestWorkspacesSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunWorkspacesSuite(t *testing.T) {
+	suite.Run(t, &TestWorkspacesSuite{test.UnitTestSuite{}})
+}
+
+func (s *TestWorkspacesSuite) TestHandlers() {
+	// given
+	fakeClient := commontest.NewFakeClient(s.T(),
+		fake.NewSpace("dancelover", "member-1", "dancelover"),
+		fake.NewSpaceBinding("dancelover-1", "dancelover", "dancelover", "admin"),
+		fake.NewSpace("movielover", "member-1", "movielover"),
+		fake.NewSpaceBinding("movielover-1", "movielover", "movielover", "admin"),
+		fake.NewSpaceBinding("movielover-2", "dancelover", "movielover", "viewer"),
+		fake.NewSpace("animelover", "member-1", "animelover"),
+		fake.NewSpaceBinding("animelover-1", "animelover", "animelover", "admin"),
+		fake.NewBase1NSTemplateTier(),
+	)
+	signupService := fake.NewSignupService(&signup.Signup{
+		Name:              "dancelover",
+		Username:          "dancelover",
+		CompliantUsername: "dancelover",
+		Status:            signup.Status{Ready: true},
+	})
+	spaceLister := &handlers.SpaceLister{
+		Client:        namespaced.NewClient(fakeClient, commontest.HostOperatorNs),
+		GetSignupFunc: signupService.GetSignup,
+	}
+	getMembersFunc := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Client: commontest.NewFakeClient(s.T()),
+				Config: &commoncluster.Config{Name: "member-1"},
+			},
+		}
+	}
+	ctrl := controller.NewWorkspaces(spaceLister, getMembersFunc)
+	request := func(handler gin.HandlerFunc, username, name string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/workspaces/"+name, nil)
+		require.NoError(s.T(), err)
+		ctx.Request = req
+		ctx.Set(rcontext.UsernameKey, username)
+		if name != "" {
+			ctx.AddParam("name", name)
+		}
+		handler(ctx)
+		return rr
+	}
+
+	s.Run("list", func() {
+		// when
+		rr := request(ctrl.ListHandler, "dancelover", "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		list := &toolchainv1alpha1.WorkspaceList{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), list))
+		assert.Equal(s.T(), "WorkspaceList", list.Kind)
+		names := []string{}
+		for _, workspace := range list.Items {
+			names = append(names, workspace.Name)
+		}
+		assert.ElementsMatch(s.T(), []string{"dancelover", "movielover"}, names)
+	})
+
+	s.Run("list without signup", func() {
+		// when
+		rr := request(ctrl.ListHandler, "unknown", "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		list := &toolchainv1alpha1.WorkspaceList{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), list))
+		assert.Empty(s.T(), list.Items)
+	})
+
+	s.Run("get", func() {
+		// when
+		rr := request(ctrl.GetHandler, "dancelover", "movielover")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rr.Code, rr.Body.String())
+		workspace := &toolchainv1alpha1.Workspace{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), workspace))
+		assert.Equal(s.T(), "movielover", workspace.Name)
+		assert.Equal(s.T(), "viewer", workspace.Status.Role)
+		assert.NotEmpty(s.T(), workspace.Status.Bindings)
+	})
+
+	s.Run("get without access", func() {
+		// when
+		rr := request(ctrl.GetHandler, "dancelover", "animelover")
+
+		// then
+		test.AssertError(s.T(), rr, http.StatusNotFound, "workspace 'animelover' not found", "workspace not found")
+	})
+}
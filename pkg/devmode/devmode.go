@@ -0,0 +1,282 @@
+// Package devmode provides an in-memory host cluster and member clusters, so that the registration service
+// and the proxy can be run locally without any Kubernetes cluster, eg. when developing the UI.
+//
+// The host cluster contains a single provisioned user (see Username) with a home Space in the first member cluster,
+// and a signed token for this user is returned by Token. Since no host operator is running, the signups created
+// through the API remain pending approval.
+// The member clusters are served by local HTTP servers which reply to all the (proxied) requests with a Status
+// describing the received request.
+package devmode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+
+	"github.com/google/uuid"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const (
+	// Namespace is the namespace of the host operator in the in-memory host cluster
+	Namespace = "toolchain-host-operator"
+	// Username is the name of the provisioned user in the in-memory host cluster
+	Username = "developer"
+
+	memberOperatorNamespace = "toolchain-member-operator"
+	memberClusterCount      = 2
+	impersonatorToken       = "dev-mode-token" // nolint:gosec
+)
+
+// userID is the subject of the token of the provisioned user
+var userID = uuid.NewSHA1(uuid.NameSpaceOID, []byte(Username))
+
+// StartMemberClusters starts the local API servers of the in-memory member clusters, which are stopped when the given context is done
+func StartMemberClusters(ctx context.Context) ([]*cluster.CachedToolchainCluster, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*cluster.CachedToolchainCluster, 0, memberClusterCount)
+	for i := 1; i <= memberClusterCount; i++ {
+		name := fmt.Sprintf("member-%d", i)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("unable to start the API server of the member cluster %s: %w", name, err)
+		}
+		srv := &http.Server{
+			Handler:           memberAPIHandler(name),
+			ReadHeaderTimeout: 2 * time.Second,
+		}
+		go func() {
+			if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Error(nil, err, "API server of the member cluster "+name+" failed")
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			_ = srv.Close()
+		}()
+
+		members = append(members, &cluster.CachedToolchainCluster{
+			Config: &cluster.Config{
+				Name:              name,
+				APIEndpoint:       "http://" + listener.Addr().String(),
+				OperatorNamespace: memberOperatorNamespace,
+				RestConfig: &rest.Config{
+					BearerToken: impersonatorToken,
+				},
+			},
+			Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		})
+		log.Infof(nil, "API server of the member cluster %s listening on %s", name, listener.Addr().String())
+	}
+	return members, nil
+}
+
+// GetMemberClustersFunc returns a function returning the given member clusters, ignoring the conditions
+func GetMemberClustersFunc(members []*cluster.CachedToolchainCluster) cluster.GetMemberClustersFunc {
+	return func(_ ...cluster.Condition) []*cluster.CachedToolchainCluster {
+		return members
+	}
+}
+
+// NewHostClient returns a client of the in-memory host cluster, containing the configuration of the service,
+// the status of the given member clusters and the resources of the provisioned user
+func NewHostClient(members []*cluster.CachedToolchainCluster) (client.Client, error) {
+	scheme, err := newScheme()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, errors.New("no member cluster to provision the user in")
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(hostObjects(members)...).
+		Build(), nil
+}
+
+// Token returns a signed token of the provisioned user, which is valid in the "e2e-tests" environment
+// configured in the in-memory host cluster
+func Token() (string, error) {
+	return authsupport.GenerateSignedE2ETestToken(authsupport.Identity{
+		ID:       userID,
+		Username: Username,
+	}, authsupport.WithEmailClaim(Username+"@example.com"))
+}
+
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := corev1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := toolchainv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+func hostObjects(members []*cluster.CachedToolchainCluster) []client.Object {
+	environment := "e2e-tests"
+	memberStatuses := make([]toolchainv1alpha1.Member, 0, len(members))
+	for _, member := range members {
+		memberStatuses = append(memberStatuses, toolchainv1alpha1.Member{
+			ClusterName: member.Name,
+			APIEndpoint: member.APIEndpoint,
+			MemberStatus: toolchainv1alpha1.MemberStatusStatus{
+				Routes: &toolchainv1alpha1.Routes{
+					ConsoleURL: fmt.Sprintf("https://console-openshift-console.apps.%s.devmode.local/", member.Name),
+				},
+			},
+		})
+	}
+	homeCluster := members[0].Name
+	now := metav1.Now()
+	ready := []toolchainv1alpha1.Condition{
+		{
+			Type:   toolchainv1alpha1.ConditionReady,
+			Status: corev1.ConditionTrue,
+			Reason: toolchainv1alpha1.MasterUserRecordProvisionedReason,
+		},
+	}
+
+	return []client.Object{
+		&toolchainv1alpha1.ToolchainConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: "config", Namespace: Namespace},
+			Spec: toolchainv1alpha1.ToolchainConfigSpec{
+				Host: toolchainv1alpha1.HostConfig{
+					RegistrationService: toolchainv1alpha1.RegistrationServiceConfig{
+						// the e2e-tests environment makes the service trust the tokens returned by Token()
+						Environment: &environment,
+					},
+				},
+			},
+		},
+		&toolchainv1alpha1.ToolchainStatus{
+			ObjectMeta: metav1.ObjectMeta{Name: "toolchain-status", Namespace: Namespace},
+			Status: toolchainv1alpha1.ToolchainStatusStatus{
+				HostRoutes: toolchainv1alpha1.HostRoutes{
+					ProxyURL: "http://localhost:" + proxy.DefaultPort,
+				},
+				Members: memberStatuses,
+			},
+		},
+		&toolchainv1alpha1.NSTemplateTier{
+			ObjectMeta: metav1.ObjectMeta{Name: "base1ns", Namespace: Namespace},
+			Spec: toolchainv1alpha1.NSTemplateTierSpec{
+				Namespaces: []toolchainv1alpha1.NSTemplateTierNamespace{
+					{TemplateRef: "base1ns-dev-dev"},
+				},
+				SpaceRoles: map[string]toolchainv1alpha1.NSTemplateTierSpaceRole{
+					"admin": {TemplateRef: "base1ns-admin-dev"},
+				},
+			},
+		},
+		&toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{Name: Username, Namespace: Namespace},
+			Spec: toolchainv1alpha1.UserSignupSpec{
+				IdentityClaims: toolchainv1alpha1.IdentityClaimsEmbedded{
+					PropagatedClaims: toolchainv1alpha1.PropagatedClaims{
+						Sub:   userID.String(),
+						Email: Username + "@example.com",
+					},
+					PreferredUsername: Username,
+				},
+			},
+			Status: toolchainv1alpha1.UserSignupStatus{
+				CompliantUsername: Username,
+				HomeSpace:         Username,
+				Conditions: []toolchainv1alpha1.Condition{
+					{
+						Type:   toolchainv1alpha1.UserSignupApproved,
+						Status: corev1.ConditionTrue,
+						Reason: toolchainv1alpha1.UserSignupApprovedAutomaticallyReason,
+					},
+					{
+						Type:   toolchainv1alpha1.UserSignupComplete,
+						Status: corev1.ConditionTrue,
+					},
+				},
+			},
+		},
+		&toolchainv1alpha1.MasterUserRecord{
+			ObjectMeta: metav1.ObjectMeta{Name: Username, Namespace: Namespace},
+			Spec: toolchainv1alpha1.MasterUserRecordSpec{
+				UserAccounts: []toolchainv1alpha1.UserAccountEmbedded{{TargetCluster: homeCluster}},
+			},
+			Status: toolchainv1alpha1.MasterUserRecordStatus{
+				Conditions:      ready,
+				ProvisionedTime: &now,
+			},
+		},
+		&toolchainv1alpha1.Space{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Username,
+				Namespace: Namespace,
+				Labels: map[string]string{
+					toolchainv1alpha1.SpaceCreatorLabelKey: Username,
+				},
+			},
+			Spec: toolchainv1alpha1.SpaceSpec{
+				TargetCluster: homeCluster,
+				TierName:      "base1ns",
+			},
+			Status: toolchainv1alpha1.SpaceStatus{
+				TargetCluster: homeCluster,
+				ProvisionedNamespaces: []toolchainv1alpha1.SpaceNamespace{
+					{Name: Username + "-dev", Type: toolchainv1alpha1.NamespaceTypeDefault},
+				},
+				Conditions: ready,
+			},
+		},
+		&toolchainv1alpha1.SpaceBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      Username + "-" + Username,
+				Namespace: Namespace,
+				Labels: map[string]string{
+					toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey: Username,
+					toolchainv1alpha1.SpaceBindingSpaceLabelKey:            Username,
+				},
+			},
+			Spec: toolchainv1alpha1.SpaceBindingSpec{
+				MasterUserRecord: Username,
+				Space:            Username,
+				SpaceRole:        "admin",
+			},
+		},
+	}
+}
+
+// memberAPIHandler replies to all the requests with a Status describing the request, as received by the member cluster
+func memberAPIHandler(clusterName string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusSuccess,
+			Message: fmt.Sprintf("%s %s received by the member cluster %s as %s",
+				r.Method, r.URL.RequestURI(), clusterName, r.Header.Get("Impersonate-User")),
+			Code: http.StatusOK,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			log.Error(nil, err, "unable to write the response of the member cluster "+clusterName)
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package devmode_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/devmode"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestDevMode(t *testing.T) {
+	// given
+	log.Init("devmode-testing")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// when
+	members, err := devmode.StartMemberClusters(ctx)
+
+	// then
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	assert.Equal(t, members, devmode.GetMemberClustersFunc(members)())
+
+	t.Run("member API server replies with the received request", func(t *testing.T) {
+		// given
+		req, err := http.NewRequest(http.MethodGet, members[0].APIEndpoint+"/api/v1/namespaces/developer-dev/pods", nil)
+		require.NoError(t, err)
+		req.Header.Set("Impersonate-User", devmode.Username)
+
+		// when
+		resp, err := http.DefaultClient.Do(req)
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		status := metav1.Status{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+		assert.Equal(t, "GET /api/v1/namespaces/developer-dev/pods received by the member cluster member-1 as developer", status.Message)
+	})
+
+	t.Run("host cluster contains the provisioned user", func(t *testing.T) {
+		// when
+		cl, err := devmode.NewHostClient(members)
+
+		// then
+		require.NoError(t, err)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: devmode.Namespace, Name: devmode.Username}, signup))
+		assert.Equal(t, devmode.Username, signup.Status.CompliantUsername)
+		space := &toolchainv1alpha1.Space{}
+		require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: devmode.Namespace, Name: devmode.Username}, space))
+		assert.Equal(t, "member-1", space.Status.TargetCluster)
+		status := &toolchainv1alpha1.ToolchainStatus{}
+		require.NoError(t, cl.Get(ctx, client.ObjectKey{Namespace: devmode.Namespace, Name: "toolchain-status"}, status))
+		require.Len(t, status.Status.Members, 2)
+		assert.Equal(t, members[1].APIEndpoint, status.Status.Members[1].APIEndpoint)
+	})
+
+	t.Run("no member cluster", func(t *testing.T) {
+		// when
+		_, err := devmode.NewHostClient(nil)
+
+		// then
+		require.EqualError(t, err, "no member cluster to provision the user in")
+	})
+
+	t.Run("token", func(t *testing.T) {
+		// when
+		token, err := devmode.Token()
+
+		// then
+		require.NoError(t, err)
+		assert.NotEmpty(t, token)
+	})
+}
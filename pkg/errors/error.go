@@ -3,15 +3,37 @@ package errors
 import (
 	"fmt"
 	"net/http"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// SeverityError is the severity of the server errors which affect a single request
+	SeverityError = "error"
+	// SeverityCritical is the severity of the server errors which affect all the users, such as an unavailable service
+	SeverityCritical = "critical"
+)
+
 type Error struct {
 	Status  string `json:"status"`
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 	Details string `json:"details"`
+	// Support is only set for the server errors (5xx)
+	Support *Support `json:"support,omitempty"`
+}
+
+// Support describes how the users can contact the support team about a server error
+type Support struct {
+	Severity string `json:"severity"`
+	// Priority is true if the channel is the priority support channel, reserved for the errors of critical severity
+	Priority bool   `json:"priority"`
+	Team     string `json:"team"`
+	Email    string `json:"email,omitempty"`
+	URL      string `json:"url,omitempty"`
 }
 
 // AbortWithError stops the chain, writes the status code and the given error
@@ -21,9 +43,34 @@ func AbortWithError(ctx *gin.Context, code int, err error, details string) {
 		Code:    code,
 		Message: err.Error(),
 		Details: details,
+		Support: newSupport(code),
 	})
 }
 
+// newSupport returns the support channel matching the severity of the error with the given status code,
+// or nil if the status code is not the one of a server error
+func newSupport(code int) *Support {
+	if code < http.StatusInternalServerError {
+		return nil
+	}
+	cfg := configuration.GetRegistrationServiceConfig().Support()
+	support := &Support{
+		Severity: SeverityError,
+	}
+	channel := cfg.Channel()
+	if slices.Contains(cfg.CriticalStatusCodes(), code) {
+		support.Severity = SeverityCritical
+		if priorityChannel, found := cfg.PriorityChannel(); found {
+			channel = priorityChannel
+			support.Priority = true
+		}
+	}
+	support.Team = channel.Team
+	support.Email = channel.Email
+	support.URL = channel.URL
+	return support
+}
+
 func (e *Error) Error() string {
 	if e.Details != "" {
 		return fmt.Sprintf("%s: %s", e.Message, e.Details)
@@ -58,6 +105,15 @@ func NewTooManyRequestsError(message, details string) *Error {
 	}
 }
 
+func NewServiceUnavailableError(message, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusServiceUnavailable),
+		Code:    http.StatusServiceUnavailable,
+		Message: message,
+		Details: details,
+	}
+}
+
 func NewInternalError(err error, details string) *Error {
 	return &Error{
 		Status:  http.StatusText(http.StatusInternalServerError),
@@ -84,3 +140,30 @@ func NewBadRequest(message, details string) *Error {
 		Details: details,
 	}
 }
+
+func NewMethodNotAllowedError(message, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusMethodNotAllowed),
+		Code:    http.StatusMethodNotAllowed,
+		Message: message,
+		Details: details,
+	}
+}
+
+func NewBadGatewayError(message, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusBadGateway),
+		Code:    http.StatusBadGateway,
+		Message: message,
+		Details: details,
+	}
+}
+
+func NewConflictError(message, details string) *Error {
+	return &Error{
+		Status:  http.StatusText(http.StatusConflict),
+		Code:    http.StatusConflict,
+		Message: message,
+		Details: details,
+	}
+}
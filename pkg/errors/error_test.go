@@ -66,6 +66,12 @@ func (s *TestErrorsSuite) TestErrors() {
 		require.Equal(s.T(), http.StatusTooManyRequests, err.Code)
 		require.Equal(s.T(), http.StatusText(http.StatusTooManyRequests), err.Status)
 
+		err = errs.NewServiceUnavailableError("foo", "bar")
+		require.Equal(s.T(), "foo", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusServiceUnavailable, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusServiceUnavailable), err.Status)
+
 		err = errs.NewInternalError(errors.New("some error"), "bar")
 		require.Equal(s.T(), "some error", err.Message)
 		require.Equal(s.T(), "bar", err.Details)
@@ -83,5 +89,75 @@ func (s *TestErrorsSuite) TestErrors() {
 		require.Equal(s.T(), "bar", err.Details)
 		require.Equal(s.T(), http.StatusBadRequest, err.Code)
 		require.Equal(s.T(), http.StatusText(http.StatusBadRequest), err.Status)
+
+		err = errs.NewBadGatewayError("foo", "bar")
+		require.Equal(s.T(), "foo", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusBadGateway, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusBadGateway), err.Status)
+
+		err = errs.NewConflictError("foo", "bar")
+		require.Equal(s.T(), "foo", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusConflict, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusConflict), err.Status)
+
+		err = errs.NewMethodNotAllowedError("foo", "bar")
+		require.Equal(s.T(), "foo", err.Message)
+		require.Equal(s.T(), "bar", err.Details)
+		require.Equal(s.T(), http.StatusMethodNotAllowed, err.Code)
+		require.Equal(s.T(), http.StatusText(http.StatusMethodNotAllowed), err.Status)
+	})
+}
+
+func (s *TestErrorsSuite) TestSupport() {
+	abort := func(code int) *errs.Support {
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+		errs.AbortWithError(ctx, code, errors.New("testing new error"), "testing payload")
+		res := errs.Error{}
+		require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), &res))
+		return res.Support
+	}
+
+	s.Run("no support for client errors", func() {
+		require.Nil(s.T(), abort(http.StatusBadRequest))
+	})
+
+	s.Run("default support channel", func() {
+		require.Equal(s.T(), &errs.Support{
+			Severity: errs.SeverityError,
+			Team:     "the Developer Sandbox team",
+			Email:    "devsandbox@redhat.com",
+		}, abort(http.StatusInternalServerError))
+
+		// critical errors use the regular channel when no priority channel is configured
+		require.Equal(s.T(), &errs.Support{
+			Severity: errs.SeverityCritical,
+			Team:     "the Developer Sandbox team",
+			Email:    "devsandbox@redhat.com",
+		}, abort(http.StatusServiceUnavailable))
+	})
+
+	s.Run("configured support channels", func() {
+		s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_TEAM", "the ACME support")
+		s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_EMAIL", "support@acme.com")
+		s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_URL", "https://support.acme.com")
+		s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_PRIORITY_URL", "https://status.acme.com")
+		s.T().Setenv("REGISTRATION_SERVICE_SUPPORT_CRITICAL_STATUS_CODES", "500")
+
+		require.Equal(s.T(), &errs.Support{
+			Severity: errs.SeverityCritical,
+			Priority: true,
+			Team:     "the ACME support",
+			URL:      "https://status.acme.com",
+		}, abort(http.StatusInternalServerError))
+
+		require.Equal(s.T(), &errs.Support{
+			Severity: errs.SeverityError,
+			Team:     "the ACME support",
+			Email:    "support@acme.com",
+			URL:      "https://support.acme.com",
+		}, abort(http.StatusServiceUnavailable))
 	})
 }
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// StateHealthy is the state of a healthy component, as reflected in the metrics
+	StateHealthy = "healthy"
+	// StateUnhealthy is the state of an unhealthy component, as reflected in the metrics
+	StateUnhealthy = "unhealthy"
+)
+
+var (
+	// ComponentHealthyGaugeVec reflects whether each component (via the `component` label) is healthy (1) or not (0)
+	ComponentHealthyGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandbox_component_healthy",
+		Help: "Whether a component the service depends on is healthy (1) or not (0)",
+	}, []string{"component"})
+	// ComponentHealthTransitionsCounterVec counts the health transitions of each component (via the `component` label)
+	// to the healthy or unhealthy state (via the `state` label)
+	ComponentHealthTransitionsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_component_health_transitions_total",
+		Help: "The number of health transitions of a component the service depends on",
+	}, []string{"component", "state"})
+)
+
+// RegisterHealthMetrics registers the metrics of the health of the components in the given registry
+func RegisterHealthMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(ComponentHealthyGaugeVec, ComponentHealthTransitionsCounterVec)
+}
+
+// Transition is a change of the health of a component
+type Transition struct {
+	Component string    `json:"component"`
+	Healthy   bool      `json:"healthy"`
+	Reason    string    `json:"reason,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// History is a bounded, in-memory history of the health transitions of the components, so that the timeline of an incident
+// can be reconstructed without going through the logs
+type History struct {
+	mu          sync.Mutex
+	size        int
+	transitions []Transition
+	healthy     map[string]bool
+}
+
+// NewHistory returns a new History keeping at most the given number of transitions
+func NewHistory(size int) *History {
+	return &History{
+		size:    max(size, 1),
+		healthy: map[string]bool{},
+	}
+}
+
+// Record records the health of the given component, which is unhealthy when the given error is not nil.
+// A transition is only added to the history when the health of the component changed since the previous record
+// (or when the component is recorded for the first time), in which case true is returned.
+func (h *History) Record(component string, err error, now time.Time) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	healthy := err == nil
+	if previous, found := h.healthy[component]; found && previous == healthy {
+		return false
+	}
+	h.healthy[component] = healthy
+	transition := Transition{
+		Component: component,
+		Healthy:   healthy,
+		Time:      now,
+	}
+	state := StateHealthy
+	ComponentHealthyGaugeVec.WithLabelValues(component).Set(1)
+	if !healthy {
+		transition.Reason = err.Error()
+		state = StateUnhealthy
+		ComponentHealthyGaugeVec.WithLabelValues(component).Set(0)
+	}
+	ComponentHealthTransitionsCounterVec.WithLabelValues(component, state).Inc()
+	if len(h.transitions) == h.size {
+		// drop the oldest transition
+		h.transitions = h.transitions[1:]
+	}
+	h.transitions = append(h.transitions, transition)
+	return true
+}
+
+// Transitions returns the transitions in the history, from the oldest to the most recent one
+func (h *History) Transitions() []Transition {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	transitions := make([]Transition, len(h.transitions))
+	copy(transitions, h.transitions)
+	return transitions
+}
+
+// GetHandler returns the transitions in the history
+func (h *History) GetHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, h.Transitions())
+}
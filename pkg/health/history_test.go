@@ -0,0 +1,80 @@
+package health_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/health"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+
+	t.Run("only the transitions are recorded", func(t *testing.T) {
+		// given
+		history := health.NewHistory(10)
+		down := testutil.ToFloat64(health.ComponentHealthTransitionsCounterVec.WithLabelValues("member-cluster/member-1", health.StateUnhealthy))
+
+		// when
+		assert.True(t, history.Record("member-cluster/member-1", nil, now))
+		assert.False(t, history.Record("member-cluster/member-1", nil, now.Add(time.Minute)))
+		assert.True(t, history.Record("member-cluster/member-1", errors.New("not ready"), now.Add(2*time.Minute)))
+		assert.False(t, history.Record("member-cluster/member-1", errors.New("still not ready"), now.Add(3*time.Minute)))
+		assert.True(t, history.Record("sso", nil, now.Add(4*time.Minute)))
+
+		// then
+		assert.Equal(t, []health.Transition{
+			{Component: "member-cluster/member-1", Healthy: true, Time: now},
+			{Component: "member-cluster/member-1", Healthy: false, Reason: "not ready", Time: now.Add(2 * time.Minute)},
+			{Component: "sso", Healthy: true, Time: now.Add(4 * time.Minute)},
+		}, history.Transitions())
+		assert.Zero(t, testutil.ToFloat64(health.ComponentHealthyGaugeVec.WithLabelValues("member-cluster/member-1")))
+		assert.InDelta(t, 1, testutil.ToFloat64(health.ComponentHealthyGaugeVec.WithLabelValues("sso")), 0.01)
+		assert.InDelta(t, down+1, testutil.ToFloat64(health.ComponentHealthTransitionsCounterVec.WithLabelValues("member-cluster/member-1", health.StateUnhealthy)), 0.01)
+	})
+
+	t.Run("the oldest transitions are dropped", func(t *testing.T) {
+		// given
+		history := health.NewHistory(2)
+
+		// when
+		history.Record("proxy", nil, now)
+		history.Record("proxy", errors.New("down"), now.Add(time.Minute))
+		history.Record("proxy", nil, now.Add(2*time.Minute))
+
+		// then
+		assert.Equal(t, []health.Transition{
+			{Component: "proxy", Healthy: false, Reason: "down", Time: now.Add(time.Minute)},
+			{Component: "proxy", Healthy: true, Time: now.Add(2 * time.Minute)},
+		}, history.Transitions())
+	})
+
+	t.Run("get handler", func(t *testing.T) {
+		// given
+		history := health.NewHistory(10)
+		history.Record("sso", errors.New("unreachable"), now)
+		rr := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(rr)
+
+		// when
+		history.GetHandler(ctx)
+
+		// then
+		require.Equal(t, http.StatusOK, rr.Code)
+		transitions := []health.Transition{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &transitions))
+		require.Len(t, transitions, 1)
+		assert.Equal(t, "sso", transitions[0].Component)
+		assert.False(t, transitions[0].Healthy)
+		assert.Equal(t, "unreachable", transitions[0].Reason)
+		assert.True(t, now.Equal(transitions[0].Time))
+	})
+}
@@ -0,0 +1,96 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+)
+
+const (
+	// ComponentProxy is the name of the API proxy component
+	ComponentProxy = "proxy"
+	// ComponentSSO is the name of the SSO component
+	ComponentSSO = "sso"
+	// componentMemberClusterPrefix is the prefix of the names of the member cluster components, followed by the name of the cluster
+	componentMemberClusterPrefix = "member-cluster/"
+)
+
+// Check checks the health of one or several components, and returns the health of each of them, keyed by the component name:
+// nil for a healthy component, or the reason why it is unhealthy
+type Check func(ctx context.Context) map[string]error
+
+// Monitor runs the given checks at the given interval, and records their results in the given history, until the given context
+// is done. Nothing is monitored when the interval is zero or negative.
+func Monitor(ctx context.Context, history *History, interval time.Duration, checks ...Check) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, check := range checks {
+			for component, err := range check(ctx) {
+				history.Record(component, err, time.Now())
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProxyCheck checks the health of the API proxy with the given checker
+func ProxyCheck(checker controller.HealthChecker) Check {
+	return func(_ context.Context) map[string]error {
+		if !checker.APIProxyAlive(nil) {
+			return map[string]error{ComponentProxy: errors.New("the API proxy health check failed")}
+		}
+		return map[string]error{ComponentProxy: nil}
+	}
+}
+
+// MemberClustersCheck checks the readiness of each of the member clusters returned by the given function
+func MemberClustersCheck(getMembersFunc cluster.GetMemberClustersFunc) Check {
+	return func(_ context.Context) map[string]error {
+		results := map[string]error{}
+		for _, member := range getMembersFunc() {
+			var err error
+			if member.ClusterStatus == nil || !cluster.IsReady(member.ClusterStatus) {
+				err = errors.New("the member cluster is not ready")
+			}
+			results[componentMemberClusterPrefix+member.Name] = err
+		}
+		return results
+	}
+}
+
+// SSOCheck checks that the SSO is reachable, by fetching its public keys from the given URL.
+// Nothing is checked when the URL is empty.
+func SSOCheck(url string) Check {
+	httpClient := &http.Client{Timeout: 5 * time.Second}
+	return func(ctx context.Context) map[string]error {
+		if url == "" {
+			return nil
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return map[string]error{ComponentSSO: err}
+		}
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return map[string]error{ComponentSSO: err}
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return map[string]error{ComponentSSO: fmt.Errorf("unexpected status of the SSO public keys endpoint: %s", resp.Status)}
+		}
+		return map[string]error{ComponentSSO: nil}
+	}
+}
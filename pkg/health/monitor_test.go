@@ -0,0 +1,130 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/health"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestMonitor(t *testing.T) {
+	t.Run("checks recorded until the context is done", func(t *testing.T) {
+		// given
+		history := health.NewHistory(10)
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		check := func(_ context.Context) map[string]error {
+			calls++
+			if calls == 3 {
+				cancel()
+				return map[string]error{"proxy": errors.New("down")}
+			}
+			return map[string]error{"proxy": nil}
+		}
+
+		// when
+		health.Monitor(ctx, history, time.Millisecond, check)
+
+		// then
+		assert.Equal(t, 3, calls)
+		transitions := history.Transitions()
+		require.Len(t, transitions, 2)
+		assert.True(t, transitions[0].Healthy)
+		assert.False(t, transitions[1].Healthy)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// given
+		history := health.NewHistory(10)
+
+		// when
+		health.Monitor(context.Background(), history, 0, func(_ context.Context) map[string]error {
+			return map[string]error{"proxy": nil}
+		})
+
+		// then
+		assert.Empty(t, history.Transitions())
+	})
+}
+
+type fakeHealthChecker bool
+
+func (c fakeHealthChecker) Alive(*gin.Context) bool {
+	return bool(c)
+}
+
+func (c fakeHealthChecker) APIProxyAlive(*gin.Context) bool {
+	return bool(c)
+}
+
+func TestProxyCheck(t *testing.T) {
+	assert.Equal(t, map[string]error{"proxy": nil}, health.ProxyCheck(fakeHealthChecker(true))(context.Background()))
+	assert.EqualError(t, health.ProxyCheck(fakeHealthChecker(false))(context.Background())["proxy"], "the API proxy health check failed")
+}
+
+func TestMemberClustersCheck(t *testing.T) {
+	// given
+	newMember := func(name string, ready corev1.ConditionStatus) *cluster.CachedToolchainCluster {
+		return &cluster.CachedToolchainCluster{
+			Config: &cluster.Config{Name: name},
+			ClusterStatus: &toolchainv1alpha1.ToolchainClusterStatus{
+				Conditions: []toolchainv1alpha1.Condition{{Type: toolchainv1alpha1.ConditionReady, Status: ready}},
+			},
+		}
+	}
+	getMembersFunc := func(_ ...cluster.Condition) []*cluster.CachedToolchainCluster {
+		return []*cluster.CachedToolchainCluster{
+			newMember("member-1", corev1.ConditionTrue),
+			newMember("member-2", corev1.ConditionFalse),
+			{Config: &cluster.Config{Name: "member-3"}},
+		}
+	}
+
+	// when
+	results := health.MemberClustersCheck(getMembersFunc)(context.Background())
+
+	// then
+	require.Len(t, results, 3)
+	require.Contains(t, results, "member-cluster/member-1")
+	assert.NoError(t, results["member-cluster/member-1"])
+	assert.EqualError(t, results["member-cluster/member-2"], "the member cluster is not ready")
+	assert.EqualError(t, results["member-cluster/member-3"], "the member cluster is not ready")
+}
+
+func TestSSOCheck(t *testing.T) {
+	// given
+	sso := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/certs" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer sso.Close()
+
+	t.Run("reachable", func(t *testing.T) {
+		assert.Equal(t, map[string]error{"sso": nil}, health.SSOCheck(sso.URL+"/certs")(context.Background()))
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		assert.EqualError(t, health.SSOCheck(sso.URL + "/unavailable")(context.Background())["sso"], "unexpected status of the SSO public keys endpoint: 503 Service Unavailable")
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		assert.Error(t, health.SSOCheck("http://localhost:1/certs")(context.Background())["sso"])
+	})
+
+	t.Run("no URL", func(t *testing.T) {
+		assert.Empty(t, health.SSOCheck("")(context.Background()))
+	})
+}
@@ -149,27 +149,43 @@ func (l *Logger) InfoEchof(ctx echo.Context, msg string, args ...string) {
 	ctxFields = append(ctxFields, "url")
 	ctxFields = append(ctxFields, ctx.Request().URL)
 
+	// the IP of the client, as resolved from the X-Forwarded-For header set by the trusted proxies (if any)
+	ctxFields = append(ctxFields, "client-ip")
+	ctxFields = append(ctxFields, ctx.RealIP())
+
 	if impersonateUser, ok := ctx.Get(context.ImpersonateUser).(string); ok {
 		ctxFields = append(ctxFields, "impersonate-user", impersonateUser)
 	}
 
+	if impersonatedBy, ok := ctx.Get(context.ImpersonatedByKey).(string); ok {
+		ctxFields = append(ctxFields, "impersonated-by", impersonatedBy)
+	}
+
 	if publicViewerEnabled, ok := ctx.Get(context.PublicViewerEnabled).(bool); ok {
 		ctxFields = append(ctxFields, "public-viewer-enabled", publicViewerEnabled)
 	}
 
+	if configuration.GetRegistrationServiceConfig().Proxy().LogScrubbingEnabled() {
+		l.logr.Info(Scrub(sprintf(msg, args...)), scrubFields(ctxFields)...)
+		return
+	}
 	l.infof(ctxFields, msg, args...)
 }
 
 func (l *Logger) infof(ctx []interface{}, msg string, args ...string) {
+	l.logr.Info(sprintf(msg, args...), ctx...)
+}
+
+// sprintf formats the given message with the given arguments, if any
+func sprintf(msg string, args ...string) string {
+	if len(args) == 0 {
+		return msg
+	}
 	arguments := make([]interface{}, len(args))
 	for i, arg := range args {
 		arguments[i] = arg
 	}
-	if len(arguments) > 0 {
-		l.logr.Info(fmt.Sprintf(msg, arguments...), ctx...)
-	} else {
-		l.logr.Info(msg, ctx...)
-	}
+	return fmt.Sprintf(msg, arguments...)
 }
 
 // Error logs the error with the given message.
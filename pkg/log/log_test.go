@@ -113,6 +113,7 @@ func TestLog(t *testing.T) {
 				assert.Contains(t, value, `"workspace":"coolworkspace"`)
 				assert.Contains(t, value, `"method":"GET"`)
 				assert.Contains(t, value, `"url":"https://api-server.com/api/workspaces/path"`)
+				assert.Contains(t, value, `"client-ip":"192.0.2.1"`) // default remote address of the test requests
 
 				if tc.contains != "" {
 					assert.Contains(t, value, tc.contains)
@@ -124,6 +125,25 @@ func TestLog(t *testing.T) {
 		}
 	})
 
+	t.Run("log infoEchof scrubbed", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_PROXY_LOG_SCRUBBING_ENABLED", "true")
+		buf.Reset()
+		req := httptest.NewRequest(http.MethodGet, "https://api-server.com/api/v1/namespaces/john-dev/configmaps/john@example.com", nil)
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "jane@example.com")
+
+		// when
+		InfoEchof(ctx, "replacing the token %s", "Bearer sha256~abcdef")
+
+		// then
+		value := buf.String()
+		assert.Contains(t, value, `"msg":"replacing the token Bearer *****"`)
+		assert.Contains(t, value, `"username":"*****"`)
+		assert.Contains(t, value, `"url":"https://api-server.com/api/v1/namespaces/john-dev/configmaps/*****"`)
+		assert.NotContains(t, value, "example.com")
+	})
+
 	t.Run("log infof with no arguments", func(t *testing.T) {
 		buf.Reset()
 		rr := httptest.NewRecorder()
@@ -0,0 +1,49 @@
+package log
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// redacted replaces the sensitive data in the scrubbed texts
+const redacted = "*****"
+
+var (
+	// bearerTokenPattern matches the bearer tokens, eg. of the Authorization header, but not the mentions of a "Bearer token"
+	bearerTokenPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]{8,}`)
+	// websocketTokenPattern matches the token segments of the Sec-WebSocket-Protocol header
+	websocketTokenPattern = regexp.MustCompile(`(base64url\.bearer\.authorization\.k8s\.io\.)[A-Za-z0-9_-]+`)
+	// jwtPattern matches the JSON Web Tokens which are not prefixed, eg. in the query parameters
+	jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	// emailPattern matches the email addresses
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+)
+
+// Scrub redacts the bearer tokens (including the ones of the Sec-WebSocket-Protocol header) and the email addresses
+// of the given text, eg. of a log line or of an error message
+func Scrub(text string) string {
+	text = bearerTokenPattern.ReplaceAllString(text, "${1}"+redacted)
+	text = websocketTokenPattern.ReplaceAllString(text, "${1}"+redacted)
+	text = jwtPattern.ReplaceAllString(text, redacted)
+	return emailPattern.ReplaceAllString(text, redacted)
+}
+
+// scrubFields scrubs the values of the given key and value pairs which are strings (or can be formatted as strings)
+func scrubFields(fields []interface{}) []interface{} {
+	scrubbed := make([]interface{}, len(fields))
+	for i, field := range fields {
+		if i%2 == 0 {
+			scrubbed[i] = field
+			continue
+		}
+		switch value := field.(type) {
+		case string:
+			scrubbed[i] = Scrub(value)
+		case fmt.Stringer:
+			scrubbed[i] = Scrub(value.String())
+		default:
+			scrubbed[i] = field
+		}
+	}
+	return scrubbed
+}
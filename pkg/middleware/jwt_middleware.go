@@ -10,21 +10,25 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // JWTMiddleware is the JWT token validation middleware
 type JWTMiddleware struct {
 	tokenParser *auth.TokenParser
+	// failures counts the rejected tokens, per reason (see auth.FailureReason)
+	failures *prometheus.CounterVec
 }
 
-// NewAuthMiddleware returns a new middleware for JWT authentication
-func NewAuthMiddleware() (*JWTMiddleware, error) {
+// NewAuthMiddleware returns a new middleware for JWT authentication, which counts the rejected tokens per reason with the given counter
+func NewAuthMiddleware(failures *prometheus.CounterVec) (*JWTMiddleware, error) {
 	tokenParserInstance, err := auth.DefaultTokenParser()
 	if err != nil {
 		return nil, err
 	}
 	return &JWTMiddleware{
 		tokenParser: tokenParserInstance,
+		failures:    failures,
 	}, nil
 }
 
@@ -58,12 +62,14 @@ func (m *JWTMiddleware) HandlerFunc() gin.HandlerFunc {
 		// check if we have a token
 		tokenStr, err := m.extractToken(c)
 		if err != nil {
+			m.failures.WithLabelValues(auth.FailureReasonMissing).Inc()
 			m.respondWithError(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 		// next, check the token
 		token, err := m.tokenParser.FromString(tokenStr)
 		if err != nil {
+			m.failures.WithLabelValues(auth.FailureReason(err)).Inc()
 			m.respondWithError(c, http.StatusUnauthorized, err.Error())
 			return
 		}
@@ -90,6 +96,7 @@ func (m *JWTMiddleware) HandlerFunc() gin.HandlerFunc {
 		c.Set(context.AccountNumberKey, token.AccountNumber)
 		c.Set(context.UsernameKey, token.PreferredUsername)
 		c.Set(context.EmailKey, token.Email)
+		c.Set(context.EmailVerifiedKey, token.EmailVerified)
 		c.Set(context.SubKey, token.Subject)
 		c.Set(context.OriginalSubKey, token.OriginalSub)
 		c.Set(context.GivenNameKey, token.GivenName)
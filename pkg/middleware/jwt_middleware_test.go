@@ -38,7 +38,7 @@ func TestRunAuthMiddlewareSuite(t *testing.T) {
 
 func (s *TestAuthMiddlewareSuite) TestAuthMiddleware() {
 	s.Run("create with DefaultTokenParser failing", func() {
-		authMiddleware, err := middleware.NewAuthMiddleware()
+		authMiddleware, err := middleware.NewAuthMiddleware(prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test"}, []string{"reason"}))
 		require.Nil(s.T(), authMiddleware)
 		require.Error(s.T(), err)
 		require.Equal(s.T(), "no default TokenParser created, call `InitializeDefaultTokenParser()` first", err.Error())
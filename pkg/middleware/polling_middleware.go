@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pollingStatesRetention is the time after which the polling state of an inactive user is discarded
+const pollingStatesRetention = time.Minute
+
+type pollingState struct {
+	lastRequest time.Time
+	// burst is the number of consecutive requests received within the configured min interval
+	burst int
+}
+
+// PollingLimiter is a middleware which rejects the requests of users who poll an endpoint
+// more aggressively than allowed by the SignupPolling configuration
+type PollingLimiter struct {
+	mu        sync.Mutex
+	states    map[string]*pollingState
+	lastPrune time.Time
+}
+
+// NewPollingLimiter returns a new PollingLimiter
+func NewPollingLimiter() *PollingLimiter {
+	return &PollingLimiter{
+		states:    map[string]*pollingState{},
+		lastPrune: time.Now(),
+	}
+}
+
+// HandlerFunc returns the HandlerFunc.
+// It requires the username to be set in the context, so it needs to be executed after the auth middleware.
+func (l *PollingLimiter) HandlerFunc() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		cfg := configuration.GetRegistrationServiceConfig().SignupPolling()
+		username := ctx.GetString(context.UsernameKey)
+		if !cfg.Enabled() || username == "" {
+			ctx.Next()
+			return
+		}
+
+		if !l.allow(username, time.Now(), cfg.MinInterval(), cfg.Burst()) {
+			retryAfter := int(math.Ceil(cfg.RetryAfter().Seconds()))
+			log.Infof(ctx, "rejecting request of user '%s' polling too aggressively", username)
+			ctx.Header("Retry-After", strconv.Itoa(retryAfter))
+			crterrors.AbortWithError(ctx, http.StatusTooManyRequests, errors.New("too many requests"),
+				"the status is polled too often, retry after "+strconv.Itoa(retryAfter)+" seconds")
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// allow records the request of the given user and returns false if the user exceeded the tolerated burst
+// of requests received within the min interval.
+// Rejected requests are recorded too, so that a client which keeps polling aggressively keeps being rejected.
+func (l *PollingLimiter) allow(username string, now time.Time, minInterval time.Duration, burst int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	state, found := l.states[username]
+	if !found {
+		l.states[username] = &pollingState{lastRequest: now}
+		return true
+	}
+	if now.Sub(state.lastRequest) < minInterval {
+		state.burst++
+	} else {
+		state.burst = 0
+	}
+	state.lastRequest = now
+	return state.burst <= burst
+}
+
+// prune removes the states of the users who did not send any request recently, so that the map doesn't grow forever
+func (l *PollingLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < pollingStatesRetention {
+		return
+	}
+	for username, state := range l.states {
+		if now.Sub(state.lastRequest) > pollingStatesRetention {
+			delete(l.states, username)
+		}
+	}
+	l.lastPrune = now
+}
@@ -0,0 +1,100 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/middleware"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PollingLimiterSuite struct {
+	test.UnitTestSuite
+}
+
+func TestPollingLimiterSuite(t *testing.T) {
+	suite.Run(t, &PollingLimiterSuite{test.UnitTestSuite{}})
+}
+
+func (s *PollingLimiterSuite) TestPollingLimiter() {
+	newRouter := func(limiter *middleware.PollingLimiter) *gin.Engine {
+		router := gin.New()
+		router.GET("/api/v1/signup", func(ctx *gin.Context) {
+			ctx.Set(context.UsernameKey, ctx.GetHeader("X-Test-User"))
+		}, limiter.HandlerFunc(), func(ctx *gin.Context) {
+			ctx.Status(http.StatusOK)
+		})
+		return router
+	}
+	get := func(router *gin.Engine, username string) *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/signup", nil)
+		require.NoError(s.T(), err)
+		req.Header.Set("X-Test-User", username)
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	s.Run("burst is tolerated", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "2")
+		router := newRouter(middleware.NewPollingLimiter())
+
+		// when & then
+		for i := 0; i < 3; i++ {
+			assert.Equal(s.T(), http.StatusOK, get(router, "john").Code)
+		}
+	})
+
+	s.Run("too aggressive polling is rejected", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "1")
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_RETRY_AFTER", "1500ms")
+		router := newRouter(middleware.NewPollingLimiter())
+		require.Equal(s.T(), http.StatusOK, get(router, "john").Code)
+		require.Equal(s.T(), http.StatusOK, get(router, "john").Code)
+
+		// when
+		resp := get(router, "john")
+
+		// then
+		assert.Equal(s.T(), http.StatusTooManyRequests, resp.Code)
+		assert.Equal(s.T(), "2", resp.Header().Get("Retry-After"))
+		assert.Contains(s.T(), resp.Body.String(), "the status is polled too often, retry after 2 seconds")
+
+		s.Run("other users are not affected", func() {
+			assert.Equal(s.T(), http.StatusOK, get(router, "jane").Code)
+		})
+	})
+
+	s.Run("polling at the expected interval is allowed", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "0")
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_MIN_INTERVAL", "1ns")
+		router := newRouter(middleware.NewPollingLimiter())
+
+		// when & then
+		for i := 0; i < 5; i++ {
+			assert.Equal(s.T(), http.StatusOK, get(router, "john").Code)
+		}
+	})
+
+	s.Run("protection disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_PROTECTION_ENABLED", "false")
+		s.T().Setenv("REGISTRATION_SERVICE_SIGNUP_POLLING_BURST", "0")
+		router := newRouter(middleware.NewPollingLimiter())
+
+		// when & then
+		for i := 0; i < 5; i++ {
+			assert.Equal(s.T(), http.StatusOK, get(router, "john").Code)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetSecurityHeaders sets the security headers configured with the SecurityHeaders settings on the given headers of a response,
+// replacing the values of the headers which are already set (eg. by a member cluster, for the proxied responses)
+func SetSecurityHeaders(header http.Header) {
+	cfg := configuration.GetRegistrationServiceConfig().SecurityHeaders()
+	if !cfg.Enabled() {
+		return
+	}
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "no-referrer")
+	if maxAge := cfg.HSTSMaxAge(); maxAge > 0 {
+		header.Set("Strict-Transport-Security", "max-age="+strconv.FormatInt(int64(maxAge.Seconds()), 10)+"; includeSubDomains")
+	}
+	if csp := cfg.ContentSecurityPolicy(); csp != "" {
+		header.Set("Content-Security-Policy", csp)
+	}
+}
+
+// SecurityHeaders returns the middleware setting the security headers on the responses (see SetSecurityHeaders)
+func SecurityHeaders() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		SetSecurityHeaders(ctx.Writer.Header())
+		ctx.Next()
+	}
+}
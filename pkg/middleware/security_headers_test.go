@@ -0,0 +1,78 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/middleware"
+	"github.com/codeready-toolchain/registration-service/test"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SecurityHeadersSuite struct {
+	test.UnitTestSuite
+}
+
+func TestSecurityHeadersSuite(t *testing.T) {
+	suite.Run(t, &SecurityHeadersSuite{test.UnitTestSuite{}})
+}
+
+func (s *SecurityHeadersSuite) TestSecurityHeaders() {
+	// given
+	router := gin.New()
+	router.Use(middleware.SecurityHeaders())
+	router.GET("/api/v1/health", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+	get := func() *httptest.ResponseRecorder {
+		resp := httptest.NewRecorder()
+		req, err := http.NewRequest(http.MethodGet, "/api/v1/health", nil)
+		require.NoError(s.T(), err)
+		router.ServeHTTP(resp, req)
+		return resp
+	}
+
+	s.Run("default headers", func() {
+		// when
+		resp := get()
+
+		// then
+		assert.Equal(s.T(), "nosniff", resp.Header().Get("X-Content-Type-Options"))
+		assert.Equal(s.T(), "no-referrer", resp.Header().Get("Referrer-Policy"))
+		// not set outside of the production environment by default
+		assert.Empty(s.T(), resp.Header().Get("Strict-Transport-Security"))
+		assert.Empty(s.T(), resp.Header().Get("Content-Security-Policy"))
+	})
+
+	s.Run("configured headers", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_HSTS_MAX_AGE", "8760h")
+		s.T().Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_CONTENT_SECURITY_POLICY", "default-src 'self'")
+
+		// when
+		resp := get()
+
+		// then
+		assert.Equal(s.T(), "max-age=31536000; includeSubDomains", resp.Header().Get("Strict-Transport-Security"))
+		assert.Equal(s.T(), "default-src 'self'", resp.Header().Get("Content-Security-Policy"))
+	})
+
+	s.Run("disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_ENABLED", "false")
+		s.T().Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_HSTS_MAX_AGE", "8760h")
+
+		// when
+		resp := get()
+
+		// then
+		assert.Empty(s.T(), resp.Header().Get("X-Content-Type-Options"))
+		assert.Empty(s.T(), resp.Header().Get("Referrer-Policy"))
+		assert.Empty(s.T(), resp.Header().Get("Strict-Transport-Security"))
+	})
+}
@@ -1,6 +1,7 @@
 package access
 
 import (
+	"crypto/tls"
 	"net/url"
 )
 
@@ -12,6 +13,10 @@ type ClusterAccess struct { // nolint:revive
 	impersonatorToken string
 	// username is the id of the user to use for impersonation
 	username string
+	// clusterName is the name of the member cluster hosting the target, if known
+	clusterName string
+	// tlsConfig is the optional TLS configuration to use when dialing the target, eg. the backend of a proxy plugin
+	tlsConfig *tls.Config
 }
 
 func NewClusterAccess(apiURL url.URL, impersonatorToken, username string) *ClusterAccess {
@@ -22,6 +27,15 @@ func NewClusterAccess(apiURL url.URL, impersonatorToken, username string) *Clust
 	}
 }
 
+// NewMemberClusterAccess creates a ClusterAccess to a target hosted by the given member cluster,
+// which must be dialed with the given TLS configuration (if any)
+func NewMemberClusterAccess(clusterName string, apiURL url.URL, impersonatorToken, username string, tlsConfig *tls.Config) *ClusterAccess {
+	a := NewClusterAccess(apiURL, impersonatorToken, username)
+	a.clusterName = clusterName
+	a.tlsConfig = tlsConfig
+	return a
+}
+
 func (a *ClusterAccess) APIURL() url.URL {
 	return a.apiURL
 }
@@ -33,3 +47,14 @@ func (a *ClusterAccess) ImpersonatorToken() string {
 func (a *ClusterAccess) Username() string {
 	return a.username
 }
+
+// ClusterName returns the name of the member cluster hosting the target, or an empty string if unknown
+func (a *ClusterAccess) ClusterName() string {
+	return a.clusterName
+}
+
+// TLSConfig returns the TLS configuration to use when dialing the target,
+// or nil if the default transport policy applies
+func (a *ClusterAccess) TLSConfig() *tls.Config {
+	return a.tlsConfig
+}
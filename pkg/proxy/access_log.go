@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+const redacted = "REDACTED"
+
+// AccessLogEntry is the entry of the access log written for each request received by the proxy
+type AccessLogEntry struct {
+	Time      time.Time `json:"ts"`
+	User      string    `json:"user"`
+	Workspace string    `json:"workspace"`
+	Member    string    `json:"member"`
+	Verb      string    `json:"verb"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	// Duration is the time taken to handle the request, in seconds
+	Duration float64 `json:"duration"`
+	// Object is the object of a mutating request to a member cluster, if audited (see the AuditMutations setting)
+	Object *AuditedObject `json:"object,omitempty"`
+	// ImpersonatedBy is the name of the admin who sent the request as the User, if any (see ImpersonateUserHeader).
+	// The impersonated requests are always written, whatever the sampling.
+	ImpersonatedBy string `json:"impersonatedBy,omitempty"`
+}
+
+// AuditedObject is the object of a mutating request to a member cluster, so that the operators can find out who created, changed or
+// deleted an object through the proxy. The payloads of the requests are never written to the access log.
+type AuditedObject struct {
+	// Resource is the resource of the request, with its subresource if any, eg. 'deployments' or 'pods/exec'
+	Resource string `json:"resource"`
+	// Kind is the kind of the object of the request body, if any
+	Kind      string `json:"kind,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// maxAuditedBodySize is the maximum size of the request bodies parsed to find the kind, the namespace and the name of the audited objects
+const maxAuditedBodySize = 64 * 1024
+
+// AccessLogger writes one JSON line per request received by the proxy
+type AccessLogger struct {
+	mu             sync.Mutex
+	out            io.Writer
+	sampling       uint64
+	count          atomic.Uint64
+	redactedFields []string
+	auditMutations bool
+}
+
+// redactableFields are the fields of the access log entries which can be redacted
+var redactableFields = []string{"user", "workspace", "member", "path", "object"}
+
+// NewAccessLogger returns a new AccessLogger writing to the given writer, with the given configuration
+func NewAccessLogger(out io.Writer, cfg configuration.AccessLogConfig) *AccessLogger {
+	redactedFields := cfg.RedactedFields()
+	for _, field := range redactedFields {
+		if !slices.Contains(redactableFields, field) {
+			log.Infof(nil, "ignoring the redaction of the unknown access log field '%s'", field)
+		}
+	}
+	return &AccessLogger{
+		out:            out,
+		sampling:       uint64(cfg.Sampling()),
+		redactedFields: redactedFields,
+		auditMutations: cfg.AuditMutations(),
+	}
+}
+
+// sampled returns true if the request with the given status should be written, according to the sampling of the logger
+func (l *AccessLogger) sampled(status int) bool {
+	// count all the requests so that the sampling rate doesn't depend on the rate of the errors
+	n := l.count.Add(1)
+	return status >= http.StatusInternalServerError || (n-1)%l.sampling == 0
+}
+
+func (l *AccessLogger) write(entry AccessLogEntry) {
+	for _, field := range l.redactedFields {
+		switch field {
+		case "user":
+			entry.User = redacted
+			if entry.ImpersonatedBy != "" {
+				entry.ImpersonatedBy = redacted
+			}
+		case "workspace":
+			entry.Workspace = redacted
+		case "member":
+			entry.Member = redacted
+		case "path":
+			entry.Path = redacted
+		case "object":
+			if entry.Object != nil {
+				object := *entry.Object
+				object.Namespace, object.Name = redacted, redacted
+				entry.Object = &object
+			}
+		}
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Error(nil, err, "unable to marshal the access log entry")
+		return
+	}
+	line = []byte(scrub(string(line)))
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.out.Write(append(line, '\n')); err != nil {
+		log.Error(nil, err, "unable to write the access log entry")
+	}
+}
+
+// accessLog writes the access log entry of the request once it is handled, if the access log is enabled.
+// The middleware needs to be executed before routing, so that the requests rejected by the other Pre middlewares are logged as well.
+func (p *Proxy) accessLog() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if p.accessLogger == nil || probeEndpoint(ctx.Request().URL.Path) {
+				return next(ctx)
+			}
+			start := time.Now()
+			// the path is captured before the plugin and workspace prefixes are removed
+			path := ctx.Request().URL.Path
+			var object *AuditedObject
+			if p.accessLogger.auditMutations && isMutatingRequest(ctx.Request()) && !strings.HasPrefix(path, pluginsEndpoint) {
+				object = auditedObjectOf(ctx.Request())
+			}
+			if err := next(ctx); err != nil {
+				// write the error response now, so that its status and size are known
+				ctx.Error(err)
+			}
+			status := ctx.Response().Status
+			impersonatedBy, _ := ctx.Get(context.ImpersonatedByKey).(string)
+			if !p.accessLogger.sampled(status) && object == nil && impersonatedBy == "" {
+				return nil
+			}
+			if object != nil {
+				// the path of the request no longer has the workspace prefix, if it was routed
+				if attributes, ok := requestAttributes(ctx.Request()); ok {
+					object.Resource = qualifiedResource(attributes)
+					object.Namespace = cmp.Or(attributes.Namespace, object.Namespace)
+					object.Name = cmp.Or(attributes.Name, object.Name)
+				}
+			}
+			username, _ := ctx.Get(context.UsernameKey).(string)
+			workspace, _ := ctx.Get(context.WorkspaceKey).(string)
+			member, _ := ctx.Get(context.TargetClusterKey).(string)
+			p.accessLogger.write(AccessLogEntry{
+				Time:           start.UTC(),
+				User:           username,
+				Workspace:      workspace,
+				Member:         member,
+				Verb:           ctx.Request().Method,
+				Path:           path,
+				Status:         status,
+				Bytes:          ctx.Response().Size,
+				Duration:       time.Since(start).Seconds(),
+				Object:         object,
+				ImpersonatedBy: impersonatedBy,
+			})
+			return nil
+		}
+	}
+}
+
+// isMutatingRequest returns true if the given request may create, change or delete an object
+func isMutatingRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// auditedObjectOf returns the object of the given mutating request, with the kind, the namespace and the name of the JSON body
+// of the request if any. The body is restored, so that it's still forwarded to the member cluster.
+func auditedObjectOf(req *http.Request) *AuditedObject {
+	object := &AuditedObject{}
+	if req.Body == nil || req.Body == http.NoBody || !strings.Contains(req.Header.Get("Content-Type"), "json") {
+		return object
+	}
+	prefix, err := io.ReadAll(io.LimitReader(req.Body, maxAuditedBodySize+1))
+	req.Body = &prefixedBody{
+		Reader: io.MultiReader(bytes.NewReader(prefix), req.Body),
+		Closer: req.Body,
+	}
+	if err != nil || len(prefix) > maxAuditedBodySize {
+		return object
+	}
+	body := struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"metadata"`
+	}{}
+	// the body may also be a JSON patch, ie. an array without any kind nor metadata
+	_ = json.Unmarshal(prefix, &body)
+	object.Kind = body.Kind
+	object.Namespace = body.Metadata.Namespace
+	object.Name = body.Metadata.Name
+	return object
+}
+
+// prefixedBody is a request body whose beginning was already read
+type prefixedBody struct {
+	io.Reader
+	io.Closer
+}
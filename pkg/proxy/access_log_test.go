@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestAccessLog() {
+	newRouter := func(accessLogger *AccessLogger) *echo.Echo {
+		p := &Proxy{accessLogger: accessLogger}
+		router := echo.New()
+		router.HTTPErrorHandler = customHTTPErrorHandler
+		router.Pre(p.accessLog())
+		router.GET(proxyHealthEndpoint, health)
+		router.Any("/*", func(ctx echo.Context) error {
+			if strings.HasSuffix(ctx.Request().URL.Path, "/forbidden") {
+				return crterrors.NewForbiddenError("invalid workspace request", "access forbidden")
+			}
+			if strings.HasSuffix(ctx.Request().URL.Path, "/unavailable") {
+				return crterrors.NewInternalError(assert.AnError, "unavailable")
+			}
+			ctx.Set(context.UsernameKey, "smith")
+			ctx.Set(context.WorkspaceKey, "smith-ws")
+			ctx.Set(context.TargetClusterKey, "member-1")
+			return ctx.String(http.StatusOK, "pods")
+		})
+		return router
+	}
+	serve := func(router *echo.Echo, method, path string) {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, path, nil))
+	}
+	entries := func(buf *bytes.Buffer) []AccessLogEntry {
+		result := []AccessLogEntry{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			entry := AccessLogEntry{}
+			require.NoError(s.T(), json.Unmarshal([]byte(line), &entry))
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	s.Run("one entry per request", func() {
+		// given
+		buf := &bytes.Buffer{}
+		router := newRouter(NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog()))
+
+		// when
+		serve(router, http.MethodGet, "/workspaces/smith-ws/api/v1/pods")
+		serve(router, http.MethodDelete, "/workspaces/other/forbidden")
+		serve(router, http.MethodGet, proxyHealthEndpoint)
+
+		// then
+		logged := entries(buf)
+		require.Len(s.T(), logged, 2)
+		assert.Equal(s.T(), "smith", logged[0].User)
+		assert.Equal(s.T(), "smith-ws", logged[0].Workspace)
+		assert.Equal(s.T(), "member-1", logged[0].Member)
+		assert.Equal(s.T(), http.MethodGet, logged[0].Verb)
+		assert.Equal(s.T(), "/workspaces/smith-ws/api/v1/pods", logged[0].Path)
+		assert.Equal(s.T(), http.StatusOK, logged[0].Status)
+		assert.Equal(s.T(), int64(len("pods")), logged[0].Bytes)
+		assert.False(s.T(), logged[0].Time.IsZero())
+		assert.Equal(s.T(), http.MethodDelete, logged[1].Verb)
+		assert.Equal(s.T(), "/workspaces/other/forbidden", logged[1].Path)
+		assert.Equal(s.T(), http.StatusForbidden, logged[1].Status)
+		assert.Positive(s.T(), logged[1].Bytes)
+	})
+
+	s.Run("sampling", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_SAMPLING", "3")
+		buf := &bytes.Buffer{}
+		router := newRouter(NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog()))
+
+		// when
+		for i := 0; i < 6; i++ {
+			serve(router, http.MethodGet, "/api/v1/pods")
+		}
+		serve(router, http.MethodGet, "/api/v1/unavailable")
+
+		// then only 1 in 3 requests is logged, but the server errors are always logged
+		logged := entries(buf)
+		require.Len(s.T(), logged, 3)
+		assert.Equal(s.T(), http.StatusInternalServerError, logged[2].Status)
+	})
+
+	s.Run("redacted fields", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_REDACTED_FIELDS", "user, path,unknown")
+		buf := &bytes.Buffer{}
+		router := newRouter(NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog()))
+
+		// when
+		serve(router, http.MethodGet, "/workspaces/smith-ws/api/v1/pods")
+
+		// then
+		logged := entries(buf)
+		require.Len(s.T(), logged, 1)
+		assert.Equal(s.T(), "REDACTED", logged[0].User)
+		assert.Equal(s.T(), "REDACTED", logged[0].Path)
+		assert.Equal(s.T(), "smith-ws", logged[0].Workspace)
+	})
+
+	s.Run("scrubbed", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOG_SCRUBBING_ENABLED", "true")
+		buf := &bytes.Buffer{}
+		router := newRouter(NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog()))
+
+		// when
+		serve(router, http.MethodGet, "/workspaces/smith-ws/api/v1/namespaces/smith-ws/configmaps/john@example.com")
+
+		// then
+		logged := entries(buf)
+		require.Len(s.T(), logged, 1)
+		assert.Equal(s.T(), "/workspaces/smith-ws/api/v1/namespaces/smith-ws/configmaps/*****", logged[0].Path)
+	})
+
+	s.Run("mutations audited", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_SAMPLING", "100")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_AUDIT_MUTATIONS", "true")
+		buf := &bytes.Buffer{}
+		p := &Proxy{accessLogger: NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog())}
+		router := echo.New()
+		router.Pre(p.accessLog())
+		forwarded := []string{}
+		router.Any("/*", func(ctx echo.Context) error {
+			// the workspace prefix is removed before the request is forwarded
+			ctx.Request().URL.Path = strings.TrimPrefix(ctx.Request().URL.Path, "/workspaces/smith-ws")
+			body, err := io.ReadAll(ctx.Request().Body)
+			require.NoError(s.T(), err)
+			forwarded = append(forwarded, string(body))
+			return ctx.NoContent(http.StatusOK)
+		})
+		send := func(method, path, contentType, body string) {
+			req := httptest.NewRequest(method, path, strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+		}
+		deployment := `{"apiVersion":"apps/v1","kind":"Deployment","metadata":{"name":"app","namespace":"smith-dev"},"spec":{"replicas":1}}`
+
+		// when
+		send(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "", "")
+		send(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "", "")
+		send(http.MethodPost, "/workspaces/smith-ws/apis/apps/v1/namespaces/smith-dev/deployments", "application/json", deployment)
+		send(http.MethodDelete, "/apis/apps/v1/namespaces/smith-dev/deployments/app", "", "")
+		send(http.MethodPatch, "/api/v1/namespaces/smith-dev/configmaps/config", "application/json-patch+json", `[{"op":"remove","path":"/data/key"}]`)
+		send(http.MethodPost, "/api/v1/namespaces/smith-dev/configmaps", "application/json", `{"kind":"ConfigMap","metadata":{"name":"`+strings.Repeat("a", maxAuditedBodySize)+`"}}`)
+		send(http.MethodPost, "/plugins/tekton-results/api/v1/results", "application/json", `{"kind":"Result"}`)
+
+		// then only the first request is sampled, but all the mutating requests are logged along with their object
+		logged := entries(buf)
+		require.Len(s.T(), logged, 5)
+		assert.Nil(s.T(), logged[0].Object)
+		assert.Equal(s.T(), &AuditedObject{Resource: "deployments", Kind: "Deployment", Namespace: "smith-dev", Name: "app"}, logged[1].Object)
+		assert.Equal(s.T(), &AuditedObject{Resource: "deployments", Namespace: "smith-dev", Name: "app"}, logged[2].Object)
+		assert.Equal(s.T(), &AuditedObject{Resource: "configmaps", Namespace: "smith-dev", Name: "config"}, logged[3].Object)
+		assert.Equal(s.T(), &AuditedObject{Resource: "configmaps", Namespace: "smith-dev"}, logged[4].Object, "the body is too large to be parsed")
+		assert.NotContains(s.T(), buf.String(), "tekton-results", "the requests to the proxy plugins are not audited")
+		// and the bodies are still forwarded
+		assert.Equal(s.T(), deployment, forwarded[2])
+		assert.Len(s.T(), forwarded[5], maxAuditedBodySize+len(`{"kind":"ConfigMap","metadata":{"name":""}}`))
+		assert.NotContains(s.T(), buf.String(), "replicas")
+	})
+
+	s.Run("access log disabled", func() {
+		// given
+		router := newRouter(nil)
+		rr := httptest.NewRecorder()
+
+		// when
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/workspaces/other/forbidden", nil))
+
+		// then the error is still handled by the error handler
+		assert.Equal(s.T(), http.StatusForbidden, rr.Code)
+	})
+}
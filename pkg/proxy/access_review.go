@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// accessReviewTimeout is the maximum duration of the SelfSubjectAccessReviews performed before forwarding the mutating requests
+const accessReviewTimeout = 5 * time.Second
+
+// mutatingVerbs are the verbs of the requests whose access is reviewed (see the PreflightAccessReviewEnabled setting)
+var mutatingVerbs = []string{"create", "update", "patch", "delete", "deletecollection"}
+
+// reviewAccess checks with a SelfSubjectAccessReview, as the impersonated user, that the user is allowed to perform the mutating
+// request to the API of the given member cluster, if the PreflightAccessReviewEnabled setting is enabled. Returns the Status
+// to respond with if the user is not allowed, explaining the missing permission, or nil if the request can be forwarded.
+// The request is forwarded when its access can't be reviewed, so that the member cluster decides.
+func (p *Proxy) reviewAccess(ctx echo.Context, target *access.ClusterAccess) *metav1.Status {
+	if !configuration.GetRegistrationServiceConfig().Proxy().PreflightAccessReviewEnabled() {
+		return nil
+	}
+	attributes, ok := requestAttributes(ctx.Request())
+	if !ok || !slices.Contains(mutatingVerbs, attributes.Verb) {
+		return nil
+	}
+	review, err := selfSubjectAccessReview(ctx.Request().Context(), target, impersonatedGroups(ctx, target), attributes)
+	if err != nil {
+		log.Error(nil, err, "unable to review the access of the user, forwarding the request")
+		return nil
+	}
+	if review.Status.Allowed {
+		return nil
+	}
+	log.InfoEchof(ctx, "rejecting the request: '%s %s' is not allowed by the member cluster", attributes.Verb, qualifiedResource(attributes))
+	return forbiddenStatus(target.Username(), attributes, review.Status.Reason)
+}
+
+// selfSubjectAccessReview creates a SelfSubjectAccessReview of the given attributes in the given member cluster,
+// impersonating the user and the groups, and returns the result
+func selfSubjectAccessReview(ctx gocontext.Context, target *access.ClusterAccess, groups []string, attributes *authorizationv1.ResourceAttributes) (*authorizationv1.SelfSubjectAccessReview, error) {
+	body, err := json.Marshal(&authorizationv1.SelfSubjectAccessReview{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "SelfSubjectAccessReview",
+			APIVersion: authorizationv1.SchemeGroupVersion.String(),
+		},
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := gocontext.WithTimeout(ctx, accessReviewTimeout)
+	defer cancel()
+	reviewURL := target.APIURL()
+	reviewURL.Path = singleJoiningSlash(reviewURL.Path, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reviewURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+target.ImpersonatorToken())
+	req.Header.Set("Impersonate-User", target.Username())
+	for _, group := range groups {
+		req.Header.Add("Impersonate-Group", group)
+	}
+	resp, err := (&http.Client{Transport: getTransport(req.Header, target.TLSConfig())}).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("unexpected status of the SelfSubjectAccessReview: %d", resp.StatusCode)
+	}
+	review := &authorizationv1.SelfSubjectAccessReview{}
+	if err := json.NewDecoder(resp.Body).Decode(review); err != nil {
+		return nil, fmt.Errorf("unable to decode the SelfSubjectAccessReview: %w", err)
+	}
+	return review, nil
+}
+
+// forbiddenStatus returns the Status of the response to a request with the given attributes which the given user is not allowed
+// to perform, similar to the one of the Kubernetes API, so that the clients such as kubectl display the missing permission
+func forbiddenStatus(username string, attributes *authorizationv1.ResourceAttributes, reason string) *metav1.Status {
+	message := fmt.Sprintf("User %q cannot %s resource %q in API group %q", username, attributes.Verb, qualifiedResource(attributes), attributes.Group)
+	if attributes.Namespace != "" {
+		message += fmt.Sprintf(" in the namespace %q", attributes.Namespace)
+	}
+	if reason != "" {
+		message += ": " + reason
+	}
+	subject := attributes.Resource
+	if attributes.Group != "" {
+		subject += "." + attributes.Group
+	}
+	if attributes.Name != "" {
+		subject += fmt.Sprintf(" %q", attributes.Name)
+	}
+	status := newStatus(http.StatusForbidden, fmt.Sprintf("%s is forbidden: %s", subject, message))
+	status.Details = &metav1.StatusDetails{
+		Name:  attributes.Name,
+		Group: attributes.Group,
+		Kind:  attributes.Resource,
+	}
+	return status
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *TestProxySuite) TestReviewAccess() {
+	// given
+	var reviews []*http.Request
+	var reviewed *authorizationv1.SelfSubjectAccessReview
+	allowed := true
+	status := http.StatusCreated
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reviews = append(reviews, r)
+		reviewed = &authorizationv1.SelfSubjectAccessReview{}
+		if !assert.NoError(s.T(), json.NewDecoder(r.Body).Decode(reviewed)) {
+			return
+		}
+		reviewed.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: allowed}
+		if !allowed {
+			reviewed.Status.Reason = "no RBAC policy matched"
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(reviewed)
+	}))
+	defer member.Close()
+	apiURL, err := url.Parse(member.URL)
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *apiURL, "clusterSAToken", "smith", nil)
+	newContext := func(method, path string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+	}
+	reset := func(isAllowed bool, withStatus int) {
+		reviews = nil
+		reviewed = nil
+		allowed = isAllowed
+		status = withStatus
+	}
+
+	s.Run("disabled", func() {
+		// given
+		reset(false, http.StatusCreated)
+
+		// when
+		result := (&Proxy{}).reviewAccess(newContext(http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec"), target)
+
+		// then
+		assert.Nil(s.T(), result)
+		assert.Empty(s.T(), reviews)
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_PREFLIGHT_ACCESS_REVIEW_ENABLED", "true")
+
+	s.Run("not a mutating request", func() {
+		// given
+		reset(false, http.StatusCreated)
+
+		// when
+		result := (&Proxy{}).reviewAccess(newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/secrets"), target)
+
+		// then
+		assert.Nil(s.T(), result)
+		assert.Empty(s.T(), reviews)
+	})
+
+	s.Run("allowed", func() {
+		// given
+		reset(true, http.StatusCreated)
+
+		// when
+		result := (&Proxy{}).reviewAccess(newContext(http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec"), target)
+
+		// then
+		assert.Nil(s.T(), result)
+		require.Len(s.T(), reviews, 1)
+		assert.Equal(s.T(), "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", reviews[0].URL.Path)
+		assert.Equal(s.T(), "Bearer clusterSAToken", reviews[0].Header.Get("Authorization"))
+		assert.Equal(s.T(), "smith", reviews[0].Header.Get("Impersonate-User"))
+		assert.Equal(s.T(), &authorizationv1.ResourceAttributes{
+			Namespace:   "smith-dev",
+			Verb:        "create",
+			Version:     "v1",
+			Resource:    "pods",
+			Subresource: "exec",
+			Name:        "app",
+		}, reviewed.Spec.ResourceAttributes)
+	})
+
+	s.Run("denied", func() {
+		// given
+		reset(false, http.StatusCreated)
+
+		// when
+		result := (&Proxy{}).reviewAccess(newContext(http.MethodDelete, "/apis/apps/v1/namespaces/smith-dev/deployments/app"), target)
+
+		// then
+		require.NotNil(s.T(), result)
+		assert.Equal(s.T(), int32(http.StatusForbidden), result.Code)
+		assert.Equal(s.T(), metav1.StatusReasonForbidden, result.Reason)
+		assert.Equal(s.T(), `deployments.apps "app" is forbidden: User "smith" cannot delete resource "deployments" in API group "apps" in the namespace "smith-dev": no RBAC policy matched`, result.Message)
+		assert.Equal(s.T(), &metav1.StatusDetails{Name: "app", Group: "apps", Kind: "deployments"}, result.Details)
+	})
+
+	s.Run("review failed", func() {
+		// given
+		reset(false, http.StatusInternalServerError)
+
+		// when
+		result := (&Proxy{}).reviewAccess(newContext(http.MethodPost, "/api/v1/namespaces/smith-dev/configmaps"), target)
+
+		// then the request is forwarded
+		assert.Nil(s.T(), result)
+		assert.Len(s.T(), reviews, 1)
+	})
+}
@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// UpstreamDurationHeader is the response header with the time (in seconds) taken by the member cluster
+	// to return the headers of the response
+	UpstreamDurationHeader = "X-Upstream-Duration"
+	// UpstreamTotalDurationTrailer is the response trailer with the time (in seconds) taken to transfer the whole response
+	// from the member cluster
+	UpstreamTotalDurationTrailer = "X-Upstream-Total-Duration"
+	// BytesTransferredTrailer is the response trailer with the number of bytes of the response body sent to the client
+	BytesTransferredTrailer = "X-Bytes-Transferred"
+)
+
+// upstreamAccounting reports the duration of the proxied requests and the number of bytes transferred to the clients,
+// so that the clients can adapt their own pacing and verify the usage data on their side
+type upstreamAccounting struct {
+	forwardedTime time.Time
+}
+
+// forwarded records the time when the request is forwarded to the member cluster
+func (a *upstreamAccounting) forwarded() {
+	a.forwardedTime = time.Now()
+}
+
+// addToResponse sets the upstream duration header of the response, and declares the trailers sent once the response body
+// is transferred. The trailers are only declared when the length of the response is unknown (ie. the response is streamed),
+// since they can't be sent along with a Content-Length over HTTP/1.1, and the Content-Length already gives the size otherwise.
+func (a *upstreamAccounting) addToResponse(resp *http.Response) error {
+	resp.Header.Set(UpstreamDurationHeader, formatSeconds(time.Since(a.forwardedTime)))
+	if resp.ContentLength < 0 && resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Header.Add("Trailer", UpstreamTotalDurationTrailer)
+		resp.Header.Add("Trailer", BytesTransferredTrailer)
+	}
+	return nil
+}
+
+// setTrailers sets the values of the trailers once the response body was transferred.
+// The values are ignored by the server when the trailers were not declared.
+func (a *upstreamAccounting) setTrailers(rw *echo.Response) {
+	if a.forwardedTime.IsZero() {
+		// the request was not forwarded
+		return
+	}
+	rw.Header().Set(UpstreamTotalDurationTrailer, formatSeconds(time.Since(a.forwardedTime)))
+	rw.Header().Set(BytesTransferredTrailer, strconv.FormatInt(rw.Size, 10))
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestUpstreamAccounting() {
+	// given
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/watch" {
+			// the response is streamed, so its length is unknown
+			for _, event := range []string{"added", "modified", "deleted"} {
+				_, _ = w.Write([]byte(event))
+				w.(http.Flusher).Flush()
+			}
+			return
+		}
+		_, _ = w.Write([]byte("pods"))
+	}))
+	defer upstream.Close()
+	upstreamURL, err := url.Parse(upstream.URL)
+	require.NoError(s.T(), err)
+
+	router := echo.New()
+	router.Any("/*", func(ctx echo.Context) error {
+		accounting := &upstreamAccounting{}
+		reverseProxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+		director := reverseProxy.Director
+		reverseProxy.Director = func(req *http.Request) {
+			director(req)
+			accounting.forwarded()
+		}
+		reverseProxy.ModifyResponse = accounting.addToResponse
+		reverseProxy.ServeHTTP(ctx.Response(), ctx.Request())
+		accounting.setTrailers(ctx.Response())
+		return nil
+	})
+	proxy := httptest.NewServer(router)
+	defer proxy.Close()
+
+	s.Run("streamed response", func() {
+		// when
+		resp, err := http.Get(proxy.URL + "/watch")
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+
+		// then
+		assert.Equal(s.T(), "addedmodifieddeleted", string(body))
+		assertSeconds(s, resp.Header.Get(UpstreamDurationHeader))
+		assertSeconds(s, resp.Trailer.Get(UpstreamTotalDurationTrailer))
+		assert.Equal(s.T(), strconv.Itoa(len(body)), resp.Trailer.Get(BytesTransferredTrailer))
+	})
+
+	s.Run("response with a known length", func() {
+		// when
+		resp, err := http.Get(proxy.URL + "/pods")
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(s.T(), err)
+
+		// then
+		assertSeconds(s, resp.Header.Get(UpstreamDurationHeader))
+		assert.Equal(s.T(), int64(len("pods")), resp.ContentLength)
+		assert.Empty(s.T(), resp.Trailer)
+	})
+}
+
+func assertSeconds(s *TestProxySuite, value string) {
+	seconds, err := strconv.ParseFloat(value, 64)
+	require.NoError(s.T(), err, "invalid duration '%s'", value)
+	assert.GreaterOrEqual(s.T(), seconds, 0.0)
+}
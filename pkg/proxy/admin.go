@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+)
+
+// isProxyAdmin returns true if the user of the given name is an admin of the proxy (see the Admins setting)
+func isProxyAdmin(username string) bool {
+	return username != "" && slices.Contains(configuration.GetRegistrationServiceConfig().Proxy().Admins(), username)
+}
+
+// checkProxyAdmin returns an error if the user of the request is not allowed to use the admin endpoints of the proxy, ie. if the
+// request is sent with a personal access token or as another user, or if the user is not an admin of the proxy
+func checkProxyAdmin(ctx echo.Context) error {
+	if err := checkNoPersonalAccessToken(ctx); err != nil {
+		return err
+	}
+	if err := checkNotImpersonated(ctx); err != nil {
+		return err
+	}
+	if username, _ := ctx.Get(context.UsernameKey).(string); !isProxyAdmin(username) {
+		return crterrors.NewForbiddenError("invalid admin request", "the user is not an admin of the proxy")
+	}
+	return nil
+}
+
+// addAdminRoutes adds the admin routes of the proxy to the given server, which is the admin server (see StartMetricsServer),
+// so that the admin endpoints are not exposed on the listeners of the proxy. The user of the request is retrieved from the token,
+// as on the listeners of the proxy, and each handler checks that the user is an admin with checkProxyAdmin.
+func (p *Proxy) addAdminRoutes(srv *echo.Echo) {
+	srv.HTTPErrorHandler = customHTTPErrorHandler
+	admin := []echo.MiddlewareFunc{p.addUserContext(), p.ensureUserIsNotBanned()}
+	// routes to register and deregister the endpoints of the member clusters at runtime
+	srv.GET(memberRegistryEndpoint, p.listRegisteredMembers, admin...)
+	srv.PUT(memberRegistryEndpoint+"/:name", p.registerMember, admin...)
+	srv.DELETE(memberRegistryEndpoint+"/:name", p.deregisterMember, admin...)
+	// routes to revoke tokens and subjects at runtime
+	srv.GET(revocationsEndpoint, p.listRevocations, admin...)
+	srv.PUT(revocationsEndpoint+"/:kind/:value", p.revoke, admin...)
+	srv.DELETE(revocationsEndpoint+"/:kind/:value", p.liftRevocation, admin...)
+	// routes to capture the requests of a user at runtime
+	srv.GET(captureEndpoint, p.getCapture, admin...)
+	srv.PUT(captureEndpoint, p.setCapture, admin...)
+	srv.DELETE(captureEndpoint, p.deleteCapture, admin...)
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+// ImpersonateUserHeader is the header of the requests which the proxy handles as if they were sent by the given user instead of
+// the user of the token, eg. for the support and the abuse investigations. The header is only honored for the admins allowed with
+// the Admins and the ImpersonationAdminClaim settings, and is never forwarded.
+const ImpersonateUserHeader = "X-Impersonate-User"
+
+// ImpersonatedByHeader is the header of the responses to the requests sent by an admin as another user, with the name of the admin
+const ImpersonatedByHeader = "X-Impersonated-By"
+
+// isImpersonationAdmin returns true if the user of the given name and claims is allowed to send requests as another user
+func isImpersonationAdmin(username string, claims *auth.TokenClaims) bool {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	if username == "" || claims == nil || auth.IsPersonalAccessTokenClaims(claims) {
+		return false
+	}
+	if isProxyAdmin(username) {
+		return true
+	}
+	claim, value := cfg.ImpersonationAdminClaim()
+	return claim != "" && slices.Contains(claims.StringValues(claim), value)
+}
+
+// impersonateUser handles the requests with the ImpersonateUserHeader as if they were sent by the user of the header, if the user
+// of the token is an impersonation admin. The impersonated requests are always audited: an entry is written to the log and to the
+// access log (whatever its sampling), and the ImpersonatedByHeader is set on the response.
+// This Middleware requires the context to contain the user of the token, so it needs to be executed after the `addUserContext` Middleware.
+func (p *Proxy) impersonateUser() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			impersonated := ctx.Request().Header.Get(ImpersonateUserHeader)
+			if impersonated == "" {
+				return next(ctx)
+			}
+			ctx.Request().Header.Del(ImpersonateUserHeader)
+			if unsecured(ctx) {
+				return next(ctx)
+			}
+			admin, _ := ctx.Get(context.UsernameKey).(string)
+			claims, _ := ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims)
+			if !isImpersonationAdmin(admin, claims) {
+				log.InfoEchof(ctx, "denying the impersonation of '%s'", impersonated)
+				return crterrors.NewForbiddenError("invalid impersonation request", fmt.Sprintf("the user is not allowed to send requests with the %s header", ImpersonateUserHeader))
+			}
+			// the claims of the admin (eg. the groups) must not apply to the impersonated user
+			ctx.Set(context.SubKey, "")
+			ctx.Set(context.UsernameKey, impersonated)
+			ctx.Set(context.EmailKey, "")
+			ctx.Set(context.JWTClaimsKey, &auth.TokenClaims{PreferredUsername: impersonated})
+			ctx.Set(context.ImpersonatedByKey, admin)
+			ctx.Response().Header().Set(ImpersonatedByHeader, admin)
+			log.InfoEchof(ctx, "audit: '%s' is sending the request as '%s'", admin, impersonated)
+			return next(ctx)
+		}
+	}
+}
+
+// checkNotImpersonated returns a Forbidden error if the request was sent by an admin as another user, since the impersonated
+// requests can't be used for the admin endpoints of the proxy
+func checkNotImpersonated(ctx echo.Context) error {
+	if impersonatedBy, _ := ctx.Get(context.ImpersonatedByKey).(string); impersonatedBy != "" {
+		return crterrors.NewForbiddenError("invalid admin request", "the impersonated requests can't be used for the admin endpoints")
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestImpersonateUser() {
+	// given
+	newRouter := func(buf *bytes.Buffer, claims string) *echo.Echo {
+		p := &Proxy{accessLogger: NewAccessLogger(buf, configuration.GetRegistrationServiceConfig().Proxy().AccessLog())}
+		router := echo.New()
+		router.HTTPErrorHandler = customHTTPErrorHandler
+		router.Pre(
+			p.accessLog(),
+			func(next echo.HandlerFunc) echo.HandlerFunc {
+				return func(ctx echo.Context) error {
+					token := &auth.TokenClaims{}
+					require.NoError(s.T(), json.Unmarshal([]byte(claims), token))
+					ctx.Set(context.UsernameKey, token.PreferredUsername)
+					ctx.Set(context.JWTClaimsKey, token)
+					return next(ctx)
+				}
+			},
+			p.impersonateUser(),
+		)
+		router.Any("/*", func(ctx echo.Context) error {
+			token, _ := ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims)
+			username, _ := ctx.Get(context.UsernameKey).(string)
+			return ctx.String(http.StatusOK, username+"|"+strings.Join(token.StringValues("groups"), ",")+"|"+ctx.Request().Header.Get(ImpersonateUserHeader))
+		})
+		return router
+	}
+	serve := func(router *echo.Echo, impersonated string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil)
+		if impersonated != "" {
+			req.Header.Set(ImpersonateUserHeader, impersonated)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ACCESS_LOG_SAMPLING", "100")
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATION_ADMIN_CLAIM", "roles=sandbox-support")
+
+	s.Run("impersonated", func() {
+		for name, claims := range map[string]string{
+			"admin by name":  `{"preferred_username":"admin","groups":["admins"]}`,
+			"admin by claim": `{"preferred_username":"support","groups":["admins"],"roles":["dev","sandbox-support"]}`,
+		} {
+			s.Run(name, func() {
+				// given
+				buf := &bytes.Buffer{}
+				router := newRouter(buf, claims)
+				serve(router, "") // the first request is always logged, whatever the sampling
+
+				// when
+				rec := serve(router, "smith")
+
+				// then the claims of the admin don't apply, and the header is not forwarded
+				require.Equal(s.T(), http.StatusOK, rec.Code)
+				assert.Equal(s.T(), "smith||", rec.Body.String())
+				admin := rec.Header().Get(ImpersonatedByHeader)
+				assert.NotEmpty(s.T(), admin)
+				lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+				require.Len(s.T(), lines, 2)
+				entry := AccessLogEntry{}
+				require.NoError(s.T(), json.Unmarshal([]byte(lines[1]), &entry))
+				assert.Equal(s.T(), "smith", entry.User)
+				assert.Equal(s.T(), admin, entry.ImpersonatedBy)
+			})
+		}
+	})
+
+	s.Run("not impersonated", func() {
+		for name, claims := range map[string]string{
+			"not an admin":          `{"preferred_username":"smith","roles":["dev"]}`,
+			"personal access token": `{"preferred_username":"admin","iss":"registration-service"}`,
+			"anonymous":             `{}`,
+		} {
+			s.Run(name, func() {
+				// given
+				router := newRouter(&bytes.Buffer{}, claims)
+
+				// when
+				rec := serve(router, "john")
+
+				// then
+				assert.Equal(s.T(), http.StatusForbidden, rec.Code)
+				assert.Empty(s.T(), rec.Header().Get(ImpersonatedByHeader))
+			})
+		}
+	})
+
+	s.Run("no header", func() {
+		// given
+		router := newRouter(&bytes.Buffer{}, `{"preferred_username":"admin","groups":["admins"]}`)
+
+		// when
+		rec := serve(router, "")
+
+		// then
+		require.Equal(s.T(), http.StatusOK, rec.Code)
+		assert.Equal(s.T(), "admin|admins|", rec.Body.String())
+		assert.Empty(s.T(), rec.Header().Get(ImpersonatedByHeader))
+	})
+
+	s.Run("admin endpoints refused", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "smith")
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, revocationsEndpoint, nil), httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "smith")
+		ctx.Set(context.ImpersonatedByKey, "admin")
+
+		// when
+		err := checkProxyAdmin(ctx)
+
+		// then
+		require.EqualError(s.T(), err, "invalid admin request: the impersonated requests can't be used for the admin endpoints")
+	})
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestCheckProxyAdmin() {
+	// given
+	newContext := func(username string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, revocationsEndpoint, nil), httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, username)
+		return ctx
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin1, admin2")
+
+	s.Run("admin", func() {
+		for _, username := range []string{"admin1", "admin2"} {
+			s.Run(username, func() {
+				// when
+				err := checkProxyAdmin(newContext(username))
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+
+	s.Run("not an admin", func() {
+		for _, username := range []string{"smith", ""} {
+			s.Run(username, func() {
+				// when
+				err := checkProxyAdmin(newContext(username))
+
+				// then
+				require.EqualError(s.T(), err, "invalid admin request: the user is not an admin of the proxy")
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestAddAdminRoutes() {
+	// given
+	p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+	srv := echo.New()
+	p.addAdminRoutes(srv)
+
+	for _, path := range []string{memberRegistryEndpoint, revocationsEndpoint, captureEndpoint} {
+		s.Run(path, func() {
+			// when
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+			// then
+			// the admin routes require the token of the user, as on the listeners of the proxy
+			assert.Equal(s.T(), http.StatusUnauthorized, rec.Code)
+			assert.Contains(s.T(), rec.Body.String(), "no token found")
+		})
+	}
+}
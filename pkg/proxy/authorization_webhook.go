@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// authorizationWebhookTimeout is the maximum duration of a call to the authorization webhook
+	authorizationWebhookTimeout = 5 * time.Second
+	// maxAuthorizationDecisions is the maximum number of decisions of the authorization webhook in the cache
+	maxAuthorizationDecisions = 10000
+)
+
+// AuthorizationRequest is the body of the requests sent to the authorization webhook (see the AuthorizationWebhookURL setting)
+type AuthorizationRequest struct {
+	// User is the name of the user of the request
+	User string `json:"user"`
+	// Workspace is the name of the workspace targeted by the request
+	Workspace string `json:"workspace"`
+	// Plugin is the name of the proxy plugin targeted by the request, if any
+	Plugin string `json:"plugin,omitempty"`
+	// Verb is the verb of the request, as the verbs of the RBAC rules, eg. 'get', 'list' or 'create'
+	Verb string `json:"verb"`
+	// Path is the path of the request forwarded to the member cluster (or to the proxy plugin backend), without the plugin
+	// and workspace prefixes
+	Path string `json:"path"`
+}
+
+// AuthorizationResponse is the body of the responses of the authorization webhook
+type AuthorizationResponse struct {
+	// Allowed is true if the request can be forwarded
+	Allowed bool `json:"allowed"`
+	// Reason is the reason why the request is denied, returned to the user
+	Reason string `json:"reason,omitempty"`
+}
+
+type authorizationDecision struct {
+	AuthorizationResponse
+	expiresAt time.Time
+}
+
+// AuthorizationWebhook asks an external policy service whether the requests can be forwarded (see the AuthorizationWebhookURL
+// setting), and caches its decisions for the AuthorizationWebhookCacheTTL
+type AuthorizationWebhook struct {
+	client    *http.Client
+	mu        sync.Mutex
+	decisions map[AuthorizationRequest]authorizationDecision
+}
+
+// NewAuthorizationWebhook returns a new AuthorizationWebhook, without any decision
+func NewAuthorizationWebhook() *AuthorizationWebhook {
+	return &AuthorizationWebhook{
+		client:    &http.Client{Timeout: authorizationWebhookTimeout},
+		decisions: map[AuthorizationRequest]authorizationDecision{},
+	}
+}
+
+// authorize returns the decision of the webhook of the given URL about the given request, from the cache if it's not expired at the given time
+func (w *AuthorizationWebhook) authorize(ctx gocontext.Context, webhookURL string, request AuthorizationRequest, now time.Time) (AuthorizationResponse, error) {
+	w.mu.Lock()
+	decision, found := w.decisions[request]
+	w.mu.Unlock()
+	if found && now.Before(decision.expiresAt) {
+		return decision.AuthorizationResponse, nil
+	}
+	response, err := w.call(ctx, webhookURL, request)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	if ttl := configuration.GetRegistrationServiceConfig().Proxy().AuthorizationWebhookCacheTTL(); ttl > 0 {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if len(w.decisions) >= maxAuthorizationDecisions {
+			for cached, decision := range w.decisions {
+				if !now.Before(decision.expiresAt) {
+					delete(w.decisions, cached)
+				}
+			}
+			if len(w.decisions) >= maxAuthorizationDecisions {
+				clear(w.decisions)
+			}
+		}
+		w.decisions[request] = authorizationDecision{AuthorizationResponse: response, expiresAt: now.Add(ttl)}
+	}
+	return response, nil
+}
+
+// call sends the given request to the webhook of the given URL and returns its decision
+func (w *AuthorizationWebhook) call(ctx gocontext.Context, webhookURL string, request AuthorizationRequest) (AuthorizationResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return AuthorizationResponse{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return AuthorizationResponse{}, fmt.Errorf("unexpected status of the authorization webhook: %d", resp.StatusCode)
+	}
+	response := AuthorizationResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return AuthorizationResponse{}, fmt.Errorf("unable to decode the response of the authorization webhook: %w", err)
+	}
+	return response, nil
+}
+
+// checkAuthorizationWebhook returns a Forbidden error if the authorization webhook (if configured) denies the request, once its
+// workspace is resolved, or a ServiceUnavailable error if the webhook can't decide, unless the AuthorizationWebhookFailOpen
+// setting is enabled
+func (p *Proxy) checkAuthorizationWebhook(ctx echo.Context, proxyPluginName string) error {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	webhookURL := cfg.AuthorizationWebhookURL()
+	if webhookURL == "" {
+		return nil
+	}
+	req := ctx.Request()
+	username, _ := ctx.Get(context.UsernameKey).(string)
+	request := AuthorizationRequest{
+		User:      username,
+		Workspace: targetWorkspace(ctx),
+		Plugin:    proxyPluginName,
+		Verb:      methodVerb(req.Method),
+		Path:      req.URL.Path,
+	}
+	if attributes, ok := requestAttributes(req); ok && proxyPluginName == "" {
+		request.Verb = attributes.Verb
+	}
+	response, err := p.authorizationWebhook.authorize(req.Context(), webhookURL, request, time.Now())
+	switch {
+	case err != nil && cfg.AuthorizationWebhookFailOpen():
+		log.Error(nil, err, "unable to call the authorization webhook, forwarding the request")
+		return nil
+	case err != nil:
+		log.Error(nil, err, "unable to call the authorization webhook, rejecting the request")
+		return crterrors.NewServiceUnavailableError("unable to authorize the request", "the authorization service is unavailable")
+	case !response.Allowed:
+		log.InfoEchof(ctx, "denying the request: '%s %s' is denied by the authorization webhook", request.Verb, request.Path)
+		reason := response.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("'%s %s' is not allowed", request.Verb, request.Path)
+		}
+		return crterrors.NewForbiddenError("request denied by policy", reason)
+	}
+	return nil
+}
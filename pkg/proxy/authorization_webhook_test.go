@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	gocontext "context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestCheckAuthorizationWebhook() {
+	// given
+	var calls atomic.Int32
+	received := make(chan AuthorizationRequest, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		request := AuthorizationRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			rw.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- request
+		switch request.Workspace {
+		case "unavailable":
+			rw.WriteHeader(http.StatusInternalServerError)
+		case "alice-dev":
+			_ = json.NewEncoder(rw).Encode(AuthorizationResponse{Allowed: false, Reason: "the workspace is frozen"})
+		case "bob-dev":
+			_ = json.NewEncoder(rw).Encode(AuthorizationResponse{Allowed: false})
+		default:
+			_ = json.NewEncoder(rw).Encode(AuthorizationResponse{Allowed: true})
+		}
+	}))
+	defer webhook.Close()
+	newContext := func(method, path, workspace string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "smith")
+		ctx.Set(context.WorkspaceKey, workspace)
+		return ctx
+	}
+	assertError := func(err error, code int, details string) {
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), code, crtErr.Code)
+		assert.Equal(s.T(), details, crtErr.Details)
+	}
+
+	s.Run("no webhook", func() {
+		// given
+		p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+		// when
+		err := p.checkAuthorizationWebhook(newContext(http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "smith-dev"), "")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Zero(s.T(), calls.Load())
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_URL", webhook.URL)
+
+	s.Run("allowed", func() {
+		// given
+		p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+		// when
+		err := p.checkAuthorizationWebhook(newContext(http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "smith-dev"), "")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), AuthorizationRequest{
+			User:      "smith",
+			Workspace: "smith-dev",
+			Verb:      "delete",
+			Path:      "/api/v1/namespaces/smith-dev/pods/app",
+		}, <-received)
+
+		s.Run("decision cached", func() {
+			// given
+			before := calls.Load()
+
+			// when
+			err := p.checkAuthorizationWebhook(newContext(http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "smith-dev"), "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), before, calls.Load())
+		})
+
+		s.Run("decision expired", func() {
+			// given
+			before := calls.Load()
+			request := AuthorizationRequest{User: "smith", Workspace: "smith-dev", Verb: "delete", Path: "/api/v1/namespaces/smith-dev/pods/app"}
+
+			// when
+			_, err := p.authorizationWebhook.authorize(gocontext.TODO(), webhook.URL, request, time.Now().Add(time.Minute))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), before+1, calls.Load())
+			<-received
+		})
+	})
+
+	s.Run("proxy plugin", func() {
+		// given
+		p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+		// when
+		err := p.checkAuthorizationWebhook(newContext(http.MethodPost, "/api/v1/results", "smith-dev"), "tekton-results")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), AuthorizationRequest{
+			User:      "smith",
+			Workspace: "smith-dev",
+			Plugin:    "tekton-results",
+			Verb:      "create",
+			Path:      "/api/v1/results",
+		}, <-received)
+	})
+
+	s.Run("denied", func() {
+		// given
+		p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+		// when
+		err := p.checkAuthorizationWebhook(newContext(http.MethodGet, "/api/v1/namespaces/alice-dev/pods", "alice-dev"), "")
+
+		// then
+		assertError(err, http.StatusForbidden, "the workspace is frozen")
+		<-received
+
+		s.Run("without reason", func() {
+			// when
+			err := p.checkAuthorizationWebhook(newContext(http.MethodGet, "/api/v1/namespaces/bob-dev/pods", "bob-dev"), "")
+
+			// then
+			assertError(err, http.StatusForbidden, "'list /api/v1/namespaces/bob-dev/pods' is not allowed")
+			<-received
+		})
+	})
+
+	s.Run("webhook unavailable", func() {
+		s.Run("request rejected", func() {
+			// given
+			p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+			// when
+			err := p.checkAuthorizationWebhook(newContext(http.MethodGet, "/api/v1/namespaces/unavailable/pods", "unavailable"), "")
+
+			// then
+			assertError(err, http.StatusServiceUnavailable, "the authorization service is unavailable")
+			<-received
+			assert.Empty(s.T(), p.authorizationWebhook.decisions, "the failures are not cached")
+		})
+
+		s.Run("request forwarded", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_FAIL_OPEN", "true")
+			p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+
+			// when
+			err := p.checkAuthorizationWebhook(newContext(http.MethodGet, "/api/v1/namespaces/unavailable/pods", "unavailable"), "")
+
+			// then
+			require.NoError(s.T(), err)
+			<-received
+		})
+	})
+
+	s.Run("decisions not cached", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_AUTHORIZATION_WEBHOOK_CACHE_TTL", "0")
+		p := &Proxy{authorizationWebhook: NewAuthorizationWebhook()}
+		before := calls.Load()
+
+		// when
+		for i := 0; i < 2; i++ {
+			require.NoError(s.T(), p.checkAuthorizationWebhook(newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "smith-dev"), ""))
+			<-received
+		}
+
+		// then
+		assert.Equal(s.T(), before+2, calls.Load())
+		assert.Empty(s.T(), p.authorizationWebhook.decisions)
+	})
+}
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+)
+
+// bans are the hashes of the emails of the users banned recently, with the time their ban expires, so that their requests are
+// rejected even if the informer didn't see the BannedUser yet
+type bans struct {
+	mu        sync.RWMutex
+	expiresAt map[string]time.Time
+}
+
+// isBanned returns true if the user with the given hash of the email is banned at the given time
+func (b *bans) isBanned(emailHash string, now time.Time) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	expiresAt, found := b.expiresAt[emailHash]
+	return found && now.Before(expiresAt)
+}
+
+// banned records the ban of the user with the given hash of the email, until the sharedCacheBanTTL elapsed
+func (b *bans) banned(emailHash string, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.expiresAt == nil {
+		b.expiresAt = map[string]time.Time{}
+	}
+	for hash, expiresAt := range b.expiresAt {
+		if !now.Before(expiresAt) {
+			delete(b.expiresAt, hash)
+		}
+	}
+	b.expiresAt[emailHash] = now.Add(sharedCacheBanTTL)
+}
+
+// BanUser rejects the requests of the user with the given email right away, eg. once banned with the admin endpoint of the
+// registration service, instead of waiting for the informer to see the BannedUser. The ban is also published to the other
+// replicas with the shared cache, if any.
+func (p *Proxy) BanUser(ctx context.Context, email string) {
+	emailHash := hash.EncodeString(email)
+	p.recentBans.banned(emailHash, time.Now())
+	if p.sharedCache != nil {
+		p.sharedCache.publishBan(ctx, emailHash)
+	}
+}
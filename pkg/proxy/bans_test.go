@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestBanUser() {
+	// given
+	p := &Proxy{
+		// the informer didn't see the BannedUser yet
+		Client: namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs),
+	}
+	call := func(email string) error {
+		req := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil), httptest.NewRecorder())
+		req.Set(context.EmailKey, email)
+		return p.ensureUserIsNotBanned()(func(_ echo.Context) error {
+			return nil
+		})(req)
+	}
+
+	// when
+	p.BanUser(gocontext.Background(), "smith@example.com")
+
+	// then
+	err := call("smith@example.com")
+	crtErr := &crterrors.Error{}
+	require.ErrorAs(s.T(), err, &crtErr)
+	assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+	require.NoError(s.T(), call("alice@example.com"))
+	assert.False(s.T(), p.recentBans.isBanned(hash.EncodeString("smith@example.com"), time.Now().Add(sharedCacheBanTTL)))
+}
@@ -0,0 +1,118 @@
+package proxy
+
+import (
+	"io"
+	"net/http/httputil"
+	"sync"
+	"sync/atomic"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+const (
+	// defaultCopyBufferSize is the size of the copy buffers when the CopyBufferSize setting is not set, ie. the one used by io.Copy
+	defaultCopyBufferSize = 32 * 1024
+	// maxCopyBufferSize is the maximum size of the copy buffers, so that a misconfigured CopyBufferSize setting can't make each
+	// in-flight request (or each direction of an upgraded connection) retain a huge buffer
+	maxCopyBufferSize = 1024 * 1024
+)
+
+// copyBufferPool is a pool of buffers of the same size, used to copy the data between the clients and the upstreams,
+// eg. the large list responses and the followed logs
+type copyBufferPool struct {
+	size int
+	pool sync.Pool
+}
+
+var _ httputil.BufferPool = &copyBufferPool{}
+
+func newCopyBufferPool(size int) *copyBufferPool {
+	return &copyBufferPool{
+		size: size,
+		pool: sync.Pool{
+			New: func() any {
+				return make([]byte, size)
+			},
+		},
+	}
+}
+
+// Get returns a buffer of the pool
+func (p *copyBufferPool) Get() []byte {
+	return p.pool.Get().([]byte)
+}
+
+// Put returns the given buffer to the pool, unless it's not a buffer of the pool
+func (p *copyBufferPool) Put(buf []byte) {
+	if cap(buf) != p.size {
+		return
+	}
+	p.pool.Put(buf[:p.size]) // nolint:staticcheck
+}
+
+// getCopyBufferPool returns the pool of the buffers of the size configured with the CopyBufferSize setting
+func (p *Proxy) getCopyBufferPool() *copyBufferPool {
+	size := configuration.GetRegistrationServiceConfig().Proxy().CopyBufferSize()
+	if size <= 0 {
+		size = defaultCopyBufferSize
+	}
+	size = min(size, maxCopyBufferSize)
+	if pool, ok := p.copyBufferPools.Load(size); ok {
+		return pool.(*copyBufferPool)
+	}
+	pool, _ := p.copyBufferPools.LoadOrStore(size, newCopyBufferPool(size))
+	return pool.(*copyBufferPool)
+}
+
+// upgradedConnection is the connection to the upstream of an upgraded request, which copies the data from and to the client
+// with the buffers of the given pool, rather than with the buffers allocated by io.Copy for each direction of the connection
+type upgradedConnection struct {
+	io.ReadWriteCloser
+	buffers *copyBufferPool
+	// received and sent are the numbers of bytes received from and sent to the upstream
+	received, sent atomic.Int64
+	// onClose, if set, is called with the numbers of bytes received from and sent to the upstream once the connection is closed
+	onClose   func(received, sent int64)
+	closeOnce sync.Once
+}
+
+func (c *upgradedConnection) Read(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Read(p)
+	c.received.Add(int64(n))
+	return n, err
+}
+
+func (c *upgradedConnection) Write(p []byte) (int, error) {
+	n, err := c.ReadWriteCloser.Write(p)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+// WriteTo copies the data received from the upstream to the given writer (ie. to the client)
+func (c *upgradedConnection) WriteTo(w io.Writer) (int64, error) {
+	buf := c.buffers.Get()
+	defer c.buffers.Put(buf)
+	// the reader and the writer are wrapped, so that io.CopyBuffer doesn't delegate to their WriteTo or ReadFrom methods
+	n, err := io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{c.ReadWriteCloser}, buf)
+	c.received.Add(n)
+	return n, err
+}
+
+// ReadFrom copies the data read from the given reader (ie. from the client) to the upstream
+func (c *upgradedConnection) ReadFrom(r io.Reader) (int64, error) {
+	buf := c.buffers.Get()
+	defer c.buffers.Put(buf)
+	n, err := io.CopyBuffer(struct{ io.Writer }{c.ReadWriteCloser}, struct{ io.Reader }{r}, buf)
+	c.sent.Add(n)
+	return n, err
+}
+
+func (c *upgradedConnection) Close() error {
+	err := c.ReadWriteCloser.Close()
+	if c.onClose != nil {
+		c.closeOnce.Do(func() {
+			c.onClose(c.received.Load(), c.sent.Load())
+		})
+	}
+	return err
+}
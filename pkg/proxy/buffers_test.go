@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestGetCopyBufferPool() {
+	// given
+	p := &Proxy{}
+
+	s.Run("default size", func() {
+		// when
+		buf := p.getCopyBufferPool().Get()
+
+		// then
+		assert.Len(s.T(), buf, 32*1024)
+		assert.Same(s.T(), p.getCopyBufferPool(), p.getCopyBufferPool())
+	})
+
+	s.Run("configured size", func() {
+		// given
+		defaultPool := p.getCopyBufferPool()
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_COPY_BUFFER_SIZE", "1024")
+
+		// when
+		pool := p.getCopyBufferPool()
+
+		// then
+		assert.Len(s.T(), pool.Get(), 1024)
+		assert.NotSame(s.T(), defaultPool, pool)
+	})
+
+	s.Run("size capped", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_COPY_BUFFER_SIZE", "104857600")
+
+		// when
+		buf := p.getCopyBufferPool().Get()
+
+		// then
+		assert.Len(s.T(), buf, 1024*1024)
+	})
+}
+
+func (s *TestProxySuite) TestCopyBufferPool() {
+	s.Run("buffer reused", func() {
+		// given
+		pool := newCopyBufferPool(1024)
+		buf := pool.Get()
+
+		// when
+		pool.Put(buf[:10])
+
+		// then the whole buffer is returned again
+		assert.Len(s.T(), pool.Get(), 1024)
+	})
+
+	s.Run("not a buffer of the pool", func() {
+		// given
+		pool := newCopyBufferPool(1024)
+
+		// when
+		pool.Put(make([]byte, 512))
+
+		// then
+		assert.Len(s.T(), pool.Get(), 1024)
+	})
+
+}
+
+func (s *TestProxySuite) TestConnectionBufferSize() {
+	s.Run("default size", func() {
+		// when
+		transport := getTransport(http.Header{}, nil)
+
+		// then
+		assert.Zero(s.T(), transport.ReadBufferSize)
+		assert.Zero(s.T(), transport.WriteBufferSize)
+	})
+
+	s.Run("configured size", func() {
+		// given
+		p := &Proxy{}
+		defaultTransport := p.getSharedTransport(http.Header{}, 0)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CONNECTION_BUFFER_SIZE", "65536")
+
+		// when
+		transport := getTransport(http.Header{}, nil)
+		sharedTransport := p.getSharedTransport(http.Header{}, 0)
+
+		// then
+		assert.Equal(s.T(), 65536, transport.ReadBufferSize)
+		assert.Equal(s.T(), 65536, transport.WriteBufferSize)
+		assert.Equal(s.T(), 65536, sharedTransport.(*http.Transport).ReadBufferSize)
+		assert.NotSame(s.T(), defaultTransport, sharedTransport)
+	})
+}
+
+type upstreamConn struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (c *upstreamConn) Read(p []byte) (int, error) {
+	return c.Reader.Read(p)
+}
+
+func (c *upstreamConn) Close() error {
+	return nil
+}
+
+func (s *TestProxySuite) TestUpgradedConnection() {
+	// given
+	upstream := &upstreamConn{Reader: strings.NewReader(strings.Repeat("a", 5000))}
+	conn := &upgradedConnection{ReadWriteCloser: upstream, buffers: newCopyBufferPool(1024)}
+
+	s.Run("from the upstream", func() {
+		// given
+		client := &bytes.Buffer{}
+
+		// when
+		n, err := io.Copy(client, conn)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), int64(5000), n)
+		assert.Equal(s.T(), strings.Repeat("a", 5000), client.String())
+	})
+
+	s.Run("to the upstream", func() {
+		// when
+		n, err := io.Copy(conn, strings.NewReader(strings.Repeat("b", 3000)))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), int64(3000), n)
+		assert.Equal(s.T(), strings.Repeat("b", 3000), upstream.String())
+	})
+
+	s.Run("transferred bytes observed once closed", func() {
+		// given
+		var observed [][2]int64
+		conn := &upgradedConnection{
+			ReadWriteCloser: &upstreamConn{Reader: strings.NewReader(strings.Repeat("a", 100))},
+			buffers:         newCopyBufferPool(1024),
+			onClose: func(received, sent int64) {
+				observed = append(observed, [2]int64{received, sent})
+			},
+		}
+		_, err := io.Copy(&bytes.Buffer{}, conn)
+		require.NoError(s.T(), err)
+		_, err = io.Copy(conn, strings.NewReader(strings.Repeat("b", 20)))
+		require.NoError(s.T(), err)
+		_, err = conn.Write([]byte("ccc"))
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), observed)
+
+		// when
+		require.NoError(s.T(), conn.Close())
+		require.NoError(s.T(), conn.Close())
+
+		// then
+		assert.Equal(s.T(), [][2]int64{{100, 23}}, observed)
+	})
+}
@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// CanaryFlag is the name of a behavior of the proxy which is only enabled for a cohort of users,
+// so that risky changes of the proxy can be rolled out incrementally, to a small slice of users first
+type CanaryFlag string
+
+const (
+	// CanarySharedTransport reuses the transports, and thus the connections, to the member clusters across the requests,
+	// instead of creating a new transport for each request
+	CanarySharedTransport CanaryFlag = "shared-transport"
+)
+
+// canaryFlags are all the known canary flags
+var canaryFlags = []CanaryFlag{CanarySharedTransport}
+
+// userCohort returns the cohort (between 0 and 99) of the given user. The cohort only depends on the username,
+// so that a user consistently gets the same behaviors, whichever replica of the proxy handles the requests.
+func userCohort(username string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(username))
+	return int(h.Sum32() % 100)
+}
+
+// CanaryFlags are the canary flags evaluated for the user of a request
+type CanaryFlags map[CanaryFlag]bool
+
+// evaluateCanaryFlags returns the canary flags configured with the CanaryFlags setting of the proxy, evaluated for the given user:
+// a flag is enabled if the cohort of the user is below the percentage of the users the flag is enabled for
+func evaluateCanaryFlags(username string) CanaryFlags {
+	flags := CanaryFlags{}
+	cohort := userCohort(username)
+	for name, percentage := range configuration.GetRegistrationServiceConfig().Proxy().CanaryFlags() {
+		if flag := CanaryFlag(name); slices.Contains(canaryFlags, flag) {
+			flags[flag] = cohort < percentage
+		}
+	}
+	return flags
+}
+
+// logUnknownCanaryFlags logs the configured canary flags which are unknown, and thus ignored
+func logUnknownCanaryFlags() {
+	for name := range configuration.GetRegistrationServiceConfig().Proxy().CanaryFlags() {
+		if !slices.Contains(canaryFlags, CanaryFlag(name)) {
+			log.Infof(nil, "ignoring the unknown canary flag '%s'", name)
+		}
+	}
+}
+
+// Enabled returns true if the given flag is enabled
+func (f CanaryFlags) Enabled(flag CanaryFlag) bool {
+	return f[flag]
+}
+
+// observe counts the request with the given response status in the cohort of each configured flag
+func (f CanaryFlags) observe(proxyMetrics *metrics.ProxyMetrics, status int) {
+	for flag, enabled := range f {
+		cohort := metrics.MetricLabelBaseline
+		if enabled {
+			cohort = metrics.MetricLabelCanary
+		}
+		proxyMetrics.RegServProxyCanaryCounterVec.WithLabelValues(string(flag), cohort, strconv.Itoa(status)).Inc()
+	}
+}
+
+// sharedTransportKey identifies the transports which can be shared by the requests to the member clusters
+type sharedTransportKey struct {
+	spdy                  bool
+	responseHeaderTimeout time.Duration
+	bufferSize            int
+	// resolution is the configured host overrides and DNS servers (see resolvingDialer)
+	resolution string
+}
+
+// getSharedTransport returns the transport shared by all the requests to the member clusters which use the default
+// TLS settings, the given response header timeout and the configured buffer size and resolution (see the CanarySharedTransport flag)
+func (p *Proxy) getSharedTransport(reqHeader http.Header, responseHeaderTimeout time.Duration) http.RoundTripper {
+	proxyConfig := configuration.GetRegistrationServiceConfig().Proxy()
+	key := sharedTransportKey{
+		// the SPDY upgrades require HTTP/1.1, and thus a dedicated transport
+		spdy:                  strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/"),
+		responseHeaderTimeout: responseHeaderTimeout,
+		bufferSize:            proxyConfig.ConnectionBufferSize(),
+		resolution:            fmt.Sprint(proxyConfig.HostOverrides(), proxyConfig.DNSServers()),
+	}
+	if transport, ok := p.sharedTransports.Load(key); ok {
+		return transport.(http.RoundTripper)
+	}
+	transport := getTransport(reqHeader, nil)
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	shared, _ := p.sharedTransports.LoadOrStore(key, transport)
+	return shared.(http.RoundTripper)
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *TestProxySuite) TestUserCohort() {
+	s.Run("same cohort for the same user", func() {
+		assert.Equal(s.T(), userCohort("smith"), userCohort("smith"))
+	})
+
+	s.Run("users evenly distributed in the cohorts", func() {
+		// when
+		below10 := 0
+		for i := 0; i < 10000; i++ {
+			cohort := userCohort(fmt.Sprintf("user-%d", i))
+			assert.GreaterOrEqual(s.T(), cohort, 0)
+			assert.Less(s.T(), cohort, 100)
+			if cohort < 10 {
+				below10++
+			}
+		}
+
+		// then about 10% of the users are in the first 10 cohorts
+		assert.InDelta(s.T(), 1000, below10, 150)
+	})
+}
+
+func (s *TestProxySuite) TestEvaluateCanaryFlags() {
+	s.Run("no canary flag", func() {
+		// when
+		flags := evaluateCanaryFlags("smith")
+
+		// then
+		assert.Empty(s.T(), flags)
+		assert.False(s.T(), flags.Enabled(CanarySharedTransport))
+	})
+
+	s.Run("flag enabled for all the users", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CANARY_FLAGS", "shared-transport=100,unknown=100")
+
+		// when
+		flags := evaluateCanaryFlags("smith")
+
+		// then
+		assert.Equal(s.T(), CanaryFlags{CanarySharedTransport: true}, flags)
+	})
+
+	s.Run("flag disabled for all the users", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CANARY_FLAGS", "shared-transport=0")
+
+		// when
+		flags := evaluateCanaryFlags("smith")
+
+		// then
+		assert.Equal(s.T(), CanaryFlags{CanarySharedTransport: false}, flags)
+	})
+
+	s.Run("flag enabled depending on the cohort of the user", func() {
+		// given
+		var canaryUser, baselineUser string
+		for i := 0; canaryUser == "" || baselineUser == ""; i++ {
+			username := fmt.Sprintf("user-%d", i)
+			if cohort := userCohort(username); cohort < 50 && canaryUser == "" {
+				canaryUser = username
+			} else if cohort >= 50 && baselineUser == "" {
+				baselineUser = username
+			}
+		}
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CANARY_FLAGS", "shared-transport=50")
+
+		// then
+		assert.True(s.T(), evaluateCanaryFlags(canaryUser).Enabled(CanarySharedTransport))
+		assert.False(s.T(), evaluateCanaryFlags(baselineUser).Enabled(CanarySharedTransport))
+	})
+}
+
+func (s *TestProxySuite) TestObserveCanaryFlags() {
+	// given
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+
+	// when
+	CanaryFlags{CanarySharedTransport: true}.observe(proxyMetrics, http.StatusOK)
+	CanaryFlags{CanarySharedTransport: true}.observe(proxyMetrics, http.StatusOK)
+	CanaryFlags{CanarySharedTransport: true}.observe(proxyMetrics, http.StatusBadGateway)
+	CanaryFlags{CanarySharedTransport: false}.observe(proxyMetrics, http.StatusOK)
+	CanaryFlags{}.observe(proxyMetrics, http.StatusOK)
+
+	// then
+	counter := proxyMetrics.RegServProxyCanaryCounterVec
+	assert.Equal(s.T(), 3, promtestutil.CollectAndCount(counter))
+	assert.InDelta(s.T(), 2, promtestutil.ToFloat64(counter.WithLabelValues("shared-transport", "canary", "200")), 0.01)
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues("shared-transport", "canary", "502")), 0.01)
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues("shared-transport", "baseline", "200")), 0.01)
+}
+
+func (s *TestProxySuite) TestGetSharedTransport() {
+	// given
+	p := &Proxy{}
+	spdy := http.Header{"Upgrade": {"SPDY/3.1"}}
+
+	// when
+	transport := p.getSharedTransport(http.Header{}, 0)
+	spdyTransport := p.getSharedTransport(spdy, 0)
+	timeoutTransport := p.getSharedTransport(http.Header{}, 10*time.Second)
+
+	// then
+	assert.Same(s.T(), transport, p.getSharedTransport(http.Header{"Upgrade": {"websocket"}}, 0))
+	assert.Same(s.T(), spdyTransport, p.getSharedTransport(spdy, 0))
+	assert.Same(s.T(), timeoutTransport, p.getSharedTransport(http.Header{}, 10*time.Second))
+	assert.NotSame(s.T(), transport, spdyTransport)
+	assert.NotSame(s.T(), transport, timeoutTransport)
+	assert.False(s.T(), spdyTransport.(*http.Transport).ForceAttemptHTTP2)
+	assert.Zero(s.T(), transport.(*http.Transport).ResponseHeaderTimeout)
+	assert.Equal(s.T(), 10*time.Second, timeoutTransport.(*http.Transport).ResponseHeaderTimeout)
+	assert.NotSame(s.T(), transport, (&Proxy{}).getSharedTransport(http.Header{}, 0), "the transports should not be shared across proxies")
+}
@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/application"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// captureLogMessage is the message of the log entries of the captured requests, used to find them in the logs of the proxy
+	captureLogMessage = "proxied request captured"
+	// maxCaptureWindow bounds the time window of the capture, so that a forgotten setting can't capture the requests forever:
+	// the requests are only captured during the last 24 hours before the configured end of the capture.
+	maxCaptureWindow = 24 * time.Hour
+	// captureEndpoint is the admin endpoint of the proxy to capture the requests of a user at runtime, as with the CaptureUsername
+	// and CaptureUntil settings. The capture only applies to the replica of the proxy it was set on, unless the caches are shared
+	// with the other replicas (see SharedCache).
+	captureEndpoint = "/proxyadmin/capture"
+)
+
+// CapturedRequest is the sanitized metadata of a proxied request, along with the route it was forwarded to.
+// Neither the headers, the query nor the body of the request are captured.
+type CapturedRequest struct {
+	Time                time.Time `json:"time"`
+	Username            string    `json:"username"`
+	Method              string    `json:"method"`
+	Path                string    `json:"path"`
+	PublicViewerEnabled bool      `json:"publicViewerEnabled"`
+	// Route is the route the request was forwarded to, nil if the request was rejected
+	Route *Route `json:"route,omitempty"`
+	// Error is the reason why the request was rejected, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Capture is the capture of the requests of a user until a given time, set at runtime with the admin endpoint of the proxy
+type Capture struct {
+	Username string    `json:"username"`
+	Until    time.Time `json:"until"`
+}
+
+// active returns true if the capture applies at the given time, ie. in the last maxCaptureWindow before its end
+func (c Capture) active(now time.Time) bool {
+	return c.Username != "" && now.Before(c.Until) && c.Until.Sub(now) <= maxCaptureWindow
+}
+
+// captures holds the capture set at runtime, if any
+type captures struct {
+	mu      sync.RWMutex
+	capture *Capture
+}
+
+// get returns the capture set at runtime, or nil if there is none
+func (c *captures) get() *Capture {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.capture
+}
+
+// set sets the given capture, or removes the current one if nil
+func (c *captures) set(capture *Capture) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.capture = capture
+}
+
+// capturing returns true if the requests of the given user received at the given time must be captured, as set at runtime with
+// the admin endpoint of the proxy, or as configured with the CaptureUsername and CaptureUntil settings of the proxy
+func (p *Proxy) capturing(username string, now time.Time) bool {
+	if username == "" {
+		return false
+	}
+	if capture := p.captures.get(); capture != nil && capture.Username == username && capture.active(now) {
+		return true
+	}
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	configured := Capture{Username: cfg.CaptureUsername(), Until: cfg.CaptureUntil()}
+	return configured.Username == username && configured.active(now)
+}
+
+// getCapture returns the capture set at runtime, or a NotFound error if there is none
+func (p *Proxy) getCapture(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	capture := p.captures.get()
+	if capture == nil {
+		return crterrors.NewNotFoundError(errors.New("no capture"), "capture not found")
+	}
+	return ctx.JSON(http.StatusOK, capture)
+}
+
+// setCapture captures the requests of the user of the Capture of the body until its end, which must be in the next maxCaptureWindow.
+// The capture replaces the one which was set before, if any.
+func (p *Proxy) setCapture(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	capture := Capture{}
+	if err := json.NewDecoder(ctx.Request().Body).Decode(&capture); err != nil {
+		return crterrors.NewBadRequest("invalid capture request", fmt.Sprintf("unable to decode the capture: %s", err.Error()))
+	}
+	if capture.Username == "" {
+		return crterrors.NewBadRequest("invalid capture request", "the username is required")
+	}
+	if !capture.active(time.Now()) {
+		return crterrors.NewBadRequest("invalid capture request", fmt.Sprintf("the end of the capture must be within the next %s", maxCaptureWindow))
+	}
+	p.captures.set(&capture)
+	log.InfoEchof(ctx, "capturing the requests of '%s' until %s", capture.Username, capture.Until.Format(time.RFC3339))
+	if p.sharedCache != nil {
+		if err := p.sharedCache.saveCapture(ctx.Request().Context(), capture); err != nil {
+			return crterrors.NewInternalError(err, "the capture only applies to this replica of the proxy")
+		}
+	}
+	return ctx.JSON(http.StatusOK, capture)
+}
+
+// deleteCapture stops the capture set at runtime
+func (p *Proxy) deleteCapture(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	if p.captures.get() == nil {
+		return crterrors.NewNotFoundError(errors.New("no capture"), "capture not found")
+	}
+	p.captures.set(nil)
+	log.InfoEchof(ctx, "stopped the capture of the requests")
+	if p.sharedCache != nil {
+		if err := p.sharedCache.deleteCapture(ctx.Request().Context()); err != nil {
+			return crterrors.NewInternalError(err, "the capture was only stopped on this replica of the proxy")
+		}
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// captureRequest logs the metadata of the request received at the given time on the given path (ie. before it was processed),
+// along with the route resolved for this request or the error returned while resolving it
+func captureRequest(ctx echo.Context, receivedTime time.Time, path, proxyPluginName string, cluster *access.ClusterAccess, err error) {
+	username, _ := ctx.Get(context.UsernameKey).(string)
+	captured := CapturedRequest{
+		Time:                receivedTime,
+		Username:            username,
+		Method:              ctx.Request().Method,
+		Path:                path,
+		PublicViewerEnabled: context.IsPublicViewerEnabled(ctx),
+	}
+	if err != nil {
+		captured.Error = err.Error()
+	} else {
+		workspace, _ := ctx.Get(context.WorkspaceKey).(string)
+		route := newRoute(workspace, proxyPluginName, cluster, ctx.Request().URL.Path, impersonatedGroups(ctx, cluster))
+		captured.Route = &route
+	}
+	log.WithValues(map[string]interface{}{"capture": captured}).InfoEchof(ctx, captureLogMessage)
+}
+
+// ReadCapturedRequests returns the requests captured in the given logs of the proxy, ignoring all the other log entries
+func ReadCapturedRequests(r io.Reader) ([]CapturedRequest, error) {
+	captured := []CapturedRequest{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := struct {
+			Msg     string           `json:"msg"`
+			Capture *CapturedRequest `json:"capture"`
+		}{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Msg != captureLogMessage || entry.Capture == nil {
+			continue
+		}
+		captured = append(captured, *entry.Capture)
+	}
+	return captured, scanner.Err()
+}
+
+// ReplayResult is the route of a captured request, resolved again against the current state of the clusters
+type ReplayResult struct {
+	Captured CapturedRequest `json:"captured"`
+	Route    *Route          `json:"route,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	// Changed is true if the request is now forwarded to a different route, or is now rejected (or accepted)
+	Changed bool `json:"changed"`
+}
+
+// Replayer resolves the routes of the captured requests again, the same way as the proxy would do it,
+// to debug the requests which were reported to be forwarded to the wrong cluster
+type Replayer struct {
+	proxy  *Proxy
+	router *echo.Echo
+}
+
+// NewReplayer returns a new Replayer resolving the routes against the given host and member clusters
+func NewReplayer(nsClient namespaced.Client, app application.Application, getMembersFunc commoncluster.GetMemberClustersFunc) *Replayer {
+	// the metrics are not exposed
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+	return &Replayer{
+		proxy: &Proxy{
+			Client:          nsClient,
+			signupService:   app.SignupService(),
+			spaceLister:     handlers.NewSpaceLister(nsClient, app, proxyMetrics),
+			metrics:         proxyMetrics,
+			getMembersFunc:  getMembersFunc,
+			pluginEndpoints: NewPluginEndpoints(),
+		},
+		router: echo.New(),
+	}
+}
+
+// Replay resolves the route of the given captured request again
+func (r *Replayer) Replay(captured CapturedRequest) ReplayResult {
+	req := &http.Request{
+		Method: captured.Method,
+		URL:    &url.URL{Path: captured.Path},
+		Header: http.Header{},
+	}
+	ctx := r.router.NewContext(req, nil)
+	ctx.Set(context.UsernameKey, captured.Username)
+	ctx.Set(context.PublicViewerEnabled, configuration.GetRegistrationServiceConfig().PublicViewerEnabled())
+
+	result := ReplayResult{
+		Captured: captured,
+	}
+	proxyPluginName, cluster, err := r.proxy.processRequest(ctx)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		workspace, _ := ctx.Get(context.WorkspaceKey).(string)
+		route := newRoute(workspace, proxyPluginName, cluster, req.URL.Path, impersonatedGroups(ctx, cluster))
+		result.Route = &route
+	}
+	result.Changed = (result.Error == "") != (captured.Error == "") || !reflect.DeepEqual(result.Route, captured.Route)
+	return result
+}
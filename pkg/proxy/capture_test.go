@@ -0,0 +1,345 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	"github.com/codeready-toolchain/registration-service/test/util"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/labstack/echo/v4"
+	routev1 "github.com/openshift/api/route/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func (s *TestProxySuite) TestCapturing() {
+	now := time.Now()
+	tests := map[string]struct {
+		username, captureUsername, captureUntil string
+		expected                                bool
+	}{
+		"user captured": {
+			username: "smith", captureUsername: "smith", captureUntil: now.Add(time.Hour).Format(time.RFC3339),
+			expected: true,
+		},
+		"other user": {
+			username: "john", captureUsername: "smith", captureUntil: now.Add(time.Hour).Format(time.RFC3339),
+		},
+		"anonymous request": {
+			captureUntil: now.Add(time.Hour).Format(time.RFC3339),
+		},
+		"capture is over": {
+			username: "smith", captureUsername: "smith", captureUntil: now.Add(-time.Minute).Format(time.RFC3339),
+		},
+		"capture has not started yet": {
+			username: "smith", captureUsername: "smith", captureUntil: now.Add(25 * time.Hour).Format(time.RFC3339),
+		},
+		"end of the capture not set": {
+			username: "smith", captureUsername: "smith",
+		},
+		"invalid end of the capture": {
+			username: "smith", captureUsername: "smith", captureUntil: "tomorrow",
+		},
+	}
+	for name, tc := range tests {
+		s.Run(name, func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_CAPTURE_USERNAME", tc.captureUsername)
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_CAPTURE_UNTIL", tc.captureUntil)
+
+			// when
+			captured := (&Proxy{}).capturing(tc.username, now)
+
+			// then
+			assert.Equal(s.T(), tc.expected, captured)
+		})
+	}
+}
+
+func (s *TestProxySuite) TestCaptureEndpoints() {
+	// given
+	p := &Proxy{}
+	newContext := func(username, method, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, captureEndpoint, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.Set(context.UsernameKey, username)
+		return ctx, rec
+	}
+	assertError := func(err error, code int) {
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), code, crtErr.Code)
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	s.Run("capture set by an admin", func() {
+		// given
+		ctx, rec := newContext("admin", http.MethodPut, `{"username":"smith","until":"`+until.Format(time.RFC3339)+`"}`)
+
+		// when
+		err := p.setCapture(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), http.StatusOK, rec.Code)
+		assert.True(s.T(), p.capturing("smith", time.Now()))
+		assert.False(s.T(), p.capturing("john", time.Now()))
+		assert.False(s.T(), p.capturing("smith", until))
+
+		s.Run("returned", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodGet, "")
+
+			// when
+			err := p.getCapture(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			capture := Capture{}
+			require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), &capture))
+			assert.Equal(s.T(), "smith", capture.Username)
+			assert.True(s.T(), until.Equal(capture.Until))
+		})
+
+		s.Run("stopped", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodDelete, "")
+
+			// when
+			err := p.deleteCapture(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusNoContent, rec.Code)
+			assert.False(s.T(), p.capturing("smith", time.Now()))
+
+			s.Run("not found anymore", func() {
+				// given
+				getCtx, _ := newContext("admin", http.MethodGet, "")
+				deleteCtx, _ := newContext("admin", http.MethodDelete, "")
+
+				// then
+				assertError(p.getCapture(getCtx), http.StatusNotFound)
+				assertError(p.deleteCapture(deleteCtx), http.StatusNotFound)
+			})
+		})
+	})
+
+	s.Run("capture not set", func() {
+		for name, body := range map[string]string{
+			"invalid body":        `{"username":`,
+			"username missing":    `{"until":"` + until.Format(time.RFC3339) + `"}`,
+			"capture is over":     `{"username":"smith","until":"` + time.Now().Add(-time.Minute).Format(time.RFC3339) + `"}`,
+			"capture is too long": `{"username":"smith","until":"` + time.Now().Add(25*time.Hour).Format(time.RFC3339) + `"}`,
+		} {
+			s.Run(name, func() {
+				// given
+				ctx, _ := newContext("admin", http.MethodPut, body)
+
+				// when
+				err := p.setCapture(ctx)
+
+				// then
+				assertError(err, http.StatusBadRequest)
+				assert.Nil(s.T(), p.captures.get())
+			})
+		}
+
+		s.Run("by a user who is not an admin", func() {
+			// given
+			ctx, _ := newContext("smith", http.MethodPut, `{"username":"smith","until":"`+until.Format(time.RFC3339)+`"}`)
+
+			// when
+			err := p.setCapture(ctx)
+
+			// then
+			assertError(err, http.StatusForbidden)
+			assert.Nil(s.T(), p.captures.get())
+		})
+
+		s.Run("by an impersonating admin", func() {
+			// given
+			ctx, _ := newContext("admin", http.MethodPut, `{"username":"smith","until":"`+until.Format(time.RFC3339)+`"}`)
+			ctx.Set(context.ImpersonatedByKey, "other-admin")
+
+			// when
+			err := p.setCapture(ctx)
+
+			// then
+			assertError(err, http.StatusForbidden)
+			assert.Nil(s.T(), p.captures.get())
+		})
+	})
+}
+
+func (s *TestProxySuite) TestReadCapturedRequests() {
+	// given
+	logs := strings.NewReader(`Starting the Proxy server...
+{"level":"info","ts":"2024-01-01T10:00:00Z","msg":"request received","username":"smith"}
+{"level":"info","ts":"2024-01-01T10:00:00Z","msg":"proxied request captured","username":"smith","capture":{"time":"2024-01-01T10:00:00Z","username":"smith","method":"GET","path":"/workspaces/smith/api/v1/pods","publicViewerEnabled":false,"route":{"workspace":"smith","targetCluster":"member-1","apiEndpoint":"https://api.member-1:6443","path":"/api/v1/pods","impersonateUser":"smith"}}}
+{"level":"info","ts":"2024-01-01T10:00:01Z","msg":"proxied request captured","username":"smith","capture":{"time":"2024-01-01T10:00:01Z","username":"smith","method":"GET","path":"/workspaces/other/api/v1/pods","publicViewerEnabled":false,"error":"unable to get target cluster"}}
+{"level":"info","ts":"2024-01-01T10:00:02Z","msg":"proxied request captured","username":"smith"}
+`)
+
+	// when
+	captured, err := ReadCapturedRequests(logs)
+
+	// then
+	require.NoError(s.T(), err)
+	require.Len(s.T(), captured, 2)
+	assert.Equal(s.T(), CapturedRequest{
+		Time:     time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		Username: "smith",
+		Method:   "GET",
+		Path:     "/workspaces/smith/api/v1/pods",
+		Route: &Route{
+			Workspace:       "smith",
+			TargetCluster:   "member-1",
+			APIEndpoint:     "https://api.member-1:6443",
+			Path:            "/api/v1/pods",
+			ImpersonateUser: "smith",
+		},
+	}, captured[0])
+	assert.Equal(s.T(), "/workspaces/other/api/v1/pods", captured[1].Path)
+	assert.Nil(s.T(), captured[1].Route)
+	assert.Equal(s.T(), "unable to get target cluster", captured[1].Error)
+}
+
+func (s *TestProxySuite) TestReplay() {
+	// given
+	memberURL := "https://api.endpoint.member-2.com:6443"
+	signupService := fake.NewSignupService(&signup.Signup{
+		Name:              "smith2",
+		APIEndpoint:       memberURL,
+		ClusterName:       "member-2",
+		CompliantUsername: "smith2",
+		Username:          "smith2@",
+		Status: signup.Status{
+			Ready: true,
+		},
+	})
+	require.NoError(s.T(), routev1.Install(scheme.Scheme))
+	fakeClient, app := util.PrepareInClusterApp(s.T(),
+		fake.NewSpace("mycoolworkspace", "member-2", "smith2"),
+		fake.NewSpaceBinding("mycoolworkspace-smith2", "smith2", "mycoolworkspace", "admin"),
+		&toolchainv1alpha1.ProxyPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: commontest.HostOperatorNs,
+				Name:      "myplugin",
+			},
+			Spec: toolchainv1alpha1.ProxyPluginSpec{
+				OpenShiftRouteTargetEndpoint: &toolchainv1alpha1.OpenShiftRouteTarget{
+					Namespace: commontest.MemberOperatorNs,
+					Name:      "proxy-plugin",
+				},
+			},
+		},
+		fake.NewBase1NSTemplateTier())
+	nsClient := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+	replayer := NewReplayer(nsClient, app, s.newMemberClustersFunc(memberURL))
+	replayer.proxy.signupService = signupService
+	replayer.proxy.spaceLister = &handlers.SpaceLister{
+		Client:        nsClient,
+		GetSignupFunc: signupService.GetSignup,
+		ProxyMetrics:  replayer.proxy.metrics,
+	}
+	route := &Route{
+		Workspace:       "mycoolworkspace",
+		TargetCluster:   "member-2",
+		APIEndpoint:     memberURL,
+		Path:            "/api/v1/pods",
+		ImpersonateUser: "smith2",
+	}
+
+	s.Run("same route", func() {
+		// when
+		result := replayer.Replay(CapturedRequest{
+			Username: "smith2",
+			Method:   "GET",
+			Path:     "/workspaces/mycoolworkspace/api/v1/pods",
+			Route:    route,
+		})
+
+		// then
+		assert.Equal(s.T(), route, result.Route)
+		assert.Empty(s.T(), result.Error)
+		assert.False(s.T(), result.Changed)
+	})
+
+	s.Run("route to another cluster", func() {
+		// given
+		misrouted := *route
+		misrouted.TargetCluster = "member-1"
+		misrouted.APIEndpoint = "https://api.endpoint.member-1.com:6443"
+
+		// when
+		result := replayer.Replay(CapturedRequest{
+			Username: "smith2",
+			Method:   "GET",
+			Path:     "/workspaces/mycoolworkspace/api/v1/pods",
+			Route:    &misrouted,
+		})
+
+		// then
+		assert.Equal(s.T(), route, result.Route)
+		assert.True(s.T(), result.Changed)
+	})
+
+	s.Run("plugin route", func() {
+		// when
+		result := replayer.Replay(CapturedRequest{
+			Username: "smith2",
+			Method:   "GET",
+			Path:     "/plugins/myplugin/workspaces/mycoolworkspace/api/v1/pods",
+		})
+
+		// then
+		require.NotNil(s.T(), result.Route)
+		assert.Equal(s.T(), "myplugin", result.Route.ProxyPlugin)
+		assert.Equal(s.T(), "http://api.endpoint.member-2.com:6443", result.Route.APIEndpoint)
+		assert.True(s.T(), result.Changed) // the request was captured without a route
+	})
+
+	s.Run("request now rejected", func() {
+		// when
+		result := replayer.Replay(CapturedRequest{
+			Username: "smith2",
+			Method:   "GET",
+			Path:     "/workspaces/not-existing-workspace/api/v1/pods",
+			Route:    route,
+		})
+
+		// then
+		assert.Nil(s.T(), result.Route)
+		assert.NotEmpty(s.T(), result.Error)
+		assert.True(s.T(), result.Changed)
+	})
+
+	s.Run("request still rejected", func() {
+		// when
+		result := replayer.Replay(CapturedRequest{
+			Username: "smith2",
+			Method:   "GET",
+			Path:     "/workspaces/not-existing-workspace/api/v1/pods",
+			Error:    "unable to get target cluster",
+		})
+
+		// then
+		assert.NotEmpty(s.T(), result.Error)
+		assert.False(s.T(), result.Changed)
+	})
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	errs "github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/httpstream/wsstream"
+)
+
+// serverTLSConfig returns the given TLS configuration of the proxy server along with the certificate of the TLSCertFile setting
+// and, if the ClientCAFile setting is set, the authorities the client certificates are verified with. Nil is returned if the proxy
+// doesn't serve TLS.
+func serverTLSConfig(base *tls.Config) (*tls.Config, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	if cfg.TLSCertFile() == "" {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile(), cfg.TLSKeyFile())
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to load the TLS certificate of the proxy")
+	}
+	tlsConfig := base.Clone()
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	if cfg.ClientCAFile() == "" {
+		return tlsConfig, nil
+	}
+	pem, err := os.ReadFile(cfg.ClientCAFile())
+	if err != nil {
+		return nil, errs.Wrap(err, "unable to read the client certificate authorities of the proxy")
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate found in the client certificate authorities file '%s'", cfg.ClientCAFile())
+	}
+	tlsConfig.ClientCAs = clientCAs
+	// the client certificates are optional, since the other clients authenticate with their tokens
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
+
+// hasToken returns true if the given request has a token, which takes precedence over the client certificate, if any
+func hasToken(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" {
+		return true
+	}
+	if wsstream.IsWebSocketRequest(req) {
+		_, err := extractTokenFromWebsocketRequest(req)
+		return err == nil
+	}
+	return false
+}
+
+// clientCertUsername returns the name of the user the verified client certificate of the given request is mapped to with
+// the first matching rule of the ClientCertRules setting, along with the subject of the certificate. False is returned if
+// the request has no verified client certificate, and an empty username if no rule matches the certificate.
+func clientCertUsername(req *http.Request) (string, string, bool) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", "", false
+	}
+	cert := req.TLS.VerifiedChains[0][0]
+	for _, rule := range configuration.GetRegistrationServiceConfig().Proxy().ClientCertRules() {
+		for _, value := range clientCertValues(cert, rule.Field) {
+			if value == "" || (rule.Value != "*" && rule.Value != value) {
+				continue
+			}
+			if rule.Username == "*" {
+				return value, cert.Subject.String(), true
+			}
+			return rule.Username, cert.Subject.String(), true
+		}
+	}
+	return "", cert.Subject.String(), true
+}
+
+// clientCertValues returns the values of the given field of the given certificate (see ClientCertRule)
+func clientCertValues(cert *x509.Certificate, field string) []string {
+	switch field {
+	case "cn":
+		return []string{cert.Subject.CommonName}
+	case "dns":
+		return cert.DNSNames
+	case "email":
+		return cert.EmailAddresses
+	case "uri":
+		values := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			values = append(values, uri.String())
+		}
+		return values
+	default:
+		return nil
+	}
+}
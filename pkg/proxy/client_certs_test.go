@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate is a certificate along with its private key, generated for the tests
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCertificate returns a new certificate generated from the given template, signed by the given parent (self-signed if nil)
+func (s *TestProxySuite) newTestCertificate(template *x509.Certificate, parent *testCertificate) testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(s.T(), err)
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Minute)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(s.T(), err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(s.T(), err)
+	return testCertificate{cert: cert, key: key}
+}
+
+// writeFiles writes the PEM-encoded certificate and key to the given directory, and returns their paths
+func (c testCertificate) writeFiles(s *TestProxySuite, dir, name string) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	require.NoError(s.T(), os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600))
+	der, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(s.T(), err)
+	require.NoError(s.T(), os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600))
+	return certFile, keyFile
+}
+
+func (s *TestProxySuite) TestClientCertUsername() {
+	// given
+	ca := s.newTestCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "ca"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	spiffe, err := url.Parse("spiffe://example.com/ci")
+	require.NoError(s.T(), err)
+	client := s.newTestCertificate(&x509.Certificate{
+		Subject:        pkix.Name{CommonName: "tekton-ci"},
+		DNSNames:       []string{"ci.example.com"},
+		EmailAddresses: []string{"ci@example.com"},
+		URIs:           []*url.URL{spiffe},
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+	newRequest := func(verified bool) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{client.cert}}
+		if verified {
+			req.TLS.VerifiedChains = [][]*x509.Certificate{{client.cert, ca.cert}}
+		}
+		return req
+	}
+
+	s.Run("mapped", func() {
+		for rules, expected := range map[string]string{
+			"cn:tekton-ci=smith":                           "smith",
+			"cn:other=john,dns:ci.example.com=smith":       "smith",
+			"email:ci@example.com=smith,cn:tekton-ci=john": "smith",
+			"uri:spiffe://example.com/ci=smith":            "smith",
+			"cn:*=*":                                       "tekton-ci",
+			"dns:*=smith":                                  "smith",
+		} {
+			s.Run(rules, func() {
+				// given
+				s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", rules)
+
+				// when
+				username, subject, found := clientCertUsername(newRequest(true))
+
+				// then
+				require.True(s.T(), found)
+				assert.Equal(s.T(), expected, username)
+				assert.Equal(s.T(), "CN=tekton-ci", subject)
+			})
+		}
+	})
+
+	s.Run("not mapped", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:other=smith,dns:other.example.com=smith")
+
+		// when
+		username, _, found := clientCertUsername(newRequest(true))
+
+		// then
+		assert.True(s.T(), found)
+		assert.Empty(s.T(), username)
+	})
+
+	s.Run("not verified", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:*=*")
+
+		// when
+		_, _, found := clientCertUsername(newRequest(false))
+
+		// then
+		assert.False(s.T(), found)
+	})
+
+	s.Run("user context", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:tekton-ci=smith")
+		p := &Proxy{}
+		var username, certificate string
+		var claims *auth.TokenClaims
+		handler := p.addUserContext()(func(ctx echo.Context) error {
+			username, _ = ctx.Get(context.UsernameKey).(string)
+			certificate, _ = ctx.Get(context.ClientCertificateKey).(string)
+			claims, _ = ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims)
+			return nil
+		})
+
+		// when
+		err := handler(echo.New().NewContext(newRequest(true), httptest.NewRecorder()))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "smith", username)
+		assert.Equal(s.T(), "CN=tekton-ci", certificate)
+		require.NotNil(s.T(), claims)
+		assert.Equal(s.T(), "smith", claims.PreferredUsername)
+
+		s.Run("rejected when not mapped", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CERT_RULES", "cn:other=smith")
+
+			// when
+			err := handler(echo.New().NewContext(newRequest(true), httptest.NewRecorder()))
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusUnauthorized, crtErr.Code)
+			assert.Equal(s.T(), "no user is mapped to the client certificate 'CN=tekton-ci'", crtErr.Details)
+		})
+	})
+}
+
+func (s *TestProxySuite) TestServerTLSConfig() {
+	// given
+	dir := s.T().TempDir()
+	ca := s.newTestCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "ca"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+	server := s.newTestCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "proxy"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}}, &ca)
+	certFile, keyFile := server.writeFiles(s, dir, "tls")
+	caFile, _ := ca.writeFiles(s, dir, "ca")
+	base := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	s.Run("no TLS", func() {
+		// when
+		tlsConfig, err := serverTLSConfig(base)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Nil(s.T(), tlsConfig)
+	})
+
+	s.Run("TLS without client certificates", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", certFile)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE", keyFile)
+
+		// when
+		tlsConfig, err := serverTLSConfig(base)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), tlsConfig.Certificates, 1)
+		assert.Equal(s.T(), uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+		assert.Equal(s.T(), tls.NoClientCert, tlsConfig.ClientAuth)
+	})
+
+	s.Run("TLS with client certificates", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", certFile)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE", keyFile)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CA_FILE", caFile)
+
+		// when
+		tlsConfig, err := serverTLSConfig(base)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+		assert.NotNil(s.T(), tlsConfig.ClientCAs)
+	})
+
+	s.Run("invalid files", func() {
+		for name, tc := range map[string]struct {
+			certFile string
+			caFile   string
+			err      string
+		}{
+			"missing certificate": {
+				certFile: filepath.Join(dir, "missing.crt"),
+				err:      "unable to load the TLS certificate of the proxy",
+			},
+			"missing authorities": {
+				certFile: certFile,
+				caFile:   filepath.Join(dir, "missing.crt"),
+				err:      "unable to read the client certificate authorities of the proxy",
+			},
+			"no authority": {
+				certFile: certFile,
+				caFile:   keyFile,
+				err:      "no certificate found in the client certificate authorities file",
+			},
+		} {
+			s.Run(name, func() {
+				// given
+				s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", tc.certFile)
+				s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE", keyFile)
+				s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CA_FILE", tc.caFile)
+
+				// when
+				_, err := serverTLSConfig(base)
+
+				// then
+				require.ErrorContains(s.T(), err, tc.err)
+			})
+		}
+	})
+}
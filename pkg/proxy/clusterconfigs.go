@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// clusterConfigRefreshInterval is the minimum interval between two refreshes of the config of a member cluster
+// triggered by the responses of the member cluster (see ClusterConfigRefresher.Refresh)
+const clusterConfigRefreshInterval = 30 * time.Second
+
+// ToolchainClusterUpdater updates the cached config of a member cluster from its ToolchainCluster,
+// eg. the cluster.ToolchainClusterService
+type ToolchainClusterUpdater interface {
+	AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error
+}
+
+// ClusterConfigRefresher refreshes the cached configs of the member clusters when the Secrets of their ToolchainClusters
+// change, so that the proxy doesn't keep on impersonating the users with a stale token once the token of the service account
+// was rotated, until the service is restarted
+type ClusterConfigRefresher struct {
+	client  namespaced.Client
+	updater ToolchainClusterUpdater
+	mu      sync.Mutex
+	// refreshed is the time of the last refresh of each member cluster triggered by Refresh
+	refreshed map[string]time.Time
+}
+
+// NewClusterConfigRefresher returns a new ClusterConfigRefresher updating the cached configs of the member clusters with the given updater
+func NewClusterConfigRefresher(client namespaced.Client, updater ToolchainClusterUpdater) *ClusterConfigRefresher {
+	return &ClusterConfigRefresher{
+		client:    client,
+		updater:   updater,
+		refreshed: map[string]time.Time{},
+	}
+}
+
+// WatchSecrets refreshes the configs of the member clusters whenever the Secrets of their ToolchainClusters are updated
+func (r *ClusterConfigRefresher) WatchSecrets(ctx context.Context, informers cache.Informers) error {
+	informer, err := informers.GetInformer(ctx, &corev1.Secret{})
+	if err != nil {
+		return err
+	}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSecret, ok := oldObj.(*corev1.Secret)
+			newSecret, ok2 := newObj.(*corev1.Secret)
+			if !ok || !ok2 || oldSecret.ResourceVersion == newSecret.ResourceVersion {
+				// periodic resync
+				return
+			}
+			if err := r.SecretChanged(ctx, newSecret.Name); err != nil {
+				log.Error(nil, err, fmt.Sprintf("unable to refresh the member clusters using the '%s' secret", newSecret.Name))
+			}
+		},
+	})
+	return err
+}
+
+// SecretChanged refreshes the configs of the member clusters whose ToolchainCluster references the Secret with the given name
+func (r *ClusterConfigRefresher) SecretChanged(ctx context.Context, secretName string) error {
+	clusters := &toolchainv1alpha1.ToolchainClusterList{}
+	if err := r.client.List(ctx, clusters, client.InNamespace(r.client.Namespace)); err != nil {
+		return err
+	}
+	for i := range clusters.Items {
+		if clusters.Items[i].Spec.SecretRef.Name != secretName {
+			continue
+		}
+		if err := r.update(&clusters.Items[i]); err != nil {
+			return err
+		}
+		log.Infof(nil, "refreshed the config of the '%s' member cluster after its '%s' secret changed", clusters.Items[i].Name, secretName)
+	}
+	return nil
+}
+
+// Refresh refreshes the config of the member cluster with the given name, eg. when it rejected the token of the service account
+// used to impersonate the users, unless it was already refreshed less than a clusterConfigRefreshInterval ago, so that a member cluster
+// which keeps on rejecting the token doesn't trigger a refresh for every request. Returns true if the config was refreshed.
+func (r *ClusterConfigRefresher) Refresh(ctx context.Context, clusterName string, now time.Time) (bool, error) {
+	r.mu.Lock()
+	if last, found := r.refreshed[clusterName]; found && now.Sub(last) < clusterConfigRefreshInterval {
+		r.mu.Unlock()
+		return false, nil
+	}
+	r.refreshed[clusterName] = now
+	r.mu.Unlock()
+
+	cluster := &toolchainv1alpha1.ToolchainCluster{}
+	if err := r.client.Get(ctx, r.client.NamespacedName(clusterName), cluster); err != nil {
+		return false, err
+	}
+	if err := r.update(cluster); err != nil {
+		return false, err
+	}
+	log.Infof(nil, "refreshed the config of the '%s' member cluster", clusterName)
+	return true, nil
+}
+
+func (r *ClusterConfigRefresher) update(cluster *toolchainv1alpha1.ToolchainCluster) error {
+	if err := r.updater.AddOrUpdateToolchainCluster(cluster); err != nil {
+		return fmt.Errorf("unable to refresh the config of the '%s' member cluster: %w", cluster.Name, err)
+	}
+	return nil
+}
+
+// refreshClusterConfig refreshes the config of the member cluster of the given target, if the refresher is configured,
+// and drops its short-lived token, if any
+func (p *Proxy) refreshClusterConfig(ctx context.Context, target *access.ClusterAccess) {
+	if p.impersonatorTokens != nil && target.ClusterName() != "" {
+		p.impersonatorTokens.invalidate(target.ClusterName())
+	}
+	if p.clusterConfigRefresher == nil || target.ClusterName() == "" {
+		return
+	}
+	if _, err := p.clusterConfigRefresher.Refresh(ctx, target.ClusterName(), time.Now()); err != nil {
+		log.Error(nil, err, "unable to refresh the config of the member cluster after it rejected the token")
+	}
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+)
+
+type fakeToolchainClusterUpdater struct {
+	mu      sync.Mutex
+	updated []string
+	err     error
+}
+
+func (u *fakeToolchainClusterUpdater) AddOrUpdateToolchainCluster(cluster *toolchainv1alpha1.ToolchainCluster) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.err != nil {
+		return u.err
+	}
+	u.updated = append(u.updated, cluster.Name)
+	return nil
+}
+
+func (u *fakeToolchainClusterUpdater) Updated() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.updated
+}
+
+func (s *TestProxySuite) TestClusterConfigRefresher() {
+	// given
+	newToolchainCluster := func(name, secretName string) *toolchainv1alpha1.ToolchainCluster {
+		return &toolchainv1alpha1.ToolchainCluster{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: commontest.HostOperatorNs,
+			},
+			Spec: toolchainv1alpha1.ToolchainClusterSpec{
+				SecretRef: toolchainv1alpha1.LocalSecretReference{
+					Name: secretName,
+				},
+			},
+		}
+	}
+	nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(),
+		newToolchainCluster("member-1", "member-1-secret"),
+		newToolchainCluster("member-2", "member-2-secret"),
+		newToolchainCluster("member-3", "member-1-secret")), commontest.HostOperatorNs)
+	now := time.Now()
+
+	s.Run("secret changed", func() {
+		// given
+		updater := &fakeToolchainClusterUpdater{}
+		refresher := NewClusterConfigRefresher(nsClient, updater)
+
+		// when
+		err := refresher.SecretChanged(context.TODO(), "member-1-secret")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.ElementsMatch(s.T(), []string{"member-1", "member-3"}, updater.Updated())
+	})
+
+	s.Run("unknown secret changed", func() {
+		// given
+		updater := &fakeToolchainClusterUpdater{}
+		refresher := NewClusterConfigRefresher(nsClient, updater)
+
+		// when
+		err := refresher.SecretChanged(context.TODO(), "unknown")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), updater.Updated())
+	})
+
+	s.Run("refresh", func() {
+		// given
+		updater := &fakeToolchainClusterUpdater{}
+		refresher := NewClusterConfigRefresher(nsClient, updater)
+
+		// when
+		refreshed, err := refresher.Refresh(context.TODO(), "member-2", now)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), refreshed)
+		assert.Equal(s.T(), []string{"member-2"}, updater.Updated())
+
+		s.Run("not refreshed again within the interval", func() {
+			// when
+			refreshed, err := refresher.Refresh(context.TODO(), "member-2", now.Add(clusterConfigRefreshInterval-time.Second))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.False(s.T(), refreshed)
+			assert.Equal(s.T(), []string{"member-2"}, updater.Updated())
+		})
+
+		s.Run("other member cluster refreshed within the interval", func() {
+			// when
+			refreshed, err := refresher.Refresh(context.TODO(), "member-1", now.Add(time.Second))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.True(s.T(), refreshed)
+			assert.Equal(s.T(), []string{"member-2", "member-1"}, updater.Updated())
+		})
+
+		s.Run("refreshed again after the interval", func() {
+			// when
+			refreshed, err := refresher.Refresh(context.TODO(), "member-2", now.Add(clusterConfigRefreshInterval))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.True(s.T(), refreshed)
+			assert.Equal(s.T(), []string{"member-2", "member-1", "member-2"}, updater.Updated())
+		})
+	})
+
+	s.Run("refresh fails", func() {
+		s.Run("unknown member cluster", func() {
+			// given
+			refresher := NewClusterConfigRefresher(nsClient, &fakeToolchainClusterUpdater{})
+
+			// when
+			refreshed, err := refresher.Refresh(context.TODO(), "unknown", now)
+
+			// then
+			require.Error(s.T(), err)
+			assert.False(s.T(), refreshed)
+		})
+
+		s.Run("update error", func() {
+			// given
+			refresher := NewClusterConfigRefresher(nsClient, &fakeToolchainClusterUpdater{err: errors.New("invalid kubeconfig")})
+
+			// when
+			refreshed, err := refresher.Refresh(context.TODO(), "member-1", now)
+
+			// then
+			require.EqualError(s.T(), err, "unable to refresh the config of the 'member-1' member cluster: invalid kubeconfig")
+			assert.False(s.T(), refreshed)
+		})
+	})
+
+	s.Run("watch secrets", func() {
+		// given
+		updater := &fakeToolchainClusterUpdater{}
+		refresher := NewClusterConfigRefresher(nsClient, updater)
+		informers := &informertest.FakeInformers{}
+		require.NoError(s.T(), refresher.WatchSecrets(context.TODO(), informers))
+		informer, err := informers.FakeInformerFor(context.TODO(), &corev1.Secret{})
+		require.NoError(s.T(), err)
+		secret := func(name, resourceVersion string) *corev1.Secret {
+			return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: commontest.HostOperatorNs, ResourceVersion: resourceVersion}}
+		}
+
+		// when
+		informer.Add(secret("member-2-secret", "1"))
+		informer.Update(secret("member-2-secret", "1"), secret("member-2-secret", "1"))
+		informer.Update(secret("member-1-secret", "1"), secret("member-1-secret", "2"))
+
+		// then only the actual update of the secret triggers a refresh
+		assert.ElementsMatch(s.T(), []string{"member-1", "member-3"}, updater.Updated())
+	})
+
+	s.Run("refresh the member cluster rejecting the token", func() {
+		// given
+		updater := &fakeToolchainClusterUpdater{}
+		apiURL, err := url.Parse("https://api.member-1.com")
+		require.NoError(s.T(), err)
+
+		s.Run("refresher configured", func() {
+			// given
+			p := &Proxy{clusterConfigRefresher: NewClusterConfigRefresher(nsClient, updater)}
+
+			// when
+			p.refreshClusterConfig(context.TODO(), access.NewMemberClusterAccess("member-1", *apiURL, "token", "smith", nil))
+			p.refreshClusterConfig(context.TODO(), access.NewClusterAccess(*apiURL, "token", "smith"))
+
+			// then
+			assert.Equal(s.T(), []string{"member-1"}, updater.Updated())
+		})
+
+		s.Run("no refresher", func() {
+			// given
+			p := &Proxy{}
+
+			// when
+			p.refreshClusterConfig(context.TODO(), access.NewMemberClusterAccess("member-1", *apiURL, "token", "smith", nil))
+
+			// then nothing happens
+			assert.Equal(s.T(), []string{"member-1"}, updater.Updated())
+		})
+	})
+}
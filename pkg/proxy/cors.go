@@ -75,9 +75,13 @@ func isMethodAllowed(method string) bool {
 
 type responseModifier struct {
 	requestOrigin string
+	// withSession is true if the request was sent with the cookie of a session (see Sessions), which authenticates the request
+	// by itself, and thus can't be used by the credentialed CORS requests of the other sites
+	withSession bool
 }
 
-// addCorsToResponse adds CORS headers to the response
+// addCorsToResponse adds CORS headers to the response. The credentialed CORS requests are not allowed for the requests
+// with the cookie of a session.
 func (r *responseModifier) addCorsToResponse(response *http.Response) error {
 	origin := r.requestOrigin
 	if origin == "" {
@@ -86,7 +90,11 @@ func (r *responseModifier) addCorsToResponse(response *http.Response) error {
 	// CORS Headers
 	response.Header.Add("Vary", "Origin")
 	response.Header.Set("Access-Control-Allow-Origin", origin)
-	response.Header.Set("Access-Control-Allow-Credentials", "true")
+	if r.withSession {
+		response.Header.Del("Access-Control-Allow-Credentials")
+	} else {
+		response.Header.Set("Access-Control-Allow-Credentials", "true")
+	}
 	response.Header.Set("Access-Control-Expose-Headers", "Content-Length, Content-Encoding, Authorization")
 
 	return nil
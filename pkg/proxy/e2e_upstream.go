@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+)
+
+// E2EUpstreamHeader is the header of the requests which the proxy forwards to the given URL instead of the member cluster
+// of the workspace, so that the e2e tests can direct the requests to stub servers. The header is only honored in the test
+// environments, for the URLs allowed with the E2EUpstreamAllowlist setting, and is never forwarded.
+const E2EUpstreamHeader = "X-Sandbox-E2E-Upstream"
+
+// e2eUpstreamOverride returns the given target of the request, with the URL of the E2EUpstreamHeader of the request, if any.
+// The user is still impersonated with the service account of the member cluster of the workspace.
+func e2eUpstreamOverride(req *http.Request, target *access.ClusterAccess) (*access.ClusterAccess, error) {
+	upstream := req.Header.Get(E2EUpstreamHeader)
+	if upstream == "" {
+		return target, nil
+	}
+	req.Header.Del(E2EUpstreamHeader)
+	cfg := configuration.GetRegistrationServiceConfig()
+	if !cfg.IsTestEnvironment() {
+		log.Infof(nil, "ignoring the %s header in the '%s' environment", E2EUpstreamHeader, cfg.Environment())
+		return target, nil
+	}
+	upstreamURL, err := url.Parse(upstream)
+	if err != nil || !slices.ContainsFunc(cfg.Proxy().E2EUpstreamAllowlist(), func(allowed string) bool {
+		allowedURL, err := url.Parse(allowed)
+		return err == nil && strings.EqualFold(allowedURL.Scheme, upstreamURL.Scheme) && strings.EqualFold(allowedURL.Host, upstreamURL.Host)
+	}) {
+		return nil, crterrors.NewForbiddenError("invalid upstream override", fmt.Sprintf("the '%s' upstream is not allowed", upstream))
+	}
+	return access.NewMemberClusterAccess(target.ClusterName(), *upstreamURL, target.ImpersonatorToken(), target.Username(), nil), nil
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestE2EUpstreamOverride() {
+	// given
+	apiURL, err := url.Parse("https://api.member-1.com:6443")
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *apiURL, "clusterSAToken", "smith", nil)
+	newRequest := func(upstream string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/pods", nil)
+		if upstream != "" {
+			req.Header.Set(E2EUpstreamHeader, upstream)
+		}
+		return req
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_E2E_UPSTREAM_ALLOWLIST", "http://127.0.0.1:9090,https://stub.example.com")
+
+	s.Run("no override", func() {
+		// when
+		result, err := e2eUpstreamOverride(newRequest(""), target)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Same(s.T(), target, result)
+	})
+
+	s.Run("allowed upstream", func() {
+		for _, upstream := range []string{"http://127.0.0.1:9090", "https://STUB.example.com/base"} {
+			s.Run(upstream, func() {
+				// given
+				req := newRequest(upstream)
+
+				// when
+				result, err := e2eUpstreamOverride(req, target)
+
+				// then
+				require.NoError(s.T(), err)
+				apiURL := result.APIURL()
+				assert.Equal(s.T(), upstream, apiURL.String())
+				assert.Equal(s.T(), "member-1", result.ClusterName())
+				assert.Equal(s.T(), "clusterSAToken", result.ImpersonatorToken())
+				assert.Equal(s.T(), "smith", result.Username())
+				assert.Empty(s.T(), req.Header.Get(E2EUpstreamHeader), "the header should not be forwarded")
+			})
+		}
+	})
+
+	s.Run("upstream not allowed", func() {
+		for _, upstream := range []string{"https://127.0.0.1:9090", "http://127.0.0.1:9091", "http://evil.com", "://invalid"} {
+			s.Run(upstream, func() {
+				// when
+				_, err := e2eUpstreamOverride(newRequest(upstream), target)
+
+				// then
+				require.EqualError(s.T(), err, "invalid upstream override: the '"+upstream+"' upstream is not allowed")
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+			})
+		}
+	})
+
+	s.Run("header ignored outside of the test environments", func() {
+		// given
+		env := s.DefaultConfig().Environment()
+		defer s.SetConfig(testconfig.RegistrationService().
+			Environment(env))
+		s.SetConfig(testconfig.RegistrationService().
+			Environment("prod"))
+		req := newRequest("http://127.0.0.1:9090")
+
+		// when
+		result, err := e2eUpstreamOverride(req, target)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Same(s.T(), target, result)
+		assert.Empty(s.T(), req.Header.Get(E2EUpstreamHeader), "the header should not be forwarded")
+	})
+}
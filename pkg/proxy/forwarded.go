@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// forwardedHeaders are the headers set by the upstream proxies to describe the original request.
+// They are only kept when the request comes from a trusted proxy (or passed through when no trusted proxy is configured),
+// since they can be spoofed by any other client.
+var forwardedHeaders = []string{
+	"Forwarded",
+	"X-Forwarded-For",
+	"X-Forwarded-Host",
+	"X-Forwarded-Proto",
+	"X-Real-Ip",
+}
+
+// ipExtractor returns the function used to extract the IP of the client from the requests:
+// the X-Forwarded-For header is only used when the request comes from one of the given trusted proxies,
+// and the IP of the client is the last (ie. the closest) untrusted IP in the header.
+// When there is no trusted proxy, nil is returned so that the client IP is looked up from the headers as before,
+// eg. from the X-Forwarded-For header set by the OpenShift router.
+func ipExtractor(trustedProxies []*net.IPNet) echo.IPExtractor {
+	if len(trustedProxies) == 0 {
+		return nil
+	}
+	// only trust the configured proxies, not the whole private network (which is trusted by default)
+	options := []echo.TrustOption{
+		echo.TrustLoopback(false),
+		echo.TrustLinkLocal(false),
+		echo.TrustPrivateNet(false),
+	}
+	for _, ipRange := range trustedProxies {
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+	return echo.ExtractIPFromXFFHeader(options...)
+}
+
+// isTrustedProxy returns true if the given remote address (host:port) belongs to one of the trusted proxies.
+// No peer is trusted when there is no trusted proxy.
+func isTrustedProxy(remoteAddr string, trustedProxies []*net.IPNet) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipRange := range trustedProxies {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// setForwardedHeaders sets the X-Forwarded-Proto and X-Forwarded-Host headers of the request forwarded to the member cluster
+// (X-Forwarded-For is appended by the reverse proxy itself), after removing all the forwarded headers which were set by
+// an untrusted client. The headers set by a trusted proxy are left untouched since they describe the original request.
+// originalHost is the host requested by the client, before it was (possibly) replaced by the host of the target.
+// The headers are passed through as they are when there is no trusted proxy, so that the ones set by the OpenShift router are
+// forwarded, but they are not trusted by the proxy itself (see requestScheme).
+func setForwardedHeaders(req *http.Request, originalHost string, trustedProxies []*net.IPNet) {
+	if len(trustedProxies) == 0 {
+		return
+	}
+	if !isTrustedProxy(req.RemoteAddr, trustedProxies) {
+		for _, h := range forwardedHeaders {
+			req.Header.Del(h)
+		}
+	}
+	if req.Header.Get("X-Forwarded-Proto") == "" {
+		proto := "http"
+		if req.TLS != nil {
+			proto = "https"
+		}
+		req.Header.Set("X-Forwarded-Proto", proto)
+	}
+	if req.Header.Get("X-Forwarded-Host") == "" && originalHost != "" {
+		req.Header.Set("X-Forwarded-Host", originalHost)
+	}
+}
+
+// requestScheme returns the scheme of the original request: the one of the X-Forwarded-Proto header if the request comes from
+// one of the given trusted proxies, or else 'https' if the request was received over TLS, and 'http' otherwise.
+// The header is ignored when there is no trusted proxy.
+func requestScheme(req *http.Request, trustedProxies []*net.IPNet) string {
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto != "" && isTrustedProxy(req.RemoteAddr, trustedProxies) {
+		return proto
+	}
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
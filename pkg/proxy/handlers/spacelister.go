@@ -42,6 +42,10 @@ func NewSpaceLister(client namespaced.Client, app application.Application, proxy
 
 func (s *SpaceLister) GetProvisionedUserSignup(ctx echo.Context) (*signup.Signup, error) {
 	username, _ := ctx.Get(context.UsernameKey).(string)
+	if context.IsAnonymous(ctx) {
+		// the anonymous requests have no UserSignup
+		return nil, nil
+	}
 
 	userSignup, err := s.GetSignupFunc(nil, username, false)
 	if err != nil {
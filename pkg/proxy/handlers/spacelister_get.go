@@ -69,8 +69,8 @@ func GetUserWorkspace(ctx echo.Context, spaceLister *SpaceLister, workspaceName
 }
 
 // getUserOrPublicViewerSpaceBinding retrieves the user space binding for an user and a space.
-// If the SpaceBinding is not found and the PublicViewer feature is enabled, it will retry
-// with the PublicViewer credentials.
+// If the SpaceBinding is not found, it will retry with the groups of the user and then,
+// if the PublicViewer feature is enabled, with the PublicViewer credentials.
 func getUserOrPublicViewerSpaceBinding(ctx echo.Context, spaceLister *SpaceLister, space *toolchainv1alpha1.Space, userSignup *signup.Signup, workspaceName string) (*toolchainv1alpha1.SpaceBinding, error) {
 	userSpaceBinding, err := getUserSpaceBinding(spaceLister, space, userSignup.CompliantUsername)
 	if err != nil {
@@ -78,6 +78,11 @@ func getUserOrPublicViewerSpaceBinding(ctx echo.Context, spaceLister *SpaceListe
 		return nil, err
 	}
 
+	// if user space binding is not found, retry with the groups of the user
+	if userSpaceBinding == nil {
+		userSpaceBinding = GroupSpaceBinding(ctx, userSignup.CompliantUsername, space.Name)
+	}
+
 	// if user space binding is not found and PublicViewer is enabled,
 	// retry with PublicViewer's signup
 	if userSpaceBinding == nil {
@@ -163,6 +168,10 @@ func GetUserWorkspaceWithBindings(ctx echo.Context, spaceLister *SpaceLister, wo
 
 	// check if user has access to this workspace
 	userBinding := filterUserSpaceBinding(userSignup.CompliantUsername, allSpaceBindings)
+	if userBinding == nil {
+		// check if the groups of the user have access to this workspace
+		userBinding = GroupSpaceBinding(ctx, userSignup.CompliantUsername, space.Name)
+	}
 	if userBinding == nil {
 		// if PublicViewer is enabled, check if the Space is visibile to PublicViewer
 		// in case usersignup is the KubesawAuthenticatedUsername, then we already checked in the previous step
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"slices"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/labstack/echo/v4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// groupSpaceBindings returns the SpaceBindings of the roles granted to the groups of the user of the request (see the GroupRoles
+// setting), at most one per workspace. These SpaceBindings don't exist in the cluster: they are only used to build the Workspaces
+// of the user. None is returned for the public viewer.
+func groupSpaceBindings(ctx echo.Context, compliantUsername string) []toolchainv1alpha1.SpaceBinding {
+	claims, _ := ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims)
+	if claims == nil || compliantUsername == "" || compliantUsername == toolchainv1alpha1.KubesawAuthenticatedUsername {
+		return nil
+	}
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	groups := claims.StringValues(cfg.GroupClaim())
+	bindings := []toolchainv1alpha1.SpaceBinding{}
+	for _, groupRole := range cfg.GroupRoles() {
+		if !slices.Contains(groups, groupRole.Group) || slices.ContainsFunc(bindings, func(binding toolchainv1alpha1.SpaceBinding) bool {
+			return binding.Spec.Space == groupRole.Workspace
+		}) {
+			// the first matching role applies
+			continue
+		}
+		bindings = append(bindings, toolchainv1alpha1.SpaceBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: groupRole.Workspace + "-group-" + groupRole.Group,
+				Labels: map[string]string{
+					toolchainv1alpha1.SpaceBindingMasterUserRecordLabelKey: compliantUsername,
+					toolchainv1alpha1.SpaceBindingSpaceLabelKey:            groupRole.Workspace,
+				},
+			},
+			Spec: toolchainv1alpha1.SpaceBindingSpec{
+				MasterUserRecord: compliantUsername,
+				Space:            groupRole.Workspace,
+				SpaceRole:        groupRole.Role,
+			},
+		})
+	}
+	return bindings
+}
+
+// GroupSpaceBinding returns the SpaceBinding of the role granted in the given workspace to the groups of the user of the request,
+// or nil if none of the groups of the user has access to the workspace.
+func GroupSpaceBinding(ctx echo.Context, compliantUsername, workspaceName string) *toolchainv1alpha1.SpaceBinding {
+	for _, binding := range groupSpaceBindings(ctx, compliantUsername) {
+		if binding.Spec.Space == workspaceName {
+			return &binding
+		}
+	}
+	return nil
+}
+
+// withGroupSpaceBindings returns the given SpaceBindings of the user along with the SpaceBindings of the roles granted to the groups
+// of the user in the workspaces the user has no SpaceBinding for. The role of the groups takes precedence over the one of the public
+// viewer, whose SpaceBindings are removed for these workspaces.
+func withGroupSpaceBindings(ctx echo.Context, compliantUsername string, spaceBindings []toolchainv1alpha1.SpaceBinding) []toolchainv1alpha1.SpaceBinding {
+	for _, groupBinding := range groupSpaceBindings(ctx, compliantUsername) {
+		spaceName := groupBinding.Spec.Space
+		if slices.ContainsFunc(spaceBindings, func(binding toolchainv1alpha1.SpaceBinding) bool {
+			return binding.Spec.MasterUserRecord == compliantUsername && binding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey] == spaceName
+		}) {
+			continue
+		}
+		spaceBindings = slices.DeleteFunc(spaceBindings, func(binding toolchainv1alpha1.SpaceBinding) bool {
+			return binding.Spec.MasterUserRecord == toolchainv1alpha1.KubesawAuthenticatedUsername && binding.Labels[toolchainv1alpha1.SpaceBindingSpaceLabelKey] == spaceName
+		})
+		spaceBindings = append(spaceBindings, groupBinding)
+	}
+	return spaceBindings
+}
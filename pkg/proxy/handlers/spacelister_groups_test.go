@@ -0,0 +1,189 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	rcontext "github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/codeready-toolchain/registration-service/test/fake"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commonproxy "github.com/codeready-toolchain/toolchain-common/pkg/proxy"
+	"github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestGroupRoles(t *testing.T) {
+	// given
+	fakeSignupService, fakeClient := buildSpaceListerFakesWithResources(t, nil, []runtimeclient.Object{
+		fake.NewSpace("communitylover", "member-1", "communitylover"),
+		fake.NewSpaceBinding("communitylover-publicviewer", toolchainv1alpha1.KubesawAuthenticatedUsername, "communitylover", "viewer"),
+	})
+	s := &handlers.SpaceLister{
+		Client:        namespaced.NewClient(fakeClient, test.HostOperatorNs),
+		GetSignupFunc: fakeSignupService.GetSignup,
+		ProxyMetrics:  metrics.NewProxyMetrics(prometheus.NewRegistry()),
+	}
+	newContext := func(t *testing.T, username, claims string, publicViewerEnabled bool) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/", strings.NewReader("")), httptest.NewRecorder())
+		ctx.Set(rcontext.UsernameKey, username)
+		ctx.Set(rcontext.RequestReceivedTime, time.Now())
+		ctx.Set(rcontext.PublicViewerEnabled, publicViewerEnabled)
+		token := &auth.TokenClaims{}
+		require.NoError(t, json.Unmarshal([]byte(claims), token))
+		ctx.Set(rcontext.JWTClaimsKey, token)
+		return ctx
+	}
+	getMembersFuncMock := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Client: test.NewFakeClient(t),
+				Config: &commoncluster.Config{
+					Name: "not-me",
+				},
+			},
+		}
+	}
+	t.Setenv("REGISTRATION_SERVICE_PROXY_GROUP_ROLES", "payments-team=movielover:viewer,payments-team=movielover:admin,auditors=dancelover:viewer,payments-team=communitylover:contributor")
+
+	t.Run("list", func(t *testing.T) {
+		for name, tc := range map[string]struct {
+			username            string
+			claims              string
+			publicViewerEnabled bool
+			expected            func() []toolchainv1alpha1.Workspace
+		}{
+			"workspaces of the groups": {
+				username: "usernospace",
+				claims:   `{"groups":["payments-team","auditors"]}`,
+				expected: func() []toolchainv1alpha1.Workspace {
+					return []toolchainv1alpha1.Workspace{
+						workspaceFor(t, fakeClient, "movielover", "viewer", false),
+						workspaceFor(t, fakeClient, "dancelover", "viewer", false),
+						workspaceFor(t, fakeClient, "communitylover", "contributor", false),
+					}
+				},
+			},
+			"space bindings take precedence": {
+				username: "dancelover",
+				claims:   `{"groups":["payments-team","auditors"]}`,
+				expected: func() []toolchainv1alpha1.Workspace {
+					return []toolchainv1alpha1.Workspace{
+						workspaceFor(t, fakeClient, "dancelover", "admin", true),
+						workspaceFor(t, fakeClient, "movielover", "other", false),
+						workspaceFor(t, fakeClient, "communitylover", "contributor", false),
+					}
+				},
+			},
+			"groups take precedence over the public viewer": {
+				username:            "usernospace",
+				claims:              `{"groups":"payments-team"}`,
+				publicViewerEnabled: true,
+				expected: func() []toolchainv1alpha1.Workspace {
+					return []toolchainv1alpha1.Workspace{
+						workspaceFor(t, fakeClient, "movielover", "viewer", false),
+						workspaceFor(t, fakeClient, "communitylover", "contributor", false),
+					}
+				},
+			},
+			"no group": {
+				username: "usernospace",
+				claims:   `{"groups":["other"]}`,
+				expected: func() []toolchainv1alpha1.Workspace {
+					return []toolchainv1alpha1.Workspace{}
+				},
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				// when
+				workspaces, err := handlers.ListUserWorkspaces(newContext(t, tc.username, tc.claims, tc.publicViewerEnabled), s)
+
+				// then
+				require.NoError(t, err)
+				expected := tc.expected()
+				require.Len(t, workspaces, len(expected))
+				for i, w := range workspaces {
+					assert.Equal(t, expected[i].Name, w.Name)
+					assert.Equal(t, expected[i].Status, w.Status)
+				}
+			})
+		}
+
+		t.Run("other group claim", func(t *testing.T) {
+			// given
+			t.Setenv("REGISTRATION_SERVICE_PROXY_GROUP_CLAIM", "roles")
+
+			// when
+			workspaces, err := handlers.ListUserWorkspaces(newContext(t, "usernospace", `{"groups":["payments-team"],"roles":["auditors"]}`, false), s)
+
+			// then
+			require.NoError(t, err)
+			require.Len(t, workspaces, 1)
+			assert.Equal(t, workspaceFor(t, fakeClient, "dancelover", "viewer", false).Status, workspaces[0].Status)
+		})
+	})
+
+	t.Run("get", func(t *testing.T) {
+		t.Run("workspace of the groups", func(t *testing.T) {
+			// when
+			workspace, err := handlers.GetUserWorkspace(newContext(t, "usernospace", `{"groups":["payments-team"]}`, false), s, "movielover")
+
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, workspace)
+			assert.Equal(t, workspaceFor(t, fakeClient, "movielover", "viewer", false), *workspace)
+		})
+
+		t.Run("space binding takes precedence", func(t *testing.T) {
+			// when
+			workspace, err := handlers.GetUserWorkspace(newContext(t, "dancelover", `{"groups":["payments-team"]}`, false), s, "movielover")
+
+			// then
+			require.NoError(t, err)
+			require.NotNil(t, workspace)
+			assert.Equal(t, "other", workspace.Status.Role)
+		})
+
+		t.Run("not a workspace of the groups", func(t *testing.T) {
+			// when
+			workspace, err := handlers.GetUserWorkspace(newContext(t, "usernospace", `{"groups":["payments-team"]}`, false), s, "dancelover")
+
+			// then
+			require.NoError(t, err)
+			assert.Nil(t, workspace)
+		})
+
+		t.Run("with bindings", func(t *testing.T) {
+			// when
+			workspace, err := handlers.GetUserWorkspaceWithBindings(newContext(t, "usernospace", `{"groups":["payments-team"]}`, true), s, "communitylover", getMembersFuncMock)
+
+			// then the group is not listed in the bindings of the workspace
+			require.NoError(t, err)
+			require.NotNil(t, workspace)
+			assert.Equal(t, "contributor", workspace.Status.Role)
+			assert.Equal(t, []toolchainv1alpha1.Binding{
+				{
+					MasterUserRecord: toolchainv1alpha1.KubesawAuthenticatedUsername,
+					Role:             "viewer",
+					AvailableActions: []string{},
+				},
+			}, workspace.Status.Bindings)
+			assert.Equal(t, workspaceFor(t, fakeClient, "communitylover", "contributor", false,
+				commonproxy.WithBindings(workspace.Status.Bindings),
+				commonproxy.WithAvailableRoles([]string{"admin", "viewer"}),
+			), *workspace)
+		})
+	})
+}
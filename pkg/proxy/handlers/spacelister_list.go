@@ -36,7 +36,8 @@ func HandleSpaceListRequest(spaceLister *SpaceLister) echo.HandlerFunc {
 }
 
 // ListUserWorkspaces returns a list of Workspaces for the current user.
-// The function lists all SpaceBindings for the user and return all the workspaces found from this list.
+// The function lists all SpaceBindings for the user and return all the workspaces found from this list,
+// along with the workspaces the groups of the user have access to.
 func ListUserWorkspaces(ctx echo.Context, spaceLister *SpaceLister) ([]toolchainv1alpha1.Workspace, error) {
 	signup, err := spaceLister.GetProvisionedUserSignup(ctx)
 	if err != nil {
@@ -56,6 +57,8 @@ func ListUserWorkspaces(ctx echo.Context, spaceLister *SpaceLister) ([]toolchain
 		ctx.Logger().Error(errs.Wrap(err, "error listing space bindings"))
 		return nil, err
 	}
+	// add the workspaces the groups of the user have access to
+	spaceBindings = withGroupSpaceBindings(ctx, signup.CompliantUsername, spaceBindings)
 
 	return workspacesFromSpaceBindings(ctx, spaceLister, signup.Name, spaceBindings), nil
 }
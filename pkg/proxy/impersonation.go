@@ -0,0 +1,38 @@
+package proxy
+
+import (
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+)
+
+// impersonatedGroups returns the groups impersonated, along with the user, when forwarding the request to the given cluster,
+// so that the RBAC of the member clusters can be expressed with groups: the values of the configured token claims
+// (unless the public viewer is impersonated), and the role of the user in the targeted workspace (see the ImpersonateGroupClaims
+// and ImpersonateRoleGroupPrefix settings). The system groups are never impersonated.
+func impersonatedGroups(ctx echo.Context, target *access.ClusterAccess) []string {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	groups := []string{}
+	if claims, ok := ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims); ok && target.Username() != toolchainv1alpha1.KubesawAuthenticatedUsername {
+		for _, claim := range cfg.ImpersonateGroupClaims() {
+			groups = append(groups, claims.StringValues(claim)...)
+		}
+	}
+	if prefix := cfg.ImpersonateRoleGroupPrefix(); prefix != "" {
+		if role, _ := ctx.Get(context.WorkspaceRoleKey).(string); role != "" {
+			groups = append(groups, prefix+role)
+		}
+	}
+	groups = slices.DeleteFunc(groups, func(group string) bool {
+		// the system groups would grant the privileges of the cluster components
+		return group == "" || strings.HasPrefix(group, "system:")
+	})
+	slices.Sort(groups)
+	return slices.Compact(groups)
+}
@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestImpersonatedGroups() {
+	// given
+	claims := &auth.TokenClaims{}
+	require.NoError(s.T(), json.Unmarshal([]byte(`{
+		"preferred_username": "smith",
+		"groups": ["dev", "system:masters", "", "qa"],
+		"roles": "dev",
+		"admin": true
+	}`), claims))
+	newContext := func(role string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/pods", nil), httptest.NewRecorder())
+		ctx.Set(context.JWTClaimsKey, claims)
+		if role != "" {
+			ctx.Set(context.WorkspaceRoleKey, role)
+		}
+		return ctx
+	}
+	smith := access.NewClusterAccess(url.URL{}, "token", "smith")
+	publicViewer := access.NewClusterAccess(url.URL{}, "token", toolchainv1alpha1.KubesawAuthenticatedUsername)
+
+	s.Run("no group by default", func() {
+		assert.Empty(s.T(), impersonatedGroups(newContext("admin"), smith))
+	})
+
+	s.Run("groups from the token claims", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_GROUP_CLAIMS", "groups,roles,admin,unknown")
+
+		// when
+		groups := impersonatedGroups(newContext(""), smith)
+
+		// then
+		assert.Equal(s.T(), []string{"dev", "qa"}, groups)
+	})
+
+	s.Run("group from the workspace role", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_ROLE_GROUP_PREFIX", "sandbox-role:")
+
+		// when
+		groups := impersonatedGroups(newContext("admin"), smith)
+
+		// then
+		assert.Equal(s.T(), []string{"sandbox-role:admin"}, groups)
+	})
+
+	s.Run("groups from the token claims and the workspace role", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_GROUP_CLAIMS", "groups")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_ROLE_GROUP_PREFIX", "sandbox-role:")
+
+		// when
+		groups := impersonatedGroups(newContext("viewer"), smith)
+
+		// then
+		assert.Equal(s.T(), []string{"dev", "qa", "sandbox-role:viewer"}, groups)
+	})
+
+	s.Run("no system group from the workspace role", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_ROLE_GROUP_PREFIX", "system:")
+
+		// when
+		groups := impersonatedGroups(newContext("admin"), smith)
+
+		// then
+		assert.Empty(s.T(), groups)
+	})
+
+	s.Run("no group from the token claims when the public viewer is impersonated", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_GROUP_CLAIMS", "groups")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATE_ROLE_GROUP_PREFIX", "sandbox-role:")
+
+		// when
+		groups := impersonatedGroups(newContext("viewer"), publicViewer)
+
+		// then
+		assert.Equal(s.T(), []string{"sandbox-role:viewer"}, groups)
+	})
+}
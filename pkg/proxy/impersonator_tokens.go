@@ -0,0 +1,183 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/golang-jwt/jwt/v5"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// minImpersonatorTokenTTL is the minimum lifetime of the tokens minted with the TokenRequest API
+	minImpersonatorTokenTTL = 10 * time.Minute
+	// impersonatorTokenRetryInterval is the minimum interval between two attempts to mint a token for the same member cluster,
+	// so that a member cluster which keeps on rejecting the TokenRequests doesn't trigger an attempt for every request
+	impersonatorTokenRetryInterval = 30 * time.Second
+	// impersonatorTokenMintTimeout is the timeout of the TokenRequests, so that a member cluster which doesn't respond doesn't
+	// prevent the tokens of this member cluster from being minted once it responds again
+	impersonatorTokenMintTimeout = 10 * time.Second
+)
+
+type impersonatorToken struct {
+	token string
+	// staticToken is the hash of the token of the ToolchainCluster the token was minted with, so that a new token is minted
+	// once the token of the ToolchainCluster is rotated
+	staticToken [sha256.Size]byte
+	expiresAt   time.Time
+	// refreshAt is the time after which a new token is minted, before the token expires
+	refreshAt time.Time
+}
+
+// ImpersonatorTokens mints and caches the short-lived tokens used to impersonate the users in the member clusters
+// (see the ImpersonatorTokenTTL setting), so that a leaked token can only be used until it expires, unlike the tokens
+// of the ToolchainClusters. The tokens are minted with the TokenRequest API for the service accounts of the tokens of the
+// ToolchainClusters, and are refreshed once 80% of their lifetime has elapsed.
+// The tokens are minted at most one at a time per member cluster. The tokens due for refresh are minted in the background, and the
+// previous token is used until the new one is minted. The requests wait for the token to be minted when there is no token yet
+// (or when the token expired), and fail if it can't be minted, rather than using the privileged token of the ToolchainCluster.
+type ImpersonatorTokens struct {
+	mu     sync.Mutex
+	tokens map[string]impersonatorToken
+	// failures are the times of the last failed attempts to mint a token, by member cluster
+	failures map[string]time.Time
+	// minting are the member clusters for which a token is being minted, with the channels closed once the tokens are minted
+	minting map[string]chan struct{}
+}
+
+// NewImpersonatorTokens returns a new ImpersonatorTokens, without any token
+func NewImpersonatorTokens() *ImpersonatorTokens {
+	return &ImpersonatorTokens{
+		tokens:   map[string]impersonatorToken{},
+		failures: map[string]time.Time{},
+		minting:  map[string]chan struct{}{},
+	}
+}
+
+// get returns the token used to impersonate the users in the given member cluster at the given time: the short-lived token
+// of the member cluster, or the token of its ToolchainCluster if the short-lived tokens are disabled.
+// A new token is minted when there is no token yet or when the token is due for refresh. The call waits for the new token
+// (at most for impersonatorTokenMintTimeout) if there is no token to use until then, and returns an error if it isn't minted.
+func (t *ImpersonatorTokens) get(member *commoncluster.CachedToolchainCluster, now time.Time) (string, error) {
+	staticToken := member.RestConfig.BearerToken
+	ttl := configuration.GetRegistrationServiceConfig().Proxy().ImpersonatorTokenTTL()
+	if ttl <= 0 || member.Client == nil {
+		return staticToken, nil
+	}
+	staticTokenHash := sha256.Sum256([]byte(staticToken))
+
+	t.mu.Lock()
+	cached, found := t.tokens[member.Name]
+	found = found && cached.staticToken == staticTokenHash
+	if found && now.Before(cached.refreshAt) {
+		t.mu.Unlock()
+		return cached.token, nil
+	}
+	minted := t.minting[member.Name]
+	if failed, ok := t.failures[member.Name]; minted == nil && (!ok || now.Sub(failed) >= impersonatorTokenRetryInterval) {
+		minted = make(chan struct{})
+		t.minting[member.Name] = minted
+		go t.mint(member, staticTokenHash, max(ttl, minImpersonatorTokenTTL), now)
+	}
+	t.mu.Unlock()
+	if found && now.Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+
+	// there is no token to use until the new one is minted
+	if minted != nil {
+		select {
+		case <-minted:
+		case <-time.After(impersonatorTokenMintTimeout):
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if cached, found := t.tokens[member.Name]; found && cached.staticToken == staticTokenHash && now.Before(cached.expiresAt) {
+		return cached.token, nil
+	}
+	return "", fmt.Errorf("no token to impersonate the users in the '%s' member cluster", member.Name)
+}
+
+// mint mints a new token with the given lifetime for the given member cluster and caches it, or records the failure at the
+// given time
+func (t *ImpersonatorTokens) mint(member *commoncluster.CachedToolchainCluster, staticTokenHash [sha256.Size]byte, ttl time.Duration, now time.Time) {
+	ctx, cancel := context.WithTimeout(context.Background(), impersonatorTokenMintTimeout)
+	defer cancel()
+	minted, err := mintImpersonatorToken(ctx, member, ttl, now)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	close(t.minting[member.Name])
+	delete(t.minting, member.Name)
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to mint a token to impersonate the users in the '%s' member cluster", member.Name))
+		t.failures[member.Name] = now
+		return
+	}
+	minted.staticToken = staticTokenHash
+	t.tokens[member.Name] = minted
+	delete(t.failures, member.Name)
+}
+
+// invalidate drops the token of the member cluster with the given name, eg. when the member cluster rejected it
+func (t *ImpersonatorTokens) invalidate(clusterName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.tokens, clusterName)
+}
+
+// mintImpersonatorToken mints a token with the given lifetime for the service account of the token of the ToolchainCluster
+// of the given member cluster
+func mintImpersonatorToken(ctx context.Context, member *commoncluster.CachedToolchainCluster, ttl time.Duration, now time.Time) (impersonatorToken, error) {
+	namespace, name, err := serviceAccountOf(member.RestConfig.BearerToken)
+	if err != nil {
+		return impersonatorToken{}, err
+	}
+	expirationSeconds := int64(ttl.Seconds())
+	tokenRequest := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+		},
+	}
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+	if err := member.Client.SubResource("token").Create(ctx, serviceAccount, tokenRequest); err != nil {
+		return impersonatorToken{}, fmt.Errorf("unable to request a token for the '%s/%s' service account: %w", namespace, name, err)
+	}
+	expiresAt := tokenRequest.Status.ExpirationTimestamp.Time
+	lifetime := expiresAt.Sub(now)
+	return impersonatorToken{
+		token:     tokenRequest.Status.Token,
+		expiresAt: expiresAt,
+		refreshAt: now.Add(lifetime - lifetime/5),
+	}, nil
+}
+
+// serviceAccountOf returns the namespace and the name of the service account of the given token, from its `sub` claim
+// (eg. `system:serviceaccount:toolchain-member-operator:toolchaincluster-host`). The token is not verified.
+func serviceAccountOf(token string) (string, string, error) {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(token, claims); err != nil {
+		return "", "", fmt.Errorf("unable to parse the token of the ToolchainCluster: %w", err)
+	}
+	subject, _ := claims.GetSubject()
+	segments := strings.Split(subject, ":")
+	if len(segments) != 4 || segments[0] != "system" || segments[1] != "serviceaccount" {
+		return "", "", fmt.Errorf("the token of the ToolchainCluster is not the token of a service account: '%s'", subject)
+	}
+	return segments[2], segments[3], nil
+}
@@ -0,0 +1,313 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"sync/atomic"
+	"time"
+
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *TestProxySuite) TestImpersonatorTokens() {
+	// given
+	serviceAccountToken := func(subject string) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": subject}).SignedString([]byte("secret"))
+		require.NoError(s.T(), err)
+		return token
+	}
+	staticToken := serviceAccountToken("system:serviceaccount:" + commontest.MemberOperatorNs + ":toolchaincluster-host")
+	memberClient := commontest.NewFakeClient(s.T(), &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "toolchaincluster-host",
+			Namespace: commontest.MemberOperatorNs,
+		},
+	})
+	newMember := func(token string) *commoncluster.CachedToolchainCluster {
+		return &commoncluster.CachedToolchainCluster{
+			Config: &commoncluster.Config{
+				Name:       "member-1",
+				RestConfig: &rest.Config{BearerToken: token},
+			},
+			Client: memberClient,
+		}
+	}
+	// waitForMinting waits until the token of the member cluster is not being minted anymore
+	waitForMinting := func(tokens *ImpersonatorTokens) {
+		require.Eventually(s.T(), func() bool {
+			tokens.mu.Lock()
+			defer tokens.mu.Unlock()
+			return tokens.minting["member-1"] == nil
+		}, time.Second, time.Millisecond)
+	}
+	now := time.Now()
+
+	s.Run("disabled", func() {
+		// given
+		tokens := NewImpersonatorTokens()
+
+		// when
+		token, err := tokens.get(newMember(staticToken), now)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), staticToken, token)
+		assert.Empty(s.T(), tokens.minting)
+		assert.Empty(s.T(), tokens.tokens)
+	})
+
+	s.Run("enabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IMPERSONATOR_TOKEN_TTL", "1h")
+
+		s.Run("first token minted and cached", func() {
+			// given
+			tokens := NewImpersonatorTokens()
+
+			// when
+			token, err := tokens.get(newMember(staticToken), now)
+
+			// then
+			// the request waits for the token rather than using the token of the ToolchainCluster
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "fake-token", token)
+			assert.Empty(s.T(), tokens.minting)
+			require.Contains(s.T(), tokens.tokens, "member-1")
+			cached := tokens.tokens["member-1"]
+			assert.True(s.T(), cached.refreshAt.After(now))
+			assert.True(s.T(), cached.refreshAt.Before(cached.expiresAt))
+
+			s.Run("token minted again once the token of the ToolchainCluster is rotated", func() {
+				// given
+				rotatedToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+					"sub": "system:serviceaccount:" + commontest.MemberOperatorNs + ":toolchaincluster-host",
+					"jti": "rotated",
+				}).SignedString([]byte("secret"))
+				require.NoError(s.T(), err)
+				tokens.tokens["member-1"] = impersonatorToken{token: "previous-token", staticToken: cached.staticToken, expiresAt: cached.expiresAt, refreshAt: cached.refreshAt}
+
+				// when
+				token, err := tokens.get(newMember(rotatedToken), now)
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "fake-token", token)
+				assert.Equal(s.T(), sha256.Sum256([]byte(rotatedToken)), tokens.tokens["member-1"].staticToken)
+			})
+
+			s.Run("token dropped", func() {
+				// when
+				tokens.invalidate("member-1")
+
+				// then
+				assert.NotContains(s.T(), tokens.tokens, "member-1")
+			})
+		})
+
+		s.Run("cached token returned until it is refreshed", func() {
+			// given
+			tokens := NewImpersonatorTokens()
+			tokens.tokens["member-1"] = impersonatorToken{
+				token:       "cached-token",
+				staticToken: sha256.Sum256([]byte(staticToken)),
+				expiresAt:   now.Add(time.Hour),
+				refreshAt:   now.Add(time.Minute),
+			}
+
+			// when
+			token, err := tokens.get(newMember(staticToken), now)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "cached-token", token)
+			assert.Empty(s.T(), tokens.minting)
+		})
+
+		s.Run("cached token returned while it is refreshed", func() {
+			// given
+			tokens := NewImpersonatorTokens()
+			tokens.tokens["member-1"] = impersonatorToken{
+				token:       "cached-token",
+				staticToken: sha256.Sum256([]byte(staticToken)),
+				expiresAt:   now.Add(time.Hour),
+				refreshAt:   now.Add(-time.Minute),
+			}
+
+			// when
+			token, err := tokens.get(newMember(staticToken), now)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "cached-token", token)
+			waitForMinting(tokens)
+			assert.Equal(s.T(), "fake-token", tokens.tokens["member-1"].token)
+		})
+
+		s.Run("requests wait for the token being minted", func() {
+			// given
+			tokens := NewImpersonatorTokens()
+			release := make(chan struct{})
+			calls := &atomic.Int32{}
+			member := newMember(staticToken)
+			member.Client = blockingTokenClient{Client: memberClient, release: release, calls: calls}
+			results := make(chan string, 2)
+
+			// when
+			for i := 0; i < 2; i++ {
+				go func() {
+					token, err := tokens.get(member, now)
+					assert.NoError(s.T(), err)
+					results <- token
+				}()
+			}
+
+			// then
+			require.Eventually(s.T(), func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+			assert.Empty(s.T(), results)
+			close(release)
+			assert.Equal(s.T(), "fake-token", <-results)
+			assert.Equal(s.T(), "fake-token", <-results)
+			// only one token is minted at a time
+			assert.Equal(s.T(), int32(1), calls.Load())
+		})
+
+		s.Run("token not minted", func() {
+			unknownServiceAccountToken := serviceAccountToken("system:serviceaccount:" + commontest.MemberOperatorNs + ":unknown")
+
+			s.Run("token of the ToolchainCluster not returned", func() {
+				// given
+				tokens := NewImpersonatorTokens()
+
+				// when
+				_, err := tokens.get(newMember(unknownServiceAccountToken), now)
+
+				// then
+				require.EqualError(s.T(), err, "no token to impersonate the users in the 'member-1' member cluster")
+				assert.Empty(s.T(), tokens.tokens)
+				assert.Equal(s.T(), now, tokens.failures["member-1"])
+			})
+
+			s.Run("cached token returned until it expires", func() {
+				// given
+				tokens := NewImpersonatorTokens()
+				tokens.tokens["member-1"] = impersonatorToken{
+					token:       "cached-token",
+					staticToken: sha256.Sum256([]byte(unknownServiceAccountToken)),
+					expiresAt:   now.Add(time.Minute),
+					refreshAt:   now.Add(-time.Minute),
+				}
+
+				// then
+				token, err := tokens.get(newMember(unknownServiceAccountToken), now)
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "cached-token", token)
+				waitForMinting(tokens)
+				_, err = tokens.get(newMember(unknownServiceAccountToken), now.Add(time.Minute))
+				require.EqualError(s.T(), err, "no token to impersonate the users in the 'member-1' member cluster")
+			})
+
+			s.Run("not retried before the retry interval", func() {
+				// given
+				tokens := NewImpersonatorTokens()
+				tokens.failures["member-1"] = now.Add(-time.Second)
+
+				// when
+				_, err := tokens.get(newMember(staticToken), now)
+
+				// then
+				require.EqualError(s.T(), err, "no token to impersonate the users in the 'member-1' member cluster")
+				assert.Empty(s.T(), tokens.minting)
+				token, err := tokens.get(newMember(staticToken), now.Add(impersonatorTokenRetryInterval))
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "fake-token", token)
+				assert.NotContains(s.T(), tokens.failures, "member-1")
+			})
+
+			s.Run("no client", func() {
+				// given
+				tokens := NewImpersonatorTokens()
+				member := newMember(staticToken)
+				member.Client = nil
+
+				// then
+				token, err := tokens.get(member, now)
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), staticToken, token)
+				assert.Empty(s.T(), tokens.minting)
+			})
+		})
+	})
+}
+
+// blockingTokenClient is a client whose TokenRequests wait until the release channel is closed
+type blockingTokenClient struct {
+	client.Client
+	release chan struct{}
+	calls   *atomic.Int32
+}
+
+func (c blockingTokenClient) SubResource(subResource string) client.SubResourceClient {
+	return blockingSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), release: c.release, calls: c.calls}
+}
+
+type blockingSubResourceClient struct {
+	client.SubResourceClient
+	release chan struct{}
+	calls   *atomic.Int32
+}
+
+func (c blockingSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	c.calls.Add(1)
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return c.SubResourceClient.Create(ctx, obj, subResource, opts...)
+}
+
+func (s *TestProxySuite) TestServiceAccountOf() {
+	s.Run("service account token", func() {
+		// given
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "system:serviceaccount:toolchain-member-operator:toolchaincluster-host",
+		}).SignedString([]byte("secret"))
+		require.NoError(s.T(), err)
+
+		// when
+		namespace, name, err := serviceAccountOf(token)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "toolchain-member-operator", namespace)
+		assert.Equal(s.T(), "toolchaincluster-host", name)
+	})
+
+	s.Run("not a service account token", func() {
+		// given
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "smith"}).SignedString([]byte("secret"))
+		require.NoError(s.T(), err)
+
+		// when
+		_, _, err = serviceAccountOf(token)
+
+		// then
+		require.EqualError(s.T(), err, "the token of the ToolchainCluster is not the token of a service account: 'smith'")
+	})
+
+	s.Run("not a JWT", func() {
+		// when
+		_, _, err := serviceAccountOf("clusterSAToken")
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to parse the token of the ToolchainCluster")
+	})
+}
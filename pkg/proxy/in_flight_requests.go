@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	gocontext "context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// memberRequests are the in-flight and the queued requests to a member cluster
+type memberRequests struct {
+	inFlight int
+	// limit is the limit of in-flight requests of the last request to the member cluster
+	limit int
+	// waiters are the queued requests, in the order they were received. A request is granted a slot by closing its channel.
+	waiters []chan struct{}
+}
+
+// InFlightRequests limits the number of requests forwarded concurrently to each member cluster (see the MaxInFlightRequestsPerMember
+// setting), with a bounded queue of the requests waiting for the in-flight requests to complete
+type InFlightRequests struct {
+	mu       sync.Mutex
+	members  map[string]*memberRequests
+	rejected *prometheus.CounterVec
+}
+
+// NewInFlightRequests returns a new InFlightRequests counting the rejected requests per member cluster with the given counter
+func NewInFlightRequests(rejected *prometheus.CounterVec) *InFlightRequests {
+	return &InFlightRequests{
+		members:  map[string]*memberRequests{},
+		rejected: rejected,
+	}
+}
+
+// Acquire registers a new in-flight request to the given member cluster, unless the member cluster already has the given limit
+// of in-flight requests, in which case the request waits, up to the given timeout, for an in-flight request to complete. Returns false
+// if the queue is full (ie. if the given number of requests are already waiting) or if the timeout expired. There is no limit when
+// the given limit is zero or negative. The requests registered with Acquire must be released with the returned function once completed.
+func (r *InFlightRequests) Acquire(ctx gocontext.Context, cluster string, limit, queueSize int, timeout time.Duration) (func(), bool) {
+	if limit <= 0 {
+		return func() {}, true
+	}
+	release := func() {
+		r.release(cluster)
+	}
+	r.mu.Lock()
+	member, found := r.members[cluster]
+	if !found {
+		member = &memberRequests{}
+		r.members[cluster] = member
+	}
+	member.limit = limit
+	if member.inFlight < limit {
+		member.inFlight++
+		r.mu.Unlock()
+		return release, true
+	}
+	if len(member.waiters) >= queueSize {
+		r.mu.Unlock()
+		r.rejected.WithLabelValues(cluster).Inc()
+		return nil, false
+	}
+	granted := make(chan struct{})
+	member.waiters = append(member.waiters, granted)
+	r.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-granted:
+		return release, true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	r.mu.Lock()
+	if i := slices.Index(member.waiters, granted); i >= 0 {
+		member.waiters = slices.Delete(member.waiters, i, i+1)
+		r.mu.Unlock()
+	} else {
+		// the request was granted a slot in the meantime, which is handed over to the next request
+		r.mu.Unlock()
+		release()
+	}
+	r.rejected.WithLabelValues(cluster).Inc()
+	return nil, false
+}
+
+// release hands over the slot of a completed request to the first queued request, if any
+func (r *InFlightRequests) release(cluster string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	member := r.members[cluster]
+	if len(member.waiters) > 0 && member.inFlight <= member.limit {
+		close(member.waiters[0])
+		member.waiters = member.waiters[1:]
+		return
+	}
+	member.inFlight--
+	if member.inFlight == 0 && len(member.waiters) == 0 {
+		// don't keep the member clusters without any in-flight request
+		delete(r.members, cluster)
+	}
+}
+
+// acquireInFlightRequest registers the request to the member cluster of the given target (see InFlightRequests), and returns the function
+// to call once the request completed, or a TooManyRequests error if the member cluster has too many in-flight requests. The requests
+// to the proxy plugin backends, the watch streams and the upgraded connections are not limited.
+func (p *Proxy) acquireInFlightRequest(ctx echo.Context, target *access.ClusterAccess) (func(), error) {
+	req := ctx.Request()
+	if target.ClusterName() == "" || isStreamingRequest(req) || httpstream.IsUpgradeRequest(req) {
+		return func() {}, nil
+	}
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	limit := cfg.MaxInFlightRequestsPerMember()
+	release, ok := p.inFlightRequests.Acquire(req.Context(), target.ClusterName(), limit, cfg.InFlightQueueSize(), cfg.InFlightQueueTimeout())
+	if ok {
+		return release, nil
+	}
+	log.InfoEchof(ctx, "rejecting the request: the limit of %s in-flight requests to the member cluster '%s' is reached", strconv.Itoa(limit), target.ClusterName())
+	ctx.Response().Header().Set("Retry-After", "1")
+	return nil, crterrors.NewTooManyRequestsError("too many requests", fmt.Sprintf("the maximum number of %d in-flight requests to the member cluster is reached", limit))
+}
@@ -0,0 +1,185 @@
+package proxy
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRejectedCounterVec() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "rejected"}, []string{"cluster"})
+}
+
+func (s *TestProxySuite) TestInFlightRequests() {
+	ctx := gocontext.Background()
+
+	s.Run("no limit", func() {
+		// given
+		requests := NewInFlightRequests(newRejectedCounterVec())
+
+		// then
+		for i := 0; i < 100; i++ {
+			_, ok := requests.Acquire(ctx, "member-1", 0, 0, time.Second)
+			assert.True(s.T(), ok)
+		}
+		assert.Empty(s.T(), requests.members)
+	})
+
+	s.Run("limit reached without queue", func() {
+		// given
+		rejected := newRejectedCounterVec()
+		requests := NewInFlightRequests(rejected)
+		release1, ok := requests.Acquire(ctx, "member-1", 2, 0, time.Second)
+		require.True(s.T(), ok)
+		release2, ok := requests.Acquire(ctx, "member-1", 2, 0, time.Second)
+		require.True(s.T(), ok)
+
+		// when
+		_, ok = requests.Acquire(ctx, "member-1", 2, 0, time.Second)
+
+		// then
+		assert.False(s.T(), ok)
+		_, ok = requests.Acquire(ctx, "member-2", 2, 0, time.Second)
+		assert.True(s.T(), ok, "the limits should be distinct for each member cluster")
+		release1()
+		_, ok = requests.Acquire(ctx, "member-1", 2, 0, time.Second)
+		assert.True(s.T(), ok)
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(rejected.WithLabelValues("member-1")), 0.01)
+		release2()
+	})
+
+	s.Run("queued request granted once a request completed", func() {
+		// given
+		requests := NewInFlightRequests(newRejectedCounterVec())
+		release, ok := requests.Acquire(ctx, "member-1", 1, 1, time.Second)
+		require.True(s.T(), ok)
+		granted := make(chan bool)
+		go func() {
+			release, ok := requests.Acquire(ctx, "member-1", 1, 1, 10*time.Second)
+			granted <- ok
+			release()
+		}()
+		require.Eventually(s.T(), func() bool {
+			requests.mu.Lock()
+			defer requests.mu.Unlock()
+			return len(requests.members["member-1"].waiters) == 1
+		}, time.Second, time.Millisecond)
+
+		// when the queue is full
+		_, ok = requests.Acquire(ctx, "member-1", 1, 1, time.Second)
+
+		// then
+		assert.False(s.T(), ok)
+		release()
+		assert.True(s.T(), <-granted)
+		require.Eventually(s.T(), func() bool {
+			requests.mu.Lock()
+			defer requests.mu.Unlock()
+			return len(requests.members) == 0
+		}, time.Second, time.Millisecond, "the member clusters without any in-flight request should not be kept")
+	})
+
+	s.Run("queued request rejected after the timeout", func() {
+		// given
+		rejected := newRejectedCounterVec()
+		requests := NewInFlightRequests(rejected)
+		release, ok := requests.Acquire(ctx, "member-1", 1, 1, time.Second)
+		require.True(s.T(), ok)
+
+		// when
+		_, ok = requests.Acquire(ctx, "member-1", 1, 1, 10*time.Millisecond)
+
+		// then
+		assert.False(s.T(), ok)
+		assert.Empty(s.T(), requests.members["member-1"].waiters)
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(rejected.WithLabelValues("member-1")), 0.01)
+		release()
+		assert.Empty(s.T(), requests.members)
+	})
+
+	s.Run("queued request canceled", func() {
+		// given
+		requests := NewInFlightRequests(newRejectedCounterVec())
+		release, ok := requests.Acquire(ctx, "member-1", 1, 1, time.Second)
+		require.True(s.T(), ok)
+		canceled, cancel := gocontext.WithCancel(ctx)
+		cancel()
+
+		// when
+		_, ok = requests.Acquire(canceled, "member-1", 1, 1, time.Minute)
+
+		// then
+		assert.False(s.T(), ok)
+		release()
+		assert.Empty(s.T(), requests.members)
+	})
+}
+
+func (s *TestProxySuite) TestAcquireInFlightRequest() {
+	// given
+	p := &Proxy{inFlightRequests: NewInFlightRequests(newRejectedCounterVec())}
+	apiURL, err := url.Parse("https://api.member-1.example.com:6443")
+	require.NoError(s.T(), err)
+	member := access.NewMemberClusterAccess("member-1", *apiURL, "", "", nil)
+	newContext := func(target string, header http.Header) (echo.Context, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		return echo.New().NewContext(req, rr), rr
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_MAX_IN_FLIGHT_REQUESTS_PER_MEMBER", "1")
+	ctx, _ := newContext("/api/v1/pods", nil)
+	release, err := p.acquireInFlightRequest(ctx, member)
+	require.NoError(s.T(), err)
+	defer release()
+
+	s.Run("limit reached", func() {
+		// given
+		ctx, rr := newContext("/api/v1/pods", nil)
+
+		// when
+		_, err := p.acquireInFlightRequest(ctx, member)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusTooManyRequests, crtErr.Code)
+		assert.Equal(s.T(), "the maximum number of 1 in-flight requests to the member cluster is reached", crtErr.Details)
+		assert.Equal(s.T(), "1", rr.Header().Get("Retry-After"))
+	})
+
+	for name, tc := range map[string]struct {
+		target string
+		header http.Header
+		access *access.ClusterAccess
+	}{
+		"watch":             {"/api/v1/pods?watch=true", nil, member},
+		"upgraded":          {"/api/v1/namespaces/smith-dev/pods/app/exec", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}}, member},
+		"proxy plugin":      {"/api/v1/pods", nil, access.NewClusterAccess(*apiURL, "", "")},
+		"no member cluster": {"/api/v1/pods", nil, access.NewMemberClusterAccess("", *apiURL, "", "", nil)},
+	} {
+		s.Run(name+" not limited", func() {
+			// given
+			ctx, _ := newContext(tc.target, tc.header)
+
+			// when
+			release, err := p.acquireInFlightRequest(ctx, tc.access)
+
+			// then
+			require.NoError(s.T(), err)
+			release()
+		})
+	}
+}
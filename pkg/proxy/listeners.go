@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	errs "github.com/pkg/errors"
+)
+
+// unixSocketPrefix is the prefix of the listen addresses which are Unix domain sockets, eg. "unix:/var/run/proxy.sock"
+const unixSocketPrefix = "unix:"
+
+// ListenAddresses returns the addresses the proxy listens on, as configured with the ListenAddresses setting of the proxy,
+// or the default port of all the interfaces
+func ListenAddresses() []string {
+	if addresses := configuration.GetRegistrationServiceConfig().Proxy().ListenAddresses(); len(addresses) > 0 {
+		return addresses
+	}
+	return []string{":" + DefaultPort}
+}
+
+// HealthCheckPort returns the port the health of the proxy is checked on via localhost: the admin port when the health
+// endpoint is only served there (see the DedicatedAdminPort setting), or else the port of the first TCP address among
+// the given listen addresses, which must then be reachable via localhost.
+func HealthCheckPort(addresses []string) (string, error) {
+	if configuration.GetRegistrationServiceConfig().Proxy().DedicatedAdminPort() {
+		return strconv.Itoa(ProxyMetricsPort), nil
+	}
+	for _, address := range addresses {
+		if strings.HasPrefix(address, unixSocketPrefix) {
+			continue
+		}
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return "", errs.Wrapf(err, "invalid proxy listen address '%s'", address)
+		}
+		return port, nil
+	}
+	return "", fmt.Errorf("no TCP address among the proxy listen addresses %v, one is required to check the health of the proxy", addresses)
+}
+
+func listen(address string) (net.Listener, error) {
+	if path, found := strings.CutPrefix(address, unixSocketPrefix); found {
+		// remove the socket left over by a previous run, if any (the sockets are removed when the listeners are closed)
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if err := os.Remove(path); err != nil {
+				return nil, err
+			}
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
+	"github.com/codeready-toolchain/registration-service/test/util"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestListenAddresses() {
+	s.Run("default port", func() {
+		assert.Equal(s.T(), []string{":" + DefaultPort}, ListenAddresses())
+	})
+
+	s.Run("configured addresses", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LISTEN_ADDRESSES", "unix:/var/run/proxy.sock,127.0.0.1:8091")
+
+		// then
+		assert.Equal(s.T(), []string{"unix:/var/run/proxy.sock", "127.0.0.1:8091"}, ListenAddresses())
+	})
+}
+
+func (s *TestProxySuite) TestHealthCheckPort() {
+	s.Run("first TCP address", func() {
+		// when
+		port, err := HealthCheckPort([]string{"unix:/var/run/proxy.sock", "127.0.0.1:8091", ":8092"})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "8091", port)
+	})
+
+	s.Run("port of all the interfaces", func() {
+		// when
+		port, err := HealthCheckPort([]string{":8081"})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "8081", port)
+	})
+
+	s.Run("dedicated admin port", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_DEDICATED_ADMIN_PORT", "true")
+
+		// when
+		port, err := HealthCheckPort([]string{"unix:/var/run/proxy.sock"})
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "8082", port)
+	})
+
+	s.Run("invalid TCP address", func() {
+		// when
+		_, err := HealthCheckPort([]string{"localhost"})
+
+		// then
+		require.EqualError(s.T(), err, "invalid proxy listen address 'localhost': address localhost: missing port in address")
+	})
+
+	s.Run("no TCP address", func() {
+		// when
+		_, err := HealthCheckPort([]string{"unix:/var/run/proxy.sock"})
+
+		// then
+		require.EqualError(s.T(), err, "no TCP address among the proxy listen addresses [unix:/var/run/proxy.sock], one is required to check the health of the proxy")
+	})
+}
+
+func (s *TestProxySuite) TestStartProxyListeners() {
+	// given
+	env := s.DefaultConfig().Environment()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.E2E)))
+	_, err := auth.InitializeDefaultTokenParser()
+	require.NoError(s.T(), err)
+	fakeClient, app := util.PrepareInClusterApp(s.T())
+	proxy, err := NewProxy(namespaced.NewClient(fakeClient, commontest.HostOperatorNs),
+		app, metrics.NewProxyMetrics(prometheus.NewRegistry()), proxytest.NewGetMembersFunc(commontest.NewFakeClient(s.T())))
+	require.NoError(s.T(), err)
+	socket := filepath.Join(s.T().TempDir(), "proxy.sock")
+
+	s.Run("listening on a TCP address and a Unix domain socket", func() {
+		// when
+		server, err := proxy.StartProxyListeners("127.0.0.1:8091", "unix:"+socket)
+
+		// then
+		require.NoError(s.T(), err)
+		defer func() {
+			_ = server.Shutdown(context.Background())
+		}()
+		s.checkProxyIsHealthy("8091")
+		unixClient := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+				},
+			},
+		}
+		resp, err := unixClient.Get("http://proxy/proxyhealth")
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		s.assertResponseBody(resp, `{"alive": true}`)
+	})
+
+	s.Run("health endpoint not served with a dedicated admin port", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_DEDICATED_ADMIN_PORT", "true")
+
+		// when
+		server, err := proxy.StartProxyListeners("127.0.0.1:8091")
+
+		// then
+		require.NoError(s.T(), err)
+		defer func() {
+			_ = server.Shutdown(context.Background())
+		}()
+		resp, err := http.Get("http://127.0.0.1:8091/proxyhealth")
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusNotFound, resp.StatusCode)
+	})
+
+	s.Run("serving TLS", func() {
+		// given
+		dir := s.T().TempDir()
+		ca := s.newTestCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "ca"}, IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}, nil)
+		serverCert := s.newTestCertificate(&x509.Certificate{Subject: pkix.Name{CommonName: "proxy"}, IPAddresses: []net.IP{net.ParseIP("127.0.0.1")}}, &ca)
+		certFile, keyFile := serverCert.writeFiles(s, dir, "tls")
+		caFile, _ := ca.writeFiles(s, dir, "ca")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", certFile)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_KEY_FILE", keyFile)
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_CLIENT_CA_FILE", caFile)
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12},
+			},
+		}
+
+		// when
+		server, err := proxy.StartProxyListeners("127.0.0.1:8091")
+
+		// then
+		require.NoError(s.T(), err)
+		defer func() {
+			_ = server.Shutdown(context.Background())
+		}()
+		resp, err := client.Get("https://127.0.0.1:8091/proxyhealth")
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+		assert.Equal(s.T(), 1, resp.ProtoMajor)
+	})
+
+	s.Run("invalid TLS certificate", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TLS_CERT_FILE", filepath.Join(s.T().TempDir(), "missing.crt"))
+
+		// when
+		_, err := proxy.StartProxyListeners("127.0.0.1:8091")
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to load the TLS certificate of the proxy")
+	})
+
+	s.Run("PROXY protocol without trusted proxy", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_PROXY_PROTOCOL_ENABLED", "true")
+
+		// when
+		_, err := proxy.StartProxyListeners("127.0.0.1:8091")
+
+		// then
+		require.EqualError(s.T(), err, "the PROXY protocol is enabled without any trusted proxy to read its headers from")
+	})
+
+	s.Run("stale socket removed", func() {
+		// given the socket left over by the previous test (unless it was not closed properly)
+		l, err := net.Listen("unix", socket)
+		require.NoError(s.T(), err)
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		require.NoError(s.T(), l.Close())
+
+		// when
+		server, err := proxy.StartProxyListeners("unix:" + socket)
+
+		// then
+		require.NoError(s.T(), err)
+		_ = server.Shutdown(context.Background())
+	})
+
+	s.Run("address already in use", func() {
+		// given
+		l, err := net.Listen("tcp", "127.0.0.1:8092")
+		require.NoError(s.T(), err)
+		defer l.Close()
+
+		// when
+		_, err = proxy.StartProxyListeners("127.0.0.1:8091", "127.0.0.1:8092")
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to listen on '127.0.0.1:8092'")
+		// the listeners opened before the failure are closed
+		other, err := net.Listen("tcp", "127.0.0.1:8091")
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), other.Close())
+	})
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"runtime"
+	runtimemetrics "runtime/metrics"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+const (
+	// loadSamplingInterval is the minimum interval between two samplings of the heap in use and of the number of goroutines
+	loadSamplingInterval = time.Second
+	// overloadCriticalRatio is the ratio of the load to a threshold above which all the requests are shed, except the exempted ones,
+	// rather than only the lowest-priority ones
+	overloadCriticalRatio = 1.25
+	// heapObjectsMetric is the runtime metric of the size of the heap in use
+	heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+	// loadSheddingRetryAfter is the number of seconds after which the clients of the shed requests should retry
+	loadSheddingRetryAfter = "5"
+)
+
+// interactiveUserAgents are the prefixes of the User-Agent of the clients used by the users themselves (the CLIs and the browsers),
+// rather than by the controllers or the scripts
+var interactiveUserAgents = []string{"kubectl/", "oc/", "Mozilla/"}
+
+// requestPriority is the priority of a request when the proxy is overloaded
+type requestPriority int
+
+const (
+	// priorityLow are the list and watch requests of the non-interactive clients, shed first
+	priorityLow requestPriority = iota
+	// priorityNormal are the other requests, only shed when the proxy is critically overloaded
+	priorityNormal
+	// priorityExempt are the health and readiness probes and the upgraded connections (exec, attach, port-forward), never shed
+	priorityExempt
+)
+
+func (p requestPriority) String() string {
+	switch p {
+	case priorityLow:
+		return "low"
+	case priorityNormal:
+		return "normal"
+	default:
+		return "exempt"
+	}
+}
+
+// priorityOf returns the priority of the given request, before its path is stripped of the workspace prefix
+func priorityOf(req *http.Request) requestPriority {
+	if probeEndpoint(req.URL.Path) || httpstream.IsUpgradeRequest(req) {
+		return priorityExempt
+	}
+	userAgent := req.UserAgent()
+	for _, prefix := range interactiveUserAgents {
+		if strings.HasPrefix(userAgent, prefix) {
+			return priorityNormal
+		}
+	}
+	path := req.URL.Path
+	if rest, found := strings.CutPrefix(path, "/workspaces/"); found {
+		_, path, _ = strings.Cut(rest, "/")
+	}
+	attributes, ok := requestAttributes(&http.Request{Method: req.Method, URL: &url.URL{Path: path, RawQuery: req.URL.RawQuery}})
+	if ok && (attributes.Verb == "list" || attributes.Verb == "watch") {
+		return priorityLow
+	}
+	return priorityNormal
+}
+
+// LoadShedder sheds the requests when the proxy is overloaded, ie. when the heap in use, the number of goroutines or the number of
+// in-flight requests reached one of the thresholds of the LoadShedding settings, starting with the lowest-priority requests
+type LoadShedder struct {
+	// inFlight is the number of requests handled by the proxy, except the streaming requests
+	inFlight atomic.Int64
+	mu       sync.Mutex
+	// sampled is the time of the last sampling of the heap in use and of the number of goroutines
+	sampled    time.Time
+	heapBytes  uint64
+	goroutines int
+	shed       *prometheus.CounterVec
+}
+
+// NewLoadShedder returns a new LoadShedder counting the shed requests per priority with the given counter
+func NewLoadShedder(shed *prometheus.CounterVec) *LoadShedder {
+	return &LoadShedder{
+		shed: shed,
+	}
+}
+
+// load returns the highest ratio of the current load of the proxy to the given thresholds. The heap in use and the number
+// of goroutines are sampled at most once per loadSamplingInterval.
+func (l *LoadShedder) load(cfg configuration.LoadSheddingConfig, now time.Time) float64 {
+	l.mu.Lock()
+	if now.Sub(l.sampled) >= loadSamplingInterval {
+		sample := []runtimemetrics.Sample{{Name: heapObjectsMetric}}
+		runtimemetrics.Read(sample)
+		l.heapBytes = sample[0].Value.Uint64()
+		l.goroutines = runtime.NumGoroutine()
+		l.sampled = now
+	}
+	heapBytes, goroutines := l.heapBytes, l.goroutines
+	l.mu.Unlock()
+
+	load := 0.0
+	for _, usage := range []struct {
+		value     float64
+		threshold int
+	}{
+		{float64(heapBytes), cfg.MaxHeapBytes()},
+		{float64(goroutines), cfg.MaxGoroutines()},
+		{float64(l.inFlight.Load()), cfg.MaxInFlightRequests()},
+	} {
+		if usage.threshold > 0 {
+			load = max(load, usage.value/float64(usage.threshold))
+		}
+	}
+	return load
+}
+
+// Shed returns true if a request with the given priority must be shed, given the current load of the proxy: the low-priority requests
+// are shed once a threshold is reached, and the normal-priority requests once a threshold is exceeded by the overloadCriticalRatio
+func (l *LoadShedder) Shed(cfg configuration.LoadSheddingConfig, priority requestPriority, now time.Time) bool {
+	switch priority {
+	case priorityExempt:
+		return false
+	case priorityLow:
+		return l.load(cfg, now) >= 1
+	default:
+		return l.load(cfg, now) >= overloadCriticalRatio
+	}
+}
+
+// shedLoad rejects the requests shed by the load shedder with a ServiceUnavailable error, and counts the in-flight requests
+func (p *Proxy) shedLoad() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			cfg := configuration.GetRegistrationServiceConfig().Proxy().LoadShedding()
+			if !cfg.Enabled() {
+				return next(ctx)
+			}
+			req := ctx.Request()
+			priority := priorityOf(req)
+			if p.loadShedder.Shed(cfg, priority, time.Now()) {
+				p.loadShedder.shed.WithLabelValues(priority.String()).Inc()
+				ctx.Response().Header().Set("Retry-After", loadSheddingRetryAfter)
+				return crterrors.NewServiceUnavailableError("the proxy is overloaded", "the request was shed, retry later")
+			}
+			if priority != priorityExempt && !isStreamingRequest(req) {
+				p.loadShedder.inFlight.Add(1)
+				defer p.loadShedder.inFlight.Add(-1)
+			}
+			return next(ctx)
+		}
+	}
+}
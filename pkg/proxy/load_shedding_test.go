@@ -0,0 +1,165 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *TestProxySuite) TestPriorityOf() {
+	for name, tc := range map[string]struct {
+		method    string
+		target    string
+		header    http.Header
+		userAgent string
+		expected  requestPriority
+	}{
+		"health probe":              {http.MethodGet, proxyHealthEndpoint, nil, "kube-probe/1.30", priorityExempt},
+		"exec":                      {http.MethodPost, "/workspaces/smith-dev/api/v1/namespaces/smith-dev/pods/app/exec", http.Header{"Connection": {"Upgrade"}, "Upgrade": {"SPDY/3.1"}}, "Go-http-client/1.1", priorityExempt},
+		"list of a controller":      {http.MethodGet, "/workspaces/smith-dev/api/v1/namespaces/smith-dev/pods", nil, "argocd/v2.10", priorityLow},
+		"list without workspace":    {http.MethodGet, "/apis/apps/v1/namespaces/smith-dev/deployments", nil, "Go-http-client/1.1", priorityLow},
+		"watch of a controller":     {http.MethodGet, "/api/v1/namespaces/smith-dev/configmaps?watch=true", nil, "", priorityLow},
+		"get of a controller":       {http.MethodGet, "/api/v1/namespaces/smith-dev/pods/app", nil, "Go-http-client/1.1", priorityNormal},
+		"create of a controller":    {http.MethodPost, "/api/v1/namespaces/smith-dev/pods", nil, "Go-http-client/1.1", priorityNormal},
+		"list of kubectl":           {http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil, "kubectl/v1.30.0 (linux/amd64) kubernetes/7c48c2b", priorityNormal},
+		"watch of oc":               {http.MethodGet, "/api/v1/namespaces/smith-dev/pods?watch=1", nil, "oc/4.16.0 (linux/amd64) kubernetes/abcdef0", priorityNormal},
+		"list of the console":       {http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil, "Mozilla/5.0 (X11; Linux x86_64)", priorityNormal},
+		"request to a proxy plugin": {http.MethodGet, "/plugins/tekton-results/api/v1/results", nil, "Go-http-client/1.1", priorityNormal},
+	} {
+		s.Run(name, func() {
+			// given
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			for key, values := range tc.header {
+				req.Header[key] = values
+			}
+			req.Header.Set("User-Agent", tc.userAgent)
+
+			// then
+			assert.Equal(s.T(), tc.expected, priorityOf(req))
+		})
+	}
+}
+
+func (s *TestProxySuite) TestLoadShedder() {
+	// given
+	now := time.Now()
+	cfg := configuration.LoadSheddingConfig{}
+	newLoadShedder := func(inFlight int64) *LoadShedder {
+		shedder := NewLoadShedder(prometheus.NewCounterVec(prometheus.CounterOpts{Name: "shed"}, []string{"priority"}))
+		shedder.inFlight.Store(inFlight)
+		// no sampling of the heap and the goroutines
+		shedder.sampled = now
+		return shedder
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_IN_FLIGHT_REQUESTS", "8")
+
+	s.Run("not overloaded", func() {
+		shedder := newLoadShedder(7)
+		for _, priority := range []requestPriority{priorityLow, priorityNormal, priorityExempt} {
+			assert.False(s.T(), shedder.Shed(cfg, priority, now))
+		}
+	})
+
+	s.Run("overloaded", func() {
+		shedder := newLoadShedder(8)
+		assert.True(s.T(), shedder.Shed(cfg, priorityLow, now))
+		assert.False(s.T(), shedder.Shed(cfg, priorityNormal, now))
+		assert.False(s.T(), shedder.Shed(cfg, priorityExempt, now))
+	})
+
+	s.Run("critically overloaded", func() {
+		shedder := newLoadShedder(10)
+		assert.True(s.T(), shedder.Shed(cfg, priorityLow, now))
+		assert.True(s.T(), shedder.Shed(cfg, priorityNormal, now))
+		assert.False(s.T(), shedder.Shed(cfg, priorityExempt, now))
+	})
+
+	s.Run("goroutines sampled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_GOROUTINES", "1")
+		shedder := newLoadShedder(0)
+
+		// then the previous sample is used until it is stale
+		assert.False(s.T(), shedder.Shed(cfg, priorityNormal, now.Add(500*time.Millisecond)))
+		assert.True(s.T(), shedder.Shed(cfg, priorityNormal, now.Add(time.Second)))
+	})
+
+	s.Run("heap sampled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_HEAP_BYTES", "1")
+		shedder := newLoadShedder(0)
+
+		// then
+		assert.True(s.T(), shedder.Shed(cfg, priorityNormal, now.Add(time.Second)))
+	})
+}
+
+func (s *TestProxySuite) TestShedLoad() {
+	// given
+	shed := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "shed"}, []string{"priority"})
+	p := &Proxy{loadShedder: NewLoadShedder(shed)}
+	router := echo.New()
+	router.HTTPErrorHandler = customHTTPErrorHandler
+	router.Pre(p.shedLoad())
+	var inFlight int64
+	router.Any("/*", func(ctx echo.Context) error {
+		inFlight = p.loadShedder.inFlight.Load()
+		return ctx.NoContent(http.StatusOK)
+	})
+	send := func(target, userAgent string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("User-Agent", userAgent)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	s.Run("load shedding disabled", func() {
+		// when
+		rr := send("/api/v1/namespaces/smith-dev/pods", "argocd/v2.10")
+
+		// then
+		assert.Equal(s.T(), http.StatusOK, rr.Code)
+		assert.Zero(s.T(), inFlight, "the in-flight requests should not be counted")
+	})
+
+	s.Run("in-flight requests counted", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_IN_FLIGHT_REQUESTS", "100")
+
+		// when
+		rr := send("/api/v1/namespaces/smith-dev/pods", "argocd/v2.10")
+
+		// then
+		assert.Equal(s.T(), http.StatusOK, rr.Code)
+		assert.Equal(s.T(), int64(1), inFlight)
+		assert.Zero(s.T(), p.loadShedder.inFlight.Load())
+	})
+
+	s.Run("low-priority request shed", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOAD_SHEDDING_MAX_IN_FLIGHT_REQUESTS", "4")
+		p.loadShedder.inFlight.Store(4)
+		defer p.loadShedder.inFlight.Store(0)
+
+		// when
+		rr := send("/api/v1/namespaces/smith-dev/pods", "argocd/v2.10")
+		interactive := send("/api/v1/namespaces/smith-dev/pods", "kubectl/v1.30.0")
+		probe := send(proxyHealthEndpoint, "kube-probe/1.30")
+
+		// then
+		assert.Equal(s.T(), http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(s.T(), "5", rr.Header().Get("Retry-After"))
+		s.assertResponseBody(rr.Result(), crterrors.NewServiceUnavailableError("the proxy is overloaded", "the request was shed, retry later").Error())
+		assert.Equal(s.T(), http.StatusOK, interactive.Code)
+		assert.Equal(s.T(), http.StatusOK, probe.Code)
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(shed.WithLabelValues("low")), 0.01)
+	})
+}
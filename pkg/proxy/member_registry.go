@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	"k8s.io/client-go/rest"
+)
+
+// memberRegistryEndpoint is the admin endpoint of the proxy to register and deregister the endpoints of the member clusters at runtime
+const memberRegistryEndpoint = "/proxyadmin/members"
+
+// MemberRegistration is the endpoint of a member cluster registered at runtime
+type MemberRegistration struct {
+	// Name is the name of the member cluster
+	Name string `json:"name"`
+	// APIEndpoint is the URL of the API server of the member cluster the requests are forwarded to
+	APIEndpoint string `json:"apiEndpoint"`
+	// Token is the token of the service account used to impersonate the users. Optional for a member cluster of the
+	// ToolchainCluster cache, whose token is used by default, and never returned by the admin endpoints.
+	Token string `json:"token,omitempty"`
+}
+
+// MemberRegistry holds the endpoints of the member clusters registered at runtime, in addition to the member clusters of the
+// ToolchainCluster cache, eg. to reroute the requests to a member cluster in a disaster recovery scenario without waiting for
+// its ToolchainCluster to be updated. The registrations are not persisted, and only apply to the replica of the proxy they were made on,
+// unless the caches are shared with the other replicas (see SharedCache).
+type MemberRegistry struct {
+	// cachedMembers returns the member clusters of the ToolchainCluster cache
+	cachedMembers commoncluster.GetMemberClustersFunc
+	mu            sync.RWMutex
+	registrations map[string]MemberRegistration
+}
+
+// NewMemberRegistry returns a new MemberRegistry, in addition to the member clusters returned by the given func
+func NewMemberRegistry(cachedMembers commoncluster.GetMemberClustersFunc) *MemberRegistry {
+	return &MemberRegistry{
+		cachedMembers: cachedMembers,
+		registrations: map[string]MemberRegistration{},
+	}
+}
+
+// Register registers the given endpoint of a member cluster, replacing the endpoint of the member cluster with the same name
+// in the ToolchainCluster cache (or a previous registration), if any
+func (r *MemberRegistry) Register(registration MemberRegistration) error {
+	if registration.Name == "" {
+		return errors.New("the name of the member cluster is missing")
+	}
+	apiURL, err := url.Parse(registration.APIEndpoint)
+	if err != nil || (apiURL.Scheme != "https" && apiURL.Scheme != "http") || apiURL.Host == "" {
+		return fmt.Errorf("invalid API endpoint '%s'", registration.APIEndpoint)
+	}
+	if registration.Token == "" && !slices.ContainsFunc(r.cachedMembers(), func(member *commoncluster.CachedToolchainCluster) bool {
+		return member.Name == registration.Name
+	}) {
+		return fmt.Errorf("the token is missing for the '%s' member cluster which is not in the ToolchainCluster cache", registration.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registrations[registration.Name] = registration
+	return nil
+}
+
+// Deregister removes the registration of the member cluster with the given name, so that the member cluster of the ToolchainCluster
+// cache (if any) is used again. Returns false if no endpoint was registered for the member cluster.
+func (r *MemberRegistry) Deregister(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, found := r.registrations[name]
+	delete(r.registrations, name)
+	return found
+}
+
+// Registrations returns the registered endpoints, sorted by name and without their tokens
+func (r *MemberRegistry) Registrations() []MemberRegistration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	registrations := make([]MemberRegistration, 0, len(r.registrations))
+	for _, registration := range r.registrations {
+		registration.Token = ""
+		registrations = append(registrations, registration)
+	}
+	slices.SortFunc(registrations, func(a, b MemberRegistration) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return registrations
+}
+
+// GetMembers returns the member clusters of the ToolchainCluster cache matching the given conditions, with the registered
+// endpoints instead of the cached ones, along with the registered member clusters which are not in the cache
+func (r *MemberRegistry) GetMembers(conditions ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+	members := r.cachedMembers(conditions...)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.registrations) == 0 {
+		return members
+	}
+	result := make([]*commoncluster.CachedToolchainCluster, 0, len(members)+len(r.registrations))
+	for _, member := range members {
+		if registration, found := r.registrations[member.Name]; found {
+			member = registeredMember(member, registration)
+		}
+		result = append(result, member)
+	}
+	for _, registration := range r.registrations {
+		if !slices.ContainsFunc(result, func(member *commoncluster.CachedToolchainCluster) bool {
+			return member.Name == registration.Name
+		}) && registration.Token != "" {
+			result = append(result, registeredMember(nil, registration))
+		}
+	}
+	return result
+}
+
+// registeredMember returns a copy of the given cached member cluster (if any) with the endpoint and the token of the given registration
+func registeredMember(cached *commoncluster.CachedToolchainCluster, registration MemberRegistration) *commoncluster.CachedToolchainCluster {
+	member := &commoncluster.CachedToolchainCluster{
+		Config: &commoncluster.Config{
+			Name:       registration.Name,
+			RestConfig: &rest.Config{},
+		},
+	}
+	if cached != nil {
+		config := *cached.Config
+		config.RestConfig = rest.CopyConfig(cached.RestConfig)
+		member.Config = &config
+		// the client of the cached member cluster is still used to resolve the endpoints of the proxy plugins
+		member.Client = cached.Client
+		member.ClusterStatus = cached.ClusterStatus
+	}
+	member.APIEndpoint = registration.APIEndpoint
+	if registration.Token != "" {
+		member.RestConfig.BearerToken = registration.Token
+	}
+	return member
+}
+
+// listRegisteredMembers returns the endpoints of the member clusters registered at runtime
+func (p *Proxy) listRegisteredMembers(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, p.memberRegistry.Registrations())
+}
+
+// registerMember registers the endpoint of the member cluster with the name of the path, from the MemberRegistration of the body
+func (p *Proxy) registerMember(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	registration := MemberRegistration{}
+	if err := json.NewDecoder(ctx.Request().Body).Decode(&registration); err != nil {
+		return crterrors.NewBadRequest("invalid member registration request", fmt.Sprintf("unable to decode the registration: %s", err.Error()))
+	}
+	registration.Name = ctx.Param("name")
+	if err := p.memberRegistry.Register(registration); err != nil {
+		return crterrors.NewBadRequest("invalid member registration request", err.Error())
+	}
+	log.InfoEchof(ctx, "registered the '%s' endpoint of the '%s' member cluster", registration.APIEndpoint, registration.Name)
+	if p.sharedCache != nil {
+		if err := p.sharedCache.saveMemberRegistration(ctx.Request().Context(), registration); err != nil {
+			return crterrors.NewInternalError(err, "the member cluster was only registered on this replica of the proxy")
+		}
+	}
+	registration.Token = ""
+	return ctx.JSON(http.StatusOK, registration)
+}
+
+// deregisterMember removes the registration of the member cluster with the name of the path
+func (p *Proxy) deregisterMember(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	name := ctx.Param("name")
+	if !p.memberRegistry.Deregister(name) {
+		return crterrors.NewNotFoundError(fmt.Errorf("no endpoint registered for the '%s' member cluster", name), "member registration not found")
+	}
+	log.InfoEchof(ctx, "deregistered the endpoint of the '%s' member cluster", name)
+	if p.sharedCache != nil {
+		if err := p.sharedCache.deleteMemberRegistration(ctx.Request().Context(), name); err != nil {
+			return crterrors.NewInternalError(err, "the member cluster was only deregistered on this replica of the proxy")
+		}
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,255 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func (s *TestProxySuite) TestMemberRegistry() {
+	// given
+	cachedMembers := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Config: &commoncluster.Config{
+					Name:        "member-1",
+					APIEndpoint: "https://api.member-1.com:6443",
+					RestConfig:  &rest.Config{BearerToken: "member1SAToken"},
+				},
+			},
+		}
+	}
+
+	s.Run("no registration", func() {
+		// given
+		registry := NewMemberRegistry(cachedMembers)
+
+		// then
+		assert.Equal(s.T(), cachedMembers(), registry.GetMembers())
+		assert.Empty(s.T(), registry.Registrations())
+	})
+
+	s.Run("endpoint of a cached member cluster replaced", func() {
+		// given
+		registry := NewMemberRegistry(cachedMembers)
+
+		// when
+		err := registry.Register(MemberRegistration{Name: "member-1", APIEndpoint: "https://api.member-1-dr.com:6443"})
+
+		// then
+		require.NoError(s.T(), err)
+		members := registry.GetMembers()
+		require.Len(s.T(), members, 1)
+		assert.Equal(s.T(), "member-1", members[0].Name)
+		assert.Equal(s.T(), "https://api.member-1-dr.com:6443", members[0].APIEndpoint)
+		assert.Equal(s.T(), "member1SAToken", members[0].RestConfig.BearerToken)
+		assert.Equal(s.T(), "https://api.member-1.com:6443", cachedMembers()[0].APIEndpoint)
+
+		s.Run("with another token", func() {
+			// when
+			err := registry.Register(MemberRegistration{Name: "member-1", APIEndpoint: "https://api.member-1-dr.com:6443", Token: "drSAToken"})
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "drSAToken", registry.GetMembers()[0].RestConfig.BearerToken)
+			assert.Equal(s.T(), []MemberRegistration{{Name: "member-1", APIEndpoint: "https://api.member-1-dr.com:6443"}}, registry.Registrations())
+		})
+
+		s.Run("deregistered", func() {
+			// when
+			found := registry.Deregister("member-1")
+
+			// then
+			assert.True(s.T(), found)
+			assert.Equal(s.T(), cachedMembers(), registry.GetMembers())
+			assert.False(s.T(), registry.Deregister("member-1"))
+		})
+	})
+
+	s.Run("member cluster added", func() {
+		// given
+		registry := NewMemberRegistry(cachedMembers)
+
+		// when
+		err := registry.Register(MemberRegistration{Name: "member-2", APIEndpoint: "https://api.member-2.com:6443", Token: "member2SAToken"})
+
+		// then
+		require.NoError(s.T(), err)
+		members := registry.GetMembers()
+		require.Len(s.T(), members, 2)
+		assert.Equal(s.T(), "member-1", members[0].Name)
+		assert.Equal(s.T(), "member-2", members[1].Name)
+		assert.Equal(s.T(), "https://api.member-2.com:6443", members[1].APIEndpoint)
+		assert.Equal(s.T(), "member2SAToken", members[1].RestConfig.BearerToken)
+	})
+
+	s.Run("invalid registrations", func() {
+		// given
+		registry := NewMemberRegistry(cachedMembers)
+
+		for name, tc := range map[string]struct {
+			registration  MemberRegistration
+			expectedError string
+		}{
+			"no name": {
+				registration:  MemberRegistration{APIEndpoint: "https://api.member-1.com:6443"},
+				expectedError: "the name of the member cluster is missing",
+			},
+			"invalid endpoint": {
+				registration:  MemberRegistration{Name: "member-1", APIEndpoint: "api.member-1.com:6443"},
+				expectedError: "invalid API endpoint 'api.member-1.com:6443'",
+			},
+			"no token for an unknown member cluster": {
+				registration:  MemberRegistration{Name: "member-2", APIEndpoint: "https://api.member-2.com:6443"},
+				expectedError: "the token is missing for the 'member-2' member cluster which is not in the ToolchainCluster cache",
+			},
+		} {
+			s.Run(name, func() {
+				// when
+				err := registry.Register(tc.registration)
+
+				// then
+				require.EqualError(s.T(), err, tc.expectedError)
+				assert.Empty(s.T(), registry.Registrations())
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestMemberRegistryEndpoints() {
+	// given
+	p := &Proxy{
+		memberRegistry: NewMemberRegistry(func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+			return nil
+		}),
+	}
+	newContext := func(username, method, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, memberRegistryEndpoint+"/member-2", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.SetParamNames("name")
+		ctx.SetParamValues("member-2")
+		ctx.Set(context.UsernameKey, username)
+		return ctx, rec
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+
+	s.Run("registered by an admin", func() {
+		// given
+		ctx, rec := newContext("admin", http.MethodPut, `{"apiEndpoint":"https://api.member-2.com:6443","token":"member2SAToken"}`)
+
+		// when
+		err := p.registerMember(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), http.StatusOK, rec.Code)
+		assert.JSONEq(s.T(), `{"name":"member-2","apiEndpoint":"https://api.member-2.com:6443"}`, rec.Body.String())
+		assert.Equal(s.T(), "member2SAToken", p.memberRegistry.GetMembers()[0].RestConfig.BearerToken)
+
+		s.Run("listed", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodGet, "")
+
+			// when
+			err := p.listRegisteredMembers(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			registrations := []MemberRegistration{}
+			require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), &registrations))
+			assert.Equal(s.T(), []MemberRegistration{{Name: "member-2", APIEndpoint: "https://api.member-2.com:6443"}}, registrations)
+		})
+
+		s.Run("deregistered", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodDelete, "")
+
+			// when
+			err := p.deregisterMember(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusNoContent, rec.Code)
+			assert.Empty(s.T(), p.memberRegistry.GetMembers())
+		})
+	})
+
+	s.Run("not registered", func() {
+		s.Run("by a user who is not an admin", func() {
+			// given
+			ctx, _ := newContext("smith", http.MethodPut, `{"apiEndpoint":"https://api.member-2.com:6443","token":"member2SAToken"}`)
+
+			// when
+			err := p.registerMember(ctx)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+			assert.Empty(s.T(), p.memberRegistry.GetMembers())
+		})
+
+		s.Run("invalid body", func() {
+			// given
+			ctx, _ := newContext("admin", http.MethodPut, `{"apiEndpoint":`)
+
+			// when
+			err := p.registerMember(ctx)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+		})
+
+		s.Run("invalid registration", func() {
+			// given
+			ctx, _ := newContext("admin", http.MethodPut, `{"apiEndpoint":"https://api.member-2.com:6443"}`)
+
+			// when
+			err := p.registerMember(ctx)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+			assert.Contains(s.T(), crtErr.Details, "the token is missing")
+		})
+	})
+
+	s.Run("not deregistered", func() {
+		// given
+		ctx, _ := newContext("admin", http.MethodDelete, "")
+
+		// when
+		err := p.deregisterMember(ctx)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusNotFound, crtErr.Code)
+	})
+
+	s.Run("not listed for a user who is not an admin", func() {
+		// given
+		ctx, _ := newContext("smith", http.MethodGet, "")
+
+		// when
+		err := p.listRegisteredMembers(ctx)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+	})
+}
@@ -2,8 +2,12 @@ package proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/url"
+	"strconv"
+	"time"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/application/service"
@@ -17,7 +21,24 @@ import (
 
 	errs "github.com/pkg/errors"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// The annotations below can be set on a ProxyPlugin to configure the TLS settings used when dialing the plugin backend.
+// If none of them is set, the plugin backend is dialed with the same transport policy as the member clusters.
+const (
+	// ProxyPluginTLSCASecretAnnotationKey is the name of the Secret (in the host operator namespace) whose `ca.crt` key
+	// contains the PEM encoded CA bundle used to verify the certificate of the plugin backend
+	ProxyPluginTLSCASecretAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "proxy-plugin-tls-ca-secret"
+	// ProxyPluginTLSInsecureSkipVerifyAnnotationKey disables the verification of the certificate of the plugin backend when set to "true"
+	ProxyPluginTLSInsecureSkipVerifyAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "proxy-plugin-tls-insecure-skip-verify"
+	// ProxyPluginTLSServerNameAnnotationKey is the SNI hostname sent to the plugin backend and used to verify its certificate
+	ProxyPluginTLSServerNameAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "proxy-plugin-tls-server-name"
+
+	proxyPluginTLSCAKey = "ca.crt"
 )
 
 // MemberClusters is a type that helps with retrieving access to a specific member cluster
@@ -25,15 +46,39 @@ type MemberClusters struct { // nolint:revive
 	namespaced.Client
 	SignupService  service.SignupService
 	GetMembersFunc cluster.GetMemberClustersFunc
+	// pluginEndpoints caches the URLs of the proxy plugin backends. The Routes are fetched on every request if nil.
+	pluginEndpoints *PluginEndpoints
+	// impersonatorTokens mints the short-lived tokens used to impersonate the users. The tokens of the ToolchainClusters are used if nil.
+	impersonatorTokens *ImpersonatorTokens
+}
+
+// MemberClustersOption the options of the MemberClusters
+type MemberClustersOption func(*MemberClusters)
+
+// WithPluginEndpoints sets the cache of the proxy plugin backend URLs
+func WithPluginEndpoints(pluginEndpoints *PluginEndpoints) MemberClustersOption {
+	return func(s *MemberClusters) {
+		s.pluginEndpoints = pluginEndpoints
+	}
+}
+
+// WithImpersonatorTokens sets the short-lived tokens used to impersonate the users in the member clusters
+func WithImpersonatorTokens(impersonatorTokens *ImpersonatorTokens) MemberClustersOption {
+	return func(s *MemberClusters) {
+		s.impersonatorTokens = impersonatorTokens
+	}
 }
 
 // NewMemberClusters creates an instance of the MemberClusters type
-func NewMemberClusters(client namespaced.Client, signupService service.SignupService, getMembersFunc cluster.GetMemberClustersFunc) *MemberClusters {
+func NewMemberClusters(client namespaced.Client, signupService service.SignupService, getMembersFunc cluster.GetMemberClustersFunc, opts ...MemberClustersOption) *MemberClusters {
 	si := &MemberClusters{
 		Client:         client,
 		SignupService:  signupService,
 		GetMembersFunc: getMembersFunc,
 	}
+	for _, opt := range opts {
+		opt(si)
+	}
 	return si
 }
 
@@ -89,7 +134,7 @@ func (s *MemberClusters) getClusterAccessForDefaultWorkspace(username, proxyPlug
 	}
 
 	// retrieve user's access for cluster
-	return s.accessForCluster(userSignup.APIEndpoint, userSignup.ClusterName, userSignup.CompliantUsername, proxyPluginName)
+	return s.accessForCluster(userSignup.ClusterName, userSignup.CompliantUsername, proxyPluginName)
 }
 
 func (s *MemberClusters) getSignupFromInformerForProvisionedUser(username string) (*signup.Signup, error) {
@@ -118,13 +163,16 @@ func (s *MemberClusters) accessForSpace(space *toolchainv1alpha1.Space, username
 	}
 	for _, member := range members {
 		if member.Name == space.Status.TargetCluster {
-			apiURL, err := s.getMemberURL(proxyPluginName, member)
+			apiURL, tlsConfig, err := s.getMemberURL(proxyPluginName, member)
 			if err != nil {
 				return nil, err
 			}
 			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
-			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			impersonatorToken, err := s.impersonatorToken(member)
+			if err != nil {
+				return nil, err
+			}
+			return access.NewMemberClusterAccess(member.Name, *apiURL, impersonatorToken, username, tlsConfig), nil
 		}
 	}
 
@@ -133,46 +181,130 @@ func (s *MemberClusters) accessForSpace(space *toolchainv1alpha1.Space, username
 	return nil, errs.New(errMsg)
 }
 
-func (s *MemberClusters) accessForCluster(apiEndpoint, clusterName, username, proxyPluginName string) (*access.ClusterAccess, error) {
+func (s *MemberClusters) accessForCluster(clusterName, username, proxyPluginName string) (*access.ClusterAccess, error) {
 	// Get the target member
 	members := s.GetMembersFunc()
 	if len(members) == 0 {
 		return nil, errs.New("no member clusters found")
 	}
 	for _, member := range members {
-		// also check that the member cluster name matches because the api endpoint is the same for both members
-		// in the e2e tests because a single cluster is used for testing multi-member scenarios
-		if member.APIEndpoint == apiEndpoint && member.Name == clusterName {
-			apiURL, err := s.getMemberURL(proxyPluginName, member)
+		// the member cluster is matched by its name and not by the api endpoint of the UserSignup, since the api endpoint
+		// is the same for both members in the e2e tests (a single cluster is used for testing multi-member scenarios),
+		// and is not the one of a member cluster whose endpoint was registered at runtime (see MemberRegistry)
+		if member.Name == clusterName {
+			apiURL, tlsConfig, err := s.getMemberURL(proxyPluginName, member)
 			if err != nil {
 				return nil, err
 			}
 			// requests use impersonation so are made with member ToolchainCluster token, not user tokens
-			impersonatorToken := member.RestConfig.BearerToken
-			return access.NewClusterAccess(*apiURL, impersonatorToken, username), nil
+			impersonatorToken, err := s.impersonatorToken(member)
+			if err != nil {
+				return nil, err
+			}
+			return access.NewMemberClusterAccess(member.Name, *apiURL, impersonatorToken, username, tlsConfig), nil
 		}
 	}
 
 	return nil, errs.New("no member cluster found for the user")
 }
 
-func (s *MemberClusters) getMemberURL(proxyPluginName string, member *cluster.CachedToolchainCluster) (*url.URL, error) {
+// accessForClusterName returns the access to the member cluster with the given name (or to the given proxy plugin in this member cluster),
+// impersonating the given user
+func (s *MemberClusters) accessForClusterName(clusterName, username, proxyPluginName string) (*access.ClusterAccess, error) {
+	for _, member := range s.GetMembersFunc() {
+		if member.Name == clusterName {
+			apiURL, tlsConfig, err := s.getMemberURL(proxyPluginName, member)
+			if err != nil {
+				return nil, err
+			}
+			impersonatorToken, err := s.impersonatorToken(member)
+			if err != nil {
+				return nil, err
+			}
+			return access.NewMemberClusterAccess(member.Name, *apiURL, impersonatorToken, username, tlsConfig), nil
+		}
+	}
+	return nil, fmt.Errorf("no member cluster found with the name '%s'", clusterName)
+}
+
+// impersonatorToken returns the token used to impersonate the users in the given member cluster: a short-lived token if configured,
+// or else the token of its ToolchainCluster
+func (s *MemberClusters) impersonatorToken(member *cluster.CachedToolchainCluster) (string, error) {
+	if s.impersonatorTokens == nil {
+		return member.RestConfig.BearerToken, nil
+	}
+	return s.impersonatorTokens.get(member, time.Now())
+}
+
+// WarmUpPluginEndpoints resolves the endpoints of all the proxy plugins in all the member clusters,
+// so that the first plugin requests after a restart don't need to fetch the Routes from the member clusters.
+// A plugin which can't be resolved in a member cluster is skipped, as it is not necessarily deployed in all of them.
+func (s *MemberClusters) WarmUpPluginEndpoints(ctx context.Context) error {
+	if s.pluginEndpoints == nil {
+		return nil
+	}
+	proxyPlugins := &toolchainv1alpha1.ProxyPluginList{}
+	if err := s.List(ctx, proxyPlugins, client.InNamespace(s.Namespace)); err != nil {
+		return errs.Wrap(err, "unable to list the proxy plugins")
+	}
+	for _, member := range s.GetMembersFunc() {
+		for _, proxyPlugin := range proxyPlugins.Items {
+			if _, _, err := s.getMemberURL(proxyPlugin.Name, member); err != nil {
+				log.Infof(nil, "unable to resolve the endpoint of the proxy plugin '%s' in the member cluster '%s': %s", proxyPlugin.Name, member.Name, err.Error())
+			}
+		}
+	}
+	return nil
+}
+
+// getMemberURL returns the URL of the member API endpoint or, if a proxy plugin is requested, the URL of the plugin backend
+// along with its TLS configuration (which is nil if the ProxyPlugin doesn't define any TLS setting)
+func (s *MemberClusters) getMemberURL(proxyPluginName string, member *cluster.CachedToolchainCluster) (*url.URL, *tls.Config, error) {
 	if member == nil {
-		return nil, errs.New("nil member provided")
+		return nil, nil, errs.New("nil member provided")
 	}
 	if len(proxyPluginName) == 0 {
-		return url.Parse(member.APIEndpoint)
+		apiURL, err := url.Parse(member.APIEndpoint)
+		return apiURL, nil, err
 	}
 	if member.Client == nil {
-		return nil, errs.New(fmt.Sprintf("client for member %s not set", member.Name))
+		return nil, nil, errs.New(fmt.Sprintf("client for member %s not set", member.Name))
 	}
 	proxyCfg := &toolchainv1alpha1.ProxyPlugin{}
 	if err := s.Get(context.TODO(), s.NamespacedName(proxyPluginName), proxyCfg); err != nil {
-		return nil, errs.New(fmt.Sprintf("unable to get proxy config %s: %s", proxyPluginName, err.Error()))
+		return nil, nil, errs.New(fmt.Sprintf("unable to get proxy config %s: %s", proxyPluginName, err.Error()))
 	}
 	if proxyCfg.Spec.OpenShiftRouteTargetEndpoint == nil {
-		return nil, errs.New(fmt.Sprintf("the proxy plugin config %s does not define an openshift route endpoint", proxyPluginName))
+		return nil, nil, errs.New(fmt.Sprintf("the proxy plugin config %s does not define an openshift route endpoint", proxyPluginName))
 	}
+	tlsConfig, err := s.pluginTLSConfig(proxyCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.pluginEndpoints != nil {
+		if pluginURL, found := s.pluginEndpoints.get(member.Name, proxyPluginName, proxyCfg.ResourceVersion); found {
+			return pluginURL, tlsConfig, nil
+		}
+	}
+	pluginURL, routeVersion, err := getPluginURL(member, proxyCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.pluginEndpoints != nil {
+		routeKey := types.NamespacedName{
+			Namespace: proxyCfg.Spec.OpenShiftRouteTargetEndpoint.Namespace,
+			Name:      proxyCfg.Spec.OpenShiftRouteTargetEndpoint.Name,
+		}
+		s.pluginEndpoints.set(member.Name, proxyPluginName, proxyCfg.ResourceVersion, pluginURL, func() (watch.Interface, error) {
+			return s.pluginEndpoints.watchRoute(member, routeKey, routeVersion)
+		})
+	}
+	return pluginURL, tlsConfig, nil
+}
+
+// getPluginURL returns the URL of the plugin backend exposed by the OpenShift Route targeted by the given ProxyPlugin,
+// along with the resource version of the Route
+func getPluginURL(member *cluster.CachedToolchainCluster, proxyCfg *toolchainv1alpha1.ProxyPlugin) (*url.URL, string, error) {
 	routeNamespace := proxyCfg.Spec.OpenShiftRouteTargetEndpoint.Namespace
 	routeName := proxyCfg.Spec.OpenShiftRouteTargetEndpoint.Name
 
@@ -181,12 +313,11 @@ func (s *MemberClusters) getMemberURL(proxyPluginName string, member *cluster.Ca
 		Namespace: routeNamespace,
 		Name:      routeName,
 	}
-	err := member.Client.Get(context.Background(), key, proxyRoute)
-	if err != nil {
-		return nil, err
+	if err := member.Client.Get(context.Background(), key, proxyRoute); err != nil {
+		return nil, "", err
 	}
 	if len(proxyRoute.Status.Ingress) == 0 {
-		return nil, fmt.Errorf("the route %q has not initialized to the point where the status ingress is populated", key.String())
+		return nil, "", fmt.Errorf("the route %q has not initialized to the point where the status ingress is populated", key.String())
 	}
 
 	scheme := ""
@@ -199,6 +330,41 @@ func (s *MemberClusters) getMemberURL(proxyPluginName string, member *cluster.Ca
 	default:
 		scheme = "https://"
 	}
-	return url.Parse(scheme + proxyRoute.Status.Ingress[0].Host)
+	pluginURL, err := url.Parse(scheme + proxyRoute.Status.Ingress[0].Host)
+	return pluginURL, proxyRoute.ResourceVersion, err
+}
+
+// pluginTLSConfig returns the TLS configuration defined by the annotations of the given ProxyPlugin,
+// or nil if none of the TLS annotations is set
+func (s *MemberClusters) pluginTLSConfig(proxyCfg *toolchainv1alpha1.ProxyPlugin) (*tls.Config, error) {
+	caSecretName, caSecretFound := proxyCfg.Annotations[ProxyPluginTLSCASecretAnnotationKey]
+	insecure, insecureFound := proxyCfg.Annotations[ProxyPluginTLSInsecureSkipVerifyAnnotationKey]
+	serverName, serverNameFound := proxyCfg.Annotations[ProxyPluginTLSServerNameAnnotationKey]
+	if !caSecretFound && !insecureFound && !serverNameFound {
+		return nil, nil
+	}
 
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+	if insecureFound {
+		insecureSkipVerify, err := strconv.ParseBool(insecure)
+		if err != nil {
+			return nil, errs.Wrapf(err, "invalid value of the '%s' annotation in the proxy plugin config %s", ProxyPluginTLSInsecureSkipVerifyAnnotationKey, proxyCfg.Name)
+		}
+		tlsConfig.InsecureSkipVerify = insecureSkipVerify // nolint:gosec
+	}
+	if caSecretFound {
+		secret := &corev1.Secret{}
+		if err := s.Get(context.TODO(), s.NamespacedName(caSecretName), secret); err != nil {
+			return nil, errs.Wrapf(err, "unable to get the CA bundle secret of the proxy plugin config %s", proxyCfg.Name)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(secret.Data[proxyPluginTLSCAKey]) {
+			return nil, fmt.Errorf("the secret %s does not contain a valid PEM encoded CA bundle in its '%s' key", caSecretName, proxyPluginTLSCAKey)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+	return tlsConfig, nil
 }
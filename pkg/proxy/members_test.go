@@ -2,10 +2,18 @@ package proxy_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/proxy"
@@ -24,7 +32,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -406,7 +416,7 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 				//given
 				expectedURL, err := url.Parse("https://api.endpoint.member-2.com:6443")
 				require.NoError(s.T(), err)
-				expectedClusterAccess := access.NewClusterAccess(*expectedURL, "token", toolchainv1alpha1.KubesawAuthenticatedUsername)
+				expectedClusterAccess := access.NewMemberClusterAccess("member-2", *expectedURL, "token", toolchainv1alpha1.KubesawAuthenticatedUsername, nil)
 
 				// when
 				clusterAccess, err := members.GetClusterAccess(toolchainv1alpha1.KubesawAuthenticatedUsername, "smith2", "", true)
@@ -437,6 +447,392 @@ func (s *TestMemberClustersSuite) TestGetClusterAccess() {
 	})
 }
 
+func (s *TestMemberClustersSuite) TestGetClusterAccessWithPluginTLSConfig() {
+	// given
+	sc := fake.NewSignupService(&signup.Signup{
+		Name:              "789-ready",
+		APIEndpoint:       "https://api.endpoint.member-2.com:6443",
+		ClusterName:       "member-2",
+		CompliantUsername: "smith2",
+		Username:          "smith@",
+		Status: signup.Status{
+			Ready: true,
+		},
+	})
+	memberClient := commontest.NewFakeClient(s.T())
+	memberClient.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+		if route, ok := obj.(*routev1.Route); ok {
+			route.Status.Ingress = []routev1.RouteIngress{
+				{
+					Host: "myservice.endpoint.member-2.com",
+				},
+			}
+			return nil
+		}
+		return memberClient.Client.Get(ctx, key, obj, opts...)
+	}
+	getMembers := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Config: &commoncluster.Config{
+					Name:        "member-2",
+					APIEndpoint: "https://api.endpoint.member-2.com:6443",
+					RestConfig: &rest.Config{
+						BearerToken: "abc123",
+					},
+				},
+				Client: memberClient,
+			},
+		}
+	}
+	newProxyPlugin := func(annotations map[string]string) *toolchainv1alpha1.ProxyPlugin {
+		return &toolchainv1alpha1.ProxyPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "tekton-results",
+				Namespace:   commontest.HostOperatorNs,
+				Annotations: annotations,
+			},
+			Spec: toolchainv1alpha1.ProxyPluginSpec{
+				OpenShiftRouteTargetEndpoint: &toolchainv1alpha1.OpenShiftRouteTarget{
+					Namespace: "tekton-results",
+					Name:      "tekton-results",
+				},
+			},
+		}
+	}
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tekton-results-ca",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string][]byte{
+			"ca.crt": newCACertificate(s.T()),
+		},
+	}
+
+	s.Run("no TLS annotation", func() {
+		// given
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), newProxyPlugin(nil)), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		ca, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Nil(s.T(), ca.TLSConfig())
+	})
+
+	s.Run("all TLS annotations", func() {
+		// given
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), caSecret, newProxyPlugin(map[string]string{
+			proxy.ProxyPluginTLSCASecretAnnotationKey:           "tekton-results-ca",
+			proxy.ProxyPluginTLSInsecureSkipVerifyAnnotationKey: "false",
+			proxy.ProxyPluginTLSServerNameAnnotationKey:         "tekton-results.internal",
+		})), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		ca, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), ca.TLSConfig())
+		assert.Equal(s.T(), "tekton-results.internal", ca.TLSConfig().ServerName)
+		assert.False(s.T(), ca.TLSConfig().InsecureSkipVerify)
+		assert.NotNil(s.T(), ca.TLSConfig().RootCAs)
+		apiURL := ca.APIURL()
+		assert.Equal(s.T(), "https://myservice.endpoint.member-2.com", apiURL.String())
+	})
+
+	s.Run("insecure skip verify", func() {
+		// given
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), newProxyPlugin(map[string]string{
+			proxy.ProxyPluginTLSInsecureSkipVerifyAnnotationKey: "true",
+		})), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		ca, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), ca.TLSConfig())
+		assert.True(s.T(), ca.TLSConfig().InsecureSkipVerify)
+		assert.Nil(s.T(), ca.TLSConfig().RootCAs)
+	})
+
+	s.Run("invalid insecure skip verify value", func() {
+		// given
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), newProxyPlugin(map[string]string{
+			proxy.ProxyPluginTLSInsecureSkipVerifyAnnotationKey: "yes please",
+		})), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		_, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.ErrorContains(s.T(), err, "invalid value of the 'toolchain.dev.openshift.com/proxy-plugin-tls-insecure-skip-verify' annotation in the proxy plugin config tekton-results")
+	})
+
+	s.Run("CA secret not found", func() {
+		// given
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), newProxyPlugin(map[string]string{
+			proxy.ProxyPluginTLSCASecretAnnotationKey: "tekton-results-ca",
+		})), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		_, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to get the CA bundle secret of the proxy plugin config tekton-results")
+	})
+
+	s.Run("invalid CA bundle", func() {
+		// given
+		invalidSecret := caSecret.DeepCopy()
+		invalidSecret.Data["ca.crt"] = []byte("not a certificate")
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), invalidSecret, newProxyPlugin(map[string]string{
+			proxy.ProxyPluginTLSCASecretAnnotationKey: "tekton-results-ca",
+		})), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers)
+
+		// when
+		_, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+
+		// then
+		require.EqualError(s.T(), err, "the secret tekton-results-ca does not contain a valid PEM encoded CA bundle in its 'ca.crt' key")
+	})
+}
+
+func (s *TestMemberClustersSuite) TestGetClusterAccessWithPluginEndpoints() {
+	// given
+	sc := fake.NewSignupService(&signup.Signup{
+		Name:              "789-ready",
+		APIEndpoint:       "https://api.endpoint.member-2.com:6443",
+		ClusterName:       "member-2",
+		CompliantUsername: "smith2",
+		Username:          "smith@",
+		Status: signup.Status{
+			Ready: true,
+		},
+	})
+	routeHost := "myservice.endpoint.member-2.com"
+	routeGets := 0
+	memberClient := &routeWatchingClient{FakeClient: commontest.NewFakeClient(s.T())}
+	memberClient.MockGet = func(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+		if route, ok := obj.(*routev1.Route); ok {
+			routeGets++
+			route.Status.Ingress = []routev1.RouteIngress{
+				{
+					Host: routeHost,
+				},
+			}
+			return nil
+		}
+		return memberClient.Client.Get(ctx, key, obj, opts...)
+	}
+	getMembers := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return []*commoncluster.CachedToolchainCluster{
+			{
+				Config: &commoncluster.Config{
+					Name:        "member-2",
+					APIEndpoint: "https://api.endpoint.member-2.com:6443",
+					RestConfig: &rest.Config{
+						BearerToken: "abc123",
+					},
+				},
+				Client: memberClient,
+			},
+		}
+	}
+	proxyPlugin := &toolchainv1alpha1.ProxyPlugin{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "tekton-results",
+			Namespace: commontest.HostOperatorNs,
+		},
+		Spec: toolchainv1alpha1.ProxyPluginSpec{
+			OpenShiftRouteTargetEndpoint: &toolchainv1alpha1.OpenShiftRouteTarget{
+				Namespace: "tekton-results",
+				Name:      "tekton-results",
+			},
+		},
+	}
+	getPluginHost := func(members *proxy.MemberClusters) string {
+		ca, err := members.GetClusterAccess("789-ready", "", "tekton-results", false)
+		require.NoError(s.T(), err)
+		apiURL := ca.APIURL()
+		return apiURL.Host
+	}
+
+	s.Run("route is fetched once", func() {
+		// given
+		routeGets = 0
+		routeHost = "myservice.endpoint.member-2.com"
+		fakeClient := commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy())
+		nsClient := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+
+		// when
+		for i := 0; i < 3; i++ {
+			assert.Equal(s.T(), "myservice.endpoint.member-2.com", getPluginHost(members))
+		}
+
+		// then
+		assert.Equal(s.T(), 1, routeGets)
+
+		s.Run("route is fetched again when the proxy plugin changes", func() {
+			// given
+			routeGets = 0
+			routeHost = "myservice-v2.endpoint.member-2.com"
+			plugin := &toolchainv1alpha1.ProxyPlugin{}
+			require.NoError(s.T(), fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(proxyPlugin), plugin))
+			plugin.Spec.OpenShiftRouteTargetEndpoint.Name = "tekton-results-v2"
+			require.NoError(s.T(), fakeClient.Update(context.TODO(), plugin))
+
+			// when
+			host := getPluginHost(members)
+
+			// then
+			assert.Equal(s.T(), "myservice-v2.endpoint.member-2.com", host)
+			assert.Equal(s.T(), 1, routeGets)
+		})
+	})
+
+	s.Run("route is fetched again when the route changes", func() {
+		// given
+		routeGets = 0
+		routeHost = "myservice.endpoint.member-2.com"
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+		require.Equal(s.T(), "myservice.endpoint.member-2.com", getPluginHost(members))
+		require.NotNil(s.T(), memberClient.routeWatch)
+
+		// when
+		routeHost = "myservice-v2.endpoint.member-2.com"
+		memberClient.routeWatch.Modify(&routev1.Route{})
+
+		// then
+		assert.Eventually(s.T(), func() bool {
+			return getPluginHost(members) == "myservice-v2.endpoint.member-2.com"
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), 2, routeGets)
+	})
+
+	s.Run("route is fetched again when the host is invalidated", func() {
+		// given
+		routeGets = 0
+		routeHost = "myservice.endpoint.member-2.com"
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		pluginEndpoints := proxy.NewPluginEndpoints()
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(pluginEndpoints))
+		getPluginHost(members)
+
+		// when
+		pluginEndpoints.InvalidateHost("myservice.endpoint.member-2.com")
+		getPluginHost(members)
+
+		// then
+		assert.Equal(s.T(), 2, routeGets)
+	})
+
+	s.Run("route is fetched again when the entry expired", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_PLUGIN_ENDPOINT_CACHE_TTL", "1ns")
+		routeGets = 0
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+
+		// when
+		getPluginHost(members)
+		time.Sleep(time.Millisecond)
+		getPluginHost(members)
+
+		// then
+		assert.Equal(s.T(), 2, routeGets)
+	})
+
+	s.Run("route watch stopped once the entry expired", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_PLUGIN_ENDPOINT_CACHE_TTL", "10ms")
+		routeHost = "myservice.endpoint.member-2.com"
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+
+		// when
+		getPluginHost(members)
+
+		// then
+		routeWatch := memberClient.routeWatch
+		require.NotNil(s.T(), routeWatch)
+		assert.Eventually(s.T(), routeWatch.IsStopped, 5*time.Second, 10*time.Millisecond)
+	})
+
+	s.Run("endpoints are resolved by the warm up", func() {
+		// given
+		routeGets = 0
+		routeHost = "myservice.endpoint.member-2.com"
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+
+		// when
+		err := members.WarmUpPluginEndpoints(context.TODO())
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), 1, routeGets)
+		assert.Equal(s.T(), "myservice.endpoint.member-2.com", getPluginHost(members))
+		assert.Equal(s.T(), 1, routeGets)
+	})
+
+	s.Run("cache disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_PLUGIN_ENDPOINT_CACHE_TTL", "0")
+		routeGets = 0
+		nsClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), proxyPlugin.DeepCopy()), commontest.HostOperatorNs)
+		members := proxy.NewMemberClusters(nsClient, sc, getMembers, proxy.WithPluginEndpoints(proxy.NewPluginEndpoints()))
+
+		// when
+		getPluginHost(members)
+		getPluginHost(members)
+
+		// then
+		assert.Equal(s.T(), 2, routeGets)
+	})
+}
+
+// routeWatchingClient is a fake client of a member cluster which returns a fake watch of the Routes
+type routeWatchingClient struct {
+	*commontest.FakeClient
+	// routeWatch is the last watch returned
+	routeWatch *watch.FakeWatcher
+}
+
+func (c *routeWatchingClient) Watch(_ context.Context, _ client.ObjectList, _ ...client.ListOption) (watch.Interface, error) {
+	c.routeWatch = watch.NewFake()
+	return c.routeWatch, nil
+}
+
+// newCACertificate returns a PEM encoded self-signed CA certificate
+func newCACertificate(t *testing.T) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
 func (s *TestMemberClustersSuite) assertClusterAccess(expected, actual *access.ClusterAccess) {
 	require.NotNil(s.T(), expected)
 	require.NotNil(s.T(), actual)
@@ -5,30 +5,156 @@ import (
 )
 
 const (
-	MetricLabelRejected  = "Rejected"
-	MetricsLabelVerbGet  = "Get"
-	MetricsLabelVerbList = "List"
+	MetricLabelCanary   = "canary"
+	MetricLabelBaseline = "baseline"
+	MetricLabelRejected = "Rejected"
+	MetricLabelHit      = "hit"
+	MetricLabelMiss     = "miss"
+	MetricLabelMatch    = "match"
+	MetricLabelMismatch = "mismatch"
+	MetricLabelError    = "error"
+	// MetricLabelUpstream is the direction of the data sent by the clients to the member clusters
+	MetricLabelUpstream = "upstream"
+	// MetricLabelDownstream is the direction of the data sent by the member clusters to the clients
+	MetricLabelDownstream = "downstream"
+	// MetricLabelRouteImplicit is the route type of the requests to the home workspace of the user
+	MetricLabelRouteImplicit = "implicit"
+	// MetricLabelRouteWorkspace is the route type of the requests to an explicit workspace, eg. with the /workspaces/<workspace> prefix
+	MetricLabelRouteWorkspace = "workspace"
+	// MetricLabelRoutePlugin is the route type of the requests to a proxy plugin, with the /plugins/<plugin> prefix
+	MetricLabelRoutePlugin = "plugin"
+	MetricsLabelVerbGet    = "Get"
+	MetricsLabelVerbList   = "List"
 )
 
 type ProxyMetrics struct {
-	// RegServProxyAPIHistogramVec measures the time taken by proxy before forwarding the request
+	// RegServProxyAPIHistogramVec measures the time taken by proxy before forwarding the request, per route type
+	// (implicit workspace, explicit workspace or proxy plugin)
 	RegServProxyAPIHistogramVec *prometheus.HistogramVec
 	// RegServWorkspaceHistogramVec measures the response time for either response or error from proxy when there is no routing
 	RegServWorkspaceHistogramVec *prometheus.HistogramVec
-	Reg                          *prometheus.Registry
+	// RegServProxyUpgradedConnectionsGauge reflects the number of active upgraded (websocket, SPDY) connections handled by the proxy
+	RegServProxyUpgradedConnectionsGauge prometheus.Gauge
+	// RegServProxyCanaryCounterVec counts the requests forwarded by proxy, per canary flag and per cohort of users
+	// (with or without the canary behavior), so that the canary behaviors can be compared with the current ones
+	RegServProxyCanaryCounterVec *prometheus.CounterVec
+	// RegServProxyTokenCacheCounterVec counts the lookups of the validated tokens in the cache of the proxy, per result (hit or miss)
+	RegServProxyTokenCacheCounterVec *prometheus.CounterVec
+	// RegServProxyWorkspaceQuotaRejectedCounter counts the requests rejected by proxy because their workspace exceeded its quota
+	RegServProxyWorkspaceQuotaRejectedCounter prometheus.Counter
+	// RegServProxyShadowCounterVec counts the requests shadowed by proxy to a secondary member cluster, per member cluster
+	// and per result (the status of the response matches the one of the primary member cluster, mismatches it, or an error occurred)
+	RegServProxyShadowCounterVec *prometheus.CounterVec
+	// RegServProxyUpstreamResponsesCounterVec counts the responses of the member clusters (and of the proxy plugin backends) to the
+	// requests forwarded by proxy, per member cluster and per status class (eg. 5xx), or the requests which failed to get a response
+	// (error), so that a misbehaving member cluster is visible
+	RegServProxyUpstreamResponsesCounterVec *prometheus.CounterVec
+	// RegServProxyUpgradedConnectionDurationHistogramVec measures the lifetime of the upgraded (websocket, SPDY) connections
+	// handled by proxy, per member cluster
+	RegServProxyUpgradedConnectionDurationHistogramVec *prometheus.HistogramVec
+	// RegServProxyUpgradedConnectionBytesHistogramVec measures the bytes transferred by the upgraded (websocket, SPDY) connections
+	// handled by proxy, per member cluster and per direction (upstream or downstream)
+	RegServProxyUpgradedConnectionBytesHistogramVec *prometheus.HistogramVec
+	// RegServProxyInFlightRejectedCounterVec counts the requests rejected by proxy because their member cluster had too many
+	// in-flight requests, per member cluster
+	RegServProxyInFlightRejectedCounterVec *prometheus.CounterVec
+	// RegServProxyShedCounterVec counts the requests shed by proxy because it was overloaded, per priority of the requests
+	RegServProxyShedCounterVec *prometheus.CounterVec
+	// RegServProxyActiveWatchesGaugeVec reflects the number of active watches handled by proxy, per member cluster
+	RegServProxyActiveWatchesGaugeVec *prometheus.GaugeVec
+	// RegServProxyTokenValidationFailuresCounterVec counts the tokens rejected by proxy, per reason (eg. expired, bad_signature,
+	// missing_claim or malformed, see auth.FailureReason), so that the spikes of 401 responses can be diagnosed
+	RegServProxyTokenValidationFailuresCounterVec *prometheus.CounterVec
+	Reg                                           *prometheus.Registry
 }
 
 const metricsPrefix = "sandbox_"
 
 func NewProxyMetrics(reg *prometheus.Registry) *ProxyMetrics {
-	regServProxyAPIHistogramVec := newHistogramVec("proxy_api_http_request_time", "time taken by proxy to route to a target cluster", "status_code", "route_to")
+	regServProxyAPIHistogramVec := newHistogramVec("proxy_api_http_request_time", "time taken by proxy to route to a target cluster", "status_code", "route_to", "route_type")
 	regServWorkspaceHistogramVec := newHistogramVec("proxy_workspace_http_request_time", "time for response of a request to proxy ", "status_code", "kube_verb")
+	regServProxyUpgradedConnectionsGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: metricsPrefix + "proxy_upgraded_connections",
+		Help: "number of active upgraded (websocket, SPDY) connections handled by proxy",
+	})
+	regServProxyCanaryCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_canary_requests_total",
+		Help: "number of requests forwarded by proxy per canary flag and cohort",
+	}, []string{"flag", "cohort", "status_code"})
+	regServProxyTokenCacheCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_token_cache_lookups_total",
+		Help: "number of lookups of the validated tokens in the cache of proxy per result",
+	}, []string{"result"})
+	regServProxyWorkspaceQuotaRejectedCounter := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_workspace_quota_rejected_requests_total",
+		Help: "number of requests rejected by proxy because their workspace exceeded its quota",
+	})
+	regServProxyShadowCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_shadow_requests_total",
+		Help: "number of requests shadowed by proxy to a secondary member cluster per member cluster and result",
+	}, []string{"cluster", "result"})
+	regServProxyUpstreamResponsesCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_upstream_responses_total",
+		Help: "number of responses to the requests forwarded by proxy per member cluster and status class",
+	}, []string{"cluster", "status_class"})
+	regServProxyUpgradedConnectionDurationHistogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: metricsPrefix + "proxy_upgraded_connection_duration_seconds",
+		Help: "lifetime of the upgraded (websocket, SPDY) connections handled by proxy per member cluster",
+		// from 1 second to 4 hours
+		Buckets: []float64{1, 10, 60, 300, 900, 3600, 14400},
+	}, []string{"cluster"})
+	regServProxyUpgradedConnectionBytesHistogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: metricsPrefix + "proxy_upgraded_connection_bytes",
+		Help: "bytes transferred by the upgraded (websocket, SPDY) connections handled by proxy per member cluster and direction",
+		// from 1KiB to 16GiB
+		Buckets: prometheus.ExponentialBuckets(1024, 8, 9),
+	}, []string{"cluster", "direction"})
+	regServProxyInFlightRejectedCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_in_flight_rejected_requests_total",
+		Help: "number of requests rejected by proxy because their member cluster had too many in-flight requests per member cluster",
+	}, []string{"cluster"})
+	regServProxyShedCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_shed_requests_total",
+		Help: "number of requests shed by proxy because it was overloaded per priority",
+	}, []string{"priority"})
+	regServProxyActiveWatchesGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricsPrefix + "proxy_active_watches",
+		Help: "number of active watches handled by proxy per member cluster",
+	}, []string{"cluster"})
+	regServProxyTokenValidationFailuresCounterVec := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: metricsPrefix + "proxy_token_validation_failures_total",
+		Help: "number of tokens rejected by proxy per reason",
+	}, []string{"reason"})
 	reg.MustRegister(regServProxyAPIHistogramVec)
 	reg.MustRegister(regServWorkspaceHistogramVec)
+	reg.MustRegister(regServProxyUpgradedConnectionsGauge)
+	reg.MustRegister(regServProxyCanaryCounterVec)
+	reg.MustRegister(regServProxyTokenCacheCounterVec)
+	reg.MustRegister(regServProxyWorkspaceQuotaRejectedCounter)
+	reg.MustRegister(regServProxyShadowCounterVec)
+	reg.MustRegister(regServProxyUpstreamResponsesCounterVec)
+	reg.MustRegister(regServProxyUpgradedConnectionDurationHistogramVec)
+	reg.MustRegister(regServProxyUpgradedConnectionBytesHistogramVec)
+	reg.MustRegister(regServProxyInFlightRejectedCounterVec)
+	reg.MustRegister(regServProxyShedCounterVec)
+	reg.MustRegister(regServProxyActiveWatchesGaugeVec)
+	reg.MustRegister(regServProxyTokenValidationFailuresCounterVec)
 	return &ProxyMetrics{
-		RegServWorkspaceHistogramVec: regServWorkspaceHistogramVec,
-		RegServProxyAPIHistogramVec:  regServProxyAPIHistogramVec,
-		Reg:                          reg,
+		RegServWorkspaceHistogramVec:                       regServWorkspaceHistogramVec,
+		RegServProxyAPIHistogramVec:                        regServProxyAPIHistogramVec,
+		RegServProxyUpgradedConnectionsGauge:               regServProxyUpgradedConnectionsGauge,
+		RegServProxyCanaryCounterVec:                       regServProxyCanaryCounterVec,
+		RegServProxyTokenCacheCounterVec:                   regServProxyTokenCacheCounterVec,
+		RegServProxyWorkspaceQuotaRejectedCounter:          regServProxyWorkspaceQuotaRejectedCounter,
+		RegServProxyShadowCounterVec:                       regServProxyShadowCounterVec,
+		RegServProxyUpstreamResponsesCounterVec:            regServProxyUpstreamResponsesCounterVec,
+		RegServProxyUpgradedConnectionDurationHistogramVec: regServProxyUpgradedConnectionDurationHistogramVec,
+		RegServProxyUpgradedConnectionBytesHistogramVec:    regServProxyUpgradedConnectionBytesHistogramVec,
+		RegServProxyInFlightRejectedCounterVec:             regServProxyInFlightRejectedCounterVec,
+		RegServProxyShedCounterVec:                         regServProxyShedCounterVec,
+		RegServProxyActiveWatchesGaugeVec:                  regServProxyActiveWatchesGaugeVec,
+		RegServProxyTokenValidationFailuresCounterVec:      regServProxyTokenValidationFailuresCounterVec,
+		Reg: reg,
 	}
 }
 
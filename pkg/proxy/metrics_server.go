@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/labstack/echo/v4"
 	glog "github.com/labstack/gommon/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,15 +14,32 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 )
 
+// ProxyMetricsPort is the admin port of the proxy, serving the Prometheus metrics and the health endpoint
 const ProxyMetricsPort = 8082
 
-// StartMetricsServer start server with a single `/metrics` endpoint to server the Prometheus metrics
+// StartMetricsServer start the admin server with a `/metrics` endpoint to server the Prometheus metrics,
+// the `/proxyhealth` endpoint to check the health of the proxy, the `/proxyready` endpoint to check the readiness and the
+// `/proxyadmin/*` admin endpoints of the given proxy (unless nil), along with the `/debug/pprof/` endpoints when enabled in the configuration.
 // Uses echo web framework
-func StartMetricsServer(reg *prometheus.Registry, port int) *http.Server {
+func StartMetricsServer(reg *prometheus.Registry, port int, p *Proxy) *http.Server {
 	log := logf.Log.WithName("proxy_metrics")
 	srv := echo.New()
 	srv.Logger.SetLevel(glog.INFO)
 	srv.GET("/metrics", echo.WrapHandler(promhttp.HandlerFor(reg, promhttp.HandlerOpts{DisableCompression: true, Registry: reg})))
+	srv.GET(proxyHealthEndpoint, health)
+	if p != nil {
+		srv.GET(proxyReadyEndpoint, p.Ready)
+		p.addAdminRoutes(srv)
+	}
+	if configuration.GetRegistrationServiceConfig().Proxy().PprofEnabled() {
+		log.Info("Enabling the pprof endpoints on the proxy metrics server")
+		srv.GET("/debug/pprof/cmdline", echo.WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+		srv.GET("/debug/pprof/profile", echo.WrapHandler(http.HandlerFunc(pprof.Profile)))
+		srv.Any("/debug/pprof/symbol", echo.WrapHandler(http.HandlerFunc(pprof.Symbol)))
+		srv.GET("/debug/pprof/trace", echo.WrapHandler(http.HandlerFunc(pprof.Trace)))
+		// the index also serves the named profiles, eg. /debug/pprof/heap or /debug/pprof/goroutine
+		srv.GET("/debug/pprof/*", echo.WrapHandler(http.HandlerFunc(pprof.Index)))
+	}
 	srv.DisableHTTP2 = true // disable HTTP/2 for now
 
 	log.Info("Starting the proxy metrics server...")
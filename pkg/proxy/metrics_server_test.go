@@ -2,8 +2,10 @@ package proxy_test
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"testing"
 	"time"
 
@@ -17,7 +19,7 @@ import (
 func TestProxyMetricsServer(t *testing.T) {
 	reg := prometheus.NewRegistry()
 	_ = metrics.NewProxyMetrics(reg)
-	server := proxy.StartMetricsServer(reg, proxy.ProxyMetricsPort)
+	server := proxy.StartMetricsServer(reg, proxy.ProxyMetricsPort, nil)
 	require.NotNil(t, server)
 	// Wait up to N seconds for the Metrics server to start
 	ready := false
@@ -61,10 +63,66 @@ func TestProxyMetricsServer(t *testing.T) {
 	_, err = buf.ReadFrom(resp.Body)
 	require.NoError(t, err)
 	assert.Equal(t, expectedServerBlankResponse, buf.String())
+
+	t.Run("health endpoint", func(t *testing.T) {
+		// when
+		resp, err := http.Get("http://localhost:8082/proxyhealth")
+
+		// then
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"alive": true}`, string(body))
+	})
+}
+
+func TestProxyMetricsServerPprof(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("pprof enabled: %t", enabled), func(t *testing.T) {
+			// given
+			t.Setenv("REGISTRATION_SERVICE_PROXY_PPROF_ENABLED", strconv.FormatBool(enabled))
+			reg := prometheus.NewRegistry()
+			server := proxy.StartMetricsServer(reg, 8086, nil)
+			require.NotNil(t, server)
+			defer func() {
+				_ = server.Close()
+			}()
+			require.Eventually(t, func() bool {
+				resp, err := http.Get("http://localhost:8086/metrics")
+				if err != nil {
+					return false
+				}
+				_ = resp.Body.Close()
+				return resp.StatusCode == http.StatusOK
+			}, 10*time.Second, 100*time.Millisecond)
+
+			for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine?debug=1", "/debug/pprof/cmdline"} {
+				// when
+				resp, err := http.Get("http://localhost:8086" + path)
+
+				// then
+				require.NoError(t, err)
+				_ = resp.Body.Close()
+				if enabled {
+					assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+				} else {
+					assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+				}
+			}
+		})
+	}
 }
 
 var expectedServerBlankResponse = `# HELP promhttp_metric_handler_errors_total Total number of internal errors encountered by the promhttp metric handler.
 # TYPE promhttp_metric_handler_errors_total counter
 promhttp_metric_handler_errors_total{cause="encoding"} 0
 promhttp_metric_handler_errors_total{cause="gathering"} 0
+# HELP sandbox_proxy_upgraded_connections number of active upgraded (websocket, SPDY) connections handled by proxy
+# TYPE sandbox_proxy_upgraded_connections gauge
+sandbox_proxy_upgraded_connections 0
+# HELP sandbox_proxy_workspace_quota_rejected_requests_total number of requests rejected by proxy because their workspace exceeded its quota
+# TYPE sandbox_proxy_workspace_quota_rejected_requests_total counter
+sandbox_proxy_workspace_quota_rejected_requests_total 0
 `
@@ -0,0 +1,44 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+// pushNotBeforeEndpoint is the endpoint Keycloak pushes the not-before policies of its realms to (see auth.PushNotBeforeAction),
+// with '<proxy_url>/proxyadmin/keycloak' as the admin URL of the client of the proxy in Keycloak. The tokens issued before the
+// not-before time of their realm are then rejected by the proxy and the registration service, which share the TokenParser, on all
+// the replicas of the proxy if the caches are shared (see SharedCache). The not-before times are never lowered: the pushes made
+// after clearing the not-before policy of a realm are ignored, until the proxy is restarted.
+const pushNotBeforeEndpoint = "/proxyadmin/keycloak/k_push_not_before"
+
+// maxPushNotBeforeActionSize is the maximum size of the body of the requests to the pushNotBeforeEndpoint
+const maxPushNotBeforeActionSize = 64 * 1024
+
+// pushNotBefore applies the not-before policy of the PushNotBeforeAction of the body, which must be signed with a key of a realm
+func (p *Proxy) pushNotBefore(ctx echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(ctx.Request().Body, maxPushNotBeforeActionSize))
+	if err != nil {
+		return crterrors.NewBadRequest("invalid not-before policy", err.Error())
+	}
+	issuer, notBefore, err := p.tokenParser.FromPushNotBeforeAction(strings.TrimSpace(string(body)))
+	if err != nil {
+		return crterrors.NewUnauthorizedError("invalid not-before policy", err.Error())
+	}
+	if !p.tokenParser.NotBeforePolicies().Set(issuer, notBefore) {
+		return ctx.NoContent(http.StatusNoContent)
+	}
+	log.InfoEchof(ctx, "rejecting the tokens of '%s' issued before %s", issuer, notBefore.UTC().Format(time.RFC3339))
+	if p.sharedCache != nil {
+		if err := p.sharedCache.saveNotBefore(ctx.Request().Context(), issuer, notBefore); err != nil {
+			return crterrors.NewInternalError(err, "the not-before policy only applies to this replica of the proxy")
+		}
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
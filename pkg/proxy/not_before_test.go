@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	authsupport "github.com/codeready-toolchain/toolchain-common/pkg/test/auth"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestPushNotBefore() {
+	// given
+	tokengenerator := authsupport.NewTokenManager()
+	kid := uuid.NewString()
+	_, err := tokengenerator.AddPrivateKey(kid)
+	require.NoError(s.T(), err)
+	keyServer := tokengenerator.NewKeyServer()
+	defer keyServer.Close()
+	env := s.DefaultConfig().Environment()
+	keysURL := s.DefaultConfig().Auth().AuthClientPublicKeysURL()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env).
+		Auth().AuthClientPublicKeysURL(keysURL))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.Dev)).
+		Auth().AuthClientPublicKeysURL(keyServer.URL))
+	keyManager, err := auth.NewKeyManager()
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParser(keyManager)
+	require.NoError(s.T(), err)
+	p := &Proxy{tokenParser: tokenParser}
+	issuer := s.DefaultConfig().Auth().Realms()[0].Issuer
+	notBefore := time.Now().Truncate(time.Second)
+	push := func(action string, notBefore time.Time) (*httptest.ResponseRecorder, error) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"id":         uuid.NewString(),
+			"action":     action,
+			"resource":   "sandbox-proxy",
+			"expiration": time.Now().Add(10 * time.Second).Unix(),
+			"notBefore":  notBefore.Unix(),
+		})
+		token.Header["kid"] = kid
+		signed, err := tokengenerator.SignToken(token, kid)
+		require.NoError(s.T(), err)
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, pushNotBeforeEndpoint, strings.NewReader(signed))
+		req.Header.Set("Content-Type", "text/plain")
+		return rec, p.pushNotBefore(echo.New().NewContext(req, rec))
+	}
+
+	s.Run("pushed", func() {
+		// when
+		rec, err := push("PUSH_NOT_BEFORE", notBefore)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), http.StatusNoContent, rec.Code)
+		current, found := tokenParser.NotBeforePolicies().Get(issuer)
+		require.True(s.T(), found)
+		assert.True(s.T(), notBefore.Equal(current))
+		claims := &auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   issuer,
+			IssuedAt: jwt.NewNumericDate(notBefore.Add(-time.Second)),
+		}}
+		assert.True(s.T(), tokenParser.IsRevoked(claims))
+
+		s.Run("earlier time ignored", func() {
+			// when
+			rec, err := push("PUSH_NOT_BEFORE", notBefore.Add(-time.Hour))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusNoContent, rec.Code)
+			current, _ := tokenParser.NotBeforePolicies().Get(issuer)
+			assert.True(s.T(), notBefore.Equal(current))
+		})
+	})
+
+	s.Run("invalid action", func() {
+		// when
+		_, err := push("PUSH_REVOCATION", notBefore.Add(time.Hour))
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusUnauthorized, crtErr.Code)
+		current, _ := tokenParser.NotBeforePolicies().Get(issuer)
+		assert.True(s.T(), notBefore.Equal(current))
+	})
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+// readVerbs are the verbs allowed by the read scope of the personal access tokens
+var readVerbs = []string{"get", "list", "watch"}
+
+// personalAccessToken returns the claims of the personal access token of the request, if the request was sent with such a token
+func personalAccessToken(ctx echo.Context) (*auth.TokenClaims, bool) {
+	claims, ok := ctx.Get(context.JWTClaimsKey).(*auth.TokenClaims)
+	if !ok || !auth.IsPersonalAccessTokenClaims(claims) {
+		return nil, false
+	}
+	return claims, true
+}
+
+// checkPersonalAccessTokenScopes returns a Forbidden error if the request was sent with a personal access token whose scopes don't
+// allow the request: the read scope only allows the 'get', 'list' and 'watch' requests (and the self reviews of the access), and the
+// workspace scopes (if any) limit the requests to their workspaces
+func checkPersonalAccessTokenScopes(ctx echo.Context, proxyPluginName string) error {
+	claims, ok := personalAccessToken(ctx)
+	if !ok {
+		return nil
+	}
+	scopes := auth.PersonalAccessTokenScopes(claims)
+	name := auth.PersonalAccessTokenName(claims)
+	workspace := targetWorkspace(ctx)
+	var workspaces []string
+	for _, scope := range scopes {
+		if scoped, found := strings.CutPrefix(scope, auth.WorkspaceScopePrefix); found {
+			workspaces = append(workspaces, scoped)
+		}
+	}
+	if len(workspaces) > 0 && !slices.Contains(workspaces, workspace) {
+		log.InfoEchof(ctx, "denying the request: the '%s' personal access token is not scoped to the '%s' workspace", name, workspace)
+		return crterrors.NewForbiddenError("request denied by token scopes",
+			fmt.Sprintf("the '%s' personal access token is limited to the '%s' workspaces", name, strings.Join(workspaces, "|")))
+	}
+	if slices.Contains(scopes, auth.WriteScope) {
+		return nil
+	}
+	verb, resource := methodVerb(ctx.Request().Method), ctx.Request().URL.Path
+	if attributes, ok := requestAttributes(ctx.Request()); ok && proxyPluginName == "" {
+		verb, resource = attributes.Verb, qualifiedResource(attributes)
+		if verb == "create" && slices.Contains(selfReviewResources, resource) {
+			return nil
+		}
+	}
+	if slices.Contains(readVerbs, verb) {
+		return nil
+	}
+	log.InfoEchof(ctx, "denying the request: '%s %s' is not allowed by the read-only '%s' personal access token", verb, resource, name)
+	return crterrors.NewForbiddenError("request denied by token scopes",
+		fmt.Sprintf("the '%s' personal access token is read-only, '%s %s' is not allowed", name, verb, resource))
+}
+
+// checkNoPersonalAccessToken returns a Forbidden error if the request was sent with a personal access token, which can't be used
+// for the admin endpoints of the proxy
+func checkNoPersonalAccessToken(ctx echo.Context) error {
+	if _, ok := personalAccessToken(ctx); ok {
+		return crterrors.NewForbiddenError("invalid admin request", "the personal access tokens can't be used for the admin endpoints")
+	}
+	return nil
+}
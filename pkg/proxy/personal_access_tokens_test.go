@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestCheckPersonalAccessTokenScopes() {
+	// given
+	newContext := func(method, path string, scopes ...string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+		ctx.Set(context.WorkspaceKey, "smith-dev")
+		raw, err := json.Marshal(map[string]interface{}{"iss": auth.PersonalAccessTokenIssuer, "token_name": "tekton-ci", "scopes": scopes})
+		require.NoError(s.T(), err)
+		claims := &auth.TokenClaims{}
+		require.NoError(s.T(), json.Unmarshal(raw, claims))
+		ctx.Set(context.JWTClaimsKey, claims)
+		return ctx
+	}
+
+	s.Run("denied", func() {
+		for _, tc := range []struct {
+			method  string
+			path    string
+			plugin  string
+			scopes  []string
+			details string
+		}{
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "", []string{"read"},
+				"the 'tekton-ci' personal access token is read-only, 'delete pods' is not allowed"},
+			{http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec", "", []string{"read", "workspace:smith-dev"},
+				"the 'tekton-ci' personal access token is read-only, 'create pods/exec' is not allowed"},
+			{http.MethodPost, "/plugins/tekton-results/workspaces/smith-dev/api/v1/results", "tekton-results", []string{"read"},
+				"the 'tekton-ci' personal access token is read-only, 'create /plugins/tekton-results/workspaces/smith-dev/api/v1/results' is not allowed"},
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "", []string{"write", "workspace:smith-stage"},
+				"the 'tekton-ci' personal access token is limited to the 'smith-stage' workspaces"},
+		} {
+			s.Run(tc.method+" "+tc.path, func() {
+				// when
+				err := checkPersonalAccessTokenScopes(newContext(tc.method, tc.path, tc.scopes...), tc.plugin)
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+				assert.Equal(s.T(), "request denied by token scopes", crtErr.Message)
+				assert.Equal(s.T(), tc.details, crtErr.Details)
+			})
+		}
+	})
+
+	s.Run("allowed", func() {
+		for _, tc := range []struct {
+			method string
+			path   string
+			plugin string
+			scopes []string
+		}{
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "", []string{"read"}},
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods?watch=true", "", []string{"read", "workspace:smith-dev"}},
+			{http.MethodPost, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", "", []string{"read"}},
+			{http.MethodGet, "/plugins/tekton-results/workspaces/smith-dev/api/v1/results", "tekton-results", []string{"read"}},
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "", []string{"write"}},
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "", []string{"write", "workspace:smith-stage", "workspace:smith-dev"}},
+		} {
+			s.Run(tc.method+" "+tc.path, func() {
+				// when
+				err := checkPersonalAccessTokenScopes(newContext(tc.method, tc.path, tc.scopes...), tc.plugin)
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+
+	s.Run("not a personal access token", func() {
+		// given
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", nil), httptest.NewRecorder())
+		ctx.Set(context.JWTClaimsKey, &auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Issuer: "https://sso.redhat.com"}})
+
+		// when
+		err := checkPersonalAccessTokenScopes(ctx, "")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("admin endpoints refused", func() {
+		// given
+		ctx := newContext(http.MethodGet, revocationsEndpoint, "write")
+		ctx.Set(context.UsernameKey, "admin")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+
+		// when
+		err := checkProxyAdmin(ctx)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+		assert.Equal(s.T(), "the personal access tokens can't be used for the admin endpoints", crtErr.Details)
+	})
+}
@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+
+	routev1 "github.com/openshift/api/route/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type pluginEndpointKey struct {
+	memberName      string
+	proxyPluginName string
+}
+
+type pluginEndpoint struct {
+	url *url.URL
+	// proxyPluginVersion is the resource version of the ProxyPlugin at the time the endpoint was resolved,
+	// so that the endpoint is resolved again as soon as the ProxyPlugin changes (eg. to target another Route)
+	proxyPluginVersion string
+	expiresAt          time.Time
+	// routeWatch is the watch of the Route the URL was resolved from (if any), which is stopped when the entry is removed
+	routeWatch watch.Interface
+}
+
+// routeWatchClient is the client watching the Routes of a member cluster, along with the API server and the token it was created with
+type routeWatchClient struct {
+	host   string
+	token  string
+	client client.WithWatch
+}
+
+// PluginEndpoints caches the URLs of the proxy plugin backends resolved from the OpenShift Routes in the member clusters,
+// so that the Route doesn't need to be fetched from the member cluster on every plugin request.
+// The entries are invalidated as soon as their Route changes or is deleted, or when the plugin backend can't be reached,
+// and expire after the configured TTL anyway, in case the Route couldn't be watched. There is at most one watch per entry,
+// which is stopped once the entry is removed or expires.
+type PluginEndpoints struct {
+	mu      sync.RWMutex
+	entries map[pluginEndpointKey]pluginEndpoint
+	// watchClients are the clients watching the Routes, by member cluster
+	watchClients map[string]routeWatchClient
+}
+
+// NewPluginEndpoints returns a new, empty PluginEndpoints cache
+func NewPluginEndpoints() *PluginEndpoints {
+	return &PluginEndpoints{
+		entries:      map[pluginEndpointKey]pluginEndpoint{},
+		watchClients: map[string]routeWatchClient{},
+	}
+}
+
+// get returns the cached URL of the given plugin in the given member, if it is still valid for the given version of the ProxyPlugin
+func (e *PluginEndpoints) get(memberName, proxyPluginName, proxyPluginVersion string) (*url.URL, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	endpoint, found := e.entries[pluginEndpointKey{memberName: memberName, proxyPluginName: proxyPluginName}]
+	if !found || endpoint.proxyPluginVersion != proxyPluginVersion || time.Now().After(endpoint.expiresAt) {
+		return nil, false
+	}
+	u := *endpoint.url
+	return &u, true
+}
+
+// set stores the URL of the given plugin in the given member, unless the cache is disabled.
+// The entry is removed as soon as the watch returned by the given function (if any) receives an event, ie. when the Route changes.
+func (e *PluginEndpoints) set(memberName, proxyPluginName, proxyPluginVersion string, pluginURL *url.URL, watchRoute func() (watch.Interface, error)) {
+	ttl := configuration.GetRegistrationServiceConfig().Proxy().PluginEndpointCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+	key := pluginEndpointKey{memberName: memberName, proxyPluginName: proxyPluginName}
+	var routeWatch watch.Interface
+	if watchRoute != nil {
+		var err error
+		if routeWatch, err = watchRoute(); err != nil {
+			// the entry still expires after the TTL
+			log.Error(nil, err, "unable to watch the route of the proxy plugin "+proxyPluginName+" in the member cluster "+memberName)
+		}
+	}
+	u := *pluginURL
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.remove(key)
+	e.entries[key] = pluginEndpoint{
+		url:                &u,
+		proxyPluginVersion: proxyPluginVersion,
+		expiresAt:          time.Now().Add(ttl),
+		routeWatch:         routeWatch,
+	}
+	if routeWatch != nil {
+		go e.invalidateOnRouteEvent(key, routeWatch, ttl)
+	}
+}
+
+// invalidateOnRouteEvent removes the entry with the given key once the given watch of its Route receives an event,
+// or ends (in which case the Route may have changed in the meantime), or once the entry expires after the given TTL
+func (e *PluginEndpoints) invalidateOnRouteEvent(key pluginEndpointKey, routeWatch watch.Interface, ttl time.Duration) {
+	expired := time.NewTimer(ttl)
+	defer expired.Stop()
+	select {
+	case <-routeWatch.ResultChan():
+	case <-expired.C:
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	// the entry may have been replaced in the meantime, along with its watch
+	if endpoint, found := e.entries[key]; found && endpoint.routeWatch == routeWatch {
+		e.remove(key)
+	}
+	routeWatch.Stop()
+}
+
+// watchRoute watches the changes of the Route with the given key in the given member cluster, after the given resource version.
+// The Route is watched with the client of the member cluster if it supports watches, or else with a client created once per member
+// cluster (and again when its API server or its token changes).
+func (e *PluginEndpoints) watchRoute(member *cluster.CachedToolchainCluster, key types.NamespacedName, resourceVersion string) (watch.Interface, error) {
+	watchClient, err := e.watchClient(member)
+	if err != nil {
+		return nil, err
+	}
+	return watchClient.Watch(context.Background(), &routev1.RouteList{},
+		client.InNamespace(key.Namespace),
+		client.MatchingFields{"metadata.name": key.Name},
+		&client.ListOptions{Raw: &metav1.ListOptions{ResourceVersion: resourceVersion}})
+}
+
+// watchClient returns the client watching the Routes of the given member cluster
+func (e *PluginEndpoints) watchClient(member *cluster.CachedToolchainCluster) (client.WithWatch, error) {
+	if watchClient, ok := member.Client.(client.WithWatch); ok {
+		return watchClient, nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if cached, found := e.watchClients[member.Name]; found && cached.host == member.RestConfig.Host && cached.token == member.RestConfig.BearerToken {
+		return cached.client, nil
+	}
+	watchClient, err := client.NewWithWatch(member.RestConfig, client.Options{Scheme: member.Client.Scheme()})
+	if err != nil {
+		return nil, err
+	}
+	e.watchClients[member.Name] = routeWatchClient{
+		host:   member.RestConfig.Host,
+		token:  member.RestConfig.BearerToken,
+		client: watchClient,
+	}
+	return watchClient, nil
+}
+
+// InvalidateHost removes all the entries pointing to the given host
+func (e *PluginEndpoints) InvalidateHost(host string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key, endpoint := range e.entries {
+		if endpoint.url.Host == host {
+			e.remove(key)
+		}
+	}
+}
+
+// remove removes the entry with the given key (if any) and stops the watch of its Route. The lock must be held by the caller.
+func (e *PluginEndpoints) remove(key pluginEndpointKey) {
+	if endpoint, found := e.entries[key]; found {
+		if endpoint.routeWatch != nil {
+			endpoint.routeWatch.Stop()
+		}
+		delete(e.entries, key)
+	}
+}
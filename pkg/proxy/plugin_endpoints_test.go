@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func (s *TestProxySuite) TestRouteWatchClient() {
+	// given
+	// a client which can't watch, as the clients of the cached member clusters
+	memberClient := struct{ client.Client }{commontest.NewFakeClient(s.T())}
+	newMember := func(token string) *commoncluster.CachedToolchainCluster {
+		return &commoncluster.CachedToolchainCluster{
+			Config: &commoncluster.Config{
+				Name:       "member-1",
+				RestConfig: &rest.Config{Host: "https://api.member-1.example.com:6443", BearerToken: token},
+			},
+			Client: memberClient,
+		}
+	}
+	pluginEndpoints := NewPluginEndpoints()
+	first, err := pluginEndpoints.watchClient(newMember("abc123"))
+	require.NoError(s.T(), err)
+
+	s.Run("client reused for the member cluster", func() {
+		// when
+		watchClient, err := pluginEndpoints.watchClient(newMember("abc123"))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), watchClient == first)
+	})
+
+	s.Run("client created again once the token is rotated", func() {
+		// when
+		watchClient, err := pluginEndpoints.watchClient(newMember("def456"))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.False(s.T(), watchClient == first)
+		assert.Len(s.T(), pluginEndpoints.watchClients, 1)
+	})
+}
+
+func (s *TestProxySuite) TestProxyPluginUnreachable() {
+	// given
+	plugin := httptest.NewServer(http.NotFoundHandler())
+	plugin.Close()
+	pluginURL, err := url.Parse(plugin.URL)
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *pluginURL, "clusterSAToken", "smith", nil)
+	p := &Proxy{pluginEndpoints: NewPluginEndpoints(), metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+	p.pluginEndpoints.set("member-1", "tekton-results", "1", pluginURL, nil)
+	req := httptest.NewRequest(http.MethodGet, "/plugins/tekton-results/api/v1/results", nil)
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(req, rec)
+	ctx.Set(context.UsernameKey, "smith")
+
+	// when
+	p.newReverseProxy(ctx, target, true, &upstreamAccounting{}, CanaryFlags{}).ServeHTTP(ctx.Response(), req)
+
+	// then
+	assert.Equal(s.T(), http.StatusBadGateway, rec.Code)
+	status := &metav1.Status{}
+	require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), status))
+	assert.Equal(s.T(), metav1.StatusFailure, status.Status)
+	assert.Equal(s.T(), "unable to proxy the request: the proxy plugin backend can't be reached", status.Message)
+	assert.Equal(s.T(), int32(http.StatusBadGateway), status.Code)
+	// the endpoint is resolved again for the next request
+	_, found := p.pluginEndpoints.get("member-1", "tekton-results", "1")
+	assert.False(s.T(), found)
+}
@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+// requestAttributes returns the RBAC attributes (verb, API group, resource, subresource, namespace and name) of the given request
+// to the API of a member cluster, once the workspace prefix is removed from its path, or false if the request is not a resource request
+// (eg. a discovery request)
+func requestAttributes(req *http.Request) (*authorizationv1.ResourceAttributes, bool) {
+	attributes := &authorizationv1.ResourceAttributes{}
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	switch {
+	case len(segments) >= 3 && segments[0] == "api":
+		attributes.Version = segments[1]
+		segments = segments[2:]
+	case len(segments) >= 4 && segments[0] == "apis":
+		attributes.Group = segments[1]
+		attributes.Version = segments[2]
+		segments = segments[3:]
+	default:
+		return nil, false
+	}
+	// the watches of the deprecated /watch/ paths
+	watchPath := segments[0] == "watch"
+	if watchPath {
+		segments = segments[1:]
+	}
+	// the namespaced resources, but not the namespaces themselves
+	if len(segments) >= 3 && segments[0] == "namespaces" {
+		attributes.Namespace = segments[1]
+		segments = segments[2:]
+	}
+	if len(segments) == 0 || segments[0] == "" {
+		return nil, false
+	}
+	attributes.Resource = strings.ToLower(segments[0])
+	if len(segments) >= 2 {
+		attributes.Name = segments[1]
+	}
+	if len(segments) >= 3 {
+		attributes.Subresource = strings.ToLower(segments[2])
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		watch, _ := strconv.ParseBool(req.URL.Query().Get("watch"))
+		switch {
+		case watchPath || watch:
+			attributes.Verb = "watch"
+		case attributes.Name != "":
+			attributes.Verb = "get"
+		default:
+			attributes.Verb = "list"
+		}
+	case http.MethodDelete:
+		attributes.Verb = "delete"
+		if attributes.Name == "" {
+			attributes.Verb = "deletecollection"
+		}
+	default:
+		attributes.Verb = methodVerb(req.Method)
+	}
+	return attributes, true
+}
+
+// methodVerb returns the RBAC verb of the given HTTP method, for a single resource or a non-resource URL
+func methodVerb(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return "get"
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case http.MethodPatch:
+		return "patch"
+	case http.MethodDelete:
+		return "delete"
+	default:
+		return strings.ToLower(method)
+	}
+}
+
+// qualifiedResource returns the resource of the given attributes, with its subresource if any, eg. 'pods/exec'
+func qualifiedResource(attributes *authorizationv1.ResourceAttributes) string {
+	if attributes.Subresource == "" {
+		return attributes.Resource
+	}
+	return attributes.Resource + "/" + attributes.Subresource
+}
+
+// denyRuleMatches returns true if the given rule denies the request with the given verb and resource
+// from a user with the given role in the targeted workspace
+func denyRuleMatches(rule configuration.DenyRule, role, verb, resource string) bool {
+	if rule.Role != "*" && rule.Role != role {
+		return false
+	}
+	if rule.Verb != "*" && rule.Verb != verb {
+		return false
+	}
+	if rule.Resource == "*" || rule.Resource == resource {
+		return true
+	}
+	prefix, found := strings.CutSuffix(rule.Resource, "/*")
+	return found && strings.HasPrefix(resource, prefix+"/")
+}
+
+// checkDenyRules returns a Forbidden error if the request to the API of the member cluster is denied by one of the DenyRules
+// of the proxy, so that the request never reaches the member cluster. The requests to the proxy plugins are not checked.
+func checkDenyRules(ctx echo.Context) error {
+	rules := configuration.GetRegistrationServiceConfig().Proxy().DenyRules()
+	if len(rules) == 0 {
+		return nil
+	}
+	attributes, ok := requestAttributes(ctx.Request())
+	if !ok {
+		return nil
+	}
+	verb, resource := attributes.Verb, qualifiedResource(attributes)
+	role, _ := ctx.Get(context.WorkspaceRoleKey).(string)
+	for _, rule := range rules {
+		if denyRuleMatches(rule, role, verb, resource) {
+			log.InfoEchof(ctx, "denying the request: '%s %s' is denied by the proxy policy", verb, resource)
+			return crterrors.NewForbiddenError("request denied by policy", fmt.Sprintf("'%s %s' is not allowed", verb, resource))
+		}
+	}
+	return nil
+}
+
+// selfReviewResources are the resources the users can always create whatever their role, since the reviews only disclose the
+// identity and the permissions of the users themselves (eg. with 'kubectl auth can-i')
+var selfReviewResources = []string{"selfsubjectaccessreviews", "selfsubjectrulesreviews", "selfsubjectreviews"}
+
+// checkRoleVerbs returns a Forbidden error if the verb of the request to the API of the member cluster is not one of the verbs the
+// role of the user in the targeted workspace is limited to (see the RoleAllowedVerbs setting), eg. a request to update a resource
+// from a viewer, so that the request never reaches the member cluster. The requests to the proxy plugins are not checked.
+func checkRoleVerbs(ctx echo.Context) error {
+	role, _ := ctx.Get(context.WorkspaceRoleKey).(string)
+	allowed, found := configuration.GetRegistrationServiceConfig().Proxy().RoleAllowedVerbs()[role]
+	if !found {
+		return nil
+	}
+	verb, resource := methodVerb(ctx.Request().Method), ctx.Request().URL.Path
+	if attributes, ok := requestAttributes(ctx.Request()); ok {
+		verb, resource = attributes.Verb, qualifiedResource(attributes)
+		if verb == "create" && slices.Contains(selfReviewResources, resource) {
+			return nil
+		}
+	}
+	if slices.Contains(allowed, verb) {
+		return nil
+	}
+	workspace := targetWorkspace(ctx)
+	log.InfoEchof(ctx, "denying the request: '%s %s' is not allowed for the '%s' role in the '%s' workspace", verb, resource, role, workspace)
+	return crterrors.NewForbiddenError("request denied by role",
+		fmt.Sprintf("the '%s' role in the workspace '%s' only allows the '%s' verbs, not '%s %s'", role, workspace, strings.Join(allowed, "|"), verb, resource))
+}
+
+// checkDeniedPaths returns a Forbidden error if the path of the request to the API of the member cluster is one of the DeniedPaths
+// of the proxy, so that the request never reaches the member cluster with the privileges of the impersonating service account.
+// The path is cleaned first, so that a denied path can't be reached with eg. '/api/../metrics'. The requests to the proxy plugins
+// are not checked.
+func checkDeniedPaths(ctx echo.Context) error {
+	deniedPaths := configuration.GetRegistrationServiceConfig().Proxy().DeniedPaths()
+	if len(deniedPaths) == 0 {
+		return nil
+	}
+	requestPath := path.Clean("/" + ctx.Request().URL.Path)
+	for _, denied := range deniedPaths {
+		prefix, wildcard := strings.CutSuffix(denied, "/*")
+		if requestPath == denied || (wildcard && strings.HasPrefix(requestPath, prefix+"/")) {
+			log.InfoEchof(ctx, "denying the request: the '%s' path is denied by the proxy policy", requestPath)
+			return crterrors.NewForbiddenError("request denied by policy", fmt.Sprintf("the path '%s' is not allowed", requestPath))
+		}
+	}
+	return nil
+}
+
+// workspaceNamespaces returns the names of the namespaces of the given workspace
+func workspaceNamespaces(workspace *toolchainv1alpha1.Workspace) []string {
+	namespaces := make([]string, 0, len(workspace.Status.Namespaces))
+	for _, ns := range workspace.Status.Namespaces {
+		namespaces = append(namespaces, ns.Name)
+	}
+	return namespaces
+}
+
+// checkWorkspaceNamespace returns a Forbidden error if the StrictNamespaceValidation of the proxy is enabled and the request to the API
+// of the member cluster targets a namespace which doesn't belong to the targeted workspace, so that the request never reaches the member
+// cluster. The requests to the cluster-scoped resources (except the namespaces) and to the proxy plugins are not checked.
+func checkWorkspaceNamespace(ctx echo.Context) error {
+	if !configuration.GetRegistrationServiceConfig().Proxy().StrictNamespaceValidationEnabled() {
+		return nil
+	}
+	namespaces, found := ctx.Get(context.WorkspaceNamespacesKey).([]string)
+	if !found {
+		// the workspace of the request was not resolved
+		return nil
+	}
+	attributes, ok := requestAttributes(ctx.Request())
+	if !ok {
+		return nil
+	}
+	namespace := attributes.Namespace
+	if attributes.Resource == "namespaces" && namespace == "" {
+		namespace = attributes.Name
+	}
+	if namespace == "" || slices.Contains(namespaces, namespace) {
+		return nil
+	}
+	workspace := targetWorkspace(ctx)
+	log.InfoEchof(ctx, "denying the request: the '%s' namespace doesn't belong to the '%s' workspace", namespace, workspace)
+	return crterrors.NewForbiddenError("invalid namespace request", fmt.Sprintf("the namespace '%s' doesn't belong to the workspace '%s'", namespace, workspace))
+}
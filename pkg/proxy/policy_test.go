@@ -0,0 +1,324 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+)
+
+func (s *TestProxySuite) TestRequestAttributes() {
+	for _, tc := range []struct {
+		method   string
+		path     string
+		verb     string
+		resource string
+	}{
+		{http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "list", "pods"},
+		{http.MethodGet, "/api/v1/namespaces/smith-dev/pods?watch=true", "watch", "pods"},
+		{http.MethodGet, "/api/v1/watch/namespaces/smith-dev/pods", "watch", "pods"},
+		{http.MethodGet, "/api/v1/namespaces/smith-dev/secrets/token", "get", "secrets"},
+		{http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec?command=sh", "create", "pods/exec"},
+		{http.MethodGet, "/api/v1/namespaces/smith-dev/pods/app/log", "get", "pods/log"},
+		{http.MethodPut, "/apis/apps/v1/namespaces/smith-dev/deployments/app/scale", "update", "deployments/scale"},
+		{http.MethodPatch, "/apis/apps/v1/namespaces/smith-dev/Deployments/app", "patch", "deployments"},
+		{http.MethodDelete, "/api/v1/namespaces/smith-dev/configmaps/config", "delete", "configmaps"},
+		{http.MethodDelete, "/api/v1/namespaces/smith-dev/configmaps", "deletecollection", "configmaps"},
+		{http.MethodGet, "/api/v1/namespaces/smith-dev", "get", "namespaces"},
+		{http.MethodGet, "/apis/rbac.authorization.k8s.io/v1/clusterroles", "list", "clusterroles"},
+	} {
+		s.Run(tc.method+" "+tc.path, func() {
+			// when
+			attributes, ok := requestAttributes(httptest.NewRequest(tc.method, tc.path, nil))
+
+			// then
+			require.True(s.T(), ok)
+			assert.Equal(s.T(), tc.verb, attributes.Verb)
+			assert.Equal(s.T(), tc.resource, qualifiedResource(attributes))
+		})
+	}
+
+	s.Run("all the attributes", func() {
+		// when
+		attributes, ok := requestAttributes(httptest.NewRequest(http.MethodPut, "/apis/apps/v1/namespaces/smith-dev/deployments/app/scale", nil))
+
+		// then
+		require.True(s.T(), ok)
+		assert.Equal(s.T(), &authorizationv1.ResourceAttributes{
+			Namespace:   "smith-dev",
+			Verb:        "update",
+			Group:       "apps",
+			Version:     "v1",
+			Resource:    "deployments",
+			Subresource: "scale",
+			Name:        "app",
+		}, attributes)
+	})
+
+	s.Run("not a resource request", func() {
+		for _, path := range []string{"/api", "/api/v1", "/apis/apps/v1", "/version", "/openapi/v2"} {
+			_, ok := requestAttributes(httptest.NewRequest(http.MethodGet, path, nil))
+			assert.False(s.T(), ok, path)
+		}
+	})
+}
+
+func (s *TestProxySuite) TestCheckDenyRules() {
+	// given
+	newContext := func(method, path, role string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+		if role != "" {
+			ctx.Set(context.WorkspaceRoleKey, role)
+		}
+		return ctx
+	}
+
+	s.Run("no rule", func() {
+		// when
+		err := checkDenyRules(newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/secrets/token", "admin"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_DENY_RULES", "get:secrets,viewer:create:pods/exec,*:deployments/*")
+
+	s.Run("denied", func() {
+		for _, tc := range []struct {
+			method string
+			path   string
+			role   string
+		}{
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/secrets/token", "admin"},
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/secrets/token", ""},
+			{http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec", "viewer"},
+			{http.MethodPut, "/apis/apps/v1/namespaces/smith-dev/deployments/app/scale", "admin"},
+		} {
+			s.Run(tc.method+" "+tc.path+" as "+tc.role, func() {
+				// when
+				err := checkDenyRules(newContext(tc.method, tc.path, tc.role))
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+				assert.Equal(s.T(), "request denied by policy", crtErr.Message)
+			})
+		}
+	})
+
+	s.Run("allowed", func() {
+		for _, tc := range []struct {
+			method string
+			path   string
+			role   string
+		}{
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/secrets", "admin"},
+			{http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec", "admin"},
+			{http.MethodPut, "/apis/apps/v1/namespaces/smith-dev/deployments/app", "viewer"},
+			{http.MethodGet, "/apis/apps/v1", "viewer"},
+		} {
+			s.Run(tc.method+" "+tc.path+" as "+tc.role, func() {
+				// when
+				err := checkDenyRules(newContext(tc.method, tc.path, tc.role))
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestCheckRoleVerbs() {
+	// given
+	newContext := func(method, path, role string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+		ctx.Set(context.WorkspaceKey, "smith-dev")
+		if role != "" {
+			ctx.Set(context.WorkspaceRoleKey, role)
+		}
+		return ctx
+	}
+
+	s.Run("no limited role", func() {
+		// when
+		err := checkRoleVerbs(newContext(http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "viewer"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROLE_ALLOWED_VERBS", "viewer=get|list|watch")
+
+	s.Run("denied", func() {
+		for _, tc := range []struct {
+			method string
+			path   string
+			detail string
+		}{
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "'delete pods'"},
+			{http.MethodPost, "/api/v1/namespaces/smith-dev/pods/app/exec", "'create pods/exec'"},
+			{http.MethodPatch, "/apis/apps/v1/namespaces/smith-dev/deployments/app", "'patch deployments'"},
+			{http.MethodPost, "/apis/authorization.k8s.io/v1/subjectaccessreviews", "'create subjectaccessreviews'"},
+			{http.MethodPost, "/openapi/v3", "'create /openapi/v3'"},
+		} {
+			s.Run(tc.method+" "+tc.path, func() {
+				// when
+				err := checkRoleVerbs(newContext(tc.method, tc.path, "viewer"))
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+				assert.Equal(s.T(), "request denied by role", crtErr.Message)
+				assert.Equal(s.T(), "the 'viewer' role in the workspace 'smith-dev' only allows the 'get|list|watch' verbs, not "+tc.detail, crtErr.Details)
+			})
+		}
+	})
+
+	s.Run("allowed", func() {
+		for _, tc := range []struct {
+			method string
+			path   string
+			role   string
+		}{
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods", "viewer"},
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods/app", "viewer"},
+			{http.MethodGet, "/api/v1/namespaces/smith-dev/pods?watch=true", "viewer"},
+			{http.MethodHead, "/apis/apps/v1", "viewer"},
+			{http.MethodPost, "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews", "viewer"},
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", "admin"},
+			{http.MethodDelete, "/api/v1/namespaces/smith-dev/pods/app", ""},
+		} {
+			s.Run(tc.method+" "+tc.path+" as "+tc.role, func() {
+				// when
+				err := checkRoleVerbs(newContext(tc.method, tc.path, tc.role))
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestCheckDeniedPaths() {
+	// given
+	newContext := func(path string) echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = path
+		return echo.New().NewContext(req, httptest.NewRecorder())
+	}
+
+	s.Run("no denied path", func() {
+		// when
+		err := checkDeniedPaths(newContext("/metrics"))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_DENIED_PATHS", "/metrics,/version,/debug/*")
+
+	s.Run("denied", func() {
+		for _, path := range []string{"/metrics", "/version", "/debug/pprof/heap", "/api/../metrics", "//metrics", "metrics"} {
+			s.Run(path, func() {
+				// when
+				err := checkDeniedPaths(newContext(path))
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+				assert.Equal(s.T(), "request denied by policy", crtErr.Message)
+			})
+		}
+	})
+
+	s.Run("allowed", func() {
+		for _, path := range []string{"/api/v1/namespaces/smith-dev/pods", "/metrics/cadvisor", "/debug", "/versions", "/apis/metrics.k8s.io/v1beta1"} {
+			s.Run(path, func() {
+				// when
+				err := checkDeniedPaths(newContext(path))
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestCheckWorkspaceNamespace() {
+	// given
+	newContext := func(path string, namespaces []string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, path, nil), httptest.NewRecorder())
+		ctx.Set(context.WorkspaceKey, "smith")
+		if namespaces != nil {
+			ctx.Set(context.WorkspaceNamespacesKey, namespaces)
+		}
+		return ctx
+	}
+	namespaces := []string{"smith-dev", "smith-stage"}
+
+	s.Run("disabled", func() {
+		// when
+		err := checkWorkspaceNamespace(newContext("/api/v1/namespaces/alice-dev/pods", namespaces))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_STRICT_NAMESPACE_VALIDATION_ENABLED", "true")
+
+	s.Run("denied", func() {
+		for _, path := range []string{
+			"/api/v1/namespaces/alice-dev/pods",
+			"/apis/apps/v1/namespaces/alice-dev/deployments/app/scale",
+			"/api/v1/watch/namespaces/alice-dev/pods",
+			"/api/v1/namespaces/alice-dev",
+		} {
+			s.Run(path, func() {
+				// when
+				err := checkWorkspaceNamespace(newContext(path, namespaces))
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+				assert.Equal(s.T(), "invalid namespace request", crtErr.Message)
+				assert.Equal(s.T(), "the namespace 'alice-dev' doesn't belong to the workspace 'smith'", crtErr.Details)
+			})
+		}
+	})
+
+	s.Run("allowed", func() {
+		for _, path := range []string{
+			"/api/v1/namespaces/smith-dev/pods",
+			"/apis/apps/v1/namespaces/smith-stage/deployments/app",
+			"/api/v1/namespaces/smith-dev",
+			"/api/v1/namespaces",
+			"/apis/rbac.authorization.k8s.io/v1/clusterroles",
+			"/apis/apps/v1",
+		} {
+			s.Run(path, func() {
+				// when
+				err := checkWorkspaceNamespace(newContext(path, namespaces))
+
+				// then
+				require.NoError(s.T(), err)
+			})
+		}
+	})
+
+	s.Run("workspace not resolved", func() {
+		// when
+		err := checkWorkspaceNamespace(newContext("/api/v1/namespaces/alice-dev/pods", nil))
+
+		// then
+		require.NoError(s.T(), err)
+	})
+}
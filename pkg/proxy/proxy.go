@@ -12,7 +12,11 @@ import (
 	"net/http/httputil"
 	"net/textproto"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 
@@ -46,21 +50,33 @@ const (
 	bearerProtocolPrefix = "base64url.bearer.authorization.k8s.io." //nolint:gosec
 
 	proxyHealthEndpoint          = "/proxyhealth"
+	proxyReadyEndpoint           = "/proxyready"
 	authEndpoint                 = "/auth/"
 	wellKnownOauthConfigEndpoint = "/.well-known/oauth-authorization-server"
 	pluginsEndpoint              = "/plugins/"
 )
 
-func ssoWellKnownTarget() string {
-	return fmt.Sprintf("%s/auth/realms/%s/.well-known/openid-configuration", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), configuration.GetRegistrationServiceConfig().Auth().SSORealm())
+func ssoWellKnownTarget(realm configuration.SSORealm) string {
+	return fmt.Sprintf("%s/auth/realms/%s/.well-known/openid-configuration", realm.SSOBaseURL, realm.Name)
 }
 
-func openidAuthEndpoint() string {
-	return fmt.Sprintf("/auth/realms/%s/protocol/openid-connect/auth", configuration.GetRegistrationServiceConfig().Auth().SSORealm())
+func openidAuthEndpoint(realm string) string {
+	return fmt.Sprintf("/auth/realms/%s/protocol/openid-connect/auth", realm)
 }
 
-func authorizationEndpointTarget() string {
-	return fmt.Sprintf("%s%s", configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL(), openidAuthEndpoint())
+func authorizationEndpointTarget(realm configuration.SSORealm) string {
+	return fmt.Sprintf("%s%s", realm.SSOBaseURL, openidAuthEndpoint(realm.Name))
+}
+
+// ssoRealm returns the realm of SSO the given request to SSO is for: the realm in the path of the request, if any, eg. for
+// '/auth/realms/<realm>/protocol/openid-connect/token', or else the realm of the host of the request, eg. for '/auth/js/keycloak.js'
+func ssoRealm(req *http.Request) configuration.SSORealm {
+	cfg := configuration.GetRegistrationServiceConfig().Auth()
+	if name, found := strings.CutPrefix(req.URL.Path, "/auth/realms/"); found {
+		name, _, _ = strings.Cut(name, "/")
+		return cfg.RealmByName(name)
+	}
+	return cfg.RealmByHost(req.Host)
 }
 
 type Proxy struct {
@@ -70,9 +86,64 @@ type Proxy struct {
 	spaceLister    *handlers.SpaceLister
 	metrics        *metrics.ProxyMetrics
 	getMembersFunc commoncluster.GetMemberClustersFunc
+	// memberRegistry holds the endpoints of the member clusters registered at runtime, which are returned by getMembersFunc
+	memberRegistry  *MemberRegistry
+	pluginEndpoints *PluginEndpoints
+	// impersonatorTokens are the short-lived tokens used to impersonate the users in the member clusters
+	impersonatorTokens *ImpersonatorTokens
+	trustedProxies     []*net.IPNet
+	// accessLogger is nil when the access log is disabled
+	accessLogger        *AccessLogger
+	upgradedConnections *UpgradedConnections
+	// sharedTransports are the transports shared by the requests of the users with the CanarySharedTransport flag
+	sharedTransports sync.Map
+	// copyBufferPools are the pools of the buffers used to copy the data between the clients and the upstreams, by size
+	copyBufferPools sync.Map
+	// ready is set once the proxy is ready to serve requests (see Ready)
+	ready atomic.Bool
+	// tokenCache caches the claims of the validated tokens
+	tokenCache *TokenCache
+	// ssoCache caches the responses of SSO which don't depend on the user
+	ssoCache *SSOCache
+	// sessions are the sessions of the browsers logged in through the login endpoint
+	sessions *Sessions
+	// workspaceQuotas enforces the quotas of requests to the workspaces
+	workspaceQuotas *WorkspaceQuotas
+	// inFlightRequests limits the number of requests forwarded concurrently to each member cluster
+	inFlightRequests *InFlightRequests
+	// loadShedder sheds the lowest-priority requests when the proxy is overloaded
+	loadShedder *LoadShedder
+	// clusterConfigRefresher is nil when the configs of the member clusters are not refreshed when they reject the token
+	clusterConfigRefresher *ClusterConfigRefresher
+	// sharedCache is nil when the caches are not shared with the other replicas of the proxy
+	sharedCache *SharedCache
+	// recentBans are the users banned with BanUser
+	recentBans bans
+	// captures holds the capture of the requests set at runtime with the admin endpoint
+	captures captures
+	// authorizationWebhook asks the external policy service, if any, whether the requests can be forwarded
+	authorizationWebhook *AuthorizationWebhook
 }
 
-func NewProxy(nsClient namespaced.Client, app application.Application, proxyMetrics *metrics.ProxyMetrics, getMembersFunc commoncluster.GetMemberClustersFunc) (*Proxy, error) {
+// ProxyOption the options of the Proxy
+type ProxyOption func(*Proxy) // nolint:revive
+
+// WithClusterConfigRefresher sets the refresher of the configs of the member clusters, used when a member cluster
+// rejects the token of the service account used to impersonate the users
+func WithClusterConfigRefresher(refresher *ClusterConfigRefresher) ProxyOption {
+	return func(p *Proxy) {
+		p.clusterConfigRefresher = refresher
+	}
+}
+
+// WithSharedCache sets the cache shared with the other replicas of the proxy (see SyncSharedCache)
+func WithSharedCache(sharedCache *SharedCache) ProxyOption {
+	return func(p *Proxy) {
+		p.sharedCache = sharedCache
+	}
+}
+
+func NewProxy(nsClient namespaced.Client, app application.Application, proxyMetrics *metrics.ProxyMetrics, getMembersFunc commoncluster.GetMemberClustersFunc, opts ...ProxyOption) (*Proxy, error) {
 	tokenParser, err := auth.DefaultTokenParser()
 	if err != nil {
 		return nil, err
@@ -80,31 +151,131 @@ func NewProxy(nsClient namespaced.Client, app application.Application, proxyMetr
 
 	// init handlers
 	spaceLister := handlers.NewSpaceLister(nsClient, app, proxyMetrics)
-	return &Proxy{
-		Client:         nsClient,
-		signupService:  app.SignupService(),
-		tokenParser:    tokenParser,
-		spaceLister:    spaceLister,
-		metrics:        proxyMetrics,
-		getMembersFunc: getMembersFunc,
-	}, nil
+	logUnknownCanaryFlags()
+	var accessLogger *AccessLogger
+	if cfg := configuration.GetRegistrationServiceConfig().Proxy().AccessLog(); cfg.Enabled() {
+		accessLogger = NewAccessLogger(os.Stdout, cfg)
+	}
+	memberRegistry := NewMemberRegistry(getMembersFunc)
+	p := &Proxy{
+		Client:               nsClient,
+		signupService:        app.SignupService(),
+		tokenParser:          tokenParser,
+		spaceLister:          spaceLister,
+		metrics:              proxyMetrics,
+		getMembersFunc:       memberRegistry.GetMembers,
+		memberRegistry:       memberRegistry,
+		pluginEndpoints:      NewPluginEndpoints(),
+		impersonatorTokens:   NewImpersonatorTokens(),
+		trustedProxies:       configuration.GetRegistrationServiceConfig().Proxy().TrustedProxies(),
+		accessLogger:         accessLogger,
+		upgradedConnections:  NewUpgradedConnections(proxyMetrics.RegServProxyUpgradedConnectionsGauge),
+		tokenCache:           NewTokenCache(),
+		ssoCache:             NewSSOCache(),
+		sessions:             NewSessions(),
+		workspaceQuotas:      NewWorkspaceQuotas(proxyMetrics.RegServProxyWorkspaceQuotaRejectedCounter),
+		inFlightRequests:     NewInFlightRequests(proxyMetrics.RegServProxyInFlightRejectedCounterVec),
+		loadShedder:          NewLoadShedder(proxyMetrics.RegServProxyShedCounterVec),
+		authorizationWebhook: NewAuthorizationWebhook(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
 }
 
+// WarmUpCaches primes the routing caches of the proxy which would otherwise be populated lazily by the first requests.
+// The Spaces and SpaceBindings don't need to be primed here since they are served by the informer cache of the client,
+// which is fully synced before the proxy is created.
+func (p *Proxy) WarmUpCaches(ctx gocontext.Context) error {
+	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc, WithPluginEndpoints(p.pluginEndpoints))
+	return members.WarmUpPluginEndpoints(ctx)
+}
+
+// StartProxy starts the proxy server listening on the given port of all the interfaces
 func (p *Proxy) StartProxy(port string) *http.Server {
-	// start server
+	srv := p.newServer()
+	srv.Addr = fmt.Sprintf(":%s", port)
+	log.Info(nil, "Starting the Proxy server...")
+	// listen concurrently to allow for graceful shutdown
+	go func() {
+		logServerError(srv.ListenAndServe())
+	}()
+	return srv
+}
+
+// StartProxyListeners starts the proxy server listening on all the given addresses at once (see ListenAddresses for
+// the supported formats). An error is returned if any of the addresses can't be listened on, if the TLS certificate of
+// the proxy can't be loaded (see the TLSCertFile setting), or if the PROXY protocol is enabled without any trusted proxy.
+// Shutting down the returned server closes all the listeners.
+func (p *Proxy) StartProxyListeners(addresses ...string) (*http.Server, error) {
+	proxyProtocol := configuration.GetRegistrationServiceConfig().Proxy().ProxyProtocolEnabled()
+	if proxyProtocol && len(p.trustedProxies) == 0 {
+		return nil, errs.New("the PROXY protocol is enabled without any trusted proxy to read its headers from")
+	}
+	srv := p.newServer()
+	tlsConfig, err := serverTLSConfig(srv.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	listeners := make([]net.Listener, 0, len(addresses))
+	for _, address := range addresses {
+		l, err := listen(address)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, errs.Wrapf(err, "unable to listen on '%s'", address)
+		}
+		if proxyProtocol {
+			l = newProxyProtocolListener(l, p.trustedProxies)
+		}
+		if tlsConfig != nil {
+			l = tls.NewListener(l, tlsConfig)
+		}
+		listeners = append(listeners, l)
+	}
+	for _, l := range listeners {
+		log.Infof(nil, "Starting the Proxy server on %s...", l.Addr().String())
+		// listen concurrently to allow for graceful shutdown
+		go func() {
+			logServerError(srv.Serve(l))
+		}()
+	}
+	return srv, nil
+}
+
+func logServerError(err error) {
+	if err == nil {
+		return
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		log.Info(nil, fmt.Sprintf("%s - this is expected when server shutdown has been initiated", err.Error()))
+	} else {
+		log.Error(nil, err, err.Error())
+	}
+}
+
+// newServer returns the proxy server, without any address to listen on
+func (p *Proxy) newServer() *http.Server {
 	router := echo.New()
 	router.Logger.SetLevel(glog.INFO)
 	router.HTTPErrorHandler = customHTTPErrorHandler
+	// do not trust the X-Forwarded-For header of the clients which are not trusted proxies when looking up the client IP
+	router.IPExtractor = ipExtractor(p.trustedProxies)
 	// middleware before routing
 	router.Pre(
 		p.addStartTime(),
+		p.addSecurityHeaders(),
+		p.accessLog(),
+		p.shedLoad(), // before the token of the user is validated
 		middleware.RemoveTrailingSlash(),
 		p.stripInvalidHeaders(),
 		p.addUserContext(), // get user information from token before handling request
 		// log request information before routing
 		func(next echo.HandlerFunc) echo.HandlerFunc {
 			return func(ctx echo.Context) error {
-				if ctx.Request().URL.Path == proxyHealthEndpoint { // skip for health endpoint
+				if probeEndpoint(ctx.Request().URL.Path) { // skip for health and readiness endpoints
 					return next(ctx)
 				}
 				log.InfoEchof(ctx, "request received")
@@ -112,6 +283,7 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 			}
 		},
 		p.ensureUserIsNotBanned(),
+		p.impersonateUser(), // after the ban of the admin is checked
 		p.addPublicViewerContext(),
 	)
 
@@ -119,7 +291,7 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 	router.Use(
 		middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 			Skipper: func(ctx echo.Context) bool {
-				return ctx.Request().URL.RequestURI() == proxyHealthEndpoint // skip logging for health check, so it doesn't pollute the logs
+				return probeEndpoint(ctx.Request().URL.RequestURI()) // skip logging for health and readiness checks, so they don't pollute the logs
 			},
 			LogMethod: true,
 			LogStatus: true,
@@ -137,30 +309,49 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 	wg.GET("/:workspace", handlers.HandleSpaceGetRequest(p.spaceLister, p.getMembersFunc))
 	wg.GET("", handlers.HandleSpaceListRequest(p.spaceLister))
 
-	router.GET(proxyHealthEndpoint, p.health)
+	router.GET(proxyHealthEndpoint, func(ctx echo.Context) error {
+		if configuration.GetRegistrationServiceConfig().Proxy().DedicatedAdminPort() {
+			// the health endpoint is only served on the admin port
+			return crterrors.NewNotFoundError(errors.New("health endpoint not found"), "the health endpoint is served on the admin port")
+		}
+		return health(ctx)
+	})
+	router.GET(proxyReadyEndpoint, func(ctx echo.Context) error {
+		if configuration.GetRegistrationServiceConfig().Proxy().DedicatedAdminPort() {
+			// the readiness endpoint is only served on the admin port
+			return crterrors.NewNotFoundError(errors.New("readiness endpoint not found"), "the readiness endpoint is served on the admin port")
+		}
+		return p.Ready(ctx)
+	})
+	// Dry-run route. Returns where a request would be forwarded, without forwarding it.
+	router.GET(proxyRouteEndpoint, p.proxyRoute)
+	// Route of the not-before policies pushed by SSO, which are signed with the keys of the realms
+	router.POST(pushNotBeforeEndpoint, p.pushNotBefore)
 	// SSO routes. Used by web login (oc login -w).
 	// Here is the expected flow for the "oc login -w" command:
 	// 1. "oc login -w --server=<proxy_url>"
 	// 2. oc calls <proxy_url>/.well-known/oauth-authorization-server (wellKnownOauthConfigEndpoint endpoint)
-	// 3. proxy forwards it to <sso_url>/auth/realms/<sso_realm>/.well-known/openid-configuration
+	// 3. proxy forwards it to <sso_url>/auth/realms/<sso_realm>/.well-known/openid-configuration, for the realm of the <proxy_url> host
 	// 4. oc starts an OAuth flow by opening a browser for <proxy_url>/auth/realms/<realm>/protocol/openid-connect/auth
 	// 5. proxy redirects (the request is not proxied but redirected via 403 See Others response!) the request
 	//    to <sso_url>/auth/realms/<realm>/protocol/openid-connect/auth
 	//    Note: oc uses this hardcoded public (no secret) oauth client name: "openshift-cli-client" which has to exist in SSO to make this flow work.
 	// 6. user provides the login credentials in the sso login page
 	// 7. all following oc requests (<proxy_url>/auth/*) go to the proxy and forwarded to SSO as is. This is used to obtain the generated token by oc.
-	router.Any(wellKnownOauthConfigEndpoint, p.oauthConfiguration)     // <- this is the step 2 in the flow above
-	router.Any(fmt.Sprintf("%s*", openidAuthEndpoint()), p.openidAuth) // <- this is the step 5 in the flow above
-	router.Any(fmt.Sprintf("%s*", authEndpoint), p.auth)               // <- this is the step 7.
+	router.Any(wellKnownOauthConfigEndpoint, p.oauthConfiguration)             // <- this is the step 2 in the flow above
+	router.Any(fmt.Sprintf("%s*", openidAuthEndpoint(":realm")), p.openidAuth) // <- this is the step 5 in the flow above
+	router.Any(fmt.Sprintf("%s*", authEndpoint), p.auth)                       // <- this is the step 7.
+	// Browser login routes. The token of the user is kept server-side, in a session identified by an HttpOnly cookie.
+	router.GET(loginEndpoint, p.login)
+	router.GET(loginCallbackEndpoint, p.loginCallback)
+	router.Any(logoutEndpoint, p.logout) // only POST is allowed, the other methods are rejected rather than proxied
 	// The main proxy route
 	router.Any("/*", p.handleRequestAndRedirect)
 
 	// Insert the CORS preflight middleware
 	handler := corsPreflightHandler(router)
 
-	log.Info(nil, "Starting the Proxy server...")
-	srv := &http.Server{
-		Addr:              fmt.Sprintf(":%s", port),
+	return &http.Server{
 		Handler:           handler,
 		ReadHeaderTimeout: 2 * time.Second,
 		TLSConfig: &tls.Config{
@@ -168,30 +359,25 @@ func (p *Proxy) StartProxy(port string) *http.Server {
 			NextProtos: []string{"http/1.1"}, // disable HTTP/2 for now
 		},
 	}
-	// listen concurrently to allow for graceful shutdown
-	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			if errors.Is(err, http.ErrServerClosed) {
-				log.Info(nil, fmt.Sprintf("%s - this is expected when server shutdown has been initiated", err.Error()))
-			} else {
-				log.Error(nil, err, err.Error())
-			}
-		}
-	}()
+}
 
-	return srv
+// probeEndpoint returns true if the given path is the one of the health or the readiness endpoint
+func probeEndpoint(path string) bool {
+	return path == proxyHealthEndpoint || path == proxyReadyEndpoint
 }
 
 // unsecured returns true if the request does not require authentication
 func unsecured(ctx echo.Context) bool {
 	uri := ctx.Request().URL.RequestURI()
-	return uri == proxyHealthEndpoint || uri == wellKnownOauthConfigEndpoint || strings.HasPrefix(uri, authEndpoint)
+	path := ctx.Request().URL.Path
+	return probeEndpoint(uri) || uri == wellKnownOauthConfigEndpoint || strings.HasPrefix(uri, authEndpoint) ||
+		path == loginEndpoint || path == loginCallbackEndpoint || path == logoutEndpoint || path == pushNotBeforeEndpoint
 }
 
 // auth handles requests to SSO. Used by web login.
 func (p *Proxy) auth(ctx echo.Context) error {
 	req := ctx.Request()
-	targetURL, err := url.Parse(configuration.GetRegistrationServiceConfig().Auth().SSOBaseURL())
+	targetURL, err := url.Parse(ssoRealm(req).SSOBaseURL)
 	if err != nil {
 		return err
 	}
@@ -203,7 +389,7 @@ func (p *Proxy) auth(ctx echo.Context) error {
 
 // oauthConfiguration handles requests to oauth configuration and proxies them to the corresponding SSO endpoint. Used by web login.
 func (p *Proxy) oauthConfiguration(ctx echo.Context) error {
-	targetURL, err := url.Parse(ssoWellKnownTarget())
+	targetURL, err := url.Parse(ssoWellKnownTarget(configuration.GetRegistrationServiceConfig().Auth().RealmByHost(ctx.Request().Host)))
 	if err != nil {
 		return err
 	}
@@ -212,7 +398,7 @@ func (p *Proxy) oauthConfiguration(ctx echo.Context) error {
 
 // openidAuth handles requests to the openID Connect authentication endpoint. Used by web login.
 func (p *Proxy) openidAuth(ctx echo.Context) error {
-	targetURL, err := url.Parse(authorizationEndpointTarget())
+	targetURL, err := url.Parse(authorizationEndpointTarget(configuration.GetRegistrationServiceConfig().Auth().RealmByName(ctx.Param("realm"))))
 	if err != nil {
 		return err
 	}
@@ -231,9 +417,15 @@ func (p *Proxy) redirectTo(ctx echo.Context, to string) error {
 }
 
 // handleSSORequest handles requests to the cluster authentication server and proxy them to SSO instead. Used by web login.
+// The responses to the requests of the SSOCachedPaths are served from the SSOCache when possible.
 func (p *Proxy) handleSSORequest(targetURL *url.URL) echo.HandlerFunc {
 	return func(ctx echo.Context) error {
 		req := ctx.Request()
+		cacheable := ssoCacheable(req)
+		if cacheable && p.ssoCache.serve(ctx.Response(), targetURL.String(), time.Now(), false) {
+			log.InfoEchof(ctx, "serving the cached response of %s", targetURL.String())
+			return nil
+		}
 		director := func(req *http.Request) {
 			origin := req.URL.String()
 			req.URL.Scheme = targetURL.Scheme
@@ -243,20 +435,25 @@ func (p *Proxy) handleSSORequest(targetURL *url.URL) echo.HandlerFunc {
 			req.Host = targetURL.Host
 			log.InfoEchof(ctx, "forwarding %s to %s", origin, req.URL.String())
 		}
-		transport := getTransport(req.Header)
+		transport := getTransport(req.Header, nil)
 		reverseProxy := &httputil.ReverseProxy{
 			Director:      director,
 			Transport:     transport,
 			FlushInterval: -1,
 		}
+		if cacheable {
+			reverseProxy.ModifyResponse = p.ssoCache.modifyResponse(targetURL.String())
+			reverseProxy.ErrorHandler = p.ssoCache.errorHandler(targetURL.String())
+		}
 
 		// Note that ServeHttp is non-blocking and uses a go routine under the hood
-		reverseProxy.ServeHTTP(ctx.Response().Writer, ctx.Request())
+		// The response of echo is used (rather than the underlying writer), so that the status and size of the response are recorded
+		reverseProxy.ServeHTTP(ctx.Response(), ctx.Request())
 		return nil
 	}
 }
 
-func (p *Proxy) health(ctx echo.Context) error {
+func health(ctx echo.Context) error {
 	ctx.Response().Writer.Header().Set("Content-Type", "application/json")
 	ctx.Response().Writer.WriteHeader(http.StatusOK)
 	_, err := io.WriteString(ctx.Response().Writer, `{"alive": true}`)
@@ -266,10 +463,11 @@ func (p *Proxy) health(ctx echo.Context) error {
 func (p *Proxy) processRequest(ctx echo.Context) (string, *access.ClusterAccess, error) {
 	// retrieve required information from the HTTP request
 	username, _ := ctx.Get(context.UsernameKey).(string)
-	proxyPluginName, workspaceName, err := getWorkspaceContext(ctx.Request())
+	wc, err := getWorkspaceContext(ctx.Request())
 	if err != nil {
 		return "", nil, crterrors.NewBadRequest("unable to get workspace context", err.Error())
 	}
+	proxyPluginName, workspaceName := wc.proxyPluginName, wc.workspace
 
 	// set workspace context for logging
 	ctx.Set(context.WorkspaceKey, workspaceName)
@@ -296,7 +494,7 @@ func (p *Proxy) processRequest(ctx echo.Context) (string, *access.ClusterAccess,
 // processHomeWorkspaceRequest process an HTTP Request targeting the user's home workspace.
 func (p *Proxy) processHomeWorkspaceRequest(ctx echo.Context, username, proxyPluginName string) (*access.ClusterAccess, error) {
 	// retrieves the ClusterAccess for the user and their home workspace
-	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc)
+	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc, WithPluginEndpoints(p.pluginEndpoints), WithImpersonatorTokens(p.impersonatorTokens))
 	cluster, err := members.GetClusterAccess(username, "", proxyPluginName, false)
 	if err != nil {
 		return nil, crterrors.NewInternalError(errs.New("unable to get target cluster"), err.Error())
@@ -312,6 +510,14 @@ func (p *Proxy) processHomeWorkspaceRequest(ctx echo.Context, username, proxyPlu
 	if err := validateWorkspaceRequest("", workspaces...); err != nil {
 		return nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
 	}
+	for _, w := range workspaces {
+		if w.Status.Type == "home" {
+			ctx.Set(context.HomeWorkspaceKey, w.Name)
+			ctx.Set(context.WorkspaceRoleKey, w.Status.Role)
+			ctx.Set(context.WorkspaceNamespacesKey, workspaceNamespaces(&w))
+			break
+		}
+	}
 
 	// return the cluster access
 	return cluster, nil
@@ -335,6 +541,9 @@ func (p *Proxy) processWorkspaceRequest(ctx echo.Context, username, workspaceNam
 	if err := validateWorkspaceRequest(workspaceName, *workspace); err != nil {
 		return nil, crterrors.NewForbiddenError("invalid workspace request", err.Error())
 	}
+	// the role is the one of the user, or the one of the public viewer if the user has no direct access to the workspace
+	ctx.Set(context.WorkspaceRoleKey, workspace.Status.Role)
+	ctx.Set(context.WorkspaceNamespacesKey, workspaceNamespaces(workspace))
 
 	// retrieve the ClusterAccess for the user and the target workspace
 	return p.getClusterAccess(ctx, username, proxyPluginName, workspace)
@@ -403,24 +612,27 @@ func (p *Proxy) getClusterAccess(ctx echo.Context, username, proxyPluginName str
 	return cluster, nil
 }
 
-// getClusterAccessAsUserOrPublicViewer if the requesting user exists and has direct access to the workspace,
+// getClusterAccessAsUserOrPublicViewer if the requesting user exists and has direct or group access to the workspace,
 // this function returns the ClusterAccess impersonating the requesting user.
 // If PublicViewer support is enabled and PublicViewer user has access to the workspace,
 // this function returns the ClusterAccess impersonating the PublicViewer user.
 // If requesting user does not exists and PublicViewer is disabled or does not have access to the workspace,
 // this function returns an error.
 func (p *Proxy) getClusterAccessAsUserOrPublicViewer(ctx echo.Context, username, proxyPluginName string, workspace *toolchainv1alpha1.Workspace) (*access.ClusterAccess, error) {
-	// retrieve the requesting user's UserSignup
-	userSignup, err := p.signupService.GetSignup(nil, username, false)
-	if err != nil {
-		log.Error(nil, err, fmt.Sprintf("error retrieving user signup for username '%s'", username))
-		return nil, crterrors.NewInternalError(errs.New("unable to get user info"), "error retrieving user")
+	// retrieve the requesting user's UserSignup, unless the request is anonymous
+	var userSignup *signup.Signup
+	if !context.IsAnonymous(ctx) {
+		var err error
+		if userSignup, err = p.signupService.GetSignup(nil, username, false); err != nil {
+			log.Error(nil, err, fmt.Sprintf("error retrieving user signup for username '%s'", username))
+			return nil, crterrors.NewInternalError(errs.New("unable to get user info"), "error retrieving user")
+		}
 	}
 
 	// proceed as PublicViewer if the feature is enabled and userSignup is nil
 	publicViewerEnabled := context.IsPublicViewerEnabled(ctx)
-	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc)
-	if publicViewerEnabled && !userHasDirectAccess(userSignup, workspace) {
+	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc, WithPluginEndpoints(p.pluginEndpoints), WithImpersonatorTokens(p.impersonatorTokens))
+	if publicViewerEnabled && !userHasDirectAccess(userSignup, workspace) && !userHasGroupAccess(ctx, userSignup, workspace) {
 		return members.GetClusterAccess(
 			toolchainv1alpha1.KubesawAuthenticatedUsername,
 			workspace.Name,
@@ -442,6 +654,15 @@ func userHasDirectAccess(signup *signup.Signup, workspace *toolchainv1alpha1.Wor
 	return userHasBinding(signup.CompliantUsername, workspace)
 }
 
+// userHasGroupAccess checks if the groups of an UserSignup have access to a workspace (see the GroupRoles setting).
+func userHasGroupAccess(ctx echo.Context, signup *signup.Signup, workspace *toolchainv1alpha1.Workspace) bool {
+	if signup == nil {
+		return false
+	}
+
+	return handlers.GroupSpaceBinding(ctx, signup.CompliantUsername, workspace.Name) != nil
+}
+
 func userHasBinding(username string, workspace *toolchainv1alpha1.Workspace) bool {
 	for _, b := range workspace.Status.Bindings {
 		if b.MasterUserRecord == username {
@@ -470,65 +691,205 @@ func (p *Proxy) getUserWorkspaceWithBindings(ctx echo.Context, workspaceName str
 
 func (p *Proxy) handleRequestAndRedirect(ctx echo.Context) error {
 	requestReceivedTime := ctx.Get(context.RequestReceivedTime).(time.Time)
+	username, _ := ctx.Get(context.UsernameKey).(string)
+	path := ctx.Request().URL.Path // before the plugin and workspace prefixes are removed
+	if httpstream.IsUpgradeRequest(ctx.Request()) {
+		// the connection is counted until ServeHTTP returns, ie. until the upgraded connection is closed
+		limit := configuration.GetRegistrationServiceConfig().Proxy().MaxUpgradedConnectionsPerUser()
+		if !p.upgradedConnections.Acquire(username, limit) {
+			log.InfoEchof(ctx, "rejecting the upgraded connection: the limit of %s connections per user is reached", strconv.Itoa(limit))
+			return crterrors.NewTooManyRequestsError("too many connections", fmt.Sprintf("the maximum number of %d concurrent upgraded connections per user is reached", limit))
+		}
+		defer p.upgradedConnections.Release(username)
+	}
 	proxyPluginName, cluster, err := p.processRequest(ctx)
+	if p.capturing(username, requestReceivedTime) {
+		captureRequest(ctx, requestReceivedTime, path, proxyPluginName, cluster, err)
+	}
+	if err != nil {
+		p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusNotAcceptable), metrics.MetricLabelRejected, routeType(ctx, path)).Observe(time.Since(requestReceivedTime).Seconds())
+		return err
+	}
+	if err := p.checkWorkspaceQuota(ctx); err != nil {
+		return err
+	}
+	if err := checkPersonalAccessTokenScopes(ctx, proxyPluginName); err != nil {
+		return err
+	}
+	if proxyPluginName == "" {
+		if err := checkRoleVerbs(ctx); err != nil {
+			return err
+		}
+		if err := checkDenyRules(ctx); err != nil {
+			return err
+		}
+		if err := checkDeniedPaths(ctx); err != nil {
+			return err
+		}
+		if err := checkWorkspaceNamespace(ctx); err != nil {
+			return err
+		}
+	}
+	if err := p.checkAuthorizationWebhook(ctx, proxyPluginName); err != nil {
+		return err
+	}
+	if cluster, err = p.routingOverride(ctx, cluster, proxyPluginName); err != nil {
+		return err
+	}
+	if cluster, err = e2eUpstreamOverride(ctx.Request(), cluster); err != nil {
+		return err
+	}
+	if proxyPluginName == "" {
+		if status := p.reviewAccess(ctx, cluster); status != nil {
+			return ctx.JSON(http.StatusForbidden, status)
+		}
+	}
+	// set the target cluster in context for the access log
+	ctx.Set(context.TargetClusterKey, cluster.ClusterName())
+	release, err := p.acquireInFlightRequest(ctx, cluster)
 	if err != nil {
-		p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusNotAcceptable), metrics.MetricLabelRejected).Observe(time.Since(requestReceivedTime).Seconds())
 		return err
 	}
-	reverseProxy := p.newReverseProxy(ctx, cluster, len(proxyPluginName) > 0)
+	defer release()
+	if isWatchRequest(ctx.Request()) {
+		defer p.startWatch(ctx, cluster, len(proxyPluginName) > 0)()
+	}
+	accounting := &upstreamAccounting{}
+	canary := evaluateCanaryFlags(username)
+	shadow := p.newShadowRequest(ctx, cluster, len(proxyPluginName) > 0)
+	reverseProxy := p.newReverseProxy(ctx, cluster, len(proxyPluginName) > 0, accounting, canary)
 	routeTime := time.Since(requestReceivedTime)
-	p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusAccepted), cluster.APIURL().Host).Observe(routeTime.Seconds())
+	p.metrics.RegServProxyAPIHistogramVec.WithLabelValues(fmt.Sprintf("%d", http.StatusAccepted), cluster.APIURL().Host, routeType(ctx, path)).Observe(routeTime.Seconds())
 	// Note that ServeHttp is non-blocking and uses a go routine under the hood
-	reverseProxy.ServeHTTP(ctx.Response().Writer, ctx.Request())
+	// The response of echo is used (rather than the underlying writer), so that the status and size of the response are recorded
+	reverseProxy.ServeHTTP(ctx.Response(), ctx.Request())
+	accounting.setTrailers(ctx.Response())
+	canary.observe(p.metrics, ctx.Response().Status)
+	if shadow != nil {
+		shadow.send(p.metrics, ctx.Response().Status)
+	}
 	return nil
 }
 
-func getWorkspaceContext(req *http.Request) (string, string, error) {
-	path := req.URL.Path
-	proxyPluginName := ""
-	// first string off any preceding proxy plugin url segment
-	if strings.HasPrefix(path, pluginsEndpoint) {
-		segments := strings.Split(path, "/")
-		// NOTE: a split on "/plugins/" results in an array with 2 items.  One is the empty string, two is "plugins", 3 is empty string
-		// behavior is not unique to "/";  "," and ",plugins," works the same way
-		if len(segments) < 3 {
-			return "", "", fmt.Errorf("path %q not a proxied route request", path)
-		}
-		if len(segments) == 3 {
-			// need to distinguish between the third entry being "" vs "<plugin-name>"
-			if len(strings.TrimSpace(segments[2])) == 0 {
-				return "", "", fmt.Errorf("path %q not a proxied route request", path)
-			}
+// WorkspaceHeader is the header of the requests targeting a workspace, as an alternative to the '/workspaces/<name>' prefix
+// of the path, so that the path of the requests is the same as the one of the Kubernetes API. The header is never forwarded.
+const WorkspaceHeader = "X-Workspace"
+
+// routeType returns the type of the route of the request with the given path (before the plugin and workspace prefixes are removed):
+// a request to a proxy plugin, to an explicit workspace (with the prefix of the path, the subdomain of the host or the WorkspaceHeader),
+// or to the home workspace of the user
+func routeType(ctx echo.Context, path string) string {
+	workspace, _ := ctx.Get(context.WorkspaceKey).(string)
+	switch {
+	case strings.HasPrefix(path, pluginsEndpoint):
+		return metrics.MetricLabelRoutePlugin
+	case workspace != "" || strings.HasPrefix(path, "/workspaces/"):
+		return metrics.MetricLabelRouteWorkspace
+	default:
+		return metrics.MetricLabelRouteImplicit
+	}
+}
+
+// workspaceContext is the context of a request parsed from its path, its host and its headers
+type workspaceContext struct {
+	// proxyPluginName is the name of the proxy plugin of the `/plugins/<plugin_name>` prefix of the path, if any
+	proxyPluginName string
+	// workspace is the name of the workspace of the `/workspaces/<workspace_name>` prefix of the path,
+	// of the subdomain of the host or of the WorkspaceHeader, if any
+	workspace string
+	// path is the path of the request without the plugin and workspace prefixes, ie. the path forwarded to the upstream
+	path string
+}
+
+// parseWorkspacePath parses the plugin and workspace prefixes of the given path in a single pass, since it's done for every request.
+// If the workspace prefix is invalid, the returned context holds the path without the plugin prefix, which is the path of the
+// request then, as when the prefixes were parsed one after the other.
+func parseWorkspacePath(path string) (workspaceContext, error) {
+	wc := workspaceContext{path: path}
+	if rest, found := strings.CutPrefix(wc.path, pluginsEndpoint); found {
+		pluginName, remaining := cutPathSegment(rest)
+		if remaining == "" && strings.TrimSpace(pluginName) == "" {
+			return wc, fmt.Errorf("path %q not a proxied route request", path)
 		}
-		proxyPluginName = segments[2]
-		req.URL.Path = strings.TrimPrefix(path, fmt.Sprintf("/%s/%s", segments[1], segments[2]))
-		path = req.URL.Path
+		wc.proxyPluginName, wc.path = pluginName, remaining
 	}
-	var workspace string
 	// handle specific workspace request eg. /workspaces/mycoolworkspace/api/clusterroles
-	if strings.HasPrefix(path, "/workspaces/") {
-		segments := strings.Split(path, "/")
-		// there should be at least 4 segments eg. /workspaces/mycoolworkspace/api/clusterroles counts as 4
-		if len(segments) < 4 && len(proxyPluginName) == 0 {
-			return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/api/...", path) // nolint:revive,staticcheck
-		}
-		// with proxy plugins, the route host is sufficient, and hence do not need api/...
-		if len(segments) < 3 {
-			return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
-		}
-		if len(segments) == 3 {
-			// need to distinguish between the third entry being "" vs "<plugin-name>"
-			if len(strings.TrimSpace(segments[2])) == 0 {
-				return "", "", fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", path) // nolint:revive
+	if rest, found := strings.CutPrefix(wc.path, "/workspaces/"); found {
+		workspace, remaining := cutPathSegment(rest)
+		if remaining == "" {
+			// there should be at least 4 segments eg. /workspaces/mycoolworkspace/api/clusterroles counts as 4
+			if wc.proxyPluginName == "" {
+				return wc, fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/api/...", wc.path) // nolint:revive,staticcheck
+			}
+			// with proxy plugins, the route host is sufficient, and hence do not need api/...
+			if strings.TrimSpace(workspace) == "" {
+				return wc, fmt.Errorf("workspace request path has too few segments '%s'; expected path format: /workspaces/<workspace_name>/<optional path>", wc.path) // nolint:revive
 			}
 		}
-		// get the workspace segment eg. mycoolworkspace
-		workspace = segments[2]
 		// remove workspaces/mycoolworkspace from the request path before forwarding the request
-		req.URL.Path = strings.TrimPrefix(req.URL.Path, "/workspaces/"+workspace)
+		wc.workspace, wc.path = workspace, remaining
+	}
+	return wc, nil
+}
+
+// cutPathSegment returns the first segment of the given path (without its leading slash), and the rest of the path
+// starting with its slash, if any
+func cutPathSegment(path string) (string, string) {
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
+
+// getWorkspaceContext returns the context of the given request, whose path is updated without the plugin and workspace prefixes
+func getWorkspaceContext(req *http.Request) (workspaceContext, error) {
+	wc, err := parseWorkspacePath(req.URL.Path)
+	req.URL.Path = wc.path
+	if err != nil {
+		return workspaceContext{}, err
+	}
+	// the workspace can also be the subdomain of the host, or the WorkspaceHeader of the request,
+	// for the clients which can't add a prefix to the path of the API
+	if fromHost := hostWorkspace(req); fromHost != "" {
+		if wc.workspace != "" && wc.workspace != fromHost {
+			return workspaceContext{}, fmt.Errorf("the workspace '%s' of the request path doesn't match the workspace '%s' of the host", wc.workspace, fromHost)
+		}
+		wc.workspace = fromHost
+	}
+	if fromHeader := strings.TrimSpace(req.Header.Get(WorkspaceHeader)); fromHeader != "" {
+		req.Header.Del(WorkspaceHeader)
+		if wc.workspace != "" && wc.workspace != fromHeader {
+			return workspaceContext{}, fmt.Errorf("the workspace '%s' of the %s header doesn't match the workspace '%s' of the request", fromHeader, WorkspaceHeader, wc.workspace)
+		}
+		wc.workspace = fromHeader
 	}
+	return wc, nil
+}
 
-	return proxyPluginName, workspace, nil
+// hostWorkspace returns the workspace of the request resolved from its Host header, ie. the subdomain of the host when the host is
+// in one of the domains configured with the WorkspaceDomains setting, eg. 'myworkspace' for 'myworkspace.proxy.example.com'
+func hostWorkspace(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, domain := range configuration.GetRegistrationServiceConfig().Proxy().WorkspaceDomains() {
+		if workspace, found := strings.CutSuffix(host, "."+domain); found && workspace != "" && !strings.Contains(workspace, ".") {
+			return workspace
+		}
+	}
+	return ""
+}
+
+// targetWorkspace returns the name of the workspace targeted by the request, ie. the requested workspace
+// or the home workspace of the user, once the request was processed
+func targetWorkspace(ctx echo.Context) string {
+	if workspace, _ := ctx.Get(context.WorkspaceKey).(string); workspace != "" {
+		return workspace
+	}
+	workspace, _ := ctx.Get(context.HomeWorkspaceKey).(string)
+	return workspace
 }
 
 func customHTTPErrorHandler(cause error, ctx echo.Context) {
@@ -537,12 +898,33 @@ func customHTTPErrorHandler(cause error, ctx echo.Context) {
 	if errors.As(cause, &ce) {
 		code = ce.Code
 	}
-	ctx.Logger().Error(cause)
-	if err := ctx.String(code, cause.Error()); err != nil {
+	message := scrub(cause.Error())
+	ctx.Logger().Error(message)
+	if (code == http.StatusUnauthorized || code == http.StatusForbidden) && wsstream.IsWebSocketRequest(ctx.Request()) && !ctx.Response().Committed {
+		// the browsers don't expose the body of a rejected websocket handshake
+		err := closeWebsocket(ctx, message)
+		if err == nil {
+			return
+		}
+		ctx.Logger().Error(err)
+		if ctx.Response().Committed {
+			return
+		}
+	}
+	if err := ctx.JSON(code, newStatus(code, message)); err != nil {
 		ctx.Logger().Error(err)
 	}
 }
 
+// scrub redacts the bearer tokens and the email addresses of the given text, eg. of an error message,
+// if the LogScrubbingEnabled setting of the proxy is set
+func scrub(text string) string {
+	if configuration.GetRegistrationServiceConfig().Proxy().LogScrubbingEnabled() {
+		return log.Scrub(text)
+	}
+	return text
+}
+
 // addUserContext updates echo.Context with the claims extracted from the Bearer token.
 // To be used for storing the claims and logging only.
 func (p *Proxy) addUserContext() echo.MiddlewareFunc {
@@ -551,20 +933,63 @@ func (p *Proxy) addUserContext() echo.MiddlewareFunc {
 			if unsecured(ctx) { // skip only for unsecured endpoints
 				return next(ctx)
 			}
+			if !hasToken(ctx.Request()) {
+				if username, subject, found := clientCertUsername(ctx.Request()); found {
+					if username == "" {
+						return crterrors.NewUnauthorizedError("invalid client certificate", fmt.Sprintf("no user is mapped to the client certificate '%s'", subject))
+					}
+					ctx.Set(context.ClientCertificateKey, subject)
+					ctx.Set(context.SubKey, "")
+					ctx.Set(context.UsernameKey, username)
+					ctx.Set(context.EmailKey, "")
+					ctx.Set(context.JWTClaimsKey, &auth.TokenClaims{PreferredUsername: username})
+					return next(ctx)
+				}
+			}
+			if anonymous, err := anonymousRequest(ctx.Request()); anonymous {
+				if err != nil {
+					return err
+				}
+				ctx.Set(context.AnonymousKey, true)
+				ctx.Set(context.UsernameKey, "")
+				ctx.Set(context.EmailKey, "")
+				return next(ctx)
+			}
 
 			token, err := p.extractUserToken(ctx.Request())
 			if err != nil {
 				return crterrors.NewUnauthorizedError("invalid bearer token", err.Error())
 			}
+			if configuration.GetRegistrationServiceConfig().Auth().RequireVerifiedEmail() && !token.EmailVerified {
+				return crterrors.NewForbiddenError("email not verified",
+					"the email address of your account is not verified: please verify it with your identity provider, then log in again")
+			}
 			ctx.Set(context.SubKey, token.Subject)
 			ctx.Set(context.UsernameKey, token.PreferredUsername)
 			ctx.Set(context.EmailKey, token.Email)
+			ctx.Set(context.JWTClaimsKey, token)
 
 			return next(ctx)
 		}
 	}
 }
 
+// anonymousRequest returns true if the request has no token and the PublicViewer support is enabled, in which case the request
+// is proxied as the PublicViewer, so that the community workspaces can be browsed anonymously. An error is returned along with true
+// if the anonymous request is not allowed: only the read-only (GET or HEAD) requests to a workspace are.
+func anonymousRequest(req *http.Request) (bool, error) {
+	if req.Header.Get("Authorization") != "" || hasSessionCookie(req) || httpstream.IsUpgradeRequest(req) || !configuration.GetRegistrationServiceConfig().PublicViewerEnabled() {
+		return false, nil
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return true, crterrors.NewUnauthorizedError("no token found", "the anonymous requests are read-only")
+	}
+	if !strings.HasPrefix(req.URL.Path, "/workspaces/") && hostWorkspace(req) == "" && req.Header.Get(WorkspaceHeader) == "" {
+		return true, crterrors.NewUnauthorizedError("no token found", "the anonymous requests are limited to the community workspaces")
+	}
+	return true, nil
+}
+
 // addPublicViewerContext updates echo.Context with the configuration's PublicViewerEnabled value.
 func (p *Proxy) addPublicViewerContext() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -583,7 +1008,12 @@ func (p *Proxy) addPublicViewerContext() echo.MiddlewareFunc {
 func (p *Proxy) ensureUserIsNotBanned() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			if unsecured(ctx) { // skip only for unsecured endpoints
+			if unsecured(ctx) || context.IsAnonymous(ctx) { // skip only for unsecured endpoints and anonymous requests
+				return next(ctx)
+			}
+			if _, found := ctx.Get(context.ClientCertificateKey).(string); found {
+				// there is no email in the client certificates, but the banned users are deprovisioned, so that the requests
+				// are rejected anyway when looking up the workspaces of the user
 				return next(ctx)
 			}
 
@@ -594,6 +1024,10 @@ func (p *Proxy) ensureUserIsNotBanned() echo.MiddlewareFunc {
 
 			// retrieve banned users
 			hashedEmail := hash.EncodeString(email)
+			if p.recentBans.isBanned(hashedEmail, time.Now()) || (p.sharedCache != nil && p.sharedCache.isBanned(hashedEmail, time.Now())) {
+				// banned with BanUser or according to another replica, whose informer already saw the BannedUser
+				return crterrors.NewForbiddenError("user access is forbidden", "user access is forbidden")
+			}
 			bannedUsers := &toolchainv1alpha1.BannedUserList{}
 			if err := p.List(ctx.Request().Context(), bannedUsers, client.InNamespace(p.Namespace),
 				client.MatchingLabels{toolchainv1alpha1.BannedUserEmailHashLabelKey: hashedEmail}); err != nil {
@@ -603,6 +1037,9 @@ func (p *Proxy) ensureUserIsNotBanned() echo.MiddlewareFunc {
 
 			// if a matching Banned user is found, then user is banned
 			if len(bannedUsers.Items) > 0 {
+				if p.sharedCache != nil {
+					p.sharedCache.publishBan(ctx.Request().Context(), hashedEmail)
+				}
 				return crterrors.NewForbiddenError("user access is forbidden", "user access is forbidden")
 			}
 
@@ -630,7 +1067,7 @@ func (p *Proxy) stripInvalidHeaders() echo.MiddlewareFunc {
 func (p *Proxy) addStartTime() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(ctx echo.Context) error {
-			if ctx.Request().URL.Path == proxyHealthEndpoint { // skip only for health endpoint
+			if probeEndpoint(ctx.Request().URL.Path) { // skip only for health and readiness endpoints
 				return next(ctx)
 			}
 			ctx.Set(context.RequestReceivedTime, time.Now())
@@ -642,22 +1079,49 @@ func (p *Proxy) addStartTime() echo.MiddlewareFunc {
 func (p *Proxy) extractUserToken(req *http.Request) (*auth.TokenClaims, error) {
 	userToken := ""
 	var err error
-	if wsstream.IsWebSocketRequest(req) {
+	if token, found := p.sessions.token(req, time.Now()); found && !hasToken(req) {
+		// the browsers logged in through the login endpoint only send the cookie of their session
+		userToken = token
+	} else if wsstream.IsWebSocketRequest(req) {
 		userToken, err = extractTokenFromWebsocketRequest(req)
 		if err != nil {
+			reason := auth.FailureReasonMalformed
+			if errors.Is(err, errNoWebsocketToken) {
+				reason = auth.FailureReasonMissing
+			}
+			p.metrics.RegServProxyTokenValidationFailuresCounterVec.WithLabelValues(reason).Inc()
 			return nil, err
 		}
 	} else {
 		userToken, err = extractUserToken(req)
 		if err != nil {
+			p.metrics.RegServProxyTokenValidationFailuresCounterVec.WithLabelValues(auth.FailureReasonMissing).Inc()
 			return nil, err
 		}
 	}
 
-	token, err := p.tokenParser.FromString(userToken)
+	now := time.Now()
+	if token, found := p.tokenCache.get(userToken, now); found {
+		p.metrics.RegServProxyTokenCacheCounterVec.WithLabelValues(metrics.MetricLabelHit).Inc()
+		// the token may have been revoked since it was cached
+		if p.tokenParser.IsRevoked(token) {
+			p.metrics.RegServProxyTokenValidationFailuresCounterVec.WithLabelValues(auth.FailureReasonRevoked).Inc()
+			return nil, crterrors.NewUnauthorizedError("unable to extract claims from token", auth.ErrTokenRevoked.Error())
+		}
+		return token, nil
+	}
+	p.metrics.RegServProxyTokenCacheCounterVec.WithLabelValues(metrics.MetricLabelMiss).Inc()
+	var token *auth.TokenClaims
+	if auth.IsPersonalAccessToken(userToken) {
+		token, err = p.tokenParser.FromPersonalAccessToken(userToken)
+	} else {
+		token, err = p.tokenParser.FromString(userToken)
+	}
 	if err != nil {
+		p.metrics.RegServProxyTokenValidationFailuresCounterVec.WithLabelValues(auth.FailureReason(err)).Inc()
 		return nil, crterrors.NewUnauthorizedError("unable to extract claims from token", err.Error())
 	}
+	p.tokenCache.set(userToken, token, now)
 	return token, nil
 }
 
@@ -670,15 +1134,17 @@ func extractUserToken(req *http.Request) (string, error) {
 	return token[1], nil
 }
 
-func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess, isPlugin bool) *httputil.ReverseProxy {
+func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess, isPlugin bool, accounting *upstreamAccounting, canary CanaryFlags) *httputil.ReverseProxy {
 	req := ctx.Request()
 	targetQuery := target.APIURL().RawQuery
 	username, _ := ctx.Get(context.UsernameKey).(string)
 	// set username in context for logging purposes
 	ctx.Set(context.ImpersonateUser, target.Username())
+	groups := impersonatedGroups(ctx, target)
 
 	director := func(req *http.Request) {
 		origin := req.URL.String()
+		setForwardedHeaders(req, req.Host, p.trustedProxies)
 		req.URL.Scheme = target.APIURL().Scheme
 		req.URL.Host = target.APIURL().Host
 		req.URL.Path = singleJoiningSlash(target.APIURL().Path, req.URL.Path)
@@ -701,23 +1167,79 @@ func (p *Proxy) newReverseProxy(ctx echo.Context, target *access.ClusterAccess,
 			req.Header.Set("User-Agent", "")
 		}
 		// Replace token
+		removeSessionCookie(req)
 		if wsstream.IsWebSocketRequest(req) {
 			replaceTokenInWebsocketRequest(req, target.ImpersonatorToken())
 		} else {
 			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", target.ImpersonatorToken()))
 		}
 
-		// Set impersonation header
+		// Set impersonation headers
 		req.Header.Set("Impersonate-User", target.Username())
+		for _, group := range groups {
+			req.Header.Add("Impersonate-Group", group)
+		}
+		accounting.forwarded()
+	}
+	headerTimeout, idleTimeout := upstreamTimeouts(isStreamingRequest(req))
+	var transport http.RoundTripper
+	if canary.Enabled(CanarySharedTransport) && target.TLSConfig() == nil {
+		transport = p.getSharedTransport(req.Header, headerTimeout)
+	} else {
+		t := getTransport(req.Header, target.TLSConfig())
+		t.ResponseHeaderTimeout = headerTimeout
+		transport = t
+	}
+	m := &responseModifier{
+		requestOrigin: req.Header.Get("Origin"),
+		withSession:   hasSessionCookie(req),
+	}
+	buffers := p.getCopyBufferPool()
+	reverseProxy := &httputil.ReverseProxy{
+		Director:   director,
+		Transport:  newObservedTransport(transport, p.metrics, target),
+		BufferPool: buffers,
+		// the response is flushed after each write, so that the watch and the Server-Sent events are not buffered
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			if err := accounting.addToResponse(resp); err != nil {
+				return err
+			}
+			if upstream, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+				resp.Body = &upgradedConnection{
+					ReadWriteCloser: upstream,
+					buffers:         buffers,
+					onClose:         p.observeUpgradedConnection(target, time.Now()),
+				}
+			}
+			timeout := idleTimeout
+			if prepareEventStream(resp) {
+				timeout = configuration.GetRegistrationServiceConfig().Proxy().EventStreamIdleTimeout()
+			}
+			if timeout > 0 && resp.StatusCode != http.StatusSwitchingProtocols {
+				resp.Body = newIdleTimeoutBody(resp.Body, timeout)
+			}
+			if resp.StatusCode == http.StatusUnauthorized && !isPlugin {
+				// the member cluster rejected the token of the service account, which may have been rotated
+				p.refreshClusterConfig(resp.Request.Context(), target)
+			}
+			return m.addCorsToResponse(resp)
+		},
 	}
-	transport := getTransport(req.Header)
-	m := &responseModifier{req.Header.Get("Origin")}
-	return &httputil.ReverseProxy{
-		Director:       director,
-		Transport:      transport,
-		FlushInterval:  -1,
-		ModifyResponse: m.addCorsToResponse,
+	if isPlugin {
+		reverseProxy.ErrorHandler = func(_ http.ResponseWriter, req *http.Request, err error) {
+			// the plugin backend can't be reached, most likely because its Route changed,
+			// so make sure that the Route is fetched again for the next request, by all the replicas
+			apiURL := target.APIURL()
+			p.pluginEndpoints.InvalidateHost(apiURL.Host)
+			if p.sharedCache != nil {
+				p.sharedCache.publishPluginEndpointInvalidation(gocontext.WithoutCancel(req.Context()), apiURL.Host)
+			}
+			log.Error(nil, err, "unable to reach the proxy plugin backend "+apiURL.Host)
+			customHTTPErrorHandler(crterrors.NewBadGatewayError("unable to proxy the request", "the proxy plugin backend can't be reached"), ctx)
+		}
 	}
+	return reverseProxy
 }
 
 // TODO: use transport from the cached ToolchainCluster instance
@@ -737,11 +1259,24 @@ var noTimeoutDialerProxy = func(ctx gocontext.Context, network, addr string) (ne
 	return dialer.DialContext(ctx, network, addr)
 }
 
-func getTransport(reqHeader http.Header) *http.Transport {
+// getTransport returns the transport to use to reach the target.
+// If a TLS configuration is given (eg. the one of a proxy plugin backend), then it is used
+// instead of the environment-based TLS policy.
+func getTransport(reqHeader http.Header, tlsConfig *tls.Config) *http.Transport {
 	// TODO: use transport from the cached ToolchainCluster instance
 	transport := noTimeoutDefaultTransport()
+	proxyConfig := configuration.GetRegistrationServiceConfig().Proxy()
+	if size := proxyConfig.ConnectionBufferSize(); size > 0 {
+		transport.ReadBufferSize = size
+		transport.WriteBufferSize = size
+	}
+	if dialContext := resolvingDialer(proxyConfig.HostOverrides(), proxyConfig.DNSServers()); dialContext != nil {
+		transport.DialContext = dialContext
+	}
 
-	if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	} else if !configuration.GetRegistrationServiceConfig().IsProdEnvironment() {
 		transport.TLSClientConfig = &tls.Config{
 			InsecureSkipVerify: true, // nolint:gosec
 		}
@@ -751,8 +1286,12 @@ func getTransport(reqHeader http.Header) *http.Transport {
 	if strings.HasPrefix(strings.ToLower(reqHeader.Get(httpstream.HeaderUpgrade)), "spdy/") {
 		// thus, we need to switch to http/1.1
 		transport.ForceAttemptHTTP2 = false
-		transport.TLSClientConfig = &tls.Config{ // nolint:gosec
-			NextProtos: []string{"http/1.1"},
+		if tlsConfig != nil {
+			transport.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		} else {
+			transport.TLSClientConfig = &tls.Config{ // nolint:gosec
+				NextProtos: []string{"http/1.1"},
+			}
 		}
 	}
 
@@ -773,6 +1312,9 @@ func singleJoiningSlash(a, b string) string {
 
 var ph = textproto.CanonicalMIMEHeaderKey("Sec-WebSocket-Protocol")
 
+// errNoWebsocketToken is returned when a websocket request has no bearer token protocol
+var errNoWebsocketToken = errs.New("no base64.bearer.authorization token found")
+
 func extractTokenFromWebsocketRequest(req *http.Request) (string, error) {
 	token := ""
 	sawTokenProtocol := false
@@ -801,7 +1343,7 @@ func extractTokenFromWebsocketRequest(req *http.Request) (string, error) {
 	}
 
 	if len(token) == 0 {
-		return "", errs.New("no base64.bearer.authorization token found")
+		return "", errNoWebsocketToken
 	}
 
 	return token, nil
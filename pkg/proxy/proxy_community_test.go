@@ -61,6 +61,7 @@ func (s *TestProxySuite) TestProxyCommunityEnabled() {
 }
 
 func (s *TestProxySuite) checkProxyCommunityOK(proxy *Proxy, port string) {
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_DOMAINS", "proxy.example.com")
 	podsRequestURL := func(workspace string) string {
 		return fmt.Sprintf("http://localhost:%s/workspaces/%s/api/pods", port, workspace)
 	}
@@ -153,6 +154,7 @@ func (s *TestProxySuite) checkProxyCommunityOK(proxy *Proxy, port string) {
 		// run test cases
 		tests := map[string]struct {
 			ProxyRequestMethod              string
+			ProxyRequestHost                string
 			ProxyRequestHeaders             http.Header
 			ExpectedAPIServerRequestHeaders http.Header
 			ExpectedProxyResponseStatus     int
@@ -382,6 +384,89 @@ func (s *TestProxySuite) checkProxyCommunityOK(proxy *Proxy, port string) {
 				RequestPath:                 podsInNamespaceRequestURL("smith-community", "not-existing"),
 				ExpectedResponse:            "user access is forbidden: user access is forbidden",
 			},
+			// Given smith owns a workspace named smith-community
+			// And   smith-community is publicly visible (shared with PublicViewer)
+			// When  an anonymous user requests the list of pods in workspace smith-community
+			// Then  the request is forwarded from the proxy
+			// And   the request impersonates the PublicViewer
+			// And   the request is successful
+			"plain http actual request as anonymous user to community workspace": {
+				ProxyRequestMethod: "GET",
+				ExpectedAPIServerRequestHeaders: map[string][]string{
+					"Authorization":    {"Bearer clusterSAToken"},
+					"Impersonate-User": {toolchainv1alpha1.KubesawAuthenticatedUsername},
+					"X-SSO-User":       {""},
+				},
+				ExpectedProxyResponseStatus: http.StatusOK,
+				RequestPath:                 podsRequestURL("smith-community"),
+				ExpectedResponse:            httpTestServerResponse,
+			},
+			// Given user alice exists
+			// And   alice owns a private workspace
+			// When  an anonymous user requests the list of pods in alice's workspace
+			// Then  the proxy does NOT forward the request
+			// And   the proxy rejects the call with 403 Forbidden
+			"plain http actual request as anonymous user to private workspace": {
+				ProxyRequestMethod:          "GET",
+				ExpectedProxyResponseStatus: http.StatusForbidden,
+				RequestPath:                 podsRequestURL("alice-private"),
+				ExpectedResponse:            "invalid workspace request: access to workspace 'alice-private' is forbidden",
+			},
+			// Given smith owns a workspace named smith-community
+			// And   smith-community is publicly visible (shared with PublicViewer)
+			// When  an anonymous user creates a pod in workspace smith-community
+			// Then  the proxy does NOT forward the request
+			// And   the proxy rejects the call with 401 Unauthorized
+			"plain http mutating request as anonymous user to community workspace": {
+				ProxyRequestMethod:          "POST",
+				ExpectedProxyResponseStatus: http.StatusUnauthorized,
+				RequestPath:                 podsRequestURL("smith-community"),
+				ExpectedResponse:            "no token found: the anonymous requests are read-only",
+			},
+			// Given smith owns a workspace named smith-community
+			// And   smith-community is publicly visible (shared with PublicViewer)
+			// When  an anonymous user requests the list of pods with the smith-community subdomain of the proxy
+			// Then  the request is forwarded from the proxy
+			// And   the request impersonates the PublicViewer
+			// And   the request is successful
+			// Given smith owns a workspace named smith-community
+			// And   smith-community is publicly visible (shared with PublicViewer)
+			// When  an anonymous user requests the list of pods with the smith-community X-Workspace header
+			// Then  the request is forwarded from the proxy, without the header
+			// And   the request impersonates the PublicViewer
+			// And   the request is successful
+			"plain http actual request as anonymous user to community workspace of the header": {
+				ProxyRequestMethod:  "GET",
+				ProxyRequestHeaders: map[string][]string{"X-Workspace": {"smith-community"}},
+				ExpectedAPIServerRequestHeaders: map[string][]string{
+					"Authorization":    {"Bearer clusterSAToken"},
+					"Impersonate-User": {toolchainv1alpha1.KubesawAuthenticatedUsername},
+					"X-Workspace":      nil,
+				},
+				ExpectedProxyResponseStatus: http.StatusOK,
+				RequestPath:                 fmt.Sprintf("http://localhost:%s/api/pods", port),
+				ExpectedResponse:            httpTestServerResponse,
+			},
+			"plain http actual request as anonymous user to community workspace of the host": {
+				ProxyRequestMethod: "GET",
+				ProxyRequestHost:   "smith-community.proxy.example.com",
+				ExpectedAPIServerRequestHeaders: map[string][]string{
+					"Authorization":    {"Bearer clusterSAToken"},
+					"Impersonate-User": {toolchainv1alpha1.KubesawAuthenticatedUsername},
+				},
+				ExpectedProxyResponseStatus: http.StatusOK,
+				RequestPath:                 fmt.Sprintf("http://localhost:%s/api/pods", port),
+				ExpectedResponse:            httpTestServerResponse,
+			},
+			// When  an anonymous user requests the list of pods in their home workspace
+			// Then  the proxy does NOT forward the request
+			// And   the proxy rejects the call with 401 Unauthorized
+			"plain http actual request as anonymous user to home workspace": {
+				ProxyRequestMethod:          "GET",
+				ExpectedProxyResponseStatus: http.StatusUnauthorized,
+				RequestPath:                 fmt.Sprintf("http://localhost:%s/api/pods", port),
+				ExpectedResponse:            "no token found: the anonymous requests are limited to the community workspaces",
+			},
 		}
 
 		for k, tc := range tests {
@@ -411,6 +496,9 @@ func (s *TestProxySuite) checkProxyCommunityOK(proxy *Proxy, port string) {
 				req, err := http.NewRequest(tc.ProxyRequestMethod, tc.RequestPath, nil)
 				require.NoError(s.T(), err)
 				require.NotNil(s.T(), req)
+				if tc.ProxyRequestHost != "" {
+					req.Host = tc.ProxyRequestHost
+				}
 
 				for hk, hv := range tc.ProxyRequestHeaders {
 					for _, v := range hv {
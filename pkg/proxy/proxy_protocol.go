@@ -0,0 +1,196 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+)
+
+// The PROXY protocol (https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt) is used by the TCP load balancers
+// to convey the address of the original client of a connection, in a header sent before the data of the connection
+const (
+	// proxyProtocolHeaderTimeout is the maximum duration to receive the PROXY protocol header of a connection
+	proxyProtocolHeaderTimeout = 5 * time.Second
+	// proxyProtocolV1MaxLength is the maximum length of a header of the version 1 (text) of the protocol, including the CRLF
+	proxyProtocolV1MaxLength = 107
+)
+
+// proxyProtocolV2Signature is the signature of the headers of the version 2 (binary) of the PROXY protocol
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocolListener accepts the connections whose original client address is given with a PROXY protocol header (v1 or v2).
+// The header is only read from the trusted peers, and is optional for them, so that the proxy can still be reached directly,
+// eg. by the probes of its health. The headers sent by any other peer are not read, and are thus rejected as invalid requests.
+type proxyProtocolListener struct {
+	net.Listener
+	trustedProxies []*net.IPNet
+}
+
+// newProxyProtocolListener returns a listener reading the PROXY protocol headers sent by the given trusted proxies only
+func newProxyProtocolListener(l net.Listener, trustedProxies []*net.IPNet) net.Listener {
+	return &proxyProtocolListener{
+		Listener:       l,
+		trustedProxies: trustedProxies,
+	}
+}
+
+// Accept returns the next connection. Its header is read on the first call of its Read or RemoteAddr methods,
+// so that a slow client doesn't block the other connections.
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !isTrustedProxy(conn.RemoteAddr().String(), l.trustedProxies) {
+		return conn, nil
+	}
+	return &proxyProtocolConn{
+		Conn:   conn,
+		reader: bufio.NewReader(conn),
+	}, nil
+}
+
+// proxyProtocolConn is a connection which may start with a PROXY protocol header
+type proxyProtocolConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	once       sync.Once
+	remoteAddr net.Addr
+	err        error
+}
+
+// readHeader reads the PROXY protocol header of the connection, if any, once. The header is read when the server gets the remote
+// address of the connection, before it sets the deadlines of the connection, which are thus not reset by the deadline of the header.
+func (c *proxyProtocolConn) readHeader() {
+	c.once.Do(func() {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout))
+		c.remoteAddr, c.err = readProxyProtocolHeader(c.reader)
+		_ = c.Conn.SetReadDeadline(time.Time{})
+		if c.err != nil {
+			log.Error(nil, c.err, "invalid PROXY protocol header received from "+c.Conn.RemoteAddr().String())
+			_ = c.Conn.Close()
+		}
+	})
+}
+
+// Read reads the data of the connection, after the PROXY protocol header
+func (c *proxyProtocolConn) Read(b []byte) (int, error) {
+	c.readHeader()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the original client given with the PROXY protocol header, if any,
+// or else the address of the peer
+func (c *proxyProtocolConn) RemoteAddr() net.Addr {
+	c.readHeader()
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// readProxyProtocolHeader reads the PROXY protocol header from the given reader, if the data starts with one, and returns the address
+// of the original client, or nil if the data doesn't start with a header, or if the header doesn't convey the address of a client
+// (eg. for the health checks of the load balancer)
+func readProxyProtocolHeader(reader *bufio.Reader) (net.Addr, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	switch first[0] {
+	case 'P':
+		if prefix, err := reader.Peek(6); err != nil || string(prefix) != "PROXY " {
+			return nil, nil
+		}
+		return readProxyProtocolV1Header(reader)
+	case proxyProtocolV2Signature[0]:
+		if signature, err := reader.Peek(len(proxyProtocolV2Signature)); err != nil || !bytes.Equal(signature, proxyProtocolV2Signature) {
+			return nil, nil
+		}
+		return readProxyProtocolV2Header(reader)
+	default:
+		return nil, nil
+	}
+}
+
+// readProxyProtocolV1Header reads a header such as 'PROXY TCP4 192.168.0.1 192.168.0.11 56324 443\r\n'
+func readProxyProtocolV1Header(reader *bufio.Reader) (net.Addr, error) {
+	var line []byte
+	for !bytes.HasSuffix(line, []byte("\r\n")) {
+		if len(line) >= proxyProtocolV1MaxLength {
+			return nil, errors.New("the PROXY protocol v1 header is too long")
+		}
+		b, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, fmt.Errorf("invalid PROXY protocol v1 header '%s'", strings.TrimSpace(string(line)))
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil || (fields[1] == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid PROXY protocol v1 header '%s'", strings.TrimSpace(string(line)))
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+// readProxyProtocolV2Header reads a binary header: the signature, the version and the command, the address family and the protocol,
+// the length of the addresses, and the addresses (followed by optional TLVs, which are ignored)
+func readProxyProtocolV2Header(reader *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, len(proxyProtocolV2Signature)+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, err
+	}
+	versionCommand, family := header[12], header[13]
+	addresses := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(reader, addresses); err != nil {
+		return nil, err
+	}
+	if versionCommand>>4 != 2 {
+		return nil, fmt.Errorf("unsupported PROXY protocol version %d", versionCommand>>4)
+	}
+	switch versionCommand & 0x0F {
+	case 0x0:
+		// LOCAL command, eg. sent by the health checks of the load balancer
+		return nil, nil
+	case 0x1:
+		// PROXY command
+	default:
+		return nil, fmt.Errorf("unsupported PROXY protocol v2 command %d", versionCommand&0x0F)
+	}
+	switch family {
+	case 0x11: // TCP over IPv4
+		if len(addresses) < 12 {
+			return nil, errors.New("invalid PROXY protocol v2 IPv4 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:4]), Port: int(binary.BigEndian.Uint16(addresses[8:10]))}, nil
+	case 0x21: // TCP over IPv6
+		if len(addresses) < 36 {
+			return nil, errors.New("invalid PROXY protocol v2 IPv6 addresses")
+		}
+		return &net.TCPAddr{IP: net.IP(addresses[0:16]), Port: int(binary.BigEndian.Uint16(addresses[32:34]))}, nil
+	default:
+		// the other families (eg. UDP, Unix sockets) don't convey the address of a TCP client
+		return nil, nil
+	}
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func proxyProtocolV2Header(command, family byte, addresses []byte) string {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	header = append(header, 0x20|command, family)
+	header = binary.BigEndian.AppendUint16(header, uint16(len(addresses))) // nolint:gosec
+	return string(append(header, addresses...))
+}
+
+func (s *TestProxySuite) TestReadProxyProtocolHeader() {
+	ipv4Addresses := []byte{192, 168, 0, 1, 10, 0, 0, 1, 0xDB, 0xBA, 0x01, 0xBB}
+	ipv6Addresses := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0xDB, 0xBA, 0x01, 0xBB)
+
+	s.Run("valid headers", func() {
+		for name, tc := range map[string]struct {
+			header       string
+			expectedAddr string
+		}{
+			"v1 TCP4":    {"PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\n", "192.168.0.1:56250"},
+			"v1 TCP6":    {"PROXY TCP6 2001:db8::1 2001:db8::2 56250 443\r\n", "[2001:db8::1]:56250"},
+			"v1 UNKNOWN": {"PROXY UNKNOWN\r\n", ""},
+			"v2 TCP4":    {proxyProtocolV2Header(0x1, 0x11, ipv4Addresses), "192.168.0.1:56250"},
+			"v2 TCP6":    {proxyProtocolV2Header(0x1, 0x21, ipv6Addresses), "[2001:db8::1]:56250"},
+			"v2 TLVs":    {proxyProtocolV2Header(0x1, 0x11, append(ipv4Addresses, 0x01, 0x00, 0x02, 'h', '2')), "192.168.0.1:56250"},
+			"v2 LOCAL":   {proxyProtocolV2Header(0x0, 0x00, nil), ""},
+			"v2 UNIX":    {proxyProtocolV2Header(0x1, 0x31, make([]byte, 216)), ""},
+			"no header":  {"", ""},
+		} {
+			s.Run(name, func() {
+				// given
+				reader := bufio.NewReader(strings.NewReader(tc.header + "POST /api/v1/pods HTTP/1.1\r\n"))
+
+				// when
+				addr, err := readProxyProtocolHeader(reader)
+
+				// then
+				require.NoError(s.T(), err)
+				if tc.expectedAddr == "" {
+					assert.Nil(s.T(), addr)
+				} else {
+					require.NotNil(s.T(), addr)
+					assert.Equal(s.T(), tc.expectedAddr, addr.String())
+				}
+				// the data after the header is left unread
+				line, err := reader.ReadString('\n')
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "POST /api/v1/pods HTTP/1.1\r\n", line)
+			})
+		}
+	})
+
+	s.Run("invalid headers", func() {
+		for name, tc := range map[string]struct {
+			header      string
+			expectedErr string
+		}{
+			"v1 too long":          {"PROXY TCP4 " + strings.Repeat("1", 100) + "\r\n", "the PROXY protocol v1 header is too long"},
+			"v1 unknown protocol":  {"PROXY UDP4 192.168.0.1 10.0.0.1 56250 443\r\n", "invalid PROXY protocol v1 header 'PROXY UDP4 192.168.0.1 10.0.0.1 56250 443'"},
+			"v1 invalid address":   {"PROXY TCP4 2001:db8::1 10.0.0.1 56250 443\r\n", "invalid PROXY protocol v1 header 'PROXY TCP4 2001:db8::1 10.0.0.1 56250 443'"},
+			"v1 invalid port":      {"PROXY TCP4 192.168.0.1 10.0.0.1 99999 443\r\n", "invalid PROXY protocol v1 header 'PROXY TCP4 192.168.0.1 10.0.0.1 99999 443'"},
+			"v1 missing fields":    {"PROXY TCP4 192.168.0.1\r\n", "invalid PROXY protocol v1 header 'PROXY TCP4 192.168.0.1'"},
+			"v2 unknown command":   {proxyProtocolV2Header(0x2, 0x11, ipv4Addresses), "unsupported PROXY protocol v2 command 2"},
+			"v2 short addresses":   {proxyProtocolV2Header(0x1, 0x21, ipv4Addresses), "invalid PROXY protocol v2 IPv6 addresses"},
+			"v2 truncated address": {proxyProtocolV2Header(0x1, 0x11, ipv4Addresses)[:20], io.ErrUnexpectedEOF.Error()},
+		} {
+			s.Run(name, func() {
+				// when
+				_, err := readProxyProtocolHeader(bufio.NewReader(strings.NewReader(tc.header)))
+
+				// then
+				require.EqualError(s.T(), err, tc.expectedErr)
+			})
+		}
+	})
+}
+
+func (s *TestProxySuite) TestProxyProtocolListener() {
+	accept := func(trustedProxies []*net.IPNet, data string) (net.Conn, error) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(s.T(), err)
+		defer l.Close()
+		go func() {
+			client, err := net.Dial("tcp", l.Addr().String())
+			if !assert.NoError(s.T(), err) {
+				return
+			}
+			defer client.Close()
+			_, _ = client.Write([]byte(data))
+		}()
+		return newProxyProtocolListener(l, trustedProxies).Accept()
+	}
+
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(s.T(), err)
+
+	s.Run("address of the original client", func() {
+		// when
+		conn, err := accept([]*net.IPNet{loopback}, "PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\nGET / HTTP/1.1\r\n")
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.Equal(s.T(), "192.168.0.1:56250", conn.RemoteAddr().String())
+		data, err := io.ReadAll(conn)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "GET / HTTP/1.1\r\n", string(data))
+	})
+
+	s.Run("no header", func() {
+		// when
+		conn, err := accept([]*net.IPNet{loopback}, "GET / HTTP/1.1\r\n")
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.True(s.T(), strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:"))
+		data, err := io.ReadAll(conn)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "GET / HTTP/1.1\r\n", string(data))
+	})
+
+	s.Run("header of an untrusted peer not read", func() {
+		// given
+		_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+		require.NoError(s.T(), err)
+
+		// when
+		conn, err := accept([]*net.IPNet{trusted}, "PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\n")
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.True(s.T(), strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:"))
+		data, err := io.ReadAll(conn)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\n", string(data))
+	})
+
+	s.Run("header not read without trusted proxy", func() {
+		// when
+		conn, err := accept(nil, "PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\n")
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.True(s.T(), strings.HasPrefix(conn.RemoteAddr().String(), "127.0.0.1:"))
+		data, err := io.ReadAll(conn)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "PROXY TCP4 192.168.0.1 10.0.0.1 56250 443\r\n", string(data))
+	})
+
+	s.Run("invalid header", func() {
+		// when
+		conn, err := accept([]*net.IPNet{loopback}, "PROXY TCP4 invalid\r\nGET / HTTP/1.1\r\n")
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		_, err = io.ReadAll(conn)
+		require.EqualError(s.T(), err, "invalid PROXY protocol v1 header 'PROXY TCP4 invalid'")
+	})
+}
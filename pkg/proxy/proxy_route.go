@@ -0,0 +1,86 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+)
+
+const proxyRouteEndpoint = "/proxyroute"
+
+// Route describes where a request would be forwarded by the proxy
+type Route struct {
+	// Workspace is the workspace targeted by the request, empty for the home workspace of the user
+	Workspace string `json:"workspace,omitempty"`
+	// ProxyPlugin is the name of the proxy plugin targeted by the request, if any
+	ProxyPlugin string `json:"proxyPlugin,omitempty"`
+	// TargetCluster is the name of the member cluster the request would be forwarded to
+	TargetCluster string `json:"targetCluster"`
+	// APIEndpoint is the URL of the API server (or plugin backend) the request would be forwarded to
+	APIEndpoint string `json:"apiEndpoint"`
+	// Path is the path of the forwarded request
+	Path string `json:"path"`
+	// ImpersonateUser is the identity impersonated when forwarding the request
+	ImpersonateUser string `json:"impersonateUser"`
+	// ImpersonateGroups are the groups impersonated along with the user when forwarding the request, if any
+	ImpersonateGroups []string `json:"impersonateGroups,omitempty"`
+}
+
+// proxyRoute resolves the route of the request defined by the `workspace` and `path` query params,
+// the same way as the proxy would do it, but returns the route instead of forwarding the request.
+// Useful to debug why a request was forwarded to a given member cluster.
+func (p *Proxy) proxyRoute(ctx echo.Context) error {
+	workspace := ctx.QueryParam("workspace")
+	req := ctx.Request().Clone(ctx.Request().Context())
+	req.URL.Path = dryRunPath(workspace, ctx.QueryParam("path"))
+	req.URL.RawQuery = ""
+	ctx.SetRequest(req)
+
+	proxyPluginName, cluster, err := p.processRequest(ctx)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, newRoute(workspace, proxyPluginName, cluster, req.URL.Path, impersonatedGroups(ctx, cluster)))
+}
+
+// newRoute returns the route to the given cluster, for the given path of the request after it was processed
+// (ie. without the plugin and workspace prefixes), impersonating the given groups
+func newRoute(workspace, proxyPluginName string, cluster *access.ClusterAccess, path string, groups []string) Route {
+	apiURL := cluster.APIURL()
+	route := Route{
+		Workspace:       workspace,
+		ProxyPlugin:     proxyPluginName,
+		TargetCluster:   cluster.ClusterName(),
+		APIEndpoint:     apiURL.String(),
+		Path:            singleJoiningSlash(apiURL.Path, path),
+		ImpersonateUser: cluster.Username(),
+	}
+	if len(groups) > 0 {
+		route.ImpersonateGroups = groups
+	}
+	return route
+}
+
+// dryRunPath returns the path of the request that the proxy would receive for the given workspace and path,
+// eg. `/plugins/<plugin>/workspaces/<workspace>/<path>` for a path starting with `/plugins/<plugin>`
+func dryRunPath(workspace, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	pluginPrefix := ""
+	if strings.HasPrefix(path, pluginsEndpoint) {
+		// segments are "", "plugins", <plugin> and the rest of the path
+		segments := strings.SplitN(path, "/", 4)
+		pluginPrefix = "/plugins/" + segments[2]
+		path = "/"
+		if len(segments) == 4 {
+			path += segments[3]
+		}
+	}
+	if workspace != "" {
+		path = "/workspaces/" + workspace + path
+	}
+	return pluginPrefix + path
+}
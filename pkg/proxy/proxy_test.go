@@ -5,9 +5,11 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -26,7 +28,9 @@ import (
 	"github.com/codeready-toolchain/registration-service/test/fake"
 	"github.com/codeready-toolchain/registration-service/test/util"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
 	"github.com/prometheus/client_golang/prometheus"
 	"k8s.io/client-go/kubernetes/scheme"
 
@@ -118,11 +122,20 @@ func (s *TestProxySuite) TestProxy() {
 			s.Run("health check ok", func() {
 				s.checkProxyIsHealthy(DefaultPort)
 			})
+			s.Run("readiness check ok", func() {
+				resp, err := http.Get(fmt.Sprintf("http://localhost:%s/proxyready", DefaultPort))
+				require.NoError(s.T(), err)
+				defer resp.Body.Close()
+				assert.Equal(s.T(), http.StatusOK, resp.StatusCode)
+				s.assertResponseBody(resp, `{"ready":true}`+"\n")
+			})
 
 			s.checkPlainHTTPErrors(proxy)
 			s.checkWebsocketsError()
+			s.checkUpgradedConnectionsLimit(proxy)
 			s.checkWebLogin()
 			s.checkProxyOK(proxy)
+			s.checkProxyRoute(proxy)
 		})
 	}
 }
@@ -250,6 +263,26 @@ func (s *TestProxySuite) checkPlainHTTPErrors(proxy *Proxy) {
 			s.assertResponseBody(resp, "invalid bearer token: unable to extract claims from token: token does not comply to expected claims: email missing")
 		})
 
+		s.Run("forbidden if the email is not verified", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_REQUIRE_VERIFIED_EMAIL", "true")
+			req, err := http.NewRequest("GET", "http://localhost:8081/api/mycoolworkspace/pods", nil)
+			require.NoError(s.T(), err)
+			req.Header.Set("Authorization", "Bearer "+s.token("unverified-user", func(token *jwt.Token) {
+				token.Claims.(*authsupport.MyClaims).EmailVerified = false
+			}))
+
+			// when
+			resp, err := http.DefaultClient.Do(req)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), resp)
+			defer resp.Body.Close()
+			assert.Equal(s.T(), http.StatusForbidden, resp.StatusCode)
+			s.assertResponseBody(resp, "email not verified: the email address of your account is not verified: please verify it with your identity provider, then log in again")
+		})
+
 		s.Run("unauthorized if workspace context is invalid", func() {
 			// when
 			req := s.request()
@@ -485,6 +518,82 @@ func (s *TestProxySuite) checkWebLogin() {
 				}
 			})
 		}
+
+		s.Run("additional realms", func() {
+			// given
+			partnersServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				_, err := w.Write([]byte("partners " + r.URL.Path))
+				assert.NoError(s.T(), err)
+			}))
+			defer partnersServer.Close()
+			s.T().Setenv("REGISTRATION_SERVICE_AUTH_REALMS", fmt.Sprintf(`[{"name":"partners","ssoBaseURL":%q,"hosts":["proxy.partners.example.com"]}]`, partnersServer.URL))
+
+			tests := map[string]struct {
+				RequestURL         string
+				Host               string
+				ExpectedStatusCode int
+				ExpectedLocation   string
+				ExpectedResponse   string
+			}{
+				"well-known configuration request for the host of the realm": {
+					RequestURL:         "http://localhost:8081/.well-known/oauth-authorization-server",
+					Host:               "proxy.partners.example.com:8081",
+					ExpectedStatusCode: http.StatusOK,
+					ExpectedResponse:   "partners /auth/realms/partners/.well-known/openid-configuration",
+				},
+				"well-known configuration request for another host": {
+					RequestURL:         "http://localhost:8081/.well-known/oauth-authorization-server",
+					Host:               "proxy.example.com",
+					ExpectedStatusCode: http.StatusOK,
+					ExpectedResponse:   "mock SSO configuration",
+				},
+				"oidc": {
+					RequestURL:         "http://localhost:8081/auth/realms/partners/protocol/openid-connect/auth?state=mystate",
+					ExpectedStatusCode: http.StatusSeeOther,
+					ExpectedLocation:   partnersServer.URL + "/auth/realms/partners/protocol/openid-connect/auth?state=mystate",
+				},
+				"auth requests of the realm": {
+					RequestURL:         "http://localhost:8081/auth/realms/partners/protocol/openid-connect/token",
+					ExpectedStatusCode: http.StatusOK,
+					ExpectedResponse:   "partners /auth/realms/partners/protocol/openid-connect/token",
+				},
+				"other auth requests for the host of the realm": {
+					RequestURL:         "http://localhost:8081/auth/anything",
+					Host:               "proxy.partners.example.com",
+					ExpectedStatusCode: http.StatusOK,
+					ExpectedResponse:   "partners /auth/anything",
+				},
+			}
+			for k, tc := range tests {
+				s.Run(k, func() {
+					client := &http.Client{
+						CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+							return http.ErrUseLastResponse
+						}}
+					req, err := http.NewRequest(http.MethodGet, tc.RequestURL, nil)
+					require.NoError(s.T(), err)
+					if tc.Host != "" {
+						req.Host = tc.Host
+					}
+
+					// when
+					resp, err := client.Do(req)
+
+					// then
+					require.NoError(s.T(), err)
+					require.NotNil(s.T(), resp)
+					defer resp.Body.Close()
+					assert.Equal(s.T(), tc.ExpectedStatusCode, resp.StatusCode)
+					if tc.ExpectedResponse != "" {
+						s.assertResponseBody(resp, tc.ExpectedResponse)
+					}
+					if tc.ExpectedLocation != "" {
+						assert.Equal(s.T(), tc.ExpectedLocation, resp.Header.Get("Location"))
+					}
+				})
+			}
+		})
 	})
 }
 
@@ -895,6 +1004,114 @@ func (s *TestProxySuite) checkProxyOK(proxy *Proxy) {
 	})
 }
 
+func (s *TestProxySuite) checkProxyRoute(proxy *Proxy) {
+	s.Run("dry-run proxy route", func() {
+		// given
+		memberURL := "https://api.endpoint.member-2.com:6443"
+		proxy.signupService = fake.NewSignupService(&signup.Signup{
+			Name:              "smith2",
+			APIEndpoint:       memberURL,
+			ClusterName:       "member-2",
+			CompliantUsername: "smith2",
+			Username:          "smith2@",
+			Status: signup.Status{
+				Ready: true,
+			},
+		})
+		proxyPlugin := &toolchainv1alpha1.ProxyPlugin{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: commontest.HostOperatorNs,
+				Name:      "myplugin",
+			},
+			Spec: toolchainv1alpha1.ProxyPluginSpec{
+				OpenShiftRouteTargetEndpoint: &toolchainv1alpha1.OpenShiftRouteTarget{
+					Namespace: commontest.MemberOperatorNs,
+					Name:      "proxy-plugin",
+				},
+			},
+		}
+		require.NoError(s.T(), routev1.Install(scheme.Scheme))
+		proxy.Client.Client = commontest.NewFakeClient(s.T(),
+			fake.NewSpace("mycoolworkspace", "member-2", "smith2"),
+			fake.NewSpaceBinding("mycoolworkspace-smith2", "smith2", "mycoolworkspace", "admin"),
+			proxyPlugin,
+			fake.NewBase1NSTemplateTier())
+		proxy.getMembersFunc = s.newMemberClustersFunc(memberURL)
+		proxy.pluginEndpoints = NewPluginEndpoints()
+		proxy.spaceLister = &handlers.SpaceLister{
+			Client:        proxy.Client,
+			GetSignupFunc: proxy.signupService.GetSignup,
+			ProxyMetrics:  proxy.metrics,
+		}
+
+		tests := map[string]struct {
+			query          string
+			expectedStatus int
+			expectedRoute  Route
+		}{
+			"home workspace": {
+				query:          "path=/api/v1/pods",
+				expectedStatus: http.StatusOK,
+				expectedRoute: Route{
+					TargetCluster:   "member-2",
+					APIEndpoint:     memberURL,
+					Path:            "/api/v1/pods",
+					ImpersonateUser: "smith2",
+				},
+			},
+			"explicit workspace": {
+				query:          "workspace=mycoolworkspace&path=/api/v1/namespaces/smith2-dev/pods",
+				expectedStatus: http.StatusOK,
+				expectedRoute: Route{
+					Workspace:       "mycoolworkspace",
+					TargetCluster:   "member-2",
+					APIEndpoint:     memberURL,
+					Path:            "/api/v1/namespaces/smith2-dev/pods",
+					ImpersonateUser: "smith2",
+				},
+			},
+			"proxy plugin": {
+				query:          "workspace=mycoolworkspace&path=/plugins/myplugin/api/v1/pods",
+				expectedStatus: http.StatusOK,
+				expectedRoute: Route{
+					Workspace:       "mycoolworkspace",
+					ProxyPlugin:     "myplugin",
+					TargetCluster:   "member-2",
+					APIEndpoint:     "http://api.endpoint.member-2.com:6443",
+					Path:            "/api/v1/pods",
+					ImpersonateUser: "smith2",
+				},
+			},
+			"workspace without access": {
+				query:          "workspace=not-existing-workspace&path=/api/v1/pods",
+				expectedStatus: http.StatusInternalServerError,
+			},
+		}
+
+		for k, tc := range tests {
+			s.Run(k, func() {
+				req, err := http.NewRequest(http.MethodGet, "http://localhost:8081/proxyroute?"+tc.query, nil)
+				require.NoError(s.T(), err)
+				req.Header.Set("Authorization", "Bearer "+s.token("smith2"))
+
+				// when
+				client := http.Client{Timeout: 3 * time.Second}
+				resp, err := client.Do(req)
+
+				// then
+				require.NoError(s.T(), err)
+				defer resp.Body.Close()
+				require.Equal(s.T(), tc.expectedStatus, resp.StatusCode)
+				if tc.expectedStatus == http.StatusOK {
+					route := Route{}
+					require.NoError(s.T(), json.NewDecoder(resp.Body).Decode(&route))
+					assert.Equal(s.T(), tc.expectedRoute, route)
+				}
+			})
+		}
+	})
+}
+
 type headerToAdd struct {
 	key, value string
 }
@@ -976,8 +1193,205 @@ func (s *TestProxySuite) TestSingleJoiningSlash() {
 	assert.Equal(s.T(), "/proxy/subpath/api/namespace/pods/", singleJoiningSlash("/proxy/subpath/", "/api/namespace/pods/"))
 }
 
+func (s *TestProxySuite) TestDryRunPath() {
+	tests := map[string]struct {
+		workspace, path, expected string
+	}{
+		"home workspace":                {path: "/api/v1/pods", expected: "/api/v1/pods"},
+		"no leading slash":              {path: "api/v1/pods", expected: "/api/v1/pods"},
+		"workspace":                     {workspace: "ws", path: "/api/v1/pods", expected: "/workspaces/ws/api/v1/pods"},
+		"workspace without path":        {workspace: "ws", expected: "/workspaces/ws/"},
+		"plugin in home workspace":      {path: "/plugins/myplugin/api/v1/pods", expected: "/plugins/myplugin/api/v1/pods"},
+		"plugin in workspace":           {workspace: "ws", path: "/plugins/myplugin/api/v1/pods", expected: "/plugins/myplugin/workspaces/ws/api/v1/pods"},
+		"plugin in workspace, no path":  {workspace: "ws", path: "/plugins/myplugin", expected: "/plugins/myplugin/workspaces/ws/"},
+		"workspace already in the path": {path: "/workspaces/ws/api/v1/pods", expected: "/workspaces/ws/api/v1/pods"},
+	}
+	for name, tc := range tests {
+		s.Run(name, func() {
+			assert.Equal(s.T(), tc.expected, dryRunPath(tc.workspace, tc.path))
+		})
+	}
+}
+
+func (s *TestProxySuite) TestSetForwardedHeaders() {
+	_, trustedRange, err := net.ParseCIDR("10.128.0.0/14")
+	require.NoError(s.T(), err)
+	trustedProxies := []*net.IPNet{trustedRange}
+
+	newRequest := func(remoteAddr string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/v1/pods", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "api.sandbox.example.com")
+		req.Header.Set("Forwarded", "for=1.2.3.4")
+		req.Header.Set("X-Real-Ip", "1.2.3.4")
+		return req
+	}
+
+	s.Run("headers set by a trusted proxy are kept", func() {
+		// given
+		req := newRequest("10.128.0.1:43210")
+
+		// when
+		setForwardedHeaders(req, req.Host, trustedProxies)
+
+		// then
+		assert.Equal(s.T(), "1.2.3.4", req.Header.Get("X-Forwarded-For"))
+		assert.Equal(s.T(), "https", req.Header.Get("X-Forwarded-Proto"))
+		assert.Equal(s.T(), "api.sandbox.example.com", req.Header.Get("X-Forwarded-Host"))
+		assert.Equal(s.T(), "for=1.2.3.4", req.Header.Get("Forwarded"))
+		assert.Equal(s.T(), "1.2.3.4", req.Header.Get("X-Real-Ip"))
+	})
+
+	for name, tc := range map[string]struct {
+		remoteAddr     string
+		trustedProxies []*net.IPNet
+	}{
+		"untrusted client":         {remoteAddr: "192.168.1.1:43210", trustedProxies: trustedProxies},
+		"untrusted client in IPv6": {remoteAddr: "[fd00::1]:43210", trustedProxies: trustedProxies},
+		"invalid remote address":   {remoteAddr: "unknown", trustedProxies: trustedProxies},
+	} {
+		s.Run("headers spoofed by "+name+" are replaced", func() {
+			// given
+			req := newRequest(tc.remoteAddr)
+
+			// when
+			setForwardedHeaders(req, req.Host, tc.trustedProxies)
+
+			// then
+			assert.Empty(s.T(), req.Header.Get("X-Forwarded-For")) // appended by the reverse proxy
+			assert.Equal(s.T(), "http", req.Header.Get("X-Forwarded-Proto"))
+			assert.Equal(s.T(), "proxy.example.com", req.Header.Get("X-Forwarded-Host"))
+			assert.Empty(s.T(), req.Header.Get("Forwarded"))
+			assert.Empty(s.T(), req.Header.Get("X-Real-Ip"))
+		})
+	}
+
+	s.Run("headers kept when there is no trusted proxy at all", func() {
+		// given
+		req := newRequest("10.128.0.1:43210")
+
+		// when
+		setForwardedHeaders(req, req.Host, nil)
+
+		// then
+		assert.Equal(s.T(), "1.2.3.4", req.Header.Get("X-Forwarded-For"))
+		assert.Equal(s.T(), "https", req.Header.Get("X-Forwarded-Proto"))
+		assert.Equal(s.T(), "api.sandbox.example.com", req.Header.Get("X-Forwarded-Host"))
+		assert.Equal(s.T(), "for=1.2.3.4", req.Header.Get("Forwarded"))
+		assert.Equal(s.T(), "1.2.3.4", req.Header.Get("X-Real-Ip"))
+	})
+
+	s.Run("proto of a TLS request", func() {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "https://proxy.example.com/api/v1/pods", nil)
+
+		// when
+		setForwardedHeaders(req, req.Host, trustedProxies)
+
+		// then
+		assert.Equal(s.T(), "https", req.Header.Get("X-Forwarded-Proto"))
+	})
+}
+
+func (s *TestProxySuite) TestRequestScheme() {
+	_, trustedRange, err := net.ParseCIDR("10.128.0.0/14")
+	require.NoError(s.T(), err)
+	trustedProxies := []*net.IPNet{trustedRange}
+
+	for name, tc := range map[string]struct {
+		url            string
+		remoteAddr     string
+		trustedProxies []*net.IPNet
+		expected       string
+	}{
+		"header set by a trusted proxy":         {url: "http://proxy.example.com", remoteAddr: "10.128.0.1:43210", trustedProxies: trustedProxies, expected: "https"},
+		"header spoofed by an untrusted client": {url: "http://proxy.example.com", remoteAddr: "192.168.1.1:43210", trustedProxies: trustedProxies, expected: "http"},
+		"header ignored without trusted proxy":  {url: "http://proxy.example.com", remoteAddr: "10.128.0.1:43210", expected: "http"},
+		"TLS request without trusted proxy":     {url: "https://proxy.example.com", remoteAddr: "10.128.0.1:43210", expected: "https"},
+	} {
+		s.Run(name, func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-Proto", "https")
+
+			// when
+			scheme := requestScheme(req, tc.trustedProxies)
+
+			// then
+			assert.Equal(s.T(), tc.expected, scheme)
+		})
+	}
+}
+
+func (s *TestProxySuite) TestIPExtractor() {
+	_, trustedRange, err := net.ParseCIDR("10.128.0.0/14")
+	require.NoError(s.T(), err)
+
+	tests := map[string]struct {
+		trustedProxies []*net.IPNet
+		remoteAddr     string
+		xff            string
+		expected       string
+	}{
+		"request from a trusted proxy": {
+			trustedProxies: []*net.IPNet{trustedRange},
+			remoteAddr:     "10.128.0.1:43210",
+			xff:            "1.2.3.4",
+			expected:       "1.2.3.4",
+		},
+		"spoofed header behind a trusted proxy": {
+			trustedProxies: []*net.IPNet{trustedRange},
+			remoteAddr:     "10.128.0.1:43210",
+			xff:            "6.6.6.6, 1.2.3.4",
+			expected:       "1.2.3.4",
+		},
+		"request from an untrusted client": {
+			trustedProxies: []*net.IPNet{trustedRange},
+			remoteAddr:     "192.168.1.1:43210",
+			xff:            "1.2.3.4",
+			expected:       "192.168.1.1",
+		},
+	}
+	for name, tc := range tests {
+		s.Run(name, func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/v1/pods", nil)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", tc.xff)
+
+			// when
+			ip := ipExtractor(tc.trustedProxies)(req)
+
+			// then
+			assert.Equal(s.T(), tc.expected, ip)
+		})
+	}
+
+	s.Run("no trusted proxy", func() {
+		// given
+		router := echo.New()
+		router.IPExtractor = ipExtractor(nil)
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/v1/pods", nil)
+		req.RemoteAddr = "10.128.0.1:43210"
+		req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+		// when
+		ip := router.NewContext(req, httptest.NewRecorder()).RealIP()
+
+		// then
+		assert.Nil(s.T(), router.IPExtractor)
+		assert.Equal(s.T(), "1.2.3.4", ip) // the header set by the OpenShift router is used, as before
+	})
+}
+
 func (s *TestProxySuite) TestGetWorkspaceContext() {
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_DOMAINS", "proxy.example.com")
 	tests := map[string]struct {
+		host              string
+		header            string
 		path              string
 		expectedWorkspace string
 		expectedPath      string
@@ -1042,6 +1456,24 @@ func (s *TestProxySuite) TestGetWorkspaceContext() {
 			expectedErr:       "path \"/plugins/\" not a proxied route request",
 			expectedPlugin:    "",
 		},
+		"empty plugin segment": {
+			path:              "/plugins//api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+			expectedErr:       "",
+			expectedPlugin:    "",
+		},
+		"empty workspace segment": {
+			path:              "/workspaces//api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+			expectedErr:       "",
+		},
+		"workspace context with trailing slash": {
+			path:              "/workspaces/myworkspace/",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/",
+		},
 		"plugin spec but nothing else": {
 			path:              "/plugins/whatever",
 			expectedWorkspace: "",
@@ -1084,24 +1516,114 @@ func (s *TestProxySuite) TestGetWorkspaceContext() {
 			expectedErr:       "",
 			expectedPlugin:    "tekton-results",
 		},
+		"workspace context from the host": {
+			host:              "myworkspace.proxy.example.com",
+			path:              "/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+		},
+		"workspace context from the host with a port": {
+			host:              "MyWorkspace.Proxy.Example.com:443",
+			path:              "/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+		},
+		"workspace context from the host with plugin": {
+			host:              "myworkspace.proxy.example.com",
+			path:              "/plugins/tekton-results/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+			expectedPlugin:    "tekton-results",
+		},
+		"same workspace context from the host and the path": {
+			host:              "myworkspace.proxy.example.com",
+			path:              "/workspaces/myworkspace/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+		},
+		"different workspace contexts from the host and the path": {
+			host:              "myworkspace.proxy.example.com",
+			path:              "/workspaces/otherworkspace/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+			expectedErr:       "the workspace 'otherworkspace' of the request path doesn't match the workspace 'myworkspace' of the host",
+		},
+		"no workspace context from the host of the domain": {
+			host:              "proxy.example.com",
+			path:              "/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+		},
+		"no workspace context from a nested subdomain": {
+			host:              "my.workspace.proxy.example.com",
+			path:              "/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+		},
+		"no workspace context from another domain": {
+			host:              "myworkspace.other.example.com",
+			path:              "/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+		},
+		"workspace context from the header": {
+			header:            "myworkspace",
+			path:              "/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+		},
+		"workspace context from the header with plugin": {
+			header:            "myworkspace",
+			path:              "/plugins/tekton-results/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+			expectedPlugin:    "tekton-results",
+		},
+		"same workspace context from the header and the path": {
+			header:            "myworkspace",
+			path:              "/workspaces/myworkspace/api/pods",
+			expectedWorkspace: "myworkspace",
+			expectedPath:      "/api/pods",
+		},
+		"different workspace contexts from the header and the path": {
+			header:            "otherworkspace",
+			path:              "/workspaces/myworkspace/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+			expectedErr:       "the workspace 'otherworkspace' of the X-Workspace header doesn't match the workspace 'myworkspace' of the request",
+		},
+		"different workspace contexts from the header and the host": {
+			host:              "myworkspace.proxy.example.com",
+			header:            "otherworkspace",
+			path:              "/api/pods",
+			expectedWorkspace: "",
+			expectedPath:      "/api/pods",
+			expectedErr:       "the workspace 'otherworkspace' of the X-Workspace header doesn't match the workspace 'myworkspace' of the request",
+		},
 	}
 
 	for k, tc := range tests {
 		s.Run(k, func() {
 			req := &http.Request{
+				Host:   tc.host,
+				Header: http.Header{},
 				URL: &url.URL{
 					Path: tc.path,
 				},
 			}
-			proxy, workspace, err := getWorkspaceContext(req)
+			if tc.header != "" {
+				req.Header.Set(WorkspaceHeader, tc.header)
+			}
+			wc, err := getWorkspaceContext(req)
 			if tc.expectedErr == "" {
 				require.NoErrorf(s.T(), err, "failed for tc %s", k)
 			} else {
 				require.EqualErrorf(s.T(), err, tc.expectedErr, "failed for tc %s", k)
 			}
-			assert.Equalf(s.T(), tc.expectedWorkspace, workspace, "failed for tc %s", k)
+			assert.Equalf(s.T(), tc.expectedWorkspace, wc.workspace, "failed for tc %s", k)
 			assert.Equalf(s.T(), tc.expectedPath, req.URL.Path, "failed for tc %s", k)
-			assert.Equalf(s.T(), tc.expectedPlugin, proxy, "failed for tc %s", k)
+			assert.Equalf(s.T(), tc.expectedPlugin, wc.proxyPluginName, "failed for tc %s", k)
+			assert.Emptyf(s.T(), req.Header.Get(WorkspaceHeader), "failed for tc %s", k)
 		})
 	}
 }
@@ -1193,7 +1715,7 @@ func (s *TestProxySuite) TestGetTransport() {
 					Environment(string(envName)))
 
 				// when
-				transport := getTransport(map[string][]string{})
+				transport := getTransport(map[string][]string{}, nil)
 
 				// then
 				expectedTransport := noTimeoutDefaultTransport()
@@ -1218,7 +1740,7 @@ func (s *TestProxySuite) TestGetTransport() {
 			transport := getTransport(map[string][]string{
 				"Connection": {"Upgrade"},
 				"Upgrade":    {"SPDY/3.1"},
-			})
+			}, nil)
 
 			// then
 			expectedTransport := noTimeoutDefaultTransport().Clone()
@@ -1233,7 +1755,7 @@ func (s *TestProxySuite) TestGetTransport() {
 			transport := getTransport(map[string][]string{
 				"Connection": {"Upgrade"},
 				"Upgrade":    {"websocket"},
-			})
+			}, nil)
 
 			// then
 			assertTransport(s.T(), noTimeoutDefaultTransport(), transport)
@@ -1241,13 +1763,51 @@ func (s *TestProxySuite) TestGetTransport() {
 
 		s.Run("no upgrade header is set", func() {
 			// when
-			transport := getTransport(map[string][]string{})
+			transport := getTransport(map[string][]string{}, nil)
 
 			// then
 			assertTransport(s.T(), noTimeoutDefaultTransport(), transport)
 		})
 	})
 
+	s.Run("with plugin TLS config", func() {
+		// given
+		tlsConfig := &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			ServerName: "plugin.example.com",
+		}
+
+		s.Run("TLS config is used instead of the environment-based policy", func() {
+			// when
+			transport := getTransport(map[string][]string{}, tlsConfig)
+
+			// then
+			expectedTransport := noTimeoutDefaultTransport()
+			expectedTransport.TLSClientConfig = tlsConfig
+			assertTransport(s.T(), expectedTransport, transport)
+		})
+
+		s.Run("upgrade header is set to 'SPDY/3.1'", func() {
+			// when
+			transport := getTransport(map[string][]string{
+				"Connection": {"Upgrade"},
+				"Upgrade":    {"SPDY/3.1"},
+			}, tlsConfig)
+
+			// then
+			expectedTransport := noTimeoutDefaultTransport()
+			expectedTransport.TLSClientConfig = &tls.Config{
+				MinVersion: tls.VersionTLS12,
+				ServerName: "plugin.example.com",
+				NextProtos: []string{"http/1.1"},
+			}
+			expectedTransport.ForceAttemptHTTP2 = false
+			assertTransport(s.T(), expectedTransport, transport)
+			// the given TLS config is not modified
+			assert.Empty(s.T(), tlsConfig.NextProtos)
+		})
+	})
+
 	s.Run("default transport should be same except for DailContext", func() {
 		// when
 		transport := http.DefaultTransport.(interface {
@@ -1304,9 +1864,33 @@ func (s *TestProxySuite) token(username string, extraClaims ...authsupport.Extra
 	return token
 }
 
+// assertResponseBody asserts that the body of the response is the expected one or, for the errors of the proxy
+// returned as a Status, that the message of the Status is the expected one
 func (s *TestProxySuite) assertResponseBody(resp *http.Response, expectedBody string) {
 	buf := new(bytes.Buffer)
 	_, err := buf.ReadFrom(resp.Body)
 	require.NoError(s.T(), err)
+	status := &metav1.Status{}
+	if resp.StatusCode >= http.StatusBadRequest && json.Unmarshal(buf.Bytes(), status) == nil && status.Kind == "Status" {
+		assert.Equal(s.T(), int32(resp.StatusCode), status.Code) // nolint:gosec
+		assert.Equal(s.T(), expectedBody, status.Message)
+		return
+	}
 	assert.Equal(s.T(), expectedBody, buf.String())
 }
+
+func BenchmarkGetWorkspaceContext(b *testing.B) {
+	paths := []string{
+		"/api/v1/namespaces/myns/pods",
+		"/workspaces/mycoolworkspace/api/v1/namespaces/myns/pods",
+		"/plugins/tekton-results/workspaces/mycoolworkspace/apis/results.tekton.dev/v1alpha2/parents/myns/results",
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		req.URL.Path = paths[i%len(paths)]
+		if _, err := getWorkspaceContext(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
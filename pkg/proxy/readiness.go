@@ -0,0 +1,39 @@
+package proxy
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// checkReady returns an error if the proxy is not ready to serve requests yet, ie. until the public keys used to validate
+// the tokens are loaded and the cache of the member clusters is synced. Once ready, the proxy remains ready.
+func (p *Proxy) checkReady() error {
+	if p.ready.Load() {
+		return nil
+	}
+	if !p.tokenParser.KeysLoaded() {
+		return errors.New("the public keys used to validate the tokens are not loaded")
+	}
+	if len(p.getMembersFunc()) == 0 {
+		return errors.New("the cache of the member clusters is not synced")
+	}
+	p.ready.Store(true)
+	return nil
+}
+
+// Ready is the handler of the readiness endpoint, which only reports the proxy as ready once it can serve requests,
+// so that no traffic is routed to a replica which would fail every request. Unlike the health endpoint,
+// it should be used by the readiness probes.
+func (p *Proxy) Ready(ctx echo.Context) error {
+	if err := p.checkReady(); err != nil {
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]interface{}{
+			"ready":  false,
+			"reason": err.Error(),
+		})
+	}
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"ready": true,
+	})
+}
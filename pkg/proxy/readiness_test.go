@@ -0,0 +1,81 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	proxytest "github.com/codeready-toolchain/registration-service/pkg/proxy/test"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestReady() {
+	// given
+	env := s.DefaultConfig().Environment()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.E2E))) // the e2e-tests environment loads the e2e public keys
+	keyManager, err := auth.NewKeyManager()
+	require.NoError(s.T(), err)
+	withKeys, err := auth.NewTokenParser(keyManager)
+	require.NoError(s.T(), err)
+	noKeys, err := auth.NewTokenParser(&auth.KeyManager{})
+	require.NoError(s.T(), err)
+	members := proxytest.NewGetMembersFunc(commontest.NewFakeClient(s.T()))
+	noMembers := func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+		return nil
+	}
+	ready := func(p *Proxy) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, proxyReadyEndpoint, nil), rec)
+		require.NoError(s.T(), p.Ready(ctx))
+		return rec
+	}
+
+	s.Run("keys not loaded", func() {
+		// when
+		rec := ready(&Proxy{tokenParser: noKeys, getMembersFunc: members})
+
+		// then
+		assert.Equal(s.T(), http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(s.T(), `{"ready": false, "reason": "the public keys used to validate the tokens are not loaded"}`, rec.Body.String())
+	})
+
+	s.Run("member clusters cache not synced", func() {
+		// when
+		rec := ready(&Proxy{tokenParser: withKeys, getMembersFunc: noMembers})
+
+		// then
+		assert.Equal(s.T(), http.StatusServiceUnavailable, rec.Code)
+		assert.JSONEq(s.T(), `{"ready": false, "reason": "the cache of the member clusters is not synced"}`, rec.Body.String())
+	})
+
+	s.Run("ready", func() {
+		// given
+		p := &Proxy{tokenParser: withKeys, getMembersFunc: members}
+
+		// when
+		rec := ready(p)
+
+		// then
+		assert.Equal(s.T(), http.StatusOK, rec.Code)
+		assert.JSONEq(s.T(), `{"ready": true}`, rec.Body.String())
+
+		s.Run("remains ready", func() {
+			// given
+			p.getMembersFunc = noMembers
+
+			// when
+			rec := ready(p)
+
+			// then
+			assert.Equal(s.T(), http.StatusOK, rec.Code)
+		})
+	})
+}
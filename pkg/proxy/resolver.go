@@ -0,0 +1,43 @@
+package proxy
+
+import (
+	gocontext "context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dnsServerDialTimeout is the timeout of the connections to each of the configured DNS servers
+const dnsServerDialTimeout = 5 * time.Second
+
+// resolvingDialer returns the function dialing the member clusters (and the proxy plugin backends) with the given host overrides
+// and DNS servers (see the HostOverrides and DNSServers settings of the proxy), or nil if none is configured, so that the
+// default dialer is kept. Only the dialed address changes: the TLS certificates are still verified against the original host.
+func resolvingDialer(hostOverrides map[string]string, dnsServers []string) func(ctx gocontext.Context, network, addr string) (net.Conn, error) {
+	if len(hostOverrides) == 0 && len(dnsServers) == 0 {
+		return nil
+	}
+	dialer := &net.Dialer{}
+	if len(dnsServers) > 0 {
+		serverDialer := &net.Dialer{Timeout: dnsServerDialTimeout}
+		next := atomic.Uint32{}
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			// the configured DNS servers are used in turn instead of the ones of the system, so that the queries retried
+			// by the resolver after a failure are sent to another DNS server
+			Dial: func(ctx gocontext.Context, network, _ string) (net.Conn, error) {
+				server := dnsServers[int(next.Add(1)-1)%len(dnsServers)]
+				return serverDialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return func(ctx gocontext.Context, network, addr string) (net.Conn, error) {
+		if host, port, err := net.SplitHostPort(addr); err == nil {
+			if override, found := hostOverrides[strings.ToLower(host)]; found {
+				addr = net.JoinHostPort(override, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
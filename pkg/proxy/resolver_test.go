@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	gocontext "context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestResolvingDialer() {
+	// the member cluster listening on the loopback interface
+	member, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(s.T(), err)
+	defer member.Close()
+	go func() {
+		for {
+			conn, err := member.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(member.Addr().String())
+	require.NoError(s.T(), err)
+
+	s.Run("default dialer kept when nothing is configured", func() {
+		assert.Nil(s.T(), resolvingDialer(map[string]string{}, []string{}))
+		assert.Equal(s.T(), reflect.ValueOf(noTimeoutDialerProxy).Pointer(), reflect.ValueOf(getTransport(http.Header{}, nil).DialContext).Pointer())
+	})
+
+	s.Run("host overridden", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_HOST_OVERRIDES", "api.member-1.example.test=127.0.0.1")
+		dialContext := getTransport(http.Header{}, nil).DialContext
+
+		// when
+		conn, err := dialContext(gocontext.Background(), "tcp", net.JoinHostPort("API.member-1.example.test", port))
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.Equal(s.T(), member.Addr().String(), conn.RemoteAddr().String())
+	})
+
+	s.Run("host resolved with the configured DNS servers", func() {
+		// given
+		dnsServer := s.startDNSServer(net.IPv4(127, 0, 0, 1))
+		defer dnsServer.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_DNS_SERVERS", dnsServer.LocalAddr().String())
+		dialContext := getTransport(http.Header{}, nil).DialContext
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), 10*time.Second)
+		defer cancel()
+
+		// when
+		conn, err := dialContext(ctx, "tcp", net.JoinHostPort("api.member-1.internal.test", port))
+
+		// then
+		require.NoError(s.T(), err)
+		defer conn.Close()
+		assert.Equal(s.T(), member.Addr().String(), conn.RemoteAddr().String())
+	})
+}
+
+// startDNSServer starts a UDP DNS server answering the A queries with the given IPv4 address, and the other queries with no answer
+func (s *TestProxySuite) startDNSServer(ip net.IP) net.PacketConn {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(s.T(), err)
+	go func() {
+		query := make([]byte, 512)
+		for {
+			n, addr, err := server.ReadFrom(query)
+			if err != nil {
+				return
+			}
+			// the question starts after the 12 bytes of the header, and ends with its type and class after the name
+			end := 12
+			for end < n && query[end] != 0 {
+				end += int(query[end]) + 1
+			}
+			end += 5
+			if end > n {
+				continue
+			}
+			answer := append([]byte{}, query[:end]...)
+			binary.BigEndian.PutUint16(answer[2:], 0x8180) // response, recursion desired and available
+			binary.BigEndian.PutUint16(answer[8:], 0)      // no authority
+			binary.BigEndian.PutUint16(answer[10:], 0)     // no additional record
+			if qtype := binary.BigEndian.Uint16(query[end-4:]); qtype == 1 {
+				binary.BigEndian.PutUint16(answer[6:], 1)
+				// the name pointing to the one of the question, type A, class IN, TTL, length and address
+				answer = append(answer, 0xC0, 0x0C, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+				answer = append(answer, ip.To4()...)
+			} else {
+				binary.BigEndian.PutUint16(answer[6:], 0)
+			}
+			_, _ = server.WriteTo(answer, addr)
+		}
+	}()
+	return server
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+// revocationsEndpoint is the admin endpoint of the proxy to revoke tokens (with '<endpoint>/tokens/<jti>') and subjects
+// (with '<endpoint>/subjects/<sub>') at runtime. The revocations apply to the registration service as well, since they share
+// the TokenParser. They are not persisted, and only apply to the replica of the proxy they were made on, unless the caches are
+// shared with the other replicas (see SharedCache).
+const revocationsEndpoint = "/proxyadmin/revocations"
+
+// revocationOf returns the revocation of the kind and the value of the path, ie. 'tokens/<jti>' or 'subjects/<sub>'
+func revocationOf(ctx echo.Context) (auth.Revocation, error) {
+	switch kind, value := ctx.Param("kind"), ctx.Param("value"); kind {
+	case "tokens":
+		return auth.Revocation{TokenID: value}, nil
+	case "subjects":
+		return auth.Revocation{Subject: value}, nil
+	default:
+		return auth.Revocation{}, fmt.Errorf("unknown kind of revocation '%s', expected 'tokens' or 'subjects'", kind)
+	}
+}
+
+// listRevocations returns the revoked tokens and subjects
+func (p *Proxy) listRevocations(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	return ctx.JSON(http.StatusOK, p.tokenParser.Revocations().List())
+}
+
+// revoke revokes the token or the subject of the path, until the optional expiration time of the Revocation of the body
+func (p *Proxy) revoke(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	revocation := auth.Revocation{}
+	if err := json.NewDecoder(ctx.Request().Body).Decode(&revocation); err != nil && !errors.Is(err, io.EOF) {
+		return crterrors.NewBadRequest("invalid revocation request", fmt.Sprintf("unable to decode the revocation: %s", err.Error()))
+	}
+	revoked, err := revocationOf(ctx)
+	if err != nil {
+		return crterrors.NewBadRequest("invalid revocation request", err.Error())
+	}
+	revoked.ExpiresAt = revocation.ExpiresAt
+	if err := p.tokenParser.Revocations().Revoke(revoked); err != nil {
+		return crterrors.NewBadRequest("invalid revocation request", err.Error())
+	}
+	log.InfoEchof(ctx, "revoked '%s'", revoked.Key())
+	if p.sharedCache != nil {
+		if err := p.sharedCache.saveRevocation(ctx.Request().Context(), revoked); err != nil {
+			return crterrors.NewInternalError(err, "the revocation only applies to this replica of the proxy")
+		}
+	}
+	return ctx.JSON(http.StatusOK, revoked)
+}
+
+// liftRevocation lifts the revocation of the token or the subject of the path
+func (p *Proxy) liftRevocation(ctx echo.Context) error {
+	if err := checkProxyAdmin(ctx); err != nil {
+		return err
+	}
+	revocation, err := revocationOf(ctx)
+	if err != nil {
+		return crterrors.NewBadRequest("invalid revocation request", err.Error())
+	}
+	key := revocation.Key()
+	if !p.tokenParser.Revocations().Lift(key) {
+		return crterrors.NewNotFoundError(fmt.Errorf("no revocation of '%s'", key), "revocation not found")
+	}
+	log.InfoEchof(ctx, "lifted the revocation of '%s'", key)
+	if p.sharedCache != nil {
+		if err := p.sharedCache.deleteRevocation(ctx.Request().Context(), key); err != nil {
+			return crterrors.NewInternalError(err, "the revocation was only lifted on this replica of the proxy")
+		}
+	}
+	return ctx.NoContent(http.StatusNoContent)
+}
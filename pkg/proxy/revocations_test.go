@@ -0,0 +1,158 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestRevocationEndpoints() {
+	// given
+	tokenParser, err := auth.NewTokenParser(&auth.KeyManager{})
+	require.NoError(s.T(), err)
+	p := &Proxy{tokenParser: tokenParser}
+	newContext := func(username, method, kind, value, body string) (echo.Context, *httptest.ResponseRecorder) {
+		req := httptest.NewRequest(method, revocationsEndpoint+"/"+kind+"/"+value, strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.SetParamNames("kind", "value")
+		ctx.SetParamValues(kind, value)
+		ctx.Set(context.UsernameKey, username)
+		return ctx, rec
+	}
+	assertError := func(err error, code int) {
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), code, crtErr.Code)
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+
+	s.Run("token revoked by an admin", func() {
+		// given
+		ctx, rec := newContext("admin", http.MethodPut, "tokens", "0a6b5b3e", "")
+
+		// when
+		err := p.revoke(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), http.StatusOK, rec.Code)
+		assert.JSONEq(s.T(), `{"tokenID":"0a6b5b3e"}`, rec.Body.String())
+		assert.True(s.T(), tokenParser.IsRevoked(&auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{ID: "0a6b5b3e", Subject: "smith"}}))
+
+		s.Run("listed", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodGet, "", "", "")
+
+			// when
+			err := p.listRevocations(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			revocations := []auth.Revocation{}
+			require.NoError(s.T(), json.Unmarshal(rec.Body.Bytes(), &revocations))
+			assert.Equal(s.T(), []auth.Revocation{{TokenID: "0a6b5b3e"}}, revocations)
+		})
+
+		s.Run("lifted", func() {
+			// given
+			ctx, rec := newContext("admin", http.MethodDelete, "tokens", "0a6b5b3e", "")
+
+			// when
+			err := p.liftRevocation(ctx)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), http.StatusNoContent, rec.Code)
+			assert.Empty(s.T(), tokenParser.Revocations().List())
+		})
+	})
+
+	s.Run("subject revoked until a given time", func() {
+		// given
+		expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		ctx, _ := newContext("admin", http.MethodPut, "subjects", "smith", `{"expiresAt":"`+expiresAt.Format(time.RFC3339)+`"}`)
+		defer tokenParser.Revocations().Lift("subjects/smith")
+
+		// when
+		err := p.revoke(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		revocations := tokenParser.Revocations().List()
+		require.Len(s.T(), revocations, 1)
+		assert.Equal(s.T(), "smith", revocations[0].Subject)
+		require.NotNil(s.T(), revocations[0].ExpiresAt)
+		assert.True(s.T(), expiresAt.Equal(*revocations[0].ExpiresAt))
+	})
+
+	s.Run("not revoked", func() {
+		s.Run("by a user who is not an admin", func() {
+			// given
+			ctx, _ := newContext("smith", http.MethodPut, "tokens", "0a6b5b3e", "")
+
+			// when
+			err := p.revoke(ctx)
+
+			// then
+			assertError(err, http.StatusForbidden)
+			assert.Empty(s.T(), tokenParser.Revocations().List())
+		})
+
+		s.Run("unknown kind", func() {
+			// given
+			ctx, _ := newContext("admin", http.MethodPut, "users", "smith", "")
+
+			// when
+			err := p.revoke(ctx)
+
+			// then
+			assertError(err, http.StatusBadRequest)
+			assert.Empty(s.T(), tokenParser.Revocations().List())
+		})
+
+		s.Run("invalid body", func() {
+			// given
+			ctx, _ := newContext("admin", http.MethodPut, "tokens", "0a6b5b3e", `{"expiresAt":`)
+
+			// when
+			err := p.revoke(ctx)
+
+			// then
+			assertError(err, http.StatusBadRequest)
+			assert.Empty(s.T(), tokenParser.Revocations().List())
+		})
+	})
+
+	s.Run("not lifted", func() {
+		// given
+		ctx, _ := newContext("admin", http.MethodDelete, "tokens", "0a6b5b3e", "")
+
+		// when
+		err := p.liftRevocation(ctx)
+
+		// then
+		assertError(err, http.StatusNotFound)
+	})
+
+	s.Run("not listed for a user who is not an admin", func() {
+		// given
+		ctx, _ := newContext("smith", http.MethodGet, "", "", "")
+
+		// when
+		err := p.listRevocations(ctx)
+
+		// then
+		assertError(err, http.StatusForbidden)
+	})
+}
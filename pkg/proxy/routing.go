@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+)
+
+// RouteToHeader is the header of the requests which the proxy forwards to the given member cluster instead of the member cluster
+// of the workspace, eg. to check a workspace which is being relocated to another member cluster. The header is only honored for
+// the users allowed with the RoutingAdmins setting, and is never forwarded.
+const RouteToHeader = "X-Route-To"
+
+// routingOverride returns the given target of the request, or the access to another member cluster if the request is routed to it,
+// either with the RouteToHeader of the request, or with the RoutingRules setting for the cohort of the user in the targeted workspace.
+// The user is still impersonated, with the service account of the other member cluster.
+func (p *Proxy) routingOverride(ctx echo.Context, target *access.ClusterAccess, proxyPluginName string) (*access.ClusterAccess, error) {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	username, _ := ctx.Get(context.UsernameKey).(string)
+	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc, WithPluginEndpoints(p.pluginEndpoints), WithImpersonatorTokens(p.impersonatorTokens))
+	if cluster := ctx.Request().Header.Get(RouteToHeader); cluster != "" {
+		ctx.Request().Header.Del(RouteToHeader)
+		if impersonatedBy, _ := ctx.Get(context.ImpersonatedByKey).(string); username == "" || impersonatedBy != "" || !slices.Contains(cfg.RoutingAdmins(), username) {
+			return nil, crterrors.NewForbiddenError("invalid routing override", fmt.Sprintf("the user is not allowed to route the requests with the %s header", RouteToHeader))
+		}
+		if cluster == target.ClusterName() {
+			return target, nil
+		}
+		routed, err := members.accessForClusterName(cluster, target.Username(), proxyPluginName)
+		if err != nil {
+			return nil, crterrors.NewBadRequest("invalid routing override", err.Error())
+		}
+		log.InfoEchof(ctx, "routing the request to the '%s' member cluster with the %s header", cluster, RouteToHeader)
+		return routed, nil
+	}
+	rule, found := cfg.RoutingRules()[targetWorkspace(ctx)]
+	if !found || rule.Cluster == target.ClusterName() || userCohort(username) >= rule.Percentage {
+		return target, nil
+	}
+	routed, err := members.accessForClusterName(rule.Cluster, target.Username(), proxyPluginName)
+	if err != nil {
+		// the request is still forwarded to the member cluster of the workspace when the rule is misconfigured
+		log.Error(nil, err, fmt.Sprintf("unable to route the request to the '%s' member cluster", rule.Cluster))
+		return target, nil
+	}
+	return routed, nil
+}
@@ -0,0 +1,180 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func (s *TestProxySuite) TestRoutingOverride() {
+	// given
+	p := &Proxy{
+		getMembersFunc: func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+			return []*commoncluster.CachedToolchainCluster{
+				{
+					Config: &commoncluster.Config{
+						Name:        "member-3",
+						APIEndpoint: "https://api.member-3.com:6443",
+						RestConfig:  &rest.Config{BearerToken: "member3SAToken"},
+					},
+				},
+			}
+		},
+	}
+	apiURL, err := url.Parse("https://api.member-1.com:6443")
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *apiURL, "clusterSAToken", "smith", nil)
+	newContext := func(username, workspace, routeTo string) echo.Context {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil)
+		if routeTo != "" {
+			req.Header.Set(RouteToHeader, routeTo)
+		}
+		ctx := echo.New().NewContext(req, httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, username)
+		ctx.Set(context.WorkspaceKey, workspace)
+		return ctx
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_ADMINS", "admin")
+
+	s.Run("not routed", func() {
+		// when
+		routed, err := p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Same(s.T(), target, routed)
+	})
+
+	s.Run("routed with the header", func() {
+		s.Run("by an admin", func() {
+			// given
+			ctx := newContext("admin", "smith-dev", "member-3")
+
+			// when
+			routed, err := p.routingOverride(ctx, target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "member-3", routed.ClusterName())
+			assert.Equal(s.T(), "api.member-3.com:6443", routed.APIURL().Host)
+			assert.Equal(s.T(), "member3SAToken", routed.ImpersonatorToken())
+			assert.Equal(s.T(), "smith", routed.Username())
+			assert.Empty(s.T(), ctx.Request().Header.Get(RouteToHeader))
+		})
+
+		s.Run("to the member cluster of the workspace", func() {
+			// when
+			routed, err := p.routingOverride(newContext("admin", "smith-dev", "member-1"), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Same(s.T(), target, routed)
+		})
+
+		s.Run("to an unknown member cluster", func() {
+			// when
+			_, err := p.routingOverride(newContext("admin", "smith-dev", "member-4"), target, "")
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+			assert.Equal(s.T(), "no member cluster found with the name 'member-4'", crtErr.Details)
+		})
+
+		s.Run("not allowed", func() {
+			for name, username := range map[string]string{
+				"not an admin": "smith",
+				"anonymous":    "",
+			} {
+				s.Run(name, func() {
+					// given
+					ctx := newContext(username, "smith-dev", "member-3")
+
+					// when
+					_, err := p.routingOverride(ctx, target, "")
+
+					// then
+					crtErr := &crterrors.Error{}
+					require.ErrorAs(s.T(), err, &crtErr)
+					assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+					assert.Equal(s.T(), "the user is not allowed to route the requests with the X-Route-To header", crtErr.Details)
+					assert.Empty(s.T(), ctx.Request().Header.Get(RouteToHeader))
+				})
+			}
+		})
+	})
+
+	s.Run("routed with the rules", func() {
+		s.Run("all the users", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3:100")
+
+			// when
+			routed, err := p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+			other, err2 := p.routingOverride(newContext("smith", "alice-dev", ""), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), err2)
+			assert.Equal(s.T(), "member-3", routed.ClusterName())
+			assert.Same(s.T(), target, other)
+		})
+
+		s.Run("no user", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3:0")
+
+			// when
+			routed, err := p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Same(s.T(), target, routed)
+		})
+
+		s.Run("depending on the cohort of the user", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3:"+strconv.Itoa(userCohort("smith")+1))
+
+			// when
+			routed, err := p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "member-3", routed.ClusterName())
+
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-3:"+strconv.Itoa(userCohort("smith")))
+
+			// when
+			routed, err = p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Same(s.T(), target, routed)
+		})
+
+		s.Run("unknown member cluster", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_ROUTING_RULES", "smith-dev=member-4:100")
+
+			// when
+			routed, err := p.routingOverride(newContext("smith", "smith-dev", ""), target, "")
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Same(s.T(), target, routed)
+		})
+	})
+}
@@ -0,0 +1,19 @@
+package proxy
+
+import (
+	regsvcmiddleware "github.com/codeready-toolchain/registration-service/pkg/middleware"
+	"github.com/labstack/echo/v4"
+)
+
+// addSecurityHeaders sets the security headers on the responses, just before they are written, so that they replace the ones
+// of the proxied responses
+func (p *Proxy) addSecurityHeaders() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			ctx.Response().Before(func() {
+				regsvcmiddleware.SetSecurityHeaders(ctx.Response().Header())
+			})
+			return next(ctx)
+		}
+	}
+}
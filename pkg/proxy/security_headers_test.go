@@ -0,0 +1,30 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func (s *TestProxySuite) TestAddSecurityHeaders() {
+	// given
+	router := echo.New()
+	router.Pre((&Proxy{}).addSecurityHeaders())
+	router.GET("/api/v1/pods", func(ctx echo.Context) error {
+		// the header of the response of the member cluster
+		ctx.Response().Header().Add("X-Content-Type-Options", "nosniff")
+		return ctx.String(http.StatusOK, "{}")
+	})
+	s.T().Setenv("REGISTRATION_SERVICE_SECURITY_HEADERS_HSTS_MAX_AGE", "1h")
+
+	// when
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil))
+
+	// then
+	assert.Equal(s.T(), []string{"nosniff"}, resp.Header().Values("X-Content-Type-Options"))
+	assert.Equal(s.T(), "no-referrer", resp.Header().Get("Referrer-Policy"))
+	assert.Equal(s.T(), "max-age=3600; includeSubDomains", resp.Header().Get("Strict-Transport-Security"))
+}
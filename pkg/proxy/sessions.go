@@ -0,0 +1,348 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	loginEndpoint         = "/login"
+	loginCallbackEndpoint = "/login/callback"
+	logoutEndpoint        = "/logout"
+	// sessionCookieName is the name of the HttpOnly cookie identifying the sessions of the browsers logged in through the login endpoint.
+	// The cookie is SameSite=Strict, and the responses to the requests with the cookie don't allow any credentialed CORS request
+	// (see responseModifier), so that the other sites can't send requests, nor read their responses, on behalf of the users.
+	sessionCookieName = "sandbox-proxy-session"
+	// loginCookieName is the name of the HttpOnly cookie binding a pending login to the browser which started it, so that the
+	// callback of a login can't be completed by another browser (login CSRF)
+	loginCookieName = "sandbox-proxy-login"
+	// loginTimeout is how long the users have to log in with SSO once redirected by the login endpoint
+	loginTimeout = 10 * time.Minute
+	// sessionsTimeout is the timeout of the requests to the token endpoint of SSO
+	sessionsTimeout = 10 * time.Second
+)
+
+// session is the session of a browser logged in through the login endpoint
+type session struct {
+	token     string
+	expiresAt time.Time
+}
+
+// pendingLogin is a login started by the login endpoint and not completed yet, by the state of its OIDC code flow
+type pendingLogin struct {
+	verifier string
+	// binding is the hash of the value of the login cookie of the browser which started the login
+	binding     [sha256.Size]byte
+	redirectURI string
+	redirect    string
+	realm       configuration.SSORealm
+	expiresAt   time.Time
+}
+
+// Sessions keeps the tokens of the users logged in through the login endpoint (see the LoginClientID setting) server-side, in
+// sessions identified by an HttpOnly cookie, so that the browsers can access the proxy without the tokens being exposed to JS.
+// The sessions are kept in memory, so the browsers are supposed to stick to the same replica of the proxy, as with the default
+// session affinity of the OpenShift routes.
+type Sessions struct {
+	client   *http.Client
+	mu       sync.Mutex
+	sessions map[string]session
+	logins   map[string]pendingLogin
+}
+
+// NewSessions returns a new Sessions, without any session
+func NewSessions() *Sessions {
+	return &Sessions{
+		client:   auth.NewSSOClient(sessionsTimeout),
+		sessions: map[string]session{},
+		logins:   map[string]pendingLogin{},
+	}
+}
+
+// randomString returns a random string of the given number of bytes, encoded in base64url
+func randomString(size int) (string, error) {
+	value := make([]byte, size)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// startLogin stores a new login with the given settings and returns its state, its PKCE verifier and the value of the login
+// cookie it is bound to
+func (s *Sessions) startLogin(login pendingLogin, now time.Time) (string, string, string, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	if login.verifier, err = randomString(32); err != nil {
+		return "", "", "", err
+	}
+	binding, err := randomString(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	login.binding = sha256.Sum256([]byte(binding))
+	login.expiresAt = now.Add(loginTimeout)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for state, login := range s.logins {
+		if !now.Before(login.expiresAt) {
+			delete(s.logins, state)
+		}
+	}
+	s.logins[state] = login
+	return state, login.verifier, binding, nil
+}
+
+// completeLogin returns and deletes the login of the given state, if it is not expired at the given time and bound to the
+// given value of the login cookie
+func (s *Sessions) completeLogin(state, binding string, now time.Time) (pendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	login, found := s.logins[state]
+	delete(s.logins, state)
+	hash := sha256.Sum256([]byte(binding))
+	return login, found && now.Before(login.expiresAt) && subtle.ConstantTimeCompare(hash[:], login.binding[:]) == 1
+}
+
+// create stores a new session with the given token until the given time, and returns its ID
+func (s *Sessions) create(token string, expiresAt, now time.Time) (string, error) {
+	id, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, session := range s.sessions {
+		if !now.Before(session.expiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	s.sessions[id] = session{token: token, expiresAt: expiresAt}
+	return id, nil
+}
+
+// token returns the token of the session of the given request, if any and not expired at the given time
+func (s *Sessions) token(req *http.Request, now time.Time) (string, bool) {
+	cookie, err := req.Cookie(sessionCookieName)
+	if err != nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, found := s.sessions[cookie.Value]
+	if !found || !now.Before(session.expiresAt) {
+		return "", false
+	}
+	return session.token, true
+}
+
+// delete deletes the session of the given request, if any
+func (s *Sessions) delete(req *http.Request) {
+	if cookie, err := req.Cookie(sessionCookieName); err == nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.sessions, cookie.Value)
+	}
+}
+
+// hasSessionCookie returns true if the given request has a session cookie (see Sessions), valid or not
+func hasSessionCookie(req *http.Request) bool {
+	_, err := req.Cookie(sessionCookieName)
+	return err == nil
+}
+
+// removeSessionCookie removes the session cookie (see Sessions) from the given request, so that it's not forwarded to the member clusters
+func removeSessionCookie(req *http.Request) {
+	if !hasSessionCookie(req) {
+		return
+	}
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		if cookie.Name != sessionCookieName {
+			req.AddCookie(cookie)
+		}
+	}
+}
+
+// localRedirect returns the given path if it is a path of the proxy, or '/' otherwise, so that the login and logout endpoints
+// can't be used to redirect the users to another site
+func localRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// setLoginCookie sets the login cookie with the given value and max age on the response, for the callback endpoint only
+func (p *Proxy) setLoginCookie(ctx echo.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Response().Writer, &http.Cookie{
+		Name:     loginCookieName,
+		Value:    value,
+		Path:     loginCallbackEndpoint,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   requestScheme(ctx.Request(), p.trustedProxies) == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// login starts the OIDC code flow (with PKCE) with the SSO realm of the host of the request, and redirects the browser to SSO.
+// The login is bound to the browser with a short-lived login cookie, which the callback requires. The browser is redirected to
+// the path of the optional `redirect` parameter once logged in.
+func (p *Proxy) login(ctx echo.Context) error {
+	clientID := configuration.GetRegistrationServiceConfig().Proxy().LoginClientID()
+	if clientID == "" {
+		return crterrors.NewNotFoundError(errors.New("login endpoint not found"), "the login endpoint is not enabled")
+	}
+	req := ctx.Request()
+	login := pendingLogin{
+		redirectURI: fmt.Sprintf("%s://%s%s", requestScheme(req, p.trustedProxies), req.Host, loginCallbackEndpoint),
+		redirect:    localRedirect(ctx.QueryParam("redirect")),
+		realm:       configuration.GetRegistrationServiceConfig().Auth().RealmByHost(req.Host),
+	}
+	state, verifier, binding, err := p.sessions.startLogin(login, time.Now())
+	if err != nil {
+		return crterrors.NewInternalError(err, "unable to start the login")
+	}
+	p.setLoginCookie(ctx, binding, int(loginTimeout.Seconds()))
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {login.redirectURI},
+		"scope":                 {"openid"},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	return p.redirectTo(ctx, login.realm.Issuer+"/protocol/openid-connect/auth?"+query.Encode())
+}
+
+// loginCallback completes the OIDC code flow started by the login endpoint in the same browser: the code is exchanged for the
+// token of the user, which is kept in a new session, and the browser is redirected with the cookie of the session.
+func (p *Proxy) loginCallback(ctx echo.Context) error {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	if cfg.LoginClientID() == "" {
+		return crterrors.NewNotFoundError(errors.New("login endpoint not found"), "the login endpoint is not enabled")
+	}
+	now := time.Now()
+	binding := ""
+	if cookie, err := ctx.Request().Cookie(loginCookieName); err == nil {
+		binding = cookie.Value
+	}
+	// the login cookie is single-use, like the state
+	p.setLoginCookie(ctx, "", -1)
+	login, found := p.sessions.completeLogin(ctx.QueryParam("state"), binding, now)
+	if !found {
+		return crterrors.NewBadRequest("invalid login", "the login is unknown or expired")
+	}
+	if ssoErr := ctx.QueryParam("error"); ssoErr != "" {
+		return crterrors.NewUnauthorizedError("login failed", fmt.Sprintf("%s: %s", ssoErr, ctx.QueryParam("error_description")))
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {ctx.QueryParam("code")},
+		"redirect_uri":  {login.redirectURI},
+		"client_id":     {cfg.LoginClientID()},
+		"code_verifier": {login.verifier},
+	}
+	if secret := cfg.LoginClientSecret(); secret != "" {
+		form.Set("client_secret", secret)
+	}
+	accessToken, err := p.sessions.exchangeCode(ctx, login.realm, form)
+	if err != nil {
+		log.Error(nil, err, "unable to exchange the code of the login")
+		return crterrors.NewUnauthorizedError("login failed", "unable to exchange the code for a token")
+	}
+	token, err := p.tokenParser.FromString(accessToken)
+	if err != nil {
+		return crterrors.NewUnauthorizedError("login failed", err.Error())
+	}
+	expiresAt := now.Add(cfg.SessionLifetime())
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(expiresAt) {
+		expiresAt = token.ExpiresAt.Time
+	}
+	id, err := p.sessions.create(accessToken, expiresAt, now)
+	if err != nil {
+		return crterrors.NewInternalError(err, "unable to create the session")
+	}
+	log.InfoEchof(ctx, "'%s' logged in until %s", token.PreferredUsername, expiresAt.Format(time.RFC3339))
+	http.SetCookie(ctx.Response().Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    id,
+		Path:     "/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   requestScheme(ctx.Request(), p.trustedProxies) == "https",
+		SameSite: http.SameSiteStrictMode,
+	})
+	return p.redirectTo(ctx, login.redirect)
+}
+
+// logout ends the session of the request, if any, and redirects the browser to the path of the optional `redirect` parameter.
+// Only POST is allowed, so that the users can't be logged out by a cross-site link, and the session cookie is only cleared when it
+// is sent with the request, ie. not by the cross-site requests.
+func (p *Proxy) logout(ctx echo.Context) error {
+	if ctx.Request().Method != http.MethodPost {
+		return crterrors.NewMethodNotAllowedError("method not allowed", "the logout endpoint only accepts POST requests")
+	}
+	if hasSessionCookie(ctx.Request()) {
+		p.sessions.delete(ctx.Request())
+		http.SetCookie(ctx.Response().Writer, &http.Cookie{
+			Name:     sessionCookieName,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   requestScheme(ctx.Request(), p.trustedProxies) == "https",
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	return p.redirectTo(ctx, localRedirect(ctx.QueryParam("redirect")))
+}
+
+// exchangeCode posts the given form to the token endpoint of the given realm, and returns the access token of the response
+func (s *Sessions) exchangeCode(ctx echo.Context, realm configuration.SSORealm, form url.Values) (string, error) {
+	req, err := http.NewRequestWithContext(ctx.Request().Context(), http.MethodPost, realm.Issuer+"/protocol/openid-connect/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("SSO responded with status %d", resp.StatusCode)
+	}
+	body := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.AccessToken == "" {
+		return "", errors.New("no access token in the response of SSO")
+	}
+	return body.AccessToken, nil
+}
@@ -0,0 +1,267 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestSessions() {
+	// given
+	var challenge string
+	ssoToken := s.token("smith")
+	sso := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(s.T(), "/auth/realms/sandbox-dev/protocol/openid-connect/token", r.URL.Path)
+		assert.NoError(s.T(), r.ParseForm())
+		assert.Equal(s.T(), "authorization_code", r.PostForm.Get("grant_type"))
+		assert.Equal(s.T(), "sandbox-proxy", r.PostForm.Get("client_id"))
+		assert.Equal(s.T(), "s3cr3t", r.PostForm.Get("client_secret"))
+		assert.Equal(s.T(), "http://proxy.example.com/login/callback", r.PostForm.Get("redirect_uri"))
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "valid" || base64.RawURLEncoding.EncodeToString(verifier[:]) != challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"` + ssoToken + `","token_type":"Bearer","expires_in":300}`))
+	}))
+	defer sso.Close()
+	env := s.DefaultConfig().Environment()
+	ssoBaseURL := s.DefaultConfig().Auth().SSOBaseURL()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env).
+		Auth().SSOBaseURL(ssoBaseURL))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.E2E)). // the e2e-tests environment loads the e2e public keys
+		Auth().SSOBaseURL(sso.URL))
+	keyManager, err := auth.NewKeyManager()
+	require.NoError(s.T(), err)
+	tokenParser, err := auth.NewTokenParser(keyManager)
+	require.NoError(s.T(), err)
+	p := &Proxy{
+		tokenParser: tokenParser,
+		metrics:     metrics.NewProxyMetrics(prometheus.NewRegistry()),
+		tokenCache:  NewTokenCache(),
+		sessions:    NewSessions(),
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOGIN_CLIENT_ID", "sandbox-proxy")
+	s.SetRegistrationServiceSecret(map[string]string{configuration.ProxyLoginClientSecretKey: "s3cr3t"})
+	call := func(method string, handler echo.HandlerFunc, target string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(method, "http://proxy.example.com"+target, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		return rec, handler(echo.New().NewContext(req, rec))
+	}
+	login := func() (url.Values, *http.Cookie) {
+		rec, err := call(http.MethodGet, p.login, loginEndpoint+"?redirect=/workspaces/smith/api")
+		require.NoError(s.T(), err)
+		require.Equal(s.T(), http.StatusSeeOther, rec.Code)
+		location, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), sso.URL+"/auth/realms/sandbox-dev/protocol/openid-connect/auth", location.Scheme+"://"+location.Host+location.Path)
+		challenge = location.Query().Get("code_challenge")
+		cookies := rec.Result().Cookies()
+		require.Len(s.T(), cookies, 1)
+		return location.Query(), &http.Cookie{Name: cookies[0].Name, Value: cookies[0].Value}
+	}
+	userOf := func(cookies ...*http.Cookie) (*auth.TokenClaims, error) {
+		req := httptest.NewRequest(http.MethodGet, "http://proxy.example.com/api/v1/pods", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		return p.extractUserToken(req)
+	}
+
+	s.Run("login", func() {
+		// when
+		query, loginCookie := login()
+
+		// then
+		assert.Equal(s.T(), loginCookieName, loginCookie.Name)
+		assert.NotEmpty(s.T(), loginCookie.Value)
+		assert.Equal(s.T(), "code", query.Get("response_type"))
+		assert.Equal(s.T(), "sandbox-proxy", query.Get("client_id"))
+		assert.Equal(s.T(), "http://proxy.example.com/login/callback", query.Get("redirect_uri"))
+		assert.Equal(s.T(), "S256", query.Get("code_challenge_method"))
+		assert.NotEmpty(s.T(), query.Get("state"))
+
+		s.Run("callback", func() {
+			// when
+			rec, err := call(http.MethodGet, p.loginCallback, loginCallbackEndpoint+"?code=valid&state="+query.Get("state"), loginCookie)
+
+			// then
+			require.NoError(s.T(), err)
+			require.Equal(s.T(), http.StatusSeeOther, rec.Code)
+			assert.Equal(s.T(), "/workspaces/smith/api", rec.Header().Get("Location"))
+			cookies := rec.Result().Cookies()
+			require.Len(s.T(), cookies, 2)
+			// the login cookie is cleared
+			assert.Equal(s.T(), loginCookieName, cookies[0].Name)
+			assert.Negative(s.T(), cookies[0].MaxAge)
+			cookie := cookies[1]
+			assert.Equal(s.T(), sessionCookieName, cookie.Name)
+			assert.True(s.T(), cookie.HttpOnly)
+			assert.Equal(s.T(), http.SameSiteStrictMode, cookie.SameSite)
+			assert.NotContains(s.T(), cookie.Value, ssoToken)
+
+			s.Run("authenticated with the session", func() {
+				// when
+				user, err := userOf(&http.Cookie{Name: sessionCookieName, Value: cookie.Value})
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "smith", user.PreferredUsername)
+			})
+
+			s.Run("state used once", func() {
+				// when
+				_, err := call(http.MethodGet, p.loginCallback, loginCallbackEndpoint+"?code=valid&state="+query.Get("state"), loginCookie)
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+			})
+
+			s.Run("logout with GET not allowed", func() {
+				// when
+				_, err := call(http.MethodGet, p.logout, logoutEndpoint, &http.Cookie{Name: sessionCookieName, Value: cookie.Value})
+
+				// then
+				crtErr := &crterrors.Error{}
+				require.ErrorAs(s.T(), err, &crtErr)
+				assert.Equal(s.T(), http.StatusMethodNotAllowed, crtErr.Code)
+				user, err := userOf(&http.Cookie{Name: sessionCookieName, Value: cookie.Value})
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), "smith", user.PreferredUsername)
+			})
+
+			s.Run("logout", func() {
+				// when
+				rec, err := call(http.MethodPost, p.logout, logoutEndpoint, &http.Cookie{Name: sessionCookieName, Value: cookie.Value})
+
+				// then
+				require.NoError(s.T(), err)
+				assert.Equal(s.T(), http.StatusSeeOther, rec.Code)
+				assert.Equal(s.T(), "/", rec.Header().Get("Location"))
+				assert.Contains(s.T(), rec.Header().Get("Set-Cookie"), "Max-Age=0")
+				_, err = userOf(&http.Cookie{Name: sessionCookieName, Value: cookie.Value})
+				require.Error(s.T(), err)
+			})
+		})
+	})
+
+	s.Run("callback without the login cookie", func() {
+		// given
+		query, _ := login()
+
+		// when
+		_, err := call(http.MethodGet, p.loginCallback, loginCallbackEndpoint+"?code=valid&state="+query.Get("state"))
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+	})
+
+	s.Run("callback with the login cookie of another login", func() {
+		// given
+		query, _ := login()
+		_, otherLoginCookie := login()
+
+		// when
+		_, err := call(http.MethodGet, p.loginCallback, loginCallbackEndpoint+"?code=valid&state="+query.Get("state"), otherLoginCookie)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusBadRequest, crtErr.Code)
+	})
+
+	s.Run("invalid code", func() {
+		// given
+		query, loginCookie := login()
+
+		// when
+		_, err := call(http.MethodGet, p.loginCallback, loginCallbackEndpoint+"?code=invalid&state="+query.Get("state"), loginCookie)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusUnauthorized, crtErr.Code)
+	})
+
+	s.Run("unknown session", func() {
+		// when
+		_, err := userOf(&http.Cookie{Name: sessionCookieName, Value: "unknown"})
+
+		// then
+		require.Error(s.T(), err)
+	})
+
+	s.Run("disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOGIN_CLIENT_ID", "")
+
+		// when
+		_, err := call(http.MethodGet, p.login, loginEndpoint)
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusNotFound, crtErr.Code)
+	})
+
+	s.Run("session cookie not forwarded", func() {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+		req.AddCookie(&http.Cookie{Name: "other", Value: "a"})
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "b"})
+
+		// when
+		removeSessionCookie(req)
+
+		// then
+		assert.Equal(s.T(), "other=a", req.Header.Get("Cookie"))
+	})
+
+	s.Run("no credentialed CORS request with the session cookie", func() {
+		// given
+		resp := &http.Response{Header: http.Header{"Access-Control-Allow-Credentials": {"true"}}}
+		m := &responseModifier{requestOrigin: "https://evil.example.com", withSession: true}
+
+		// when
+		err := m.addCorsToResponse(resp)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Empty(s.T(), resp.Header.Get("Access-Control-Allow-Credentials"))
+	})
+
+	s.Run("local redirects only", func() {
+		for redirect, expected := range map[string]string{
+			"/workspaces/smith/api": "/workspaces/smith/api",
+			"":                      "/",
+			"https://evil.com":      "/",
+			"//evil.com":            "/",
+			"/\\evil.com":           "/",
+		} {
+			assert.Equal(s.T(), expected, localRedirect(redirect), redirect)
+		}
+	})
+}
@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	gocontext "context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/labstack/echo/v4"
+)
+
+// shadowRequestTimeout is the maximum duration of the requests shadowed to a secondary member cluster
+const shadowRequestTimeout = 30 * time.Second
+
+// shadowRequest is the copy of a read-only request, sent to the secondary member cluster of its workspace (see the ShadowWorkspaces setting)
+type shadowRequest struct {
+	req       *http.Request
+	cluster   string
+	transport http.RoundTripper
+}
+
+// newShadowRequest returns the copy of the read-only request of the given context, to send to the secondary member cluster of its
+// workspace, or nil if the workspace is not shadowed. The streaming requests and the requests to the proxy plugins are not shadowed.
+// The copy must be created before the request is forwarded, since the context can't be used once the request was handled.
+func (p *Proxy) newShadowRequest(ctx echo.Context, target *access.ClusterAccess, isPlugin bool) *shadowRequest {
+	req := ctx.Request()
+	if isPlugin || (req.Method != http.MethodGet && req.Method != http.MethodHead) || isStreamingRequest(req) {
+		return nil
+	}
+	workspace := targetWorkspace(ctx)
+	secondary, found := configuration.GetRegistrationServiceConfig().Proxy().ShadowWorkspaces()[workspace]
+	if workspace == "" || !found || secondary == target.ClusterName() {
+		return nil
+	}
+	members := NewMemberClusters(p.Client, p.signupService, p.getMembersFunc, WithImpersonatorTokens(p.impersonatorTokens))
+	shadow, err := members.accessForClusterName(secondary, target.Username(), "")
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to shadow the request to the '%s' member cluster", secondary))
+		return nil
+	}
+	shadowURL := shadow.APIURL()
+	shadowURL.Path = singleJoiningSlash(shadowURL.Path, req.URL.Path)
+	shadowURL.RawQuery = req.URL.RawQuery
+	shadowReq, err := http.NewRequest(req.Method, shadowURL.String(), nil) // nolint:noctx
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to shadow the request to the '%s' member cluster", secondary))
+		return nil
+	}
+	shadowReq.Header.Set("Accept", req.Header.Get("Accept"))
+	shadowReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	shadowReq.Header.Set("Authorization", "Bearer "+shadow.ImpersonatorToken())
+	shadowReq.Header.Set("Impersonate-User", target.Username())
+	for _, group := range impersonatedGroups(ctx, target) {
+		shadowReq.Header.Add("Impersonate-Group", group)
+	}
+	return &shadowRequest{
+		req:       shadowReq,
+		cluster:   secondary,
+		transport: p.getSharedTransport(shadowReq.Header, 0),
+	}
+}
+
+// send sends the shadow request in the background and discards the response, counting whether its status
+// matches the given status of the response of the primary member cluster
+func (r *shadowRequest) send(proxyMetrics *metrics.ProxyMetrics, primaryStatus int) {
+	go func() {
+		ctx, cancel := gocontext.WithTimeout(gocontext.Background(), shadowRequestTimeout)
+		defer cancel()
+		result := metrics.MetricLabelMatch
+		resp, err := (&http.Client{Transport: r.transport}).Do(r.req.WithContext(ctx))
+		switch {
+		case err != nil:
+			log.Error(nil, err, fmt.Sprintf("unable to shadow the request to the '%s' member cluster", r.cluster))
+			result = metrics.MetricLabelError
+		default:
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != primaryStatus {
+				log.Infof(nil, "the status of the request shadowed to the '%s' member cluster mismatches: %s instead of %s (%s)", r.cluster, strconv.Itoa(resp.StatusCode), strconv.Itoa(primaryStatus), r.req.URL.Path)
+				result = metrics.MetricLabelMismatch
+			}
+		}
+		proxyMetrics.RegServProxyShadowCounterVec.WithLabelValues(r.cluster, result).Inc()
+	}()
+}
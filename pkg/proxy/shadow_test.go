@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+func (s *TestProxySuite) TestShadowRequest() {
+	// given
+	var mu sync.Mutex
+	var shadowed []*http.Request
+	status := http.StatusOK
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		shadowed = append(shadowed, r)
+		w.WriteHeader(status)
+	}))
+	defer secondary.Close()
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+	p := &Proxy{
+		metrics: proxyMetrics,
+		getMembersFunc: func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+			return []*commoncluster.CachedToolchainCluster{
+				{
+					Config: &commoncluster.Config{
+						Name:        "member-3",
+						APIEndpoint: secondary.URL,
+						RestConfig:  &rest.Config{BearerToken: "member3SAToken"},
+					},
+				},
+			}
+		},
+	}
+	apiURL, err := url.Parse("https://api.member-1.com:6443")
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *apiURL, "clusterSAToken", "smith", nil)
+	newContext := func(method, path, workspace string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), httptest.NewRecorder())
+		ctx.Set(context.WorkspaceKey, workspace)
+		return ctx
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_SHADOW_WORKSPACES", "smith-dev=member-3,alice-dev=member-1,bob-dev=unknown")
+
+	s.Run("not shadowed", func() {
+		for name, ctx := range map[string]echo.Context{
+			"workspace not shadowed":     newContext(http.MethodGet, "/api/v1/namespaces/john-dev/pods", "john-dev"),
+			"same member cluster":        newContext(http.MethodGet, "/api/v1/namespaces/alice-dev/pods", "alice-dev"),
+			"unknown member cluster":     newContext(http.MethodGet, "/api/v1/namespaces/bob-dev/pods", "bob-dev"),
+			"mutating request":           newContext(http.MethodPost, "/api/v1/namespaces/smith-dev/pods", "smith-dev"),
+			"streaming request":          newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/pods?watch=true", "smith-dev"),
+			"home workspace not enabled": newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", ""),
+		} {
+			s.Run(name, func() {
+				assert.Nil(s.T(), p.newShadowRequest(ctx, target, false))
+			})
+		}
+		s.Run("proxy plugin", func() {
+			assert.Nil(s.T(), p.newShadowRequest(newContext(http.MethodGet, "/", "smith-dev"), target, true))
+		})
+	})
+
+	s.Run("status matches", func() {
+		// given
+		ctx := newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/pods?limit=500", "smith-dev")
+		ctx.Request().Header.Set("Accept", "application/json")
+		shadow := p.newShadowRequest(ctx, target, false)
+		require.NotNil(s.T(), shadow)
+
+		// when
+		shadow.send(proxyMetrics, http.StatusOK)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return promtestutil.ToFloat64(proxyMetrics.RegServProxyShadowCounterVec.WithLabelValues("member-3", metrics.MetricLabelMatch)) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(s.T(), shadowed, 1)
+		assert.Equal(s.T(), "/api/v1/namespaces/smith-dev/pods", shadowed[0].URL.Path)
+		assert.Equal(s.T(), "limit=500", shadowed[0].URL.RawQuery)
+		assert.Equal(s.T(), "application/json", shadowed[0].Header.Get("Accept"))
+		assert.Equal(s.T(), "Bearer member3SAToken", shadowed[0].Header.Get("Authorization"))
+		assert.Equal(s.T(), "smith", shadowed[0].Header.Get("Impersonate-User"))
+	})
+
+	s.Run("status mismatches", func() {
+		// given
+		mu.Lock()
+		status = http.StatusNotFound
+		mu.Unlock()
+		shadow := p.newShadowRequest(newContext(http.MethodGet, "/api/v1/namespaces/smith-dev/pods/app", "smith-dev"), target, false)
+		require.NotNil(s.T(), shadow)
+
+		// when
+		shadow.send(proxyMetrics, http.StatusOK)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return promtestutil.ToFloat64(proxyMetrics.RegServProxyShadowCounterVec.WithLabelValues("member-3", metrics.MetricLabelMismatch)) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
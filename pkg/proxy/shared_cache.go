@@ -0,0 +1,446 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// sharedCacheChannel is the Redis channel the events of the shared cache are published on
+	sharedCacheChannel = "sandbox-proxy:events"
+	// sharedCacheMemberRegistrationsKey is the Redis hash of the endpoints of the member clusters registered at runtime, by name
+	sharedCacheMemberRegistrationsKey = "sandbox-proxy:member-registrations"
+	// sharedCacheRevocationsKey is the Redis hash of the revoked tokens and subjects, by key (see auth.Revocation)
+	sharedCacheRevocationsKey = "sandbox-proxy:revocations"
+	// sharedCacheNotBeforeKey is the Redis hash of the not-before times of the realms of SSO (see auth.NotBeforePolicies), in
+	// seconds since the epoch, by issuer
+	sharedCacheNotBeforeKey = "sandbox-proxy:not-before"
+	// sharedCacheCaptureKey is the Redis key of the capture set at runtime (see Capture), which expires at the end of the capture
+	sharedCacheCaptureKey = "sandbox-proxy:capture"
+	// sharedCacheClaimKeyPrefix is the prefix of the Redis keys of the claims of the replicas (see Claim)
+	sharedCacheClaimKeyPrefix = "sandbox-proxy:claims:"
+	// sharedCacheClaimTTL is how long a claim is kept, which is far longer than the informers of the replicas take to see the
+	// same transition of a resource
+	sharedCacheClaimTTL = time.Hour
+	// sharedCacheBanTTL is how long a replica rejects the requests of a user banned according to another replica, which is enough
+	// for the informer of the replica to catch up with the BannedUser, and short enough for a user to be unbanned quickly
+	sharedCacheBanTTL = time.Minute
+)
+
+// sharedCacheRetryInterval is how often a replica which couldn't subscribe to the shared cache at startup tries again
+var sharedCacheRetryInterval = 10 * time.Second
+
+const (
+	// sharedCacheBannedUserEvent is published with the hash of the email of a banned user
+	sharedCacheBannedUserEvent = "banned-user"
+	// sharedCacheMemberEvent is published with the name of a member cluster whose registration changed
+	sharedCacheMemberEvent = "member"
+	// sharedCacheRevocationEvent is published with the key of a revocation which was added or lifted
+	sharedCacheRevocationEvent = "revocation"
+	// sharedCacheNotBeforeEvent is published with the issuer of a realm whose not-before time was raised
+	sharedCacheNotBeforeEvent = "not-before"
+	// sharedCachePluginEndpointEvent is published with the host of a proxy plugin backend which couldn't be reached
+	sharedCachePluginEndpointEvent = "plugin-endpoint"
+	// sharedCacheCaptureEvent is published when the capture set at runtime changed
+	sharedCacheCaptureEvent = "capture"
+)
+
+// sharedCacheEvent is an event published to all the replicas of the proxy
+type sharedCacheEvent struct {
+	Kind string `json:"kind"`
+	Key  string `json:"key"`
+}
+
+// SharedCache keeps the caches of the replicas of the proxy consistent with a Redis server (see the SharedCacheURL setting):
+//   - a user who is banned according to a replica is rejected right away by all the replicas, even if their informer didn't
+//     see the BannedUser yet,
+//   - the endpoints of the member clusters registered at runtime (see MemberRegistry) are stored in Redis, and applied by
+//     all the replicas, including the ones started later on,
+//   - so are the tokens and subjects revoked at runtime (see auth.Revocations), the not-before policies pushed by SSO
+//     (see auth.NotBeforePolicies), and the capture of the requests set at runtime (see Capture),
+//   - the endpoints of a proxy plugin backend which can't be reached by a replica are invalidated by all the replicas
+//     (see PluginEndpoints), so that they all fetch the Route again,
+//   - the events of the transitions of the UserSignups, which are seen by the informers of all the replicas, are delivered to
+//     the webhooks by a single replica (see Claim).
+//
+// The tokens cached by a replica (see TokenCache) don't need to be shared: the cached claims are checked against the revocations
+// and the not-before policies on every request, which are shared. Neither does the routing of the workspaces, which is
+// read from the informers of each replica.
+//
+// The events are published with Redis pub/sub, and the replicas keep on serving the requests if Redis is unavailable.
+type SharedCache struct {
+	client *redis.Client
+	// bans are the users banned according to a replica
+	bans
+}
+
+// NewSharedCache returns a new SharedCache backed by the Redis server of the given URL, eg. 'redis://:password@redis:6379/0'
+func NewSharedCache(redisURL string) (*SharedCache, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL of the shared cache: %w", err)
+	}
+	return &SharedCache{
+		client: redis.NewClient(opts),
+	}, nil
+}
+
+// publish publishes the given event to all the replicas, including this one
+func (c *SharedCache) publish(ctx context.Context, kind, key string) error {
+	event, err := json.Marshal(sharedCacheEvent{Kind: kind, Key: key})
+	if err != nil {
+		return err
+	}
+	return c.client.Publish(ctx, sharedCacheChannel, event).Err()
+}
+
+// publishBan publishes the ban of the user with the given hash of the email, unless it was already published
+func (c *SharedCache) publishBan(ctx context.Context, emailHash string) {
+	if c.isBanned(emailHash, time.Now()) {
+		return
+	}
+	// the ban is recorded right away, so that it's not published again by the next requests of the user
+	c.banned(emailHash, time.Now())
+	if err := c.publish(ctx, sharedCacheBannedUserEvent, emailHash); err != nil {
+		log.Error(nil, err, "unable to publish the ban of a user to the shared cache")
+	}
+}
+
+// saveMemberRegistration stores the given registration and notifies the other replicas
+func (c *SharedCache) saveMemberRegistration(ctx context.Context, registration MemberRegistration) error {
+	value, err := json.Marshal(registration)
+	if err != nil {
+		return err
+	}
+	if err := c.client.HSet(ctx, sharedCacheMemberRegistrationsKey, registration.Name, value).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheMemberEvent, registration.Name)
+}
+
+// deleteMemberRegistration deletes the registration of the member cluster with the given name and notifies the other replicas
+func (c *SharedCache) deleteMemberRegistration(ctx context.Context, name string) error {
+	if err := c.client.HDel(ctx, sharedCacheMemberRegistrationsKey, name).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheMemberEvent, name)
+}
+
+// saveRevocation stores the given revocation and notifies the other replicas
+func (c *SharedCache) saveRevocation(ctx context.Context, revocation auth.Revocation) error {
+	value, err := json.Marshal(revocation)
+	if err != nil {
+		return err
+	}
+	if err := c.client.HSet(ctx, sharedCacheRevocationsKey, revocation.Key(), value).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheRevocationEvent, revocation.Key())
+}
+
+// deleteRevocation deletes the revocation with the given key and notifies the other replicas
+func (c *SharedCache) deleteRevocation(ctx context.Context, key string) error {
+	if err := c.client.HDel(ctx, sharedCacheRevocationsKey, key).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheRevocationEvent, key)
+}
+
+// saveNotBefore stores the given not-before time of the realm with the given issuer and notifies the other replicas
+func (c *SharedCache) saveNotBefore(ctx context.Context, issuer string, notBefore time.Time) error {
+	if err := c.client.HSet(ctx, sharedCacheNotBeforeKey, issuer, notBefore.Unix()).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheNotBeforeEvent, issuer)
+}
+
+// saveCapture stores the given capture and notifies the other replicas
+func (c *SharedCache) saveCapture(ctx context.Context, capture Capture) error {
+	value, err := json.Marshal(capture)
+	if err != nil {
+		return err
+	}
+	if err := c.client.Set(ctx, sharedCacheCaptureKey, value, time.Until(capture.Until)).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheCaptureEvent, "")
+}
+
+// deleteCapture deletes the capture and notifies the other replicas
+func (c *SharedCache) deleteCapture(ctx context.Context) error {
+	if err := c.client.Del(ctx, sharedCacheCaptureKey).Err(); err != nil {
+		return err
+	}
+	return c.publish(ctx, sharedCacheCaptureEvent, "")
+}
+
+// Claim returns true if this replica is the first one to claim the given key, eg. the ID of an event of the transition of a
+// UserSignup which is seen by the informers of all the replicas, so that only one of them delivers the event. The key is claimed
+// if the shared cache can't be reached, so that the event is delivered more than once rather than not at all.
+func (c *SharedCache) Claim(ctx context.Context, key string) bool {
+	claimed, err := c.client.SetNX(ctx, sharedCacheClaimKeyPrefix+key, 1, sharedCacheClaimTTL).Result()
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to claim '%s' in the shared cache", key))
+		return true
+	}
+	return claimed
+}
+
+// publishPluginEndpointInvalidation notifies all the replicas that the proxy plugin backend with the given host can't be reached
+func (c *SharedCache) publishPluginEndpointInvalidation(ctx context.Context, host string) {
+	if err := c.publish(ctx, sharedCachePluginEndpointEvent, host); err != nil {
+		log.Error(nil, err, "unable to publish the invalidation of a proxy plugin endpoint to the shared cache")
+	}
+}
+
+// SyncSharedCache applies the events of the shared cache (if configured) to the caches of the proxy until the given context
+// is done. The registrations of the member clusters, the revocations and the not-before policies are loaded from the shared cache
+// first, and reloaded whenever the subscription is re-established, since the events published in the meantime are lost.
+// If the shared cache can't be reached, the error is returned and the subscription is retried in the background.
+func (p *Proxy) SyncSharedCache(ctx context.Context) error {
+	if p.sharedCache == nil {
+		return nil
+	}
+	pubsub, err := p.subscribeSharedCache(ctx)
+	if err != nil {
+		go p.retrySharedCacheSubscription(ctx)
+		return err
+	}
+	go p.applySharedCacheEvents(ctx, pubsub)
+	return nil
+}
+
+// retrySharedCacheSubscription subscribes to the shared cache every sharedCacheRetryInterval until it succeeds or the given
+// context is done, and then applies its events
+func (p *Proxy) retrySharedCacheSubscription(ctx context.Context) {
+	ticker := time.NewTicker(sharedCacheRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pubsub, err := p.subscribeSharedCache(ctx)
+			if err != nil {
+				log.Error(nil, err, "failed to sync the shared cache of the proxy, retrying")
+				continue
+			}
+			log.Info(nil, "synced the shared cache of the proxy")
+			p.applySharedCacheEvents(ctx, pubsub)
+			return
+		}
+	}
+}
+
+// subscribeSharedCache subscribes to the events of the shared cache and loads the registrations of the member clusters,
+// the revocations, the not-before policies and the capture
+func (p *Proxy) subscribeSharedCache(ctx context.Context) (*redis.PubSub, error) {
+	pubsub := p.sharedCache.client.Subscribe(ctx, sharedCacheChannel)
+	// wait for the subscription, so that no event is missed once the registrations are loaded
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("unable to subscribe to the shared cache: %w", err)
+	}
+	if err := p.loadMemberRegistrations(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	if err := p.loadRevocations(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	if err := p.loadNotBeforePolicies(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	if err := p.loadCapture(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
+
+// applySharedCacheEvents applies the events of the given subscription to the caches of the proxy until the given context is done
+func (p *Proxy) applySharedCacheEvents(ctx context.Context, pubsub *redis.PubSub) {
+	defer pubsub.Close()
+	messages := pubsub.ChannelWithSubscriptions()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case message := <-messages:
+			switch message := message.(type) {
+			case *redis.Subscription:
+				if err := p.loadMemberRegistrations(ctx); err != nil {
+					log.Error(nil, err, "unable to reload the member registrations after the subscription to the shared cache was re-established")
+				}
+				if err := p.loadRevocations(ctx); err != nil {
+					log.Error(nil, err, "unable to reload the revocations after the subscription to the shared cache was re-established")
+				}
+				if err := p.loadNotBeforePolicies(ctx); err != nil {
+					log.Error(nil, err, "unable to reload the not-before policies after the subscription to the shared cache was re-established")
+				}
+				if err := p.loadCapture(ctx); err != nil {
+					log.Error(nil, err, "unable to reload the capture after the subscription to the shared cache was re-established")
+				}
+			case *redis.Message:
+				p.handleSharedCacheEvent(ctx, message.Payload)
+			}
+		}
+	}
+}
+
+// loadMemberRegistrations registers the endpoints of the member clusters of the shared cache
+func (p *Proxy) loadMemberRegistrations(ctx context.Context) error {
+	registrations, err := p.sharedCache.client.HGetAll(ctx, sharedCacheMemberRegistrationsKey).Result()
+	if err != nil {
+		return fmt.Errorf("unable to load the member registrations from the shared cache: %w", err)
+	}
+	for name, value := range registrations {
+		p.applyMemberRegistration(name, value)
+	}
+	for _, registration := range p.memberRegistry.Registrations() {
+		if _, found := registrations[registration.Name]; !found {
+			p.memberRegistry.Deregister(registration.Name)
+		}
+	}
+	return nil
+}
+
+// applyMemberRegistration registers the given JSON registration of the member cluster with the given name
+func (p *Proxy) applyMemberRegistration(name, value string) {
+	registration := MemberRegistration{}
+	if err := json.Unmarshal([]byte(value), &registration); err != nil {
+		log.Error(nil, err, fmt.Sprintf("ignoring the invalid registration of the '%s' member cluster in the shared cache", name))
+		return
+	}
+	registration.Name = name
+	if err := p.memberRegistry.Register(registration); err != nil {
+		log.Error(nil, err, fmt.Sprintf("ignoring the invalid registration of the '%s' member cluster in the shared cache", name))
+	}
+}
+
+// loadRevocations applies the revocations of the shared cache
+func (p *Proxy) loadRevocations(ctx context.Context) error {
+	revocations, err := p.sharedCache.client.HGetAll(ctx, sharedCacheRevocationsKey).Result()
+	if err != nil {
+		return fmt.Errorf("unable to load the revocations from the shared cache: %w", err)
+	}
+	for key, value := range revocations {
+		p.applyRevocation(key, value)
+	}
+	for _, revocation := range p.tokenParser.Revocations().List() {
+		if _, found := revocations[revocation.Key()]; !found {
+			p.tokenParser.Revocations().Lift(revocation.Key())
+		}
+	}
+	return nil
+}
+
+// applyRevocation applies the given JSON revocation with the given key
+func (p *Proxy) applyRevocation(key, value string) {
+	revocation := auth.Revocation{}
+	if err := json.Unmarshal([]byte(value), &revocation); err != nil {
+		log.Error(nil, err, fmt.Sprintf("ignoring the invalid revocation of '%s' in the shared cache", key))
+		return
+	}
+	if err := p.tokenParser.Revocations().Revoke(revocation); err != nil {
+		log.Error(nil, err, fmt.Sprintf("ignoring the invalid revocation of '%s' in the shared cache", key))
+	}
+}
+
+// loadNotBeforePolicies applies the not-before policies of the shared cache
+func (p *Proxy) loadNotBeforePolicies(ctx context.Context) error {
+	policies, err := p.sharedCache.client.HGetAll(ctx, sharedCacheNotBeforeKey).Result()
+	if err != nil {
+		return fmt.Errorf("unable to load the not-before policies from the shared cache: %w", err)
+	}
+	for issuer, value := range policies {
+		p.applyNotBefore(issuer, value)
+	}
+	return nil
+}
+
+// applyNotBefore applies the given not-before time, in seconds since the epoch, of the realm with the given issuer
+func (p *Proxy) applyNotBefore(issuer, value string) {
+	notBefore, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("ignoring the invalid not-before policy of '%s' in the shared cache", issuer))
+		return
+	}
+	p.tokenParser.NotBeforePolicies().Set(issuer, time.Unix(notBefore, 0))
+}
+
+// loadCapture applies the capture of the shared cache, or removes the current one if there is none
+func (p *Proxy) loadCapture(ctx context.Context) error {
+	value, err := p.sharedCache.client.Get(ctx, sharedCacheCaptureKey).Result()
+	if errors.Is(err, redis.Nil) {
+		p.captures.set(nil)
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to load the capture from the shared cache: %w", err)
+	}
+	capture := Capture{}
+	if err := json.Unmarshal([]byte(value), &capture); err != nil {
+		log.Error(nil, err, "ignoring the invalid capture in the shared cache")
+		return nil
+	}
+	p.captures.set(&capture)
+	return nil
+}
+
+// handleSharedCacheEvent applies the given JSON event of the shared cache
+func (p *Proxy) handleSharedCacheEvent(ctx context.Context, payload string) {
+	event := sharedCacheEvent{}
+	if err := json.Unmarshal([]byte(payload), &event); err != nil {
+		log.Error(nil, err, "ignoring an invalid event of the shared cache")
+		return
+	}
+	switch event.Kind {
+	case sharedCacheBannedUserEvent:
+		p.sharedCache.banned(event.Key, time.Now())
+	case sharedCacheMemberEvent:
+		value, err := p.sharedCache.client.HGet(ctx, sharedCacheMemberRegistrationsKey, event.Key).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			p.memberRegistry.Deregister(event.Key)
+		case err != nil:
+			log.Error(nil, err, fmt.Sprintf("unable to get the registration of the '%s' member cluster from the shared cache", event.Key))
+		default:
+			p.applyMemberRegistration(event.Key, value)
+		}
+	case sharedCacheRevocationEvent:
+		value, err := p.sharedCache.client.HGet(ctx, sharedCacheRevocationsKey, event.Key).Result()
+		switch {
+		case errors.Is(err, redis.Nil):
+			p.tokenParser.Revocations().Lift(event.Key)
+		case err != nil:
+			log.Error(nil, err, fmt.Sprintf("unable to get the revocation of '%s' from the shared cache", event.Key))
+		default:
+			p.applyRevocation(event.Key, value)
+		}
+	case sharedCacheNotBeforeEvent:
+		value, err := p.sharedCache.client.HGet(ctx, sharedCacheNotBeforeKey, event.Key).Result()
+		if err != nil {
+			log.Error(nil, err, fmt.Sprintf("unable to get the not-before policy of '%s' from the shared cache", event.Key))
+			return
+		}
+		p.applyNotBefore(event.Key, value)
+	case sharedCacheCaptureEvent:
+		if err := p.loadCapture(ctx); err != nil {
+			log.Error(nil, err, "unable to get the capture from the shared cache")
+		}
+	case sharedCachePluginEndpointEvent:
+		if p.pluginEndpoints != nil {
+			p.pluginEndpoints.InvalidateHost(event.Key)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package proxy
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	commoncluster "github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestSharedCache() {
+	// given
+	server := miniredis.RunT(s.T())
+	ctx, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	newProxy := func() *Proxy {
+		sharedCache, err := NewSharedCache("redis://" + server.Addr())
+		require.NoError(s.T(), err)
+		tokenParser, err := auth.NewTokenParser(&auth.KeyManager{})
+		require.NoError(s.T(), err)
+		return &Proxy{
+			memberRegistry: NewMemberRegistry(func(_ ...commoncluster.Condition) []*commoncluster.CachedToolchainCluster {
+				return nil
+			}),
+			pluginEndpoints: NewPluginEndpoints(),
+			sharedCache:     sharedCache,
+			tokenParser:     tokenParser,
+		}
+	}
+	newSyncedProxy := func() *Proxy {
+		p := newProxy()
+		require.NoError(s.T(), p.SyncSharedCache(ctx))
+		return p
+	}
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_ADMINS", "admin")
+	newAdminContext := func(method, body string) echo.Context {
+		ctx := echo.New().NewContext(httptest.NewRequest(method, memberRegistryEndpoint+"/member-2", strings.NewReader(body)), httptest.NewRecorder())
+		ctx.SetParamNames("name")
+		ctx.SetParamValues("member-2")
+		ctx.Set(context.UsernameKey, "admin")
+		return ctx
+	}
+	registration := MemberRegistration{Name: "member-2", APIEndpoint: "https://api.member-2.com:6443"}
+
+	s.Run("member registrations shared", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+
+		// when
+		err := p1.registerMember(newAdminContext(http.MethodPut, `{"apiEndpoint":"https://api.member-2.com:6443","token":"member2SAToken"}`))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Eventually(s.T(), func() bool {
+			registrations := p2.memberRegistry.Registrations()
+			return len(registrations) == 1 && registrations[0] == registration
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), "member2SAToken", p2.memberRegistry.GetMembers()[0].RestConfig.BearerToken)
+
+		s.Run("loaded by a new replica", func() {
+			// when
+			p3 := newSyncedProxy()
+
+			// then
+			assert.Equal(s.T(), []MemberRegistration{registration}, p3.memberRegistry.Registrations())
+		})
+
+		s.Run("deregistered", func() {
+			// when
+			err := p2.deregisterMember(newAdminContext(http.MethodDelete, ""))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Eventually(s.T(), func() bool {
+				return len(p1.memberRegistry.Registrations()) == 0
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Empty(s.T(), newSyncedProxy().memberRegistry.Registrations())
+		})
+	})
+
+	s.Run("revocations shared", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+		newRevocationContext := func(method string) echo.Context {
+			ctx := echo.New().NewContext(httptest.NewRequest(method, revocationsEndpoint+"/subjects/smith", nil), httptest.NewRecorder())
+			ctx.SetParamNames("kind", "value")
+			ctx.SetParamValues("subjects", "smith")
+			ctx.Set(context.UsernameKey, "admin")
+			return ctx
+		}
+		claims := &auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{Subject: "smith"}}
+
+		// when
+		err := p1.revoke(newRevocationContext(http.MethodPut))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Eventually(s.T(), func() bool {
+			return p2.tokenParser.IsRevoked(claims)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		s.Run("loaded by a new replica", func() {
+			// when
+			p3 := newSyncedProxy()
+
+			// then
+			assert.True(s.T(), p3.tokenParser.IsRevoked(claims))
+		})
+
+		s.Run("lifted", func() {
+			// when
+			err := p2.liftRevocation(newRevocationContext(http.MethodDelete))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Eventually(s.T(), func() bool {
+				return !p1.tokenParser.IsRevoked(claims)
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.False(s.T(), newSyncedProxy().tokenParser.IsRevoked(claims))
+		})
+	})
+
+	s.Run("not-before policies shared", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+		notBefore := time.Now().Truncate(time.Second)
+		claims := &auth.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   "https://sso.example.com/auth/realms/sandbox-dev",
+			IssuedAt: jwt.NewNumericDate(notBefore.Add(-time.Minute)),
+		}}
+
+		// when
+		err := p1.sharedCache.saveNotBefore(ctx, claims.Issuer, notBefore)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Eventually(s.T(), func() bool {
+			return p2.tokenParser.IsRevoked(claims)
+		}, 5*time.Second, 10*time.Millisecond)
+
+		s.Run("loaded by a new replica", func() {
+			// when
+			p3 := newSyncedProxy()
+
+			// then
+			assert.True(s.T(), p3.tokenParser.IsRevoked(claims))
+		})
+	})
+
+	s.Run("bans shared", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+		emailHash := hash.EncodeString("smith@example.com")
+
+		// when
+		p1.sharedCache.publishBan(ctx, emailHash)
+
+		// then
+		assert.True(s.T(), p1.sharedCache.isBanned(emailHash, time.Now()))
+		assert.Eventually(s.T(), func() bool {
+			return p2.sharedCache.isBanned(emailHash, time.Now())
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.False(s.T(), p2.sharedCache.isBanned(emailHash, time.Now().Add(sharedCacheBanTTL)))
+		assert.False(s.T(), p2.sharedCache.isBanned(hash.EncodeString("alice@example.com"), time.Now()))
+
+		s.Run("request rejected", func() {
+			// given
+			p2.Client = namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+			req := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil), httptest.NewRecorder())
+			req.Set(context.EmailKey, "smith@example.com")
+
+			// when
+			err := p2.ensureUserIsNotBanned()(func(_ echo.Context) error {
+				return nil
+			})(req)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+		})
+	})
+
+	s.Run("capture shared", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+		newCaptureContext := func(method, body string) echo.Context {
+			ctx := echo.New().NewContext(httptest.NewRequest(method, captureEndpoint, strings.NewReader(body)), httptest.NewRecorder())
+			ctx.Set(context.UsernameKey, "admin")
+			return ctx
+		}
+
+		// when
+		err := p1.setCapture(newCaptureContext(http.MethodPut, `{"username":"smith","until":"`+time.Now().Add(time.Hour).Format(time.RFC3339)+`"}`))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Eventually(s.T(), func() bool {
+			return p2.capturing("smith", time.Now())
+		}, 5*time.Second, 10*time.Millisecond)
+
+		s.Run("loaded by a new replica", func() {
+			// when
+			p3 := newSyncedProxy()
+
+			// then
+			assert.True(s.T(), p3.capturing("smith", time.Now()))
+		})
+
+		s.Run("stopped", func() {
+			// when
+			err := p2.deleteCapture(newCaptureContext(http.MethodDelete, ""))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Eventually(s.T(), func() bool {
+				return !p1.capturing("smith", time.Now())
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.False(s.T(), newSyncedProxy().capturing("smith", time.Now()))
+		})
+	})
+
+	s.Run("plugin endpoints invalidated", func() {
+		// given
+		p1 := newSyncedProxy()
+		p2 := newSyncedProxy()
+		pluginURL, err := url.Parse("https://tekton-results.member-1.com")
+		require.NoError(s.T(), err)
+		p2.pluginEndpoints.set("member-1", "tekton-results", "1", pluginURL, nil)
+
+		// when
+		p1.sharedCache.publishPluginEndpointInvalidation(ctx, pluginURL.Host)
+
+		// then
+		assert.Eventually(s.T(), func() bool {
+			_, found := p2.pluginEndpoints.get("member-1", "tekton-results", "1")
+			return !found
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	s.Run("shared cache unavailable", func() {
+		// given
+		retryInterval := sharedCacheRetryInterval
+		sharedCacheRetryInterval = 10 * time.Millisecond
+		defer func() {
+			sharedCacheRetryInterval = retryInterval
+		}()
+		syncCtx, cancelSync := gocontext.WithCancel(ctx)
+		defer cancelSync()
+		p := newProxy()
+		server.Close()
+
+		// when
+		err := p.SyncSharedCache(syncCtx)
+
+		// then
+		require.ErrorContains(s.T(), err, "unable to subscribe to the shared cache")
+
+		s.Run("subscription retried in the background", func() {
+			// when
+			require.NoError(s.T(), server.Restart())
+
+			// then
+			emailHash := hash.EncodeString("bob@example.com")
+			assert.Eventually(s.T(), func() bool {
+				// the event is published again until the replica subscribed
+				require.NoError(s.T(), newProxy().sharedCache.publish(ctx, sharedCacheBannedUserEvent, emailHash))
+				return p.sharedCache.isBanned(emailHash, time.Now())
+			}, 5*time.Second, 50*time.Millisecond)
+		})
+	})
+
+	s.Run("claims shared", func() {
+		// given
+		p1 := newProxy()
+		p2 := newProxy()
+		require.True(s.T(), p1.sharedCache.Claim(ctx, "webhooks:1234-5-signup.approved"))
+
+		// then
+		assert.False(s.T(), p2.sharedCache.Claim(ctx, "webhooks:1234-5-signup.approved"))
+		assert.False(s.T(), p1.sharedCache.Claim(ctx, "webhooks:1234-5-signup.approved"))
+		assert.True(s.T(), p2.sharedCache.Claim(ctx, "webhooks:1234-6-signup.provisioned"))
+		assert.Equal(s.T(), sharedCacheClaimTTL, server.TTL(sharedCacheClaimKeyPrefix+"webhooks:1234-5-signup.approved"))
+
+		s.Run("claimed when the shared cache can't be reached", func() {
+			// given
+			unreachable := miniredis.RunT(s.T())
+			sharedCache, err := NewSharedCache("redis://" + unreachable.Addr())
+			require.NoError(s.T(), err)
+			unreachable.Close()
+
+			// then
+			assert.True(s.T(), sharedCache.Claim(ctx, "webhooks:1234-5-signup.approved"))
+			assert.True(s.T(), sharedCache.Claim(ctx, "webhooks:1234-5-signup.approved"))
+		})
+	})
+
+	s.Run("invalid URL", func() {
+		// when
+		_, err := NewSharedCache("http://redis:6379")
+
+		// then
+		require.ErrorContains(s.T(), err, "invalid URL of the shared cache")
+	})
+
+	s.Run("not configured", func() {
+		// then
+		require.NoError(s.T(), (&Proxy{}).SyncSharedCache(ctx))
+	})
+}
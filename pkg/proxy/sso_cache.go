@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+)
+
+// maxSSOCacheEntrySize is the maximum size of the bodies of the responses of SSO stored in the SSOCache
+const maxSSOCacheEntrySize = 1 << 20
+
+type ssoCacheEntry struct {
+	status   int
+	header   http.Header
+	body     []byte
+	storedAt time.Time
+}
+
+// SSOCache caches the responses of SSO to the requests of the web login which don't depend on the user, such as the well-known
+// OAuth configuration or the keycloak.js adapter (see the SSOCachedPaths setting), so that SSO is not called for each of these
+// requests. The expired responses are still served for a while when SSO is unavailable (see the SSOCacheMaxStale setting),
+// so that the short outages of SSO don't break the logins to the console.
+type SSOCache struct {
+	mu      sync.RWMutex
+	entries map[string]ssoCacheEntry
+}
+
+// NewSSOCache returns a new, empty SSOCache
+func NewSSOCache() *SSOCache {
+	return &SSOCache{
+		entries: map[string]ssoCacheEntry{},
+	}
+}
+
+// ssoCacheable returns true if the response of SSO to the given request can be cached
+func ssoCacheable(req *http.Request) bool {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	return cfg.SSOCacheTTL() > 0 && req.Method == http.MethodGet && slices.Contains(cfg.SSOCachedPaths(), req.URL.Path)
+}
+
+// get returns the response cached for the given target URL, if it is not expired at the given time or, if stale is true,
+// if it has not been expired for longer than the SSOCacheMaxStale
+func (c *SSOCache) get(target string, now time.Time, stale bool) (ssoCacheEntry, bool) {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, found := c.entries[target]
+	if !found {
+		return ssoCacheEntry{}, false
+	}
+	maxAge := cfg.SSOCacheTTL()
+	if stale {
+		maxAge += cfg.SSOCacheMaxStale()
+	}
+	return entry, now.Sub(entry.storedAt) < maxAge
+}
+
+// serve writes the response cached for the given target URL (see get) and returns true, or returns false if there is none
+func (c *SSOCache) serve(w http.ResponseWriter, target string, now time.Time, stale bool) bool {
+	entry, found := c.get(target, now, stale)
+	if !found {
+		return false
+	}
+	for name, values := range entry.header {
+		w.Header()[name] = slices.Clone(values)
+	}
+	w.Header().Set("Age", strconv.Itoa(int(now.Sub(entry.storedAt).Seconds())))
+	w.WriteHeader(entry.status)
+	_, _ = w.Write(entry.body)
+	return true
+}
+
+// modifyResponse returns the function storing the successful responses of SSO to the given target URL, called by the reverse
+// proxy before forwarding them. The server errors of SSO are returned as errors if a stale response can be served instead.
+func (c *SSOCache) modifyResponse(target string) func(*http.Response) error {
+	return func(resp *http.Response) error {
+		now := time.Now()
+		if resp.StatusCode >= http.StatusInternalServerError {
+			if _, found := c.get(target, now, true); found {
+				return fmt.Errorf("SSO responded with status %d", resp.StatusCode)
+			}
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK || len(resp.Header.Values("Set-Cookie")) > 0 || resp.ContentLength > maxSSOCacheEntrySize {
+			return nil
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxSSOCacheEntrySize+1))
+		if err != nil {
+			return err
+		}
+		// the body is forwarded whatever its size
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		if len(body) > maxSSOCacheEntrySize {
+			return nil
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.entries[target] = ssoCacheEntry{
+			status:   resp.StatusCode,
+			header:   resp.Header.Clone(),
+			body:     body,
+			storedAt: now,
+		}
+		return nil
+	}
+}
+
+// errorHandler returns the function serving the stale response cached for the given target URL, if any, when SSO is unavailable
+// or responds with a server error (see modifyResponse), called by the reverse proxy
+func (c *SSOCache) errorHandler(target string) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, _ *http.Request, err error) {
+		if c.serve(w, target, time.Now(), true) {
+			log.Errorf(nil, err, "serving the stale response of SSO to %s", target)
+			return
+		}
+		log.Errorf(nil, err, "unable to forward the request to %s", target)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
@@ -0,0 +1,203 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestSSOCache() {
+	// given
+	var calls atomic.Int32
+	var status atomic.Int32
+	sso := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/auth/realms/sandbox-dev/protocol/openid-connect/token" {
+			w.Header().Set("Set-Cookie", "session=abc")
+		}
+		w.Header().Set("Content-Type", "application/javascript")
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte("keycloak.js"))
+	}))
+	defer sso.Close()
+	newHandler := func() (*Proxy, func(method, path string) *httptest.ResponseRecorder) {
+		p := &Proxy{ssoCache: NewSSOCache()}
+		return p, func(method, path string) *httptest.ResponseRecorder {
+			target, err := url.Parse(sso.URL + path)
+			require.NoError(s.T(), err)
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(method, path, nil), rec)
+			require.NoError(s.T(), p.handleSSORequest(target)(ctx))
+			return rec
+		}
+	}
+	reset := func() {
+		calls.Store(0)
+		status.Store(http.StatusOK)
+	}
+
+	s.Run("cached", func() {
+		// given
+		reset()
+		_, serve := newHandler()
+
+		// when
+		first := serve(http.MethodGet, "/auth/js/keycloak.js")
+		second := serve(http.MethodGet, "/auth/js/keycloak.js")
+
+		// then
+		assert.Equal(s.T(), int32(1), calls.Load())
+		for _, rec := range []*httptest.ResponseRecorder{first, second} {
+			require.Equal(s.T(), http.StatusOK, rec.Code)
+			assert.Equal(s.T(), "keycloak.js", rec.Body.String())
+			assert.Equal(s.T(), "application/javascript", rec.Header().Get("Content-Type"))
+		}
+		assert.Empty(s.T(), first.Header().Get("Age"))
+		assert.Equal(s.T(), "0", second.Header().Get("Age"))
+	})
+
+	s.Run("not cached", func() {
+		for name, tc := range map[string]struct {
+			method string
+			path   string
+			status int
+		}{
+			"other path":   {method: http.MethodGet, path: "/auth/realms/sandbox-dev/account"},
+			"other method": {method: http.MethodPost, path: "/auth/js/keycloak.js"},
+			"not found":    {method: http.MethodGet, path: "/auth/js/keycloak.js", status: http.StatusNotFound},
+			"with cookie":  {method: http.MethodGet, path: "/auth/realms/sandbox-dev/protocol/openid-connect/token"},
+		} {
+			s.Run(name, func() {
+				// given
+				reset()
+				if tc.status != 0 {
+					status.Store(int32(tc.status))
+				}
+				s.T().Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHED_PATHS", "/auth/js/keycloak.js,/auth/realms/sandbox-dev/protocol/openid-connect/token")
+				_, serve := newHandler()
+
+				// when
+				serve(tc.method, tc.path)
+				serve(tc.method, tc.path)
+
+				// then
+				assert.Equal(s.T(), int32(2), calls.Load())
+			})
+		}
+
+		s.Run("disabled", func() {
+			// given
+			reset()
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_SSO_CACHE_TTL", "0s")
+			_, serve := newHandler()
+
+			// when
+			serve(http.MethodGet, "/auth/js/keycloak.js")
+			serve(http.MethodGet, "/auth/js/keycloak.js")
+
+			// then
+			assert.Equal(s.T(), int32(2), calls.Load())
+		})
+	})
+
+	s.Run("expired", func() {
+		// given
+		reset()
+		p, serve := newHandler()
+		serve(http.MethodGet, "/auth/js/keycloak.js")
+		expire := func(age time.Duration) {
+			p.ssoCache.mu.Lock()
+			defer p.ssoCache.mu.Unlock()
+			for target, entry := range p.ssoCache.entries {
+				entry.storedAt = time.Now().Add(-age)
+				p.ssoCache.entries[target] = entry
+			}
+		}
+
+		s.Run("refreshed", func() {
+			// given
+			expire(6 * time.Minute)
+
+			// when
+			rec := serve(http.MethodGet, "/auth/js/keycloak.js")
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rec.Code)
+			assert.Empty(s.T(), rec.Header().Get("Age"))
+			assert.Equal(s.T(), int32(2), calls.Load())
+		})
+
+		s.Run("stale served on server error", func() {
+			// given
+			expire(6 * time.Minute)
+			status.Store(http.StatusServiceUnavailable)
+
+			// when
+			rec := serve(http.MethodGet, "/auth/js/keycloak.js")
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rec.Code)
+			assert.Equal(s.T(), "keycloak.js", rec.Body.String())
+			assert.Equal(s.T(), "360", rec.Header().Get("Age"))
+		})
+
+		s.Run("server error forwarded when too stale", func() {
+			// given
+			expire(2 * time.Hour)
+			status.Store(http.StatusServiceUnavailable)
+
+			// when
+			rec := serve(http.MethodGet, "/auth/js/keycloak.js")
+
+			// then
+			assert.Equal(s.T(), http.StatusServiceUnavailable, rec.Code)
+		})
+	})
+
+	s.Run("stale served when SSO is unavailable", func() {
+		// given
+		reset()
+		unavailable := httptest.NewServer(http.NotFoundHandler())
+		unavailable.Close()
+		p := &Proxy{ssoCache: NewSSOCache()}
+		target, err := url.Parse(unavailable.URL + "/auth/js/keycloak.js")
+		require.NoError(s.T(), err)
+		serve := func() *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/auth/js/keycloak.js", nil), rec)
+			require.NoError(s.T(), p.handleSSORequest(target)(ctx))
+			return rec
+		}
+
+		s.Run("nothing cached", func() {
+			// when
+			rec := serve()
+
+			// then
+			assert.Equal(s.T(), http.StatusBadGateway, rec.Code)
+		})
+
+		s.Run("stale", func() {
+			// given
+			p.ssoCache.entries[target.String()] = ssoCacheEntry{
+				status:   http.StatusOK,
+				header:   http.Header{"Content-Type": []string{"application/javascript"}},
+				body:     []byte("keycloak.js"),
+				storedAt: time.Now().Add(-10 * time.Minute),
+			}
+
+			// when
+			rec := serve()
+
+			// then
+			require.Equal(s.T(), http.StatusOK, rec.Code)
+			assert.Equal(s.T(), "keycloak.js", rec.Body.String())
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func (s *TestProxySuite) TestErrorStatus() {
+	for code, reason := range map[int]metav1.StatusReason{
+		http.StatusBadRequest:          metav1.StatusReasonBadRequest,
+		http.StatusUnauthorized:        metav1.StatusReasonUnauthorized,
+		http.StatusForbidden:           metav1.StatusReasonForbidden,
+		http.StatusTooManyRequests:     metav1.StatusReasonTooManyRequests,
+		http.StatusInternalServerError: metav1.StatusReasonInternalError,
+		http.StatusTeapot:              metav1.StatusReasonUnknown,
+	} {
+		s.Run(http.StatusText(code), func() {
+			// given
+			rr := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil), rr)
+
+			// when
+			customHTTPErrorHandler(&crterrors.Error{Code: code, Message: "unable to proxy", Details: "details"}, ctx)
+
+			// then
+			assert.Equal(s.T(), code, rr.Code)
+			assert.Contains(s.T(), rr.Header().Get("Content-Type"), "application/json")
+			status := &metav1.Status{}
+			require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), status))
+			assert.Equal(s.T(), metav1.Status{
+				TypeMeta: metav1.TypeMeta{
+					Kind:       "Status",
+					APIVersion: "v1",
+				},
+				Status:  metav1.StatusFailure,
+				Message: "unable to proxy: details",
+				Reason:  reason,
+				Code:    int32(code), // nolint:gosec
+			}, *status)
+		})
+	}
+}
+
+func (s *TestProxySuite) TestErrorStatusScrubbed() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_LOG_SCRUBBING_ENABLED", "true")
+	rr := httptest.NewRecorder()
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil), rr)
+
+	// when
+	customHTTPErrorHandler(crterrors.NewForbiddenError("invalid workspace request", "the user john@example.com has no access"), ctx)
+
+	// then
+	status := &metav1.Status{}
+	require.NoError(s.T(), json.Unmarshal(rr.Body.Bytes(), status))
+	assert.Equal(s.T(), "invalid workspace request: the user ***** has no access", status.Message)
+}
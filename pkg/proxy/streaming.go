@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+)
+
+// eventStreamContentType is the media type of the Server-Sent Events, eg. sent by the proxy plugin backends
+const eventStreamContentType = "text/event-stream"
+
+// isStreamingRequest returns true if the response to the given request is streamed for an unbounded time, ie. for the watches,
+// the followed logs, the event streams and the upgraded connections (exec, attach, port-forward)
+func isStreamingRequest(req *http.Request) bool {
+	if httpstream.IsUpgradeRequest(req) || strings.Contains(strings.ToLower(req.Header.Get("Accept")), eventStreamContentType) {
+		return true
+	}
+	if isWatchRequest(req) {
+		return true
+	}
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	if follow, _ := strconv.ParseBool(req.URL.Query().Get("follow")); follow && strings.HasSuffix(path, "/log") {
+		return true
+	}
+	return strings.HasSuffix(path, "/exec") || strings.HasSuffix(path, "/attach") || strings.HasSuffix(path, "/portforward")
+}
+
+// upstreamTimeouts returns the response header and idle timeouts of the requests forwarded to the member clusters
+// (or to the proxy plugin backends), which are distinct for the streaming requests
+func upstreamTimeouts(streaming bool) (time.Duration, time.Duration) {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	if streaming {
+		return cfg.StreamingResponseHeaderTimeout(), cfg.StreamingIdleTimeout()
+	}
+	return cfg.ResponseHeaderTimeout(), cfg.IdleTimeout()
+}
+
+// prepareEventStream returns true if the given response of the upstream is an event stream, in which case the response headers are
+// updated so that the events are not buffered by the reverse proxies in front of the proxy either (the proxy flushes each event)
+func prepareEventStream(resp *http.Response) bool {
+	if !strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), eventStreamContentType) {
+		return false
+	}
+	resp.Header.Set("X-Accel-Buffering", "no")
+	resp.Header.Set("Cache-Control", "no-cache")
+	return true
+}
+
+// idleTimeoutBody closes the response body of the upstream when no data is received for the given timeout,
+// which aborts the response. The time spent writing the data to the client is not counted.
+type idleTimeoutBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration) *idleTimeoutBody {
+	timer := time.AfterFunc(timeout, func() {
+		_ = body.Close()
+	})
+	timer.Stop()
+	return &idleTimeoutBody{
+		ReadCloser: body,
+		timeout:    timeout,
+		timer:      timer,
+	}
+}
+
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(b.timeout)
+	defer b.timer.Stop()
+	return b.ReadCloser.Read(p)
+}
+
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestIsStreamingRequest() {
+	for target, expected := range map[string]bool{
+		"/api/v1/namespaces/smith-dev/pods":                                    false,
+		"/api/v1/namespaces/smith-dev/pods?watch=true":                         true,
+		"/api/v1/namespaces/smith-dev/pods?watch=1&resourceVersion=10":         true,
+		"/api/v1/namespaces/smith-dev/pods?watch=false":                        false,
+		"/api/v1/watch/namespaces/smith-dev/pods":                              true,
+		"/apis/apps/v1/watch/namespaces/smith-dev/deployments/":                true,
+		"/api/v1/namespaces/smith-dev/pods/mypod/log":                          false,
+		"/api/v1/namespaces/smith-dev/pods/mypod/log?follow=true&container=c1": true,
+		"/api/v1/namespaces/smith-dev/pods/mypod/exec?command=ls":              true,
+		"/api/v1/namespaces/smith-dev/pods/mypod/attach":                       true,
+		"/api/v1/namespaces/smith-dev/pods/mypod/portforward":                  true,
+		"/api/v1/namespaces/smith-dev/configmaps/follow?follow=true":           false,
+	} {
+		s.Run(target, func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+
+			// then
+			assert.Equal(s.T(), expected, isStreamingRequest(req))
+		})
+	}
+
+	s.Run("upgrade request", func() {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/namespaces/smith-dev/pods", nil)
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+
+		// then
+		assert.True(s.T(), isStreamingRequest(req))
+	})
+
+	s.Run("event stream request", func() {
+		// given
+		req := httptest.NewRequest(http.MethodGet, "/events", nil)
+		req.Header.Set("Accept", "Text/Event-Stream")
+
+		// then
+		assert.True(s.T(), isStreamingRequest(req))
+	})
+}
+
+func (s *TestProxySuite) TestPrepareEventStream() {
+	s.Run("event stream", func() {
+		// given
+		resp := &http.Response{Header: http.Header{"Content-Type": {"text/event-stream; charset=utf-8"}}}
+
+		// when
+		eventStream := prepareEventStream(resp)
+
+		// then
+		assert.True(s.T(), eventStream)
+		assert.Equal(s.T(), "no", resp.Header.Get("X-Accel-Buffering"))
+		assert.Equal(s.T(), "no-cache", resp.Header.Get("Cache-Control"))
+	})
+
+	s.Run("not an event stream", func() {
+		// given
+		resp := &http.Response{Header: http.Header{"Content-Type": {"application/json"}}}
+
+		// when
+		eventStream := prepareEventStream(resp)
+
+		// then
+		assert.False(s.T(), eventStream)
+		assert.Empty(s.T(), resp.Header.Get("X-Accel-Buffering"))
+	})
+}
+
+func (s *TestProxySuite) TestProxyPluginEventStream() {
+	// given
+	next := make(chan struct{})
+	plugin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", eventStreamContentType)
+		w.WriteHeader(http.StatusOK)
+		for i := 1; i <= 2; i++ {
+			_, _ = fmt.Fprintf(w, "data: event-%d\n\n", i)
+			w.(http.Flusher).Flush()
+			// the next event is only sent once the client received the previous one
+			<-next
+		}
+	}))
+	defer plugin.Close()
+	pluginURL, err := url.Parse(plugin.URL)
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *pluginURL, "clusterSAToken", "smith", nil)
+	p := &Proxy{pluginEndpoints: NewPluginEndpoints(), metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := echo.New().NewContext(r, w)
+		ctx.Set(context.UsernameKey, "smith")
+		p.newReverseProxy(ctx, target, true, &upstreamAccounting{}, CanaryFlags{}).ServeHTTP(ctx.Response(), r)
+	}))
+	defer proxy.Close()
+	// the idle timeout of the event streams is longer than the one of the ordinary requests
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_IDLE_TIMEOUT", "100ms")
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_EVENT_STREAM_IDLE_TIMEOUT", "1m")
+
+	// when
+	resp, err := http.Get(proxy.URL + "/events") // nolint:noctx
+	require.NoError(s.T(), err)
+	defer resp.Body.Close()
+
+	// then
+	assert.Equal(s.T(), "no", resp.Header.Get("X-Accel-Buffering"))
+	reader := bufio.NewReader(resp.Body)
+	for i := 1; i <= 2; i++ {
+		line, err := reader.ReadString('\n')
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), fmt.Sprintf("data: event-%d\n", i), line)
+		_, err = reader.ReadString('\n')
+		require.NoError(s.T(), err)
+		// wait longer than the idle timeout of the ordinary requests
+		time.Sleep(200 * time.Millisecond)
+		next <- struct{}{}
+	}
+	_, err = reader.ReadString('\n')
+	assert.Equal(s.T(), io.EOF, err)
+}
+
+func (s *TestProxySuite) TestUpstreamTimeouts() {
+	s.Run("no timeout by default", func() {
+		for _, streaming := range []bool{true, false} {
+			// when
+			headerTimeout, idleTimeout := upstreamTimeouts(streaming)
+
+			// then
+			assert.Zero(s.T(), headerTimeout)
+			assert.Zero(s.T(), idleTimeout)
+		}
+	})
+
+	s.Run("distinct timeouts for the streaming requests", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_RESPONSE_HEADER_TIMEOUT", "10s")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_IDLE_TIMEOUT", "30s")
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_STREAMING_IDLE_TIMEOUT", "1h")
+
+		// when
+		headerTimeout, idleTimeout := upstreamTimeouts(false)
+		streamingHeaderTimeout, streamingIdleTimeout := upstreamTimeouts(true)
+
+		// then
+		assert.Equal(s.T(), 10*time.Second, headerTimeout)
+		assert.Equal(s.T(), 30*time.Second, idleTimeout)
+		assert.Zero(s.T(), streamingHeaderTimeout)
+		assert.Equal(s.T(), time.Hour, streamingIdleTimeout)
+	})
+}
+
+func (s *TestProxySuite) TestIdleTimeoutBody() {
+	s.Run("data received before the timeout", func() {
+		// given
+		r, w := io.Pipe()
+		body := newIdleTimeoutBody(r, 500*time.Millisecond)
+		go func() {
+			for i := 0; i < 3; i++ {
+				time.Sleep(200 * time.Millisecond)
+				_, _ = w.Write([]byte("data"))
+			}
+			_ = w.Close()
+		}()
+
+		// when
+		data, err := io.ReadAll(body)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "datadatadata", string(data))
+		require.NoError(s.T(), body.Close())
+	})
+
+	s.Run("no data received before the timeout", func() {
+		// given
+		r, w := io.Pipe()
+		defer w.Close()
+		body := newIdleTimeoutBody(r, 100*time.Millisecond)
+		go func() {
+			_, _ = w.Write([]byte("data"))
+		}()
+
+		// when
+		data, err := io.ReadAll(body)
+
+		// then
+		require.ErrorIs(s.T(), err, io.ErrClosedPipe)
+		assert.Equal(s.T(), "data", string(data))
+	})
+
+	s.Run("time spent between the reads not counted", func() {
+		// given
+		r, w := io.Pipe()
+		body := newIdleTimeoutBody(r, 100*time.Millisecond)
+		go func() {
+			_, _ = w.Write([]byte("data"))
+			_ = w.Close()
+		}()
+		buf := make([]byte, 4)
+		_, err := io.ReadFull(body, buf)
+		require.NoError(s.T(), err)
+
+		// when the client is slow to consume the data
+		time.Sleep(300 * time.Millisecond)
+		_, err = body.Read(buf)
+
+		// then
+		assert.Equal(s.T(), io.EOF, err)
+	})
+}
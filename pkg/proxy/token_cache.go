@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+)
+
+type tokenCacheEntry struct {
+	claims    *auth.TokenClaims
+	expiresAt time.Time
+}
+
+// TokenCache caches the claims of the tokens validated by the proxy until the tokens expire, so that the clients sending
+// many requests (such as the console polling the resources) don't pay the verification of the signature of the token on
+// every request. The tokens are keyed by their hash, so that they are not kept in memory.
+type TokenCache struct {
+	mu      sync.RWMutex
+	entries map[[sha256.Size]byte]tokenCacheEntry
+}
+
+// NewTokenCache returns a new, empty TokenCache
+func NewTokenCache() *TokenCache {
+	return &TokenCache{
+		entries: map[[sha256.Size]byte]tokenCacheEntry{},
+	}
+}
+
+// get returns the cached claims of the given token, if the token is not expired yet at the given time
+func (c *TokenCache) get(token string, now time.Time) (*auth.TokenClaims, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, found := c.entries[sha256.Sum256([]byte(token))]
+	if !found || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.claims, true
+}
+
+// set stores the claims of the given validated token until the token expires, unless the cache is disabled or the token
+// has no expiration time. The expired entries are removed when the cache is full, and the token is not cached if the cache
+// is still full then.
+func (c *TokenCache) set(token string, claims *auth.TokenClaims, now time.Time) {
+	size := configuration.GetRegistrationServiceConfig().Proxy().TokenCacheSize()
+	if size <= 0 || claims.ExpiresAt == nil || !now.Before(claims.ExpiresAt.Time) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= size {
+		for key, entry := range c.entries {
+			if !now.Before(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= size {
+			return
+		}
+	}
+	c.entries[sha256.Sum256([]byte(token))] = tokenCacheEntry{
+		claims:    claims,
+		expiresAt: claims.ExpiresAt.Time,
+	}
+}
@@ -0,0 +1,195 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/auth"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestTokenCache() {
+	// given
+	now := time.Now()
+	newClaims := func(expiresAt time.Time) *auth.TokenClaims {
+		return &auth.TokenClaims{
+			PreferredUsername: "smith",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(expiresAt),
+			},
+		}
+	}
+
+	s.Run("cached until the token expires", func() {
+		// given
+		cache := NewTokenCache()
+		claims := newClaims(now.Add(time.Minute))
+
+		// when
+		cache.set("token", claims, now)
+
+		// then
+		cached, found := cache.get("token", now.Add(59*time.Second))
+		require.True(s.T(), found)
+		assert.Same(s.T(), claims, cached)
+		_, found = cache.get("token", now.Add(time.Minute))
+		assert.False(s.T(), found)
+		_, found = cache.get("other-token", now)
+		assert.False(s.T(), found)
+	})
+
+	s.Run("not cached", func() {
+		s.Run("cache disabled", func() {
+			// given
+			s.T().Setenv("REGISTRATION_SERVICE_PROXY_TOKEN_CACHE_SIZE", "0")
+			cache := NewTokenCache()
+
+			// when
+			cache.set("token", newClaims(now.Add(time.Minute)), now)
+
+			// then
+			_, found := cache.get("token", now)
+			assert.False(s.T(), found)
+		})
+
+		s.Run("no expiration time", func() {
+			// given
+			cache := NewTokenCache()
+
+			// when
+			cache.set("token", &auth.TokenClaims{PreferredUsername: "smith"}, now)
+
+			// then
+			_, found := cache.get("token", now)
+			assert.False(s.T(), found)
+		})
+
+		s.Run("token already expired", func() {
+			// given
+			cache := NewTokenCache()
+
+			// when
+			cache.set("token", newClaims(now.Add(-time.Second)), now)
+
+			// then
+			assert.Empty(s.T(), cache.entries)
+		})
+	})
+
+	s.Run("cache full", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_TOKEN_CACHE_SIZE", "2")
+		cache := NewTokenCache()
+		cache.set("token-1", newClaims(now.Add(time.Second)), now)
+		cache.set("token-2", newClaims(now.Add(time.Minute)), now)
+
+		s.Run("not cached when no entry is expired", func() {
+			// when
+			cache.set("token-3", newClaims(now.Add(time.Minute)), now)
+
+			// then
+			_, found := cache.get("token-3", now)
+			assert.False(s.T(), found)
+			assert.Len(s.T(), cache.entries, 2)
+		})
+
+		s.Run("expired entries removed", func() {
+			// when
+			later := now.Add(2 * time.Second)
+			cache.set("token-3", newClaims(now.Add(time.Minute)), later)
+
+			// then
+			_, found := cache.get("token-3", later)
+			assert.True(s.T(), found)
+			_, found = cache.get("token-2", later)
+			assert.True(s.T(), found)
+			assert.Len(s.T(), cache.entries, 2)
+		})
+	})
+}
+
+func (s *TestProxySuite) TestExtractUserTokenFromCache() {
+	// given
+	env := s.DefaultConfig().Environment()
+	defer s.SetConfig(testconfig.RegistrationService().
+		Environment(env))
+	s.SetConfig(testconfig.RegistrationService().
+		Environment(string(testconfig.E2E)))
+	tokenParser, err := auth.InitializeDefaultTokenParser()
+	require.NoError(s.T(), err)
+	proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+	p := &Proxy{
+		tokenParser: tokenParser,
+		metrics:     proxyMetrics,
+		tokenCache:  NewTokenCache(),
+	}
+	newRequest := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/pods", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+	token := s.token("smith")
+	counter := proxyMetrics.RegServProxyTokenCacheCounterVec
+
+	// when
+	first, err := p.extractUserToken(newRequest(token))
+	require.NoError(s.T(), err)
+	second, err := p.extractUserToken(newRequest(token))
+	require.NoError(s.T(), err)
+
+	// then
+	assert.Same(s.T(), first, second)
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues(metrics.MetricLabelMiss)), 0.01)
+	assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues(metrics.MetricLabelHit)), 0.01)
+
+	s.Run("revoked token rejected", func() {
+		// given
+		require.NoError(s.T(), tokenParser.Revocations().Revoke(auth.Revocation{TokenID: first.ID}))
+		defer tokenParser.Revocations().Lift(auth.Revocation{TokenID: first.ID}.Key())
+
+		// when
+		_, err := p.extractUserToken(newRequest(token))
+
+		// then
+		crtErr := &crterrors.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusUnauthorized, crtErr.Code)
+		assert.Equal(s.T(), "token is revoked", crtErr.Details)
+		assert.InDelta(s.T(), 2, promtestutil.ToFloat64(counter.WithLabelValues(metrics.MetricLabelHit)), 0.01)
+	})
+
+	s.Run("invalid token not cached", func() {
+		// when
+		_, err := p.extractUserToken(newRequest("invalid"))
+		require.Error(s.T(), err)
+		_, err = p.extractUserToken(newRequest("invalid"))
+
+		// then
+		require.Error(s.T(), err)
+		assert.InDelta(s.T(), 3, promtestutil.ToFloat64(counter.WithLabelValues(metrics.MetricLabelMiss)), 0.01)
+		assert.InDelta(s.T(), 2, promtestutil.ToFloat64(counter.WithLabelValues(metrics.MetricLabelHit)), 0.01)
+	})
+
+	s.Run("failures counted by reason", func() {
+		// given
+		failures := proxyMetrics.RegServProxyTokenValidationFailuresCounterVec
+		req := httptest.NewRequest(http.MethodGet, "/api/pods", nil)
+
+		// when
+		_, err := p.extractUserToken(req)
+
+		// then
+		require.Error(s.T(), err)
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(failures.WithLabelValues(auth.FailureReasonMissing)), 0.01)
+		assert.InDelta(s.T(), 2, promtestutil.ToFloat64(failures.WithLabelValues(auth.FailureReasonMalformed)), 0.01)
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(failures.WithLabelValues(auth.FailureReasonRevoked)), 0.01)
+	})
+}
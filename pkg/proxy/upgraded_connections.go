@@ -0,0 +1,57 @@
+package proxy
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UpgradedConnections tracks the active upgraded (websocket, SPDY) connections of each user, so that a single user can't
+// exhaust the file descriptors of the proxy with watch streams
+type UpgradedConnections struct {
+	mu    sync.Mutex
+	count map[string]int
+	gauge prometheus.Gauge
+}
+
+// NewUpgradedConnections returns a new UpgradedConnections reflecting the number of active connections in the given gauge
+func NewUpgradedConnections(gauge prometheus.Gauge) *UpgradedConnections {
+	return &UpgradedConnections{
+		count: map[string]int{},
+		gauge: gauge,
+	}
+}
+
+// Acquire registers a new upgraded connection for the given user, unless the user already reached the given limit,
+// in which case false is returned. There is no limit when the given limit is zero or negative.
+// The connections registered with Acquire must be released with Release once they are closed.
+func (c *UpgradedConnections) Acquire(username string, limit int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if limit > 0 && c.count[username] >= limit {
+		return false
+	}
+	c.count[username]++
+	c.gauge.Inc()
+	return true
+}
+
+// Release unregisters an upgraded connection of the given user
+func (c *UpgradedConnections) Release(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count[username] <= 1 {
+		// don't keep the users without any active connection
+		delete(c.count, username)
+	} else {
+		c.count[username]--
+	}
+	c.gauge.Dec()
+}
+
+// Count returns the number of active upgraded connections of the given user
+func (c *UpgradedConnections) Count(username string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count[username]
+}
@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestUpgradedConnections() {
+	// given
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_upgraded_connections"})
+	connections := NewUpgradedConnections(gauge)
+
+	s.Run("limit reached", func() {
+		// when
+		require.True(s.T(), connections.Acquire("smith", 2))
+		require.True(s.T(), connections.Acquire("smith", 2))
+		require.True(s.T(), connections.Acquire("john", 2))
+
+		// then
+		assert.False(s.T(), connections.Acquire("smith", 2))
+		assert.Equal(s.T(), 2, connections.Count("smith"))
+		assert.Equal(s.T(), 1, connections.Count("john"))
+		assert.InDelta(s.T(), 3, promtestutil.ToFloat64(gauge), 0.01)
+	})
+
+	s.Run("connection released", func() {
+		// when
+		connections.Release("smith")
+
+		// then
+		assert.True(s.T(), connections.Acquire("smith", 2))
+		assert.InDelta(s.T(), 3, promtestutil.ToFloat64(gauge), 0.01)
+	})
+
+	s.Run("no limit", func() {
+		// when
+		for i := 0; i < 10; i++ {
+			require.True(s.T(), connections.Acquire("john", 0))
+		}
+
+		// then
+		assert.Equal(s.T(), 11, connections.Count("john"))
+	})
+
+	s.Run("all connections released", func() {
+		// when
+		for i := 0; i < 2; i++ {
+			connections.Release("smith")
+		}
+		for i := 0; i < 11; i++ {
+			connections.Release("john")
+		}
+
+		// then
+		assert.Zero(s.T(), connections.Count("smith"))
+		assert.Zero(s.T(), connections.Count("john"))
+		assert.Empty(s.T(), connections.count)
+		assert.Zero(s.T(), promtestutil.ToFloat64(gauge))
+	})
+}
+
+func (s *TestProxySuite) checkUpgradedConnectionsLimit(proxy *Proxy) {
+	s.Run("upgraded connections limit", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_MAX_UPGRADED_CONNECTIONS_PER_USER", "1")
+		username := "smith2"
+		// the user already has an open connection
+		require.True(s.T(), proxy.upgradedConnections.Acquire(username, 0))
+		defer proxy.upgradedConnections.Release(username)
+		req, err := http.NewRequest("GET", "http://localhost:8081/api/mycoolworkspace/pods", nil)
+		require.NoError(s.T(), err)
+		upgradeToWebsocket(req)
+		req.Header.Set("Sec-Websocket-Protocol", websocketProtocol(s.token(username)))
+
+		// when
+		resp, err := http.DefaultClient.Do(req)
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), resp)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusTooManyRequests, resp.StatusCode)
+		s.assertResponseBody(resp, "too many connections: the maximum number of 1 concurrent upgraded connections per user is reached")
+		assert.Equal(s.T(), 1, proxy.upgradedConnections.Count(username))
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(proxy.metrics.RegServProxyUpgradedConnectionsGauge), 0.01)
+
+		s.Run("other users are not limited", func() {
+			// given
+			req.Header.Set("Sec-Websocket-Protocol", websocketProtocol(s.token("smith3")))
+
+			// when
+			resp, err := http.DefaultClient.Do(req)
+
+			// then
+			require.NoError(s.T(), err)
+			require.NotNil(s.T(), resp)
+			defer resp.Body.Close()
+			assert.NotEqual(s.T(), http.StatusTooManyRequests, resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+			assert.Zero(s.T(), proxy.upgradedConnections.Count("smith3"))
+		})
+	})
+}
+
+func websocketProtocol(token string) string {
+	return fmt.Sprintf("base64url.bearer.authorization.k8s.io.%s,dummy", base64.RawURLEncoding.EncodeToString([]byte(token)))
+}
@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+)
+
+// observedTransport counts the responses of the member cluster (or of the proxy plugin backend) per status class,
+// and the requests which failed to get a response, eg. because the member cluster couldn't be reached
+type observedTransport struct {
+	http.RoundTripper
+	proxyMetrics *metrics.ProxyMetrics
+	// upstream is the name of the member cluster, or the host of the proxy plugin backend
+	upstream string
+}
+
+func newObservedTransport(transport http.RoundTripper, proxyMetrics *metrics.ProxyMetrics, target *access.ClusterAccess) *observedTransport {
+	return &observedTransport{
+		RoundTripper: transport,
+		proxyMetrics: proxyMetrics,
+		upstream:     upstreamName(target),
+	}
+}
+
+func (t *observedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	switch {
+	case err == nil:
+		t.proxyMetrics.RegServProxyUpstreamResponsesCounterVec.WithLabelValues(t.upstream, upstreamStatusClass(resp.StatusCode)).Inc()
+	case req.Context().Err() == nil:
+		// the requests canceled by the clients are not the failures of the member cluster
+		t.proxyMetrics.RegServProxyUpstreamResponsesCounterVec.WithLabelValues(t.upstream, metrics.MetricLabelError).Inc()
+	}
+	return resp, err
+}
+
+// observeUpgradedConnection returns the function observing the lifetime of an upgraded connection to the given target,
+// established at the given time, and the bytes transferred in each direction, once the connection is closed
+func (p *Proxy) observeUpgradedConnection(target *access.ClusterAccess, established time.Time) func(received, sent int64) {
+	upstream := upstreamName(target)
+	return func(received, sent int64) {
+		p.metrics.RegServProxyUpgradedConnectionDurationHistogramVec.WithLabelValues(upstream).Observe(time.Since(established).Seconds())
+		p.metrics.RegServProxyUpgradedConnectionBytesHistogramVec.WithLabelValues(upstream, metrics.MetricLabelUpstream).Observe(float64(sent))
+		p.metrics.RegServProxyUpgradedConnectionBytesHistogramVec.WithLabelValues(upstream, metrics.MetricLabelDownstream).Observe(float64(received))
+	}
+}
+
+// upstreamName returns the name of the member cluster of the given target, or the host of the proxy plugin backend
+func upstreamName(target *access.ClusterAccess) string {
+	if name := target.ClusterName(); name != "" {
+		return name
+	}
+	return target.APIURL().Host
+}
+
+// upstreamStatusClass returns the class of the given status, eg. '5xx' for 503
+func upstreamStatusClass(status int) string {
+	return fmt.Sprintf("%dxx", status/100)
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	gocontext "context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	clientmodel "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestObservedTransport() {
+	// given
+	member := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer member.Close()
+	memberURL, err := url.Parse(member.URL)
+	require.NoError(s.T(), err)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+	unreachableURL, err := url.Parse(unreachable.URL)
+	require.NoError(s.T(), err)
+
+	send := func(transport http.RoundTripper, ctx gocontext.Context, target string) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		require.NoError(s.T(), err)
+		if resp, err := transport.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	s.Run("responses of the member cluster", func() {
+		// given
+		proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+		transport := newObservedTransport(http.DefaultTransport, proxyMetrics, access.NewMemberClusterAccess("member-1", *memberURL, "", "", nil))
+
+		// when
+		send(transport, gocontext.Background(), member.URL+"/ok")
+		send(transport, gocontext.Background(), member.URL+"/unavailable")
+		send(transport, gocontext.Background(), member.URL+"/unavailable")
+
+		// then
+		counter := proxyMetrics.RegServProxyUpstreamResponsesCounterVec
+		assert.Equal(s.T(), 2, promtestutil.CollectAndCount(counter))
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues("member-1", "2xx")), 0.01)
+		assert.InDelta(s.T(), 2, promtestutil.ToFloat64(counter.WithLabelValues("member-1", "5xx")), 0.01)
+	})
+
+	s.Run("member cluster not reachable", func() {
+		// given
+		proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+		transport := newObservedTransport(http.DefaultTransport, proxyMetrics, access.NewMemberClusterAccess("member-2", *unreachableURL, "", "", nil))
+
+		// when
+		send(transport, gocontext.Background(), unreachable.URL)
+
+		// then
+		counter := proxyMetrics.RegServProxyUpstreamResponsesCounterVec
+		assert.Equal(s.T(), 1, promtestutil.CollectAndCount(counter))
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(counter.WithLabelValues("member-2", metrics.MetricLabelError)), 0.01)
+	})
+
+	s.Run("request canceled by the client not counted", func() {
+		// given
+		proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+		transport := newObservedTransport(http.DefaultTransport, proxyMetrics, access.NewMemberClusterAccess("member-1", *memberURL, "", "", nil))
+		ctx, cancel := gocontext.WithCancel(gocontext.Background())
+		cancel()
+
+		// when
+		send(transport, ctx, member.URL+"/ok")
+
+		// then
+		assert.Zero(s.T(), promtestutil.CollectAndCount(proxyMetrics.RegServProxyUpstreamResponsesCounterVec))
+	})
+
+	s.Run("host of the proxy plugin backend", func() {
+		// given
+		proxyMetrics := metrics.NewProxyMetrics(prometheus.NewRegistry())
+		transport := newObservedTransport(http.DefaultTransport, proxyMetrics, access.NewClusterAccess(*memberURL, "", ""))
+
+		// when
+		send(transport, gocontext.Background(), member.URL+"/ok")
+
+		// then
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(proxyMetrics.RegServProxyUpstreamResponsesCounterVec.WithLabelValues(memberURL.Host, "2xx")), 0.01)
+	})
+}
+
+func (s *TestProxySuite) TestObserveUpgradedConnection() {
+	// given
+	p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+	memberURL, err := url.Parse("https://api.member-1.example.com:6443")
+	require.NoError(s.T(), err)
+	observe := p.observeUpgradedConnection(access.NewMemberClusterAccess("member-1", *memberURL, "", "", nil), time.Now().Add(-90*time.Second))
+
+	// when
+	observe(5000, 200)
+
+	// then
+	duration, err := p.metrics.RegServProxyUpgradedConnectionDurationHistogramVec.GetMetricWithLabelValues("member-1")
+	require.NoError(s.T(), err)
+	s.assertHistogram(duration, 1, 90)
+	upstream, err := p.metrics.RegServProxyUpgradedConnectionBytesHistogramVec.GetMetricWithLabelValues("member-1", metrics.MetricLabelUpstream)
+	require.NoError(s.T(), err)
+	s.assertHistogram(upstream, 1, 200)
+	downstream, err := p.metrics.RegServProxyUpgradedConnectionBytesHistogramVec.GetMetricWithLabelValues("member-1", metrics.MetricLabelDownstream)
+	require.NoError(s.T(), err)
+	s.assertHistogram(downstream, 1, 5000)
+}
+
+func (s *TestProxySuite) assertHistogram(observer prometheus.Observer, expectedCount uint64, expectedSum float64) {
+	m := &clientmodel.Metric{}
+	require.NoError(s.T(), observer.(prometheus.Metric).Write(m))
+	assert.Equal(s.T(), expectedCount, m.GetHistogram().GetSampleCount())
+	assert.InDelta(s.T(), expectedSum, m.GetHistogram().GetSampleSum(), 1)
+}
+
+func (s *TestProxySuite) TestRouteType() {
+	tests := map[string]struct {
+		path      string
+		workspace string
+		expected  string
+	}{
+		"implicit workspace": {
+			path:     "/api/v1/namespaces/smith-dev/pods",
+			expected: metrics.MetricLabelRouteImplicit,
+		},
+		"explicit workspace with the prefix of the path": {
+			path:      "/workspaces/smith-dev/api/v1/namespaces/smith-dev/pods",
+			workspace: "smith-dev",
+			expected:  metrics.MetricLabelRouteWorkspace,
+		},
+		"explicit workspace with the header": {
+			path:      "/api/v1/namespaces/smith-dev/pods",
+			workspace: "smith-dev",
+			expected:  metrics.MetricLabelRouteWorkspace,
+		},
+		"invalid workspace request": {
+			path:     "/workspaces/smith-dev",
+			expected: metrics.MetricLabelRouteWorkspace,
+		},
+		"proxy plugin": {
+			path:     "/plugins/tekton-results/workspaces/smith-dev/apis/results.tekton.dev/v1alpha2/parents",
+			expected: metrics.MetricLabelRoutePlugin,
+		},
+	}
+
+	for k, tc := range tests {
+		s.Run(k, func() {
+			// given
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, tc.path, nil), httptest.NewRecorder())
+			if tc.workspace != "" {
+				ctx.Set(context.WorkspaceKey, tc.workspace)
+			}
+
+			// then
+			assert.Equal(s.T(), tc.expected, routeType(ctx, tc.path))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/labstack/echo/v4"
+)
+
+// watchLifetimeGracePeriod is how long a watch may outlive its maximum lifetime before the proxy aborts it, so that the member
+// clusters can end the watches cleanly, with the timeout of the watches set by the proxy
+const watchLifetimeGracePeriod = 10 * time.Second
+
+// isWatchRequest returns true if the given request is a watch, ie. a long poll of the Kubernetes API, with the `watch` parameter
+// or with the deprecated `/watch/` prefix of the path
+func isWatchRequest(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+	if watch, _ := strconv.ParseBool(req.URL.Query().Get("watch")); watch {
+		return true
+	}
+	return strings.Contains(strings.TrimSuffix(req.URL.Path, "/"), "/watch/")
+}
+
+// startWatch counts the watch of the request as an active watch of the given target and caps its lifetime (see the WatchMaxLifetime
+// setting), until the returned func is called once the watch ended. The watches forwarded to a member cluster are ended by the
+// member cluster itself, with a `timeoutSeconds` parameter which doesn't exceed the maximum lifetime, and are aborted by the proxy
+// after the watchLifetimeGracePeriod otherwise (eg. the watches of the proxy plugin backends).
+func (p *Proxy) startWatch(ctx echo.Context, target *access.ClusterAccess, isPlugin bool) func() {
+	activeWatches := p.metrics.RegServProxyActiveWatchesGaugeVec.WithLabelValues(upstreamName(target))
+	activeWatches.Inc()
+	cancel := context.CancelFunc(func() {})
+	if maxLifetime := configuration.GetRegistrationServiceConfig().Proxy().WatchMaxLifetime(); maxLifetime > 0 {
+		req := ctx.Request()
+		if !isPlugin {
+			capWatchTimeout(req, maxLifetime)
+		}
+		var watchCtx context.Context
+		watchCtx, cancel = context.WithTimeout(req.Context(), maxLifetime+watchLifetimeGracePeriod)
+		ctx.SetRequest(req.WithContext(watchCtx))
+	}
+	return func() {
+		cancel()
+		activeWatches.Dec()
+	}
+}
+
+// capWatchTimeout sets the `timeoutSeconds` parameter of the given watch request to the given maximum lifetime, unless the
+// request has a shorter timeout
+func capWatchTimeout(req *http.Request, maxLifetime time.Duration) {
+	maxSeconds := max(int64(maxLifetime.Seconds()), 1)
+	query := req.URL.Query()
+	if timeout, err := strconv.ParseInt(query.Get("timeoutSeconds"), 10, 64); err == nil && timeout > 0 && timeout <= maxSeconds {
+		return
+	}
+	query.Set("timeoutSeconds", strconv.FormatInt(maxSeconds, 10))
+	req.URL.RawQuery = query.Encode()
+}
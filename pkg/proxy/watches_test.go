@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/access"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/metrics"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestIsWatchRequest() {
+	for target, expected := range map[string]bool{
+		"/api/v1/namespaces/smith-dev/pods":                            false,
+		"/api/v1/namespaces/smith-dev/pods?watch=true":                 true,
+		"/api/v1/namespaces/smith-dev/pods?watch=1&resourceVersion=10": true,
+		"/api/v1/namespaces/smith-dev/pods?watch=false":                false,
+		"/api/v1/watch/namespaces/smith-dev/pods":                      true,
+		"/apis/apps/v1/watch/namespaces/smith-dev/deployments/":        true,
+		"/api/v1/namespaces/smith-dev/configmaps/watch":                false,
+		"/api/v1/namespaces/smith-dev/pods/mypod/log?follow=true":      false,
+	} {
+		s.Run(target, func() {
+			// given
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+
+			// then
+			assert.Equal(s.T(), expected, isWatchRequest(req))
+		})
+	}
+
+	s.Run("not a GET request", func() {
+		// given
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/namespaces/smith-dev/pods?watch=true", nil)
+
+		// then
+		assert.False(s.T(), isWatchRequest(req))
+	})
+}
+
+func (s *TestProxySuite) TestStartWatch() {
+	// given
+	memberURL, err := url.Parse("https://api.member-1.example.com:6443")
+	require.NoError(s.T(), err)
+	target := access.NewMemberClusterAccess("member-1", *memberURL, "", "", nil)
+	newWatch := func(target string) echo.Context {
+		return echo.New().NewContext(httptest.NewRequest(http.MethodGet, target, nil), httptest.NewRecorder())
+	}
+
+	s.Run("active watches counted", func() {
+		// given
+		p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+		activeWatches := p.metrics.RegServProxyActiveWatchesGaugeVec.WithLabelValues("member-1")
+
+		// when
+		end1 := p.startWatch(newWatch("/api/v1/namespaces/smith-dev/pods?watch=true"), target, false)
+		end2 := p.startWatch(newWatch("/api/v1/namespaces/smith-dev/configmaps?watch=true"), target, false)
+
+		// then
+		assert.InDelta(s.T(), 2, promtestutil.ToFloat64(activeWatches), 0.01)
+		end1()
+		assert.InDelta(s.T(), 1, promtestutil.ToFloat64(activeWatches), 0.01)
+		end2()
+		assert.InDelta(s.T(), 0, promtestutil.ToFloat64(activeWatches), 0.01)
+	})
+
+	s.Run("no maximum lifetime", func() {
+		// given
+		p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+		ctx := newWatch("/api/v1/namespaces/smith-dev/pods?watch=true")
+
+		// when
+		end := p.startWatch(ctx, target, false)
+		defer end()
+
+		// then
+		assert.Equal(s.T(), "watch=true", ctx.Request().URL.RawQuery)
+		_, found := ctx.Request().Context().Deadline()
+		assert.False(s.T(), found)
+	})
+
+	s.Run("maximum lifetime", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_PROXY_WATCH_MAX_LIFETIME", "30m")
+
+		for path, expected := range map[string]string{
+			"/api/v1/namespaces/smith-dev/pods?watch=true":                     "1800",
+			"/api/v1/namespaces/smith-dev/pods?watch=true&timeoutSeconds=300":  "300",
+			"/api/v1/namespaces/smith-dev/pods?watch=true&timeoutSeconds=3600": "1800",
+			"/api/v1/namespaces/smith-dev/pods?watch=true&timeoutSeconds=0":    "1800",
+			"/api/v1/namespaces/smith-dev/pods?watch=true&timeoutSeconds=oops": "1800",
+		} {
+			s.Run(path, func() {
+				// given
+				p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+				ctx := newWatch(path)
+
+				// when
+				end := p.startWatch(ctx, target, false)
+
+				// then
+				assert.Equal(s.T(), expected, ctx.Request().URL.Query().Get("timeoutSeconds"))
+				assert.Equal(s.T(), "true", ctx.Request().URL.Query().Get("watch"))
+				deadline, found := ctx.Request().Context().Deadline()
+				require.True(s.T(), found)
+				assert.WithinDuration(s.T(), time.Now().Add(30*time.Minute+watchLifetimeGracePeriod), deadline, time.Minute)
+				end()
+				assert.Error(s.T(), ctx.Request().Context().Err())
+			})
+		}
+
+		s.Run("proxy plugin", func() {
+			// given
+			p := &Proxy{metrics: metrics.NewProxyMetrics(prometheus.NewRegistry())}
+			ctx := newWatch("/events?watch=true")
+
+			// when
+			end := p.startWatch(ctx, target, true)
+			defer end()
+
+			// then
+			assert.Equal(s.T(), "watch=true", ctx.Request().URL.RawQuery)
+			_, found := ctx.Request().Context().Deadline()
+			assert.True(s.T(), found)
+		})
+	})
+}
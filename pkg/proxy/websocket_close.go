@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"crypto/sha1" // nolint:gosec
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// websocketGUID is the GUID concatenated to the key of the websocket handshake to compute the Sec-WebSocket-Accept header (see RFC 6455)
+	websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	// websocketClosePolicyViolation is the status code of the close frames of the websocket connections rejected by the proxy
+	websocketClosePolicyViolation = 1008
+	// maxWebsocketCloseReasonLength is the maximum length of the reason of a close frame, whose payload is limited to 125 bytes
+	maxWebsocketCloseReasonLength = 123
+)
+
+// closeWebsocket completes the handshake of the websocket request of the given context and immediately sends a close frame with
+// the policy violation code and the given reason, so that the browsers, which don't expose the body of a rejected handshake,
+// get a deterministic error instead of a generic connection failure
+func closeWebsocket(ctx echo.Context, reason string) error {
+	req := ctx.Request()
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return errors.New("missing Sec-WebSocket-Key header")
+	}
+	conn, rw, err := http.NewResponseController(ctx.Response()).Hijack()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx.Response().Status = http.StatusSwitchingProtocols
+	ctx.Response().Committed = true
+
+	accept := sha1.Sum([]byte(key + websocketGUID)) // nolint:gosec
+	handshake := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(accept[:]) + "\r\n"
+	// the browsers fail the connection if none of the requested subprotocols is selected
+	if protocol := websocketSubprotocol(req); protocol != "" {
+		handshake += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if _, err := rw.WriteString(handshake + "\r\n"); err != nil {
+		return err
+	}
+	if len(reason) > maxWebsocketCloseReasonLength {
+		reason = reason[:maxWebsocketCloseReasonLength]
+	}
+	// an unmasked and unfragmented close frame, whose payload is the status code followed by the reason
+	frame := []byte{0x88, byte(2 + len(reason))}
+	frame = binary.BigEndian.AppendUint16(frame, websocketClosePolicyViolation)
+	frame = append(frame, reason...)
+	if _, err := rw.Write(frame); err != nil {
+		return err
+	}
+	if err := rw.Flush(); err != nil {
+		return fmt.Errorf("unable to send the websocket close frame: %w", err)
+	}
+	return nil
+}
+
+// websocketSubprotocol returns the first subprotocol requested by the websocket request, other than the one carrying the token
+func websocketSubprotocol(req *http.Request) string {
+	for _, protocolHeader := range req.Header[ph] {
+		for _, protocol := range strings.Split(protocolHeader, ",") {
+			if protocol = strings.TrimSpace(protocol); protocol != "" && !strings.HasPrefix(protocol, bearerProtocolPrefix) {
+				return protocol
+			}
+		}
+	}
+	return ""
+}
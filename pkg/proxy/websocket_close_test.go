@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestCloseWebsocketOnAuthFailure() {
+	// given
+	router := echo.New()
+	router.HTTPErrorHandler = customHTTPErrorHandler
+	router.Any("/*", func(_ echo.Context) error {
+		return crterrors.NewUnauthorizedError("invalid bearer token", "token is expired")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	s.Run("websocket request", func() {
+		// given
+		conn, err := net.Dial("tcp", server.Listener.Addr().String())
+		require.NoError(s.T(), err)
+		defer conn.Close()
+
+		// when
+		_, err = io.WriteString(conn, "GET /api/v1/namespaces/smith-dev/pods?watch=true HTTP/1.1\r\n"+
+			"Host: "+server.Listener.Addr().String()+"\r\n"+
+			"Upgrade: websocket\r\n"+
+			"Connection: Upgrade\r\n"+
+			"Sec-WebSocket-Version: 13\r\n"+
+			"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+			"Sec-WebSocket-Protocol: base64url.bearer.authorization.k8s.io.dG9rZW4, base64.binary.k8s.io\r\n\r\n")
+		require.NoError(s.T(), err)
+
+		// then the handshake is completed
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, nil)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), http.StatusSwitchingProtocols, resp.StatusCode)
+		assert.Equal(s.T(), "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+		assert.Equal(s.T(), "base64.binary.k8s.io", resp.Header.Get("Sec-WebSocket-Protocol"))
+		// and followed by a close frame
+		frame, err := io.ReadAll(reader)
+		require.NoError(s.T(), err)
+		require.Greater(s.T(), len(frame), 4)
+		assert.Equal(s.T(), byte(0x88), frame[0])
+		assert.Equal(s.T(), len(frame)-2, int(frame[1]))
+		assert.Equal(s.T(), uint16(websocketClosePolicyViolation), binary.BigEndian.Uint16(frame[2:4]))
+		assert.Equal(s.T(), "invalid bearer token: token is expired", string(frame[4:]))
+	})
+
+	s.Run("response can't be hijacked", func() {
+		// given
+		rr := httptest.NewRecorder()
+		ctx := router.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), rr)
+		ctx.Request().Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+
+		// when
+		err := closeWebsocket(ctx, "invalid bearer token")
+
+		// then
+		require.Error(s.T(), err)
+		assert.False(s.T(), ctx.Response().Committed)
+	})
+
+	s.Run("plain request", func() {
+		// when
+		resp, err := http.Get(server.URL + "/api/v1/namespaces/smith-dev/pods")
+
+		// then
+		require.NoError(s.T(), err)
+		defer resp.Body.Close()
+		assert.Equal(s.T(), http.StatusUnauthorized, resp.StatusCode)
+		s.assertResponseBody(resp, "invalid bearer token: token is expired")
+	})
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// workspaceQuotasPruneInterval is the minimum interval between two removals of the buckets of the idle workspaces
+const workspaceQuotasPruneInterval = time.Minute
+
+// quotaBucket is the token bucket of the requests to a workspace
+type quotaBucket struct {
+	tokens float64
+	last   time.Time
+	// full is the time at which the bucket is full again if no more request is received
+	full time.Time
+}
+
+// WorkspaceQuotas enforces the quotas of requests per second to the workspaces (see the WorkspaceQuota setting),
+// whichever the users sending them
+type WorkspaceQuotas struct {
+	mu        sync.Mutex
+	buckets   map[string]*quotaBucket
+	lastPrune time.Time
+	rejected  prometheus.Counter
+}
+
+// NewWorkspaceQuotas returns a new WorkspaceQuotas counting the rejected requests with the given counter
+func NewWorkspaceQuotas(rejected prometheus.Counter) *WorkspaceQuotas {
+	return &WorkspaceQuotas{
+		buckets:   map[string]*quotaBucket{},
+		lastPrune: time.Now(),
+		rejected:  rejected,
+	}
+}
+
+// workspaceQuota returns the configured quota of requests per second and the burst of the given workspace,
+// or zero if the workspace has no quota
+func workspaceQuota(workspace string) (int, int) {
+	cfg := configuration.GetRegistrationServiceConfig().Proxy()
+	quota := cfg.WorkspaceQuota()
+	if override, found := cfg.WorkspaceQuotaOverrides()[workspace]; found {
+		quota = override
+	}
+	if quota <= 0 {
+		return 0, 0
+	}
+	return quota, max(cfg.WorkspaceQuotaBurst(), quota)
+}
+
+// Allow records a request to the given workspace and returns false if the workspace exceeded the given quota of requests
+// per second, with the given burst. The rejected requests are not recorded. There is no quota when the given quota is zero.
+func (q *WorkspaceQuotas) Allow(workspace string, now time.Time, quota, burst int) bool {
+	if quota <= 0 {
+		return true
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.prune(now)
+
+	bucket, found := q.buckets[workspace]
+	if !found {
+		bucket = &quotaBucket{tokens: float64(burst), last: now}
+		q.buckets[workspace] = bucket
+	}
+	bucket.tokens = min(float64(burst), bucket.tokens+now.Sub(bucket.last).Seconds()*float64(quota))
+	bucket.last = now
+	if bucket.tokens < 1 {
+		q.rejected.Inc()
+		return false
+	}
+	bucket.tokens--
+	bucket.full = now.Add(time.Duration((float64(burst) - bucket.tokens) / float64(quota) * float64(time.Second)))
+	return true
+}
+
+// prune removes the buckets of the workspaces which would be full again, so that the map doesn't grow forever
+func (q *WorkspaceQuotas) prune(now time.Time) {
+	if now.Sub(q.lastPrune) < workspaceQuotasPruneInterval {
+		return
+	}
+	for workspace, bucket := range q.buckets {
+		if !now.Before(bucket.full) {
+			delete(q.buckets, workspace)
+		}
+	}
+	q.lastPrune = now
+}
+
+// checkWorkspaceQuota returns a TooManyRequests error if the workspace targeted by the request exceeded its quota
+func (p *Proxy) checkWorkspaceQuota(ctx echo.Context) error {
+	workspace := targetWorkspace(ctx)
+	quota, burst := workspaceQuota(workspace)
+	if workspace == "" || p.workspaceQuotas.Allow(workspace, time.Now(), quota, burst) {
+		return nil
+	}
+	log.InfoEchof(ctx, "rejecting the request: the workspace '%s' exceeded its quota", workspace)
+	ctx.Response().Header().Set("Retry-After", "1")
+	return crterrors.NewTooManyRequestsError("too many requests", fmt.Sprintf("the quota of %d requests per second of the workspace '%s' is exceeded", quota, workspace))
+}
@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (s *TestProxySuite) TestWorkspaceQuotas() {
+	now := time.Now()
+
+	s.Run("no quota", func() {
+		// given
+		quotas := NewWorkspaceQuotas(prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"}))
+
+		// then
+		for i := 0; i < 100; i++ {
+			assert.True(s.T(), quotas.Allow("smith-dev", now, 0, 0))
+		}
+	})
+
+	s.Run("quota exceeded", func() {
+		// given
+		rejected := prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"})
+		quotas := NewWorkspaceQuotas(rejected)
+
+		// when the burst is consumed
+		for i := 0; i < 4; i++ {
+			require.True(s.T(), quotas.Allow("smith-dev", now, 2, 4))
+		}
+
+		// then
+		assert.False(s.T(), quotas.Allow("smith-dev", now, 2, 4))
+		assert.False(s.T(), quotas.Allow("smith-dev", now.Add(100*time.Millisecond), 2, 4))
+		assert.True(s.T(), quotas.Allow("alice-dev", now, 2, 4), "the quotas should be distinct for each workspace")
+		// one request is allowed every 500ms
+		assert.True(s.T(), quotas.Allow("smith-dev", now.Add(500*time.Millisecond), 2, 4))
+		assert.False(s.T(), quotas.Allow("smith-dev", now.Add(600*time.Millisecond), 2, 4))
+		assert.InDelta(s.T(), 3, promtestutil.ToFloat64(rejected), 0.01)
+	})
+
+	s.Run("idle workspaces are pruned", func() {
+		// given
+		quotas := NewWorkspaceQuotas(prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"}))
+		for i := 0; i < 150; i++ {
+			require.True(s.T(), quotas.Allow("smith-dev", now, 1, 200))
+		}
+		require.True(s.T(), quotas.Allow("alice-dev", now.Add(time.Minute), 1, 1))
+
+		// when
+		assert.True(s.T(), quotas.Allow("bob-dev", now.Add(2*time.Minute), 1, 1))
+
+		// then the bucket of smith-dev is kept until it is full again
+		assert.Contains(s.T(), quotas.buckets, "smith-dev")
+		assert.NotContains(s.T(), quotas.buckets, "alice-dev")
+		assert.Contains(s.T(), quotas.buckets, "bob-dev")
+	})
+}
+
+func (s *TestProxySuite) TestCheckWorkspaceQuota() {
+	// given
+	p := &Proxy{workspaceQuotas: NewWorkspaceQuotas(prometheus.NewCounter(prometheus.CounterOpts{Name: "rejected"}))}
+	newContext := func(key, workspace string) (echo.Context, *httptest.ResponseRecorder) {
+		rr := httptest.NewRecorder()
+		ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil), rr)
+		ctx.Set(key, workspace)
+		return ctx, rr
+	}
+
+	s.Run("no quota", func() {
+		for i := 0; i < 10; i++ {
+			ctx, _ := newContext(context.WorkspaceKey, "smith-dev")
+			require.NoError(s.T(), p.checkWorkspaceQuota(ctx))
+		}
+	})
+
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA", "1")
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA_BURST", "2")
+	s.T().Setenv("REGISTRATION_SERVICE_PROXY_WORKSPACE_QUOTA_OVERRIDES", "community=3,internal=0")
+
+	for _, tc := range []struct {
+		name      string
+		key       string
+		workspace string
+		allowed   int
+	}{
+		{"workspace", context.WorkspaceKey, "alice-dev", 2},
+		{"home workspace", context.HomeWorkspaceKey, "bob-dev", 2},
+		{"overridden quota", context.WorkspaceKey, "community", 3},
+	} {
+		s.Run(tc.name, func() {
+			// given
+			for i := 0; i < tc.allowed; i++ {
+				ctx, _ := newContext(tc.key, tc.workspace)
+				require.NoError(s.T(), p.checkWorkspaceQuota(ctx))
+			}
+			ctx, rr := newContext(tc.key, tc.workspace)
+
+			// when
+			err := p.checkWorkspaceQuota(ctx)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(s.T(), err, &crtErr)
+			assert.Equal(s.T(), http.StatusTooManyRequests, crtErr.Code)
+			assert.Contains(s.T(), crtErr.Details, tc.workspace)
+			assert.Equal(s.T(), "1", rr.Header().Get("Retry-After"))
+		})
+	}
+
+	s.Run("quota disabled for the workspace", func() {
+		for i := 0; i < 10; i++ {
+			ctx, _ := newContext(context.WorkspaceKey, "internal")
+			require.NoError(s.T(), p.checkWorkspaceQuota(ctx))
+		}
+	})
+}
@@ -8,10 +8,10 @@ import (
 	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
 )
 
-// NewInClusterApplication creates a new in-cluster application.
-func NewInClusterApplication(client namespaced.Client) application.Application {
+// NewInClusterApplication creates a new in-cluster application, with the given options of its signup service.
+func NewInClusterApplication(client namespaced.Client, opts ...signupservice.SignupServiceOption) application.Application {
 	return &InClusterApplication{
-		signupService:       signupservice.NewSignupService(client),
+		signupService:       signupservice.NewSignupService(client, opts...),
 		verificationService: verificationservice.NewVerificationService(client),
 	}
 }
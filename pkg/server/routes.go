@@ -11,7 +11,7 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/middleware"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaces"
-	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/registration-service/pkg/proxy/handlers"
 	"github.com/gin-gonic/gin"
 
 	"github.com/gin-contrib/static"
@@ -23,7 +23,8 @@ import (
 // proxyPort is the API Proxy Server port to be used to setup a route for the health checker for the proxy.
 func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Registry, nsClient namespaced.Client) error {
 	var err error
-	_, err = auth.InitializeDefaultTokenParser()
+	var tokenParser *auth.TokenParser
+	tokenParser, err = auth.InitializeDefaultTokenParser()
 	if err != nil {
 		return err
 	}
@@ -51,8 +52,16 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		[]string{"code", "method", "path"},
 	)
 
+	tokenFailuresCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sandbox_auth_token_validation_failures_total",
+			Help: "A counter for the tokens rejected by the auth middleware, per reason.",
+		},
+		[]string{"reason"},
+	)
+
 	// Register all of the metrics in the standard registry.
-	reg.MustRegister(counter, histVec, inFlightGauge)
+	reg.MustRegister(counter, histVec, inFlightGauge, tokenFailuresCounter)
 
 	srv.routesSetup.Do(func() {
 		// creating the controllers
@@ -60,9 +69,18 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		authConfigCtrl := controller.NewAuthConfig()
 		analyticsCtrl := controller.NewAnalytics()
 		signupCtrl := controller.NewSignup(srv.application)
-		namespacesCtrl := controller.NewNamespacesController(namespaces.NewNamespacesManager(cluster.GetMemberClusters, nsClient, srv.application.SignupService()))
+		namespacesCtrl := controller.NewNamespacesController(namespaces.NewNamespacesManager(srv.getMembersFunc, nsClient, srv.application.SignupService()))
 		usernamesCtrl := controller.NewUsernames(nsClient)
 		uiConfigCtrl := controller.NewUIConfig()
+		personalAccessTokensCtrl := controller.NewPersonalAccessTokens()
+		tokenExchangeCtrl := controller.NewTokenExchange(tokenParser)
+		deviceAuthorizationCtrl := controller.NewDeviceAuthorization()
+		userSignupsAdminCtrl := controller.NewUserSignupsAdmin(nsClient)
+		userSignupMergeCtrl := controller.NewUserSignupMerge(nsClient)
+		bansCtrl := controller.NewBans(nsClient, srv.banListener)
+		workspacesCtrl := controller.NewWorkspaces(handlers.NewSpaceLister(nsClient, srv.application, nil), srv.getMembersFunc) // no proxy metrics
+		usageCtrl := controller.NewUsage(nsClient, srv.application.SignupService(), srv.getMembersFunc)
+		termsOfServiceCtrl := controller.NewTermsOfService(srv.application)
 
 		// unsecured routes
 		unsecuredV1 := srv.router.Group("/api/v1")
@@ -73,16 +91,23 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 		unsecuredV1.GET("/health", healthCheckCtrl.GetHandler) // TODO: move to root (`/`)?
 		unsecuredV1.GET("/authconfig", authConfigCtrl.GetHandler)
 		// segment keys endpoints
-		unsecuredV1.GET("/segment-write-key", analyticsCtrl.GetDevSpacesSegmentWriteKey)         // expose the devspaces segment key
-		unsecuredV1.GET("/analytics/segment-write-key", analyticsCtrl.GetSandboxSegmentWriteKey) // expose the sandbox segment key.We had the create a new analytics endpoint to keep backward compatibility with devspaces.
+		unsecuredV1.GET("/segment-write-key", analyticsCtrl.GetDevSpacesSegmentWriteKey)               // expose the devspaces segment key
+		unsecuredV1.GET("/analytics/segment-write-key", analyticsCtrl.GetSandboxSegmentWriteKey)       // expose the sandbox segment key.We had the create a new analytics endpoint to keep backward compatibility with devspaces.
+		unsecuredV1.GET("/analytics/:destination/segment-write-key", analyticsCtrl.GetSegmentWriteKey) // expose the segment key of any configured analytics destination
+		unsecuredV1.POST("/tokens/exchange", tokenExchangeCtrl.PostHandler)                            // the SSO token is in the body of the request (see RFC 8693)
+		unsecuredV1.POST("/device/authorize", deviceAuthorizationCtrl.StartHandler)                    // the CLI logs in with the device authorization flow (see RFC 8628)
+		unsecuredV1.POST("/device/token", deviceAuthorizationCtrl.TokenHandler)
+		unsecuredV1.GET("/signup/verification/email-link", signupCtrl.VerifyEmailLinkHandler) // the link sent by email is opened without the SSO token
+		unsecuredV1.GET("/terms-of-service", termsOfServiceCtrl.GetHandler)
 
 		// create the auth middleware
 		var authMiddleware *middleware.JWTMiddleware
-		authMiddleware, err = middleware.NewAuthMiddleware()
+		authMiddleware, err = middleware.NewAuthMiddleware(tokenFailuresCounter)
 		if err != nil {
 			err = errs.Wrapf(err, "failed to init auth middleware")
 			return
 		}
+		pollingLimiter := middleware.NewPollingLimiter()
 		receivedTimeMw := func(ctx *gin.Context) {
 			ctx.Set(rcontext.RequestReceivedTime, time.Now())
 		}
@@ -96,13 +121,31 @@ func (srv *RegistrationServer) SetupRoutes(proxyPort string, reg *prometheus.Reg
 			receivedTimeMw)
 		securedV1.POST("/reset-namespaces", namespacesCtrl.ResetNamespaces)
 		securedV1.POST("/signup", signupCtrl.PostHandler)
+		securedV1.PATCH("/signup", signupCtrl.PatchHandler)   // the users refresh their profile from their token or from the body
+		securedV1.DELETE("/signup", signupCtrl.DeleteHandler) // the users deactivate their own account, with the `confirm` query parameter
+		// the users erase the personal data of their deactivated account, with the `confirm` query parameter
+		securedV1.POST("/signup/anonymize", signupCtrl.AnonymizeHandler)
 		// requires a ctx body containing the country_code and phone_number
 		securedV1.PUT("/signup/verification", signupCtrl.InitVerificationHandler)
-		securedV1.GET("/signup", signupCtrl.GetHandler)
+		securedV1.GET("/signup", pollingLimiter.HandlerFunc(), signupCtrl.GetHandler)
 		securedV1.GET("/signup/verification/:code", signupCtrl.VerifyPhoneCodeHandler) // TODO: also provide a `POST /signup/verification/phone-code` +deprecate this one + migrate UI?
 		securedV1.POST("/signup/verification/activation-code", signupCtrl.VerifyActivationCodeHandler)
+		securedV1.PUT("/signup/verification/email", signupCtrl.InitEmailVerificationHandler) // sends a verification link to the email address of the user
+		securedV1.POST("/signup/terms-of-service", termsOfServiceCtrl.AcceptHandler)         // the users accept a new version of the terms of service
 		securedV1.GET("/usernames/:username", usernamesCtrl.GetHandler)
 		securedV1.GET("/uiconfig", uiConfigCtrl.GetHandler)
+		securedV1.POST("/tokens", personalAccessTokensCtrl.PostHandler)
+		securedV1.GET("/workspaces", workspacesCtrl.ListHandler) // the same workspaces as in the workspaces API of the proxy
+		securedV1.GET("/workspaces/:name", workspacesCtrl.GetHandler)
+		securedV1.GET("/usage", usageCtrl.GetHandler) // the quotas and the consumption of the namespaces of the user
+		// the admin endpoints are restricted to the users of the support admins setting
+		securedV1.GET("/admin/signups", userSignupsAdminCtrl.ListHandler)
+		securedV1.POST("/admin/signups/:name/approve", userSignupsAdminCtrl.ApproveHandler)
+		securedV1.POST("/admin/signups/:name/decline", userSignupsAdminCtrl.DeclineHandler)
+		securedV1.POST("/admin/signups/:name/anonymize", userSignupsAdminCtrl.AnonymizeHandler)
+		// merges the duplicate UserSignup of the body into the surviving one
+		securedV1.POST("/admin/signups/merge", userSignupMergeCtrl.PostHandler)
+		securedV1.POST("/admin/bans", bansCtrl.PostHandler) // the proxy rejects the banned user right away
 
 		// if we are in testing mode, we also add a secured health route for testing
 		if configuration.IsTestingMode() {
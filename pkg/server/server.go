@@ -12,6 +12,9 @@ import (
 
 	"github.com/codeready-toolchain/registration-service/pkg/application"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/controller"
+	"github.com/codeready-toolchain/registration-service/pkg/middleware"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/gzip"
@@ -27,11 +30,29 @@ type RegistrationServer struct {
 	httpServer  *http.Server
 	routesSetup sync.Once
 	//applicationProducerFunc func() application.Application
-	application application.Application
+	application    application.Application
+	getMembersFunc cluster.GetMemberClustersFunc
+	// banListener is notified of the users banned with the admin ban endpoint, if any
+	banListener controller.BanListener
+}
+
+// WithGetMembersFunc sets the function returning the member clusters, which defaults to cluster.GetMemberClusters
+func WithGetMembersFunc(getMembersFunc cluster.GetMemberClustersFunc) ServerOption {
+	return func(server *RegistrationServer) {
+		server.getMembersFunc = getMembersFunc
+	}
+}
+
+// WithBanListener sets the listener notified of the users banned with the admin ban endpoint, eg. to invalidate the caches
+// of the proxy
+func WithBanListener(listener controller.BanListener) ServerOption {
+	return func(server *RegistrationServer) {
+		server.banListener = listener
+	}
 }
 
 // New creates a new RegistrationServer object with reasonable defaults.
-func New(application application.Application) *RegistrationServer {
+func New(application application.Application, opts ...ServerOption) *RegistrationServer {
 
 	gin.SetMode(gin.ReleaseMode)
 	ginRouter := gin.New()
@@ -64,15 +85,20 @@ func New(application application.Application) *RegistrationServer {
 		cors.New(cors.Config{
 			AllowAllOrigins:  true,
 			AllowMethods:     []string{"PUT", "PATCH", "POST", "GET", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Content-Length", "Content-Type", "Authorization", "Accept", "Recaptcha-Token"},
-			ExposeHeaders:    []string{"Content-Length", "Authorization"},
+			AllowHeaders:     []string{"Content-Length", "Content-Type", "Authorization", "Accept", "Recaptcha-Token", controller.IdempotencyKeyHeader},
+			ExposeHeaders:    []string{"Content-Length", "Authorization", controller.IdempotentReplayedHeader},
 			AllowCredentials: true,
 		}),
+		middleware.SecurityHeaders(),
 	)
 
 	srv := &RegistrationServer{
-		router:      ginRouter,
-		application: application,
+		router:         ginRouter,
+		application:    application,
+		getMembersFunc: cluster.GetMemberClusters,
+	}
+	for _, opt := range opts {
+		opt(srv)
 	}
 
 	gin.DefaultWriter = io.MultiWriter(os.Stdout)
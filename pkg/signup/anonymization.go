@@ -0,0 +1,58 @@
+package signup
+
+import (
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	"github.com/gin-gonic/gin"
+)
+
+// AnonymizedAtAnnotationKey is the annotation set with the time the personal data of a UserSignup was erased
+const AnonymizedAtAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "anonymized-at"
+
+// Anonymize erases the personal data of the deactivated UserSignup with the given name, ie. its identity claims, and the hashes of
+// the email address and of the phone number of the user unless they are retained for the abuse prevention (see the Anonymization
+// settings). Nothing is changed if the UserSignup is already anonymized. Returns a Conflict error if the UserSignup is not
+// deactivated, and a NotFound error if there is no UserSignup with such a name.
+// The name of the UserSignup (ie. the encoded username) and its compliant username are kept, since the name can't be changed
+// and the compliant username is managed by the host operator. Instead, they are not returned by the admin endpoints
+// once the UserSignup is anonymized, and the UserSignup can't be reactivated (see IsAnonymized).
+func Anonymize(ctx *gin.Context, cl namespaced.Client, name string) (*toolchainv1alpha1.UserSignup, error) {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := cl.Get(ctx, cl.NamespacedName(name), userSignup); err != nil {
+		return nil, err
+	}
+	if !states.Deactivated(userSignup) {
+		return nil, crterrors.NewConflictError("the UserSignup is not deactivated",
+			fmt.Sprintf("the UserSignup '%s' must be deactivated before its personal data is erased", name))
+	}
+	if IsAnonymized(userSignup) {
+		return userSignup, nil
+	}
+
+	userSignup.Spec.IdentityClaims = toolchainv1alpha1.IdentityClaimsEmbedded{}
+	cfg := configuration.GetRegistrationServiceConfig().Anonymization()
+	if !cfg.RetainEmailHash() {
+		delete(userSignup.Labels, toolchainv1alpha1.UserSignupUserEmailHashLabelKey)
+	}
+	if !cfg.RetainPhoneHash() {
+		delete(userSignup.Labels, toolchainv1alpha1.UserSignupUserPhoneHashLabelKey)
+	}
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[AnonymizedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	return userSignup, cl.Update(ctx, userSignup)
+}
+
+// IsAnonymized returns true if the personal data of the given UserSignup was erased. Such a UserSignup can't be reactivated,
+// since that would tie the new personal data of the user to the remaining identifiers of the erased account.
+func IsAnonymized(userSignup *toolchainv1alpha1.UserSignup) bool {
+	_, anonymized := userSignup.Annotations[AnonymizedAtAnnotationKey]
+	return anonymized
+}
@@ -0,0 +1,100 @@
+package signup
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestAnonymize(t *testing.T) {
+	// given
+	log.Init("anonymization-testing")
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	newUserSignup := func(modifiers ...testusersignup.Modifier) *toolchainv1alpha1.UserSignup {
+		modifiers = append([]testusersignup.Modifier{
+			testusersignup.WithName("jsmith"),
+			testusersignup.WithEmail("jsmith@kubesaw.io"),
+			testusersignup.WithUserID("12345"),
+			testusersignup.WithLabel(toolchainv1alpha1.UserSignupUserPhoneHashLabelKey, "fd276563a8232d16620da8ec85d0575f"),
+		}, modifiers...)
+		userSignup := testusersignup.NewUserSignup(modifiers...)
+		userSignup.Spec.IdentityClaims.GivenName = "John"
+		userSignup.Spec.IdentityClaims.FamilyName = "Smith"
+		return userSignup
+	}
+	anonymize := func(t *testing.T, userSignup *toolchainv1alpha1.UserSignup) (*toolchainv1alpha1.UserSignup, error) {
+		fakeClient := commontest.NewFakeClient(t, userSignup)
+		_, err := Anonymize(ctx, namespaced.NewClient(fakeClient, commontest.HostOperatorNs), "jsmith")
+		if err != nil {
+			return nil, err
+		}
+		anonymized := &toolchainv1alpha1.UserSignup{}
+		require.NoError(t, fakeClient.Get(context.TODO(), client.ObjectKeyFromObject(userSignup), anonymized))
+		return anonymized, nil
+	}
+
+	t.Run("anonymized", func(t *testing.T) {
+		// when
+		anonymized, err := anonymize(t, newUserSignup(testusersignup.Deactivated()))
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, toolchainv1alpha1.IdentityClaimsEmbedded{}, anonymized.Spec.IdentityClaims)
+		assert.NotEmpty(t, anonymized.Labels[toolchainv1alpha1.UserSignupUserEmailHashLabelKey])
+		assert.NotContains(t, anonymized.Labels, toolchainv1alpha1.UserSignupUserPhoneHashLabelKey)
+		assert.NotEmpty(t, anonymized.Annotations[AnonymizedAtAnnotationKey])
+	})
+
+	t.Run("with the policy of the hashes", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_ANONYMIZATION_RETAIN_EMAIL_HASH", "false")
+		t.Setenv("REGISTRATION_SERVICE_ANONYMIZATION_RETAIN_PHONE_HASH", "true")
+
+		// when
+		anonymized, err := anonymize(t, newUserSignup(testusersignup.Deactivated()))
+
+		// then
+		require.NoError(t, err)
+		assert.NotContains(t, anonymized.Labels, toolchainv1alpha1.UserSignupUserEmailHashLabelKey)
+		assert.Equal(t, "fd276563a8232d16620da8ec85d0575f", anonymized.Labels[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey])
+	})
+
+	t.Run("already anonymized", func(t *testing.T) {
+		// given
+		userSignup := newUserSignup(testusersignup.Deactivated(), testusersignup.WithAnnotation(AnonymizedAtAnnotationKey, "2024-01-15T10:00:00Z"))
+
+		// when
+		anonymized, err := anonymize(t, userSignup)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, "2024-01-15T10:00:00Z", anonymized.Annotations[AnonymizedAtAnnotationKey])
+	})
+
+	t.Run("not deactivated", func(t *testing.T) {
+		// when
+		_, err := anonymize(t, newUserSignup())
+
+		// then
+		require.EqualError(t, err, "the UserSignup is not deactivated: the UserSignup 'jsmith' must be deactivated before its personal data is erased")
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		// when
+		_, err := Anonymize(ctx, namespaced.NewClient(commontest.NewFakeClient(t), commontest.HostOperatorNs), "jsmith")
+
+		// then
+		require.True(t, apierrors.IsNotFound(err))
+	})
+}
@@ -0,0 +1,156 @@
+package signup
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// MergedIntoAnnotationKey is set on a duplicate UserSignup with the name of the UserSignup it was merged into
+	MergedIntoAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "merged-into"
+	// MergedFromAnnotationKey is set on a surviving UserSignup with the comma-separated names of the UserSignups merged into it
+	MergedFromAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "merged-from"
+)
+
+// mergedAnnotations are the annotations of the history of the user which are copied from the duplicate UserSignup to the
+// surviving one. The other annotations of the duplicate, eg. its verification code or its captcha assessment, only apply to
+// the duplicate.
+var mergedAnnotations = []string{
+	toolchainv1alpha1.UserSignupActivationCounterAnnotationKey,
+	toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey,
+}
+
+// MergeChange is a change of a UserSignup made by MergeUserSignups
+type MergeChange struct {
+	UserSignup string `json:"userSignup"`
+	Field      string `json:"field"`
+	From       string `json:"from,omitempty"`
+	To         string `json:"to"`
+}
+
+// MergeResult is the result of the merge of a duplicate UserSignup into the surviving one
+type MergeResult struct {
+	Survivor  string `json:"survivor"`
+	Duplicate string `json:"duplicate"`
+	// DryRun is true if the changes were not applied
+	DryRun  bool          `json:"dryRun"`
+	Changes []MergeChange `json:"changes"`
+}
+
+// MergeUserSignups merges the duplicate UserSignup into the surviving one, when both belong to the same person (eg. who signed up
+// with two different email identities):
+//   - the activation and verification counters of the duplicate are copied to the survivor, unless the survivor already has them,
+//   - the optional identity claims of the survivor which are not set are copied from the duplicate,
+//   - the survivor and the duplicate are bound to each other with the MergedFromAnnotationKey and MergedIntoAnnotationKey annotations,
+//   - the duplicate is deactivated.
+//
+// The changes are only returned, and not applied, in dry-run mode. Merging a duplicate again into the same survivor doesn't change anything.
+func MergeUserSignups(ctx *gin.Context, cl namespaced.Client, survivorName, duplicateName string, dryRun bool) (*MergeResult, error) {
+	if survivorName == "" || duplicateName == "" || survivorName == duplicateName {
+		return nil, crterrors.NewBadRequest("invalid merge request", "two different UserSignups must be specified")
+	}
+	survivor, err := getUserSignup(ctx, cl, survivorName)
+	if err != nil {
+		return nil, err
+	}
+	duplicate, err := getUserSignup(ctx, cl, duplicateName)
+	if err != nil {
+		return nil, err
+	}
+	if states.Deactivated(survivor) {
+		return nil, crterrors.NewBadRequest("invalid merge request", fmt.Sprintf("the surviving UserSignup '%s' is deactivated", survivorName))
+	}
+	if into, found := duplicate.Annotations[MergedIntoAnnotationKey]; found && into != survivorName {
+		return nil, crterrors.NewBadRequest("invalid merge request", fmt.Sprintf("the UserSignup '%s' was already merged into '%s'", duplicateName, into))
+	}
+
+	result := &MergeResult{
+		Survivor:  survivorName,
+		Duplicate: duplicateName,
+		DryRun:    dryRun,
+		Changes:   []MergeChange{},
+	}
+	change := func(userSignup *toolchainv1alpha1.UserSignup, field, from, to string) {
+		result.Changes = append(result.Changes, MergeChange{UserSignup: userSignup.Name, Field: field, From: from, To: to})
+	}
+	setAnnotation := func(userSignup *toolchainv1alpha1.UserSignup, key, value string) {
+		if userSignup.Annotations == nil {
+			userSignup.Annotations = map[string]string{}
+		}
+		change(userSignup, "metadata.annotations."+key, userSignup.Annotations[key], value)
+		userSignup.Annotations[key] = value
+	}
+
+	// consolidate the annotations
+	for _, key := range mergedAnnotations {
+		value, found := duplicate.Annotations[key]
+		if _, exists := survivor.Annotations[key]; found && !exists {
+			setAnnotation(survivor, key, value)
+		}
+	}
+
+	// consolidate the optional identity claims
+	for field, claims := range map[string][2]*string{
+		"givenName":     {&survivor.Spec.IdentityClaims.GivenName, &duplicate.Spec.IdentityClaims.GivenName},
+		"familyName":    {&survivor.Spec.IdentityClaims.FamilyName, &duplicate.Spec.IdentityClaims.FamilyName},
+		"company":       {&survivor.Spec.IdentityClaims.Company, &duplicate.Spec.IdentityClaims.Company},
+		"accountID":     {&survivor.Spec.IdentityClaims.AccountID, &duplicate.Spec.IdentityClaims.AccountID},
+		"accountNumber": {&survivor.Spec.IdentityClaims.AccountNumber, &duplicate.Spec.IdentityClaims.AccountNumber},
+	} {
+		if *claims[0] == "" && *claims[1] != "" {
+			change(survivor, "spec.identityClaims."+field, "", *claims[1])
+			*claims[0] = *claims[1]
+		}
+	}
+	slices.SortStableFunc(result.Changes, func(a, b MergeChange) int {
+		return strings.Compare(a.Field, b.Field)
+	})
+
+	// bind the survivor and the duplicate
+	if mergedFrom := strings.FieldsFunc(survivor.Annotations[MergedFromAnnotationKey], func(r rune) bool { return r == ',' }); !slices.Contains(mergedFrom, duplicateName) {
+		setAnnotation(survivor, MergedFromAnnotationKey, strings.Join(append(mergedFrom, duplicateName), ","))
+	}
+	if duplicate.Annotations[MergedIntoAnnotationKey] != survivorName {
+		setAnnotation(duplicate, MergedIntoAnnotationKey, survivorName)
+	}
+
+	// deactivate the duplicate
+	if !states.Deactivated(duplicate) {
+		from := fmt.Sprint(duplicate.Spec.States)
+		states.SetDeactivated(duplicate, true)
+		change(duplicate, "spec.states", from, fmt.Sprint(duplicate.Spec.States))
+	}
+
+	if dryRun || len(result.Changes) == 0 {
+		return result, nil
+	}
+	// the survivor is updated first, so that the duplicate is not deactivated if the survivor can't be updated
+	if err := cl.Update(ctx, survivor); err != nil {
+		return nil, crterrors.NewInternalError(err, fmt.Sprintf("unable to update the surviving UserSignup '%s'", survivorName))
+	}
+	if err := cl.Update(ctx, duplicate); err != nil {
+		return nil, crterrors.NewInternalError(err, fmt.Sprintf("unable to update the duplicate UserSignup '%s'", duplicateName))
+	}
+	log.Infof(ctx, "merged the UserSignup '%s' into '%s'", duplicateName, survivorName)
+	return result, nil
+}
+
+func getUserSignup(ctx *gin.Context, cl namespaced.Client, name string) (*toolchainv1alpha1.UserSignup, error) {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := cl.Get(ctx, cl.NamespacedName(name), userSignup); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, crterrors.NewNotFoundError(err, fmt.Sprintf("UserSignup '%s' not found", name))
+		}
+		return nil, crterrors.NewInternalError(err, fmt.Sprintf("unable to get the UserSignup '%s'", name))
+	}
+	return userSignup, nil
+}
@@ -0,0 +1,230 @@
+package signup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestMergeUserSignups(t *testing.T) {
+	// given
+	log.Init("merge-testing")
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	newUserSignups := func() (*toolchainv1alpha1.UserSignup, *toolchainv1alpha1.UserSignup) {
+		survivor := &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "john",
+				Namespace: commontest.HostOperatorNs,
+				Annotations: map[string]string{
+					toolchainv1alpha1.UserSignupLastTargetClusterAnnotationKey: "member-1",
+				},
+			},
+			Spec: toolchainv1alpha1.UserSignupSpec{
+				IdentityClaims: toolchainv1alpha1.IdentityClaimsEmbedded{
+					PropagatedClaims: toolchainv1alpha1.PropagatedClaims{
+						Sub:   "sub-1",
+						Email: "john@redhat.com",
+					},
+					PreferredUsername: "john",
+					GivenName:         "John",
+				},
+				States: []toolchainv1alpha1.UserSignupState{toolchainv1alpha1.UserSignupStateApproved},
+			},
+		}
+		duplicate := &toolchainv1alpha1.UserSignup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "john-doe",
+				Namespace: commontest.HostOperatorNs,
+				Annotations: map[string]string{
+					toolchainv1alpha1.UserSignupLastTargetClusterAnnotationKey:   "member-2",
+					toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey: "2",
+					// only apply to the duplicate
+					toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey: "123456",
+					toolchainv1alpha1.UserSignupCaptchaScoreAnnotationKey:     "0.9",
+				},
+			},
+			Spec: toolchainv1alpha1.UserSignupSpec{
+				IdentityClaims: toolchainv1alpha1.IdentityClaimsEmbedded{
+					PropagatedClaims: toolchainv1alpha1.PropagatedClaims{
+						Sub:       "sub-2",
+						Email:     "john.doe@gmail.com",
+						AccountID: "account-2",
+					},
+					PreferredUsername: "john-doe",
+					GivenName:         "Johnny",
+					Company:           "ACME",
+				},
+			},
+		}
+		return survivor, duplicate
+	}
+	expectedChanges := []MergeChange{
+		{UserSignup: "john", Field: "metadata.annotations." + toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, To: "2"},
+		{UserSignup: "john", Field: "spec.identityClaims.accountID", To: "account-2"},
+		{UserSignup: "john", Field: "spec.identityClaims.company", To: "ACME"},
+		{UserSignup: "john", Field: "metadata.annotations." + MergedFromAnnotationKey, To: "john-doe"},
+		{UserSignup: "john-doe", Field: "metadata.annotations." + MergedIntoAnnotationKey, To: "john"},
+		{UserSignup: "john-doe", Field: "spec.states", From: "[]", To: "[deactivated]"},
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		// given
+		survivor, duplicate := newUserSignups()
+		fakeClient := commontest.NewFakeClient(t, survivor, duplicate)
+		cl := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+
+		// when
+		result, err := MergeUserSignups(ctx, cl, "john", "john-doe", false)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, &MergeResult{Survivor: "john", Duplicate: "john-doe", Changes: expectedChanges}, result)
+		require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john"), survivor))
+		assert.Equal(t, map[string]string{
+			toolchainv1alpha1.UserSignupLastTargetClusterAnnotationKey:   "member-1",
+			toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey: "2",
+			MergedFromAnnotationKey: "john-doe",
+		}, survivor.Annotations)
+		assert.Equal(t, "sub-1", survivor.Spec.IdentityClaims.Sub)
+		assert.Equal(t, "John", survivor.Spec.IdentityClaims.GivenName)
+		assert.Equal(t, "ACME", survivor.Spec.IdentityClaims.Company)
+		assert.Equal(t, "account-2", survivor.Spec.IdentityClaims.AccountID)
+		assert.False(t, states.Deactivated(survivor))
+		require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john-doe"), duplicate))
+		assert.Equal(t, "john", duplicate.Annotations[MergedIntoAnnotationKey])
+		assert.True(t, states.Deactivated(duplicate))
+
+		t.Run("merged again", func(t *testing.T) {
+			// when
+			result, err := MergeUserSignups(ctx, cl, "john", "john-doe", false)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, result.Changes)
+		})
+
+		t.Run("merged into another UserSignup", func(t *testing.T) {
+			// given
+			other := &toolchainv1alpha1.UserSignup{ObjectMeta: metav1.ObjectMeta{Name: "johnny", Namespace: commontest.HostOperatorNs}}
+			require.NoError(t, cl.Create(context.TODO(), other))
+
+			// when
+			_, err := MergeUserSignups(ctx, cl, "johnny", "john-doe", false)
+
+			// then
+			require.EqualError(t, err, "invalid merge request: the UserSignup 'john-doe' was already merged into 'john'")
+		})
+
+		t.Run("another duplicate merged", func(t *testing.T) {
+			// given
+			other := &toolchainv1alpha1.UserSignup{ObjectMeta: metav1.ObjectMeta{Name: "jdoe", Namespace: commontest.HostOperatorNs}}
+			require.NoError(t, cl.Create(context.TODO(), other))
+
+			// when
+			_, err := MergeUserSignups(ctx, cl, "john", "jdoe", false)
+
+			// then
+			require.NoError(t, err)
+			require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john"), survivor))
+			assert.Equal(t, "john-doe,jdoe", survivor.Annotations[MergedFromAnnotationKey])
+		})
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		// given
+		survivor, duplicate := newUserSignups()
+		cl := namespaced.NewClient(commontest.NewFakeClient(t, survivor, duplicate), commontest.HostOperatorNs)
+
+		// when
+		result, err := MergeUserSignups(ctx, cl, "john", "john-doe", true)
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, &MergeResult{Survivor: "john", Duplicate: "john-doe", DryRun: true, Changes: expectedChanges}, result)
+		unchanged, _ := newUserSignups()
+		require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john"), survivor))
+		assert.Equal(t, unchanged.Annotations, survivor.Annotations)
+		assert.Equal(t, unchanged.Spec, survivor.Spec)
+		require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john-doe"), duplicate))
+		assert.False(t, states.Deactivated(duplicate))
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		t.Run("invalid request", func(t *testing.T) {
+			// given
+			survivor, duplicate := newUserSignups()
+			cl := namespaced.NewClient(commontest.NewFakeClient(t, survivor, duplicate), commontest.HostOperatorNs)
+
+			for _, names := range [][2]string{{"john", "john"}, {"", "john-doe"}, {"john", ""}} {
+				// when
+				_, err := MergeUserSignups(ctx, cl, names[0], names[1], false)
+
+				// then
+				require.EqualError(t, err, "invalid merge request: two different UserSignups must be specified")
+			}
+		})
+
+		t.Run("UserSignup not found", func(t *testing.T) {
+			// given
+			survivor, _ := newUserSignups()
+			cl := namespaced.NewClient(commontest.NewFakeClient(t, survivor), commontest.HostOperatorNs)
+
+			// when
+			_, err := MergeUserSignups(ctx, cl, "john", "john-doe", false)
+
+			// then
+			crtErr := &crterrors.Error{}
+			require.ErrorAs(t, err, &crtErr)
+			assert.Equal(t, http.StatusNotFound, crtErr.Code)
+			assert.Equal(t, "UserSignup 'john-doe' not found", crtErr.Details)
+		})
+
+		t.Run("survivor deactivated", func(t *testing.T) {
+			// given
+			survivor, duplicate := newUserSignups()
+			states.SetDeactivated(survivor, true)
+			cl := namespaced.NewClient(commontest.NewFakeClient(t, survivor, duplicate), commontest.HostOperatorNs)
+
+			// when
+			_, err := MergeUserSignups(ctx, cl, "john", "john-doe", false)
+
+			// then
+			require.EqualError(t, err, "invalid merge request: the surviving UserSignup 'john' is deactivated")
+		})
+
+		t.Run("duplicate not deactivated when the survivor can't be updated", func(t *testing.T) {
+			// given
+			survivor, duplicate := newUserSignups()
+			fakeClient := commontest.NewFakeClient(t, survivor, duplicate)
+			fakeClient.MockUpdate = func(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+				if obj.GetName() == "john" {
+					return errors.New("mock error")
+				}
+				return fakeClient.Client.Update(ctx, obj, opts...)
+			}
+			cl := namespaced.NewClient(fakeClient, commontest.HostOperatorNs)
+
+			// when
+			_, err := MergeUserSignups(ctx, cl, "john", "john-doe", false)
+
+			// then
+			require.EqualError(t, err, "mock error: unable to update the surviving UserSignup 'john'")
+			require.NoError(t, cl.Get(context.TODO(), cl.NamespacedName("john-doe"), duplicate))
+			assert.False(t, states.Deactivated(duplicate))
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	gocontext "context"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WithGetMembersFunc sets the function returning the member clusters, so that the state of the Idler of the users is part of the
+// phases of their Signup
+func WithGetMembersFunc(getMembersFunc cluster.GetMemberClustersFunc) SignupServiceOption {
+	return func(svc *ServiceImpl) {
+		svc.getMembersFunc = getMembersFunc
+	}
+}
+
+// phaseOf returns the phase with the given name and the status of the given condition, if found: the phase is done when the
+// condition is true, and in progress otherwise
+func phaseOf(name string, cond toolchainv1alpha1.Condition, found bool) signup.Phase {
+	phase := signup.Phase{
+		Name:   name,
+		Status: signup.PhaseStatusPending,
+	}
+	if !found {
+		return phase
+	}
+	phase.Status = signup.PhaseStatusInProgress
+	if cond.Status == apiv1.ConditionTrue {
+		phase.Status = signup.PhaseStatusDone
+	}
+	phase.Reason = cond.Reason
+	phase.Message = cond.Message
+	if !cond.LastTransitionTime.IsZero() {
+		phase.LastTransitionTime = cond.LastTransitionTime.UTC().Format(time.RFC3339)
+	}
+	return phase
+}
+
+// phases returns the breakdown of the provisioning of the given UserSignup: its approval, the provisioning of its home Space,
+// the readiness of the namespaces of the Space and, if the member clusters are known, the state of the Idler of its default namespace
+func (s *ServiceImpl) phases(ctx gocontext.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup) ([]signup.Phase, error) {
+	approvedCondition, found := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupApproved)
+	approval := phaseOf(signup.PhaseApproval, approvedCondition, found)
+	if approval.Status != signup.PhaseStatusDone {
+		// the approval is not in progress until it is done
+		approval.Status = signup.PhaseStatusPending
+		return []signup.Phase{
+			approval,
+			{Name: signup.PhaseSpaceProvisioning, Status: signup.PhaseStatusPending},
+			{Name: signup.PhaseNamespaces, Status: signup.PhaseStatusPending},
+		}, nil
+	}
+
+	space := &toolchainv1alpha1.Space{}
+	if userSignup.Status.HomeSpace == "" {
+		space = nil
+	} else if err := cl.Get(ctx, cl.NamespacedName(userSignup.Status.HomeSpace), space); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		space = nil
+	}
+	if space == nil {
+		// the provisioning of the Space starts once the UserSignup is approved
+		completeCondition, _ := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
+		return []signup.Phase{
+			approval,
+			{Name: signup.PhaseSpaceProvisioning, Status: signup.PhaseStatusInProgress, Reason: completeCondition.Reason, Message: completeCondition.Message},
+			{Name: signup.PhaseNamespaces, Status: signup.PhaseStatusPending},
+		}, nil
+	}
+
+	readyCondition, found := condition.FindConditionByType(space.Status.Conditions, toolchainv1alpha1.ConditionReady)
+	spaceProvisioning := phaseOf(signup.PhaseSpaceProvisioning, readyCondition, found)
+	if spaceProvisioning.Status == signup.PhaseStatusPending {
+		spaceProvisioning.Status = signup.PhaseStatusInProgress
+	}
+	namespaces := signup.Phase{
+		Name:   signup.PhaseNamespaces,
+		Status: signup.PhaseStatusInProgress,
+	}
+	var defaultNamespace string
+	if len(space.Status.ProvisionedNamespaces) > 0 {
+		names := make([]string, len(space.Status.ProvisionedNamespaces))
+		for i, ns := range space.Status.ProvisionedNamespaces {
+			names[i] = ns.Name
+			if ns.Type == toolchainv1alpha1.NamespaceTypeDefault && defaultNamespace == "" {
+				defaultNamespace = ns.Name
+			}
+		}
+		namespaces.Status = signup.PhaseStatusDone
+		namespaces.Message = strings.Join(names, ", ")
+		namespaces.LastTransitionTime = spaceProvisioning.LastTransitionTime
+	}
+	phases := []signup.Phase{approval, spaceProvisioning, namespaces}
+	if idler, ok := s.idlerPhase(ctx, space.Status.TargetCluster, defaultNamespace); ok {
+		phases = append(phases, idler)
+	}
+	return phases, nil
+}
+
+// idlerPhase returns the phase of the Idler of the given namespace in the given member cluster, if the member clusters are known.
+// The Idler is retrieved in a best effort manner: no phase is returned if it can't be.
+func (s *ServiceImpl) idlerPhase(ctx gocontext.Context, memberName, namespace string) (signup.Phase, bool) {
+	if s.getMembersFunc == nil || memberName == "" || namespace == "" {
+		return signup.Phase{}, false
+	}
+	for _, member := range s.getMembersFunc() {
+		if member.Name != memberName {
+			continue
+		}
+		// the Idler has the name of the namespace it manages
+		idler := &toolchainv1alpha1.Idler{}
+		if err := member.Client.Get(ctx, client.ObjectKey{Name: namespace}, idler); err != nil {
+			if apierrors.IsNotFound(err) {
+				return signup.Phase{Name: signup.PhaseIdler, Status: signup.PhaseStatusPending}, true
+			}
+			log.Errorf(nil, err, "unable to get the idler '%s' in the member cluster '%s'", namespace, memberName)
+			return signup.Phase{}, false
+		}
+		readyCondition, found := condition.FindConditionByType(idler.Status.Conditions, toolchainv1alpha1.ConditionReady)
+		return phaseOf(signup.PhaseIdler, readyCondition, found), true
+	}
+	return signup.Phase{}, false
+}
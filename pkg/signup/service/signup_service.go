@@ -15,16 +15,20 @@ import (
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
 	"github.com/codeready-toolchain/registration-service/pkg/configuration"
 	"github.com/codeready-toolchain/registration-service/pkg/context"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/log"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
 	"github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/registration-service/pkg/util"
 	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
 	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	"github.com/codeready-toolchain/toolchain-common/pkg/hash"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
 	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
 	"github.com/gin-gonic/gin"
 	errs "github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 	apiv1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -33,13 +37,31 @@ import (
 )
 
 const (
+	// SelfDeactivatedAtAnnotationKey is the annotation set with the time a user deactivated their own UserSignup, for the audit
+	SelfDeactivatedAtAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "self-deactivated-at"
+	// IdempotencyKeyAnnotationKey is the annotation set with the Idempotency-Key header of the request which created or reactivated
+	// the UserSignup, so that the repeats of the request return the original result
+	IdempotencyKeyAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "idempotency-key"
+	// TermsOfServiceVersionAnnotationKey is the annotation set with the version of the terms of service accepted by the user
+	TermsOfServiceVersionAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "terms-of-service-version"
+	// TermsOfServiceAcceptedAtAnnotationKey is the annotation set with the time the user accepted the terms of service
+	TermsOfServiceAcceptedAtAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "terms-of-service-accepted-at"
+
 	// NoSpaceKey is the query key for specifying whether the UserSignup should be created without a Space
 	NoSpaceKey = "no-space"
+
+	// getSignupConcurrency is the maximum number of resources retrieved concurrently when getting a Signup
+	getSignupConcurrency = 3
 )
 
 var ForbiddenBannedError = apierrors.NewForbidden(schema.GroupResource{}, "",
 	errs.New("Access to the Developer Sandbox has been suspended due to suspicious activity or detected abuse."))
 
+// ForbiddenEmailNotVerifiedError is returned when a user whose email is not verified signs up, while the verified emails are required
+// (see the RequireVerifiedEmail setting)
+var ForbiddenEmailNotVerifiedError = apierrors.NewForbidden(schema.GroupResource{}, "",
+	errs.New("The email address of your account is not verified. Please verify it with your identity provider, then log in again."))
+
 var annotationsToRetain = []string{
 	toolchainv1alpha1.UserSignupActivationCounterAnnotationKey,
 	toolchainv1alpha1.UserSignupLastTargetClusterAnnotationKey,
@@ -49,16 +71,22 @@ var annotationsToRetain = []string{
 type ServiceImpl struct { // nolint:revive
 	namespaced.Client
 	CaptchaChecker captcha.Assessor
+	// getMembersFunc returns the member clusters, if known (see WithGetMembersFunc)
+	getMembersFunc cluster.GetMemberClustersFunc
 }
 
 type SignupServiceOption func(svc *ServiceImpl)
 
 // NewSignupService creates a service object for performing user signup-related activities.
-func NewSignupService(client namespaced.Client) *ServiceImpl {
-	return &ServiceImpl{
+func NewSignupService(client namespaced.Client, opts ...SignupServiceOption) *ServiceImpl {
+	svc := &ServiceImpl{
 		CaptchaChecker: captcha.Helper{},
 		Client:         client,
 	}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	return svc
 }
 
 // newUserSignup generates a new UserSignup resource with the specified username and available claims.
@@ -96,6 +124,9 @@ func (s *ServiceImpl) newUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSi
 		}
 	}
 
+	if err := verifyCaptcha(s.CaptchaChecker, ctx); err != nil {
+		return nil, err
+	}
 	verificationRequired, captchaScore, assessmentID := IsPhoneVerificationRequired(s.CaptchaChecker, ctx)
 	requestReceivedTime, ok := ctx.Get(context.RequestReceivedTime)
 	if !ok {
@@ -155,9 +186,72 @@ func (s *ServiceImpl) newUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSi
 		signup.UpdateUserSignupWithSocialEvent(event, userSignup)
 	}
 
+	if key := ctx.GetString(context.IdempotencyKey); key != "" {
+		userSignup.Annotations[IdempotencyKeyAnnotationKey] = key
+	}
+
+	if version := ctx.GetString(context.TermsOfServiceVersionKey); version != "" {
+		if err := setTermsOfServiceAccepted(userSignup, version); err != nil {
+			return nil, err
+		}
+	}
+
 	return userSignup, nil
 }
 
+// setTermsOfServiceAccepted records the acceptance of the given version of the terms of service in the annotations of the given
+// UserSignup. Returns a BadRequest error if the version is not the current one.
+func setTermsOfServiceAccepted(userSignup *toolchainv1alpha1.UserSignup, version string) error {
+	if current := configuration.GetRegistrationServiceConfig().TermsOfService().Version(); version != current {
+		return crterrors.NewBadRequest("invalid terms of service version",
+			fmt.Sprintf("the version '%s' is not the current version of the terms of service", version))
+	}
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[TermsOfServiceVersionAnnotationKey] = version
+	userSignup.Annotations[TermsOfServiceAcceptedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// checkTermsOfServiceAccepted returns a Forbidden error if the acceptance of the current version of the terms of service is
+// required (see the TermsOfService settings) but not recorded in the annotations of the given UserSignup
+func checkTermsOfServiceAccepted(userSignup *toolchainv1alpha1.UserSignup) error {
+	cfg := configuration.GetRegistrationServiceConfig().TermsOfService()
+	if !cfg.Required() || cfg.Version() == "" || userSignup.Annotations[TermsOfServiceVersionAnnotationKey] == cfg.Version() {
+		return nil
+	}
+	return crterrors.NewForbiddenError("terms of service not accepted",
+		fmt.Sprintf("the version '%s' of the terms of service must be accepted", cfg.Version()))
+}
+
+// verifyCaptcha completes the assessment of the captcha token of the signup request if a valid token is required to sign up (see the
+// CaptchaRequiredForSignup setting), so that the scripted signups are rejected before the UserSignup is created or reactivated.
+// The assessment is stored in the context, where IsPhoneVerificationRequired reuses it, since a token can only be validated once.
+// Returns a Forbidden error if the token is missing or invalid.
+func verifyCaptcha(captchaChecker captcha.Assessor, ctx *gin.Context) error {
+	cfg := configuration.GetRegistrationServiceConfig()
+	if !cfg.Verification().CaptchaEnabled() || !cfg.Verification().CaptchaRequiredForSignup() {
+		return nil
+	}
+	header := captcha.TokenHeader(cfg)
+	var token []string
+	if ctx.Request != nil {
+		token = ctx.Request.Header.Values(header)
+	}
+	if len(token) != 1 {
+		log.Info(ctx, "rejecting the signup request without a valid captcha token")
+		return crterrors.NewForbiddenError("captcha token required", fmt.Sprintf("the signup request must have a captcha token in the '%s' header", header))
+	}
+	assessment, err := captchaChecker.CompleteAssessment(ctx, cfg, token[0])
+	if err != nil {
+		log.Error(ctx, err, "rejecting the signup request after the failed captcha assessment")
+		return crterrors.NewForbiddenError("captcha verification failed", "the captcha token is invalid or expired")
+	}
+	ctx.Set(context.CaptchaAssessmentKey, assessment)
+	return nil
+}
+
 func isCRTAdmin(username string) bool {
 	newUsername := regexp.MustCompile("[^A-Za-z0-9]").ReplaceAllString(strings.Split(username, "@")[0], "-")
 	return strings.HasSuffix(newUsername, "crtadmin")
@@ -191,12 +285,12 @@ func IsPhoneVerificationRequired(captchaChecker captcha.Assessor, ctx *gin.Conte
 	}
 
 	// skip verification for excluded email domains
-	userEmail := ctx.GetString(context.EmailKey)
-	emailHost := extractEmailHost(userEmail)
-	for _, d := range cfg.Verification().ExcludedEmailDomains() {
-		if strings.EqualFold(d, emailHost) {
-			return false, -1, ""
-		}
+	excludedDomains, err := util.NewDomainPatterns(cfg.Verification().ExcludedEmailDomains()...)
+	if err != nil {
+		log.Error(ctx, err, "ignoring the invalid excluded email domains")
+	}
+	if _, excluded := excludedDomains.Match(extractEmailHost(ctx.GetString(context.EmailKey))); excluded {
+		return false, -1, ""
 	}
 
 	// require verification if captcha is disabled
@@ -210,32 +304,36 @@ func IsPhoneVerificationRequired(captchaChecker captcha.Assessor, ctx *gin.Conte
 		return true, -1, ""
 	}
 
-	// require verification if captcha token is invalid
-	captchaToken, exists := ctx.Request.Header["Recaptcha-Token"]
-	if !exists || len(captchaToken) != 1 {
-		log.Error(ctx, nil, "no valid captcha token found in request header")
-		return true, -1, ""
-	}
+	// reuse the assessment completed before the UserSignup was created, if any
+	assessment, assessed := ctx.Value(context.CaptchaAssessmentKey).(*captcha.Assessment)
+	if !assessed {
+		// require verification if captcha token is invalid
+		captchaToken, exists := ctx.Request.Header[captcha.TokenHeader(cfg)]
+		if !exists || len(captchaToken) != 1 {
+			log.Error(ctx, nil, "no valid captcha token found in request header")
+			return true, -1, ""
+		}
 
-	// do captcha assessment
+		// do captcha assessment
 
-	// require verification if captcha failed
-	assessment, err := captchaChecker.CompleteAssessment(ctx, cfg, captchaToken[0])
-	if err != nil {
-		log.Error(ctx, err, "signup assessment failed")
-		return true, -1, ""
+		// require verification if captcha failed
+		assessment, err = captchaChecker.CompleteAssessment(ctx, cfg, captchaToken[0])
+		if err != nil {
+			log.Error(ctx, err, "signup assessment failed")
+			return true, -1, ""
+		}
 	}
 
 	// require verification if captcha score is too low
-	score := assessment.GetRiskAnalysis().GetScore()
+	score := assessment.Score
 	threshold := cfg.Verification().CaptchaScoreThreshold()
 	if score < threshold {
 		log.Info(ctx, fmt.Sprintf("the risk analysis score '%.1f' did not meet the expected threshold '%.1f'", score, threshold))
-		return true, score, assessment.GetName()
+		return true, score, assessment.Name
 	}
 
 	// verification not required, score is above threshold
-	return false, score, assessment.GetName()
+	return false, score, assessment.Name
 }
 
 func extractEmailHost(email string) string {
@@ -314,6 +412,11 @@ func (s *ServiceImpl) Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, e
 	username := ctx.GetString(context.UsernameKey)
 	encodedUsername := signupcommon.EncodeUserIdentifier(username)
 
+	if configuration.GetRegistrationServiceConfig().Auth().RequireVerifiedEmail() && !ctx.GetBool(context.EmailVerifiedKey) {
+		log.Info(ctx, fmt.Sprintf("the user '%s' with an unverified email just tried to signup", username))
+		return nil, ForbiddenEmailNotVerifiedError
+	}
+
 	// Retrieve UserSignup resource from the host cluster
 	userSignup := &toolchainv1alpha1.UserSignup{}
 	if err := s.Get(ctx, s.NamespacedName(encodedUsername), userSignup); err != nil {
@@ -321,15 +424,34 @@ func (s *ServiceImpl) Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, e
 			// New Signup
 			log.WithValues(map[string]interface{}{"encoded_username": encodedUsername}).Info(ctx, "user not found, creating a new one")
 			s.verifyAccount(ctx)
-			return s.createUserSignup(ctx)
+			created, err := s.createUserSignup(ctx)
+			if apierrors.IsAlreadyExists(err) && ctx.GetString(context.IdempotencyKey) != "" {
+				// created by a concurrent repeat of the request in the meantime
+				if err := s.Get(ctx, s.NamespacedName(encodedUsername), userSignup); err != nil {
+					return nil, err
+				}
+				if s.isRepeat(ctx, userSignup) {
+					return userSignup, nil
+				}
+			}
+			return created, err
 		}
 		return nil, err
 	}
 
+	if s.isRepeat(ctx, userSignup) {
+		return userSignup, nil
+	}
+
 	// Check UserSignup status to determine whether user signup is deactivated
 	signupCondition, found := condition.FindConditionByType(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
 	if found && signupCondition.Status == apiv1.ConditionTrue && signupCondition.Reason == toolchainv1alpha1.UserSignupUserDeactivatedReason {
-		// Signup is deactivated. We need to reactivate it
+		// Signup is deactivated. We need to reactivate it, unless the personal data of the user was erased
+		if signup.IsAnonymized(userSignup) {
+			log.Info(ctx, fmt.Sprintf("the user '%s' of the anonymized UserSignup '%s' just tried to signup again", username, userSignup.Name))
+			return nil, crterrors.NewForbiddenError("the account was erased",
+				"the personal data of this account was erased, so it can't be reactivated")
+		}
 		s.verifyAccount(ctx)
 		return s.reactivateUserSignup(ctx, userSignup)
 	}
@@ -338,12 +460,103 @@ func (s *ServiceImpl) Signup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, e
 		"UserSignup [username: %s]. Unable to create UserSignup because there is already an active UserSignup with such a username", username))
 }
 
+// isRepeat returns true if the request is a repeat of the request which created or reactivated the given UserSignup, ie. if
+// they have the same Idempotency-Key header, in which case the IdempotentReplayKey is set in the context
+func (s *ServiceImpl) isRepeat(ctx *gin.Context, userSignup *toolchainv1alpha1.UserSignup) bool {
+	key := ctx.GetString(context.IdempotencyKey)
+	if key == "" || userSignup.Annotations[IdempotencyKeyAnnotationKey] != key {
+		return false
+	}
+	log.Info(ctx, fmt.Sprintf("returning the UserSignup '%s' of the original request with the same idempotency key", userSignup.Name))
+	ctx.Set(context.IdempotentReplayKey, true)
+	return true
+}
+
+// Deactivate deactivates the UserSignup resource with the specified username, on behalf of the user themselves, and records the time
+// of the deactivation in the SelfDeactivatedAtAnnotationKey annotation. Nothing is changed if the UserSignup is already deactivated.
+// Returns a NotFound error if there is no UserSignup with such a username.
+func (s *ServiceImpl) Deactivate(ctx *gin.Context, username string) error {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), userSignup); err != nil {
+		return err
+	}
+	if states.Deactivated(userSignup) {
+		return nil
+	}
+	states.SetDeactivated(userSignup, true)
+	if userSignup.Annotations == nil {
+		userSignup.Annotations = map[string]string{}
+	}
+	userSignup.Annotations[SelfDeactivatedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+	if err := s.Update(ctx, userSignup); err != nil {
+		return err
+	}
+	log.Info(ctx, fmt.Sprintf("audit: the user '%s' deactivated their UserSignup '%s'", username, userSignup.Name))
+	return nil
+}
+
+// UpdateProfile updates the mutable identity claims of the UserSignup resource with the specified username with the given profile.
+// Nothing is changed if the UserSignup is already up-to-date. Returns a NotFound error if there is no UserSignup with such a username.
+func (s *ServiceImpl) UpdateProfile(ctx *gin.Context, username string, profile signup.Profile) error {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), userSignup); err != nil {
+		return err
+	}
+	claims := &userSignup.Spec.IdentityClaims
+	if claims.GivenName == profile.GivenName && claims.FamilyName == profile.FamilyName && claims.Company == profile.Company {
+		return nil
+	}
+	claims.GivenName = profile.GivenName
+	claims.FamilyName = profile.FamilyName
+	claims.Company = profile.Company
+	if err := s.Update(ctx, userSignup); err != nil {
+		return err
+	}
+	log.Info(ctx, fmt.Sprintf("the profile of the UserSignup '%s' was updated", userSignup.Name))
+	return nil
+}
+
+// Anonymize erases the personal data of the deactivated UserSignup resource with the specified username, on behalf of the user
+// themselves (see signup.Anonymize). Returns a NotFound error if there is no UserSignup with such a username.
+func (s *ServiceImpl) Anonymize(ctx *gin.Context, username string) error {
+	userSignup, err := signup.Anonymize(ctx, s.Client, signupcommon.EncodeUserIdentifier(username))
+	if err != nil {
+		return err
+	}
+	log.Info(ctx, fmt.Sprintf("audit: the user '%s' erased the personal data of their UserSignup '%s'", username, userSignup.Name))
+	return nil
+}
+
+// AcceptTermsOfService records the acceptance of the given version of the terms of service, which must be the current one, in the
+// annotations of the UserSignup resource with the specified username. Returns a NotFound error if there is no UserSignup with such
+// a username.
+func (s *ServiceImpl) AcceptTermsOfService(ctx *gin.Context, username, version string) error {
+	userSignup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), userSignup); err != nil {
+		return err
+	}
+	if err := setTermsOfServiceAccepted(userSignup, version); err != nil {
+		return err
+	}
+	if err := s.Update(ctx, userSignup); err != nil {
+		return err
+	}
+	log.Info(ctx, fmt.Sprintf("audit: the user '%s' accepted the version '%s' of the terms of service", username, version))
+	return nil
+}
+
 // createUserSignup creates a new UserSignup resource with the specified username
 func (s *ServiceImpl) createUserSignup(ctx *gin.Context) (*toolchainv1alpha1.UserSignup, error) {
 	userSignup, err := s.newUserSignup(ctx)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkTermsOfServiceAccepted(userSignup); err != nil {
+		return nil, err
+	}
+	if err := signup.ApplySlowStart(ctx, s.Client, userSignup, time.Now()); err != nil {
+		return nil, errs.Wrap(err, "unable to apply the slow-start of the member clusters")
+	}
 
 	return userSignup, s.Create(ctx, userSignup)
 }
@@ -366,6 +579,17 @@ func (s *ServiceImpl) reactivateUserSignup(ctx *gin.Context, existing *toolchain
 			newUserSignup.Annotations[a] = c
 		}
 	}
+	// the terms of service accepted earlier still apply, unless accepted again with the request
+	if _, accepted := newUserSignup.Annotations[TermsOfServiceVersionAnnotationKey]; !accepted {
+		for _, a := range []string{TermsOfServiceVersionAnnotationKey, TermsOfServiceAcceptedAtAnnotationKey} {
+			if c, exists := existing.Annotations[a]; exists {
+				newUserSignup.Annotations[a] = c
+			}
+		}
+	}
+	if err := checkTermsOfServiceAccepted(newUserSignup); err != nil {
+		return nil, err
+	}
 
 	existing.Annotations = newUserSignup.Annotations
 	existing.Labels = newUserSignup.Labels
@@ -432,6 +656,8 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		AccountID:     userSignup.Spec.IdentityClaims.AccountID,
 		AccountNumber: userSignup.Spec.IdentityClaims.AccountNumber,
 		Email:         userSignup.Spec.IdentityClaims.Email,
+
+		TermsOfServiceVersion: userSignup.Annotations[TermsOfServiceVersionAnnotationKey],
 	}
 	if userSignup.Status.CompliantUsername != "" {
 		signupResponse.CompliantUsername = userSignup.Status.CompliantUsername
@@ -449,7 +675,7 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 			Reason:               toolchainv1alpha1.UserSignupPendingApprovalReason,
 			VerificationRequired: states.VerificationRequired(userSignup),
 		}
-		return signupResponse, nil
+		return s.withPhases(ctx, cl, signupResponse, userSignup, checkUserSignupCompleted)
 	}
 
 	// in proxy, we don't care if the UserSignup is completed, since sometimes it might be transitioning from complete to provisioning
@@ -463,7 +689,7 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 			Message:              completeCondition.Message,
 			VerificationRequired: states.VerificationRequired(userSignup),
 		}
-		return signupResponse, nil
+		return s.withPhases(ctx, cl, signupResponse, userSignup, checkUserSignupCompleted)
 	} else if completeCondition.Reason == toolchainv1alpha1.UserSignupUserDeactivatedReason {
 		log.Info(nil, fmt.Sprintf("usersignup: %s is deactivated", userSignup.GetName()))
 		// UserSignup is deactivated. Treat it as non-existent.
@@ -479,10 +705,32 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 	}
 
 	// If UserSignup status is complete as active
-	// Retrieve MasterUserRecord resource from the host cluster and use its status
+	// Retrieve the MasterUserRecord, the default user target and the ToolchainStatus resources from the host cluster.
+	// They only depend on the UserSignup, so they are retrieved concurrently to reduce the latency of the (heavily polled) endpoint.
 	mur := &toolchainv1alpha1.MasterUserRecord{}
-	if err := cl.Get(ctx, cl.NamespacedName(userSignup.Status.CompliantUsername), mur); err != nil {
-		return nil, errs.Wrap(err, fmt.Sprintf("error when retrieving MasterUserRecord for completed UserSignup %s", userSignup.GetName()))
+	status := &toolchainv1alpha1.ToolchainStatus{}
+	var memberCluster, defaultNamespace string
+	var statusErr error
+	g := &errgroup.Group{}
+	g.SetLimit(getSignupConcurrency)
+	g.Go(func() error {
+		if err := cl.Get(ctx, cl.NamespacedName(userSignup.Status.CompliantUsername), mur); err != nil {
+			return errs.Wrap(err, fmt.Sprintf("error when retrieving MasterUserRecord for completed UserSignup %s", userSignup.GetName()))
+		}
+		return nil
+	})
+	g.Go(func() error {
+		// the name of the MUR is the compliant username
+		memberCluster, defaultNamespace = GetDefaultUserTarget(cl, userSignup.Status.HomeSpace, userSignup.Status.CompliantUsername)
+		return nil
+	})
+	g.Go(func() error {
+		// the ToolchainStatus is only required when there is a default user target (see below)
+		statusErr = cl.Get(ctx, cl.NamespacedName("toolchain-status"), status)
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
 	}
 	murCondition, _ := condition.FindConditionByType(mur.Status.Conditions, toolchainv1alpha1.ConditionReady)
 	// the MUR may not be ready immediately, so let's set it to not ready if the Ready condition it's not True,
@@ -500,13 +748,10 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		signupResponse.StartDate = mur.Status.ProvisionedTime.UTC().Format(time.RFC3339)
 	}
 
-	memberCluster, defaultNamespace := GetDefaultUserTarget(cl, userSignup.Status.HomeSpace, mur.Name)
 	if memberCluster != "" {
 		// Retrieve cluster-specific URLs from the status of the corresponding member cluster
-		status := &toolchainv1alpha1.ToolchainStatus{}
-
-		if err := cl.Get(ctx, cl.NamespacedName("toolchain-status"), status); err != nil {
-			return nil, errs.Wrapf(err, "error when retrieving ToolchainStatus for completed UserSignup %s", userSignup.GetName())
+		if statusErr != nil {
+			return nil, errs.Wrapf(statusErr, "error when retrieving ToolchainStatus for completed UserSignup %s", userSignup.GetName())
 		}
 		signupResponse.ProxyURL = status.Status.HostRoutes.ProxyURL
 		for _, member := range status.Status.Members {
@@ -528,6 +773,21 @@ func (s *ServiceImpl) DoGetSignup(ctx *gin.Context, cl namespaced.Client, userna
 		signupResponse.DefaultUserNamespace = defaultNamespace
 	}
 
+	return s.withPhases(ctx, cl, signupResponse, userSignup, checkUserSignupCompleted)
+}
+
+// withPhases returns the given Signup with the phases of the provisioning of the given UserSignup, when the UserSignup is expected
+// to be completed (ie. for the users polling their Signup, but not for the proxy calls, which don't need them)
+func (s *ServiceImpl) withPhases(ctx *gin.Context, cl namespaced.Client, signupResponse *signup.Signup, userSignup *toolchainv1alpha1.UserSignup,
+	checkUserSignupCompleted bool) (*signup.Signup, error) {
+	if !checkUserSignupCompleted {
+		return signupResponse, nil
+	}
+	phases, err := s.phases(ctx, cl, userSignup)
+	if err != nil {
+		return nil, errs.Wrapf(err, "error when retrieving the phases of the UserSignup %s", userSignup.GetName())
+	}
+	signupResponse.Phases = phases
 	return signupResponse, nil
 }
 
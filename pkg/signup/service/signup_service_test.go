@@ -17,8 +17,10 @@ import (
 	"github.com/codeready-toolchain/registration-service/pkg/context"
 	errors2 "github.com/codeready-toolchain/registration-service/pkg/errors"
 	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/registration-service/pkg/signup"
 	"github.com/codeready-toolchain/registration-service/pkg/signup/service"
 	"github.com/codeready-toolchain/registration-service/pkg/util"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
 	"github.com/codeready-toolchain/registration-service/test"
 	"github.com/codeready-toolchain/registration-service/test/fake"
 	testutil "github.com/codeready-toolchain/registration-service/test/util"
@@ -30,19 +32,22 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
 	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
 	"github.com/codeready-toolchain/toolchain-common/pkg/states"
 	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
 	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
 
-	recaptchapb "cloud.google.com/go/recaptchaenterprise/v2/apiv1/recaptchaenterprisepb"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 type TestSignupServiceSuite struct {
@@ -184,6 +189,29 @@ func (s *TestSignupServiceSuite) TestSignup() {
 		require.EqualError(s.T(), err, "an error occurred")
 	})
 
+	s.Run("deactivate and anonymize, then try to reactivate", func() {
+		// given
+		deactivatedUS := existing.DeepCopy()
+		states.SetDeactivated(deactivatedUS, true)
+		deactivatedUS.Status.Conditions = fake.Deactivated()
+		deactivatedUS.Annotations[signup.AnonymizedAtAnnotationKey] = time.Now().UTC().Format(time.RFC3339)
+		deactivatedUS.Spec.IdentityClaims = toolchainv1alpha1.IdentityClaimsEmbedded{}
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), deactivatedUS)
+
+		// when
+		_, err := application.SignupService().Signup(ctx)
+
+		// then
+		crtErr := &errors2.Error{}
+		require.ErrorAs(s.T(), err, &crtErr)
+		assert.Equal(s.T(), http.StatusForbidden, crtErr.Code)
+		assert.Equal(s.T(), "the account was erased: the personal data of this account was erased, so it can't be reactivated", err.Error())
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(deactivatedUS), userSignup))
+		assert.True(s.T(), states.Deactivated(userSignup))
+		assert.Empty(s.T(), userSignup.Spec.IdentityClaims.Email)
+	})
+
 	s.Run("with social event code", func() {
 		withCodeCtx := ctx.Copy()
 		withCodeCtx.Set(context.SocialEvent, "event1")
@@ -383,6 +411,115 @@ func (s *TestSignupServiceSuite) TestSignupWithCaptchaEnabled() {
 	require.Equal(s.T(), "0.9", val.Annotations[toolchainv1alpha1.UserSignupCaptchaScoreAnnotationKey]) // captcha score annotation is set
 }
 
+func (s *TestSignupServiceSuite) TestSignupWithCaptchaRequired() {
+	commontest.SetEnvVarAndRestore(s.T(), commonconfig.WatchNamespaceEnvVar, commontest.HostOperatorNs)
+	s.OverrideApplicationDefault(
+		testconfig.RegistrationService().
+			Verification().Enabled(true).
+			Verification().CaptchaEnabled(true).
+			Verification().CaptchaScoreThreshold("0.8"))
+	s.T().Setenv("REGISTRATION_SERVICE_CAPTCHA_REQUIRED_FOR_SIGNUP", "true")
+
+	newContext := func(token string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "jsmith")
+		ctx.Set(context.SubKey, "987654321")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		ctx.Request, _ = http.NewRequest("POST", "/", bytes.NewBufferString(""))
+		if token != "" {
+			ctx.Request.Header.Set("Recaptcha-Token", token)
+		}
+		return ctx
+	}
+
+	s.Run("valid token", func() {
+		// given
+		nsdClient := namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+		signupService := service.NewSignupService(nsdClient)
+		calls := 0
+		signupService.CaptchaChecker = FakeCaptchaChecker{score: 0.5, calls: &calls}
+
+		// when
+		userSignup, err := signupService.Signup(newContext("abc"))
+
+		// then
+		require.NoError(s.T(), err)
+		// the token is assessed only once
+		assert.Equal(s.T(), 1, calls)
+		assert.Equal(s.T(), "0.5", userSignup.Annotations[toolchainv1alpha1.UserSignupCaptchaScoreAnnotationKey])
+		assert.Equal(s.T(), "captcha-assessment-123", userSignup.Annotations[toolchainv1alpha1.UserSignupCaptchaAssessmentIDAnnotationKey])
+		// the score is too low
+		assert.True(s.T(), states.VerificationRequired(userSignup))
+	})
+
+	s.Run("missing token", func() {
+		// given
+		nsdClient := namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+		signupService := service.NewSignupService(nsdClient)
+		signupService.CaptchaChecker = FakeCaptchaChecker{score: 0.9}
+
+		// when
+		_, err := signupService.Signup(newContext(""))
+
+		// then
+		require.EqualError(s.T(), err, "captcha token required: the signup request must have a captcha token in the 'Recaptcha-Token' header")
+		userSignups := &toolchainv1alpha1.UserSignupList{}
+		require.NoError(s.T(), nsdClient.List(gocontext.TODO(), userSignups, client.InNamespace(commontest.HostOperatorNs)))
+		assert.Empty(s.T(), userSignups.Items)
+	})
+
+	s.Run("invalid token", func() {
+		// given
+		nsdClient := namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+		signupService := service.NewSignupService(nsdClient)
+		signupService.CaptchaChecker = FakeCaptchaChecker{result: fmt.Errorf("assessment failed")}
+
+		// when
+		_, err := signupService.Signup(newContext("abc"))
+
+		// then
+		require.EqualError(s.T(), err, "captcha verification failed: the captcha token is invalid or expired")
+		userSignups := &toolchainv1alpha1.UserSignupList{}
+		require.NoError(s.T(), nsdClient.List(gocontext.TODO(), userSignups, client.InNamespace(commontest.HostOperatorNs)))
+		assert.Empty(s.T(), userSignups.Items)
+	})
+
+	s.Run("reactivation with missing token", func() {
+		// given
+		deactivated := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"), testusersignup.Deactivated(),
+			testusersignup.SignupComplete(toolchainv1alpha1.UserSignupUserDeactivatedReason))
+		nsdClient := namespaced.NewClient(commontest.NewFakeClient(s.T(), deactivated), commontest.HostOperatorNs)
+		signupService := service.NewSignupService(nsdClient)
+		signupService.CaptchaChecker = FakeCaptchaChecker{score: 0.9}
+
+		// when
+		_, err := signupService.Signup(newContext(""))
+
+		// then
+		require.EqualError(s.T(), err, "captcha token required: the signup request must have a captcha token in the 'Recaptcha-Token' header")
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), nsdClient.Get(gocontext.TODO(), nsdClient.NamespacedName(deactivated.Name), userSignup))
+		assert.True(s.T(), states.Deactivated(userSignup))
+	})
+
+	s.Run("not required when captcha is disabled", func() {
+		// given
+		s.OverrideApplicationDefault(
+			testconfig.RegistrationService().
+				Verification().Enabled(true).
+				Verification().CaptchaEnabled(false))
+		nsdClient := namespaced.NewClient(commontest.NewFakeClient(s.T()), commontest.HostOperatorNs)
+		signupService := service.NewSignupService(nsdClient)
+
+		// when
+		userSignup, err := signupService.Signup(newContext(""))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), states.VerificationRequired(userSignup))
+	})
+}
+
 func (s *TestSignupServiceSuite) TestUserSignupWithInvalidSubjectPrefix() {
 	s.ServiceConfiguration(true, "", 5)
 
@@ -570,6 +707,148 @@ func (s *TestSignupServiceSuite) TestOKIfOtherUserBanned() {
 	require.Equal(s.T(), "a7b1b413c1cbddbcd19a51222ef8e20a", val.Labels[toolchainv1alpha1.UserSignupUserEmailHashLabelKey])
 }
 
+func (s *TestSignupServiceSuite) TestVerifiedEmailRequired() {
+	s.ServiceConfiguration(true, "", 5)
+	s.T().Setenv("REGISTRATION_SERVICE_AUTH_REQUIRE_VERIFIED_EMAIL", "true")
+	newContext := func(emailVerified bool) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "jsmith")
+		ctx.Set(context.SubKey, "userid")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		ctx.Set(context.EmailVerifiedKey, emailVerified)
+		return ctx
+	}
+
+	s.Run("email not verified", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		response, err := application.SignupService().Signup(newContext(false))
+
+		// then
+		require.Error(s.T(), err)
+		assert.Equal(s.T(), service.ForbiddenEmailNotVerifiedError, err)
+		require.Nil(s.T(), response)
+		userSignups := &toolchainv1alpha1.UserSignupList{}
+		require.NoError(s.T(), fakeClient.List(gocontext.TODO(), userSignups, client.InNamespace(commontest.HostOperatorNs)))
+		assert.Empty(s.T(), userSignups.Items)
+	})
+
+	s.Run("email verified", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		response, err := application.SignupService().Signup(newContext(true))
+
+		// then
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), response)
+	})
+}
+
+func (s *TestSignupServiceSuite) TestDeactivate() {
+	s.ServiceConfiguration(true, "", 5)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("deactivated", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+		// when
+		err := application.SignupService().Deactivate(ctx, "jsmith")
+
+		// then
+		require.NoError(s.T(), err)
+		deactivated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), deactivated))
+		assert.True(s.T(), states.Deactivated(deactivated))
+		assert.NotEmpty(s.T(), deactivated.Annotations[service.SelfDeactivatedAtAnnotationKey])
+	})
+
+	s.Run("already deactivated", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"), testusersignup.Deactivated())
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+		// when
+		err := application.SignupService().Deactivate(ctx, "jsmith")
+
+		// then
+		require.NoError(s.T(), err)
+		deactivated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), deactivated))
+		assert.True(s.T(), states.Deactivated(deactivated))
+		assert.NotContains(s.T(), deactivated.Annotations, service.SelfDeactivatedAtAnnotationKey)
+	})
+
+	s.Run("not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		err := application.SignupService().Deactivate(ctx, "jsmith")
+
+		// then
+		require.True(s.T(), apierrors.IsNotFound(err))
+	})
+}
+
+func (s *TestSignupServiceSuite) TestUpdateProfile() {
+	s.ServiceConfiguration(true, "", 5)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	profile := signup.Profile{GivenName: "Jane", FamilyName: "Smith", Company: "Red Hat"}
+
+	s.Run("updated", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+		// when
+		err := application.SignupService().UpdateProfile(ctx, "jsmith", profile)
+
+		// then
+		require.NoError(s.T(), err)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		assert.Equal(s.T(), "Jane", updated.Spec.IdentityClaims.GivenName)
+		assert.Equal(s.T(), "Smith", updated.Spec.IdentityClaims.FamilyName)
+		assert.Equal(s.T(), "Red Hat", updated.Spec.IdentityClaims.Company)
+		assert.Equal(s.T(), userSignup.Spec.IdentityClaims.Email, updated.Spec.IdentityClaims.Email)
+	})
+
+	s.Run("up-to-date", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"))
+		userSignup.Spec.IdentityClaims.GivenName = "Jane"
+		userSignup.Spec.IdentityClaims.FamilyName = "Smith"
+		userSignup.Spec.IdentityClaims.Company = "Red Hat"
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+		fakeClient.MockUpdate = func(_ gocontext.Context, _ client.Object, _ ...client.UpdateOption) error {
+			return errors.New("should not be updated")
+		}
+
+		// when
+		err := application.SignupService().UpdateProfile(ctx, "jsmith", profile)
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("not found", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		err := application.SignupService().UpdateProfile(ctx, "jsmith", profile)
+
+		// then
+		require.True(s.T(), apierrors.IsNotFound(err))
+	})
+}
+
 func (s *TestSignupServiceSuite) TestGetUserSignupFails() {
 	// given
 	username := "johnsmith"
@@ -819,6 +1098,94 @@ func (s *TestSignupServiceSuite) TestGetSignupStatusOK() {
 	}
 }
 
+func (s *TestSignupServiceSuite) TestGetSignupPhases() {
+	s.ServiceConfiguration(true, "", 5)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("pending approval", func() {
+		// given
+		us := testusersignup.NewUserSignup(testusersignup.WithEncodedName("ted@kubesaw"), testusersignup.VerificationRequired())
+		_, application := testutil.PrepareInClusterApp(s.T(), us)
+
+		// when
+		response, err := application.SignupService().GetSignup(c, "ted@kubesaw", true)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), []signup.Phase{
+			{Name: signup.PhaseApproval, Status: signup.PhaseStatusPending},
+			{Name: signup.PhaseSpaceProvisioning, Status: signup.PhaseStatusPending},
+			{Name: signup.PhaseNamespaces, Status: signup.PhaseStatusPending},
+		}, response.Phases)
+	})
+
+	s.Run("provisioned", func() {
+		// given
+		username, us := s.newUserSignupComplete()
+		mur := s.newProvisionedMUR("ted")
+		readyAt := v1.NewTime(time.Now().Add(-time.Minute))
+		homeSpace := s.newSpace(mur.Name)
+		homeSpace.Status.Conditions = []toolchainv1alpha1.Condition{
+			{Type: toolchainv1alpha1.ConditionReady, Status: apiv1.ConditionTrue, Reason: "Provisioned", LastTransitionTime: readyAt},
+		}
+		idler := &toolchainv1alpha1.Idler{
+			ObjectMeta: v1.ObjectMeta{Name: "ted-dev"},
+			Status: toolchainv1alpha1.IdlerStatus{
+				Conditions: []toolchainv1alpha1.Condition{
+					{Type: toolchainv1alpha1.ConditionReady, Status: apiv1.ConditionTrue, Reason: toolchainv1alpha1.IdlerRunningReason, LastTransitionTime: readyAt},
+				},
+			},
+		}
+		hostClient := commontest.NewFakeClient(s.T(), us, mur, homeSpace, s.newSpaceBinding(mur.Name, homeSpace.Name), s.newToolchainStatus(".apps."))
+		memberClient := commontest.NewFakeClient(s.T(), idler)
+		svc := service.NewSignupService(namespaced.NewClient(hostClient, commontest.HostOperatorNs),
+			service.WithGetMembersFunc(func(_ ...cluster.Condition) []*cluster.CachedToolchainCluster {
+				return []*cluster.CachedToolchainCluster{
+					{Config: &cluster.Config{Name: "member-123"}, Client: memberClient},
+				}
+			}))
+		approved, _ := condition.FindConditionByType(us.Status.Conditions, toolchainv1alpha1.UserSignupApproved)
+
+		// when
+		response, err := svc.GetSignup(c, username, true)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), []signup.Phase{
+			{Name: signup.PhaseApproval, Status: signup.PhaseStatusDone, Reason: approved.Reason, LastTransitionTime: approved.LastTransitionTime.UTC().Format(time.RFC3339)},
+			{Name: signup.PhaseSpaceProvisioning, Status: signup.PhaseStatusDone, Reason: "Provisioned", LastTransitionTime: readyAt.UTC().Format(time.RFC3339)},
+			{Name: signup.PhaseNamespaces, Status: signup.PhaseStatusDone, Message: "ted-dev", LastTransitionTime: readyAt.UTC().Format(time.RFC3339)},
+			{Name: signup.PhaseIdler, Status: signup.PhaseStatusDone, Reason: toolchainv1alpha1.IdlerRunningReason, LastTransitionTime: readyAt.UTC().Format(time.RFC3339)},
+		}, response.Phases)
+
+		s.Run("no phases for the proxy calls", func() {
+			// when
+			response, err := svc.GetSignup(c, username, false)
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Empty(s.T(), response.Phases)
+		})
+	})
+
+	s.Run("space not provisioned yet", func() {
+		// given
+		username, us := s.newUserSignupComplete()
+		mur := s.newProvisionedMUR("ted")
+		_, application := testutil.PrepareInClusterApp(s.T(), us, mur)
+
+		// when
+		response, err := application.SignupService().GetSignup(c, username, true)
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), response.Phases, 3)
+		assert.Equal(s.T(), signup.PhaseStatusDone, response.Phases[0].Status)
+		assert.Equal(s.T(), signup.PhaseStatusInProgress, response.Phases[1].Status)
+		assert.Equal(s.T(), signup.PhaseStatusPending, response.Phases[2].Status)
+	})
+}
+
 func (s *TestSignupServiceSuite) newToolchainStatus(appsSubDomain string) *toolchainv1alpha1.ToolchainStatus {
 	toolchainStatus := &toolchainv1alpha1.ToolchainStatus{
 		TypeMeta: v1.TypeMeta{},
@@ -874,6 +1241,28 @@ func (s *TestSignupServiceSuite) TestGetSignupStatusFailGetToolchainStatus() {
 	require.EqualError(s.T(), err, fmt.Sprintf("error when retrieving ToolchainStatus for completed UserSignup %s: toolchainstatuses.toolchain.dev.openshift.com \"toolchain-status\" not found", us.Name))
 }
 
+func (s *TestSignupServiceSuite) TestGetSignupStatusWithoutToolchainStatusAndSpace() {
+	// given
+	s.ServiceConfiguration(true, "", 5)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	username, us := s.newUserSignupComplete()
+	mur := s.newProvisionedMUR("ted")
+
+	_, application := testutil.PrepareInClusterApp(s.T(), us, mur)
+
+	// when
+	response, err := application.SignupService().GetSignup(c, username, true)
+
+	// then the ToolchainStatus is not required when the user has no space yet
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), response)
+	assert.True(s.T(), response.Status.Ready)
+	assert.Empty(s.T(), response.ProxyURL)
+	assert.Empty(s.T(), response.ClusterName)
+}
+
 func (s *TestSignupServiceSuite) TestGetSignupMURGetFails() {
 	// given
 	s.ServiceConfiguration(true, "", 5)
@@ -1210,6 +1599,20 @@ func (s *TestSignupServiceSuite) TestIsPhoneVerificationRequired() {
 			assert.InDelta(s.T(), float32(-1), score, 0.01)
 			assert.Empty(s.T(), assessmentID)
 		})
+		s.Run("user's email domain matches an excluded pattern", func() {
+			s.OverrideApplicationDefault(
+				testconfig.RegistrationService().
+					Verification().Enabled(true).
+					Verification().CaptchaEnabled(true).
+					Verification().ExcludedEmailDomains("redhat.com,*.edu,/^[a-z]+\\.gov\\.[a-z]{2}$/"))
+
+			for _, email := range []string{"joe@cs.mit.edu", "joe@state.gov.ca"} {
+				isVerificationRequired, score, assessmentID := service.IsPhoneVerificationRequired(nil, &gin.Context{Keys: map[string]interface{}{"email": email}})
+				assert.False(s.T(), isVerificationRequired)
+				assert.InDelta(s.T(), float32(-1), score, 0.01)
+				assert.Empty(s.T(), assessmentID)
+			}
+		})
 		s.Run("captcha is enabled and the assessment is successful", func() {
 			s.OverrideApplicationDefault(
 				testconfig.RegistrationService().
@@ -1222,6 +1625,38 @@ func (s *TestSignupServiceSuite) TestIsPhoneVerificationRequired() {
 			assert.InDelta(s.T(), float32(1.0), score, 0.01)
 			assert.Equal(s.T(), "captcha-assessment-123", assessmentID)
 		})
+		s.Run("captcha is enabled with the Turnstile provider", func() {
+			s.OverrideApplicationDefault(
+				testconfig.RegistrationService().
+					Verification().Enabled(true).
+					Verification().CaptchaEnabled(true).
+					Verification().CaptchaScoreThreshold("0.8"))
+			s.T().Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "turnstile")
+
+			// the reCAPTCHA token is ignored
+			isVerificationRequired, _, _ := service.IsPhoneVerificationRequired(&FakeCaptchaChecker{score: 1.0}, &gin.Context{Request: &http.Request{Header: http.Header{"Recaptcha-Token": []string{"123"}}}})
+			assert.True(s.T(), isVerificationRequired)
+
+			isVerificationRequired, score, _ := service.IsPhoneVerificationRequired(&FakeCaptchaChecker{score: 1.0}, &gin.Context{Request: &http.Request{Header: http.Header{"Cf-Turnstile-Response": []string{"123"}}}})
+			assert.False(s.T(), isVerificationRequired)
+			assert.InDelta(s.T(), float32(1.0), score, 0.01)
+		})
+		s.Run("captcha is enabled with the hCaptcha provider", func() {
+			s.OverrideApplicationDefault(
+				testconfig.RegistrationService().
+					Verification().Enabled(true).
+					Verification().CaptchaEnabled(true).
+					Verification().CaptchaScoreThreshold("0.8"))
+			s.T().Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "hcaptcha")
+
+			// the reCAPTCHA token is ignored
+			isVerificationRequired, _, _ := service.IsPhoneVerificationRequired(&FakeCaptchaChecker{score: 1.0}, &gin.Context{Request: &http.Request{Header: http.Header{"Recaptcha-Token": []string{"123"}}}})
+			assert.True(s.T(), isVerificationRequired)
+
+			isVerificationRequired, score, _ := service.IsPhoneVerificationRequired(&FakeCaptchaChecker{score: 0.9}, &gin.Context{Request: &http.Request{Header: http.Header{"H-Captcha-Response": []string{"123"}}}})
+			assert.False(s.T(), isVerificationRequired)
+			assert.InDelta(s.T(), float32(0.9), score, 0.01)
+		})
 
 	})
 
@@ -1498,13 +1933,234 @@ func (s *TestSignupServiceSuite) TestSignupCallsAccountVerifier() {
 type FakeCaptchaChecker struct {
 	score  float32
 	result error
+	// calls counts the assessments, if set
+	calls *int
 }
 
-func (c FakeCaptchaChecker) CompleteAssessment(_ *gin.Context, _ configuration.RegistrationServiceConfig, _ string) (*recaptchapb.Assessment, error) {
-	return &recaptchapb.Assessment{
-		RiskAnalysis: &recaptchapb.RiskAnalysis{
-			Score: c.score,
-		},
-		Name: "captcha-assessment-123",
+func (c FakeCaptchaChecker) CompleteAssessment(_ *gin.Context, _ configuration.RegistrationServiceConfig, _ string) (*captcha.Assessment, error) {
+	if c.calls != nil {
+		*c.calls++
+	}
+	return &captcha.Assessment{
+		Score: c.score,
+		Name:  "captcha-assessment-123",
 	}, c.result
 }
+
+func (s *TestSignupServiceSuite) TestSignupWithIdempotencyKey() {
+	s.ServiceConfiguration(true, "", 5)
+	// given
+	newContext := func(key string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "jsmith")
+		ctx.Set(context.SubKey, "987654321")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		ctx.Set(context.UserIDKey, "13349822")
+		ctx.Set(context.AccountIDKey, "45983711")
+		ctx.Set(context.IdempotencyKey, key)
+		return ctx
+	}
+
+	s.Run("repeated request", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		original, err := application.SignupService().Signup(newContext("a1b2c3"))
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "a1b2c3", original.Annotations[service.IdempotencyKeyAnnotationKey])
+		ctx := newContext("a1b2c3")
+
+		// when
+		userSignup, err := application.SignupService().Signup(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), original.Name, userSignup.Name)
+		assert.True(s.T(), ctx.GetBool(context.IdempotentReplayKey))
+	})
+
+	s.Run("another request", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+		_, err := application.SignupService().Signup(newContext("a1b2c3"))
+		require.NoError(s.T(), err)
+
+		// when
+		_, err = application.SignupService().Signup(newContext("d4e5f6"))
+
+		// then
+		require.True(s.T(), apierrors.IsConflict(err), err)
+	})
+
+	s.Run("concurrent repeat", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T())
+		_, err := application.SignupService().Signup(newContext("a1b2c3"))
+		require.NoError(s.T(), err)
+		// the UserSignup created by the concurrent request is not found by the first get
+		notFound := false
+		fakeClient.MockGet = func(ctx gocontext.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*toolchainv1alpha1.UserSignup); ok && !notFound {
+				notFound = true
+				return apierrors.NewNotFound(schema.GroupResource{}, key.Name)
+			}
+			return fakeClient.Client.Get(ctx, key, obj, opts...)
+		}
+		ctx := newContext("a1b2c3")
+
+		// when
+		_, err = application.SignupService().Signup(ctx)
+
+		// then
+		require.NoError(s.T(), err)
+		assert.True(s.T(), ctx.GetBool(context.IdempotentReplayKey))
+	})
+}
+
+func (s *TestSignupServiceSuite) TestTermsOfService() {
+	s.ServiceConfiguration(true, "", 5)
+	s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_VERSION", "2024-01")
+	s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_REQUIRED", "true")
+	newContext := func(version string) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Set(context.UsernameKey, "jsmith")
+		ctx.Set(context.SubKey, "userid")
+		ctx.Set(context.EmailKey, "jsmith@gmail.com")
+		if version != "" {
+			ctx.Set(context.TermsOfServiceVersionKey, version)
+		}
+		return ctx
+	}
+	newDeactivatedUserSignup := func(acceptedVersion string) *toolchainv1alpha1.UserSignup {
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"), testusersignup.Deactivated())
+		userSignup.Status.Conditions = fake.Deactivated()
+		userSignup.Annotations[service.TermsOfServiceVersionAnnotationKey] = acceptedVersion
+		userSignup.Annotations[service.TermsOfServiceAcceptedAtAnnotationKey] = "2024-01-15T10:00:00Z"
+		return userSignup
+	}
+
+	s.Run("signup with the current version", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		userSignup, err := application.SignupService().Signup(newContext("2024-01"))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "2024-01", userSignup.Annotations[service.TermsOfServiceVersionAnnotationKey])
+		assert.NotEmpty(s.T(), userSignup.Annotations[service.TermsOfServiceAcceptedAtAnnotationKey])
+	})
+
+	s.Run("signup with another version", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		_, err := application.SignupService().Signup(newContext("2023-06"))
+
+		// then
+		require.EqualError(s.T(), err, "invalid terms of service version: the version '2023-06' is not the current version of the terms of service")
+	})
+
+	s.Run("signup without acceptance", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		_, err := application.SignupService().Signup(newContext(""))
+
+		// then
+		require.EqualError(s.T(), err, "terms of service not accepted: the version '2024-01' of the terms of service must be accepted")
+		userSignups := &toolchainv1alpha1.UserSignupList{}
+		require.NoError(s.T(), fakeClient.List(gocontext.TODO(), userSignups, client.InNamespace(commontest.HostOperatorNs)))
+		assert.Empty(s.T(), userSignups.Items)
+	})
+
+	s.Run("signup without acceptance when not required", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_TERMS_OF_SERVICE_REQUIRED", "false")
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		userSignup, err := application.SignupService().Signup(newContext(""))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.NotContains(s.T(), userSignup.Annotations, service.TermsOfServiceVersionAnnotationKey)
+	})
+
+	s.Run("reactivation with the version accepted earlier", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), newDeactivatedUserSignup("2024-01"))
+
+		// when
+		userSignup, err := application.SignupService().Signup(newContext(""))
+
+		// then
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "2024-01", userSignup.Annotations[service.TermsOfServiceVersionAnnotationKey])
+		assert.Equal(s.T(), "2024-01-15T10:00:00Z", userSignup.Annotations[service.TermsOfServiceAcceptedAtAnnotationKey])
+	})
+
+	s.Run("reactivation with an outdated version accepted earlier", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), newDeactivatedUserSignup("2023-06"))
+
+		// when
+		_, err := application.SignupService().Signup(newContext(""))
+
+		// then
+		require.EqualError(s.T(), err, "terms of service not accepted: the version '2024-01' of the terms of service must be accepted")
+
+		s.Run("accepted again", func() {
+			// when
+			userSignup, err := application.SignupService().Signup(newContext("2024-01"))
+
+			// then
+			require.NoError(s.T(), err)
+			assert.Equal(s.T(), "2024-01", userSignup.Annotations[service.TermsOfServiceVersionAnnotationKey])
+			assert.NotEqual(s.T(), "2024-01-15T10:00:00Z", userSignup.Annotations[service.TermsOfServiceAcceptedAtAnnotationKey])
+		})
+	})
+
+	s.Run("accept", func() {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith"))
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup)
+
+		// when
+		err := application.SignupService().AcceptTermsOfService(newContext(""), "jsmith", "2024-01")
+
+		// then
+		require.NoError(s.T(), err)
+		updated := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), updated))
+		assert.Equal(s.T(), "2024-01", updated.Annotations[service.TermsOfServiceVersionAnnotationKey])
+		assert.NotEmpty(s.T(), updated.Annotations[service.TermsOfServiceAcceptedAtAnnotationKey])
+		signup, err := application.SignupService().GetSignup(newContext(""), "jsmith", false)
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "2024-01", signup.TermsOfServiceVersion)
+	})
+
+	s.Run("accept another version", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), testusersignup.NewUserSignup(testusersignup.WithEncodedName("jsmith")))
+
+		// when
+		err := application.SignupService().AcceptTermsOfService(newContext(""), "jsmith", "2023-06")
+
+		// then
+		require.EqualError(s.T(), err, "invalid terms of service version: the version '2023-06' is not the current version of the terms of service")
+	})
+
+	s.Run("accept without signup", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T())
+
+		// when
+		err := application.SignupService().AcceptTermsOfService(newContext(""), "jsmith", "2024-01")
+
+		// then
+		require.True(s.T(), apierrors.IsNotFound(err))
+	})
+}
@@ -47,12 +47,60 @@ type Signup struct {
 	AccountID string `json:"accountID,omitempty"`
 	// Email from the Identity Provider
 	Email string `json:"email,omitempty"`
+	// TermsOfServiceVersion is the version of the terms of service accepted by the user, if any
+	TermsOfServiceVersion string `json:"termsOfServiceVersion,omitempty"`
 
 	Status Status `json:"status,omitempty"`
 	// StartDate is the date that the user's current subscription started, in RFC3339 format
 	StartDate string `json:"startDate,omitempty"`
 	// End Date is the date that the user's current subscription will end, in RFC3339 format
 	EndDate string `json:"endDate,omitempty"`
+	// Phases is the breakdown of the provisioning of the user, so that the progress can be shown
+	Phases []Phase `json:"phases,omitempty"`
+}
+
+// the phases of the provisioning of a user
+const (
+	// PhaseApproval is the approval of the UserSignup
+	PhaseApproval = "approval"
+	// PhaseSpaceProvisioning is the provisioning of the home Space of the user
+	PhaseSpaceProvisioning = "spaceProvisioning"
+	// PhaseNamespaces is the readiness of the namespaces of the home Space of the user
+	PhaseNamespaces = "namespaces"
+	// PhaseIdler is the state of the Idler of the default namespace of the user
+	PhaseIdler = "idler"
+)
+
+// the statuses of the phases of the provisioning of a user
+const (
+	PhaseStatusPending    = "Pending"
+	PhaseStatusInProgress = "InProgress"
+	PhaseStatusDone       = "Done"
+)
+
+// Phase represents a phase of the provisioning of a user
+type Phase struct {
+	// Name of the phase, eg. 'approval'
+	Name string `json:"name"`
+	// Status of the phase: Pending, InProgress or Done
+	Status string `json:"status"`
+	// Brief reason for the status of the phase
+	Reason string `json:"reason,omitempty"`
+	// Human readable message indicating details about the status of the phase
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is the time the phase got its status, in RFC3339 format
+	LastTransitionTime string `json:"lastTransitionTime,omitempty"`
+}
+
+// Profile represents the mutable identity claims of a user, which are refreshed on their UserSignup resource after they changed
+// their profile in the Identity Provider
+type Profile struct {
+	// GivenName from the Identity Provider
+	GivenName string `json:"givenName"`
+	// FamilyName from the Identity Provider
+	FamilyName string `json:"familyName"`
+	// Company from the Identity Provider
+	Company string `json:"company"`
 }
 
 // Status represents UserSignup resource status
@@ -0,0 +1,103 @@
+package signup
+
+import (
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/codeready-toolchain/toolchain-common/pkg/cluster"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// SlowStartReleased is the decision of the slow-start when the placement of the new signup is left to the host operator,
+	// which may then place it in the member clusters being ramped up
+	SlowStartReleased = "released"
+	// SlowStartSteered is the decision of the slow-start when the new signup is pinned to a member cluster which is not being ramped up
+	SlowStartSteered = "steered"
+)
+
+var (
+	// MemberSlowStartShareGaugeVec reflects the share of the new signups the member clusters being ramped up can receive (via the `cluster` label)
+	MemberSlowStartShareGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sandbox_member_slow_start_share",
+		Help: "The share of the new signups a member cluster being ramped up can receive",
+	}, []string{"cluster"})
+	// SlowStartDecisionsCounterVec counts the decisions of the slow-start for the new signups (via the `decision` label),
+	// along with the member cluster the signups were pinned to (via the `cluster` label, empty for the released signups)
+	SlowStartDecisionsCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_member_slow_start_decisions_total",
+		Help: "The number of new signups released to, or steered away from, the member clusters being ramped up",
+	}, []string{"decision", "cluster"})
+
+	// slowStartRandom returns a pseudo-random number in [0.0,1.0), replaced in the tests
+	slowStartRandom = rand.Float64
+)
+
+// RegisterSlowStartMetrics registers the metrics of the slow-start of the member clusters in the given registry
+func RegisterSlowStartMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(MemberSlowStartShareGaugeVec, SlowStartDecisionsCounterVec)
+}
+
+// ApplySlowStart gradually ramps up the share of the new signups the newly added member clusters can receive, as configured
+// with the MemberSlowStartWindow setting. A member cluster is being ramped up during the window following the creation of its
+// SpaceProvisionerConfig, and the share of the new signups it can receive increases linearly over the window.
+// The signups which are not released to the member clusters being ramped up are pinned to the ready member cluster with
+// the fewest spaces among the other ones. The signups which already have a target cluster, or which are created without
+// a space, are left unchanged.
+func ApplySlowStart(ctx *gin.Context, cl namespaced.Client, userSignup *toolchainv1alpha1.UserSignup, now time.Time) error {
+	window := configuration.GetRegistrationServiceConfig().MemberSlowStartWindow()
+	if window <= 0 || userSignup.Spec.TargetCluster != "" || userSignup.Annotations[toolchainv1alpha1.SkipAutoCreateSpaceAnnotationKey] == "true" {
+		return nil
+	}
+	spcs := &toolchainv1alpha1.SpaceProvisionerConfigList{}
+	if err := cl.List(ctx, spcs, client.InNamespace(cl.Namespace)); err != nil {
+		return err
+	}
+
+	share := 1.0
+	var target *toolchainv1alpha1.SpaceProvisionerConfig
+	for i, spc := range spcs.Items {
+		if !spc.Spec.Enabled || !condition.IsTrue(spc.Status.Conditions, toolchainv1alpha1.ConditionReady) {
+			continue
+		}
+		if age := now.Sub(spc.CreationTimestamp.Time); age < window {
+			clusterShare := max(age.Seconds(), 0) / window.Seconds()
+			MemberSlowStartShareGaugeVec.WithLabelValues(spc.Spec.ToolchainCluster).Set(clusterShare)
+			share = min(share, clusterShare)
+			continue
+		}
+		MemberSlowStartShareGaugeVec.DeleteLabelValues(spc.Spec.ToolchainCluster)
+		// only the member clusters in which the host operator places the spaces by default can be targeted
+		if slices.Contains(spc.Spec.PlacementRoles, cluster.RoleLabel(cluster.Tenant)) &&
+			(target == nil || spaceCount(spc) < spaceCount(*target)) {
+			target = &spcs.Items[i]
+		}
+	}
+	if share >= 1 || target == nil {
+		// no member cluster is being ramped up, or no other member cluster can receive the signup
+		return nil
+	}
+	if slowStartRandom() < share {
+		SlowStartDecisionsCounterVec.WithLabelValues(SlowStartReleased, "").Inc()
+		return nil
+	}
+	log.Infof(ctx, "member clusters being ramped up, pinning the new signup to the '%s' member cluster", target.Spec.ToolchainCluster)
+	userSignup.Spec.TargetCluster = target.Spec.ToolchainCluster
+	SlowStartDecisionsCounterVec.WithLabelValues(SlowStartSteered, target.Spec.ToolchainCluster).Inc()
+	return nil
+}
+
+func spaceCount(spc toolchainv1alpha1.SpaceProvisionerConfig) int {
+	if spc.Status.ConsumedCapacity == nil {
+		return 0
+	}
+	return spc.Status.ConsumedCapacity.SpaceCount
+}
@@ -0,0 +1,166 @@
+package signup
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	spc "github.com/codeready-toolchain/toolchain-common/pkg/test/spaceprovisionerconfig"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestApplySlowStart(t *testing.T) {
+	// given
+	log.Init("slow-start-testing")
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	now := time.Now()
+	tenant := spc.PlacementRole("tenant")
+	newSPC := func(name string, age time.Duration, opts ...spc.CreateOption) *toolchainv1alpha1.SpaceProvisionerConfig {
+		opts = append([]spc.CreateOption{spc.ReferencingToolchainCluster(name), spc.Enabled(true), spc.WithReadyConditionValid(), spc.WithPlacementRoles(tenant)}, opts...)
+		config := spc.NewSpaceProvisionerConfig(name, commontest.HostOperatorNs, opts...)
+		config.CreationTimestamp = metav1.NewTime(now.Add(-age))
+		return config
+	}
+	mature := []*toolchainv1alpha1.SpaceProvisionerConfig{
+		newSPC("member-1", 30*24*time.Hour, spc.WithConsumedSpaceCount(100)),
+		newSPC("member-2", 30*24*time.Hour, spc.WithConsumedSpaceCount(50)),
+		// not eligible
+		newSPC("member-3", 30*24*time.Hour, spc.WithPlacementRoles(spc.PlacementRole("special"))),
+		newSPC("member-4", 30*24*time.Hour, spc.Enabled(false)),
+		newSPC("member-5", 30*24*time.Hour, spc.WithReadyConditionInvalid(toolchainv1alpha1.SpaceProvisionerConfigInsufficientCapacityReason)),
+	}
+	ramping := newSPC("member-6", 24*time.Hour)
+	newClient := func(configs ...*toolchainv1alpha1.SpaceProvisionerConfig) namespaced.Client {
+		objs := []*toolchainv1alpha1.SpaceProvisionerConfig{}
+		objs = append(objs, mature...)
+		objs = append(objs, configs...)
+		cl := commontest.NewFakeClient(t)
+		for _, o := range objs {
+			require.NoError(t, cl.Create(ctx, o.DeepCopy()))
+		}
+		return namespaced.NewClient(cl, commontest.HostOperatorNs)
+	}
+	withRandom := func(t *testing.T, value float64) {
+		original := slowStartRandom
+		slowStartRandom = func() float64 { return value }
+		t.Cleanup(func() { slowStartRandom = original })
+	}
+
+	t.Run("slow-start disabled", func(t *testing.T) {
+		// given
+		userSignup := &toolchainv1alpha1.UserSignup{}
+		withRandom(t, 0.9)
+
+		// when
+		err := ApplySlowStart(ctx, newClient(ramping), userSignup, now)
+
+		// then
+		require.NoError(t, err)
+		assert.Empty(t, userSignup.Spec.TargetCluster)
+	})
+
+	t.Run("slow-start enabled", func(t *testing.T) {
+		t.Setenv("REGISTRATION_SERVICE_MEMBER_SLOW_START_WINDOW", "96h")
+
+		t.Run("signup steered away from the member cluster being ramped up", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			withRandom(t, 0.3)
+			steered := testutil.ToFloat64(SlowStartDecisionsCounterVec.WithLabelValues(SlowStartSteered, "member-2"))
+
+			// when
+			err := ApplySlowStart(ctx, newClient(ramping), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "member-2", userSignup.Spec.TargetCluster)
+			assert.InDelta(t, 0.25, testutil.ToFloat64(MemberSlowStartShareGaugeVec.WithLabelValues("member-6")), 0.001)
+			assert.InDelta(t, steered+1, testutil.ToFloat64(SlowStartDecisionsCounterVec.WithLabelValues(SlowStartSteered, "member-2")), 0.001)
+		})
+
+		t.Run("signup released to the member cluster being ramped up", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			withRandom(t, 0.2)
+			released := testutil.ToFloat64(SlowStartDecisionsCounterVec.WithLabelValues(SlowStartReleased, ""))
+
+			// when
+			err := ApplySlowStart(ctx, newClient(ramping), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, userSignup.Spec.TargetCluster)
+			assert.InDelta(t, released+1, testutil.ToFloat64(SlowStartDecisionsCounterVec.WithLabelValues(SlowStartReleased, "")), 0.001)
+		})
+
+		t.Run("the youngest member cluster being ramped up determines the share", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			withRandom(t, 0.2)
+
+			// when
+			err := ApplySlowStart(ctx, newClient(ramping, newSPC("member-7", 12*time.Hour)), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "member-2", userSignup.Spec.TargetCluster)
+		})
+
+		t.Run("no member cluster being ramped up", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{}
+			withRandom(t, 0.9)
+
+			// when
+			err := ApplySlowStart(ctx, newClient(newSPC("member-6", 96*time.Hour)), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, userSignup.Spec.TargetCluster)
+		})
+
+		t.Run("signup with a target cluster", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{
+				Spec: toolchainv1alpha1.UserSignupSpec{
+					TargetCluster: "member-6",
+				},
+			}
+			withRandom(t, 0.9)
+
+			// when
+			err := ApplySlowStart(ctx, newClient(ramping), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Equal(t, "member-6", userSignup.Spec.TargetCluster)
+		})
+
+		t.Run("signup without a space", func(t *testing.T) {
+			// given
+			userSignup := &toolchainv1alpha1.UserSignup{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{
+						toolchainv1alpha1.SkipAutoCreateSpaceAnnotationKey: "true",
+					},
+				},
+			}
+			withRandom(t, 0.9)
+
+			// when
+			err := ApplySlowStart(ctx, newClient(ramping), userSignup, now)
+
+			// then
+			require.NoError(t, err)
+			assert.Empty(t, userSignup.Spec.TargetCluster)
+		})
+	})
+}
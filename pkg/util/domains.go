@@ -0,0 +1,94 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DomainPatterns matches domain names, such as the hosts of email addresses, with a list of patterns. A pattern is either:
+//   - a domain name, eg. 'example.com', which only matches this domain,
+//   - a wildcard pattern, eg. '*.edu' or '*.example.*', where '*' matches any sequence of characters, dots included,
+//   - a regular expression enclosed in slashes, eg. '/^[a-z]+\.gov\.[a-z]{2}$/'.
+//
+// The patterns are case-insensitive.
+type DomainPatterns struct {
+	patterns []domainPattern
+}
+
+type domainPattern struct {
+	pattern string
+	// exact is true if the pattern is a domain name
+	exact bool
+	// literals is the number of characters of the pattern other than wildcards, or -1 for regular expressions
+	literals int
+	regexp   *regexp.Regexp
+}
+
+// NewDomainPatterns returns the given domain patterns, ignoring the blank ones. The invalid patterns are ignored as well,
+// and returned in the error, so that an invalid pattern doesn't prevent the other ones from being matched.
+func NewDomainPatterns(patterns ...string) (*DomainPatterns, error) {
+	result := &DomainPatterns{}
+	var errs []error
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "":
+			continue
+		case len(p) > 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/"):
+			re, err := regexp.Compile("(?i)" + p[1:len(p)-1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("invalid domain pattern '%s': %w", p, err))
+				continue
+			}
+			result.patterns = append(result.patterns, domainPattern{pattern: p, literals: -1, regexp: re})
+		case strings.Contains(p, "*"):
+			parts := strings.Split(strings.ToLower(p), "*")
+			for i := range parts {
+				parts[i] = regexp.QuoteMeta(parts[i])
+			}
+			result.patterns = append(result.patterns, domainPattern{
+				pattern:  p,
+				literals: len(p) - strings.Count(p, "*"),
+				regexp:   regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$"),
+			})
+		default:
+			result.patterns = append(result.patterns, domainPattern{pattern: p, exact: true, literals: len(p)})
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+// Match returns the most specific pattern matching the given domain, if any: a domain name takes precedence over
+// the wildcard patterns, which take precedence over the regular expressions. Among the wildcard patterns, the one with
+// the most characters other than wildcards takes precedence, and among the regular expressions, the first one listed.
+func (d *DomainPatterns) Match(domain string) (string, bool) {
+	var match *domainPattern
+	for i, p := range d.patterns {
+		if match != nil && !p.moreSpecificThan(*match) {
+			continue
+		}
+		if p.matches(domain) {
+			match = &d.patterns[i]
+		}
+	}
+	if match == nil {
+		return "", false
+	}
+	return match.pattern, true
+}
+
+func (p domainPattern) matches(domain string) bool {
+	if p.exact {
+		return strings.EqualFold(p.pattern, domain)
+	}
+	return p.regexp.MatchString(domain)
+}
+
+func (p domainPattern) moreSpecificThan(other domainPattern) bool {
+	if p.exact != other.exact {
+		return p.exact
+	}
+	return p.literals > other.literals
+}
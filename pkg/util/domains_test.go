@@ -0,0 +1,115 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainPatterns(t *testing.T) {
+	t.Run("match", func(t *testing.T) {
+		// given
+		patterns, err := util.NewDomainPatterns("redhat.com", " *.edu ", "*.example.*", `/^[a-z]+\.gov\.[a-z]{2}$/`, "")
+		require.NoError(t, err)
+
+		for domain, expected := range map[string]string{
+			"redhat.com":       "redhat.com",
+			"RedHat.COM":       "redhat.com",
+			"mit.edu":          "*.edu",
+			"cs.mit.edu":       "*.edu",
+			"MIT.EDU":          "*.edu",
+			"mail.example.org": "*.example.*",
+			"a.example.co.uk":  "*.example.*",
+			"state.gov.ca":     `/^[a-z]+\.gov\.[a-z]{2}$/`,
+			"STATE.GOV.CA":     `/^[a-z]+\.gov\.[a-z]{2}$/`,
+		} {
+			t.Run(domain, func(t *testing.T) {
+				// when
+				pattern, found := patterns.Match(domain)
+
+				// then
+				require.True(t, found)
+				assert.Equal(t, expected, pattern)
+			})
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		// given
+		patterns, err := util.NewDomainPatterns("redhat.com", "*.edu", "*.example.*", `/^[a-z]+\.gov\.[a-z]{2}$/`)
+		require.NoError(t, err)
+
+		for _, domain := range []string{"", "sub.redhat.com", "redhat.com.evil", "edu", "example.com", "example.org", "city.state.gov.ca", "redhat-com"} {
+			t.Run(domain, func(t *testing.T) {
+				// when
+				_, found := patterns.Match(domain)
+
+				// then
+				assert.False(t, found)
+			})
+		}
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		// given
+		patterns, err := util.NewDomainPatterns(`/.*/`, `/^mail\./`, "*", "*.com", "*.redhat.com", "mail.redhat.com")
+		require.NoError(t, err)
+
+		for domain, expected := range map[string]string{
+			// the domain name takes precedence over the wildcard patterns and the regular expressions
+			"mail.redhat.com": "mail.redhat.com",
+			// the wildcard pattern with the most characters takes precedence
+			"www.redhat.com": "*.redhat.com",
+			"example.com":    "*.com",
+			// the wildcard patterns take precedence over the regular expressions
+			"mail.example.org": "*",
+		} {
+			t.Run(domain, func(t *testing.T) {
+				// when
+				pattern, found := patterns.Match(domain)
+
+				// then
+				require.True(t, found)
+				assert.Equal(t, expected, pattern)
+			})
+		}
+
+		t.Run("the first regular expression takes precedence", func(t *testing.T) {
+			// given
+			patterns, err := util.NewDomainPatterns(`/^mail\./`, `/.*/`)
+			require.NoError(t, err)
+
+			// when
+			pattern, found := patterns.Match("mail.example.org")
+
+			// then
+			require.True(t, found)
+			assert.Equal(t, `/^mail\./`, pattern)
+		})
+	})
+
+	t.Run("invalid patterns ignored", func(t *testing.T) {
+		// when
+		patterns, err := util.NewDomainPatterns("/[a-z/", "redhat.com", "/(/")
+
+		// then
+		require.ErrorContains(t, err, "invalid domain pattern '/[a-z/': error parsing regexp: missing closing ]")
+		require.ErrorContains(t, err, "invalid domain pattern '/(/': error parsing regexp: missing closing )")
+		_, found := patterns.Match("redhat.com")
+		assert.True(t, found)
+	})
+
+	t.Run("no pattern", func(t *testing.T) {
+		// given
+		patterns, err := util.NewDomainPatterns()
+		require.NoError(t, err)
+
+		// when
+		_, found := patterns.Match("redhat.com")
+
+		// then
+		assert.False(t, found)
+	})
+}
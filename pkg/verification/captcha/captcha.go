@@ -15,8 +15,39 @@ import (
 // recaptchaSignupAction is the action name corresponding to the token
 const recaptchaSignupAction = "SIGNUP"
 
+const (
+	// RecaptchaTokenHeader is the header of the signup requests with the reCAPTCHA token
+	RecaptchaTokenHeader = "Recaptcha-Token"
+	// TurnstileTokenHeader is the header of the signup requests with the Cloudflare Turnstile token
+	TurnstileTokenHeader = "Cf-Turnstile-Response"
+	// HCaptchaTokenHeader is the header of the signup requests with the hCaptcha token
+	HCaptchaTokenHeader = "H-Captcha-Response"
+)
+
+// Assessment is the result of the assessment of a captcha token, whatever the provider
+type Assessment struct {
+	// Name identifies the assessment at the provider, so that it can be annotated later on, eg. when the user is banned.
+	// Empty when the provider doesn't support it.
+	Name string
+	// Score is the score of the risk analysis, from 0.0 (likely a bot) to 1.0 (likely a human)
+	Score float32
+}
+
 type Assessor interface {
-	CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*recaptchapb.Assessment, error)
+	CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error)
+}
+
+// TokenHeader returns the header of the signup requests with the captcha token of the configured provider (see the CaptchaProvider
+// setting)
+func TokenHeader(cfg configuration.RegistrationServiceConfig) string {
+	switch cfg.Verification().CaptchaProvider() {
+	case configuration.CaptchaProviderTurnstile:
+		return TurnstileTokenHeader
+	case configuration.CaptchaProviderHCaptcha:
+		return HCaptchaTokenHeader
+	default:
+		return RecaptchaTokenHeader
+	}
 }
 
 type Helper struct{}
@@ -31,7 +62,21 @@ type Helper struct{}
 
 returns the assessment and nil if the assessment was successful, otherwise returns nil and the error.
 */
-func (c Helper) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*recaptchapb.Assessment, error) {
+func (c Helper) CompleteAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	switch provider := cfg.Verification().CaptchaProvider(); provider {
+	case configuration.CaptchaProviderRecaptcha:
+		return completeRecaptchaAssessment(ctx, cfg, token)
+	case configuration.CaptchaProviderTurnstile:
+		return completeTurnstileAssessment(ctx, cfg, token)
+	case configuration.CaptchaProviderHCaptcha:
+		return completeHCaptchaAssessment(ctx, cfg, token)
+	default:
+		return nil, fmt.Errorf("unknown captcha provider: '%s'", provider)
+	}
+}
+
+// completeRecaptchaAssessment creates a reCAPTCHA Enterprise assessment of the given token
+func completeRecaptchaAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
 	gctx := gocontext.Background()
 	client, err := recaptcha.NewClient(gctx)
 	if err != nil {
@@ -81,7 +126,10 @@ func (c Helper) CompleteAssessment(ctx *gin.Context, cfg configuration.Registrat
 			log.Info(ctx, fmt.Sprintf("Risk analysis reason: %s", reason.String()))
 		}
 		log.Info(ctx, fmt.Sprintf("Assessment Response: %+v", response))
-		return response, nil
+		return &Assessment{
+			Name:  response.GetName(),
+			Score: response.GetRiskAnalysis().GetScore(),
+		}, nil
 	}
 
 	return nil, fmt.Errorf("the action attribute in the reCAPTCHA token does not match the expected action to score")
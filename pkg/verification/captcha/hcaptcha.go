@@ -0,0 +1,50 @@
+package captcha
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+// hcaptchaVerifyURL is the URL of the endpoint of hCaptcha which validates the tokens
+const hcaptchaVerifyURL = "https://api.hcaptcha.com/siteverify"
+
+type hcaptchaResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+	Hostname   string   `json:"hostname"`
+	// Score is the risk score of the hCaptcha Enterprise accounts, from 0.0 (no risk) to 1.0 (confirmed threat)
+	Score       *float32 `json:"score"`
+	ScoreReason []string `json:"score_reason"`
+}
+
+// completeHCaptchaAssessment validates the given token with hCaptcha. The risk score of hCaptcha Enterprise is inverted into the score
+// of the assessment, so that it has the same meaning as the score of reCAPTCHA, and the assessment of a valid token has the maximum
+// score when hCaptcha doesn't score the users. An error is returned for an invalid token.
+func completeHCaptchaAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	form := url.Values{
+		"secret":   {cfg.Verification().CaptchaHCaptchaSecretKey()},
+		"response": {token},
+	}
+	if siteKey := cfg.Verification().CaptchaSiteKey(); siteKey != "" {
+		// the token must have been issued for the site key
+		form.Set("sitekey", siteKey)
+	}
+	result := hcaptchaResponse{}
+	if err := siteVerify(ctx, "hCaptcha", hcaptchaVerifyURL, form, &result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("the hCaptcha token was invalid for the following reasons: %v", result.ErrorCodes)
+	}
+	assessment := &Assessment{Score: 1.0}
+	if result.Score != nil {
+		assessment.Score = 1.0 - *result.Score
+		log.Info(ctx, fmt.Sprintf("hCaptcha risk score: %.1f, reasons: %v", *result.Score, result.ScoreReason))
+	}
+	log.Info(ctx, fmt.Sprintf("hCaptcha token validated for hostname '%s'", result.Hostname))
+	return assessment, nil
+}
@@ -0,0 +1,94 @@
+package captcha_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestHCaptchaAssessment(t *testing.T) {
+	// given
+	log.Init("captcha-testing")
+	t.Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "hcaptcha")
+	cfg := configuration.NewRegistrationServiceConfig(
+		commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().Verification().CaptchaEnabled(true).
+			Verification().CaptchaSiteKey("10000000-ffff").
+			Verification().Secret().Ref("registration-service-secrets")),
+		map[string]map[string]string{"registration-service-secrets": {configuration.CaptchaHCaptchaSecretKeyKey: "0x0000"}})
+	newContext := func() *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/signup", nil)
+		return ctx
+	}
+	defer gock.Off()
+
+	t.Run("valid token", func(t *testing.T) {
+		// given
+		gock.New("https://api.hcaptcha.com").
+			Post("/siteverify").
+			MatchType("url").
+			BodyString(`remoteip=192.0.2.1&response=t0k3n&secret=0x0000&sitekey=10000000-ffff`).
+			Reply(http.StatusOK).
+			BodyString(`{"success":true,"hostname":"sandbox.acme.com"}`)
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, &captcha.Assessment{Score: 1.0}, assessment)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("valid token with risk score", func(t *testing.T) {
+		// given
+		gock.New("https://api.hcaptcha.com").
+			Post("/siteverify").
+			Reply(http.StatusOK).
+			BodyString(`{"success":true,"hostname":"sandbox.acme.com","score":0.75,"score_reason":["automation"]}`)
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.NoError(t, err)
+		assert.InDelta(t, float32(0.25), assessment.Score, 0.01)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		// given
+		gock.New("https://api.hcaptcha.com").
+			Post("/siteverify").
+			Reply(http.StatusOK).
+			BodyString(`{"success":false,"error-codes":["invalid-or-already-seen-response"]}`)
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.EqualError(t, err, "the hCaptcha token was invalid for the following reasons: [invalid-or-already-seen-response]")
+	})
+
+	t.Run("hCaptcha unavailable", func(t *testing.T) {
+		// given
+		gock.New("https://api.hcaptcha.com").
+			Post("/siteverify").
+			Reply(http.StatusServiceUnavailable)
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.EqualError(t, err, "failed to validate the hCaptcha token: status 503")
+	})
+}
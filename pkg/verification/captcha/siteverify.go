@@ -0,0 +1,40 @@
+package captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// siteVerifyClient is the client of the requests validating the tokens with the siteverify endpoints of Turnstile and hCaptcha
+var siteVerifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// siteVerify posts the given form, completed with the IP address of the client of the given request, to the siteverify endpoint of
+// the given URL, and decodes the response in the given result. The provider is the name of the provider in the errors.
+func siteVerify(ctx *gin.Context, provider, endpoint string, form url.Values, result interface{}) error {
+	if ctx.Request != nil {
+		form.Set("remoteip", ctx.ClientIP())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("error creating the %s request: %w", provider, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := siteVerifyClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to validate the %s token: %w", provider, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to validate the %s token: status %d", provider, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("failed to read the %s response: %w", provider, err)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package captcha
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/gin-gonic/gin"
+)
+
+// turnstileVerifyURL is the URL of the endpoint of Cloudflare Turnstile which validates the tokens
+const turnstileVerifyURL = "https://challenges.cloudflare.com/turnstile/v0/siteverify"
+
+type turnstileResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+	Hostname   string   `json:"hostname"`
+	Action     string   `json:"action"`
+}
+
+// completeTurnstileAssessment validates the given token with Cloudflare Turnstile. Since Turnstile doesn't score the users, the
+// assessment of a valid token has the maximum score, and an error is returned for an invalid token.
+func completeTurnstileAssessment(ctx *gin.Context, cfg configuration.RegistrationServiceConfig, token string) (*Assessment, error) {
+	result := turnstileResponse{}
+	if err := siteVerify(ctx, "Turnstile", turnstileVerifyURL, url.Values{
+		"secret":   {cfg.Verification().CaptchaTurnstileSecretKey()},
+		"response": {token},
+	}, &result); err != nil {
+		return nil, err
+	}
+	if !result.Success {
+		return nil, fmt.Errorf("the Turnstile token was invalid for the following reasons: %v", result.ErrorCodes)
+	}
+	log.Info(ctx, fmt.Sprintf("Turnstile token validated for hostname '%s' and action '%s'", result.Hostname, result.Action))
+	return &Assessment{Score: 1.0}, nil
+}
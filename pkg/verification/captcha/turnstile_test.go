@@ -0,0 +1,89 @@
+package captcha_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/codeready-toolchain/registration-service/pkg/verification/captcha"
+	commonconfig "github.com/codeready-toolchain/toolchain-common/pkg/configuration"
+	testconfig "github.com/codeready-toolchain/toolchain-common/pkg/test/config"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/h2non/gock.v1"
+)
+
+func TestTurnstileAssessment(t *testing.T) {
+	// given
+	log.Init("captcha-testing")
+	t.Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "turnstile")
+	cfg := configuration.NewRegistrationServiceConfig(
+		commonconfig.NewToolchainConfigObjWithReset(t, testconfig.RegistrationService().Verification().CaptchaEnabled(true).
+			Verification().Secret().Ref("registration-service-secrets")),
+		map[string]map[string]string{"registration-service-secrets": {configuration.CaptchaTurnstileSecretKeyKey: "0x4AAA"}})
+	newContext := func() *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/api/v1/signup", nil)
+		return ctx
+	}
+	defer gock.Off()
+
+	t.Run("valid token", func(t *testing.T) {
+		// given
+		gock.New("https://challenges.cloudflare.com").
+			Post("/turnstile/v0/siteverify").
+			MatchType("url").
+			BodyString(`remoteip=192.0.2.1&response=t0k3n&secret=0x4AAA`).
+			Reply(http.StatusOK).
+			BodyString(`{"success":true,"hostname":"sandbox.acme.com","action":"signup"}`)
+
+		// when
+		assessment, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.NoError(t, err)
+		assert.Equal(t, &captcha.Assessment{Score: 1.0}, assessment)
+		assert.True(t, gock.IsDone())
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		// given
+		gock.New("https://challenges.cloudflare.com").
+			Post("/turnstile/v0/siteverify").
+			Reply(http.StatusOK).
+			BodyString(`{"success":false,"error-codes":["timeout-or-duplicate"]}`)
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.EqualError(t, err, "the Turnstile token was invalid for the following reasons: [timeout-or-duplicate]")
+	})
+
+	t.Run("Turnstile unavailable", func(t *testing.T) {
+		// given
+		gock.New("https://challenges.cloudflare.com").
+			Post("/turnstile/v0/siteverify").
+			Reply(http.StatusInternalServerError)
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.EqualError(t, err, "failed to validate the Turnstile token: status 500")
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		// given
+		t.Setenv("REGISTRATION_SERVICE_CAPTCHA_PROVIDER", "friendlycaptcha")
+
+		// when
+		_, err := captcha.Helper{}.CompleteAssessment(newContext(), cfg, "t0k3n")
+
+		// then
+		require.EqualError(t, err, "unknown captcha provider: 'friendlycaptcha'")
+	})
+}
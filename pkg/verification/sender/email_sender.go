@@ -0,0 +1,52 @@
+package sender
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailSender interface {
+	SendEmail(ctx *gin.Context, to, subject, body string) error
+}
+
+type SMTPConfig interface {
+	SMTPAddress() string
+	SMTPUsername() string
+	SMTPPassword() string
+	From() string
+}
+
+type SMTPEmailSender struct {
+	Config SMTPConfig
+}
+
+func NewSMTPEmailSender(cfg SMTPConfig) EmailSender {
+	return &SMTPEmailSender{
+		Config: cfg,
+	}
+}
+
+// SendEmail sends a plain text email with the given subject and body to the given address, authenticating on the SMTP server
+// only when a username is configured
+func (s *SMTPEmailSender) SendEmail(_ *gin.Context, to, subject, body string) error {
+	// the address and the subject are set in the headers of the message, which must not be split
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid email address or subject")
+	}
+	address := s.Config.SMTPAddress()
+	var auth smtp.Auth
+	if username := s.Config.SMTPUsername(); username != "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", username, s.Config.SMTPPassword(), host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.Config.From(), to, subject, body)
+	return smtp.SendMail(address, auth, s.Config.From(), []string{to}, []byte(msg))
+}
@@ -0,0 +1,118 @@
+package sender_test
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+
+	sender2 "github.com/codeready-toolchain/registration-service/pkg/verification/sender"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type MockSMTPConfig struct {
+	Address string
+}
+
+func (c *MockSMTPConfig) SMTPAddress() string {
+	return c.Address
+}
+
+func (c *MockSMTPConfig) SMTPUsername() string {
+	return ""
+}
+
+func (c *MockSMTPConfig) SMTPPassword() string {
+	return ""
+}
+
+func (c *MockSMTPConfig) From() string {
+	return "noreply@kubesaw.io"
+}
+
+// serveSMTP accepts a single connection on the given listener, and returns the envelope and the data of the message it receives
+func serveSMTP(t *testing.T, listener net.Listener) <-chan []string {
+	received := make(chan []string, 1)
+	go func() {
+		defer close(received)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) {
+			_, err := conn.Write([]byte(line + "\r\n"))
+			assert.NoError(t, err)
+		}
+		var lines []string
+		reply("220 localhost")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			switch {
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "MAIL FROM"), strings.HasPrefix(line, "RCPT TO"):
+				lines = append(lines, line)
+				reply("250 OK")
+			case line == "DATA":
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil || data == ".\r\n" {
+						break
+					}
+					lines = append(lines, strings.TrimRight(data, "\r\n"))
+				}
+				reply("250 OK")
+			case line == "QUIT":
+				reply("221 bye")
+				received <- lines
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return received
+}
+
+func TestSMTPEmailSender(t *testing.T) {
+	t.Run("sent", func(t *testing.T) {
+		// given
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		received := serveSMTP(t, listener)
+		sender := sender2.NewSMTPEmailSender(&MockSMTPConfig{Address: listener.Addr().String()})
+
+		// when
+		err = sender.SendEmail(&gin.Context{}, "johnny@kubesaw.io", "Verify your account", "https://kubesaw.io/verify")
+
+		// then
+		require.NoError(t, err)
+		lines := <-received
+		assert.Contains(t, lines, "MAIL FROM:<noreply@kubesaw.io>")
+		assert.Contains(t, lines, "RCPT TO:<johnny@kubesaw.io>")
+		assert.Contains(t, lines, "To: johnny@kubesaw.io")
+		assert.Contains(t, lines, "Subject: Verify your account")
+		assert.Contains(t, lines, "https://kubesaw.io/verify")
+	})
+
+	t.Run("invalid address", func(t *testing.T) {
+		// given
+		sender := sender2.NewSMTPEmailSender(&MockSMTPConfig{Address: "127.0.0.1:1"})
+
+		// when
+		err := sender.SendEmail(&gin.Context{}, "johnny@kubesaw.io\r\nBcc: all@kubesaw.io", "Verify your account", "https://kubesaw.io/verify")
+
+		// then
+		require.EqualError(t, err, "invalid email address or subject")
+	})
+}
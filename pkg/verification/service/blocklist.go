@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// PhonePrefixBlocklistName is the name of the ConfigMap, in the host-operator namespace, listing the prefixes of the
+	// phone numbers no verification code is sent to. The ConfigMap is managed by the admins, and is optional.
+	PhonePrefixBlocklistName = "phone-verification-blocklist"
+	// PhonePrefixBlocklistKey is the key of the blocklist ConfigMap holding the blocked prefixes, one per line
+	// or separated by commas, in the E.164 format (eg. '+1900' or '+4470')
+	PhonePrefixBlocklistKey = "prefixes"
+)
+
+// BlockedPhonePrefixCounterVec counts the verification attempts blocked because of the prefix of the phone number (via the `prefix` label)
+var BlockedPhonePrefixCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "sandbox_verification_blocked_phone_prefix_total",
+	Help: "The number of phone verification attempts blocked because of the prefix of the phone number",
+}, []string{"prefix"})
+
+// RegisterBlocklistMetrics registers the metrics of the phone prefix blocklist in the given registry
+func RegisterBlocklistMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(BlockedPhonePrefixCounterVec)
+}
+
+// blockedPhonePrefix returns the longest prefix of the given E.164 phone number listed in the blocklist ConfigMap, or an empty
+// string if the phone number is not blocked. The ConfigMap is read on each call from the cache of the client, so that
+// the changes of the blocklist are effective without restarting the service.
+func blockedPhonePrefix(ctx *gin.Context, cl namespaced.Client, e164PhoneNumber string) (string, error) {
+	blocklist := &corev1.ConfigMap{}
+	if err := cl.Get(ctx, cl.NamespacedName(PhonePrefixBlocklistName), blocklist); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	blocked := ""
+	for _, prefix := range strings.FieldsFunc(blocklist.Data[PhonePrefixBlocklistKey], func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		prefix = strings.TrimSpace(prefix)
+		if prefix == "" {
+			continue
+		}
+		if !strings.HasPrefix(prefix, "+") {
+			prefix = "+" + prefix
+		}
+		if strings.HasPrefix(e164PhoneNumber, prefix) && len(prefix) > len(blocked) {
+			blocked = prefix
+		}
+	}
+	return blocked, nil
+}
@@ -0,0 +1,248 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	crterrors "github.com/codeready-toolchain/registration-service/pkg/errors"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	signuppkg "github.com/codeready-toolchain/registration-service/pkg/signup"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	signupcommon "github.com/codeready-toolchain/toolchain-common/pkg/usersignup"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// EmailVerificationNonceAnnotationKey is the annotation of the UserSignups with the nonce of the last verification link sent by
+	// email, which is removed once the link was used, so that each link can be used only once
+	EmailVerificationNonceAnnotationKey = toolchainv1alpha1.LabelKeyPrefix + "email-verification-nonce"
+
+	// EmailVerificationLinkPath is the path of the endpoint the verification links sent by email point to, with the token of the
+	// link in the `token` query parameter
+	EmailVerificationLinkPath = "/api/v1/signup/verification/email-link"
+)
+
+// emailLink is the payload of the tokens of the verification links sent by email
+type emailLink struct {
+	// Name is the name of the UserSignup of the user
+	Name string `json:"name"`
+	// Nonce is the value of the EmailVerificationNonceAnnotationKey annotation of the UserSignup when the link was sent
+	Nonce string `json:"nonce"`
+	// ExpiresAt is the time the link expires at, in seconds since the epoch
+	ExpiresAt int64 `json:"exp"`
+}
+
+// InitEmailVerification sends a one-time verification link to the email address of the specified user, as an alternative to the
+// phone verification. The links count towards the same daily limit as the phone verification codes.
+func (s *ServiceImpl) InitEmailVerification(ctx *gin.Context, username string) error {
+	cfg := configuration.GetRegistrationServiceConfig()
+	if !cfg.EmailVerification().Enabled() {
+		return crterrors.NewForbiddenError("forbidden request", "email verification is not enabled")
+	}
+	if cfg.EmailVerification().SigningKey() == "" {
+		return crterrors.NewInternalError(errors.New("the signing key of the email verification links is not set"), "error while generating verification link")
+	}
+
+	signup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return crterrors.NewNotFoundError(err, "usersignup not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
+	}
+
+	// check that verification is required before proceeding
+	if !states.VerificationRequired(signup) {
+		log.Info(ctx, fmt.Sprintf("email verification attempted for user without verification requirement: '%s'", signup.Name))
+		return crterrors.NewBadRequest("forbidden request", "verification link will not be sent")
+	}
+	email := signup.Spec.IdentityClaims.Email
+	if email == "" {
+		return crterrors.NewBadRequest("forbidden request", "the user has no email address")
+	}
+
+	now := time.Now()
+	annotationValues := map[string]string{}
+	dailyLimit := cfg.Verification().DailyLimit()
+	counter := dailyVerificationCounter(ctx, signup, annotationValues, dailyLimit, now)
+
+	var initError error
+	if counter >= dailyLimit {
+		log.Info(ctx, fmt.Sprintf("%d attempts made. the daily limit of %d has been exceeded", counter, dailyLimit))
+		initError = crterrors.NewForbiddenError("daily limit exceeded", "cannot generate new verification link")
+	} else {
+		nonce, err := generateNonce()
+		if err != nil {
+			return crterrors.NewInternalError(err, "error while generating verification link")
+		}
+		token, err := signEmailLink(emailLink{
+			Name:      signup.Name,
+			Nonce:     nonce,
+			ExpiresAt: now.Add(cfg.EmailVerification().LinkExpiresIn()).Unix(),
+		}, cfg.EmailVerification().SigningKey())
+		if err != nil {
+			return crterrors.NewInternalError(err, "error while generating verification link")
+		}
+		link := strings.TrimSuffix(cfg.RegistrationServiceURL(), "/") + EmailVerificationLinkPath + "?token=" + url.QueryEscape(token)
+		content := fmt.Sprintf(cfg.EmailVerification().MessageTemplate(), link)
+
+		if err := s.EmailService.SendEmail(ctx, email, cfg.EmailVerification().Subject(), content); err != nil {
+			log.Error(ctx, err, "error while sending email")
+			initError = crterrors.NewInternalError(err, "error while sending verification link")
+		} else {
+			// Email sent successfully, set the verification annotations
+			annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = strconv.Itoa(counter + 1)
+			annotationValues[EmailVerificationNonceAnnotationKey] = nonce
+		}
+	}
+
+	doUpdate := func() error {
+		signup := &toolchainv1alpha1.UserSignup{}
+		if err := s.Get(ctx, s.NamespacedName(signupcommon.EncodeUserIdentifier(username)), signup); err != nil {
+			return err
+		}
+		if signup.Annotations == nil {
+			signup.Annotations = map[string]string{}
+		}
+		for k, v := range annotationValues {
+			signup.Annotations[k] = v
+		}
+		return s.Update(ctx, signup)
+	}
+
+	if updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate); updateErr != nil {
+		log.Error(ctx, updateErr, "error updating UserSignup")
+		return errUpdatingAccount()
+	}
+
+	return initError
+}
+
+// VerifyEmailLink validates the token of a verification link sent by email, and clears the verification requirement of the
+// UserSignup of the link. Each link can be used only once, and only the last link sent to the user is valid.
+func (s *ServiceImpl) VerifyEmailLink(ctx *gin.Context, token string) error {
+	cfg := configuration.GetRegistrationServiceConfig()
+	if cfg.EmailVerification().SigningKey() == "" {
+		return crterrors.NewForbiddenError("invalid link", "the verification link is invalid")
+	}
+	link, err := parseEmailLink(token, cfg.EmailVerification().SigningKey())
+	if err != nil {
+		log.Error(ctx, err, "invalid email verification link")
+		return crterrors.NewForbiddenError("invalid link", "the verification link is invalid")
+	}
+	if time.Now().Unix() > link.ExpiresAt {
+		return crterrors.NewForbiddenError("expired", "the verification link has expired")
+	}
+
+	signup := &toolchainv1alpha1.UserSignup{}
+	if err := s.Get(ctx, s.NamespacedName(link.Name), signup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Error(ctx, err, "usersignup not found")
+			return crterrors.NewNotFoundError(err, "user not found")
+		}
+		log.Error(ctx, err, "error retrieving usersignup")
+		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup '%s'", link.Name))
+	}
+	if nonce := signup.Annotations[EmailVerificationNonceAnnotationKey]; nonce == "" ||
+		!hmac.Equal([]byte(nonce), []byte(link.Nonce)) {
+		// the link was already used, or another link was sent since
+		return crterrors.NewForbiddenError("invalid link", "the verification link is invalid")
+	}
+	if err := checkRequiredManualApprovalOnActivation(ctx, signup, cfg); err != nil {
+		return err
+	}
+
+	doUpdate := func() error {
+		signup := &toolchainv1alpha1.UserSignup{}
+		if err := s.Get(ctx, s.NamespacedName(link.Name), signup); err != nil {
+			return err
+		}
+		if signup.Annotations[EmailVerificationNonceAnnotationKey] != link.Nonce {
+			// the link was used in the meantime
+			return crterrors.NewForbiddenError("invalid link", "the verification link is invalid")
+		}
+		states.SetVerificationRequired(signup, false)
+		for _, annotationName := range []string{
+			EmailVerificationNonceAnnotationKey,
+			toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey,
+			toolchainv1alpha1.UserVerificationAttemptsAnnotationKey,
+			toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey,
+			toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey,
+			toolchainv1alpha1.UserVerificationExpiryAnnotationKey,
+		} {
+			delete(signup.Annotations, annotationName)
+		}
+		return s.Update(ctx, signup)
+	}
+
+	if updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate); updateErr != nil {
+		e := &crterrors.Error{}
+		if errors.As(updateErr, &e) {
+			return e
+		}
+		log.Error(ctx, updateErr, "error updating UserSignup")
+		return errUpdatingAccount()
+	}
+	log.Info(ctx, fmt.Sprintf("usersignup '%s' verified by email", link.Name))
+	return nil
+}
+
+// signEmailLink returns the token of the given link: the payload and its HMAC-SHA256 signature, both encoded in base64 (URL) and
+// separated with a dot
+func signEmailLink(link emailLink, key string) (string, error) {
+	payload, err := json.Marshal(link)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature(encoded, key)), nil
+}
+
+// parseEmailLink returns the link of the given token, if its signature is valid
+func parseEmailLink(token, key string) (emailLink, error) {
+	link := emailLink{}
+	encoded, sig, found := strings.Cut(token, ".")
+	if !found {
+		return link, errors.New("malformed token")
+	}
+	decodedSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(decodedSig, signature(encoded, key)) {
+		return link, errors.New("invalid signature")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return link, err
+	}
+	err = json.Unmarshal(payload, &link)
+	return link, err
+}
+
+func signature(payload, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+func generateNonce() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,205 @@
+package service_test
+
+import (
+	gocontext "context"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/namespaced"
+	verificationservice "github.com/codeready-toolchain/registration-service/pkg/verification/service"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	commontest "github.com/codeready-toolchain/toolchain-common/pkg/test"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// emailSender records the emails it sends
+type emailSender struct {
+	to, subject, body []string
+	err               error
+}
+
+func (s *emailSender) SendEmail(_ *gin.Context, to, subject, body string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.to = append(s.to, to)
+	s.subject = append(s.subject, subject)
+	s.body = append(s.body, body)
+	return nil
+}
+
+var tokenRegexp = regexp.MustCompile(`\?token=(\S+)`)
+
+// tokenOf returns the token of the link of the given body of an email
+func tokenOf(t require.TestingT, body string) string {
+	match := tokenRegexp.FindStringSubmatch(body)
+	require.Len(t, match, 2)
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func (s *TestVerificationServiceSuite) TestEmailVerification() {
+	// given
+	s.T().Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_ENABLED", "true")
+	s.ServiceConfiguration("xxx", "yyy", "CodeReady")
+	s.SetRegistrationServiceSecret(map[string]string{configuration.EmailVerificationSigningKeyKey: "s1gn1ng"})
+	newService := func(objects ...client.Object) (*commontest.FakeClient, *emailSender, *verificationservice.ServiceImpl) {
+		fakeClient := commontest.NewFakeClient(s.T(), objects...)
+		sender := &emailSender{}
+		return fakeClient, sender, &verificationservice.ServiceImpl{
+			Client:       namespaced.NewClient(fakeClient, commontest.HostOperatorNs),
+			EmailService: sender,
+		}
+	}
+	newUserSignup := func() *toolchainv1alpha1.UserSignup {
+		return testusersignup.NewUserSignup(
+			testusersignup.WithEncodedName("johnny@kubesaw"),
+			testusersignup.WithEmail("johnny@kubesaw.io"),
+			testusersignup.VerificationRequiredAgo(time.Second))
+	}
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	s.Run("verified with the link", func() {
+		// given
+		userSignup := newUserSignup()
+		fakeClient, sender, svc := newService(userSignup)
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.NoError(s.T(), err)
+		require.Len(s.T(), sender.to, 1)
+		assert.Equal(s.T(), "johnny@kubesaw.io", sender.to[0])
+		assert.Equal(s.T(), "Verify your Developer Sandbox account", sender.subject[0])
+		assert.Contains(s.T(), sender.body[0], verificationservice.EmailVerificationLinkPath+"?token=")
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.NotEmpty(s.T(), signup.Annotations[verificationservice.EmailVerificationNonceAnnotationKey])
+		assert.Equal(s.T(), "1", signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey])
+		assert.True(s.T(), states.VerificationRequired(signup))
+
+		// when
+		err = svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[0]))
+
+		// then
+		require.NoError(s.T(), err)
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.False(s.T(), states.VerificationRequired(signup))
+		assert.NotContains(s.T(), signup.Annotations, verificationservice.EmailVerificationNonceAnnotationKey)
+		assert.NotContains(s.T(), signup.Annotations, toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey)
+
+		s.Run("link used twice", func() {
+			// when
+			err := svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[0]))
+
+			// then
+			require.EqualError(s.T(), err, "invalid link: the verification link is invalid")
+		})
+	})
+
+	s.Run("only the last link is valid", func() {
+		// given
+		_, sender, svc := newService(newUserSignup())
+		require.NoError(s.T(), svc.InitEmailVerification(ctx, "johnny@kubesaw"))
+		require.NoError(s.T(), svc.InitEmailVerification(ctx, "johnny@kubesaw"))
+
+		// when
+		err := svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[0]))
+
+		// then
+		require.EqualError(s.T(), err, "invalid link: the verification link is invalid")
+		require.NoError(s.T(), svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[1])))
+	})
+
+	s.Run("link expired", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_LINK_EXPIRES_IN", "-1m")
+		_, sender, svc := newService(newUserSignup())
+		require.NoError(s.T(), svc.InitEmailVerification(ctx, "johnny@kubesaw"))
+
+		// when
+		err := svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[0]))
+
+		// then
+		require.EqualError(s.T(), err, "expired: the verification link has expired")
+	})
+
+	s.Run("link tampered with", func() {
+		// given
+		_, sender, svc := newService(newUserSignup())
+		require.NoError(s.T(), svc.InitEmailVerification(ctx, "johnny@kubesaw"))
+		s.SetRegistrationServiceSecret(map[string]string{configuration.EmailVerificationSigningKeyKey: "another key"})
+
+		// when
+		err := svc.VerifyEmailLink(ctx, tokenOf(s.T(), sender.body[0]))
+
+		// then
+		require.EqualError(s.T(), err, "invalid link: the verification link is invalid")
+	})
+
+	s.Run("daily limit exceeded", func() {
+		// given
+		userSignup := newUserSignup()
+		userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = "3"
+		userSignup.Annotations[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey] = time.Now().Format(verificationservice.TimestampLayout)
+		_, sender, svc := newService(userSignup)
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "daily limit exceeded: cannot generate new verification link")
+		assert.Empty(s.T(), sender.to)
+	})
+
+	s.Run("verification not required", func() {
+		// given
+		_, sender, svc := newService(testusersignup.NewUserSignup(testusersignup.WithEncodedName("johnny@kubesaw")))
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "forbidden request: verification link will not be sent")
+		assert.Empty(s.T(), sender.to)
+	})
+
+	s.Run("email not sent", func() {
+		// given
+		userSignup := newUserSignup()
+		fakeClient, sender, svc := newService(userSignup)
+		sender.err = fmt.Errorf("mock error")
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "mock error: error while sending verification link")
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		assert.NotContains(s.T(), signup.Annotations, verificationservice.EmailVerificationNonceAnnotationKey)
+	})
+
+	s.Run("disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_EMAIL_VERIFICATION_ENABLED", "false")
+		_, _, svc := newService(newUserSignup())
+
+		// when
+		err := svc.InitEmailVerification(ctx, "johnny@kubesaw")
+
+		// then
+		require.EqualError(s.T(), err, "forbidden request: email verification is not enabled")
+	})
+}
@@ -42,6 +42,7 @@ type ServiceImpl struct { // nolint:revive
 	namespaced.Client
 	HTTPClient          *http.Client
 	NotificationService sender.NotificationSender
+	EmailService        sender.EmailSender
 	SignupService       service.SignupService
 }
 
@@ -56,6 +57,7 @@ func NewVerificationService(client namespaced.Client) service.VerificationServic
 	return &ServiceImpl{
 		Client:              client,
 		NotificationService: sender.CreateNotificationSender(httpClient),
+		EmailService:        sender.NewSMTPEmailSender(configuration.GetRegistrationServiceConfig().EmailVerification()),
 		SignupService:       signupsvc.NewSignupService(client),
 	}
 }
@@ -83,8 +85,20 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 		return crterrors.NewBadRequest("forbidden request", "verification code will not be sent")
 	}
 
+	// check that the phone number is not blocked by the admins, before sending anything
+	prefix, err := blockedPhonePrefix(ctx, s.Client, e164PhoneNumber)
+	if err != nil {
+		log.Error(ctx, err, "error while looking up the blocked phone prefixes")
+		return crterrors.NewInternalError(err, "could not lookup the blocked phone prefixes")
+	}
+	if prefix != "" {
+		log.Infof(ctx, "phone verification attempted with a phone number matching the blocked prefix '%s'", prefix)
+		BlockedPhonePrefixCounterVec.WithLabelValues(prefix).Inc()
+		return crterrors.NewForbiddenError("phone number not allowed", fmt.Sprintf("cannot register using phone number: %s", e164PhoneNumber))
+	}
+
 	// Check if the provided phone number is already being used by another user
-	err := PhoneNumberAlreadyInUse(s.Client, username, e164PhoneNumber)
+	err = PhoneNumberAlreadyInUse(s.Client, username, e164PhoneNumber)
 	if err != nil {
 		e := &crterrors.Error{}
 		switch {
@@ -103,37 +117,12 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 	// Always set the phone hash label to indicate verification was initiated
 	labelValues[toolchainv1alpha1.UserSignupUserPhoneHashLabelKey] = phoneHash
 
-	// get the verification counter (i.e. the number of times the user has initiated phone verification within
-	// the last 24 hours)
-	verificationCounter := signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey]
-	var counter int
 	cfg := configuration.GetRegistrationServiceConfig()
-
 	dailyLimit := cfg.Verification().DailyLimit()
-	if verificationCounter != "" {
-		counter, err = strconv.Atoi(verificationCounter)
-		if err != nil {
-			// We shouldn't get an error here, but if we do, we should probably set verification counter to the daily
-			// limit so that we at least now have a valid value
-			log.Error(ctx, err, fmt.Sprintf("error converting annotation [%s] value [%s] to integer, on UserSignup: [%s]",
-				toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey,
-				signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey], signup.Name))
-			annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = strconv.Itoa(dailyLimit)
-			counter = dailyLimit
-		}
-	}
 
 	// read the current time
 	now := time.Now()
-
-	// If 24 hours has passed since the verification timestamp, then reset the timestamp and verification attempts
-	ts, parseErr := time.Parse(TimestampLayout, signup.Annotations[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey])
-	if parseErr != nil || now.After(ts.Add(24*time.Hour)) {
-		// Set a new timestamp
-		annotationValues[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey] = now.Format(TimestampLayout)
-		annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = "0"
-		counter = 0
-	}
+	counter := dailyVerificationCounter(ctx, signup, annotationValues, dailyLimit, now)
 
 	var initError error
 
@@ -195,14 +184,48 @@ func (s *ServiceImpl) InitVerification(ctx *gin.Context, username, e164PhoneNumb
 	updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate)
 	if updateErr != nil {
 		log.Error(ctx, updateErr, "error updating UserSignup")
-		return errors.New("there was an error while updating your account - please wait a moment before " +
-			"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com for " +
-			"assistance: error while verifying phone code")
+		return errUpdatingAccount()
 	}
 
 	return initError
 }
 
+// dailyVerificationCounter returns the number of times the user has initiated a verification, by phone or by email, within the
+// last 24 hours, and sets the annotations resetting the counter in the given annotation values when it's invalid or when 24 hours
+// have passed since the first verification
+func dailyVerificationCounter(ctx *gin.Context, signup *toolchainv1alpha1.UserSignup, annotationValues map[string]string, dailyLimit int, now time.Time) int {
+	var counter int
+	if verificationCounter := signup.Annotations[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey]; verificationCounter != "" {
+		var err error
+		counter, err = strconv.Atoi(verificationCounter)
+		if err != nil {
+			// We shouldn't get an error here, but if we do, we should probably set verification counter to the daily
+			// limit so that we at least now have a valid value
+			log.Error(ctx, err, fmt.Sprintf("error converting annotation [%s] value [%s] to integer, on UserSignup: [%s]",
+				toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey, verificationCounter, signup.Name))
+			annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = strconv.Itoa(dailyLimit)
+			counter = dailyLimit
+		}
+	}
+
+	// If 24 hours has passed since the verification timestamp, then reset the timestamp and verification attempts
+	ts, parseErr := time.Parse(TimestampLayout, signup.Annotations[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey])
+	if parseErr != nil || now.After(ts.Add(24*time.Hour)) {
+		// Set a new timestamp
+		annotationValues[toolchainv1alpha1.UserSignupVerificationInitTimestampAnnotationKey] = now.Format(TimestampLayout)
+		annotationValues[toolchainv1alpha1.UserSignupVerificationCounterAnnotationKey] = "0"
+		counter = 0
+	}
+	return counter
+}
+
+// errUpdatingAccount is returned when the UserSignup could not be updated with the verification details
+func errUpdatingAccount() error {
+	return fmt.Errorf("there was an error while updating your account - please wait a moment before "+
+		"trying again. If this error persists, please contact %s for assistance: error while verifying phone code",
+		configuration.GetRegistrationServiceConfig().Support().Channel().Contact())
+}
+
 func generateVerificationCode() (string, error) {
 	buf := make([]byte, codeLength)
 	if _, err := rand.Read(buf); err != nil {
@@ -233,27 +256,8 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 		return crterrors.NewInternalError(err, fmt.Sprintf("error retrieving usersignup with username '%s'", username))
 	}
 
-	// check if it's a reactivation
-	if activationCounterString, foundActivationCounter := signup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; foundActivationCounter && cfg.Verification().CaptchaAllowLowScoreReactivation() {
-		activationCounter, err := strconv.Atoi(activationCounterString)
-		if err != nil {
-			log.Error(ctx, err, "activation counter is not an integer value, checking required captcha score")
-			// require manual approval if captcha score below automatic verification threshold
-			if err = checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-				return err
-			}
-		} else if activationCounter == 1 {
-			// check required captcha score if it's not a reactivation
-			if err = checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-				return err
-			}
-		}
-	} else {
-		// when allowLowScoreReactivation is not enabled or no activation counter found
-		// require manual approval if captcha score below automatic verification threshold for all users
-		if err := checkRequiredManualApproval(ctx, signup, cfg); err != nil {
-			return err
-		}
+	if err := checkRequiredManualApprovalOnActivation(ctx, signup, cfg); err != nil {
+		return err
 	}
 
 	annotationValues := map[string]string{}
@@ -353,14 +357,33 @@ func (s *ServiceImpl) VerifyPhoneCode(ctx *gin.Context, username, code string) (
 	updateErr := signuppkg.PollUpdateSignup(ctx, doUpdate)
 	if updateErr != nil {
 		log.Error(ctx, updateErr, "error updating UserSignup")
-		return errors.New("there was an error while updating your account - please wait a moment before " +
-			"trying again. If this error persists, please contact the Developer Sandbox team at devsandbox@redhat.com for " +
-			"assistance: error while verifying phone code")
+		return errUpdatingAccount()
 	}
 
 	return
 }
 
+// checkRequiredManualApprovalOnActivation checks the captcha score of the user (see checkRequiredManualApproval), unless it's a
+// reactivation and the low scores are allowed for the reactivations
+func checkRequiredManualApprovalOnActivation(ctx *gin.Context, signup *toolchainv1alpha1.UserSignup, cfg configuration.RegistrationServiceConfig) error {
+	// check if it's a reactivation
+	if activationCounterString, foundActivationCounter := signup.Annotations[toolchainv1alpha1.UserSignupActivationCounterAnnotationKey]; foundActivationCounter && cfg.Verification().CaptchaAllowLowScoreReactivation() {
+		activationCounter, err := strconv.Atoi(activationCounterString)
+		if err != nil {
+			log.Error(ctx, err, "activation counter is not an integer value, checking required captcha score")
+			// require manual approval if captcha score below automatic verification threshold
+			return checkRequiredManualApproval(ctx, signup, cfg)
+		} else if activationCounter == 1 {
+			// check required captcha score if it's not a reactivation
+			return checkRequiredManualApproval(ctx, signup, cfg)
+		}
+		return nil
+	}
+	// when allowLowScoreReactivation is not enabled or no activation counter found
+	// require manual approval if captcha score below automatic verification threshold for all users
+	return checkRequiredManualApproval(ctx, signup, cfg)
+}
+
 // checkRequiredManualApproval compares the user captcha score with the configured required captcha score.
 // When the user score is lower than the required score an error is returned meaning that the user is considered "suspicious" and manual approval of the signup is required.
 func checkRequiredManualApproval(ctx *gin.Context, signup *toolchainv1alpha1.UserSignup, cfg configuration.RegistrationServiceConfig) error {
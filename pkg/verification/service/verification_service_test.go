@@ -32,6 +32,7 @@ import (
 	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
 
 	"github.com/gin-gonic/gin"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -480,6 +481,76 @@ func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenPhoneNumberI
 	require.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
 }
 
+func (s *TestVerificationServiceSuite) TestInitVerificationFailsWhenPhonePrefixBlocked() {
+	// Setup gock to intercept calls made to the Twilio API
+	gock.New("https://api.twilio.com").
+		Reply(http.StatusNoContent).
+		BodyString("")
+	defer gock.Off()
+	// call override config to ensure the factory option takes effect
+	s.OverrideApplicationDefault()
+
+	userSignup := testusersignup.NewUserSignup(
+		testusersignup.WithEncodedName("johny@kubesaw"),
+		testusersignup.VerificationRequiredAgo(time.Second))
+	blocklist := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      verificationservice.PhonePrefixBlocklistName,
+			Namespace: commontest.HostOperatorNs,
+		},
+		Data: map[string]string{
+			verificationservice.PhonePrefixBlocklistKey: "+1900\n 1987, +44",
+		},
+	}
+
+	s.Run("phone number with a blocked prefix", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup, blocklist)
+		blocked := promtestutil.ToFloat64(verificationservice.BlockedPhonePrefixCounterVec.WithLabelValues("+1987"))
+
+		// when
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+19875551122", "1")
+
+		// then
+		require.EqualError(s.T(), err, "phone number not allowed: cannot register using phone number: +19875551122")
+		assert.InDelta(s.T(), blocked+1, promtestutil.ToFloat64(verificationservice.BlockedPhonePrefixCounterVec.WithLabelValues("+1987")), 0.01)
+		signup := &toolchainv1alpha1.UserSignup{}
+		require.NoError(s.T(), fakeClient.Get(gocontext.TODO(), client.ObjectKeyFromObject(userSignup), signup))
+		require.Empty(s.T(), signup.Annotations[toolchainv1alpha1.UserSignupVerificationCodeAnnotationKey])
+	})
+
+	s.Run("phone number without a blocked prefix", func() {
+		// given
+		_, application := testutil.PrepareInClusterApp(s.T(), userSignup, blocklist)
+
+		// when
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+19015551122", "1")
+
+		// then
+		require.NoError(s.T(), err)
+	})
+
+	s.Run("blocklist lookup fails", func() {
+		// given
+		fakeClient, application := testutil.PrepareInClusterApp(s.T(), userSignup, blocklist)
+		fakeClient.MockGet = func(ctx gocontext.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*corev1.ConfigMap); ok {
+				return errors.New("get failed")
+			}
+			return fakeClient.Client.Get(ctx, key, obj, opts...)
+		}
+
+		// when
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		err := application.VerificationService().InitVerification(ctx, userSignup.Spec.IdentityClaims.PreferredUsername, "+19875551122", "1")
+
+		// then
+		require.EqualError(s.T(), err, "get failed: could not lookup the blocked phone prefixes")
+	})
+}
+
 func (s *TestVerificationServiceSuite) TestInitVerificationOKWhenPhoneNumberInUseByDeactivatedUserSignup() {
 	// Setup gock to intercept calls made to the Twilio API
 	gock.New("https://api.twilio.com").
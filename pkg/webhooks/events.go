@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/toolchain-common/pkg/condition"
+	"github.com/codeready-toolchain/toolchain-common/pkg/states"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// The types of the events of the transitions of the UserSignups
+const (
+	// EventCreated is sent when a UserSignup is created
+	EventCreated = "signup.created"
+	// EventVerificationCompleted is sent when the user completed the verification required by their UserSignup
+	EventVerificationCompleted = "signup.verification_completed"
+	// EventApproved is sent when a UserSignup is approved, automatically or manually
+	EventApproved = "signup.approved"
+	// EventProvisioned is sent when the account of an approved UserSignup is provisioned
+	EventProvisioned = "signup.provisioned"
+	// EventDeactivated is sent when a UserSignup is deactivated
+	EventDeactivated = "signup.deactivated"
+	// EventBanned is sent when a UserSignup is banned
+	EventBanned = "signup.banned"
+)
+
+// Event is the body of the requests to the webhooks
+type Event struct {
+	// ID identifies the event, so that the webhooks can ignore the events delivered several times, eg. by several replicas of the
+	// registration service when they can't claim the events (see Claimer)
+	ID string `json:"id"`
+	// Type is the type of the event, eg. 'signup.approved'
+	Type string `json:"type"`
+	// Time is the time the event was observed at
+	Time time.Time `json:"time"`
+	// UserSignup is the UserSignup which transitioned
+	UserSignup UserSignup `json:"userSignup"`
+}
+
+// UserSignup is the UserSignup of an event
+type UserSignup struct {
+	// Name is the name of the UserSignup resource
+	Name string `json:"name"`
+	// Username is the preferred username of the user in SSO
+	Username string `json:"username"`
+	// UserID is the ID of the user in SSO
+	UserID string `json:"userID"`
+	// CompliantUsername is the username of the user in the clusters, once provisioned
+	CompliantUsername string `json:"compliantUsername,omitempty"`
+	// State is the value of the state label of the UserSignup
+	State string `json:"state"`
+}
+
+// newEvent returns a new event of the given type for the given UserSignup, identified by the UID and the version of the UserSignup
+func newEvent(eventType string, userSignup *toolchainv1alpha1.UserSignup, now time.Time) Event {
+	return Event{
+		ID:   fmt.Sprintf("%s-%s-%s", userSignup.UID, userSignup.ResourceVersion, eventType),
+		Type: eventType,
+		Time: now.UTC(),
+		UserSignup: UserSignup{
+			Name:              userSignup.Name,
+			Username:          userSignup.Spec.IdentityClaims.PreferredUsername,
+			UserID:            userSignup.Spec.IdentityClaims.UserID,
+			CompliantUsername: userSignup.Status.CompliantUsername,
+			State:             userSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey],
+		},
+	}
+}
+
+// Transitions returns the events of the transitions from the given old version of a UserSignup to the given new one.
+// The old version is nil when the UserSignup was created.
+func Transitions(oldSignup, newSignup *toolchainv1alpha1.UserSignup, now time.Time) []Event {
+	if oldSignup == nil {
+		return []Event{newEvent(EventCreated, newSignup, now)}
+	}
+	events := []Event{}
+	if states.VerificationRequired(oldSignup) && !states.VerificationRequired(newSignup) {
+		events = append(events, newEvent(EventVerificationCompleted, newSignup, now))
+	}
+	oldState := oldSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey]
+	newState := newSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey]
+	if oldState != newState {
+		switch newState {
+		case toolchainv1alpha1.UserSignupStateLabelValueApproved:
+			events = append(events, newEvent(EventApproved, newSignup, now))
+		case toolchainv1alpha1.UserSignupStateLabelValueDeactivated:
+			events = append(events, newEvent(EventDeactivated, newSignup, now))
+		case toolchainv1alpha1.UserSignupStateLabelValueBanned:
+			events = append(events, newEvent(EventBanned, newSignup, now))
+		}
+	}
+	if !provisioned(oldSignup) && provisioned(newSignup) {
+		events = append(events, newEvent(EventProvisioned, newSignup, now))
+	}
+	return events
+}
+
+// provisioned returns true if the given UserSignup is approved and its account is provisioned
+func provisioned(userSignup *toolchainv1alpha1.UserSignup) bool {
+	return userSignup.Labels[toolchainv1alpha1.UserSignupStateLabelKey] == toolchainv1alpha1.UserSignupStateLabelValueApproved &&
+		condition.IsTrue(userSignup.Status.Conditions, toolchainv1alpha1.UserSignupComplete)
+}
+
+// WatchUserSignups dispatches the events of the transitions of the UserSignups seen by the given informers to the webhooks.
+// The UserSignups of the initial list of the informer are not reported as created.
+func WatchUserSignups(ctx context.Context, informers cache.Informers, dispatcher *Dispatcher) error {
+	if !dispatcher.Enabled() {
+		return nil
+	}
+	informer, err := informers.GetInformer(ctx, &toolchainv1alpha1.UserSignup{})
+	if err != nil {
+		return err
+	}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if userSignup, ok := obj.(*toolchainv1alpha1.UserSignup); ok && !isInInitialList {
+				for _, event := range Transitions(nil, userSignup, time.Now()) {
+					dispatcher.Dispatch(event)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldSignup, ok := oldObj.(*toolchainv1alpha1.UserSignup)
+			newSignup, ok2 := newObj.(*toolchainv1alpha1.UserSignup)
+			if !ok || !ok2 || oldSignup.ResourceVersion == newSignup.ResourceVersion {
+				// periodic resync
+				return
+			}
+			for _, event := range Transitions(oldSignup, newSignup, time.Now()) {
+				dispatcher.Dispatch(event)
+			}
+		},
+	})
+	return err
+}
@@ -0,0 +1,103 @@
+package webhooks_test
+
+import (
+	"testing"
+	"time"
+
+	toolchainv1alpha1 "github.com/codeready-toolchain/api/api/v1alpha1"
+	"github.com/codeready-toolchain/registration-service/pkg/webhooks"
+	testusersignup "github.com/codeready-toolchain/toolchain-common/pkg/test/usersignup"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitions(t *testing.T) {
+	now := time.Now()
+	typesOf := func(events []webhooks.Event) []string {
+		types := []string{}
+		for _, event := range events {
+			types = append(types, event.Type)
+		}
+		return types
+	}
+
+	t.Run("created", func(t *testing.T) {
+		// given
+		userSignup := testusersignup.NewUserSignup(testusersignup.WithName("smith"))
+		userSignup.UID = "1234"
+		userSignup.ResourceVersion = "5"
+
+		// when
+		events := webhooks.Transitions(nil, userSignup, now)
+
+		// then
+		require.Len(t, events, 1)
+		assert.Equal(t, webhooks.Event{
+			ID:   "1234-5-signup.created",
+			Type: webhooks.EventCreated,
+			Time: now.UTC(),
+			UserSignup: webhooks.UserSignup{
+				Name:     "smith",
+				Username: "smith",
+				UserID:   userSignup.Spec.IdentityClaims.UserID,
+			},
+		}, events[0])
+	})
+
+	for name, tc := range map[string]struct {
+		old      *toolchainv1alpha1.UserSignup
+		new      *toolchainv1alpha1.UserSignup
+		expected []string
+	}{
+		"verification completed": {
+			old: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueNotReady)),
+			new: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+			expected: []string{webhooks.EventVerificationCompleted},
+		},
+		"verification completed and approved": {
+			old: testusersignup.NewUserSignup(testusersignup.VerificationRequired(),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueNotReady)),
+			new: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			expected: []string{webhooks.EventVerificationCompleted, webhooks.EventApproved},
+		},
+		"provisioned": {
+			old: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			new: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			expected: []string{webhooks.EventProvisioned},
+		},
+		"deactivated": {
+			old: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			new: testusersignup.NewUserSignup(testusersignup.SignupComplete(toolchainv1alpha1.UserSignupUserDeactivatedReason),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueDeactivated)),
+			expected: []string{webhooks.EventDeactivated},
+		},
+		"banned": {
+			old: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValuePending)),
+			new: testusersignup.NewUserSignup(
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueBanned)),
+			expected: []string{webhooks.EventBanned},
+		},
+		"no transition": {
+			old: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			new: testusersignup.NewUserSignup(testusersignup.SignupComplete(""),
+				testusersignup.WithStateLabel(toolchainv1alpha1.UserSignupStateLabelValueApproved)),
+			expected: []string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			// when
+			events := webhooks.Transitions(tc.old, tc.new, now)
+
+			// then
+			assert.Equal(t, tc.expected, typesOf(events))
+		})
+	}
+}
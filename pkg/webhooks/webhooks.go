@@ -0,0 +1,198 @@
+// Package webhooks notifies the configured webhooks of the transitions of the UserSignups (see the Webhooks settings), with
+// signed JSON events.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/log"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// EventHeader is the header of the requests to the webhooks with the type of the event
+	EventHeader = "X-Sandbox-Event"
+	// DeliveryHeader is the header of the requests to the webhooks with the ID of the event, which is the same for all the
+	// attempts to deliver the event, and for all the replicas of the registration service
+	DeliveryHeader = "X-Sandbox-Delivery"
+	// SignatureHeader is the header of the requests to the webhooks with the HMAC-SHA256 of the body, keyed with the secret of
+	// the webhooks, in the 'sha256=<hex>' format
+	SignatureHeader = "X-Sandbox-Signature-256"
+
+	// queueSize is the number of events waiting to be delivered to each webhook, beyond which the new events are dropped
+	queueSize = 1000
+)
+
+const (
+	// ResultDelivered is the result of the events delivered to a webhook
+	ResultDelivered = "delivered"
+	// ResultRetried is the result of the attempts to deliver an event to a webhook which failed, and are retried
+	ResultRetried = "retried"
+	// ResultFailed is the result of the events which couldn't be delivered to a webhook after all the attempts
+	ResultFailed = "failed"
+	// ResultDropped is the result of the events dropped because the queue of the webhook was full
+	ResultDropped = "dropped"
+	// ResultClaimed is the result of the events delivered to a webhook by another replica of the registration service
+	ResultClaimed = "claimed"
+)
+
+var (
+	// DeliveriesCounterVec counts the deliveries of the events to the webhooks, by type of event (via the `event` label) and
+	// result (via the `result` label)
+	DeliveriesCounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sandbox_webhook_deliveries_total",
+		Help: "The number of deliveries of the events of the UserSignups to the webhooks, per event and result",
+	}, []string{"event", "result"})
+	// DeliveryDurationHistogram observes the duration of the requests to the webhooks
+	DeliveryDurationHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "sandbox_webhook_delivery_duration_seconds",
+		Help:    "The duration of the requests delivering the events of the UserSignups to the webhooks",
+		Buckets: prometheus.DefBuckets,
+	})
+)
+
+// RegisterWebhooksMetrics registers the metrics of the deliveries to the webhooks in the given registry
+func RegisterWebhooksMetrics(registry *prometheus.Registry) {
+	registry.MustRegister(DeliveriesCounterVec, DeliveryDurationHistogram)
+}
+
+// Claimer claims the keys shared by the replicas of the registration service, eg. the shared cache of the proxy
+type Claimer interface {
+	// Claim returns true if this replica is the first one to claim the given key
+	Claim(ctx context.Context, key string) bool
+}
+
+// Dispatcher delivers the events to the webhooks, each webhook having its own queue so that a webhook which is down doesn't
+// delay the deliveries to the other ones. The failed deliveries are retried with an exponential backoff (see the MaxAttempts and
+// RetryInterval settings).
+type Dispatcher struct {
+	queues []chan Event
+}
+
+// NewDispatcher returns a new Dispatcher of the events to the webhooks of the URLs setting, which delivers the events until the
+// given context is done. The URLs which are not HTTPS are ignored in the production environment.
+// Since the events are seen by all the replicas of the registration service, each event is only delivered to a webhook by the
+// replica which claimed it first with the given claimer, unless nil.
+func NewDispatcher(ctx context.Context, claimer Claimer) *Dispatcher {
+	cfg := configuration.GetRegistrationServiceConfig()
+	d := &Dispatcher{}
+	for _, endpoint := range cfg.Webhooks().URLs() {
+		if u, err := url.Parse(endpoint); err != nil || (u.Scheme != "https" && cfg.IsProdEnvironment()) {
+			log.Error(nil, fmt.Errorf("invalid URL: '%s'", endpoint), "ignoring the webhook, whose URL is not an HTTPS URL")
+			continue
+		}
+		queue := make(chan Event, queueSize)
+		d.queues = append(d.queues, queue)
+		go deliverAll(ctx, endpoint, queue, claimer)
+	}
+	return d
+}
+
+// Enabled returns true if there is at least one webhook to notify
+func (d *Dispatcher) Enabled() bool {
+	return len(d.queues) > 0
+}
+
+// Dispatch queues the given event for delivery to all the webhooks, without waiting for the deliveries
+func (d *Dispatcher) Dispatch(event Event) {
+	for _, queue := range d.queues {
+		select {
+		case queue <- event:
+		default:
+			log.Infof(nil, "dropping the '%s' event '%s' since the queue of the webhook is full", event.Type, event.ID)
+			DeliveriesCounterVec.WithLabelValues(event.Type, ResultDropped).Inc()
+		}
+	}
+}
+
+// deliverAll delivers the events of the given queue to the webhook of the given URL until the given context is done, except the
+// events claimed by another replica with the given claimer (if not nil)
+func deliverAll(ctx context.Context, endpoint string, queue <-chan Event, claimer Claimer) {
+	client := &http.Client{Timeout: configuration.GetRegistrationServiceConfig().Webhooks().Timeout()}
+	// the URL of the webhook may contain credentials, hence the hash in the keys of the claims
+	sum := sha256.Sum256([]byte(endpoint))
+	webhookID := hex.EncodeToString(sum[:])
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-queue:
+			if claimer != nil && !claimer.Claim(ctx, fmt.Sprintf("webhooks:%s:%s", webhookID, event.ID)) {
+				DeliveriesCounterVec.WithLabelValues(event.Type, ResultClaimed).Inc()
+				continue
+			}
+			deliver(ctx, client, endpoint, event)
+		}
+	}
+}
+
+// deliver delivers the given event to the webhook of the given URL, and retries until it's delivered or the MaxAttempts are reached
+func deliver(ctx context.Context, client *http.Client, endpoint string, event Event) {
+	cfg := configuration.GetRegistrationServiceConfig().Webhooks()
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error(nil, err, fmt.Sprintf("unable to marshal the '%s' event '%s'", event.Type, event.ID))
+		DeliveriesCounterVec.WithLabelValues(event.Type, ResultFailed).Inc()
+		return
+	}
+	interval := cfg.RetryInterval()
+	for attempt := 1; ; attempt++ {
+		err := send(ctx, client, endpoint, event, body, cfg.Secret())
+		if err == nil {
+			DeliveriesCounterVec.WithLabelValues(event.Type, ResultDelivered).Inc()
+			return
+		}
+		if attempt >= cfg.MaxAttempts() {
+			log.Error(nil, err, fmt.Sprintf("unable to deliver the '%s' event '%s' to the webhook after %d attempts", event.Type, event.ID, attempt))
+			DeliveriesCounterVec.WithLabelValues(event.Type, ResultFailed).Inc()
+			return
+		}
+		DeliveriesCounterVec.WithLabelValues(event.Type, ResultRetried).Inc()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		interval *= 2
+	}
+}
+
+// send posts the given body of the given event to the webhook of the given URL, signed with the given secret
+func send(ctx context.Context, client *http.Client, endpoint string, event Event, body []byte, secret string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(DeliveryHeader, event.ID)
+	req.Header.Set(SignatureHeader, Sign(body, secret))
+	start := time.Now()
+	resp, err := client.Do(req)
+	DeliveryDurationHistogram.Observe(time.Since(start).Seconds())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("the webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the signature of the given body with the given secret, as set in the SignatureHeader
+func Sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
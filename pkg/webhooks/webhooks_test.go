@@ -0,0 +1,194 @@
+package webhooks_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codeready-toolchain/registration-service/pkg/configuration"
+	"github.com/codeready-toolchain/registration-service/pkg/webhooks"
+	"github.com/codeready-toolchain/registration-service/test"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TestWebhooksSuite struct {
+	test.UnitTestSuite
+}
+
+func TestRunWebhooksSuite(t *testing.T) {
+	suite.Run(t, &TestWebhooksSuite{test.UnitTestSuite{}})
+}
+
+// webhook records the events it receives, and responds with the given statuses first, and with 200 then
+type webhook struct {
+	mu       sync.Mutex
+	statuses []int
+	events   []webhooks.Event
+	headers  []http.Header
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	if r.Header.Get(webhooks.SignatureHeader) != webhooks.Sign(body, "s3cr3t") {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if len(w.statuses) > 0 {
+		rw.WriteHeader(w.statuses[0])
+		w.statuses = w.statuses[1:]
+		return
+	}
+	event := webhooks.Event{}
+	_ = json.Unmarshal(body, &event)
+	w.events = append(w.events, event)
+	w.headers = append(w.headers, r.Header)
+}
+
+func (w *webhook) received() []webhooks.Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]webhooks.Event{}, w.events...)
+}
+
+// claimer claims the keys which were not claimed yet, as the shared cache of the proxy
+type claimer struct {
+	mu      sync.Mutex
+	claimed map[string]bool
+}
+
+func (c *claimer) Claim(_ context.Context, key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.claimed[key] {
+		return false
+	}
+	c.claimed[key] = true
+	return true
+}
+
+func (s *TestWebhooksSuite) TestDispatcher() {
+	// given
+	s.SetRegistrationServiceSecret(map[string]string{configuration.WebhooksSecretKey: "s3cr3t"})
+	s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_MAX_ATTEMPTS", "3")
+	s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_RETRY_INTERVAL", "10ms")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	event := webhooks.Event{
+		ID:         "1234-5-signup.approved",
+		Type:       webhooks.EventApproved,
+		Time:       time.Now().UTC().Truncate(time.Second),
+		UserSignup: webhooks.UserSignup{Name: "smith", Username: "smith", State: "approved"},
+	}
+
+	s.Run("delivered to all the webhooks", func() {
+		// given
+		first, second := &webhook{}, &webhook{}
+		firstSrv, secondSrv := httptest.NewServer(first), httptest.NewServer(second)
+		defer firstSrv.Close()
+		defer secondSrv.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", firstSrv.URL+","+secondSrv.URL)
+		delivered := testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultDelivered))
+		dispatcher := webhooks.NewDispatcher(ctx, nil)
+		require.True(s.T(), dispatcher.Enabled())
+
+		// when
+		dispatcher.Dispatch(event)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return len(first.received()) == 1 && len(second.received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), event, first.received()[0])
+		assert.Equal(s.T(), event, second.received()[0])
+		assert.Equal(s.T(), webhooks.EventApproved, first.headers[0].Get(webhooks.EventHeader))
+		assert.Equal(s.T(), "1234-5-signup.approved", first.headers[0].Get(webhooks.DeliveryHeader))
+		assert.InDelta(s.T(), delivered+2, testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultDelivered)), 0.01)
+	})
+
+	s.Run("retried", func() {
+		// given
+		hook := &webhook{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError}}
+		srv := httptest.NewServer(hook)
+		defer srv.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", srv.URL)
+		retried := testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultRetried))
+
+		// when
+		webhooks.NewDispatcher(ctx, nil).Dispatch(event)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return len(hook.received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.InDelta(s.T(), retried+2, testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultRetried)), 0.01)
+	})
+
+	s.Run("failed after the max attempts", func() {
+		// given
+		hook := &webhook{statuses: []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable}}
+		srv := httptest.NewServer(hook)
+		defer srv.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", srv.URL)
+		failed := testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultFailed))
+
+		// when
+		webhooks.NewDispatcher(ctx, nil).Dispatch(event)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventApproved, webhooks.ResultFailed)) == failed+1
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Empty(s.T(), hook.received())
+	})
+
+	s.Run("claimed by another replica", func() {
+		// given
+		hook := &webhook{}
+		srv := httptest.NewServer(hook)
+		defer srv.Close()
+		s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", srv.URL)
+		claimer := &claimer{claimed: map[string]bool{}}
+		// the event of the other replica, which has its own dispatcher
+		otherEvent := event
+		otherEvent.ID = "1234-6-signup.provisioned"
+		otherEvent.Type = webhooks.EventProvisioned
+		webhooks.NewDispatcher(ctx, claimer).Dispatch(otherEvent)
+		require.Eventually(s.T(), func() bool {
+			return len(hook.received()) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+		claimed := testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventProvisioned, webhooks.ResultClaimed))
+
+		// when
+		dispatcher := webhooks.NewDispatcher(ctx, claimer)
+		dispatcher.Dispatch(otherEvent)
+		dispatcher.Dispatch(event)
+
+		// then
+		require.Eventually(s.T(), func() bool {
+			return len(hook.received()) == 2
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(s.T(), []webhooks.Event{otherEvent, event}, hook.received())
+		assert.InDelta(s.T(), claimed+1, testutil.ToFloat64(webhooks.DeliveriesCounterVec.WithLabelValues(webhooks.EventProvisioned, webhooks.ResultClaimed)), 0.01)
+	})
+
+	s.Run("disabled", func() {
+		// given
+		s.T().Setenv("REGISTRATION_SERVICE_WEBHOOKS_URLS", "")
+
+		// when
+		dispatcher := webhooks.NewDispatcher(ctx, nil)
+
+		// then
+		assert.False(s.T(), dispatcher.Enabled())
+	})
+}
@@ -28,6 +28,13 @@ func AssertError(t *testing.T, actualResponse *httptest.ResponseRecorder, expect
 	if len(expectedMessageAndDetails) > 0 {
 		details = expectedMessageAndDetails[1]
 	}
+	// the support contact details are only included in the server errors
+	if expectedErrorCode >= http.StatusInternalServerError {
+		assert.NotNil(t, data.Support, "server errors should contain the support contact details")
+	} else {
+		assert.Nil(t, data.Support, "client errors should not contain the support contact details")
+	}
+	data.Support = nil
 	assert.Equal(t, &errors.Error{
 		Status:  http.StatusText(expectedErrorCode),
 		Code:    expectedErrorCode,
@@ -48,6 +48,18 @@ func (m *SignupService) GetSignup(_ *gin.Context, username string, _ bool) (*sig
 func (m *SignupService) Signup(_ *gin.Context) (*toolchainv1alpha1.UserSignup, error) {
 	return nil, nil
 }
+func (m *SignupService) Deactivate(_ *gin.Context, _ string) error {
+	return nil
+}
+func (m *SignupService) UpdateProfile(_ *gin.Context, _ string, _ signup.Profile) error {
+	return nil
+}
+func (m *SignupService) AcceptTermsOfService(_ *gin.Context, _, _ string) error {
+	return nil
+}
+func (m *SignupService) Anonymize(_ *gin.Context, _ string) error {
+	return nil
+}
 func (m *SignupService) UpdateUserSignup(_ *toolchainv1alpha1.UserSignup) (*toolchainv1alpha1.UserSignup, error) {
 	return nil, nil
 }
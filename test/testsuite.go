@@ -15,6 +15,7 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -82,6 +83,45 @@ func (s *UnitTestSuite) SetSecret(secret *corev1.Secret) {
 	configuration.SetClient(s.ConfigClient)
 }
 
+// SetRegistrationServiceSecret sets the given secret settings of the registration service (see the keys in the configuration package)
+// in the secret referenced by the ToolchainConfig CR. The ToolchainConfig CR is updated to reference a new secret if it doesn't
+// reference any yet, and the values of the referenced secret which are not given are kept.
+func (s *UnitTestSuite) SetRegistrationServiceSecret(values map[string]string) {
+	current := &toolchainv1alpha1.ToolchainConfig{}
+	err := s.ConfigClient.Get(context.TODO(), types.NamespacedName{Name: "config", Namespace: test.HostOperatorNs}, current)
+	require.NoError(s.T(), err)
+	ref := current.Spec.Host.RegistrationService.Verification.Secret.Ref
+	if ref == nil || *ref == "" {
+		name := "registration-service-secrets"
+		current.Spec.Host.RegistrationService.Verification.Secret.Ref = &name
+		err = s.ConfigClient.Update(context.TODO(), current)
+		require.NoError(s.T(), err)
+		ref = &name
+	}
+
+	secret := &corev1.Secret{}
+	err = s.ConfigClient.Get(context.TODO(), types.NamespacedName{Name: *ref, Namespace: test.HostOperatorNs}, secret)
+	if errors.IsNotFound(err) {
+		secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: *ref, Namespace: test.HostOperatorNs}}
+	} else {
+		require.NoError(s.T(), err)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	for key, value := range values {
+		secret.Data[key] = []byte(value)
+	}
+	if secret.ResourceVersion == "" {
+		err = s.ConfigClient.Create(context.TODO(), secret)
+	} else {
+		err = s.ConfigClient.Update(context.TODO(), secret)
+	}
+	require.NoError(s.T(), err)
+	// set client
+	configuration.SetClient(s.ConfigClient)
+}
+
 func (s *UnitTestSuite) DefaultConfig() configuration.RegistrationServiceConfig {
 	// use a new configuration client to fully reset configuration
 	s.ConfigClient = test.NewFakeClient(s.T())